@@ -4,20 +4,50 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"math/rand/v2"
+	"net"
 	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"text/tabwriter"
 	"time"
 
 	"github.com/spf13/cobra"
 
+	"hist_scanner/internal/admx"
+	"hist_scanner/internal/audit"
 	"hist_scanner/internal/browser"
+	"hist_scanner/internal/buildinfo"
+	"hist_scanner/internal/catalog"
 	"hist_scanner/internal/config"
+	"hist_scanner/internal/control"
+	"hist_scanner/internal/db"
+	"hist_scanner/internal/devicekey"
 	"hist_scanner/internal/dto"
+	"hist_scanner/internal/export"
+	"hist_scanner/internal/extension"
+	"hist_scanner/internal/fixture"
+	"hist_scanner/internal/importer"
 	"hist_scanner/internal/installer"
+	"hist_scanner/internal/logging"
+	"hist_scanner/internal/notice"
+	"hist_scanner/internal/optout"
+	"hist_scanner/internal/packager"
 	"hist_scanner/internal/platform"
+	"hist_scanner/internal/proxylog"
 	"hist_scanner/internal/scanner"
-	"hist_scanner/internal/sender"
+	"hist_scanner/internal/seal"
+	"hist_scanner/internal/service"
 	"hist_scanner/internal/state"
 )
 
@@ -33,15 +63,50 @@ var (
 	apiKey      string
 	stateFile   string
 	logFile     string
+	logLevel    string
+	logFormat   string
 	initialDays int
 	chunkSizeKB int
 	compress    bool
 	timeout     time.Duration
 	dryRun      bool
+	fullScan    bool
+	fullDays    int
+	envVars     []string
+
+	runUsers    []string
+	runBrowsers []string
+	runProfiles []string
+
+	runSince string
+	runUntil string
+
+	runRoot      string
+	runRootUsers []string
+)
+
+// Install exit codes, stable for MDM deployment scripts
+const (
+	exitInstallInvalid    = 10 // Invalid configuration or options
+	exitInstallPrivileges = 11 // Not running as root/Administrator
+	exitInstallFailed     = 12 // Copying files or registering with the scheduler failed
 )
 
+// exitError carries a specific process exit code
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
 		os.Exit(2)
 	}
 }
@@ -56,8 +121,40 @@ and profiles on a machine and sends the data to a server for security audit.`,
 var runCmd = &cobra.Command{
 	Use:   "run",
 	Short: "Run the browser history scan",
-	Long:  `Scans browser history from all users and profiles and sends to server.`,
-	RunE:  runScan,
+	Long: `Scans browser history from all users and profiles and sends to server.
+
+With --root, the users of a mounted disk image or offline system are scanned
+instead, and every browser path is resolved under that directory. Their users
+come from the image (the Users folder on Windows and macOS, etc/passwd and
+home on Linux and FreeBSD) or from --root-user.`,
+	RunE: runScan,
+}
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run scans periodically in the foreground",
+	Long: `Runs a scan immediately and then once per interval until stopped.
+Used by the Windows service install mode; can also be supervised by any process manager.`,
+	RunE: runDaemon,
+}
+
+var ctlCmd = &cobra.Command{
+	Use:   "ctl <scan|status|reload|flush>",
+	Short: "Control a running daemon",
+	Long: `Sends a command to the daemon through its local control socket
+(control_socket, by default control.sock next to the state file):
+
+  scan    start a scan now
+  status  show whether a scan is running, the last run and the next scan
+  reload  reload the configuration, used from the next scan
+  flush   run a scan and wait for it; unsent visits stay behind the scan
+          positions in the browser history, so this sends everything pending
+
+The socket is only accessible to the daemon's account; run as root or
+Administrator for a system daemon. Exits with code 1 if the command failed.`,
+	Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	ValidArgs: control.Commands,
+	RunE:      runCtl,
 }
 
 var installCmd = &cobra.Command{
@@ -67,6 +164,34 @@ var installCmd = &cobra.Command{
 	RunE:  runInstall,
 }
 
+var installStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show installation status",
+	Long: `Reports whether the scanner is installed, the scheduler mechanism in use,
+the configured interval, the last run reported by the scheduler, and the
+binary, config and state paths.`,
+	RunE: runInstallStatus,
+}
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Upgrade an installed scanner in place",
+	Long: `Replaces the installed binary with this one and migrates the config and
+state files to the current format. Scheduler entries, API keys and scan
+watermarks are kept.`,
+	RunE: runUpgrade,
+}
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check the installation for drift",
+	Long: `Checks that the installed binary matches this one and the hash recorded
+at install (SHA-256), that the config file is only readable by its owner
+(0600), and that the scheduler entry still runs the installed binary. With
+--fix, repairs what it can. Exits with code 1 if any check fails.`,
+	RunE: runVerify,
+}
+
 var uninstallCmd = &cobra.Command{
 	Use:   "uninstall",
 	Short: "Remove scanner from system scheduler",
@@ -74,6 +199,229 @@ var uninstallCmd = &cobra.Command{
 	RunE:  runUninstall,
 }
 
+var packageCmd = &cobra.Command{
+	Use:   "package",
+	Short: "Build a deb or rpm package",
+	Long: `Builds a native Linux package containing the scanner binary, a default
+config file and the systemd service and timer units.`,
+	RunE: runPackage,
+}
+
+var admxCmd = &cobra.Command{
+	Use:   "admx",
+	Short: "Generate Group Policy administrative templates",
+	Long: `Writes hist_scanner.admx and en-US/hist_scanner.adml for managing the
+scanner configuration with Group Policy. Copy them to the PolicyDefinitions
+folder (or the central store in SYSVOL).`,
+	RunE: runADMX,
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export browser history to a local report",
+	Long: `Scans the browser history of all users (or the selected ones) and writes
+it to a local HTML, CSV or JSON Lines report. Nothing is sent to a server and
+the state file is not changed. With --encrypt (or encrypt_exports) the report
+is encrypted with a key derived from state_key or bound to this machine; open
+it with the decrypt command.`,
+	Args: cobra.NoArgs,
+	RunE: runExport,
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Send browser history from an export file",
+	Long: `Reads history exported outside the scanner and sends it like scanned
+history, for --user or --ip: excluded visits are dropped, services tagged and
+the payloads go to the configured sinks. Formats:
+
+  takeout       BrowserHistory.json or MyActivity.json of a Google Takeout archive
+  csv           a header with a url column and a time column (time, visit_time,
+                timestamp, date...), in RFC 3339, "2006-01-02 15:04:05" (UTC)
+                or Unix seconds, milliseconds or microseconds
+  firefox-json  a Firefox bookmark backup (.json or .jsonlz4); each bookmark
+                is a visit at the time it was added
+
+Payloads are marked as imported. Scan positions are not changed and the run is
+not recorded as a scan. Exits with code 1 if some visits could not be sent
+and 2 if none were.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runImport,
+}
+
+var proxyCmd = &cobra.Command{
+	Use:   "proxy <access.log>",
+	Short: "Send visits from a proxy access log",
+	Long: `Reads the access log of a forward proxy and sends its requests like scanned
+history: excluded visits are dropped, services tagged and the payloads go to
+the configured sinks. Formats:
+
+  squid   Squid native access.log format
+  common  Common or Combined Log Format, with absolute request URLs
+
+CONNECT requests become visits of https://host/. Requests are sent for their
+authenticated user, the user proxy_clients maps their client address to, or
+the address. Repeated requests of a client to a host within a minute are one
+visit. The position in the log is kept in the state file, so each run sends
+the lines appended since the last one; the first run sends the last
+initial_days. With --follow the log is read every --poll until interrupted,
+across rotations. Exits with code 1 if some visits could not be sent and 2 if
+none were.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runProxy,
+}
+
+var decryptCmd = &cobra.Command{
+	Use:   "decrypt <file>",
+	Short: "Decrypt an encrypted export",
+	Long: `Decrypts a report written by export --encrypt. It opens only on the machine
+that wrote it, or with the same state_key.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDecrypt,
+}
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Show SaaS usage and unsanctioned services per user",
+	Long: `Scans the last days of browser history locally, aggregates it by
+registrable domain (eTLD+1) and tags the domains with the SaaS catalog
+(built in, updated by catalog_file). Prints the top services each user visits that are not listed in
+sanctioned_services. Nothing is sent to a server.`,
+	Args: cobra.NoArgs,
+	RunE: runReport,
+}
+
+var extensionsCmd = &cobra.Command{
+	Use:   "extensions",
+	Short: "List browser extensions and rate their risk",
+	Long: `Lists the extensions installed in each user's browser profiles (Chromium
+browsers and Firefox) and rates their risk from their permissions: access to
+all sites, sensitive permissions such as cookies, clipboardRead or
+webRequest, and the ids in risky_extensions. Nothing is sent to a server.`,
+	Args: cobra.NoArgs,
+	RunE: runExtensions,
+}
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Inspect the audit log of sent data",
+}
+
+var auditVerifyCmd = &cobra.Command{
+	Use:   "verify [path]",
+	Short: "Check the hash chain of the audit log",
+	Long: `Checks that no record of the audit log (audit_log, or the given path) was
+modified, removed, inserted or reordered. Exits with code 1 if the chain is
+broken.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runAuditVerify,
+}
+
+var optoutCmd = &cobra.Command{
+	Use:   "optout",
+	Short: "Manage signed per-user opt-out markers",
+}
+
+var optoutKeygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Generate the opt-out signing key pair",
+	Long: `Writes a new private key to --key-file (readable only by its owner) and
+prints the public key to set as optout_public_key. Keep the private key off
+scanned machines.`,
+	Args: cobra.NoArgs,
+	RunE: runOptoutKeygen,
+}
+
+var optoutSignCmd = &cobra.Command{
+	Use:   "sign <user>",
+	Short: "Sign an opt-out marker for a user",
+	Long: `Signs an opt-out marker for the user with the private key in --key-file.
+Place the output in the user's home as ` + optout.MarkerFile + `; scanners
+with the matching optout_public_key skip the user and report them as
+excluded by policy.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runOptoutSign,
+}
+
+var catalogCmd = &cobra.Command{
+	Use:   "catalog",
+	Short: "Manage signed catalog bundles",
+}
+
+var catalogKeygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Generate the catalog signing key pair",
+	Long: `Writes a new private key to --key-file (readable only by its owner) and
+prints the public key to set as catalog_public_key. Keep the private key off
+scanned machines.`,
+	Args: cobra.NoArgs,
+	RunE: runCatalogKeygen,
+}
+
+var catalogSignCmd = &cobra.Command{
+	Use:   "sign <bundle.json>",
+	Short: "Sign a catalog bundle",
+	Long: `Validates a catalog bundle (version, services and category lists) and signs
+it with the private key in --key-file. Publish the output at catalog_url;
+scanners with the matching catalog_public_key load it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCatalogSign,
+}
+
+var previewCmd = &cobra.Command{
+	Use:   "preview",
+	Short: "Show exactly what the next run would send",
+	Long: `Runs the scan in dry-run mode, through the same pipeline as run, and prints
+the payloads that would be sent: the principal and device blocks and each
+history entry. Stored scan positions are used but not changed, so this is
+what the next run would send. Nothing leaves the machine.`,
+	Args: cobra.NoArgs,
+	RunE: runPreview,
+}
+
+var listBrowsersCmd = &cobra.Command{
+	Use:   "list-browsers",
+	Short: "List supported browsers and where they are installed",
+	Long: `Lists every supported browser, whether it was detected on this machine,
+the users it was found for, their profiles and the size of each profile's
+history database (including its WAL file). History is not read.`,
+	Args: cobra.NoArgs,
+	RunE: runListBrowsers,
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show scanner health",
+	Long: `Shows where the configuration comes from (file, environment, Group
+Policy, discovery), the state file with its number of tracked profiles and
+the time history was last accepted by the server, the scheduler
+registration, and whether the server is reachable: health_url answers GET
+with 2xx, or else the upload endpoint answers HEAD; no data is sent. Use
+--json for fleet collection. Exits with code 1 if the config is invalid or
+the server is unreachable.`,
+	Args: cobra.NoArgs,
+	RunE: runStatus,
+}
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Manage scan positions",
+}
+
+var stateResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Reset scan positions so profiles are rescanned",
+	Long: `Forgets the scan positions of the profiles matching --user, --browser
+and --profile, or of everything with --all, including the positions of DNS
+sources and proxy logs. The next run reads those profiles as on the first
+run: the last initial_days of history. Use it after the server lost data or
+to backfill a subset of the fleet; run --full rescans without forgetting
+anything. A running daemon keeps the positions in memory and writes them
+back, so it is refused while the daemon answers on its control socket.`,
+	Args: cobra.NoArgs,
+	RunE: runStateReset,
+}
+
 var debugCmd = &cobra.Command{
 	Use:   "debug",
 	Short: "Debug commands for testing",
@@ -93,72 +441,419 @@ var debugBrowserCmd = &cobra.Command{
 	RunE:  runDebugBrowser,
 }
 
+var debugAllCmd = &cobra.Command{
+	Use:   "all",
+	Short: "Test history extraction for every browser",
+	Long: `Scans every supported browser for each user and prints a matrix of
+users, browsers and profiles with their entry counts and errors.`,
+	Args: cobra.NoArgs,
+	RunE: runDebugAll,
+}
+
 var debugStateCmd = &cobra.Command{
 	Use:   "state",
 	Short: "Show state file contents",
 	RunE:  runDebugState,
 }
 
+var debugGenerateCmd = &cobra.Command{
+	Use:   "generate <dir>",
+	Short: "Generate browser history fixture databases",
+	Long: `Creates a system root in dir with the homes of --users, each holding
+--profiles profiles of every --browsers browser with --visits synthetic
+visits over the last --days days, laid out like a --os disk. The databases
+have the schema of real Chromium History and Firefox places.sqlite files;
+scan them with hist_scanner run --root <dir>. The same --seed gives the
+same sites at the same times relative to now. Existing databases are never
+overwritten.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDebugGenerate,
+}
+
 var debugSendCmd = &cobra.Command{
 	Use:   "send",
 	Short: "Test sending data to server",
-	RunE:  runDebugSend,
+	Long: `Sends a test payload to the server: a JSON payload from --payload-file
+(in the format the scanner sends), or --entries synthetic entries with URLs
+of --url-size bytes. Use it to check authentication, chunk limits and
+compression with realistic data.`,
+	Args: cobra.NoArgs,
+	RunE: runDebugSend,
 }
 
 // Install command specific flags
 var (
 	installInterval time.Duration
 	installUser     string
+	installMode     string
+	installScope    string
+
+	installRequireNetwork bool
+	installRunMissed      bool
+	installWakeToRun      bool
+	installRandomDelay    time.Duration
+
+	installHardening bool
+	installCPUQuota  string
+	installMemoryMax string
+	installProxy     string
+
+	installStatusURL string
+	installErrorURL  string
+	installEventsURL string
+	installNotice    []string
+	installSign      bool
+
+	statusJSON bool
+	statusRuns int
+
+	installSilent bool
+
+	installRunAsCurrentUser bool
+
+	installDryRun bool
+
+	verifyFix bool
+
+	uninstallPurge     bool
+	uninstallKeepState bool
+)
+
+// Daemon command specific flags
+var (
+	daemonInterval time.Duration
+)
+
+// Import command specific flags
+var (
+	importFormat string
+	importUser   string
+	importIP     string
+)
+
+// Proxy command specific flags
+var (
+	proxyFormat string
+	proxyFollow bool
+	proxyPoll   time.Duration
+)
+
+// Ctl command specific flags
+var (
+	ctlSocket  string
+	ctlTimeout time.Duration
+)
+
+// Package command specific flags
+var (
+	packageFormat     string
+	packageVersion    string
+	packageArch       string
+	packageBinary     string
+	packageOutput     string
+	packageInterval   time.Duration
+	packageMaintainer string
+)
+
+// ADMX command specific flags
+var (
+	admxOutput string
+)
+
+// Export command specific flags
+var (
+	exportFormat string
+	exportSince  string
+	exportUntil  string
+	exportOut    string
+
+	exportEncrypt bool
+	decryptOut    string
+)
+
+// State command specific flags
+var (
+	stateResetAll bool
+)
+
+// Report command specific flags
+var (
+	reportDays int
+	reportTop  int
+)
+
+// Optout command specific flags
+var (
+	optoutKeyFile string
+	optoutExpires string
+	optoutOutput  string
+)
+
+// Extensions command specific flags
+var (
+	extensionsMinRisk string
+)
+
+// Catalog command specific flags
+var (
+	catalogKeyFile string
+	catalogOutput  string
+)
+
+// Preview command specific flags
+var (
+	previewLimit int
 )
 
 // Debug command specific flags
 var (
 	debugUser string
+	debugDays int
+
+	debugPayloadFile string
+	debugEntries     int
+	debugURLSize     int
+
+	generateOS       string
+	generateUsers    []string
+	generateBrowsers []string
+	generateProfiles int
+	generateVisits   int
+	generateDays     int
+	generateSeed     uint64
 )
 
 func init() {
 	// Set version template to include build info
 	rootCmd.Version = version
 	rootCmd.SetVersionTemplate(fmt.Sprintf("hist_scanner version %s (commit: %s, built: %s)\n", version, commit, buildTime))
+	installer.SetBuild(version, commit, buildTime)
+	buildinfo.Set(version, commit)
 
 	// Global flags for all commands
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file path")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "", "log level: debug, info, warn or error (default: info)")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "", "log format: text or json (default: text)")
 
 	// Run command flags
 	runCmd.Flags().StringVar(&serverURL, "server-url", "", "server endpoint URL")
 	runCmd.Flags().StringVar(&apiKey, "api-key", "", "API key for authentication")
 	runCmd.Flags().StringVar(&stateFile, "state-file", "", "path to state file")
-	runCmd.Flags().StringVar(&logFile, "log-file", "", "path to log file")
+	runCmd.Flags().StringVar(&logFile, "log-file", "", "path to log file, or STDERR, SYSLOG or EVENTLOG")
 	runCmd.Flags().IntVar(&initialDays, "initial-days", 0, "days of history on first scan (default: 7)")
 	runCmd.Flags().IntVar(&chunkSizeKB, "chunk-size-kb", 0, "max compressed chunk size in KB (default: 1024)")
 	runCmd.Flags().BoolVar(&compress, "compress", true, "enable gzip compression (default: true)")
 	runCmd.Flags().DurationVar(&timeout, "timeout", 0, "HTTP timeout (default: 30s)")
 	runCmd.Flags().BoolVar(&dryRun, "dry-run", false, "scan and dump JSON to stdout instead of sending")
+	runCmd.Flags().BoolVar(&fullScan, "full", false, "ignore stored scan positions and rescan the last initial_days")
+	runCmd.Flags().IntVar(&fullDays, "days", 0, "days of history --full rescans (default: initial_days)")
+	runCmd.Flags().StringVar(&runSince, "since", "", "scan visits since, e.g. 2025-03-03, 30d or 12h, without using or changing scan positions")
+	runCmd.Flags().StringVar(&runUntil, "until", "", "scan visits before, e.g. 2025-03-05 (whole day included) or 12h, without using or changing scan positions")
+	runCmd.Flags().StringSliceVar(&runUsers, "user", nil, "only scan these users (comma-separated or repeated)")
+	runCmd.Flags().StringSliceVar(&runBrowsers, "browser", nil, "only scan these browsers, e.g. chrome,firefox")
+	runCmd.Flags().StringSliceVar(&runProfiles, "profile", nil, "only scan these profiles, by name or directory (e.g. Default)")
+	runCmd.Flags().StringVar(&runRoot, "root", "", "scan a mounted disk image or offline system root instead of this system")
+	runCmd.Flags().StringSliceVar(&runRootUsers, "root-user", nil, "users of the --root image to scan, as NAME or NAME=HOME relative to the root")
+	runCmd.Flags().StringArrayVar(&envVars, "env", nil, "set an environment variable (KEY=VALUE) before running, may be repeated")
 
 	// Install command flags
 	installCmd.Flags().StringVar(&serverURL, "server-url", "", "server endpoint URL")
 	installCmd.Flags().StringVar(&apiKey, "api-key", "", "API key for authentication")
 	installCmd.Flags().StringVar(&stateFile, "state-file", "", "path to state file")
-	installCmd.Flags().StringVar(&logFile, "log-file", "", "path to log file")
+	installCmd.Flags().StringVar(&logFile, "log-file", "", "path to log file, or STDERR, SYSLOG or EVENTLOG")
 	installCmd.Flags().IntVar(&initialDays, "initial-days", 0, "days of history on first scan")
 	installCmd.Flags().IntVar(&chunkSizeKB, "chunk-size-kb", 0, "max compressed chunk size in KB")
 	installCmd.Flags().BoolVar(&compress, "compress", true, "enable gzip compression")
 	installCmd.Flags().DurationVar(&timeout, "timeout", 0, "HTTP timeout")
 	installCmd.Flags().DurationVar(&installInterval, "interval", 24*time.Hour, "scan interval")
 	installCmd.Flags().StringVar(&installUser, "user", "", "user to run as (default: root/SYSTEM)")
+	installCmd.Flags().StringVar(&installScope, "scope", string(installer.ScopeSystem), "install scope: system (all users, needs root) or user (current user only, Linux/macOS)")
+	installCmd.Flags().StringVar(&installMode, "mode", "", "scheduler mechanism: task or service (Windows), systemd, openrc or cron (Linux); default: auto")
+	installCmd.Flags().BoolVar(&installRequireNetwork, "require-network", true, "only start when network is available (Windows task)")
+	installCmd.Flags().BoolVar(&installRunMissed, "run-missed", true, "run as soon as possible after a missed start (Windows task)")
+	installCmd.Flags().BoolVar(&installWakeToRun, "wake-to-run", false, "wake the computer to run the scan (Windows task)")
+	installCmd.Flags().DurationVar(&installRandomDelay, "random-delay", 0, "random delay added to each start (Windows task)")
+	installCmd.Flags().BoolVar(&installHardening, "hardening", true, "sandbox the service with ProtectSystem=strict, PrivateTmp and NoNewPrivileges (systemd)")
+	installCmd.Flags().StringVar(&installCPUQuota, "cpu-quota", "", "CPU quota for the scan, e.g. 50% (systemd)")
+	installCmd.Flags().StringVar(&installMemoryMax, "memory-max", "", "memory limit for the scan, e.g. 512M (systemd)")
+	installCmd.Flags().StringVar(&installProxy, "proxy", "", "HTTP(S) proxy URL for scheduled runs (sets HTTP_PROXY and HTTPS_PROXY)")
+	installCmd.Flags().StringVar(&installStatusURL, "status-url", "", "endpoint that receives a run report after each scheduled run")
+	installCmd.Flags().StringSliceVar(&installNotice, "notice", nil, "tell users that browsing is audited: page (HTML disclosure page), login (login message)")
+	installCmd.Flags().BoolVar(&installSign, "sign-payloads", false, "sign uploads with a per-device key registered with the server")
+	installCmd.Flags().StringVar(&installEventsURL, "events-url", "", "endpoint that receives an event when a user's browsing history was cleared or reduced")
+	installCmd.Flags().StringVar(&installErrorURL, "error-url", "", "endpoint that receives an error event when a scheduled run crashes or fails")
+	installCmd.Flags().StringArrayVar(&envVars, "env", nil, "environment variable (KEY=VALUE) for scheduled runs, may be repeated")
+	installCmd.Flags().BoolVar(&installRunAsCurrentUser, "run-as-current-user", false, "run as the logged-on user and scan only their profiles (Windows task)")
+	installCmd.Flags().BoolVar(&installSilent, "silent", false, "no output except errors, for MDM deployment (Intune, JAMF)")
+	installCmd.Flags().BoolVar(&installDryRun, "dry-run", false, "print the files, scheduler entries and commands the install would use, without changing anything")
+
+	installStatusCmd.Flags().StringVar(&installScope, "scope", string(installer.ScopeSystem), "install scope to inspect: system or user")
+	installStatusCmd.Flags().BoolVar(&statusJSON, "json", false, "output as JSON")
+	installStatusCmd.Flags().IntVar(&statusRuns, "runs", 5, "number of recent run results to show")
+	installCmd.AddCommand(installStatusCmd)
+
+	// Daemon command flags
+	daemonCmd.Flags().StringVar(&serverURL, "server-url", "", "server endpoint URL")
+	daemonCmd.Flags().StringVar(&apiKey, "api-key", "", "API key for authentication")
+	daemonCmd.Flags().StringVar(&stateFile, "state-file", "", "path to state file")
+	daemonCmd.Flags().StringVar(&logFile, "log-file", "", "path to log file, or STDERR, SYSLOG or EVENTLOG")
+	daemonCmd.Flags().DurationVar(&daemonInterval, "interval", 24*time.Hour, "scan interval")
+	daemonCmd.Flags().StringArrayVar(&envVars, "env", nil, "set an environment variable (KEY=VALUE) before running, may be repeated")
+
+	// Import command flags
+	importCmd.Flags().StringVar(&importFormat, "format", "", "export format: takeout, csv or firefox-json")
+	importCmd.Flags().StringVar(&importUser, "user", "", "username the visits are sent for")
+	importCmd.Flags().StringVar(&importIP, "ip", "", "IP address the visits are sent for, instead of --user")
+	importCmd.Flags().StringVar(&serverURL, "server-url", "", "server endpoint URL")
+	importCmd.Flags().StringVar(&apiKey, "api-key", "", "API key for authentication")
+	importCmd.Flags().StringVar(&stateFile, "state-file", "", "path to state file")
+	importCmd.Flags().StringVar(&logFile, "log-file", "", "path to log file, or STDERR, SYSLOG or EVENTLOG")
+	importCmd.Flags().BoolVar(&dryRun, "dry-run", false, "dump the payloads as JSON to stdout instead of sending")
+	importCmd.MarkFlagRequired("format")
+	importCmd.MarkFlagsOneRequired("user", "ip")
+	importCmd.MarkFlagsMutuallyExclusive("user", "ip")
+
+	// Proxy command flags
+	proxyCmd.Flags().StringVar(&proxyFormat, "format", proxylog.FormatSquid, "log format: squid or common")
+	proxyCmd.Flags().BoolVar(&proxyFollow, "follow", false, "keep reading lines appended to the log until interrupted")
+	proxyCmd.Flags().DurationVar(&proxyPoll, "poll", 5*time.Second, "how often a followed log is checked for new lines")
+	proxyCmd.Flags().StringVar(&serverURL, "server-url", "", "server endpoint URL")
+	proxyCmd.Flags().StringVar(&apiKey, "api-key", "", "API key for authentication")
+	proxyCmd.Flags().StringVar(&stateFile, "state-file", "", "path to state file")
+	proxyCmd.Flags().StringVar(&logFile, "log-file", "", "path to log file, or STDERR, SYSLOG or EVENTLOG")
+	proxyCmd.Flags().BoolVar(&dryRun, "dry-run", false, "dump the payloads as JSON to stdout instead of sending")
+
+	// Ctl command flags
+	ctlCmd.Flags().StringVar(&ctlSocket, "socket", "", "control socket of the daemon (default: from the config)")
+	ctlCmd.Flags().StringVar(&stateFile, "state-file", "", "state file of the daemon, whose directory holds the default socket")
+	ctlCmd.Flags().DurationVar(&ctlTimeout, "timeout", 0, "give up after this long (default: 10s, no limit for flush)")
+	ctlCmd.Flags().BoolVar(&statusJSON, "json", false, "output the response as JSON")
+
+	// Uninstall command flags
+	packageCmd.Flags().StringVar(&packageFormat, "format", packager.FormatDeb, "package format: deb or rpm")
+	packageCmd.Flags().StringVar(&packageVersion, "version", version, "package version")
+	packageCmd.Flags().StringVar(&packageArch, "arch", runtime.GOARCH, "target architecture (amd64, arm64)")
+	packageCmd.Flags().StringVar(&packageBinary, "binary", "", "Linux binary to package (default: this executable)")
+	packageCmd.Flags().StringVar(&packageOutput, "output", ".", "output directory")
+	packageCmd.Flags().DurationVar(&packageInterval, "interval", 24*time.Hour, "scan interval for the systemd timer")
+	packageCmd.Flags().StringVar(&packageMaintainer, "maintainer", "Binadox", "package maintainer")
+
+	upgradeCmd.Flags().StringVar(&installScope, "scope", string(installer.ScopeSystem), "install scope to upgrade: system or user")
+
+	verifyCmd.Flags().StringVar(&installScope, "scope", string(installer.ScopeSystem), "install scope to verify: system or user")
+	verifyCmd.Flags().BoolVar(&verifyFix, "fix", false, "repair drift (replace the binary, reset config permissions, repoint the scheduler entry)")
+	verifyCmd.Flags().BoolVar(&statusJSON, "json", false, "output as JSON")
+
+	previewCmd.Flags().IntVar(&previewLimit, "limit", 100, "entries to show, 0 for all")
+	previewCmd.Flags().BoolVar(&statusJSON, "json", false, "print the payloads as JSON")
+	previewCmd.Flags().StringSliceVar(&runUsers, "user", nil, "only preview these users (comma-separated or repeated)")
+	previewCmd.Flags().StringSliceVar(&runBrowsers, "browser", nil, "only preview these browsers, e.g. chrome,firefox")
+	previewCmd.Flags().StringSliceVar(&runProfiles, "profile", nil, "only preview these profiles, by name or directory")
+	previewCmd.Flags().StringVar(&runRoot, "root", "", "preview a mounted disk image or offline system root instead of this system")
+	previewCmd.Flags().StringSliceVar(&runRootUsers, "root-user", nil, "users of the --root image to preview, as NAME or NAME=HOME relative to the root")
+
+	listBrowsersCmd.Flags().StringSliceVar(&runUsers, "user", nil, "only check these users (comma-separated or repeated)")
+	listBrowsersCmd.Flags().BoolVar(&statusJSON, "json", false, "output as JSON")
+
+	statusCmd.Flags().StringVar(&installScope, "scope", string(installer.ScopeSystem), "install scope whose scheduler registration to report: system or user")
+	statusCmd.Flags().BoolVar(&statusJSON, "json", false, "output as JSON")
+
+	admxCmd.Flags().StringVar(&admxOutput, "output", ".", "output directory")
+
+	uninstallCmd.Flags().StringVar(&installScope, "scope", string(installer.ScopeSystem), "install scope to remove: system or user")
+	uninstallCmd.Flags().BoolVar(&uninstallPurge, "purge", false, "also remove the state, keys, logs, audit log, control socket, database copies and retention_paths contents")
+	uninstallCmd.Flags().BoolVar(&uninstallKeepState, "keep-state", false, "with --purge, keep the state file (scan watermarks) and its encryption key")
+
+	exportCmd.Flags().StringVar(&exportFormat, "format", "", "report format: html, csv or jsonl (default: from --out extension, else html)")
+	exportCmd.Flags().StringVar(&exportSince, "since", "30d", "export visits since, e.g. 30d, 2w, 12h or 2025-01-31")
+	exportCmd.Flags().StringVar(&exportUntil, "until", "", "export visits before, e.g. 2025-03-05 (whole day included) or 12h")
+	exportCmd.Flags().StringVar(&exportOut, "out", "-", "output file, - for stdout")
+	exportCmd.Flags().BoolVar(&exportEncrypt, "encrypt", false, "encrypt the report with the machine-bound key (default: encrypt_exports)")
+	decryptCmd.Flags().StringVar(&decryptOut, "out", "-", "output file, - for stdout")
+	exportCmd.Flags().StringSliceVar(&runUsers, "user", nil, "only export these users (comma-separated or repeated)")
+	exportCmd.Flags().StringSliceVar(&runBrowsers, "browser", nil, "only export these browsers, e.g. chrome,firefox")
+	exportCmd.Flags().StringSliceVar(&runProfiles, "profile", nil, "only export these profiles, by name or directory")
+
+	extensionsCmd.Flags().StringSliceVar(&runUsers, "user", nil, "only list these users (comma-separated or repeated)")
+	extensionsCmd.Flags().StringSliceVar(&runBrowsers, "browser", nil, "only list these browsers, e.g. chrome,firefox")
+	extensionsCmd.Flags().StringSliceVar(&runProfiles, "profile", nil, "only list these profiles, by name or directory")
+	extensionsCmd.Flags().StringVar(&extensionsMinRisk, "min-risk", "low", "only list extensions of at least this risk: low, medium or high")
+
+	reportCmd.Flags().IntVar(&reportDays, "days", 30, "days of history to analyze")
+	reportCmd.Flags().IntVar(&reportTop, "top", 10, "unsanctioned services listed per user, 0 for all")
+	reportCmd.Flags().StringSliceVar(&runUsers, "user", nil, "only report these users (comma-separated or repeated)")
+	reportCmd.Flags().StringSliceVar(&runBrowsers, "browser", nil, "only report these browsers, e.g. chrome,firefox")
+	reportCmd.Flags().StringSliceVar(&runProfiles, "profile", nil, "only report these profiles, by name or directory")
+
+	stateResetCmd.Flags().StringVar(&stateFile, "state-file", "", "path to state file")
+	stateResetCmd.Flags().StringSliceVar(&runUsers, "user", nil, "only reset these users (comma-separated or repeated)")
+	stateResetCmd.Flags().StringSliceVar(&runBrowsers, "browser", nil, "only reset these browsers, e.g. chrome,firefox")
+	stateResetCmd.Flags().StringSliceVar(&runProfiles, "profile", nil, "only reset these profiles, by name")
+	stateResetCmd.Flags().BoolVar(&stateResetAll, "all", false, "reset every scan position")
+	stateResetCmd.Flags().BoolVar(&dryRun, "dry-run", false, "list the positions that would be reset without changing the state")
 
 	// Debug command flags
 	debugBrowserCmd.Flags().StringVar(&debugUser, "user", "", "specific user to scan")
+	debugAllCmd.Flags().StringVar(&debugUser, "user", "", "specific user to scan")
+	debugAllCmd.Flags().IntVar(&debugDays, "days", 7, "days of history to read")
+
+	debugGenerateCmd.Flags().StringVar(&generateOS, "os", "linux", "layout of the generated root: linux, darwin or windows")
+	debugGenerateCmd.Flags().StringSliceVar(&generateUsers, "users", []string{"alice", "bob"}, "users to create")
+	debugGenerateCmd.Flags().StringSliceVar(&generateBrowsers, "browsers", []string{"chrome", "firefox"}, "Chromium-based browsers and firefox")
+	debugGenerateCmd.Flags().IntVar(&generateProfiles, "profiles", 1, "profiles per user and browser")
+	debugGenerateCmd.Flags().IntVar(&generateVisits, "visits", 1000, "visits per profile")
+	debugGenerateCmd.Flags().IntVar(&generateDays, "days", 30, "days the visits are spread over")
+	debugGenerateCmd.Flags().Uint64Var(&generateSeed, "seed", 1, "random seed")
 
 	// Build command tree
+	debugSendCmd.Flags().StringVar(&debugPayloadFile, "payload-file", "", "JSON payload to send instead of synthetic entries")
+	debugSendCmd.Flags().IntVar(&debugEntries, "entries", 3, "number of synthetic entries")
+	debugSendCmd.Flags().IntVar(&debugURLSize, "url-size", 0, "length of each synthetic URL in bytes (default: short URLs)")
+
+	optoutKeygenCmd.Flags().StringVar(&optoutKeyFile, "key-file", "", "file the private key is written to")
+	optoutSignCmd.Flags().StringVar(&optoutKeyFile, "key-file", "", "file holding the private key")
+	optoutSignCmd.Flags().StringVar(&optoutExpires, "expires", "", "last date the marker is valid, YYYY-MM-DD (default: never expires)")
+	optoutSignCmd.Flags().StringVarP(&optoutOutput, "output", "o", "", "write the marker to this file instead of stdout")
+
+	auditCmd.AddCommand(auditVerifyCmd)
+	catalogKeygenCmd.Flags().StringVar(&catalogKeyFile, "key-file", "", "file the private key is written to")
+	catalogSignCmd.Flags().StringVar(&catalogKeyFile, "key-file", "", "file holding the private key")
+	catalogSignCmd.Flags().StringVarP(&catalogOutput, "output", "o", "", "write the signed bundle to this file instead of stdout")
+	catalogCmd.AddCommand(catalogKeygenCmd)
+	catalogCmd.AddCommand(catalogSignCmd)
+
+	optoutCmd.AddCommand(optoutKeygenCmd)
+	optoutCmd.AddCommand(optoutSignCmd)
+	stateCmd.AddCommand(stateResetCmd)
 	debugCmd.AddCommand(debugUsersCmd)
 	debugCmd.AddCommand(debugBrowserCmd)
+	debugCmd.AddCommand(debugAllCmd)
 	debugCmd.AddCommand(debugStateCmd)
 	debugCmd.AddCommand(debugSendCmd)
+	debugCmd.AddCommand(debugGenerateCmd)
 
 	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(daemonCmd)
 	rootCmd.AddCommand(installCmd)
+	rootCmd.AddCommand(upgradeCmd)
+	rootCmd.AddCommand(verifyCmd)
 	rootCmd.AddCommand(uninstallCmd)
+	rootCmd.AddCommand(packageCmd)
+	rootCmd.AddCommand(admxCmd)
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(ctlCmd)
+	rootCmd.AddCommand(listBrowsersCmd)
+	rootCmd.AddCommand(previewCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(proxyCmd)
+	rootCmd.AddCommand(decryptCmd)
+	rootCmd.AddCommand(reportCmd)
+	rootCmd.AddCommand(extensionsCmd)
+	rootCmd.AddCommand(auditCmd)
+	rootCmd.AddCommand(optoutCmd)
+	rootCmd.AddCommand(catalogCmd)
+	rootCmd.AddCommand(stateCmd)
 	rootCmd.AddCommand(debugCmd)
 }
 
@@ -173,11 +868,47 @@ func loadConfig(cmd *cobra.Command) (*config.Config, error) {
 
 	// Apply CLI flags
 	cfg.ApplyFlags(serverURL, apiKey, stateFile, logFile, initialDays, chunkSizeKB, compress, compressSet, timeout)
+	if logLevel != "" {
+		cfg.LogLevel = logLevel
+	}
+	if logFormat != "" {
+		cfg.LogFormat = logFormat
+	}
 
 	return cfg, nil
 }
 
 func runScan(cmd *cobra.Command, args []string) error {
+	if err := applyEnv(envVars); err != nil {
+		return err
+	}
+
+	filter, err := runFilter()
+	if err != nil {
+		return err
+	}
+	root, err := imageRoot()
+	if err != nil {
+		return err
+	}
+
+	ranged := runSince != "" || runUntil != ""
+	since, until, err := parseRange(runSince, runUntil, time.Now())
+	if err != nil {
+		return err
+	}
+	if ranged && fullScan {
+		return fmt.Errorf("--full cannot be combined with --since or --until")
+	}
+	if cmd.Flags().Changed("days") {
+		if !fullScan {
+			return fmt.Errorf("--days requires --full")
+		}
+		if fullDays <= 0 {
+			return fmt.Errorf("--days must be > 0")
+		}
+	}
+
 	cfg, err := loadConfig(cmd)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
@@ -193,8 +924,17 @@ func runScan(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to initialize scanner: %w", err)
 	}
+	s.SetFull(fullScan, fullDays)
+	if ranged {
+		s.SetRange(since, until)
+	}
+	if root != "" {
+		s.SetRoot(root, runRootUsers)
+	}
+	s.SetFilter(filter)
+	s.SetVersion(version)
 
-	result := s.Run()
+	result := s.Run(context.Background())
 
 	// Exit with appropriate code
 	if result.ExitCode != scanner.ExitSuccess {
@@ -204,7 +944,11 @@ func runScan(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func runInstall(cmd *cobra.Command, args []string) error {
+func runDaemon(cmd *cobra.Command, args []string) error {
+	if err := applyEnv(envVars); err != nil {
+		return err
+	}
+
 	cfg, err := loadConfig(cmd)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
@@ -214,157 +958,2117 @@ func runInstall(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("invalid config: %w", err)
 	}
 
-	inst, err := installer.New()
+	if daemonInterval <= 0 {
+		return fmt.Errorf("interval must be > 0")
+	}
+
+	s, err := scanner.New(cfg, false)
 	if err != nil {
-		return fmt.Errorf("failed to create installer: %w", err)
+		return fmt.Errorf("failed to initialize scanner: %w", err)
 	}
+	s.SetVersion(version)
 
-	paths := installer.GetInstallPaths()
+	d := &daemon{
+		cmd:      cmd,
+		scanner:  s,
+		started:  time.Now(),
+		requests: make(chan chan *control.Run, 1),
+	}
 
-	fmt.Printf("Installing browser history scanner...\n")
-	fmt.Printf("  Binary: %s\n", paths.BinaryPath)
-	fmt.Printf("  Config: %s\n", paths.ConfigPath)
-	fmt.Printf("  Interval: %s\n", installInterval)
-	fmt.Printf("  Run as: %s\n", installUser)
+	return service.Run(func(ctx context.Context) {
+		if path := controlSocket(cfg); path != "" {
+			l, err := control.Listen(path)
+			if err != nil {
+				s.Logger().Warnf("control API unavailable: %v", err)
+			} else {
+				go control.Serve(ctx, l, d.handle)
+			}
+		}
+		d.loop(ctx)
+	})
+}
 
-	// If config was obtained via auto-discovery, save it to file
-	// so scheduled runs don't depend on discovery server availability
-	if cfg.WasDiscovered() {
-		fmt.Println("  Config source: auto-discovery")
-		fmt.Printf("  Saving discovered config to: %s\n", paths.ConfigPath)
-		if err := cfg.SaveToFile(paths.ConfigPath); err != nil {
-			return fmt.Errorf("failed to save discovered config: %w", err)
+// daemon runs scheduled scans and the scans and reloads requested through
+// the control API
+type daemon struct {
+	cmd      *cobra.Command
+	started  time.Time
+	requests chan chan *control.Run // Requested scans, with the channel awaiting the run, if any
+
+	mu       sync.Mutex
+	scanner  *scanner.Scanner
+	reloaded *config.Config // Reloaded config, used from the next scan
+	reloadAt time.Time
+	scanning bool
+	nextScan time.Time
+	lastRun  *control.Run
+}
+
+// loop scans immediately, then once per interval and whenever requested,
+// until ctx is canceled
+func (d *daemon) loop(ctx context.Context) {
+	ticker := time.NewTicker(daemonInterval)
+	defer ticker.Stop()
+
+	var reply chan *control.Run
+	for {
+		run := d.scan(ctx)
+		if reply != nil {
+			reply <- run
+			reply = nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case reply = <-d.requests:
 		}
 	}
-	fmt.Println()
+}
 
-	if err := inst.Install(cfg, installInterval, installUser); err != nil {
-		return fmt.Errorf("installation failed: %w", err)
+// maxControlErrors caps the errors of a run reported by the control API
+const maxControlErrors = 10
+
+// scan runs one scan with the latest config
+func (d *daemon) scan(ctx context.Context) *control.Run {
+	d.mu.Lock()
+	if d.reloaded != nil {
+		// Built only now, so it loads the state the previous scan saved
+		if s, err := scanner.New(d.reloaded, false); err != nil {
+			d.scanner.Logger().Warnf("failed to apply the reloaded config, keeping the current config: %v", err)
+		} else {
+			s.SetVersion(version)
+			d.scanner.Close()
+			d.scanner = s
+		}
+		d.reloaded = nil
 	}
+	s := d.scanner
+	d.scanning = true
+	d.mu.Unlock()
+
+	started := time.Now()
+	result := s.Run(ctx)
+
+	d.mu.Lock()
+	d.scanning = false
+	d.nextScan = time.Now().Add(daemonInterval)
+	run := &control.Run{
+		Started:         started,
+		DurationMS:      time.Since(started).Milliseconds(),
+		ExitCode:        int(result.ExitCode),
+		UsersScanned:    result.UsersScanned,
+		ProfilesScanned: result.ProfilesScanned,
+		EntriesSent:     result.EntriesSent,
+		Errors:          result.Errors[:min(len(result.Errors), maxControlErrors)],
+		ErrorCategories: result.ErrorCategories(),
+	}
+	d.lastRun = run
+	d.mu.Unlock()
+	return run
+}
 
-	fmt.Println("Installation complete!")
-	fmt.Println("\nThe scanner will run automatically on schedule.")
-	fmt.Println("To run manually: hist_scanner run --config", paths.ConfigPath)
+// handle executes a control API command
+func (d *daemon) handle(ctx context.Context, command string) control.Response {
+	switch command {
+	case control.CommandScan:
+		select {
+		case d.requests <- nil:
+			d.logger().Infof("Scan requested through the control API")
+			return control.Response{OK: true, Message: "scan started"}
+		default:
+			return control.Response{OK: true, Message: "a scan is already queued"}
+		}
 
-	return nil
+	case control.CommandFlush:
+		reply := make(chan *control.Run, 1)
+		select {
+		case d.requests <- reply:
+		case <-ctx.Done():
+			return control.Response{Message: "canceled while waiting for the queued scan"}
+		}
+		d.logger().Infof("Flush requested through the control API")
+		var run *control.Run
+		select {
+		case run = <-reply:
+		case <-ctx.Done():
+			return control.Response{Message: "canceled; the scan continues"}
+		}
+		msg := fmt.Sprintf("sent %d entries", run.EntriesSent)
+		if run.ExitCode != int(scanner.ExitSuccess) {
+			msg += fmt.Sprintf("; scan failed with exit code %d, unsent visits are retried by the next scan", run.ExitCode)
+		}
+		return control.Response{OK: run.ExitCode == int(scanner.ExitSuccess), Message: msg, Run: run}
+
+	case control.CommandReload:
+		return d.reload()
+
+	default: // control.CommandStatus
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		st := &control.Status{
+			PID:        os.Getpid(),
+			Version:    version,
+			Started:    d.started,
+			ConfigFile: cfgFile,
+			Reloaded:   d.reloadAt,
+			Interval:   daemonInterval.String(),
+			Scanning:   d.scanning,
+			Queued:     len(d.requests) > 0,
+			LastRun:    d.lastRun,
+		}
+		if !d.scanning {
+			st.NextScan = d.nextScan
+		}
+		return control.Response{OK: true, Status: st}
+	}
 }
 
-func runUninstall(cmd *cobra.Command, args []string) error {
-	inst, err := installer.New()
+// reload loads and validates the config again; the scanner built from it
+// is used from the next scan, so a running scan is not disturbed
+func (d *daemon) reload() control.Response {
+	cfg, err := loadConfig(d.cmd)
+	if err == nil {
+		err = cfg.Validate()
+	}
 	if err != nil {
-		return fmt.Errorf("failed to create installer: %w", err)
+		d.logger().Warnf("config reload failed, keeping the current config: %v", err)
+		return control.Response{Message: fmt.Sprintf("reload failed, keeping the current config: %v", err)}
 	}
 
-	if !inst.IsInstalled() {
-		fmt.Println("Scanner is not installed.")
-		return nil
-	}
+	// The scanner is replaced between scans: a scan running now keeps its
+	// settings and saves its positions first
+	d.mu.Lock()
+	d.reloaded = cfg
+	d.reloadAt = time.Now()
+	d.mu.Unlock()
 
-	fmt.Println("Uninstalling browser history scanner...")
+	d.logger().Infof("Config reloaded through the control API")
+	return control.Response{OK: true, Message: "config reloaded; used from the next scan (control_socket changes need a restart)"}
+}
 
-	if err := inst.Uninstall(); err != nil {
-		return fmt.Errorf("uninstallation failed: %w", err)
+// logger returns the logger of the current scanner
+func (d *daemon) logger() *logging.Logger {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.scanner.Logger()
+}
+
+func runCtl(cmd *cobra.Command, args []string) error {
+	command := args[0]
+	path := ctlSocket
+	if path == "" {
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		if path = controlSocket(cfg); path == "" {
+			return fmt.Errorf("the control API is disabled (control_socket: off)")
+		}
 	}
 
-	fmt.Println("Uninstallation complete!")
+	timeout := ctlTimeout
+	if timeout == 0 && command != control.CommandFlush {
+		timeout = 10 * time.Second
+	}
+	cmd.SilenceUsage = true
+	resp, err := control.Call(path, command, timeout)
+	if err != nil {
+		return err
+	}
+
+	if statusJSON {
+		data, err := json.MarshalIndent(resp, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal response: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		printCtl(resp)
+	}
+	if !resp.OK {
+		return &exitError{1, fmt.Errorf("%s failed", command)}
+	}
 	return nil
 }
 
-func runDebugUsers(cmd *cobra.Command, args []string) error {
-	fmt.Printf("Platform: %s\n\n", platform.CurrentOS())
+// printCtl writes the text output of a control API response
+func printCtl(resp *control.Response) {
+	if resp.Message != "" {
+		fmt.Println(resp.Message)
+	}
+	if st := resp.Status; st != nil {
+		state := "idle"
+		switch {
+		case st.Scanning && st.Queued:
+			state = "scanning, another scan queued"
+		case st.Scanning:
+			state = "scanning"
+		case st.Queued:
+			state = "scan queued"
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "Daemon:\tpid %d, version %s, running since %s\n", st.PID, st.Version, st.Started.Local().Format(time.DateTime))
+		fmt.Fprintf(w, "Config:\t%s\n", orNone(st.ConfigFile))
+		if !st.Reloaded.IsZero() {
+			fmt.Fprintf(w, "Reloaded:\t%s\n", st.Reloaded.Local().Format(time.DateTime))
+		}
+		fmt.Fprintf(w, "Interval:\t%s\n", st.Interval)
+		fmt.Fprintf(w, "State:\t%s\n", state)
+		if !st.NextScan.IsZero() {
+			fmt.Fprintf(w, "Next scan:\t%s\n", st.NextScan.Local().Format(time.DateTime))
+		}
+		w.Flush()
+		if st.LastRun != nil {
+			fmt.Print("Last run: ")
+			printCtlRun(st.LastRun)
+		}
+	}
+	if resp.Run != nil {
+		printCtlRun(resp.Run)
+	}
+}
 
-	users, err := platform.GetAllUsers()
-	if err != nil {
-		return fmt.Errorf("failed to enumerate users: %w", err)
+// printCtlRun writes a run summary of the control API
+func printCtlRun(run *control.Run) {
+	fmt.Printf("%s, %s, exit code %d: %d users, %d profiles, %d entries sent\n",
+		run.Started.Local().Format(time.DateTime), time.Duration(run.DurationMS)*time.Millisecond,
+		run.ExitCode, run.UsersScanned, run.ProfilesScanned, run.EntriesSent)
+	if len(run.ErrorCategories) > 0 {
+		fmt.Printf("  errors by category: %s\n", formatCategories(run.ErrorCategories))
 	}
+	for _, e := range run.Errors {
+		fmt.Printf("  %s\n", e)
+	}
+}
 
-	fmt.Printf("Found %d users:\n", len(users))
-	for _, u := range users {
-		fmt.Printf("  - %s (UID: %s)\n", u.Username, u.UID)
-		fmt.Printf("    Home: %s\n", u.HomeDir)
+// controlSocket returns the daemon's control API socket, or "" if disabled
+func controlSocket(cfg *config.Config) string {
+	switch cfg.ControlSocket {
+	case "off":
+		return ""
+	case "":
+		return control.DefaultPath(state.Dir(cfg.StateFile))
 	}
+	return cfg.ControlSocket
+}
 
+// applyEnv sets --env variables (e.g. proxy settings passed by the scheduler)
+// before config loading, so they also affect auto-discovery and HIST_SCANNER_* overrides
+func applyEnv(env []string) error {
+	if err := installer.ValidateEnvironment(env); err != nil {
+		return err
+	}
+	for _, kv := range env {
+		key, value, _ := strings.Cut(kv, "=")
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", key, err)
+		}
+	}
 	return nil
 }
 
-func runDebugBrowser(cmd *cobra.Command, args []string) error {
-	browserName := args[0]
+func runInstall(cmd *cobra.Command, args []string) error {
+	out := io.Writer(os.Stdout)
+	if installSilent {
+		out = io.Discard
+		cmd.SilenceUsage = true
+	}
+	if err := refuseInContainer("install"); err != nil {
+		return &exitError{exitInstallInvalid, err}
+	}
 
-	b := browser.ByName(browserName)
-	if b == nil {
-		return fmt.Errorf("unknown browser: %s\nSupported: %s", browserName, strings.Join(browser.SupportedBrowserNames(), ", "))
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return &exitError{exitInstallInvalid, fmt.Errorf("failed to load config: %w", err)}
 	}
 
-	fmt.Printf("Testing browser: %s\n\n", b.Name())
+	if installStatusURL != "" {
+		cfg.StatusURL = installStatusURL
+	}
+	if installErrorURL != "" {
+		cfg.ErrorURL = installErrorURL
+	}
+	if installEventsURL != "" {
+		cfg.EventsURL = installEventsURL
+	}
+	if len(installNotice) > 0 {
+		cfg.Notice = installNotice
+	}
+	if installSign {
+		cfg.SignPayloads = true
+	}
 
-	// Get users to scan
-	var users []platform.User
-	if debugUser != "" {
-		// Scan specific user
-		users = []platform.User{{Username: debugUser, HomeDir: ""}}
-		// Try to find the user's home directory
-		allUsers, _ := platform.GetAllUsers()
-		for _, u := range allUsers {
-			if u.Username == debugUser {
-				users[0] = u
-				break
-			}
-		}
-	} else {
-		// Scan all users
-		var err error
-		users, err = platform.GetAllUsers()
-		if err != nil {
-			return fmt.Errorf("failed to enumerate users: %w", err)
-		}
+	if err := cfg.Validate(); err != nil {
+		return &exitError{exitInstallInvalid, fmt.Errorf("invalid config: %w", err)}
 	}
 
-	totalEntries := 0
-	for _, user := range users {
-		profiles, err := b.FindProfiles(user)
-		if err != nil {
-			fmt.Printf("User %s: error finding profiles: %v\n", user.Username, err)
-			continue
-		}
+	scope := installer.Scope(installScope)
+	inst, err := installer.New(scope)
+	if err != nil {
+		return &exitError{exitInstallInvalid, fmt.Errorf("failed to create installer: %w", err)}
+	}
 
-		if len(profiles) == 0 {
-			fmt.Printf("User %s: no profiles found\n", user.Username)
-			continue
-		}
+	if scope == installer.ScopeSystem && !installDryRun && !platform.IsPrivileged() {
+		return &exitError{exitInstallPrivileges, fmt.Errorf("installation requires root/Administrator privileges")}
+	}
 
-		fmt.Printf("User %s: found %d profile(s)\n", user.Username, len(profiles))
+	opts := installer.Options{
+		Interval:  installInterval,
+		RunAsUser: installUser,
+		Mode:      installMode,
 
-		for _, profile := range profiles {
-			// Get last 7 days of history for demo
+		RunAsCurrentUser: installRunAsCurrentUser,
+
+		RequireNetwork: installRequireNetwork,
+		RunMissed:      installRunMissed,
+		WakeToRun:      installWakeToRun,
+		RandomDelay:    installRandomDelay,
+
+		Hardening: installHardening,
+		CPUQuota:  installCPUQuota,
+		MemoryMax: installMemoryMax,
+
+		DryRun: installDryRun,
+
+		Schedules: cfg.Schedules,
+	}
+
+	if installProxy != "" {
+		opts.Environment = append(opts.Environment, "HTTP_PROXY="+installProxy, "HTTPS_PROXY="+installProxy)
+	}
+	opts.Environment = append(opts.Environment, envVars...)
+	if err := installer.ValidateEnvironment(opts.Environment); err != nil {
+		return &exitError{exitInstallInvalid, err}
+	}
+	if err := installer.ValidateSchedules(opts.Schedules); err != nil {
+		return &exitError{exitInstallInvalid, err}
+	}
+
+	paths := installer.GetInstallPaths(scope)
+
+	if installDryRun {
+		return runInstallPlan(inst, cfg, opts, scope)
+	}
+
+	fmt.Fprintf(out, "Installing browser history scanner...\n")
+	fmt.Fprintf(out, "  Binary: %s\n", paths.BinaryPath)
+	fmt.Fprintf(out, "  Config: %s\n", paths.ConfigPath)
+	if len(cfg.Schedules) == 0 {
+		fmt.Fprintf(out, "  Interval: %s\n", installInterval)
+	}
+	for i, sched := range cfg.Schedules {
+		full := ""
+		if sched.Full {
+			full = " (full scan)"
+		}
+		fmt.Fprintf(out, "  Schedule %d: every %s%s\n", i+1, sched.Interval, full)
+	}
+	fmt.Fprintf(out, "  Scope: %s\n", scope)
+	runAs := installUser
+	if installRunAsCurrentUser {
+		runAs = "logged-on user"
+	}
+	fmt.Fprintf(out, "  Run as: %s\n", runAs)
+	if installMode != "" {
+		fmt.Fprintf(out, "  Mode: %s\n", installMode)
+	}
+
+	// If config was obtained via auto-discovery, save it to file
+	// so scheduled runs don't depend on discovery server availability
+	if cfg.WasDiscovered() {
+		fmt.Fprintln(out, "  Config source: auto-discovery")
+		fmt.Fprintf(out, "  Saving discovered config to: %s\n", paths.ConfigPath)
+		if err := cfg.SaveToFile(paths.ConfigPath); err != nil {
+			return &exitError{exitInstallFailed, fmt.Errorf("failed to save discovered config: %w", err)}
+		}
+	}
+	fmt.Fprintln(out)
+
+	if _, err := inst.Install(cfg, opts); err != nil {
+		return &exitError{exitInstallFailed, fmt.Errorf("installation failed: %w", err)}
+	}
+
+	// Detection marker for MDM tools and fleet inventory
+	md := installer.Metadata{
+		Version:     version,
+		Commit:      commit,
+		BuildTime:   buildTime,
+		Scope:       scope,
+		Mode:        inst.Status().Mode,
+		BinaryPath:  paths.BinaryPath,
+		ConfigPath:  paths.ConfigPath,
+		InstalledAt: time.Now().UTC(),
+	}
+	if err := installer.WriteMetadata(md); err != nil {
+		return &exitError{exitInstallFailed, err}
+	}
+
+	// Users are told about the audit from the start, not from the first scan
+	written, err := notice.Write(cfg.Notice, cfg.NoticeText)
+	if err != nil {
+		return &exitError{exitInstallFailed, err}
+	}
+	for _, path := range written {
+		fmt.Fprintf(out, "User notice written to %s\n", path)
+	}
+
+	// Scans run as another account create and register the key themselves,
+	// so it is readable by them
+	if cfg.SignPayloads && installUser == "" && !installRunAsCurrentUser {
+		if err := installDeviceKey(cfg, out); err != nil {
+			fmt.Fprintf(out, "Warning: %v; the first scan registers the key\n", err)
+		}
+	}
+
+	fmt.Fprintln(out, "Installation complete!")
+	fmt.Fprintln(out, "\nThe scanner will run automatically on schedule.")
+	fmt.Fprintln(out, "To run manually: hist_scanner run --config", paths.ConfigPath)
+
+	return nil
+}
+
+// installDeviceKey creates the device signing key and registers it with the server
+func installDeviceKey(cfg *config.Config, out io.Writer) error {
+	mgr, err := loadState(cfg)
+	if err != nil {
+		return err
+	}
+	path := scanner.DeviceKeyPath(cfg, mgr)
+	key, created, err := devicekey.LoadOrCreate(path)
+	if err != nil {
+		return err
+	}
+	if created {
+		fmt.Fprintf(out, "Device key %s written to %s\n", key.ID(), path)
+	}
+	if mgr.GetDeviceKey() == key.ID() {
+		return nil
+	}
+
+	deviceID, err := platform.DeviceID()
+	if err != nil {
+		deviceID = mgr.GetDeviceID()
+	}
+	client := scanner.NewClient(cfg)
+	client.SetSigner(key.Sign)
+	if err := scanner.RegisterDeviceKey(client, cfg, key, deviceID); err != nil {
+		return fmt.Errorf("failed to register device key: %w", err)
+	}
+	mgr.SetDeviceKey(key.ID())
+	if err := mgr.Save(); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	fmt.Fprintf(out, "Device key %s registered\n", key.ID())
+	return nil
+}
+
+// runInstallPlan prints the steps an install would perform without performing them
+func runInstallPlan(inst installer.Installer, cfg *config.Config, opts installer.Options, scope installer.Scope) error {
+	steps, err := inst.Install(cfg, opts)
+	if err != nil {
+		return &exitError{exitInstallInvalid, fmt.Errorf("installation plan failed: %w", err)}
+	}
+
+	// Written by the install command itself after the scheduler entry is in place
+	steps = append(steps, installer.Step{Action: installer.ActionWrite, Target: installer.MetadataPath(scope), Mode: 0644})
+	files, err := notice.Files(cfg.Notice, cfg.NoticeText)
+	if err != nil {
+		return &exitError{exitInstallInvalid, err}
+	}
+	for _, f := range files {
+		steps = append(steps, installer.Step{Action: installer.ActionWrite, Target: f.Path, Mode: 0644, Content: string(f.Data)})
+	}
+	if cfg.SignPayloads && installUser == "" && !installRunAsCurrentUser {
+		path := cfg.DeviceKey
+		if path == "" {
+			path = filepath.Join(state.Dir(cfg.StateFile), devicekey.FileName)
+		}
+		if _, err := os.Stat(path); err != nil {
+			steps = append(steps, installer.Step{Action: installer.ActionWrite, Target: path, Mode: 0600})
+		}
+		steps = append(steps, installer.Step{Action: installer.ActionRegister, Target: "device key with " + cfg.ServerURL})
+	}
+
+	fmt.Println("Installation plan (dry run, nothing was changed):")
+	for _, step := range steps {
+		if step.Action == installer.ActionWrite {
+			fmt.Printf("\n%s %s (mode %04o)\n", step.Action, step.Target, step.Mode.Perm())
+		} else {
+			fmt.Printf("\n%s %s\n", step.Action, step.Target)
+		}
+		if step.Content != "" {
+			for _, line := range strings.Split(strings.TrimRight(step.Content, "\n"), "\n") {
+				fmt.Println(strings.TrimRight("    "+line, " "))
+			}
+		}
+	}
+
+	return nil
+}
+
+func runInstallStatus(cmd *cobra.Command, args []string) error {
+	inst, err := installer.New(installer.Scope(installScope))
+	if err != nil {
+		return fmt.Errorf("failed to create installer: %w", err)
+	}
+
+	st := inst.Status()
+
+	// Resolve the state file the scheduled runs use and read their recent results
+	if cfg, err := config.LoadFile(st.ConfigPath); err == nil {
+		st.StatePath = state.Locate(cfg.StateFile)
+		if st.StatePath != "" {
+			st.Runs = loadRuns(cfg, statusRuns)
+		}
+	} else {
+		st.StatePath = state.Locate("")
+	}
+
+	// Fall back to the recorded runs where the scheduler keeps no run history
+	if st.LastRun == "" && len(st.Runs) > 0 {
+		st.LastRun = st.Runs[0].Started.Local().Format(time.DateTime)
+		st.LastResult = fmt.Sprintf("exit code %d", st.Runs[0].ExitCode)
+	}
+
+	if statusJSON {
+		data, err := json.MarshalIndent(st, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal status: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	installed := "no"
+	if st.Installed {
+		installed = "yes"
+	}
+
+	fmt.Printf("Installed:   %s\n", installed)
+	fmt.Printf("Scope:       %s\n", st.Scope)
+	fmt.Printf("Mode:        %s\n", orNone(st.Mode))
+	fmt.Printf("Interval:    %s\n", orNone(st.Interval))
+	fmt.Printf("Last run:    %s\n", orNone(st.LastRun))
+	fmt.Printf("Last result: %s\n", orNone(st.LastResult))
+	fmt.Printf("Binary:      %s\n", st.BinaryPath)
+	fmt.Printf("Config:      %s\n", st.ConfigPath)
+	fmt.Printf("State:       %s\n", orNone(st.StatePath))
+
+	if len(st.Runs) > 0 {
+		fmt.Printf("\nRecent runs:\n")
+		for _, run := range st.Runs {
+			full := ""
+			if run.Full {
+				full = " (full)"
+			}
+			if run.Ranged {
+				full = " (range)"
+			}
+			if run.UsersSkipped > 0 {
+				full += fmt.Sprintf(" (%d users skipped)", run.UsersSkipped)
+			}
+			fmt.Printf("  %s  exit %d  %d users, %d profiles, %d entries, %d errors  %s%s\n",
+				run.Started.Local().Format(time.DateTime), run.ExitCode, run.UsersScanned, run.ProfilesScanned,
+				run.EntriesSent, len(run.Errors), time.Duration(run.DurationMS)*time.Millisecond, full)
+			if t := run.Timing; t != nil {
+				fmt.Printf("    time: enumeration %s, %s\n", msDuration(t.EnumerationMS), formatPhases(t.Phases))
+				if len(t.Slowest) > 0 {
+					p := t.Slowest[0]
+					fmt.Printf("    slowest: %s/%s/%s %s (%s)\n", p.User, p.Browser, p.Profile, msDuration(p.DurationMS), formatPhases(p.Phases))
+				}
+			}
+			if len(run.ErrorCategories) > 0 {
+				fmt.Printf("    errors by category: %s\n", formatCategories(run.ErrorCategories))
+			}
+			for _, e := range run.Errors {
+				fmt.Printf("    %s\n", e)
+			}
+		}
+	}
+	return nil
+}
+
+// formatCategories lists error counts by category, e.g. "locked 2, permission 1"
+func formatCategories(counts map[string]int) string {
+	var parts []string
+	for _, category := range slices.Sorted(maps.Keys(counts)) {
+		parts = append(parts, fmt.Sprintf("%s %d", category, counts[category]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatPhases lists the phase times of a run record
+func formatPhases(p state.PhaseMS) string {
+	return fmt.Sprintf("open %s, query %s, transform %s, compress %s, http %s",
+		msDuration(p.OpenMS), msDuration(p.QueryMS), msDuration(p.TransformMS), msDuration(p.CompressMS), msDuration(p.HTTPMS))
+}
+
+// msDuration converts milliseconds to a duration for display
+func msDuration(ms int64) time.Duration {
+	return time.Duration(ms) * time.Millisecond
+}
+
+// loadRuns reads up to n recent run records from the installed state file.
+// Errors are ignored: status output should still work without readable state.
+func loadRuns(cfg *config.Config, n int) []state.RunRecord {
+	mgr, err := loadState(cfg)
+	if err != nil {
+		return nil
+	}
+	return mgr.GetRuns(n)
+}
+
+// loadState loads the state file of a config, decrypting it if needed
+func loadState(cfg *config.Config) (*state.Manager, error) {
+	mgr := state.NewManager(cfg.StateFile)
+	if cfg.StateEncryption {
+		if err := mgr.EnableEncryption(cfg.StateKey); err != nil {
+			return nil, err
+		}
+	}
+	if err := mgr.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	return mgr, nil
+}
+
+// agentStatus is the output of the status command
+type agentStatus struct {
+	Version       string   `json:"version"`
+	ConfigPath    string   `json:"config_path,omitempty"`
+	ConfigSources []string `json:"config_sources"`
+	ConfigError   string   `json:"config_error,omitempty"`
+
+	StatePath     string           `json:"state_path,omitempty"`
+	StateProfiles int              `json:"state_profiles"`
+	StateError    string           `json:"state_error,omitempty"`
+	LastSend      time.Time        `json:"last_send,omitzero"`
+	LastRun       *state.RunRecord `json:"last_run,omitempty"`
+
+	Scheduler installer.Status `json:"scheduler"`
+
+	ServerURL       string `json:"server_url,omitempty"`
+	ServerReachable bool   `json:"server_reachable"`
+	ServerError     string `json:"server_error,omitempty"`
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	inst, err := installer.New(installer.Scope(installScope))
+	if err != nil {
+		return fmt.Errorf("failed to create installer: %w", err)
+	}
+
+	st := agentStatus{
+		Version:       version,
+		Scheduler:     inst.Status(),
+		ConfigSources: []string{},
+	}
+
+	// Without --config, report on the config the scheduled runs use
+	if cfgFile == "" && st.Scheduler.Installed {
+		if _, err := os.Stat(st.Scheduler.ConfigPath); err == nil {
+			cfgFile = st.Scheduler.ConfigPath
+		}
+	}
+	st.ConfigPath = cfgFile
+
+	cfg, err := loadConfig(cmd)
+	if err == nil {
+		st.ConfigSources = append(st.ConfigSources, cfg.Sources()...)
+		err = cfg.Validate()
+	}
+	if err != nil {
+		st.ConfigError = err.Error()
+	}
+
+	if cfg != nil {
+		st.StatePath = state.Locate(cfg.StateFile)
+		if st.StatePath != "" {
+			if mgr, err := loadState(cfg); err != nil {
+				st.StateError = err.Error()
+			} else {
+				st.StateProfiles = len(mgr.GetAllEntries())
+				st.LastSend = mgr.GetLastSend()
+				if runs := mgr.GetRuns(1); len(runs) > 0 {
+					st.LastRun = &runs[0]
+				}
+			}
+		}
+
+		st.ServerURL = cfg.ServerURL
+		if cfg.ServerURL != "" {
+			client := scanner.NewClient(cfg)
+			if err := client.TestConnection(); err != nil {
+				st.ServerError = err.Error()
+			} else {
+				st.ServerReachable = true
+			}
+		}
+	}
+
+	if statusJSON {
+		data, err := json.MarshalIndent(st, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal status: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		printStatus(st)
+	}
+
+	switch {
+	case st.ConfigError != "":
+		cmd.SilenceUsage = true
+		return &exitError{1, fmt.Errorf("invalid config: %s", st.ConfigError)}
+	case !st.ServerReachable:
+		cmd.SilenceUsage = true
+		return &exitError{1, fmt.Errorf("server unreachable")}
+	}
+	return nil
+}
+
+// printStatus writes the status command's text output
+func printStatus(st agentStatus) {
+	sources := "defaults"
+	if len(st.ConfigSources) > 0 {
+		sources = strings.Join(st.ConfigSources, ", ")
+	}
+	if st.ConfigError != "" {
+		sources += " (" + st.ConfigError + ")"
+	}
+
+	stateInfo := "-"
+	switch {
+	case st.StateError != "":
+		stateInfo = fmt.Sprintf("%s (%s)", st.StatePath, st.StateError)
+	case st.StatePath != "":
+		stateInfo = fmt.Sprintf("%s (%d profiles)", st.StatePath, st.StateProfiles)
+	}
+
+	lastSend := "never"
+	if !st.LastSend.IsZero() {
+		lastSend = st.LastSend.Local().Format(time.DateTime)
+	}
+
+	lastRun := "-"
+	if st.LastRun != nil {
+		lastRun = fmt.Sprintf("%s (exit code %d)", st.LastRun.Started.Local().Format(time.DateTime), st.LastRun.ExitCode)
+	}
+
+	scheduler := "not installed"
+	if st.Scheduler.Installed {
+		scheduler = fmt.Sprintf("%s, every %s (%s scope)", orNone(st.Scheduler.Mode), orNone(st.Scheduler.Interval), st.Scheduler.Scope)
+	}
+
+	server := "not configured"
+	switch {
+	case st.ServerReachable:
+		server = st.ServerURL + " (reachable)"
+	case st.ServerURL != "":
+		server = fmt.Sprintf("%s (unreachable: %s)", st.ServerURL, st.ServerError)
+	}
+
+	fmt.Printf("Version:     %s\n", st.Version)
+	fmt.Printf("Config:      %s\n", orNone(st.ConfigPath))
+	fmt.Printf("Sources:     %s\n", sources)
+	fmt.Printf("State:       %s\n", stateInfo)
+	fmt.Printf("Last run:    %s\n", lastRun)
+	fmt.Printf("Last send:   %s\n", lastSend)
+	fmt.Printf("Scheduler:   %s\n", scheduler)
+	fmt.Printf("Server:      %s\n", server)
+}
+
+// orNone returns "-" for empty status fields
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func runUpgrade(cmd *cobra.Command, args []string) error {
+	if err := refuseInContainer("upgrade"); err != nil {
+		return err
+	}
+	scope := installer.Scope(installScope)
+	inst, err := installer.New(scope)
+	if err != nil {
+		return fmt.Errorf("failed to create installer: %w", err)
+	}
+
+	if !inst.IsInstalled() {
+		return fmt.Errorf("scanner is not installed (use install)")
+	}
+
+	paths := installer.GetInstallPaths(scope)
+
+	fmt.Printf("Upgrading browser history scanner to %s...\n", version)
+	fmt.Printf("  Binary: %s\n", paths.BinaryPath)
+	fmt.Printf("  Config: %s\n", paths.ConfigPath)
+
+	if err := inst.Upgrade(); err != nil {
+		return fmt.Errorf("upgrade failed: %w", err)
+	}
+
+	cfg, err := installer.MigrateConfig(paths.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to migrate config: %w", err)
+	}
+
+	// Rewrite the state file in the current format, keeping all watermarks
+	mgr := state.NewManager(cfg.StateFile)
+	if cfg.StateEncryption {
+		if err := mgr.EnableEncryption(cfg.StateKey); err != nil {
+			return err
+		}
+	}
+	if err := mgr.Load(); err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+	if path := state.Locate(cfg.StateFile); path != "" {
+		fmt.Printf("  State: %s\n", path)
+		if err := mgr.Save(); err != nil {
+			return fmt.Errorf("failed to migrate state: %w", err)
+		}
+	}
+
+	// Keep the detection marker in sync with the installed version
+	md := installer.Metadata{
+		Version:     version,
+		Commit:      commit,
+		BuildTime:   buildTime,
+		Scope:       scope,
+		Mode:        inst.Status().Mode,
+		BinaryPath:  paths.BinaryPath,
+		ConfigPath:  paths.ConfigPath,
+		InstalledAt: time.Now().UTC(),
+	}
+	if prev, err := installer.ReadMetadata(scope); err == nil {
+		md.InstalledAt = prev.InstalledAt
+	}
+	if err := installer.WriteMetadata(md); err != nil {
+		return err
+	}
+
+	fmt.Println("Upgrade complete!")
+	return nil
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	if err := refuseInContainer("verify"); err != nil {
+		return err
+	}
+	inst, err := installer.New(installer.Scope(installScope))
+	if err != nil {
+		return fmt.Errorf("failed to create installer: %w", err)
+	}
+
+	checks := inst.Verify(verifyFix)
+
+	failed := 0
+	for _, c := range checks {
+		if !c.OK {
+			failed++
+		}
+	}
+
+	if statusJSON {
+		data, err := json.MarshalIndent(checks, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal checks: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		for _, c := range checks {
+			result := "OK"
+			switch {
+			case c.Fixed:
+				result = "FIXED"
+			case !c.OK:
+				result = "FAIL"
+			}
+			fmt.Printf("%-6s %-10s %s\n", result, c.Name, c.Detail)
+		}
+	}
+
+	if failed > 0 {
+		cmd.SilenceUsage = true
+		return &exitError{1, fmt.Errorf("%d of %d checks failed", failed, len(checks))}
+	}
+	return nil
+}
+
+func runUninstall(cmd *cobra.Command, args []string) error {
+	if err := refuseInContainer("uninstall"); err != nil {
+		return err
+	}
+	if uninstallKeepState && !uninstallPurge {
+		return fmt.Errorf("--keep-state requires --purge")
+	}
+	inst, err := installer.New(installer.Scope(installScope))
+	if err != nil {
+		return fmt.Errorf("failed to create installer: %w", err)
+	}
+
+	if !inst.IsInstalled() {
+		fmt.Println("Scanner is not installed.")
+		return nil
+	}
+
+	fmt.Println("Uninstalling browser history scanner...")
+
+	removed, err := inst.Uninstall(installer.UninstallOptions{
+		Purge:     uninstallPurge,
+		KeepState: uninstallKeepState,
+	})
+	for _, item := range removed {
+		fmt.Printf("  Removed: %s\n", item)
+	}
+	if err != nil {
+		return fmt.Errorf("uninstallation failed: %w", err)
+	}
+
+	if !uninstallPurge {
+		fmt.Println("  Kept: state, keys and logs (use --purge to remove)")
+	} else if uninstallKeepState {
+		fmt.Println("  Kept: state file and its encryption key")
+	}
+
+	fmt.Println("Uninstallation complete!")
+	return nil
+}
+
+func runPackage(cmd *cobra.Command, args []string) error {
+	opts := packager.Options{
+		Format:     packageFormat,
+		Version:    packageVersion,
+		Arch:       packageArch,
+		BinaryPath: packageBinary,
+		OutputDir:  packageOutput,
+		Interval:   packageInterval,
+		Maintainer: packageMaintainer,
+	}
+
+	path, err := packager.Build(opts)
+	if err != nil {
+		return fmt.Errorf("failed to build package: %w", err)
+	}
+
+	fmt.Printf("Package written to %s\n", path)
+	return nil
+}
+
+func runADMX(cmd *cobra.Command, args []string) error {
+	written, err := admx.Write(admxOutput)
+	if err != nil {
+		return fmt.Errorf("failed to generate templates: %w", err)
+	}
+
+	for _, path := range written {
+		fmt.Printf("Written %s\n", path)
+	}
+	return nil
+}
+
+func runDebugUsers(cmd *cobra.Command, args []string) error {
+	fmt.Printf("Platform: %s\n\n", platform.CurrentOS())
+
+	users, err := platform.GetAllUsers()
+	if err != nil {
+		return fmt.Errorf("failed to enumerate users: %w", err)
+	}
+
+	if join := platform.GetDeviceJoin(); join != nil {
+		fmt.Printf("Device join: %s", join.Type)
+		if join.Domain != "" {
+			fmt.Printf(" (domain %s)", join.Domain)
+		}
+		if join.TenantID != "" {
+			fmt.Printf(" (tenant %s %s)", join.TenantName, join.TenantID)
+		}
+		fmt.Printf("\n\n")
+	}
+
+	denied := false
+	fmt.Printf("Found %d users:\n", len(users))
+	for _, u := range users {
+		fmt.Printf("  - %s (UID: %s)\n", u.DisplayName(), u.UID)
+		fmt.Printf("    Home: %s\n", u.HomeDir)
+		if u.SID != "" {
+			fmt.Printf("    SID:  %s\n", u.SID)
+		}
+		if disabled := platform.AccountDisabled(u); disabled != "" {
+			fmt.Printf("    Skipped: account-disabled (%s)\n", disabled)
+		}
+		if locked := platform.LockedHome(u); locked != "" {
+			fmt.Printf("    Skipped: inaccessible-encrypted (%s)\n", locked)
+		}
+		if id := platform.GetIdentity(u); id != nil {
+			fmt.Printf("    Account: %s\n", orNone(id.Account))
+			fmt.Printf("    UPN: %s\n", orNone(id.UPN))
+			if id.Directory != "" {
+				fmt.Printf("    Directory: %s\n", id.Directory)
+			}
+		}
+		for _, a := range scanner.CheckAccess(u, browser.All()) {
+			if a.Status == scanner.AccessDenied {
+				denied = true
+			}
+			if a.Status == scanner.AccessNotPresent && a.Browser != "" {
+				continue
+			}
+			name := a.Browser
+			if name == "" {
+				name = "home"
+			}
+			fmt.Printf("    Access %s: %s (%s)\n", name, a.Status, a.Path)
+		}
+	}
+
+	if denied {
+		if platform.IsPrivileged() {
+			fmt.Printf("\nSome browser data is not readable even with elevated privileges.\n")
+		} else {
+			fmt.Printf("\nSome browser data is not readable: %s.\n", platform.PrivilegeAdvice())
+		}
+	}
+
+	storeUsers, err := platform.GetProfileStoreUsers()
+	if err != nil {
+		fmt.Printf("\nProfile stores: %v\n", err)
+	}
+	if len(storeUsers) > 0 {
+		fmt.Printf("\nProfile store users (signed out, scanned with profile_stores: true):\n")
+		for _, u := range storeUsers {
+			fmt.Printf("  - %s (%s)\n", u.Username, u.ProfileStore)
+			if u.Container != "" {
+				fmt.Printf("    Container: %s\n", u.Container)
+			} else {
+				fmt.Printf("    Home: %s\n", u.HomeDir)
+			}
+		}
+	}
+
+	if platform.IsWSL() {
+		wslUsers, err := platform.GetWSLUsers(false)
+		if err != nil {
+			fmt.Printf("\nWindows profiles: %v\n", err)
+		}
+		if len(wslUsers) > 0 {
+			fmt.Printf("\nWindows profiles (WSL):\n")
+			for _, u := range wslUsers {
+				fmt.Printf("  - %s\n", u.Username)
+				fmt.Printf("    Home: %s\n", u.HomeDir)
+			}
+		}
+	}
+
+	return nil
+}
+
+func runDebugBrowser(cmd *cobra.Command, args []string) error {
+	browserName := args[0]
+
+	b := browser.ByName(browserName)
+	if b == nil {
+		return fmt.Errorf("unknown browser: %s\nSupported: %s", browserName, strings.Join(browser.SupportedBrowserNames(), ", "))
+	}
+
+	fmt.Printf("Testing browser: %s\n\n", b.Name())
+
+	users, err := debugUserList(debugUser)
+	if err != nil {
+		return err
+	}
+
+	totalEntries := 0
+	for _, user := range users {
+		profiles, err := b.FindProfiles(user)
+		if err != nil {
+			fmt.Printf("User %s: error finding profiles: %v\n", user.Username, err)
+			continue
+		}
+
+		if len(profiles) == 0 {
+			fmt.Printf("User %s: no profiles found\n", user.Username)
+			continue
+		}
+
+		fmt.Printf("User %s: found %d profile(s)\n", user.Username, len(profiles))
+
+		for _, profile := range profiles {
+			// Get last 7 days of history for demo
 			sinceTimestamp := time.Now().AddDate(0, 0, -7).UnixMilli()
 
-			entries, err := b.GetHistory(profile, sinceTimestamp)
+			entries, err := b.GetHistory(profile, browser.Cursor{Timestamp: sinceTimestamp})
+			if err != nil {
+				fmt.Printf("  Profile %s: error reading history: %v\n", profile.Name, err)
+				continue
+			}
+
+			fmt.Printf("  Profile %s: %d entries (last 7 days)\n", profile.Name, len(entries))
+			totalEntries += len(entries)
+
+			// Show first 5 entries as sample
+			for i, entry := range entries {
+				if i >= 5 {
+					fmt.Printf("    ... and %d more\n", len(entries)-5)
+					break
+				}
+				t := time.UnixMilli(entry.Timestamp)
+				// Truncate URL for display
+				url := entry.URL
+				if len(url) > 60 {
+					url = url[:57] + "..."
+				}
+				fmt.Printf("    %s | %s\n", t.Format("2006-01-02 15:04"), url)
+			}
+		}
+	}
+
+	fmt.Printf("\nTotal entries found: %d\n", totalEntries)
+	return nil
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	if !slices.Contains(importer.Formats, importFormat) {
+		return fmt.Errorf("unknown format %q (supported: %s)", importFormat, strings.Join(importer.Formats, ", "))
+	}
+	principal := dto.NewUserPrincipal(importUser)
+	if importIP != "" {
+		if net.ParseIP(importIP) == nil {
+			return fmt.Errorf("invalid --ip %q", importIP)
+		}
+		principal = dto.NewIPPrincipal(importIP)
+	}
+
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if !dryRun {
+		if err := cfg.Validate(); err != nil {
+			return fmt.Errorf("invalid config: %w", err)
+		}
+	}
+
+	cmd.SilenceUsage = true
+	read, err := importer.ReadFile(importFormat, args[0])
+	if err != nil {
+		return err
+	}
+	if read.Skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Skipped %d records without a valid URL or time\n", read.Skipped)
+	}
+	if len(read.Visits) == 0 {
+		return fmt.Errorf("no visits in %s", args[0])
+	}
+
+	s, err := scanner.New(cfg, dryRun)
+	if err != nil {
+		return fmt.Errorf("failed to initialize scanner: %w", err)
+	}
+	s.SetVersion(version)
+
+	result := s.Import(context.Background(), scanner.Import{
+		Format:    importFormat,
+		File:      args[0],
+		Principal: principal,
+		Visits:    read.Visits,
+	})
+	if !dryRun {
+		fmt.Fprintf(os.Stderr, "Imported %d of %d visits\n", result.EntriesSent, len(read.Visits))
+	}
+	if result.ExitCode != scanner.ExitSuccess {
+		os.Exit(int(result.ExitCode))
+	}
+	return nil
+}
+
+func runProxy(cmd *cobra.Command, args []string) error {
+	if !slices.Contains(proxylog.Formats, proxyFormat) {
+		return fmt.Errorf("unknown format %q (supported: %s)", proxyFormat, strings.Join(proxylog.Formats, ", "))
+	}
+	if proxyFollow && proxyPoll <= 0 {
+		return fmt.Errorf("poll must be > 0")
+	}
+
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if !dryRun {
+		if err := cfg.Validate(); err != nil {
+			return fmt.Errorf("invalid config: %w", err)
+		}
+	}
+
+	cmd.SilenceUsage = true
+	s, err := scanner.New(cfg, dryRun)
+	if err != nil {
+		return fmt.Errorf("failed to initialize scanner: %w", err)
+	}
+	s.SetVersion(version)
+
+	p := scanner.ProxyLog{Path: args[0], Format: proxyFormat, Follow: proxyFollow, Poll: proxyPoll}
+	var result *scanner.ScanResult
+	if proxyFollow {
+		if err := service.Run(func(ctx context.Context) {
+			result = s.ReadProxyLog(ctx, p)
+		}); err != nil {
+			return err
+		}
+	} else {
+		result = s.ReadProxyLog(context.Background(), p)
+	}
+	if !dryRun {
+		fmt.Fprintf(os.Stderr, "Sent %d visits\n", result.EntriesSent)
+	}
+	if result.ExitCode != scanner.ExitSuccess {
+		os.Exit(int(result.ExitCode))
+	}
+	return nil
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	format := exportFormat
+	if format == "" {
+		if format = export.FormatFromPath(exportOut); format == "" {
+			format = "html"
+		}
+	}
+	if !slices.Contains(export.Formats, format) {
+		return fmt.Errorf("unknown format %q (supported: %s)", format, strings.Join(export.Formats, ", "))
+	}
+
+	now := time.Now()
+	since, until, err := parseRange(exportSince, exportUntil, now)
+	if err != nil {
+		return err
+	}
+
+	filter, err := runFilter()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	db.SetCopyOptions(db.CopyOptions{Shred: cfg.ShredTempFiles})
+
+	// The report holds browsing history, so it is readable by the owner only
+	out := io.Writer(os.Stdout)
+	var file *os.File
+	if exportOut != "-" {
+		f, err := os.OpenFile(exportOut, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to create report: %w", err)
+		}
+		defer f.Close()
+		out, file = f, f
+	}
+	var sealed *seal.Writer
+	if exportEncrypt || cfg.EncryptExports {
+		key, err := seal.Key(cfg.StateKey, exportKeyInfo, state.NewManager(cfg.StateFile).KeyPath())
+		if err != nil {
+			return err
+		}
+		if sealed, err = seal.NewWriter(out, key); err != nil {
+			return fmt.Errorf("failed to encrypt report: %w", err)
+		}
+		out = sealed
+	}
+
+	hostname := platform.Hostname()
+	w, err := export.NewWriter(format, out, export.Meta{Host: hostname, Generated: now, Since: since, Until: until})
+	if err != nil {
+		return err
+	}
+
+	stats, err := localScan(filter, since, until, w)
+	if err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if sealed != nil {
+		if err := sealed.Close(); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+	// A full disk or network share may only report the failure on close
+	if file != nil {
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported %s\n", stats)
+	return nil
+}
+
+// exportKeyInfo binds the export encryption key to its purpose
+const exportKeyInfo = "hist_scanner export encryption"
+
+func runDecrypt(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	key, err := seal.Key(cfg.StateKey, exportKeyInfo, state.NewManager(cfg.StateFile).KeyPath())
+	if err != nil {
+		return err
+	}
+	keys := [][]byte{key}
+	// Reports exported by older versions without a state_key
+	if legacy, err := seal.LegacyKey(exportKeyInfo); err == nil && cfg.StateKey == "" {
+		keys = append(keys, legacy)
+	}
+
+	in, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	r, err := seal.NewReader(in, keys...)
+	if err != nil {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+
+	out := io.Writer(os.Stdout)
+	var file *os.File
+	if decryptOut != "-" {
+		f, err := os.OpenFile(decryptOut, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", decryptOut, err)
+		}
+		defer f.Close()
+		out, file = f, f
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	if file != nil {
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", decryptOut, err)
+		}
+	}
+	return nil
+}
+
+func runAuditVerify(cmd *cobra.Command, args []string) error {
+	var path string
+	if len(args) > 0 {
+		path = args[0]
+	} else {
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		if cfg.AuditLog == "" {
+			return fmt.Errorf("audit_log is not configured; pass the log path")
+		}
+		path = cfg.AuditLog
+	}
+
+	n, err := audit.Verify(path)
+	if err != nil {
+		fmt.Printf("%s: %d valid records before the error\n", path, n)
+		cmd.SilenceUsage = true
+		return &exitError{1, err}
+	}
+	fmt.Printf("%s: %d records, chain intact\n", path, n)
+	return nil
+}
+
+func runOptoutKeygen(cmd *cobra.Command, args []string) error {
+	if optoutKeyFile == "" {
+		return fmt.Errorf("--key-file is required")
+	}
+	if _, err := os.Stat(optoutKeyFile); err == nil {
+		return fmt.Errorf("%s already exists", optoutKeyFile)
+	}
+	pub, priv, err := optout.GenerateKey()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(optoutKeyFile, []byte(priv+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write private key: %w", err)
+	}
+	fmt.Printf("Private key written to %s\n", optoutKeyFile)
+	fmt.Printf("optout_public_key: %s\n", pub)
+	return nil
+}
+
+func runOptoutSign(cmd *cobra.Command, args []string) error {
+	if optoutKeyFile == "" {
+		return fmt.Errorf("--key-file is required")
+	}
+	data, err := os.ReadFile(optoutKeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read private key: %w", err)
+	}
+	key, err := optout.ParsePrivateKey(string(data))
+	if err != nil {
+		return err
+	}
+
+	var expires time.Time
+	if optoutExpires != "" {
+		expires, err = time.ParseInLocation(time.DateOnly, optoutExpires, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --expires %q: want YYYY-MM-DD", optoutExpires)
+		}
+	}
+
+	marker, err := optout.Sign(key, args[0], time.Now(), expires)
+	if err != nil {
+		return err
+	}
+	if optoutOutput == "" {
+		_, err = os.Stdout.Write(marker)
+		return err
+	}
+	if err := os.WriteFile(optoutOutput, marker, 0644); err != nil {
+		return fmt.Errorf("failed to write marker: %w", err)
+	}
+	fmt.Printf("Opt-out marker for %s written to %s\n", args[0], optoutOutput)
+	return nil
+}
+
+func runExtensions(cmd *cobra.Command, args []string) error {
+	minRisk := slices.Index(catalog.Risks, extensionsMinRisk)
+	if minRisk < 0 {
+		return fmt.Errorf("--min-risk must be low, medium or high")
+	}
+	filter, err := runFilter()
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	rules := extension.Rules{Risky: cfg.RiskyExtensions}
+
+	users, err := platform.GetAllUsers()
+	if err != nil {
+		return fmt.Errorf("failed to enumerate users: %w", err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "USER\tBROWSER\tPROFILE\tEXTENSION\tVERSION\tRISK\tREASONS")
+	counts := make(map[string]int)
+	for _, user := range filter.SelectUsers(users) {
+		for _, b := range filter.SelectBrowsers(browser.All()) {
+			profiles, err := b.FindProfiles(user)
+			if err != nil {
+				continue
+			}
+			for _, profile := range filter.SelectProfiles(profiles) {
+				exts, err := extension.List(profile.Path)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s/%s/%s: %v\n", user.Username, b.Name(), profile.Name, err)
+					continue
+				}
+				for _, e := range exts {
+					rules.Score(&e)
+					counts[e.Risk]++
+					if slices.Index(catalog.Risks, e.Risk) < minRisk {
+						continue
+					}
+					name := e.Name
+					if name == "" {
+						name = e.ID
+					} else {
+						name += " (" + e.ID + ")"
+					}
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", user.Username, b.Name(), profile.Name, name, e.Version, e.Risk, strings.Join(e.Reasons, "; "))
+				}
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "\n%d high, %d medium, %d low risk extensions\n", counts[extension.RiskHigh], counts[extension.RiskMedium], counts[extension.RiskLow])
+	return nil
+}
+
+func runCatalogKeygen(cmd *cobra.Command, args []string) error {
+	if catalogKeyFile == "" {
+		return fmt.Errorf("--key-file is required")
+	}
+	if _, err := os.Stat(catalogKeyFile); err == nil {
+		return fmt.Errorf("%s already exists", catalogKeyFile)
+	}
+	pub, priv, err := catalog.GenerateKey()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(catalogKeyFile, []byte(priv+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write private key: %w", err)
+	}
+	fmt.Printf("Private key written to %s\n", catalogKeyFile)
+	fmt.Printf("catalog_public_key: %s\n", pub)
+	return nil
+}
+
+func runCatalogSign(cmd *cobra.Command, args []string) error {
+	if catalogKeyFile == "" {
+		return fmt.Errorf("--key-file is required")
+	}
+	keyData, err := os.ReadFile(catalogKeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read private key: %w", err)
+	}
+	key, err := catalog.ParsePrivateKey(string(keyData))
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read bundle: %w", err)
+	}
+	signed, err := catalog.SignBundle(key, data)
+	if err != nil {
+		return err
+	}
+	if catalogOutput == "" {
+		_, err = os.Stdout.Write(append(signed, '\n'))
+		return err
+	}
+	if err := os.WriteFile(catalogOutput, append(signed, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write signed bundle: %w", err)
+	}
+	fmt.Printf("Signed catalog bundle written to %s\n", catalogOutput)
+	return nil
+}
+
+func runReport(cmd *cobra.Command, args []string) error {
+	if reportDays <= 0 {
+		return fmt.Errorf("--days must be > 0")
+	}
+
+	filter, err := runFilter()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	db.SetCopyOptions(db.CopyOptions{Shred: cfg.ShredTempFiles})
+
+	now := time.Now()
+	hostname := platform.Hostname()
+	meta := export.Meta{Host: hostname, Generated: now, Since: now.AddDate(0, 0, -reportDays)}
+	c, err := catalog.Load(cachedBundle(cfg), cfg.CatalogFile)
+	if err != nil {
+		return err
+	}
+	report := export.NewUsageReport(meta, c, cfg.SanctionedServices)
+
+	stats, err := localScan(filter, meta.Since, time.Time{}, report)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Scanned %s\n\n", stats)
+
+	return report.Print(os.Stdout, reportTop)
+}
+
+// parseRange parses the --since and --until flags; empty flags give zero times
+func parseRange(sinceFlag, untilFlag string, now time.Time) (since, until time.Time, err error) {
+	if sinceFlag != "" {
+		if since, err = export.ParseSince(sinceFlag, now); err != nil {
+			return since, until, fmt.Errorf("--since: %w", err)
+		}
+	}
+	if untilFlag != "" {
+		if until, err = export.ParseUntil(untilFlag, now); err != nil {
+			return since, until, fmt.Errorf("--until: %w", err)
+		}
+	}
+	if !since.IsZero() && !until.IsZero() && !since.Before(until) {
+		return since, until, fmt.Errorf("--since must be before --until")
+	}
+	return since, until, nil
+}
+
+// cachedBundle returns the catalog bundle the last scan cached, or nil if
+// catalog_url is not set or there is no valid one; report does not download it
+func cachedBundle(cfg *config.Config) *catalog.Bundle {
+	if cfg.CatalogURL == "" {
+		return nil
+	}
+	key, err := catalog.ParsePublicKey(cfg.CatalogPublicKey)
+	if err != nil {
+		return nil
+	}
+	b, err := catalog.ReadBundle(key, catalog.BundleCache(state.NewManager(cfg.StateFile).StateDir()))
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// refuseInContainer fails the installer commands in a container, whose
+// scheduler and files do not outlive it; the container runs the daemon or is
+// started on a schedule instead
+func refuseInContainer(command string) error {
+	if !platform.InContainer() {
+		return nil
+	}
+	return fmt.Errorf("%s is not available in a container: run hist_scanner daemon as the container's command, or start hist_scanner run on a schedule (e.g. a Kubernetes CronJob)", command)
+}
+
+// imageRoot returns the absolute path of the --root image, or "" to scan
+// this system
+func imageRoot() (string, error) {
+	if runRoot == "" {
+		if len(runRootUsers) > 0 {
+			return "", fmt.Errorf("--root-user requires --root")
+		}
+		return "", nil
+	}
+	root, err := filepath.Abs(runRoot)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve --root: %w", err)
+	}
+	if _, err := platform.ImageOS(root); err != nil {
+		return "", err
+	}
+	return root, nil
+}
+
+// runFilter returns the filter of the --user, --browser and --profile flags
+func runFilter() (scanner.Filter, error) {
+	for _, name := range runBrowsers {
+		if browser.ByName(strings.ToLower(name)) == nil {
+			return scanner.Filter{}, fmt.Errorf("unknown browser: %s\nSupported: %s", name, strings.Join(browser.SupportedBrowserNames(), ", "))
+		}
+	}
+	return scanner.Filter{Users: runUsers, Browsers: runBrowsers, Profiles: runProfiles}, nil
+}
+
+// localScanStats counts what localScan read
+type localScanStats struct {
+	users, profiles, visits, failures int
+}
+
+func (s localScanStats) String() string {
+	text := fmt.Sprintf("%d visits from %d profile(s) of %d user(s)", s.visits, s.profiles, s.users)
+	if s.failures > 0 {
+		text += fmt.Sprintf(", %d error(s)", s.failures)
+	}
+	return text
+}
+
+// localScan writes the history of the filtered users from since up to until
+// (zero for no upper bound) to w, without sending it or touching the state. Unreadable profiles are
+// reported on stderr; an error from w stops the scan.
+func localScan(filter scanner.Filter, since, until time.Time, w export.Writer) (localScanStats, error) {
+	var stats localScanStats
+
+	users, err := platform.GetAllUsers()
+	if err != nil {
+		return stats, fmt.Errorf("failed to enumerate users: %w", err)
+	}
+	if users = filter.SelectUsers(users); len(users) == 0 {
+		return stats, fmt.Errorf("no users to scan")
+	}
+	stats.users = len(users)
+
+	defer platform.ReleaseShadowCopies()
+	defer db.RemoveTempDirs()
+
+	for _, user := range users {
+		for _, b := range filter.SelectBrowsers(browser.All()) {
+			profiles, err := b.FindProfiles(user)
 			if err != nil {
-				fmt.Printf("  Profile %s: error reading history: %v\n", profile.Name, err)
+				fmt.Fprintf(os.Stderr, "%s/%s: %v\n", user.Username, b.Name(), err)
+				stats.failures++
 				continue
 			}
 
-			fmt.Printf("  Profile %s: %d entries (last 7 days)\n", profile.Name, len(entries))
-			totalEntries += len(entries)
+			for _, profile := range filter.SelectProfiles(profiles) {
+				stats.profiles++
+				var writeErr error
+				err := b.StreamHistory(context.Background(), profile, browser.Cursor{Timestamp: since.UnixMilli()}, func(site dto.VisitedSite) error {
+					if !until.IsZero() && site.Timestamp >= until.UnixMilli() {
+						return nil
+					}
+					stats.visits++
+					writeErr = w.Write(export.Visit{
+						User:    user.Username,
+						Browser: b.Name(),
+						Profile: profile.Name,
+						Time:    time.UnixMilli(site.Timestamp),
+						URL:     site.URL,
+						Domain:  export.Domain(site.URL),
+					})
+					return writeErr
+				})
+				if writeErr != nil {
+					return stats, fmt.Errorf("failed to write report: %w", writeErr)
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s/%s/%s: %v\n", user.Username, b.Name(), profile.Name, err)
+					stats.failures++
+				}
+			}
+		}
+	}
 
-			// Show first 5 entries as sample
-			for i, entry := range entries {
-				if i >= 5 {
-					fmt.Printf("    ... and %d more\n", len(entries)-5)
-					break
+	return stats, nil
+}
+
+func runPreview(cmd *cobra.Command, args []string) error {
+	if previewLimit < 0 {
+		return fmt.Errorf("--limit must be >= 0")
+	}
+
+	filter, err := runFilter()
+	if err != nil {
+		return err
+	}
+	root, err := imageRoot()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	s, err := scanner.New(cfg, true)
+	if err != nil {
+		return fmt.Errorf("failed to initialize scanner: %w", err)
+	}
+	if root != "" {
+		s.SetRoot(root, runRootUsers)
+	}
+	s.SetFilter(filter)
+	s.SetVersion(version)
+	s.SetLimit(previewLimit)
+
+	payloads := 0
+	s.SetDryRunOutput(func(payload dto.VisitedSitesDTO) error {
+		payloads++
+		if statusJSON {
+			data, err := json.MarshalIndent(payload, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to marshal JSON: %w", err)
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+		printPreviewPayload(payload)
+		return nil
+	})
+	s.SetDryRunAggregatesOutput(func(payload dto.DomainAggregatesDTO) error {
+		payloads++
+		if statusJSON {
+			data, err := json.MarshalIndent(payload, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to marshal JSON: %w", err)
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+		printPreviewAggregates(payload)
+		return nil
+	})
+
+	result := s.Run(context.Background())
+	if payloads == 0 && !statusJSON {
+		fmt.Println("Nothing to send")
+	}
+	fmt.Fprintf(os.Stderr, "%d entries in %d payload(s) from %d profile(s), %d errors\n",
+		result.EntriesSent, payloads, result.ProfilesScanned, len(result.Errors))
+	for _, e := range result.Errors {
+		fmt.Fprintf(os.Stderr, "  %s\n", e)
+	}
+	return nil
+}
+
+// printPreviewPayload prints a payload in the preview command's text format
+func printPreviewPayload(payload dto.VisitedSitesDTO) {
+	fmt.Printf("Principal: %s %s\n", strings.ToLower(string(payload.Principal.Kind)), payload.Principal.Name)
+	if id := payload.Principal.Identity; id != nil {
+		var parts []string
+		for _, f := range [][2]string{{"account", id.Account}, {"upn", id.UPN}, {"domain", id.Domain}, {"sid", id.SID}, {"directory", id.Directory}} {
+			if f[1] != "" {
+				parts = append(parts, f[0]+"="+f[1])
+			}
+		}
+		if len(parts) > 0 {
+			fmt.Printf("Identity:  %s\n", strings.Join(parts, " "))
+		}
+	}
+	if d := payload.Device; d != nil {
+		fmt.Printf("Device:    %s (%s %s, id %s)\n", d.Hostname, d.OS, d.OSVersion, d.ID)
+	}
+	fmt.Printf("Source:    %s\n", payload.Source)
+	if payload.Corrupt {
+		fmt.Printf("Corrupt:   salvaged from a damaged database\n")
+	}
+	fmt.Printf("Entries:   %d\n", len(payload.VisitedSites))
+	for _, site := range payload.VisitedSites {
+		fmt.Printf("  %s  %s\n", time.UnixMilli(site.Timestamp).Format(time.DateTime), site.URL)
+	}
+	fmt.Println()
+}
+
+// printPreviewAggregates prints a domain aggregates payload in the preview
+// command's text format
+func printPreviewAggregates(payload dto.DomainAggregatesDTO) {
+	if d := payload.Device; d != nil {
+		fmt.Printf("Device:    %s (%s %s, id %s)\n", d.Hostname, d.OS, d.OSVersion, d.ID)
+	}
+	fmt.Printf("Source:    %s\n", payload.Source)
+	fmt.Printf("Domains:   %d\n", len(payload.Domains))
+	for _, d := range payload.Domains {
+		users := make([]string, len(d.Users))
+		for i, u := range d.Users {
+			users[i] = u.Name
+		}
+		fmt.Printf("  %-40s %6d visits  %s - %s  %s\n", d.Domain, d.VisitCount,
+			time.UnixMilli(d.FirstSeen).Format(time.DateTime), time.UnixMilli(d.LastSeen).Format(time.DateTime), strings.Join(users, ", "))
+	}
+	fmt.Println()
+}
+
+// browserDetection is one browser in the list-browsers output
+type browserDetection struct {
+	Browser  string            `json:"browser"`
+	Detected bool              `json:"detected"`
+	Users    []browserUserInfo `json:"users,omitempty"`
+}
+
+// browserUserInfo lists a user's profiles of a browser
+type browserUserInfo struct {
+	User     string               `json:"user"`
+	Profiles []browserProfileInfo `json:"profiles,omitempty"`
+	Error    string               `json:"error,omitempty"`
+}
+
+// browserProfileInfo describes a profile and its history database
+type browserProfileInfo struct {
+	Name         string `json:"name"`
+	Path         string `json:"path"`
+	HistoryFile  string `json:"history_file,omitempty"`
+	HistoryBytes int64  `json:"history_bytes"`
+}
+
+func runListBrowsers(cmd *cobra.Command, args []string) error {
+	users, err := platform.GetAllUsers()
+	if err != nil {
+		return fmt.Errorf("failed to enumerate users: %w", err)
+	}
+	if users = (scanner.Filter{Users: runUsers}).SelectUsers(users); len(users) == 0 {
+		return fmt.Errorf("no users to check")
+	}
+
+	var list []browserDetection
+	for _, b := range browser.All() {
+		d := browserDetection{Browser: b.Name()}
+		for _, user := range users {
+			profiles, err := b.FindProfiles(user)
+			if err != nil {
+				d.Users = append(d.Users, browserUserInfo{User: user.Username, Error: err.Error()})
+				continue
+			}
+			if len(profiles) == 0 {
+				continue
+			}
+
+			info := browserUserInfo{User: user.Username}
+			for _, profile := range profiles {
+				p := browserProfileInfo{Name: profile.Name, Path: profile.Path}
+				if hf, ok := b.(browser.HistoryFiler); ok {
+					p.HistoryFile = hf.HistoryFile(profile)
+					p.HistoryBytes = fileSize(p.HistoryFile) + fileSize(p.HistoryFile+"-wal")
 				}
-				t := time.UnixMilli(entry.Timestamp)
-				// Truncate URL for display
-				url := entry.URL
-				if len(url) > 60 {
-					url = url[:57] + "..."
+				info.Profiles = append(info.Profiles, p)
+			}
+			d.Users = append(d.Users, info)
+			d.Detected = true
+		}
+		list = append(list, d)
+	}
+
+	if statusJSON {
+		data, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal browsers: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "BROWSER\tDETECTED\tUSERS\tPROFILES\tHISTORY")
+	for _, d := range list {
+		var names []string
+		profiles, size := 0, int64(0)
+		for _, u := range d.Users {
+			if len(u.Profiles) > 0 {
+				names = append(names, u.User)
+			}
+			profiles += len(u.Profiles)
+			for _, p := range u.Profiles {
+				size += p.HistoryBytes
+			}
+		}
+		if !d.Detected {
+			fmt.Fprintf(w, "%s\tno\t-\t-\t-\n", d.Browser)
+			continue
+		}
+		fmt.Fprintf(w, "%s\tyes\t%s\t%d\t%s\n", d.Browser, strings.Join(names, ", "), profiles, formatSize(size))
+	}
+	w.Flush()
+
+	fmt.Println()
+	fmt.Fprintln(w, "USER\tBROWSER\tPROFILE\tHISTORY\tPATH")
+	for _, d := range list {
+		for _, u := range d.Users {
+			if u.Error != "" {
+				fmt.Fprintf(w, "%s\t%s\t-\t-\t%s\n", u.User, d.Browser, u.Error)
+			}
+			for _, p := range u.Profiles {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", u.User, d.Browser, p.Name, formatSize(p.HistoryBytes), orNone(p.HistoryFile))
+			}
+		}
+	}
+	w.Flush()
+
+	return nil
+}
+
+// fileSize returns the size of a file, or 0 if it cannot be read
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// formatSize formats a byte count for display
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// debugUserList returns the user named by --user, or all users
+func debugUserList(name string) ([]platform.User, error) {
+	if name == "" {
+		users, err := platform.GetAllUsers()
+		if err != nil {
+			return nil, fmt.Errorf("failed to enumerate users: %w", err)
+		}
+		return users, nil
+	}
+
+	// Try to find the user's home directory
+	allUsers, _ := platform.GetAllUsers()
+	for _, u := range allUsers {
+		if u.Username == name {
+			return []platform.User{u}, nil
+		}
+	}
+	return []platform.User{{Username: name, HomeDir: ""}}, nil
+}
+
+func runDebugAll(cmd *cobra.Command, args []string) error {
+	if debugDays <= 0 {
+		return fmt.Errorf("--days must be > 0")
+	}
+
+	users, err := debugUserList(debugUser)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Testing all browsers for %d user(s), last %d days\n\n", len(users), debugDays)
+
+	type browserTotal struct {
+		profiles, entries, errors int
+	}
+	totals := make(map[string]*browserTotal)
+	since := browser.Cursor{Timestamp: time.Now().AddDate(0, 0, -debugDays).UnixMilli()}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "USER\tBROWSER\tPROFILE\tENTRIES\tERROR")
+	for _, user := range users {
+		found := false
+		for _, b := range browser.All() {
+			total := totals[b.Name()]
+			if total == nil {
+				total = &browserTotal{}
+				totals[b.Name()] = total
+			}
+
+			profiles, err := b.FindProfiles(user)
+			if err != nil {
+				found = true
+				total.errors++
+				fmt.Fprintf(w, "%s\t%s\t-\t-\t%v\n", user.Username, b.Name(), err)
+				continue
+			}
+
+			for _, profile := range profiles {
+				found = true
+				total.profiles++
+
+				// Count without collecting the entries
+				entries := 0
+				err := b.StreamHistory(context.Background(), profile, since, func(dto.VisitedSite) error {
+					entries++
+					return nil
+				})
+				total.entries += entries
+
+				errText := ""
+				if err != nil {
+					total.errors++
+					errText = err.Error()
 				}
-				fmt.Printf("    %s | %s\n", t.Format("2006-01-02 15:04"), url)
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", user.Username, b.Name(), profile.Name, entries, errText)
 			}
 		}
+		if !found {
+			fmt.Fprintf(w, "%s\t-\t-\t-\tno profiles found\n", user.Username)
+		}
 	}
+	w.Flush()
+
+	fmt.Println()
+	fmt.Fprintln(w, "BROWSER\tPROFILES\tENTRIES\tERRORS")
+	for _, b := range browser.All() {
+		if t := totals[b.Name()]; t != nil && (t.profiles > 0 || t.errors > 0) {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", b.Name(), t.profiles, t.entries, t.errors)
+		}
+	}
+	w.Flush()
 
-	fmt.Printf("\nTotal entries found: %d\n", totalEntries)
 	return nil
 }
 
@@ -374,9 +3078,9 @@ func runDebugState(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	mgr := state.NewManager(cfg.StateFile)
-	if err := mgr.Load(); err != nil {
-		return fmt.Errorf("failed to load state: %w", err)
+	mgr, err := loadState(cfg)
+	if err != nil {
+		return err
 	}
 
 	fmt.Printf("State file: %s\n\n", mgr.GetStateFilePath())
@@ -396,6 +3100,66 @@ func runDebugState(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runStateReset(cmd *cobra.Command, args []string) error {
+	filter, err := runFilter()
+	if err != nil {
+		return err
+	}
+	if stateResetAll && !filter.IsEmpty() {
+		return fmt.Errorf("--all cannot be combined with --user, --browser or --profile")
+	}
+	if !stateResetAll && filter.IsEmpty() {
+		return fmt.Errorf("use --user, --browser or --profile to choose the positions to reset, or --all")
+	}
+
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if path := controlSocket(cfg); path != "" && !dryRun {
+		if _, err := control.Call(path, control.CommandStatus, 5*time.Second); err == nil {
+			return fmt.Errorf("a daemon is running on %s; stop it before resetting scan positions", path)
+		}
+	}
+
+	mgr, err := loadState(cfg)
+	if err != nil {
+		return err
+	}
+
+	match := filter.MatchStateKey
+	if stateResetAll {
+		match = func(string) bool { return true }
+	}
+	if dryRun {
+		var keys []string
+		for key := range mgr.GetAllEntries() {
+			if match(key) {
+				keys = append(keys, key)
+			}
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			fmt.Printf("Would reset %s\n", key)
+		}
+		return nil
+	}
+
+	removed := mgr.Reset(match)
+	if len(removed) == 0 {
+		fmt.Println("No matching scan positions")
+		return nil
+	}
+	if err := mgr.Save(); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	for _, key := range removed {
+		fmt.Printf("Reset %s\n", key)
+	}
+	fmt.Printf("%d scan position(s) reset in %s; the next run rescans them from initial_days\n", len(removed), mgr.GetStateFilePath())
+	return nil
+}
+
 func runDebugSend(cmd *cobra.Command, args []string) error {
 	cfg, err := loadConfig(cmd)
 	if err != nil {
@@ -406,19 +3170,29 @@ func runDebugSend(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("invalid config: %w", err)
 	}
 
-	client := sender.NewClient(cfg.ServerURL, cfg.APIKey, cfg.Timeout, cfg.ChunkSizeKB, cfg.Compress)
-
-	// Send test data
-	testPayload := dto.VisitedSitesDTO{
-		Principal: dto.NewUserPrincipal("test-user"),
-		Source:    cfg.Source,
-		VisitedSites: []dto.VisitedSite{
-			{URL: "https://example.com/test1", Timestamp: time.Now().Add(-1 * time.Hour).UnixMilli()},
-			{URL: "https://example.com/test2", Timestamp: time.Now().Add(-30 * time.Minute).UnixMilli()},
-			{URL: "https://example.com/test3", Timestamp: time.Now().UnixMilli()},
-		},
+	var testPayload dto.VisitedSitesDTO
+	if debugPayloadFile != "" {
+		if cmd.Flags().Changed("entries") || cmd.Flags().Changed("url-size") {
+			return fmt.Errorf("--payload-file cannot be combined with --entries or --url-size")
+		}
+		if testPayload, err = loadPayloadFile(debugPayloadFile); err != nil {
+			return err
+		}
+		if testPayload.Source == "" {
+			testPayload.Source = cfg.Source
+		}
+	} else {
+		if debugEntries <= 0 {
+			return fmt.Errorf("--entries must be > 0")
+		}
+		if debugURLSize < 0 {
+			return fmt.Errorf("--url-size must be >= 0")
+		}
+		testPayload = syntheticPayload(debugEntries, debugURLSize, cfg.Source)
 	}
 
+	client := scanner.NewClient(cfg)
+
 	fmt.Printf("Sending test data to %s...\n", cfg.ServerURL)
 	fmt.Printf("Payload: %d entries\n", len(testPayload.VisitedSites))
 
@@ -431,6 +3205,7 @@ func runDebugSend(cmd *cobra.Command, args []string) error {
 	fmt.Printf("  Chunks sent: %d\n", result.ChunksSent)
 	fmt.Printf("  Total sent: %d\n", result.TotalSent)
 	fmt.Printf("  Failed: %d\n", result.FailedCount)
+	fmt.Printf("  Bytes: %d before compression, %d sent\n", result.BytesOriginal, result.BytesSent)
 	fmt.Printf("  Max timestamp: %d (%s)\n", maxTs, time.UnixMilli(maxTs).Format("2006-01-02 15:04:05"))
 
 	if result.LastError != nil {
@@ -439,3 +3214,78 @@ func runDebugSend(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func runDebugGenerate(cmd *cobra.Command, args []string) error {
+	layout := platform.OS(strings.ToLower(generateOS))
+	switch layout {
+	case platform.Linux, platform.Darwin, platform.Windows:
+	default:
+		return fmt.Errorf("--os must be linux, darwin or windows")
+	}
+	root, err := filepath.Abs(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", args[0], err)
+	}
+
+	dbs, err := fixture.Generate(fixture.Options{
+		Root:     root,
+		OS:       layout,
+		Users:    generateUsers,
+		Browsers: generateBrowsers,
+		Profiles: generateProfiles,
+		Visits:   generateVisits,
+		Days:     generateDays,
+		Seed:     generateSeed,
+	})
+	for _, d := range dbs {
+		fmt.Printf("%s %s/%s: %d visits in %s\n", d.User, d.Browser, d.Profile, d.Visits, d.Path)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nScan them with: hist_scanner run --root %s --dry-run\n", root)
+	return nil
+}
+
+// loadPayloadFile reads a payload in the format the scanner sends
+func loadPayloadFile(path string) (dto.VisitedSitesDTO, error) {
+	var payload dto.VisitedSitesDTO
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return payload, fmt.Errorf("failed to read payload file: %w", err)
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return payload, fmt.Errorf("failed to parse payload file: %w", err)
+	}
+	if payload.Principal.Name == "" {
+		payload.Principal = dto.NewUserPrincipal("test-user")
+	}
+	return payload, nil
+}
+
+// syntheticPayload builds n test entries spread over the last day. With
+// urlSize set, URLs are padded to that length with random path segments,
+// which compress about as well as real URLs.
+func syntheticPayload(n, urlSize int, source string) dto.VisitedSitesDTO {
+	now := time.Now()
+	step := 24 * time.Hour / time.Duration(n)
+	sites := make([]dto.VisitedSite, n)
+	for i := range sites {
+		url := fmt.Sprintf("https://site%d.example.com/test%d", i%50, i+1)
+		for len(url) < urlSize {
+			url += "/" + strconv.FormatUint(rand.Uint64(), 36)
+		}
+		if urlSize > 0 {
+			url = url[:urlSize]
+		}
+		sites[i] = dto.VisitedSite{
+			URL:       url,
+			Timestamp: now.Add(-step * time.Duration(n-i)).UnixMilli(),
+		}
+	}
+	return dto.VisitedSitesDTO{
+		Principal:    dto.NewUserPrincipal("test-user"),
+		Source:       source,
+		VisitedSites: sites,
+	}
+}
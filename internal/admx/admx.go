@@ -0,0 +1,166 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package admx generates Group Policy administrative templates (ADMX/ADML)
+// for the config keys the scanner reads from the policy registry key.
+package admx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"hist_scanner/internal/config"
+)
+
+// File names of the generated templates
+const (
+	ADMXFile = "hist_scanner.admx"
+	ADMLFile = "hist_scanner.adml"
+	language = "en-US"
+)
+
+const admxTemplate = `<?xml version="1.0" encoding="utf-8"?>
+<policyDefinitions xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" revision="1.0" schemaVersion="1.0" xmlns="http://schemas.microsoft.com/GroupPolicy/2006/07/PolicyDefinitions">
+  <policyNamespaces>
+    <target prefix="histscanner" namespace="Binadox.Policies.HistScanner" />
+    <using prefix="windows" namespace="Microsoft.Policies.Windows" />
+  </policyNamespaces>
+  <resources minRequiredRevision="1.0" />
+  <categories>
+    <category name="Binadox" displayName="$(string.Binadox)" />
+    <category name="HistScanner" displayName="$(string.HistScanner)">
+      <parentCategory ref="Binadox" />
+    </category>
+  </categories>
+  <policies>
+{{- range .Keys}}
+{{- if eq .Kind 2}}
+    <policy name="{{.Name}}" class="Machine" displayName="$(string.{{.Name}})" explainText="$(string.{{.Name}}_Help)" key="{{xml $.RegistryKey}}" valueName="{{.Name}}">
+      <parentCategory ref="HistScanner" />
+      <supportedOn ref="windows:SUPPORTED_Windows7" />
+      <enabledValue>
+        <decimal value="1" />
+      </enabledValue>
+      <disabledValue>
+        <decimal value="0" />
+      </disabledValue>
+    </policy>
+{{- else}}
+    <policy name="{{.Name}}" class="Machine" displayName="$(string.{{.Name}})" explainText="$(string.{{.Name}}_Help)" presentation="$(presentation.{{.Name}})" key="{{xml $.RegistryKey}}">
+      <parentCategory ref="HistScanner" />
+      <supportedOn ref="windows:SUPPORTED_Windows7" />
+      <elements>
+{{- if eq .Kind 1}}
+        <decimal id="{{.Name}}" valueName="{{.Name}}" minValue="0" maxValue="4294967295" />
+{{- else}}
+        <text id="{{.Name}}" valueName="{{.Name}}" />
+{{- end}}
+      </elements>
+    </policy>
+{{- end}}
+{{- end}}
+  </policies>
+</policyDefinitions>
+`
+
+const admlTemplate = `<?xml version="1.0" encoding="utf-8"?>
+<policyDefinitionResources xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" revision="1.0" schemaVersion="1.0" xmlns="http://schemas.microsoft.com/GroupPolicy/2006/07/PolicyDefinitions">
+  <displayName>Browser History Scanner</displayName>
+  <description>Settings for the Binadox browser history scanner (hist_scanner).</description>
+  <resources>
+    <stringTable>
+      <string id="Binadox">Binadox</string>
+      <string id="HistScanner">Browser History Scanner</string>
+{{- range .Keys}}
+      <string id="{{.Name}}">{{xml .DisplayName}}</string>
+      <string id="{{.Name}}_Help">{{xml .Description}}
+
+Config key: {{.Name}}. Overrides the config file and environment.</string>
+{{- end}}
+    </stringTable>
+    <presentationTable>
+{{- range .Keys}}
+{{- if eq .Kind 1}}
+      <presentation id="{{.Name}}">
+        <decimalTextBox refId="{{.Name}}">{{xml .DisplayName}}</decimalTextBox>
+      </presentation>
+{{- else if eq .Kind 0}}
+      <presentation id="{{.Name}}">
+        <textBox refId="{{.Name}}">
+          <label>{{xml .DisplayName}}</label>
+        </textBox>
+      </presentation>
+{{- end}}
+{{- end}}
+    </presentationTable>
+  </resources>
+</policyDefinitionResources>
+`
+
+// Generate renders the ADMX and en-US ADML templates
+func Generate() (admx, adml string, err error) {
+	data := struct {
+		RegistryKey string
+		Keys        []config.PolicyKey
+	}{
+		RegistryKey: config.PolicyRegistryKey,
+		Keys:        config.PolicyKeys,
+	}
+
+	if admx, err = render("admx", admxTemplate, data); err != nil {
+		return "", "", err
+	}
+	if adml, err = render("adml", admlTemplate, data); err != nil {
+		return "", "", err
+	}
+	return admx, adml, nil
+}
+
+// Write generates the templates into dir using the PolicyDefinitions layout
+// (hist_scanner.admx and en-US/hist_scanner.adml) and returns the written paths
+func Write(dir string) ([]string, error) {
+	admx, adml, err := Generate()
+	if err != nil {
+		return nil, err
+	}
+
+	admxPath := filepath.Join(dir, ADMXFile)
+	admlPath := filepath.Join(dir, language, ADMLFile)
+	if err := os.MkdirAll(filepath.Dir(admlPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	if err := os.WriteFile(admxPath, []byte(admx), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", ADMXFile, err)
+	}
+	if err := os.WriteFile(admlPath, []byte(adml), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", ADMLFile, err)
+	}
+
+	return []string{admxPath, admlPath}, nil
+}
+
+// render executes a template with XML escaping available as "xml"
+func render(name, text string, data interface{}) (string, error) {
+	tmpl, err := template.New(name).Funcs(template.FuncMap{"xml": xmlEscape}).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s template: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// xmlEscape escapes a value for use in XML text and attributes
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
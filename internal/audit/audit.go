@@ -0,0 +1,203 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package audit keeps an append-only, hash-chained log of the data sent to
+// the server. Each JSON Lines record carries the SHA-256 hash of the previous
+// record, so editing, removing or reordering records breaks the chain.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"hist_scanner/internal/dto"
+)
+
+// genesisHash is the previous hash of the first record
+const genesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// Record describes one chunk sent to the server
+type Record struct {
+	Seq       int64     `json:"seq"`
+	Time      time.Time `json:"time"`
+	Principal string    `json:"principal"`
+	Entries   int       `json:"entries"`
+	URLDigest string    `json:"url_digest"` // SHA-256 of the chunk's "timestamp url" lines
+	Bytes     int64     `json:"bytes"`      // Bytes sent, after compression
+	Status    int       `json:"status"`     // HTTP status, 0 if no response
+	Error     string    `json:"error,omitempty"`
+	Prev      string    `json:"prev"` // Hash of the previous record
+	Hash      string    `json:"hash"` // Hash of this record with Hash empty
+}
+
+// Log appends records to an audit log file
+type Log struct {
+	path string
+	mu   sync.Mutex
+	seq  int64
+	prev string
+}
+
+// Open opens the audit log at path, creating it (and its directory) if
+// needed, and continues the chain after its last record
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	l := &Log{path: path, prev: genesisHash}
+	last, err := lastRecord(path)
+	if err != nil {
+		return nil, err
+	}
+	if last != nil {
+		l.seq, l.prev = last.Seq, last.Hash
+	}
+	return l, nil
+}
+
+// Path returns the audit log file path
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Append chains r to the log and writes it. Seq, Prev and Hash are set by Append.
+func (l *Log) Append(r Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r.Seq = l.seq + 1
+	r.Prev = l.prev
+	hash, err := recordHash(r)
+	if err != nil {
+		return err
+	}
+	r.Hash = hash
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+
+	l.seq, l.prev = r.Seq, r.Hash
+	return nil
+}
+
+// URLDigest returns the SHA-256 of the "timestamp url" lines of sites
+func URLDigest(sites []dto.VisitedSite) string {
+	h := sha256.New()
+	for _, site := range sites {
+		fmt.Fprintf(h, "%d %s\n", site.Timestamp, site.URL)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Verify checks the chain of the audit log at path. It returns the number of
+// valid records and, if the chain is broken, an error naming the first bad line.
+func Verify(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	prev, seq, n := genesisHash, int64(0), 0
+	err = eachLine(f, func(line int, data []byte) error {
+		var r Record
+		if err := json.Unmarshal(data, &r); err != nil {
+			return fmt.Errorf("line %d: not a valid record: %w", line, err)
+		}
+		if r.Prev != prev || r.Seq != seq+1 {
+			return fmt.Errorf("line %d: chain broken (record removed, inserted or reordered)", line)
+		}
+		hash, err := recordHash(r)
+		if err != nil {
+			return err
+		}
+		if hash != r.Hash {
+			return fmt.Errorf("line %d: record was modified", line)
+		}
+		prev, seq = r.Hash, r.Seq
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// recordHash returns the hash of r computed with its Hash field empty
+func recordHash(r Record) (string, error) {
+	r.Hash = ""
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// lastRecord returns the last record of the log, or nil if it is empty or missing
+func lastRecord(path string) (*Record, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	var last []byte
+	if err := eachLine(f, func(_ int, data []byte) error {
+		last = append(last[:0], data...)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if last == nil {
+		return nil, nil
+	}
+
+	var r Record
+	if err := json.Unmarshal(last, &r); err != nil {
+		return nil, fmt.Errorf("failed to read last audit record: %w", err)
+	}
+	return &r, nil
+}
+
+// eachLine calls fn with the number and content of each non-empty line
+func eachLine(r io.Reader, fn func(line int, data []byte) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		data := bytes.TrimSpace(sc.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		if err := fn(line, data); err != nil {
+			return err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return nil
+}
@@ -4,6 +4,12 @@
 package browser
 
 import (
+	"context"
+	"database/sql"
+	"slices"
+	"sync"
+
+	"hist_scanner/internal/db"
 	"hist_scanner/internal/dto"
 	"hist_scanner/internal/platform"
 )
@@ -14,6 +20,23 @@ type Profile struct {
 	Path string // Full path to profile directory
 }
 
+// Cursor marks the position of the last processed history entry of a profile
+type Cursor struct {
+	Timestamp int64 // Unix milliseconds, 0 means no lower time bound
+	RowID     int64 // Row id of the last processed entry, 0 if unknown
+}
+
+// micros returns the exclusive lower time bound of the cursor in
+// microseconds since the Unix epoch. The timestamp drops the microseconds of
+// the newest visit sent, which would match again on every scan; with a row
+// id, the rest of that millisecond is left to the row id.
+func (c Cursor) micros() int64 {
+	if c.RowID > 0 && c.Timestamp > 0 {
+		return (c.Timestamp+1)*1000 - 1
+	}
+	return c.Timestamp * 1000
+}
+
 // Browser defines the interface for all browser implementations
 type Browser interface {
 	// Name returns the browser name (e.g., "chrome", "firefox")
@@ -22,15 +45,144 @@ type Browser interface {
 	// FindProfiles returns all profiles for a given user
 	FindProfiles(user platform.User) ([]Profile, error)
 
-	// GetHistory extracts history entries from a profile since the given timestamp
-	// timestamp is in Unix milliseconds, 0 means get all history
-	GetHistory(profile Profile, sinceTimestamp int64) ([]dto.VisitedSite, error)
+	// GetHistory extracts history entries from a profile newer than the given cursor.
+	// An entry is newer if its timestamp is after since.Timestamp or, when since.RowID
+	// is set, its row id is above since.RowID (catches same-millisecond visits and
+	// visits recorded while the clock was behind). A zero cursor returns all history.
+	GetHistory(profile Profile, since Cursor) ([]dto.VisitedSite, error)
+
+	// StreamHistory calls fn for each entry GetHistory would return, in row
+	// id order, without collecting them. An error from fn stops the stream
+	// and is returned; so does ctx being done, also during a page query.
+	StreamHistory(ctx context.Context, profile Profile, since Cursor, fn VisitFunc) error
+}
+
+// VisitFunc receives one history entry from StreamHistory
+type VisitFunc func(site dto.VisitedSite) error
+
+// historyPageSize is the number of rows read per history query. Rows are
+// paged by row id, which the scan position tracks along with the newest
+// timestamp, so a scan interrupted between pages resumes without gaps.
+const historyPageSize = 5000
+
+// collectHistory gathers the entries of a stream into a slice
+func collectHistory(stream func(fn VisitFunc) error) ([]dto.VisitedSite, error) {
+	var sites []dto.VisitedSite
+	err := stream(func(site dto.VisitedSite) error {
+		sites = append(sites, site)
+		return nil
+	})
+	return sites, err
+}
+
+// Fingerprint identifies a history database so that resets can be detected between scans
+type Fingerprint struct {
+	ID       string // Profile/database creation marker; changes when the profile is recreated
+	MaxRowID int64  // Highest history row id; drops when history is cleared
+	Rows     int64  // History rows; drops when history is deleted
+}
+
+// Fingerprinter is implemented by browsers that can fingerprint their history database
+type Fingerprinter interface {
+	// Fingerprint returns the current fingerprint of a profile's history database
+	Fingerprint(profile Profile) (Fingerprint, error)
+}
+
+// HistoryFiler is implemented by browsers that keep history in a single database file
+type HistoryFiler interface {
+	// HistoryFile returns the path of a profile's history database
+	HistoryFile(profile Profile) string
+}
+
+// DataDirer is implemented by browsers that can report where a user's data lives
+type DataDirer interface {
+	// DataDir returns the browser's data directory for a user, or "" if the
+	// browser is not available for the user's platform
+	DataDir(user platform.User) string
+}
+
+// ProfileInfo describes a profile as the user sees it
+type ProfileInfo struct {
+	Version     string // Browser version that last opened the profile, "" if unknown
+	DisplayName string // Name shown in the browser, "" if it has none
+}
+
+// Describer is implemented by browsers that can tell their version and the
+// display names of their profiles
+type Describer interface {
+	// Describe reads what the profile records about itself
+	Describe(profile Profile) ProfileInfo
+}
+
+// Salvage describes history recovered from a damaged database
+type Salvage struct {
+	Problems []string // First integrity_check messages
+	Skipped  int      // Rows that could not be read
 }
 
-// All returns all supported browsers
+// Salvager is implemented by browsers that can recover history from a
+// damaged database
+type Salvager interface {
+	// SalvageHistory streams the readable entries GetHistory would return
+	// from a damaged database, in row id order
+	SalvageHistory(profile Profile, since Cursor, fn VisitFunc) (Salvage, error)
+}
+
+// maxIntegrityProblems is the number of integrity_check messages reported
+const maxIntegrityProblems = 5
+
+// salvageHistory recovers the rows of a damaged history table matching
+// where, converts them with scan and streams them in rowid order
+func salvageHistory(dbPath, table, join, columns, where string, args []interface{}, scan func(rows *sql.Rows) (dto.VisitedSite, bool), fn VisitFunc) (Salvage, error) {
+	database, err := db.Open(dbPath)
+	if err != nil {
+		return Salvage{}, err
+	}
+	defer database.Close()
+
+	var info Salvage
+	if info.Problems, err = database.IntegrityCheck(maxIntegrityProblems); err != nil {
+		info.Problems = []string{err.Error()}
+	}
+
+	info.Skipped, err = database.SalvageRows(table, join, columns, where, args, func(rows *sql.Rows) error {
+		if site, ok := scan(rows); ok {
+			return fn(site)
+		}
+		return nil
+	})
+	return info, err
+}
+
+var (
+	registeredMu sync.Mutex
+	registered   []Browser
+)
+
+// Register adds a browser to All, in place of a supported browser of the
+// same name. It is meant for fakes in integration tests; Unregister removes
+// it again.
+func Register(b Browser) {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+
+	registered = slices.DeleteFunc(registered, func(r Browser) bool { return r.Name() == b.Name() })
+	registered = append(registered, b)
+}
+
+// Unregister removes a browser added with Register
+func Unregister(name string) {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+
+	registered = slices.DeleteFunc(registered, func(r Browser) bool { return r.Name() == name })
+}
+
+// All returns all supported browsers and the registered ones
 func All() []Browser {
-	return []Browser{
+	all := []Browser{
 		NewChrome(),
+		NewChromium(),
 		NewEdge(),
 		NewOpera(),
 		NewOperaGX(),
@@ -38,6 +190,17 @@ func All() []Browser {
 		NewFirefox(),
 		NewSafari(),
 	}
+
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	for _, r := range registered {
+		if i := slices.IndexFunc(all, func(b Browser) bool { return b.Name() == r.Name() }); i >= 0 {
+			all[i] = r
+		} else {
+			all = append(all, r)
+		}
+	}
+	return all
 }
 
 // ByName returns a browser by name, or nil if not found
@@ -50,6 +213,25 @@ func ByName(name string) Browser {
 	return nil
 }
 
+// processNames are the executable names of the supported browsers' main
+// processes on Linux, macOS and Windows, as platform.Processes lists them
+var processNames = map[string][]string{
+	"chrome":   {"chrome", "google chrome"},
+	"chromium": {"chromium", "chromium-browser"},
+	"edge":     {"msedge", "microsoft edge"},
+	"opera":    {"opera"},
+	"opera-gx": {"opera", "opera gx"},
+	"vivaldi":  {"vivaldi", "vivaldi-bin"},
+	"firefox":  {"firefox", "firefox-bin", "firefox-esr"},
+	"safari":   {"safari"},
+}
+
+// ProcessNames returns the executable names of a browser's processes, or
+// nil if they are not known (registered browsers)
+func ProcessNames(name string) []string {
+	return processNames[name]
+}
+
 // SupportedBrowserNames returns names of all supported browsers
 func SupportedBrowserNames() []string {
 	browsers := All()
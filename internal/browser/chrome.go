@@ -12,3 +12,14 @@ func NewChrome() *ChromiumBrowser {
 		WindowsAppData: false, // Uses LOCALAPPDATA
 	}, true) // Has profiles
 }
+
+// NewChromium creates a scanner for the open-source Chromium build
+// (distribution and FreeBSD ports packages)
+func NewChromium() *ChromiumBrowser {
+	return NewChromiumBrowser("chromium", ChromiumPaths{
+		Linux:          ".config/chromium",
+		Darwin:         "Library/Application Support/Chromium",
+		Windows:        "Chromium\\User Data",
+		WindowsAppData: false, // Uses LOCALAPPDATA
+	}, true) // Has profiles
+}
@@ -4,6 +4,9 @@
 package browser
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"strings"
@@ -45,6 +48,12 @@ func (c *ChromiumBrowser) Name() string {
 	return c.name
 }
 
+// HasProfiles reports whether profiles are Default and "Profile N"
+// directories under the data directory, rather than the directory itself
+func (c *ChromiumBrowser) HasProfiles() bool {
+	return c.hasProfiles
+}
+
 // FindProfiles returns all profiles for a given user
 func (c *ChromiumBrowser) FindProfiles(user platform.User) ([]Profile, error) {
 	baseDir := c.getBaseDir(user)
@@ -100,64 +109,153 @@ func (c *ChromiumBrowser) FindProfiles(user platform.User) ([]Profile, error) {
 	return profiles, nil
 }
 
-// GetHistory extracts history entries from a profile since the given timestamp
-func (c *ChromiumBrowser) GetHistory(profile Profile, sinceTimestamp int64) ([]dto.VisitedSite, error) {
+// GetHistory extracts history entries from a profile newer than the given cursor
+func (c *ChromiumBrowser) GetHistory(profile Profile, since Cursor) ([]dto.VisitedSite, error) {
+	return collectHistory(func(fn VisitFunc) error {
+		return c.StreamHistory(context.Background(), profile, since, fn)
+	})
+}
+
+// StreamHistory streams history entries from a profile newer than the given cursor
+func (c *ChromiumBrowser) StreamHistory(ctx context.Context, profile Profile, since Cursor, fn VisitFunc) error {
 	historyPath := filepath.Join(profile.Path, "History")
 
 	database, err := db.Open(historyPath)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer database.Close()
 
+	// One entry per visit: a revisit of a known URL adds a visits row, while
+	// its urls row keeps its id
+	query := `
+		SELECT visits.id, urls.url, visits.visit_time
+		FROM visits
+		JOIN urls ON urls.id = visits.url
+		WHERE (` + chromiumWhere + `) AND visits.id > ?
+		ORDER BY visits.id ASC
+		LIMIT ?
+	`
+
+	return database.ForEachPageContext(ctx, query, chromiumArgs(since), historyPageSize, func(rows *sql.Rows) error {
+		site, ok := scanChromiumRow(rows)
+		if !ok {
+			return nil // Skip unreadable rows
+		}
+		return fn(site)
+	})
+}
+
+// SalvageHistory recovers the readable entries of a damaged History database
+func (c *ChromiumBrowser) SalvageHistory(profile Profile, since Cursor, fn VisitFunc) (Salvage, error) {
+	return salvageHistory(filepath.Join(profile.Path, "History"), "visits", "JOIN urls ON urls.id = visits.url",
+		"visits.id, urls.url, visits.visit_time", chromiumWhere, chromiumArgs(since), scanChromiumRow, fn)
+}
+
+// chromiumWhere selects the visits newer than a cursor
+const chromiumWhere = `visits.visit_time > ? OR (? > 0 AND visits.id > ?)`
+
+// chromiumArgs returns the chromiumWhere arguments for a cursor
+func chromiumArgs(since Cursor) []interface{} {
 	// Convert Unix milliseconds to Chromium timestamp (microseconds since 1601-01-01)
 	// Chromium epoch: 1601-01-01 00:00:00 UTC
 	// Unix epoch: 1970-01-01 00:00:00 UTC
 	// Difference: 11644473600 seconds
 	var chromiumTimestamp int64
-	if sinceTimestamp > 0 {
-		// Convert ms to microseconds, then add epoch difference
-		chromiumTimestamp = (sinceTimestamp * 1000) + (11644473600 * 1000000)
+	if since.Timestamp > 0 {
+		// Convert to microseconds, then add epoch difference
+		chromiumTimestamp = since.micros() + (11644473600 * 1000000)
 	}
+	return []interface{}{chromiumTimestamp, since.RowID, since.RowID}
+}
 
-	query := `
-		SELECT url, last_visit_time
-		FROM urls
-		WHERE last_visit_time > ?
-		ORDER BY last_visit_time ASC
-	`
+// scanChromiumRow converts a (visit id, url, visit_time) row
+func scanChromiumRow(rows *sql.Rows) (dto.VisitedSite, bool) {
+	var id int64
+	var url string
+	var visitTime int64
+
+	if err := rows.Scan(&id, &url, &visitTime); err != nil {
+		return dto.VisitedSite{}, false
+	}
 
-	rows, err := database.Query(query, chromiumTimestamp)
+	// Convert Chromium timestamp back to Unix milliseconds
+	unixMs := (visitTime - (11644473600 * 1000000)) / 1000
+
+	return dto.VisitedSite{
+		URL:       url,
+		Timestamp: unixMs,
+		RowID:     id,
+	}, true
+}
+
+// Fingerprint returns the profile creation time from Preferences and the highest visits id and visit count
+func (c *ChromiumBrowser) Fingerprint(profile Profile) (Fingerprint, error) {
+	database, err := db.Open(filepath.Join(profile.Path, "History"))
 	if err != nil {
-		return nil, err
+		return Fingerprint{}, err
 	}
-	defer rows.Close()
+	defer database.Close()
 
-	var sites []dto.VisitedSite
-	for rows.Next() {
-		var url string
-		var lastVisitTime int64
+	var fp Fingerprint
+	if err := database.QueryRow("SELECT COALESCE(MAX(id), 0), COUNT(*) FROM visits", nil, &fp.MaxRowID, &fp.Rows); err != nil {
+		return Fingerprint{}, err
+	}
 
-		if err := rows.Scan(&url, &lastVisitTime); err != nil {
-			continue
+	// Preferences is rewritten when a profile is recreated, with a new creation_time
+	if data, err := os.ReadFile(filepath.Join(profile.Path, "Preferences")); err == nil {
+		var prefs struct {
+			Profile struct {
+				CreationTime string `json:"creation_time"`
+			} `json:"profile"`
 		}
+		if json.Unmarshal(data, &prefs) == nil {
+			fp.ID = prefs.Profile.CreationTime
+		}
+	}
 
-		// Convert Chromium timestamp back to Unix milliseconds
-		unixMs := (lastVisitTime - (11644473600 * 1000000)) / 1000
+	return fp, nil
+}
 
-		sites = append(sites, dto.VisitedSite{
-			URL:       url,
-			Timestamp: unixMs,
-		})
+// Describe returns the version in the data directory's Last Version file and
+// the profile name from Preferences
+func (c *ChromiumBrowser) Describe(profile Profile) ProfileInfo {
+	var info ProfileInfo
+	dataDir := profile.Path
+	if c.hasProfiles {
+		dataDir = filepath.Dir(profile.Path)
 	}
+	if data, err := os.ReadFile(filepath.Join(dataDir, "Last Version")); err == nil {
+		info.Version = strings.TrimSpace(string(data))
+	}
+	if data, err := os.ReadFile(filepath.Join(profile.Path, "Preferences")); err == nil {
+		var prefs struct {
+			Profile struct {
+				Name string `json:"name"`
+			} `json:"profile"`
+		}
+		if json.Unmarshal(data, &prefs) == nil {
+			info.DisplayName = prefs.Profile.Name
+		}
+	}
+	return info
+}
+
+// DataDir returns the browser's user data directory
+func (c *ChromiumBrowser) DataDir(user platform.User) string {
+	return c.getBaseDir(user)
+}
 
-	return sites, rows.Err()
+// HistoryFile returns the path of the profile's History database
+func (c *ChromiumBrowser) HistoryFile(profile Profile) string {
+	return filepath.Join(profile.Path, "History")
 }
 
 // getBaseDir returns the base directory for browser data
 func (c *ChromiumBrowser) getBaseDir(user platform.User) string {
-	switch platform.CurrentOS() {
-	case platform.Linux:
+	switch user.HomeLayout() {
+	case platform.Linux, platform.FreeBSD:
+		// FreeBSD ports (www/chromium) use the Linux profile locations
 		if c.paths.Linux == "" {
 			return ""
 		}
@@ -179,22 +277,23 @@ func (c *ChromiumBrowser) getBaseDir(user platform.User) string {
 		} else {
 			baseEnv = "LOCALAPPDATA"
 		}
-		// For non-current users, construct the path manually
+		// Windows paths are written with backslashes; convert them for
+		// profiles scanned from inside WSL
+		windowsPath := filepath.FromSlash(strings.ReplaceAll(c.paths.Windows, `\`, "/"))
+
+		// Use the user's (possibly redirected) AppData folders
 		if user.HomeDir != "" {
-			var appDataPath string
 			if c.paths.WindowsAppData {
-				appDataPath = filepath.Join(user.HomeDir, "AppData", "Roaming")
-			} else {
-				appDataPath = filepath.Join(user.HomeDir, "AppData", "Local")
+				return filepath.Join(user.AppDataPath(), windowsPath)
 			}
-			return filepath.Join(appDataPath, c.paths.Windows)
+			return filepath.Join(user.LocalAppDataPath(), windowsPath)
 		}
 		// Fallback for current user
 		base := os.Getenv(baseEnv)
 		if base == "" {
 			return ""
 		}
-		return filepath.Join(base, c.paths.Windows)
+		return filepath.Join(base, windowsPath)
 
 	default:
 		return ""
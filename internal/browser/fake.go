@@ -0,0 +1,134 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package browser
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"hist_scanner/internal/dto"
+	"hist_scanner/internal/platform"
+)
+
+// fakePathPrefix starts the Path of fake profiles, which have no directory
+const fakePathPrefix = "fake:"
+
+// Fake is an in-memory browser serving synthetic profiles and history, for
+// integration tests of the pipeline behind the scanner. Register makes scans
+// read it like an installed browser.
+type Fake struct {
+	name string
+
+	mu       sync.Mutex
+	profiles map[string]*fakeProfile // By Path
+	nextRow  int64
+}
+
+// fakeProfile is a profile of a Fake and the visits recorded in it
+type fakeProfile struct {
+	user    string // Lower-case user name, "" for every user
+	profile Profile
+	visits  []dto.VisitedSite // In row id order
+}
+
+// NewFake returns a fake browser without profiles
+func NewFake(name string) *Fake {
+	return &Fake{name: name, profiles: make(map[string]*fakeProfile)}
+}
+
+// Name returns the browser name
+func (f *Fake) Name() string {
+	return f.name
+}
+
+// AddVisits records visits in a user's profile, creating the profile. The
+// profiles of user "" belong to every user. Visits get increasing row ids
+// in the order they are added, like rows appended to a history database.
+func (f *Fake) AddVisits(user, profile string, visits ...dto.VisitedSite) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	user = strings.ToLower(user)
+	path := fmt.Sprintf("%s%s/%s/%s", fakePathPrefix, f.name, user, profile)
+	p, ok := f.profiles[path]
+	if !ok {
+		p = &fakeProfile{user: user, profile: Profile{Name: profile, Path: path}}
+		f.profiles[path] = p
+	}
+	for _, v := range visits {
+		f.nextRow++
+		v.RowID = f.nextRow
+		p.visits = append(p.visits, v)
+	}
+}
+
+// FindProfiles returns the user's profiles and those of every user
+func (f *Fake) FindProfiles(user platform.User) ([]Profile, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var profiles []Profile
+	for _, p := range f.profiles {
+		if p.user == "" || p.user == strings.ToLower(user.Username) {
+			profiles = append(profiles, p.profile)
+		}
+	}
+	slices.SortFunc(profiles, func(a, b Profile) int { return strings.Compare(a.Path, b.Path) })
+	return profiles, nil
+}
+
+// GetHistory returns the visits of a profile newer than the given cursor
+func (f *Fake) GetHistory(profile Profile, since Cursor) ([]dto.VisitedSite, error) {
+	return collectHistory(func(fn VisitFunc) error {
+		return f.StreamHistory(context.Background(), profile, since, fn)
+	})
+}
+
+// StreamHistory streams the visits of a profile newer than the given cursor
+func (f *Fake) StreamHistory(ctx context.Context, profile Profile, since Cursor, fn VisitFunc) error {
+	visits, err := f.visits(profile)
+	if err != nil {
+		return err
+	}
+	for _, v := range visits {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if v.Timestamp > since.Timestamp || (since.RowID > 0 && v.RowID > since.RowID) {
+			if err := fn(v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Fingerprint returns the highest row id and visit count of a profile
+func (f *Fake) Fingerprint(profile Profile) (Fingerprint, error) {
+	visits, err := f.visits(profile)
+	if err != nil {
+		return Fingerprint{}, err
+	}
+	fp := Fingerprint{ID: profile.Path, Rows: int64(len(visits))}
+	if len(visits) > 0 {
+		fp.MaxRowID = visits[len(visits)-1].RowID
+	}
+	return fp, nil
+}
+
+// visits returns a copy of the visits of a profile, so that visits can be
+// added while it is read
+func (f *Fake) visits(profile Profile) ([]dto.VisitedSite, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.profiles[profile.Path]
+	if !ok {
+		return nil, fmt.Errorf("no %s profile at %s", f.name, profile.Path)
+	}
+	return slices.Clone(p.visits), nil
+}
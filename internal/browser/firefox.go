@@ -5,6 +5,9 @@ package browser
 
 import (
 	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"strings"
@@ -186,69 +189,148 @@ func (f *FirefoxBrowser) scanForProfiles(profilesDir string) ([]Profile, error)
 	return profiles, nil
 }
 
-// GetHistory extracts history entries from a Firefox profile since the given timestamp
-func (f *FirefoxBrowser) GetHistory(profile Profile, sinceTimestamp int64) ([]dto.VisitedSite, error) {
+// GetHistory extracts history entries from a Firefox profile newer than the given cursor
+func (f *FirefoxBrowser) GetHistory(profile Profile, since Cursor) ([]dto.VisitedSite, error) {
+	return collectHistory(func(fn VisitFunc) error {
+		return f.StreamHistory(context.Background(), profile, since, fn)
+	})
+}
+
+// StreamHistory streams history entries from a Firefox profile newer than the given cursor
+func (f *FirefoxBrowser) StreamHistory(ctx context.Context, profile Profile, since Cursor, fn VisitFunc) error {
 	placesPath := filepath.Join(profile.Path, "places.sqlite")
 
 	database, err := db.Open(placesPath)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer database.Close()
 
-	// Firefox stores timestamps as microseconds since Unix epoch
-	firefoxTimestamp := sinceTimestamp * 1000 // Convert ms to microseconds
-
+	// One entry per visit: a revisit of a known URL adds a moz_historyvisits
+	// row, while its moz_places row keeps its id
 	query := `
-		SELECT url, last_visit_date
-		FROM moz_places
-		WHERE last_visit_date > ?
-		  AND last_visit_date IS NOT NULL
-		ORDER BY last_visit_date ASC
+		SELECT moz_historyvisits.id, moz_places.url, moz_historyvisits.visit_date
+		FROM moz_historyvisits
+		JOIN moz_places ON moz_places.id = moz_historyvisits.place_id
+		WHERE (` + firefoxWhere + `) AND moz_historyvisits.id > ?
+		ORDER BY moz_historyvisits.id ASC
+		LIMIT ?
 	`
 
-	rows, err := database.Query(query, firefoxTimestamp)
-	if err != nil {
-		return nil, err
+	return database.ForEachPageContext(ctx, query, firefoxArgs(since), historyPageSize, func(rows *sql.Rows) error {
+		site, ok := scanFirefoxRow(rows)
+		if !ok {
+			return nil // Skip unreadable rows
+		}
+		return fn(site)
+	})
+}
+
+// SalvageHistory recovers the readable entries of a damaged places.sqlite
+func (f *FirefoxBrowser) SalvageHistory(profile Profile, since Cursor, fn VisitFunc) (Salvage, error) {
+	return salvageHistory(filepath.Join(profile.Path, "places.sqlite"), "moz_historyvisits",
+		"JOIN moz_places ON moz_places.id = moz_historyvisits.place_id",
+		"moz_historyvisits.id, moz_places.url, moz_historyvisits.visit_date", firefoxWhere, firefoxArgs(since), scanFirefoxRow, fn)
+}
+
+// firefoxWhere selects the visits newer than a cursor
+const firefoxWhere = `moz_historyvisits.visit_date > ? OR (? > 0 AND moz_historyvisits.id > ?)`
+
+// firefoxArgs returns the firefoxWhere arguments for a cursor
+func firefoxArgs(since Cursor) []interface{} {
+	// Firefox stores timestamps as microseconds since Unix epoch
+	firefoxTimestamp := since.micros()
+	return []interface{}{firefoxTimestamp, since.RowID, since.RowID}
+}
+
+// scanFirefoxRow converts a (visit id, url, visit_date) row
+func scanFirefoxRow(rows *sql.Rows) (dto.VisitedSite, bool) {
+	var id int64
+	var url string
+	var visitDate int64
+
+	if err := rows.Scan(&id, &url, &visitDate); err != nil {
+		return dto.VisitedSite{}, false
 	}
-	defer rows.Close()
 
-	var sites []dto.VisitedSite
-	for rows.Next() {
-		var url string
-		var lastVisitDate int64
+	// Convert microseconds to milliseconds
+	unixMs := visitDate / 1000
 
-		if err := rows.Scan(&url, &lastVisitDate); err != nil {
-			continue
-		}
+	return dto.VisitedSite{
+		URL:       url,
+		Timestamp: unixMs,
+		RowID:     id,
+	}, true
+}
 
-		// Convert microseconds to milliseconds
-		unixMs := lastVisitDate / 1000
+// Fingerprint returns the profile creation time from times.json and the highest visit id and visit count
+func (f *FirefoxBrowser) Fingerprint(profile Profile) (Fingerprint, error) {
+	database, err := db.Open(filepath.Join(profile.Path, "places.sqlite"))
+	if err != nil {
+		return Fingerprint{}, err
+	}
+	defer database.Close()
 
-		sites = append(sites, dto.VisitedSite{
-			URL:       url,
-			Timestamp: unixMs,
-		})
+	var fp Fingerprint
+	if err := database.QueryRow("SELECT COALESCE(MAX(id), 0), COUNT(*) FROM moz_historyvisits", nil, &fp.MaxRowID, &fp.Rows); err != nil {
+		return Fingerprint{}, err
 	}
 
-	return sites, rows.Err()
+	// times.json records when the profile was created
+	if data, err := os.ReadFile(filepath.Join(profile.Path, "times.json")); err == nil {
+		var times struct {
+			Created json.Number `json:"created"`
+		}
+		if json.Unmarshal(data, &times) == nil {
+			fp.ID = times.Created.String()
+		}
+	}
+
+	return fp, nil
 }
 
 // getProfilesDir returns the Firefox profiles directory for a user
 func (f *FirefoxBrowser) getProfilesDir(user platform.User) string {
-	switch platform.CurrentOS() {
-	case platform.Linux:
+	switch user.HomeLayout() {
+	case platform.Linux, platform.FreeBSD:
 		return filepath.Join(user.HomeDir, ".mozilla/firefox")
 
 	case platform.Darwin:
 		return filepath.Join(user.HomeDir, "Library/Application Support/Firefox/Profiles")
 
 	case platform.Windows:
-		// Firefox uses APPDATA on Windows
-		appData := filepath.Join(user.HomeDir, "AppData", "Roaming")
-		return filepath.Join(appData, "Mozilla", "Firefox", "Profiles")
+		// Firefox uses APPDATA on Windows (redirected to a share with roaming profiles)
+		return filepath.Join(user.AppDataPath(), "Mozilla", "Firefox", "Profiles")
 
 	default:
 		return ""
 	}
 }
+
+// Describe returns the version from the profile's compatibility.ini
+// (LastVersion=128.0_20240704121409/20240704121409) and the profile name,
+// which FindProfiles took from profiles.ini
+func (f *FirefoxBrowser) Describe(profile Profile) ProfileInfo {
+	info := ProfileInfo{DisplayName: profile.Name}
+	data, err := os.ReadFile(filepath.Join(profile.Path, "compatibility.ini"))
+	if err != nil {
+		return info
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), "LastVersion="); ok {
+			info.Version, _, _ = strings.Cut(value, "_")
+			break
+		}
+	}
+	return info
+}
+
+// DataDir returns the directory holding profiles.ini and the profiles
+func (f *FirefoxBrowser) DataDir(user platform.User) string {
+	return f.getProfilesDir(user)
+}
+
+// HistoryFile returns the path of the profile's places.sqlite database
+func (f *FirefoxBrowser) HistoryFile(profile Profile) string {
+	return filepath.Join(profile.Path, "places.sqlite")
+}
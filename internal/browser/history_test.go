@@ -0,0 +1,61 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package browser_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"hist_scanner/internal/browser"
+	"hist_scanner/internal/dto"
+	"hist_scanner/internal/fixture"
+)
+
+// TestStreamHistoryAfterCursor checks that history read up to its newest
+// visit yields nothing from the cursor of that visit, although visit times
+// are finer than the cursor's milliseconds
+func TestStreamHistoryAfterCursor(t *testing.T) {
+	dbs, err := fixture.Generate(fixture.Options{
+		Root:     t.TempDir(),
+		Users:    []string{"alice"},
+		Browsers: []string{"chrome", "firefox"},
+		Profiles: 1,
+		Visits:   200,
+		Days:     3,
+		Seed:     1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range dbs {
+		t.Run(d.Browser, func(t *testing.T) {
+			b := browser.ByName(d.Browser)
+			profile := browser.Profile{Name: d.Profile, Path: filepath.Dir(d.Path)}
+
+			var cursor browser.Cursor
+			read := 0
+			err := b.StreamHistory(context.Background(), profile, cursor, func(site dto.VisitedSite) error {
+				read++
+				cursor.Timestamp = max(cursor.Timestamp, site.Timestamp)
+				cursor.RowID = max(cursor.RowID, site.RowID)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if read != d.Visits {
+				t.Fatalf("read %d visits, want %d", read, d.Visits)
+			}
+
+			err = b.StreamHistory(context.Background(), profile, cursor, func(site dto.VisitedSite) error {
+				t.Errorf("visit read again: %+v", site)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
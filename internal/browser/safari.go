@@ -4,6 +4,8 @@
 package browser
 
 import (
+	"context"
+	"database/sql"
 	"os"
 	"path/filepath"
 
@@ -29,7 +31,7 @@ func (s *SafariBrowser) Name() string {
 // Safari doesn't have multiple profiles, so we return a single "Default" profile
 func (s *SafariBrowser) FindProfiles(user platform.User) ([]Profile, error) {
 	// Safari is macOS only
-	if platform.CurrentOS() != platform.Darwin {
+	if user.HomeLayout() != platform.Darwin {
 		return nil, nil
 	}
 
@@ -48,55 +50,87 @@ func (s *SafariBrowser) FindProfiles(user platform.User) ([]Profile, error) {
 	}, nil
 }
 
-// GetHistory extracts history entries from Safari since the given timestamp
-func (s *SafariBrowser) GetHistory(profile Profile, sinceTimestamp int64) ([]dto.VisitedSite, error) {
+// GetHistory extracts history entries from Safari newer than the given cursor
+func (s *SafariBrowser) GetHistory(profile Profile, since Cursor) ([]dto.VisitedSite, error) {
+	return collectHistory(func(fn VisitFunc) error {
+		return s.StreamHistory(context.Background(), profile, since, fn)
+	})
+}
+
+// StreamHistory streams history entries from Safari newer than the given cursor
+func (s *SafariBrowser) StreamHistory(ctx context.Context, profile Profile, since Cursor, fn VisitFunc) error {
 	historyPath := filepath.Join(profile.Path, "History.db")
 
 	database, err := db.Open(historyPath)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer database.Close()
 
 	// Safari uses "Mac Absolute Time" (seconds since 2001-01-01 00:00:00 UTC)
 	// Unix epoch to Mac epoch difference: 978307200 seconds
 	var safariTimestamp float64
-	if sinceTimestamp > 0 {
-		// Convert Unix ms to Safari timestamp (seconds since 2001-01-01)
-		safariTimestamp = float64(sinceTimestamp)/1000.0 - 978307200.0
+	if since.Timestamp > 0 {
+		// Convert to Safari timestamp (seconds since 2001-01-01)
+		safariTimestamp = float64(since.micros())/1000000.0 - 978307200.0
 	}
 
 	query := `
-		SELECT hi.url, hv.visit_time
+		SELECT hv.id, hi.url, hv.visit_time
 		FROM history_visits hv
 		JOIN history_items hi ON hv.history_item = hi.id
-		WHERE hv.visit_time > ?
-		ORDER BY hv.visit_time ASC
+		WHERE (hv.visit_time > ? OR (? > 0 AND hv.id > ?))
+		  AND hv.id > ?
+		ORDER BY hv.id ASC
+		LIMIT ?
 	`
 
-	rows, err := database.Query(query, safariTimestamp)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var sites []dto.VisitedSite
-	for rows.Next() {
+	args := []interface{}{safariTimestamp, since.RowID, since.RowID}
+	return database.ForEachPageContext(ctx, query, args, historyPageSize, func(rows *sql.Rows) error {
+		var id int64
 		var url string
 		var visitTime float64
 
-		if err := rows.Scan(&url, &visitTime); err != nil {
-			continue
+		if err := rows.Scan(&id, &url, &visitTime); err != nil {
+			return nil // Skip unreadable rows
 		}
 
 		// Convert Safari timestamp back to Unix milliseconds
 		unixMs := int64((visitTime + 978307200.0) * 1000)
 
-		sites = append(sites, dto.VisitedSite{
+		return fn(dto.VisitedSite{
 			URL:       url,
 			Timestamp: unixMs,
+			RowID:     id,
 		})
+	})
+}
+
+// Fingerprint returns the highest visit id and visit count (Safari has no profile creation marker)
+func (s *SafariBrowser) Fingerprint(profile Profile) (Fingerprint, error) {
+	database, err := db.Open(filepath.Join(profile.Path, "History.db"))
+	if err != nil {
+		return Fingerprint{}, err
 	}
+	defer database.Close()
+
+	var fp Fingerprint
+	if err := database.QueryRow("SELECT COALESCE(MAX(id), 0), COUNT(*) FROM history_visits", nil, &fp.MaxRowID, &fp.Rows); err != nil {
+		return Fingerprint{}, err
+	}
+
+	return fp, nil
+}
+
+// DataDir returns the Safari data directory (macOS only)
+func (s *SafariBrowser) DataDir(user platform.User) string {
+	if user.HomeLayout() != platform.Darwin {
+		return ""
+	}
+	return filepath.Join(user.HomeDir, "Library/Safari")
+}
 
-	return sites, rows.Err()
+// HistoryFile returns the path of Safari's History.db database
+func (s *SafariBrowser) HistoryFile(profile Profile) string {
+	return filepath.Join(profile.Path, "History.db")
 }
@@ -0,0 +1,66 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package buildinfo identifies the running scanner build to the servers it
+// talks to, so they can track the versions deployed across a fleet
+package buildinfo
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+
+	"hist_scanner/internal/dto"
+)
+
+// version and commit are the link-time values of the running binary
+var (
+	version = "dev"
+	commit  = ""
+)
+
+// Set records the link-time version and commit of the running binary.
+// Builds without a commit pass "unknown" or "".
+func Set(v, c string) {
+	if v != "" {
+		version = v
+	}
+	if c == "unknown" {
+		c = ""
+	}
+	commit = c
+}
+
+// Version returns the scanner version, "dev" for development builds
+func Version() string {
+	return version
+}
+
+// Scanner returns the build as reported in payloads and reports
+func Scanner() *dto.ScannerDTO {
+	return &dto.ScannerDTO{Version: version, Commit: commit, OS: runtime.GOOS, Arch: runtime.GOARCH}
+}
+
+// UserAgent returns the User-Agent of the scanner's requests,
+// "hist_scanner/1.4.0 (linux; amd64)"
+func UserAgent() string {
+	return "hist_scanner/" + version + " (" + runtime.GOOS + "; " + runtime.GOARCH + ")"
+}
+
+// HTTPClient returns an HTTP client whose requests carry UserAgent
+func HTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: transport{http.DefaultTransport}}
+}
+
+// transport sets the User-Agent of requests that have none
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", UserAgent())
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package catalog
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"hist_scanner/internal/buildinfo"
+)
+
+// Bundle is a catalog update published with an Ed25519 signature: SaaS
+// services and category lists, so classification can change without a new
+// release
+type Bundle struct {
+	Version    int64               `json:"version"` // Increases with every bundle; older ones are rejected
+	Services   []Service           `json:"services"`
+	Categories map[string][]string `json:"categories,omitempty"` // Domains of exclude_categories lists by name
+}
+
+// signedBundle is the bundle file: the bundle JSON and its signature
+type signedBundle struct {
+	Bundle    string `json:"bundle"`    // Base64 of the bundle JSON
+	Signature string `json:"signature"` // Base64 Ed25519 signature of bundlePrefix and the bundle JSON
+}
+
+// bundlePrefix separates bundle signatures from other uses of a key
+const bundlePrefix = "hist_scanner catalog bundle\n"
+
+// maxBundleSize bounds a downloaded bundle
+const maxBundleSize = 32 << 20
+
+// GenerateKey returns a new base64 public and private bundle signing key pair
+func GenerateKey() (string, string, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	enc := base64.StdEncoding
+	return enc.EncodeToString(pub), enc.EncodeToString(priv), nil
+}
+
+// ParsePublicKey decodes a base64 bundle public key
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid catalog public key: want base64 of %d bytes", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// ParsePrivateKey decodes a base64 bundle private key
+func ParsePrivateKey(s string) (ed25519.PrivateKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid catalog private key: want base64 of %d bytes", ed25519.PrivateKeySize)
+	}
+	return ed25519.PrivateKey(key), nil
+}
+
+// SignBundle validates bundle JSON and returns the signed bundle file
+func SignBundle(key ed25519.PrivateKey, data []byte) ([]byte, error) {
+	if _, err := parseBundle(data); err != nil {
+		return nil, err
+	}
+	sig := ed25519.Sign(key, append([]byte(bundlePrefix), data...))
+	return json.MarshalIndent(signedBundle{
+		Bundle:    base64.StdEncoding.EncodeToString(data),
+		Signature: base64.StdEncoding.EncodeToString(sig),
+	}, "", "  ")
+}
+
+// VerifyBundle checks the signature of a signed bundle file and returns the
+// bundle
+func VerifyBundle(key ed25519.PublicKey, data []byte) (*Bundle, error) {
+	var signed signedBundle
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return nil, fmt.Errorf("failed to parse catalog bundle: %w", err)
+	}
+	payload, err := base64.StdEncoding.DecodeString(signed.Bundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode catalog bundle: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(signed.Signature)
+	if err != nil || !ed25519.Verify(key, append([]byte(bundlePrefix), payload...), sig) {
+		return nil, fmt.Errorf("catalog bundle signature is invalid")
+	}
+	return parseBundle(payload)
+}
+
+// parseBundle reads and validates bundle JSON
+func parseBundle(data []byte) (*Bundle, error) {
+	var b Bundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("failed to parse catalog bundle: %w", err)
+	}
+	if b.Version <= 0 {
+		return nil, fmt.Errorf("catalog bundle has no version")
+	}
+	services, err := json.Marshal(b.Services)
+	if err == nil {
+		_, err = Parse(services)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("catalog bundle: %w", err)
+	}
+	return &b, nil
+}
+
+// ReadBundle reads and verifies a signed bundle file
+func ReadBundle(key ed25519.PublicKey, path string) (*Bundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog bundle: %w", err)
+	}
+	return VerifyBundle(key, data)
+}
+
+// DownloadBundle downloads a signed bundle and, if its signature is valid
+// and it is not older than minVersion, saves it to file
+func DownloadBundle(key ed25519.PublicKey, url, file string, minVersion int64, timeout time.Duration) (*Bundle, error) {
+	client := buildinfo.HTTPClient(timeout)
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download catalog bundle: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download catalog bundle: HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBundleSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download catalog bundle: %w", err)
+	}
+	if len(data) > maxBundleSize {
+		return nil, fmt.Errorf("catalog bundle is larger than %d MB", maxBundleSize>>20)
+	}
+
+	b, err := VerifyBundle(key, data)
+	if err != nil {
+		return nil, err
+	}
+	// A replayed older bundle could bring back removed services
+	if b.Version < minVersion {
+		return nil, fmt.Errorf("catalog bundle version %d is older than the cached version %d", b.Version, minVersion)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create catalog cache: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".download-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create catalog cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save catalog bundle: %w", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package catalog identifies SaaS services by the domains they are used on
+package catalog
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Risks lists the risk levels of services, lowest first
+var Risks = []string{"low", "medium", "high"}
+
+// AITool is the category of generative-AI services
+const AITool = "ai-tool"
+
+// Service is a SaaS application and the domains it is used on. Risk rates
+// the exposure of corporate data to it: low, medium or high.
+type Service struct {
+	Name     string   `json:"name"`
+	Category string   `json:"category"`
+	Risk     string   `json:"risk,omitempty"`
+	Domains  []string `json:"domains"`
+}
+
+// Catalog maps domains to services
+type Catalog struct {
+	services []Service
+	byDomain map[string]*Service
+}
+
+//go:embed services.json
+var builtinJSON []byte
+
+var (
+	builtinOnce sync.Once
+	builtin     *Catalog
+)
+
+// Builtin returns the catalog compiled into the scanner
+func Builtin() *Catalog {
+	builtinOnce.Do(func() {
+		services, err := Parse(builtinJSON)
+		if err != nil {
+			panic("catalog: invalid services.json: " + err.Error())
+		}
+		builtin = New(services)
+	})
+	return builtin
+}
+
+// Parse reads services in the format of services.json
+func Parse(data []byte) ([]Service, error) {
+	var services []Service
+	if err := json.Unmarshal(data, &services); err != nil {
+		return nil, err
+	}
+	for _, s := range services {
+		if s.Name == "" || len(s.Domains) == 0 {
+			return nil, fmt.Errorf("service %q needs a name and domains", s.Name)
+		}
+		if s.Risk != "" && !slices.Contains(Risks, s.Risk) {
+			return nil, fmt.Errorf("service %s: invalid risk %q (use %s)", s.Name, s.Risk, strings.Join(Risks, ", "))
+		}
+	}
+	return services, nil
+}
+
+// ReadFile reads a catalog file
+func ReadFile(path string) ([]Service, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog: %w", err)
+	}
+	services, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse catalog %s: %w", path, err)
+	}
+	return services, nil
+}
+
+// Load returns the built-in catalog updated with the services of a bundle,
+// then of a catalog file. Either may be absent (nil, "").
+func Load(bundle *Bundle, path string) (*Catalog, error) {
+	c := Builtin()
+	if bundle != nil {
+		c = c.Merge(bundle.Services)
+	}
+	if path == "" {
+		return c, nil
+	}
+	services, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return c.Merge(services), nil
+}
+
+// BundleCache is the cached signed bundle of catalog_url in a state directory
+func BundleCache(stateDir string) string {
+	return filepath.Join(stateDir, "catalog", "bundle.json")
+}
+
+// Merge returns a catalog with the services of c and others. A service of
+// others replaces the one of c with the same name.
+func (c *Catalog) Merge(others []Service) *Catalog {
+	services := slices.Clone(c.services)
+	for _, o := range others {
+		i := slices.IndexFunc(services, func(s Service) bool { return strings.EqualFold(s.Name, o.Name) })
+		if i >= 0 {
+			services[i] = o
+		} else {
+			services = append(services, o)
+		}
+	}
+	return New(services)
+}
+
+// New returns a catalog of services
+func New(services []Service) *Catalog {
+	c := &Catalog{services: services, byDomain: make(map[string]*Service)}
+	for i := range c.services {
+		for _, d := range c.services[i].Domains {
+			c.byDomain[strings.ToLower(d)] = &c.services[i]
+		}
+	}
+	return c
+}
+
+// Services returns the services of the catalog
+func (c *Catalog) Services() []Service {
+	return slices.Clone(c.services)
+}
+
+// Len returns the number of services
+func (c *Catalog) Len() int {
+	return len(c.services)
+}
+
+// Lookup returns the service used on a host. The most specific catalog
+// domain wins, so mail.google.com matches Gmail rather than a google.com
+// entry.
+func (c *Catalog) Lookup(host string) (*Service, bool) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for {
+		if s, ok := c.byDomain[host]; ok {
+			return s, true
+		}
+		i := strings.IndexByte(host, '.')
+		if i < 0 {
+			return nil, false
+		}
+		host = host[i+1:]
+	}
+}
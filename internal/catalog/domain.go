@@ -0,0 +1,61 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package catalog
+
+import (
+	"net"
+	"strings"
+)
+
+// multiLabelSuffixes are common public suffixes with more than one label,
+// including hosting platforms that give each customer a subdomain. Other
+// suffixes are assumed to be a single label (com, de, io).
+var multiLabelSuffixes = map[string]bool{
+	// Country second-level domains
+	"co.uk": true, "org.uk": true, "ac.uk": true, "gov.uk": true, "me.uk": true, "ltd.uk": true, "plc.uk": true,
+	"com.au": true, "net.au": true, "org.au": true, "edu.au": true, "gov.au": true,
+	"co.nz": true, "org.nz": true, "co.za": true, "org.za": true,
+	"co.jp": true, "ne.jp": true, "or.jp": true, "ac.jp": true, "go.jp": true,
+	"co.kr": true, "or.kr": true, "co.in": true, "net.in": true, "org.in": true, "gov.in": true,
+	"com.br": true, "net.br": true, "org.br": true, "gov.br": true,
+	"com.cn": true, "net.cn": true, "org.cn": true, "gov.cn": true,
+	"com.mx": true, "com.ar": true, "com.co": true, "com.tr": true, "com.sg": true, "com.hk": true,
+	"com.tw": true, "com.my": true, "com.ph": true, "com.vn": true, "com.ua": true, "com.pl": true,
+	"co.il": true, "co.id": true, "co.th": true,
+	// Hosting platforms
+	"github.io": true, "gitlab.io": true, "herokuapp.com": true, "azurewebsites.net": true,
+	"cloudfront.net": true, "appspot.com": true, "blogspot.com": true, "web.app": true,
+	"firebaseapp.com": true, "vercel.app": true, "netlify.app": true, "pages.dev": true,
+	"workers.dev": true, "s3.amazonaws.com": true, "onrender.com": true, "fly.dev": true,
+	"ngrok.io": true, "ngrok-free.app": true,
+}
+
+// RegistrableDomain returns the eTLD+1 of a host: the public suffix and one
+// more label (mail.google.com -> google.com, www.bbc.co.uk -> bbc.co.uk).
+// The public suffix list is approximated by multiLabelSuffixes. IP addresses
+// and single-label hosts are returned unchanged.
+func RegistrableDomain(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if net.ParseIP(host) != nil {
+		return host
+	}
+
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 {
+		return host
+	}
+
+	suffixLabels := 1
+	if len(labels) >= 3 {
+		if multiLabelSuffixes[strings.Join(labels[len(labels)-3:], ".")] {
+			suffixLabels = 3
+		} else if multiLabelSuffixes[strings.Join(labels[len(labels)-2:], ".")] {
+			suffixLabels = 2
+		}
+	}
+	if len(labels) <= suffixLabels {
+		return host
+	}
+	return strings.Join(labels[len(labels)-suffixLabels-1:], ".")
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package catalog
+
+import "strings"
+
+// Sanctioned lists the approved services, by catalog name or domain
+type Sanctioned []string
+
+// Service reports whether a service is approved by name or by one of its domains
+func (l Sanctioned) Service(s *Service) bool {
+	for _, name := range l {
+		if strings.EqualFold(name, s.Name) {
+			return true
+		}
+		for _, d := range s.Domains {
+			if strings.EqualFold(name, d) || strings.EqualFold(name, RegistrableDomain(d)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Domain reports whether a registrable domain is approved, either listed
+// itself or through a listed subdomain
+func (l Sanctioned) Domain(domain string) bool {
+	for _, name := range l {
+		if strings.EqualFold(name, domain) || strings.EqualFold(RegistrableDomain(name), domain) {
+			return true
+		}
+	}
+	return false
+}
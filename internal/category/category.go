@@ -0,0 +1,202 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package category classifies sites into sensitive categories (health,
+// banking, unions, adult) from local domain lists, so that visits to them
+// can be dropped before anything leaves the machine
+package category
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+//go:embed lists/*.txt
+var bundled embed.FS
+
+// List is a set of domains; subdomains of a listed domain match too
+type List map[string]bool
+
+// Bundled returns the names of the categories with a list compiled into the
+// scanner
+func Bundled() []string {
+	entries, _ := bundled.ReadDir("lists")
+	var names []string
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".txt"))
+	}
+	return names
+}
+
+// BundledList returns the compiled-in list of a category
+func BundledList(name string) (List, bool) {
+	f, err := bundled.Open(path.Join("lists", name+".txt"))
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	l, err := Parse(f)
+	if err != nil {
+		panic("category: invalid bundled list " + name + ": " + err.Error())
+	}
+	return l, true
+}
+
+// ReadFile parses a list file
+func ReadFile(name string) (List, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open category list: %w", err)
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse reads a domain list in hosts format ("0.0.0.0 example.com"),
+// adblock format ("||example.com^") or one domain per line. Comments and
+// rules that do not block a whole domain are ignored.
+func Parse(r io.Reader) (List, error) {
+	l := make(List)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		for _, domain := range parseLine(scanner.Text()) {
+			l[domain] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read category list: %w", err)
+	}
+	return l, nil
+}
+
+// parseLine returns the domains of one list line
+func parseLine(line string) []string {
+	line = strings.TrimSpace(line)
+	// Adblock comments, headers and exception rules
+	if line == "" || line[0] == '!' || line[0] == '[' || strings.HasPrefix(line, "@@") {
+		return nil
+	}
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		// Hosts comments; example.com##.ad is an adblock element hiding rule
+		if i > 0 && line[i-1] != ' ' && line[i-1] != '\t' {
+			return nil
+		}
+		line = line[:i]
+	}
+
+	if rule, ok := strings.CutPrefix(line, "||"); ok {
+		var rest string
+		if end := strings.IndexAny(rule, "^/$|"); end >= 0 {
+			rule, rest = rule[:end], rule[end:]
+		}
+		rest, _, _ = strings.Cut(rest, "$")
+		// Only rules blocking the whole domain, not a path of it
+		if strings.Trim(rest, "^|") != "" {
+			return nil
+		}
+		if d, ok := normalize(rule); ok {
+			return []string{d}
+		}
+		return nil
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) > 1 && net.ParseIP(fields[0]) != nil {
+		fields = fields[1:]
+	} else if len(fields) != 1 {
+		return nil
+	}
+	var domains []string
+	for _, f := range fields {
+		if d, ok := normalize(f); ok {
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
+
+// normalize lowercases a listed domain and rejects hosts entries that are
+// not domains (localhost, IP addresses, wildcards)
+func normalize(domain string) (string, bool) {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimPrefix(domain, "*.")), ".")
+	if !strings.Contains(domain, ".") || strings.ContainsAny(domain, "*/:?=") || net.ParseIP(domain) != nil {
+		return "", false
+	}
+	if domain == "localhost.localdomain" {
+		return "", false
+	}
+	return domain, true
+}
+
+// Contains reports whether the list has the host or one of its parent domains
+func (l List) Contains(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for {
+		if l[host] {
+			return true
+		}
+		i := strings.IndexByte(host, '.')
+		if i < 0 {
+			return false
+		}
+		host = host[i+1:]
+	}
+}
+
+// Set is the lists of the excluded categories
+type Set struct {
+	names []string
+	lists []List
+}
+
+// Add adds the list of a category
+func (s *Set) Add(name string, l List) {
+	s.names = append(s.names, name)
+	s.lists = append(s.lists, l)
+}
+
+// Names returns the categories of the set
+func (s *Set) Names() []string {
+	return slices.Clone(s.names)
+}
+
+// Match returns the first category whose list contains the host
+func (s *Set) Match(host string) (string, bool) {
+	for i, l := range s.lists {
+		if l.Contains(host) {
+			return s.names[i], true
+		}
+	}
+	return "", false
+}
+
+// ParseSources parses category_lists entries of the form name=source, where
+// source is an http(s) URL or an absolute file path
+func ParseSources(specs []string) (map[string]string, error) {
+	sources := make(map[string]string)
+	for _, spec := range specs {
+		name, source, ok := strings.Cut(spec, "=")
+		name, source = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(source)
+		if !ok || name == "" || source == "" {
+			return nil, fmt.Errorf("invalid entry %q: want name=url or name=/path", spec)
+		}
+		if !IsURL(source) && !filepath.IsAbs(source) {
+			return nil, fmt.Errorf("invalid source of %s: %q is neither an http(s) URL nor an absolute path", name, source)
+		}
+		sources[name] = source
+	}
+	return sources, nil
+}
+
+// IsURL reports whether a list source is downloaded
+func IsURL(source string) bool {
+	return strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://")
+}
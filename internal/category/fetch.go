@@ -0,0 +1,64 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package category
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"hist_scanner/internal/buildinfo"
+)
+
+// maxListSize limits downloaded lists; large adult lists have a few million
+// domains
+const maxListSize = 256 << 20
+
+// Download fetches a hosted list to file, replacing it only once the whole
+// list was received and parsed
+func Download(url, file string, timeout time.Duration) error {
+	client := buildinfo.HTTPClient(timeout)
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to download category list: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download category list: HTTP %d", resp.StatusCode)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return fmt.Errorf("failed to create list cache: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".download-*")
+	if err != nil {
+		return fmt.Errorf("failed to create list cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, io.LimitReader(resp.Body, maxListSize+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to download category list: %w", err)
+	}
+	if n > maxListSize {
+		return fmt.Errorf("category list is larger than %d MB", maxListSize>>20)
+	}
+	l, err := ReadFile(tmp.Name())
+	if err != nil {
+		return err
+	}
+	if len(l) == 0 {
+		return fmt.Errorf("category list has no domains")
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return fmt.Errorf("failed to save category list: %w", err)
+	}
+	return nil
+}
@@ -5,12 +5,27 @@ package config
 
 import (
 	"fmt"
+	"net/textproto"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
+
+	"hist_scanner/internal/catalog"
+	"hist_scanner/internal/category"
+	"hist_scanner/internal/dnslog"
+	"hist_scanner/internal/logging"
+	"hist_scanner/internal/notice"
+	"hist_scanner/internal/optout"
+	"hist_scanner/internal/proxylog"
+	"hist_scanner/internal/watchlist"
+	"hist_scanner/pkg/sink"
 )
 
 // Config holds all configuration for the scanner
@@ -23,10 +38,304 @@ type Config struct {
 	Compress    bool          `mapstructure:"compress"`      // Enable gzip compression
 	StateFile   string        `mapstructure:"state_file"`
 	LogFile     string        `mapstructure:"log_file"`
+	LogLevel    string        `mapstructure:"log_level"`  // debug, info, warn or error
+	LogFormat   string        `mapstructure:"log_format"` // text or json
 	Source      string        `mapstructure:"source"`
 
+	// AuthHeader and AuthScheme form the header carrying APIKey,
+	// "<AuthHeader>: <AuthScheme> <APIKey>", by default "Authorization:
+	// ProxyToken <key>". Scheme "none" sends the bare key, as gateways
+	// expecting e.g. "X-API-Key: <key>" do.
+	AuthHeader string `mapstructure:"auth_header"`
+	AuthScheme string `mapstructure:"auth_scheme"`
+
+	// Headers are static "Name: value" headers added to every request to
+	// the server, e.g. "X-Tenant-Id: acme"
+	Headers []string `mapstructure:"headers"`
+
+	// PayloadFormat selects what is uploaded: "visits" sends each visit,
+	// "aggregates" sends visit counts per registrable domain (v2 payload),
+	// "both" sends both, and "auto" sends aggregates if the server accepts
+	// the v2 payload and visits otherwise
+	PayloadFormat string `mapstructure:"payload_format"`
+
+	// PayloadEncoding encodes visit uploads as "json" or "msgpack"
+	// (MessagePack, smaller and faster to encode); servers that reject
+	// MessagePack get JSON
+	PayloadEncoding string `mapstructure:"payload_encoding"`
+
+	// ResumableUploadKB uploads chunks of at least this many KB (as sent)
+	// through resumable upload sessions, which survive connection resets.
+	// 0 posts every chunk in one request.
+	ResumableUploadKB int `mapstructure:"resumable_upload_kb"`
+
+	// UploadConcurrency is how many chunks are uploaded to the server at
+	// once. The scan state still advances only through the chunks accepted
+	// in order.
+	UploadConcurrency int `mapstructure:"upload_concurrency"`
+
+	// ClockSkew measures the device clock against the server's Date
+	// headers: "annotate" adds the skew to visit payloads, "correct" also
+	// shifts visit timestamps to the server's clock once the skew exceeds
+	// two minutes, and "off" does neither
+	ClockSkew string `mapstructure:"clock_skew"`
+
+	// FlagFutureVisits marks visits timestamped later than the server's
+	// time, which analytics would otherwise reject
+	FlagFutureVisits bool `mapstructure:"flag_future_visits"`
+
+	// Sinks receive the visits: "http" uploads them to ServerURL,
+	// "file:<path>" appends them to a JSON Lines file and "syslog" or
+	// "syslog:udp://host:514" writes them to syslog. Sinks registered by an
+	// embedding agent are selected by their name.
+	Sinks []string `mapstructure:"sinks"`
+
+	// CurrentUserOnly limits scanning to the user running the scanner (per-user installs)
+	CurrentUserOnly bool `mapstructure:"current_user_only"`
+
+	// StateEncryption enables AES-GCM encryption of the state file.
+	// The key is derived from StateKey, or from the machine id if StateKey is empty.
+	StateEncryption bool   `mapstructure:"state_encryption"`
+	StateKey        string `mapstructure:"state_key"`
+
+	// HomeTimeout limits how long a home directory on a network filesystem
+	// (NFS, SMB, redirected folders) may take to respond before the user is skipped
+	HomeTimeout time.Duration `mapstructure:"home_timeout"`
+
+	// WSLWindowsProfiles also scans the Windows profiles under /mnt/c/Users
+	// when running inside WSL. WSLDistros also scans the homes inside WSL
+	// distributions when running on Windows (this starts the distributions).
+	WSLWindowsProfiles bool `mapstructure:"wsl_windows_profiles"`
+	WSLDistros         bool `mapstructure:"wsl_distros"`
+
+	// UserSource selects the account database on Linux/FreeBSD: "auto"
+	// (getent if available), "passwd" or "getent". ScanHomeDirs also scans
+	// /home directories of accounts the source does not list (e.g. SSSD
+	// domain users without enumeration).
+	UserSource   string `mapstructure:"user_source"`
+	ScanHomeDirs bool   `mapstructure:"scan_home_dirs"`
+
+	// HostRoot is where the host's filesystem, or its etc/passwd and home
+	// or its Users directory, is mounted when the scanner runs in a
+	// container (e.g. "/host"). Users are enumerated and scanned under it,
+	// and the device is identified by the host's machine id and hostname.
+	HostRoot string `mapstructure:"host_root"`
+
+	// SkipDisabledAccounts skips users whose account is disabled, locked or
+	// expired. StaleDays skips users whose browser history has not changed
+	// in that many days (0 scans all users).
+	SkipDisabledAccounts bool `mapstructure:"skip_disabled_accounts"`
+	StaleDays            int  `mapstructure:"stale_days"`
+
+	// ExcludedUsers are never scanned (matched case-insensitively).
+	// OptOutPublicKey verifies signed opt-out markers in users' homes; empty
+	// ignores markers.
+	ExcludedUsers   []string `mapstructure:"excluded_users"`
+	OptOutPublicKey string   `mapstructure:"optout_public_key"`
+
+	// ShadowCopies reads history databases that a running browser locks
+	// exclusively from a Volume Shadow Copy snapshot (Windows, needs admin)
+	ShadowCopies bool `mapstructure:"shadow_copies"`
+
+	// RunningBrowsers is how profiles whose browser the user is running are
+	// scanned: "defer" scans them after the other profiles of the run,
+	// "next-run" also leaves them to the next run while the browser still
+	// runs (at most three runs in a row), and "scan" scans them in turn
+	RunningBrowsers string `mapstructure:"running_browsers"`
+
+	// MaxCopySizeMB is the largest locked database (with its WAL) copied to a
+	// temp file for reading; larger ones are skipped. 0 means no limit.
+	MaxCopySizeMB int `mapstructure:"max_copy_size_mb"`
+
+	// TempDir holds the copies of locked databases in per-run subdirectories
+	// readable only by the scanner. Empty uses a scanner-owned directory in
+	// the system temp dir, falling back to one next to the state file.
+	TempDir string `mapstructure:"temp_dir"`
+
+	// ShredTempFiles overwrites database copies before they are removed.
+	// EncryptExports seals export files with a key derived from state_key,
+	// or bound to this machine.
+	ShredTempFiles bool `mapstructure:"shred_temp_files"`
+	EncryptExports bool `mapstructure:"encrypt_exports"`
+
+	// Retention limits the scanner's local data at the start of every scan.
+	// The log file is rotated when larger than LogMaxSizeMB (0 never
+	// rotates). Rotated logs and the files under RetentionPaths (spool and
+	// export directories) are removed after RetentionDays, then the oldest
+	// until each set fits in RetentionMaxMB (0 means no limit).
+	LogMaxSizeMB   int      `mapstructure:"log_max_size_mb"`
+	RetentionDays  int      `mapstructure:"retention_days"`
+	RetentionMaxMB int      `mapstructure:"retention_max_mb"`
+	RetentionPaths []string `mapstructure:"retention_paths"`
+
+	// QueryTimeout limits the time SQLite spends on one history query, and
+	// MaxRows the rows read from one profile per run (0 means no limit);
+	// the rest of a profile is read on the next run
+	QueryTimeout time.Duration `mapstructure:"query_timeout"`
+	MaxRows      int           `mapstructure:"max_rows"`
+
+	// ErrorSeverity sets how profiles failing with an error category count
+	// towards the exit code, as "category=severity" entries: error (the
+	// default) fails the run, warning only reports the profile
+	ErrorSeverity []string `mapstructure:"error_severity"`
+
+	// ProfileStores also scans signed-out users whose profiles live in FSLogix
+	// containers (attached read-only while scanned) or a Citrix UPM store
+	ProfileStores bool `mapstructure:"profile_stores"`
+
+	// StatusURL receives a compact report after each scan run (exit code,
+	// counts, errors). Empty disables run reporting.
+	StatusURL string `mapstructure:"status_url"`
+
+	// HealthURL is the health endpoint status checks the server with, an
+	// http(s) URL or a path resolved against ServerURL (e.g. /healthz).
+	// Empty checks the upload endpoint with a HEAD request.
+	HealthURL string `mapstructure:"health_url"`
+
+	// WebhookURL receives a scan summary signed with HMAC-SHA256 of
+	// WebhookSecret when a scan finishes, for orchestration systems.
+	// WebhookOn is "always" or "failure" (a non-zero exit code).
+	WebhookURL    string `mapstructure:"webhook_url"`
+	WebhookSecret string `mapstructure:"webhook_secret"`
+	WebhookOn     string `mapstructure:"webhook_on"`
+
+	// ControlSocket is the Unix domain socket of the daemon's control API.
+	// Empty places control.sock next to the state file; "off" disables it.
+	ControlSocket string `mapstructure:"control_socket"`
+
+	// AuditLog is an append-only, hash-chained log of every chunk sent to
+	// the server (time, principal, entry count, URL digest, status). Empty
+	// disables it.
+	AuditLog string `mapstructure:"audit_log"`
+
+	// SignPayloads signs every upload with a per-device Ed25519 key that is
+	// registered with the server. DeviceKey is the key file; empty uses
+	// device.key next to the state file.
+	SignPayloads bool   `mapstructure:"sign_payloads"`
+	DeviceKey    string `mapstructure:"device_key"`
+
+	// IntegrityCheck compares the installed binary with the hash and build
+	// recorded at install before each scan: "warn" logs a mismatch,
+	// "enforce" refuses to scan, "off" skips the check.
+	IntegrityCheck string `mapstructure:"integrity_check"`
+
+	// ErrorURL receives an error event when a scan panics or fails
+	// completely. Events carry no history data. Empty only logs them.
+	ErrorURL string `mapstructure:"error_url"`
+
+	// EventsURL receives an event when a profile's history was cleared or
+	// shrank since the last scan. Events carry no URLs. Empty only logs them.
+	EventsURL string `mapstructure:"events_url"`
+
+	// Notice lists how users are told that browsing is audited: "page" (a
+	// local HTML disclosure page) and "login" (a login message). NoticeText
+	// replaces the built-in disclosure text.
+	Notice     []string `mapstructure:"notice"`
+	NoticeText string   `mapstructure:"notice_text"`
+
+	// SanctionedServices lists the approved SaaS services, by catalog name or
+	// domain; the report command lists usage of all other services
+	SanctionedServices []string `mapstructure:"sanctioned_services"`
+
+	// SanctionedURL returns a JSON array of further approved services, merged
+	// with SanctionedServices before new services are discovered
+	SanctionedURL string `mapstructure:"sanctioned_url"`
+
+	// DiscoverServices reports each unsanctioned registrable domain the first
+	// time it is visited on the device: "catalog" only domains of catalog
+	// services, "all" every domain, "off" none
+	DiscoverServices string `mapstructure:"discover_services"`
+
+	// TrackDomains records in state when each registrable domain was first
+	// and last visited on the device; run reports list the domains new to
+	// it. DomainDays forgets domains not visited for that many days (0 never).
+	TrackDomains bool `mapstructure:"track_domains"`
+	DomainDays   int  `mapstructure:"domain_days"`
+
+	// RiskyExtensions lists browser extension ids rated high risk by the
+	// extensions command regardless of their permissions
+	RiskyExtensions []string `mapstructure:"risky_extensions"`
+
+	// DetectOAuth reports the first visit of each user to an OAuth consent
+	// page of an app, i.e. a user granting a third-party app access
+	DetectOAuth bool `mapstructure:"detect_oauth"`
+
+	// DetectSignups reports the first visit of each user to a sign-up or
+	// registration page of an unsanctioned domain, a likely account
+	// creation. SignupPatterns are regular expressions matched against the
+	// lower-case URL path.
+	DetectSignups  bool     `mapstructure:"detect_signups"`
+	SignupPatterns []string `mapstructure:"signup_patterns"`
+
+	// Watchlist lists domains (subdomains included) and host/path patterns
+	// (* matches any characters) whose visits raise a high-priority alert
+	// as soon as they are scanned. WatchlistURL returns a JSON array of
+	// further entries. AlertURL receives the alerts; empty uses EventsURL.
+	Watchlist    []string `mapstructure:"watchlist"`
+	WatchlistURL string   `mapstructure:"watchlist_url"`
+	AlertURL     string   `mapstructure:"alert_url"`
+
+	// ProxyClients maps the client addresses of proxy log requests without
+	// an authenticated user to user names ("10.1.2.7=jsmith" or
+	// "10.1.2.0/24=branch-ny"); other clients are sent by IP address
+	ProxyClients []string `mapstructure:"proxy_clients"`
+
+	// DNSSources are read after each scan for the domains this machine (or
+	// the clients of its resolver) resolved: "windows-cache",
+	// "systemd-resolved" or "unbound:/var/log/unbound.log". Their events
+	// are marked as lower-fidelity than browser history. Empty reads none.
+	DNSSources []string `mapstructure:"dns_sources"`
+
+	// TagServices tags each sent visit with the SaaS service, category and
+	// risk of its host from the catalog. CatalogFile adds services to the
+	// built-in catalog or replaces those with the same name.
+	TagServices bool   `mapstructure:"tag_services"`
+	CatalogFile string `mapstructure:"catalog_file"`
+
+	// AITools handles visits to catalog services of the ai-tool category:
+	// "tag" only tags them, "aggregate" also reports each user's AI tool
+	// visits per scan, "escalate" also reports each user's first use of
+	// every AI tool as a high-priority event
+	AITools string `mapstructure:"ai_tools"`
+
+	// CatalogURL serves a signed catalog bundle (services and category
+	// lists) that updates the built-in ones; it is checked at most daily and
+	// cached. CatalogPublicKey verifies its Ed25519 signature.
+	CatalogURL       string `mapstructure:"catalog_url"`
+	CatalogPublicKey string `mapstructure:"catalog_public_key"`
+
+	// ExcludeCategories drops visits to sites of these categories (health,
+	// banking, unions, adult) before anything is sent. CategoryLists replaces
+	// or adds category lists as name=url or name=/path entries.
+	ExcludeCategories []string `mapstructure:"exclude_categories"`
+	CategoryLists     []string `mapstructure:"category_lists"`
+
+	// AllowedSchemes lists the URL schemes that are sent ("*" sends all);
+	// visits to file:, about:, chrome-extension: and other URLs are dropped.
+	// DropPrivateAddresses drops visits to loopback, private and link-local
+	// addresses and single-label intranet hosts. InternalDomains drops
+	// visits to these domains and their subdomains.
+	AllowedSchemes       []string `mapstructure:"allowed_schemes"`
+	DropPrivateAddresses bool     `mapstructure:"drop_private_addresses"`
+	InternalDomains      []string `mapstructure:"internal_domains"`
+
+	// Schedules lists the scheduler entries created by install, e.g. an hourly
+	// incremental scan plus a weekly full rescan. Empty means a single entry
+	// running at the install --interval.
+	Schedules []Schedule `mapstructure:"schedules"`
+
 	// discoveredConfig is true if config was obtained via auto-discovery
 	discoveredConfig bool
+
+	// sources lists where Load found settings
+	sources []string
+}
+
+// Schedule is one scheduler entry created by install
+type Schedule struct {
+	Name     string        `mapstructure:"name"`     // Entry name suffix; unused for the first schedule
+	Interval time.Duration `mapstructure:"interval"` // Time between runs
+	Full     bool          `mapstructure:"full"`     // Run with --full (ignore stored scan positions)
 }
 
 // DefaultConfig returns configuration with default values
@@ -37,11 +346,61 @@ func DefaultConfig() *Config {
 		ChunkSizeKB: 1024, // 1MB default
 		Compress:    true, // Gzip enabled by default
 		Source:      "hist_scanner",
+		AuthHeader:  "Authorization",
+		AuthScheme:  "ProxyToken",
+
+		PayloadFormat:   "visits",
+		PayloadEncoding: "json",
+		WebhookOn:       "always",
+		Sinks:           []string{sink.HTTP},
+		LogLevel:        "info",
+		LogFormat:       "text",
+		HomeTimeout:     10 * time.Second,
+
+		UploadConcurrency: 1,
+
+		ClockSkew: "annotate",
+
+		WSLWindowsProfiles: true,
+
+		UserSource: "auto",
+
+		AllowedSchemes: []string{"http", "https"},
+
+		IntegrityCheck: "warn",
+
+		TagServices: true,
+		AITools:     "tag",
+
+		DiscoverServices: "off",
+		DetectOAuth:      true,
+		TrackDomains:     true,
+		DomainDays:       365,
+		DetectSignups:    true,
+		SignupPatterns: []string{
+			`(^|/)(sign-?up|register|registration|create-?account|join)(/|$)`,
+			`(^|/)welcome(/|$)`,
+			`verify-?e-?mail`,
+		},
+
+		SkipDisabledAccounts: true,
+
+		ShadowCopies:  true,
+		MaxCopySizeMB: 2048,
+
+		RunningBrowsers: "defer",
+
+		LogMaxSizeMB:   10,
+		RetentionDays:  30,
+		RetentionMaxMB: 100,
+
+		QueryTimeout: 2 * time.Minute,
+		MaxRows:      1000000,
 	}
 }
 
-// Load reads configuration from file, environment, and optionally auto-discovery.
-// Priority (highest to lowest): CLI flags > Env vars > Config file > Auto-discovery
+// Load reads configuration from Group Policy, file, environment, and optionally auto-discovery.
+// Priority (highest to lowest): CLI flags > Group Policy (Windows) > Env vars > Config file > Auto-discovery
 func Load(configPath string) (*Config, error) {
 	cfg := DefaultConfig()
 
@@ -50,6 +409,13 @@ func Load(configPath string) (*Config, error) {
 		if err := viper.ReadInConfig(); err != nil {
 			return nil, fmt.Errorf("failed to read config file: %w", err)
 		}
+		cfg.sources = append(cfg.sources, "file")
+	}
+	for _, env := range os.Environ() {
+		if strings.HasPrefix(env, "HIST_SCANNER_") {
+			cfg.sources = append(cfg.sources, "env")
+			break
+		}
 	}
 
 	// Environment variable overrides
@@ -61,7 +427,100 @@ func Load(configPath string) (*Config, error) {
 	viper.SetDefault("timeout", cfg.Timeout)
 	viper.SetDefault("chunk_size_kb", cfg.ChunkSizeKB)
 	viper.SetDefault("compress", cfg.Compress)
+	viper.SetDefault("payload_format", cfg.PayloadFormat)
+	viper.SetDefault("payload_encoding", cfg.PayloadEncoding)
+	viper.SetDefault("resumable_upload_kb", cfg.ResumableUploadKB)
+	viper.SetDefault("upload_concurrency", cfg.UploadConcurrency)
+	viper.SetDefault("clock_skew", cfg.ClockSkew)
+	viper.SetDefault("flag_future_visits", cfg.FlagFutureVisits)
+	viper.SetDefault("sinks", cfg.Sinks)
 	viper.SetDefault("source", cfg.Source)
+	viper.SetDefault("auth_header", cfg.AuthHeader)
+	viper.SetDefault("auth_scheme", cfg.AuthScheme)
+	viper.SetDefault("headers", cfg.Headers)
+	viper.SetDefault("log_level", cfg.LogLevel)
+	viper.SetDefault("log_format", cfg.LogFormat)
+	viper.SetDefault("current_user_only", cfg.CurrentUserOnly)
+	viper.SetDefault("state_encryption", cfg.StateEncryption)
+	viper.SetDefault("state_key", cfg.StateKey)
+	viper.SetDefault("status_url", cfg.StatusURL)
+	viper.SetDefault("health_url", cfg.HealthURL)
+	viper.SetDefault("webhook_url", cfg.WebhookURL)
+	viper.SetDefault("webhook_secret", cfg.WebhookSecret)
+	viper.SetDefault("webhook_on", cfg.WebhookOn)
+	viper.SetDefault("control_socket", cfg.ControlSocket)
+	viper.SetDefault("error_url", cfg.ErrorURL)
+	viper.SetDefault("events_url", cfg.EventsURL)
+	viper.SetDefault("audit_log", cfg.AuditLog)
+	viper.SetDefault("sign_payloads", cfg.SignPayloads)
+	viper.SetDefault("device_key", cfg.DeviceKey)
+	viper.SetDefault("integrity_check", cfg.IntegrityCheck)
+	viper.SetDefault("sanctioned_services", cfg.SanctionedServices)
+	viper.SetDefault("tag_services", cfg.TagServices)
+	viper.SetDefault("sanctioned_url", cfg.SanctionedURL)
+	viper.SetDefault("discover_services", cfg.DiscoverServices)
+	viper.SetDefault("detect_oauth", cfg.DetectOAuth)
+	viper.SetDefault("risky_extensions", cfg.RiskyExtensions)
+	viper.SetDefault("track_domains", cfg.TrackDomains)
+	viper.SetDefault("domain_days", cfg.DomainDays)
+	viper.SetDefault("detect_signups", cfg.DetectSignups)
+	viper.SetDefault("signup_patterns", cfg.SignupPatterns)
+	viper.SetDefault("watchlist", cfg.Watchlist)
+	viper.SetDefault("proxy_clients", cfg.ProxyClients)
+	viper.SetDefault("dns_sources", cfg.DNSSources)
+	viper.SetDefault("watchlist_url", cfg.WatchlistURL)
+	viper.SetDefault("alert_url", cfg.AlertURL)
+	viper.SetDefault("catalog_file", cfg.CatalogFile)
+	viper.SetDefault("ai_tools", cfg.AITools)
+	viper.SetDefault("catalog_url", cfg.CatalogURL)
+	viper.SetDefault("catalog_public_key", cfg.CatalogPublicKey)
+	viper.SetDefault("exclude_categories", cfg.ExcludeCategories)
+	viper.SetDefault("category_lists", cfg.CategoryLists)
+	viper.SetDefault("allowed_schemes", cfg.AllowedSchemes)
+	viper.SetDefault("drop_private_addresses", cfg.DropPrivateAddresses)
+	viper.SetDefault("internal_domains", cfg.InternalDomains)
+	viper.SetDefault("notice", cfg.Notice)
+	viper.SetDefault("notice_text", cfg.NoticeText)
+	viper.SetDefault("home_timeout", cfg.HomeTimeout)
+	viper.SetDefault("wsl_windows_profiles", cfg.WSLWindowsProfiles)
+	viper.SetDefault("wsl_distros", cfg.WSLDistros)
+	viper.SetDefault("profile_stores", cfg.ProfileStores)
+	viper.SetDefault("user_source", cfg.UserSource)
+	viper.SetDefault("scan_home_dirs", cfg.ScanHomeDirs)
+	viper.SetDefault("host_root", cfg.HostRoot)
+	viper.SetDefault("skip_disabled_accounts", cfg.SkipDisabledAccounts)
+	viper.SetDefault("stale_days", cfg.StaleDays)
+	viper.SetDefault("excluded_users", cfg.ExcludedUsers)
+	viper.SetDefault("optout_public_key", cfg.OptOutPublicKey)
+	viper.SetDefault("shadow_copies", cfg.ShadowCopies)
+	viper.SetDefault("running_browsers", cfg.RunningBrowsers)
+	viper.SetDefault("max_copy_size_mb", cfg.MaxCopySizeMB)
+	viper.SetDefault("temp_dir", cfg.TempDir)
+	viper.SetDefault("log_max_size_mb", cfg.LogMaxSizeMB)
+	viper.SetDefault("retention_days", cfg.RetentionDays)
+	viper.SetDefault("retention_max_mb", cfg.RetentionMaxMB)
+	viper.SetDefault("retention_paths", cfg.RetentionPaths)
+	viper.SetDefault("shred_temp_files", cfg.ShredTempFiles)
+	viper.SetDefault("encrypt_exports", cfg.EncryptExports)
+	viper.SetDefault("query_timeout", cfg.QueryTimeout)
+	viper.SetDefault("max_rows", cfg.MaxRows)
+	viper.SetDefault("error_severity", cfg.ErrorSeverity)
+
+	// Group Policy values take precedence over the config file and environment
+	policy := loadPolicy()
+	for key, value := range policy {
+		viper.Set(key, value)
+	}
+	if value, ok := policy["schedules"].(string); ok {
+		schedules, err := parsePolicySchedules(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedules policy: %w", err)
+		}
+		viper.Set("schedules", schedules)
+	}
+	if len(policy) > 0 {
+		cfg.sources = append(cfg.sources, "policy")
+	}
 
 	if err := viper.Unmarshal(cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
@@ -77,6 +536,7 @@ func Load(configPath string) (*Config, error) {
 				cfg.APIKey = discovered.APIKey
 			}
 			cfg.discoveredConfig = true
+			cfg.sources = append(cfg.sources, "discovery")
 		}
 	}
 
@@ -88,12 +548,95 @@ func (c *Config) WasDiscovered() bool {
 	return c.discoveredConfig
 }
 
+// reservedHeaders are set by the scanner itself and cannot be configured
+// in headers
+var reservedHeaders = []string{"Accept", "Content-Encoding", "Content-Length", "Content-Type", "Host", "X-Device-Signature"}
+
+// ErrorCategories are the categories failed profiles are classified in
+var ErrorCategories = []string{"permission", "locked", "schema", "corrupt", "timeout", "copy_refused", "send", "read"}
+
+// Severities of error categories
+const (
+	SeverityError   = "error"   // Fails the run (exit code 1 or 2)
+	SeverityWarning = "warning" // Reported, but does not fail the run
+)
+
+// ErrorSeverities returns the severity of each category listed in
+// ErrorSeverity; categories not listed are errors
+func (c *Config) ErrorSeverities() (map[string]string, error) {
+	severities := make(map[string]string, len(c.ErrorSeverity))
+	for _, entry := range c.ErrorSeverity {
+		category, severity, ok := strings.Cut(entry, "=")
+		category, severity = strings.TrimSpace(category), strings.TrimSpace(severity)
+		if !ok || !slices.Contains(ErrorCategories, category) {
+			return nil, fmt.Errorf("%q must be category=severity with a category of %s", entry, strings.Join(ErrorCategories, ", "))
+		}
+		if severity != SeverityError && severity != SeverityWarning {
+			return nil, fmt.Errorf("severity of %s must be error or warning", category)
+		}
+		severities[category] = severity
+	}
+	return severities, nil
+}
+
+// reservedHeaderPrefixes start the scan metadata and webhook headers
+var reservedHeaderPrefixes = []string{"X-Scan-", "X-Chunk-", "X-Hist-Scanner-"}
+
+// StaticHeaders returns the headers of Headers by canonical name. Malformed
+// entries, duplicates and headers the scanner sets itself are errors.
+func (c *Config) StaticHeaders() (map[string]string, error) {
+	headers := make(map[string]string, len(c.Headers))
+	for _, h := range c.Headers {
+		name, value, ok := strings.Cut(h, ":")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || !isHeaderName(name) || strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("%q is not \"Name: value\"", h)
+		}
+		name = textproto.CanonicalMIMEHeaderKey(name)
+		reserved := slices.Contains(reservedHeaders, name) || strings.EqualFold(name, c.AuthHeader)
+		for _, prefix := range reservedHeaderPrefixes {
+			reserved = reserved || strings.HasPrefix(name, prefix)
+		}
+		if reserved {
+			return nil, fmt.Errorf("%s is set by the scanner", name)
+		}
+		if _, dup := headers[name]; dup {
+			return nil, fmt.Errorf("%s is given twice", name)
+		}
+		headers[name] = value
+	}
+	return headers, nil
+}
+
+// headerNameRe matches the token characters allowed in a header name
+var headerNameRe = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// isHeaderName reports whether name is a valid HTTP header name
+func isHeaderName(name string) bool {
+	return headerNameRe.MatchString(name)
+}
+
+// Sources returns where Load found settings: "file", "env", "policy" and
+// "discovery", in order of precedence from lowest; defaults apply otherwise
+func (c *Config) Sources() []string {
+	return c.sources
+}
+
 // Validate checks that required configuration is present
 func (c *Config) Validate() error {
-	if c.ServerURL == "" {
+	// Without the http sink, visits can be kept off the server
+	needsServer := len(c.Sinks) == 0 || (c.PayloadFormat != "" && c.PayloadFormat != "visits")
+	for _, spec := range c.Sinks {
+		name, _, err := sink.Parse(spec)
+		if err != nil {
+			return fmt.Errorf("sinks: %w", err)
+		}
+		needsServer = needsServer || name == sink.HTTP
+	}
+	if c.ServerURL == "" && needsServer {
 		return fmt.Errorf("server_url is required")
 	}
-	if c.APIKey == "" {
+	if c.APIKey == "" && needsServer {
 		return fmt.Errorf("api_key is required")
 	}
 	if c.InitialDays < 0 {
@@ -102,12 +645,218 @@ func (c *Config) Validate() error {
 	if c.ChunkSizeKB <= 0 {
 		return fmt.Errorf("chunk_size_kb must be > 0")
 	}
+	if c.UploadConcurrency < 1 || c.UploadConcurrency > 16 {
+		return fmt.Errorf("upload_concurrency must be between 1 and 16")
+	}
+	if c.ResumableUploadKB < 0 {
+		return fmt.Errorf("resumable_upload_kb must be >= 0")
+	}
+	if !isHeaderName(c.AuthHeader) {
+		return fmt.Errorf("auth_header must be a header name")
+	}
+	if c.AuthScheme == "" || strings.ContainsAny(c.AuthScheme, " \t\r\n") {
+		return fmt.Errorf("auth_scheme must be a single word, or none to send the bare key")
+	}
+	if _, err := c.StaticHeaders(); err != nil {
+		return fmt.Errorf("headers: %w", err)
+	}
+	switch c.PayloadFormat {
+	case "", "visits", "aggregates", "both", "auto":
+	default:
+		return fmt.Errorf("payload_format must be visits, aggregates, both or auto")
+	}
+	switch c.PayloadEncoding {
+	case "", "json", "msgpack":
+	default:
+		return fmt.Errorf("payload_encoding must be json or msgpack")
+	}
+	switch c.RunningBrowsers {
+	case "", "scan", "defer", "next-run":
+	default:
+		return fmt.Errorf("running_browsers must be scan, defer or next-run")
+	}
+	switch c.ClockSkew {
+	case "", "off", "annotate", "correct":
+	default:
+		return fmt.Errorf("clock_skew must be off, annotate or correct")
+	}
 	if c.Timeout <= 0 {
 		return fmt.Errorf("timeout must be > 0")
 	}
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		return fmt.Errorf("log_level: %w", err)
+	}
+	if !slices.Contains(logging.Formats, strings.ToLower(c.LogFormat)) {
+		return fmt.Errorf("log_format must be text or json")
+	}
+	if logging.IsSystemTarget(c.LogFile) {
+		if err := logging.ValidateTarget(c.LogFile); err != nil {
+			return fmt.Errorf("log_file: %w", err)
+		}
+	}
+	if c.HomeTimeout <= 0 {
+		return fmt.Errorf("home_timeout must be > 0")
+	}
+	switch c.UserSource {
+	case "", "auto", "passwd", "getent":
+	default:
+		return fmt.Errorf("user_source must be auto, passwd or getent")
+	}
+	if c.HostRoot != "" && !filepath.IsAbs(c.HostRoot) {
+		return fmt.Errorf("host_root must be an absolute path")
+	}
+	switch c.IntegrityCheck {
+	case "", "warn", "enforce", "off":
+	default:
+		return fmt.Errorf("integrity_check must be warn, enforce or off")
+	}
+	if c.HealthURL != "" {
+		if u, err := url.Parse(c.HealthURL); err != nil || (u.Scheme != "" && !category.IsURL(c.HealthURL)) {
+			return fmt.Errorf("health_url must be an http(s) URL or a path on the server")
+		}
+	}
+	if c.CatalogURL != "" {
+		if !category.IsURL(c.CatalogURL) {
+			return fmt.Errorf("catalog_url must be an http(s) URL")
+		}
+		if c.CatalogPublicKey == "" {
+			return fmt.Errorf("catalog_url requires catalog_public_key")
+		}
+	}
+	if c.CatalogPublicKey != "" {
+		if _, err := catalog.ParsePublicKey(c.CatalogPublicKey); err != nil {
+			return fmt.Errorf("catalog_public_key: %w", err)
+		}
+	}
+	if _, err := watchlist.Parse(c.Watchlist); err != nil {
+		return fmt.Errorf("watchlist: %w", err)
+	}
+	if c.WatchlistURL != "" && !category.IsURL(c.WatchlistURL) {
+		return fmt.Errorf("watchlist_url must be an http(s) URL")
+	}
+	if _, err := proxylog.ParseClients(c.ProxyClients); err != nil {
+		return fmt.Errorf("proxy_clients: %w", err)
+	}
+	if _, err := dnslog.ParseSources(c.DNSSources); err != nil {
+		return fmt.Errorf("dns_sources: %w", err)
+	}
+	if c.WebhookURL != "" {
+		if !category.IsURL(c.WebhookURL) {
+			return fmt.Errorf("webhook_url must be an http(s) URL")
+		}
+		if c.WebhookSecret == "" {
+			return fmt.Errorf("webhook_url requires webhook_secret")
+		}
+	}
+	switch c.WebhookOn {
+	case "", "always", "failure":
+	default:
+		return fmt.Errorf("webhook_on must be always or failure")
+	}
+	if c.ControlSocket != "" && c.ControlSocket != "off" && !filepath.IsAbs(c.ControlSocket) {
+		return fmt.Errorf("control_socket must be an absolute path or off")
+	}
+	if c.DomainDays < 0 {
+		return fmt.Errorf("domain_days must be >= 0")
+	}
+	switch c.AITools {
+	case "", "tag", "aggregate", "escalate":
+	default:
+		return fmt.Errorf("ai_tools must be tag, aggregate or escalate")
+	}
+	switch c.DiscoverServices {
+	case "", "off", "catalog", "all":
+	default:
+		return fmt.Errorf("discover_services must be off, catalog or all")
+	}
+	if c.StaleDays < 0 {
+		return fmt.Errorf("stale_days must be >= 0")
+	}
+	if c.OptOutPublicKey != "" {
+		if _, err := optout.ParsePublicKey(c.OptOutPublicKey); err != nil {
+			return fmt.Errorf("optout_public_key: %w", err)
+		}
+	}
+	sources, err := category.ParseSources(c.CategoryLists)
+	if err != nil {
+		return fmt.Errorf("category_lists: %w", err)
+	}
+	for _, name := range c.ExcludeCategories {
+		name = strings.ToLower(strings.TrimSpace(name))
+		// A category without a list may still come from the catalog bundle
+		if _, ok := sources[name]; !ok && !slices.Contains(category.Bundled(), name) && c.CatalogURL == "" {
+			return fmt.Errorf("exclude_categories: %q has no list (bundled: %s; add others in category_lists)", name, strings.Join(category.Bundled(), ", "))
+		}
+	}
+	if c.CatalogFile != "" && !filepath.IsAbs(c.CatalogFile) {
+		return fmt.Errorf("catalog_file must be an absolute path")
+	}
+	for _, p := range c.SignupPatterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("signup_patterns: %w", err)
+		}
+	}
+	if len(c.AllowedSchemes) == 0 {
+		return fmt.Errorf(`allowed_schemes must not be empty (use "*" to send all schemes)`)
+	}
+	for _, d := range c.InternalDomains {
+		if d = strings.TrimSpace(d); d == "" || strings.ContainsAny(d, "/: ") {
+			return fmt.Errorf("internal_domains: %q is not a domain", d)
+		}
+	}
+	if c.MaxCopySizeMB < 0 {
+		return fmt.Errorf("max_copy_size_mb must be >= 0")
+	}
+	if c.QueryTimeout < 0 {
+		return fmt.Errorf("query_timeout must be >= 0")
+	}
+	if c.MaxRows < 0 {
+		return fmt.Errorf("max_rows must be >= 0")
+	}
+	if _, err := c.ErrorSeverities(); err != nil {
+		return fmt.Errorf("error_severity: %w", err)
+	}
+	if err := notice.Validate(c.Notice); err != nil {
+		return fmt.Errorf("notice: %w", err)
+	}
+	if c.DeviceKey != "" && !filepath.IsAbs(c.DeviceKey) {
+		return fmt.Errorf("device_key must be an absolute path")
+	}
+	if c.TempDir != "" && !filepath.IsAbs(c.TempDir) {
+		return fmt.Errorf("temp_dir must be an absolute path")
+	}
+	if c.LogMaxSizeMB < 0 {
+		return fmt.Errorf("log_max_size_mb must be >= 0")
+	}
+	if c.RetentionDays < 0 {
+		return fmt.Errorf("retention_days must be >= 0")
+	}
+	if c.RetentionMaxMB < 0 {
+		return fmt.Errorf("retention_max_mb must be >= 0")
+	}
+	for _, path := range c.RetentionPaths {
+		// Everything under these is deleted, so a root is never accepted
+		if !filepath.IsAbs(path) || filepath.Dir(filepath.Clean(path)) == filepath.Clean(path) {
+			return fmt.Errorf("retention_paths: %q must be an absolute path below the root", path)
+		}
+	}
+	for i, s := range c.Schedules {
+		if s.Interval <= 0 {
+			return fmt.Errorf("schedules[%d].interval must be > 0", i)
+		}
+	}
 	return nil
 }
 
+// LogPath returns the log file path, or "" when logging goes to STDERR, a
+// system log or nowhere
+func (c *Config) LogPath() string {
+	if strings.EqualFold(c.LogFile, "STDERR") || logging.IsSystemTarget(c.LogFile) {
+		return ""
+	}
+	return c.LogFile
+}
+
 // ApplyFlags merges CLI flag values into config (non-empty values override)
 func (c *Config) ApplyFlags(serverURL, apiKey, stateFile, logFile string, initialDays, chunkSizeKB int, compress bool, compressSet bool, timeout time.Duration) {
 	if serverURL != "" {
@@ -146,7 +895,311 @@ type configFile struct {
 	Compress    bool   `yaml:"compress"`
 	StateFile   string `yaml:"state_file,omitempty"`
 	LogFile     string `yaml:"log_file,omitempty"`
+	LogLevel    string `yaml:"log_level,omitempty"`
+	LogFormat   string `yaml:"log_format,omitempty"`
 	Source      string `yaml:"source"`
+
+	AuthHeader string   `yaml:"auth_header,omitempty"`
+	AuthScheme string   `yaml:"auth_scheme,omitempty"`
+	Headers    []string `yaml:"headers,omitempty"`
+
+	PayloadFormat     string   `yaml:"payload_format,omitempty"`
+	PayloadEncoding   string   `yaml:"payload_encoding,omitempty"`
+	ResumableUploadKB int      `yaml:"resumable_upload_kb,omitempty"`
+	UploadConcurrency int      `yaml:"upload_concurrency,omitempty"`
+	Sinks             []string `yaml:"sinks,omitempty"`
+
+	ClockSkew        string `yaml:"clock_skew,omitempty"`
+	FlagFutureVisits bool   `yaml:"flag_future_visits,omitempty"`
+
+	CurrentUserOnly bool `yaml:"current_user_only,omitempty"`
+
+	StateEncryption bool   `yaml:"state_encryption,omitempty"`
+	StateKey        string `yaml:"state_key,omitempty"`
+
+	StatusURL   string `yaml:"status_url,omitempty"`
+	HealthURL   string `yaml:"health_url,omitempty"`
+	ErrorURL    string `yaml:"error_url,omitempty"`
+	EventsURL   string `yaml:"events_url,omitempty"`
+	AuditLog    string `yaml:"audit_log,omitempty"`
+	HomeTimeout string `yaml:"home_timeout,omitempty"`
+
+	WebhookURL    string `yaml:"webhook_url,omitempty"`
+	WebhookSecret string `yaml:"webhook_secret,omitempty"`
+	WebhookOn     string `yaml:"webhook_on,omitempty"`
+	ControlSocket string `yaml:"control_socket,omitempty"`
+
+	SignPayloads bool   `yaml:"sign_payloads,omitempty"`
+	DeviceKey    string `yaml:"device_key,omitempty"`
+
+	IntegrityCheck string `yaml:"integrity_check,omitempty"`
+
+	SanctionedServices []string `yaml:"sanctioned_services,omitempty"`
+	SanctionedURL      string   `yaml:"sanctioned_url,omitempty"`
+	DiscoverServices   string   `yaml:"discover_services,omitempty"`
+	TrackDomains       *bool    `yaml:"track_domains,omitempty"`
+	DomainDays         *int     `yaml:"domain_days,omitempty"`
+	DetectOAuth        *bool    `yaml:"detect_oauth,omitempty"`
+	RiskyExtensions    []string `yaml:"risky_extensions,omitempty"`
+	DetectSignups      *bool    `yaml:"detect_signups,omitempty"`
+	SignupPatterns     []string `yaml:"signup_patterns,omitempty"`
+
+	Watchlist    []string `yaml:"watchlist,omitempty"`
+	WatchlistURL string   `yaml:"watchlist_url,omitempty"`
+	AlertURL     string   `yaml:"alert_url,omitempty"`
+	ProxyClients []string `yaml:"proxy_clients,omitempty"`
+	DNSSources   []string `yaml:"dns_sources,omitempty"`
+
+	TagServices *bool  `yaml:"tag_services,omitempty"`
+	CatalogFile string `yaml:"catalog_file,omitempty"`
+	AITools     string `yaml:"ai_tools,omitempty"`
+
+	CatalogURL       string `yaml:"catalog_url,omitempty"`
+	CatalogPublicKey string `yaml:"catalog_public_key,omitempty"`
+
+	ExcludeCategories []string `yaml:"exclude_categories,omitempty"`
+	CategoryLists     []string `yaml:"category_lists,omitempty"`
+
+	AllowedSchemes       []string `yaml:"allowed_schemes,omitempty"`
+	DropPrivateAddresses bool     `yaml:"drop_private_addresses,omitempty"`
+	InternalDomains      []string `yaml:"internal_domains,omitempty"`
+
+	Notice     []string `yaml:"notice,omitempty"`
+	NoticeText string   `yaml:"notice_text,omitempty"`
+
+	WSLWindowsProfiles *bool `yaml:"wsl_windows_profiles,omitempty"`
+	WSLDistros         bool  `yaml:"wsl_distros,omitempty"`
+
+	ProfileStores bool `yaml:"profile_stores,omitempty"`
+
+	UserSource   string `yaml:"user_source,omitempty"`
+	ScanHomeDirs bool   `yaml:"scan_home_dirs,omitempty"`
+	HostRoot     string `yaml:"host_root,omitempty"`
+
+	SkipDisabledAccounts *bool `yaml:"skip_disabled_accounts,omitempty"`
+	StaleDays            int   `yaml:"stale_days,omitempty"`
+
+	ExcludedUsers   []string `yaml:"excluded_users,omitempty"`
+	OptOutPublicKey string   `yaml:"optout_public_key,omitempty"`
+
+	ShadowCopies  *bool  `yaml:"shadow_copies,omitempty"`
+	MaxCopySizeMB *int   `yaml:"max_copy_size_mb,omitempty"`
+	TempDir       string `yaml:"temp_dir,omitempty"`
+
+	RunningBrowsers string `yaml:"running_browsers,omitempty"`
+
+	ShredTempFiles bool `yaml:"shred_temp_files,omitempty"`
+	EncryptExports bool `yaml:"encrypt_exports,omitempty"`
+
+	LogMaxSizeMB   *int     `yaml:"log_max_size_mb,omitempty"`
+	RetentionDays  *int     `yaml:"retention_days,omitempty"`
+	RetentionMaxMB *int     `yaml:"retention_max_mb,omitempty"`
+	RetentionPaths []string `yaml:"retention_paths,omitempty"`
+
+	QueryTimeout string `yaml:"query_timeout,omitempty"`
+	MaxRows      *int   `yaml:"max_rows,omitempty"`
+
+	ErrorSeverity []string `yaml:"error_severity,omitempty"`
+
+	Schedules []scheduleFile `yaml:"schedules,omitempty"`
+}
+
+// scheduleFile represents a schedules entry in the YAML file
+type scheduleFile struct {
+	Name     string `yaml:"name,omitempty"`
+	Interval string `yaml:"interval"`
+	Full     bool   `yaml:"full,omitempty"`
+}
+
+// LoadFile reads a config file written by SaveToFile, without environment
+// overrides or auto-discovery (used to inspect an installed config)
+func LoadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// Keys missing from the file keep their defaults
+	cf := configFile{Compress: true}
+	if err := yaml.Unmarshal(data, &cf); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.ServerURL = cf.ServerURL
+	cfg.APIKey = cf.APIKey
+	if cf.InitialDays > 0 {
+		cfg.InitialDays = cf.InitialDays
+	}
+	if cf.Timeout != "" {
+		if cfg.Timeout, err = time.ParseDuration(cf.Timeout); err != nil {
+			return nil, fmt.Errorf("invalid timeout %q: %w", cf.Timeout, err)
+		}
+	}
+	if cf.ChunkSizeKB > 0 {
+		cfg.ChunkSizeKB = cf.ChunkSizeKB
+	}
+	cfg.Compress = cf.Compress
+	if cf.AuthHeader != "" {
+		cfg.AuthHeader = cf.AuthHeader
+	}
+	if cf.AuthScheme != "" {
+		cfg.AuthScheme = cf.AuthScheme
+	}
+	cfg.Headers = cf.Headers
+	if cf.PayloadFormat != "" {
+		cfg.PayloadFormat = cf.PayloadFormat
+	}
+	if cf.PayloadEncoding != "" {
+		cfg.PayloadEncoding = cf.PayloadEncoding
+	}
+	cfg.ResumableUploadKB = cf.ResumableUploadKB
+	if cf.UploadConcurrency > 0 {
+		cfg.UploadConcurrency = cf.UploadConcurrency
+	}
+	if cf.ClockSkew != "" {
+		cfg.ClockSkew = cf.ClockSkew
+	}
+	cfg.FlagFutureVisits = cf.FlagFutureVisits
+	if cf.Sinks != nil {
+		cfg.Sinks = cf.Sinks
+	}
+	cfg.StateFile = cf.StateFile
+	cfg.LogFile = cf.LogFile
+	if cf.LogLevel != "" {
+		cfg.LogLevel = cf.LogLevel
+	}
+	if cf.LogFormat != "" {
+		cfg.LogFormat = cf.LogFormat
+	}
+	if cf.Source != "" {
+		cfg.Source = cf.Source
+	}
+	cfg.CurrentUserOnly = cf.CurrentUserOnly
+	cfg.StateEncryption = cf.StateEncryption
+	cfg.StateKey = cf.StateKey
+	cfg.StatusURL = cf.StatusURL
+	cfg.HealthURL = cf.HealthURL
+	cfg.WebhookURL = cf.WebhookURL
+	cfg.WebhookSecret = cf.WebhookSecret
+	if cf.WebhookOn != "" {
+		cfg.WebhookOn = cf.WebhookOn
+	}
+	cfg.ControlSocket = cf.ControlSocket
+	cfg.ErrorURL = cf.ErrorURL
+	cfg.EventsURL = cf.EventsURL
+	cfg.AuditLog = cf.AuditLog
+	cfg.SignPayloads = cf.SignPayloads
+	cfg.DeviceKey = cf.DeviceKey
+	if cf.IntegrityCheck != "" {
+		cfg.IntegrityCheck = cf.IntegrityCheck
+	}
+	cfg.SanctionedServices = cf.SanctionedServices
+	cfg.SanctionedURL = cf.SanctionedURL
+	if cf.DiscoverServices != "" {
+		cfg.DiscoverServices = cf.DiscoverServices
+	}
+	if cf.TrackDomains != nil {
+		cfg.TrackDomains = *cf.TrackDomains
+	}
+	if cf.DomainDays != nil {
+		cfg.DomainDays = *cf.DomainDays
+	}
+	if cf.DetectOAuth != nil {
+		cfg.DetectOAuth = *cf.DetectOAuth
+	}
+	cfg.RiskyExtensions = cf.RiskyExtensions
+	cfg.Watchlist = cf.Watchlist
+	cfg.WatchlistURL = cf.WatchlistURL
+	cfg.AlertURL = cf.AlertURL
+	cfg.ProxyClients = cf.ProxyClients
+	cfg.DNSSources = cf.DNSSources
+	if cf.DetectSignups != nil {
+		cfg.DetectSignups = *cf.DetectSignups
+	}
+	if cf.SignupPatterns != nil {
+		cfg.SignupPatterns = cf.SignupPatterns
+	}
+	if cf.TagServices != nil {
+		cfg.TagServices = *cf.TagServices
+	}
+	cfg.CatalogFile = cf.CatalogFile
+	if cf.AITools != "" {
+		cfg.AITools = cf.AITools
+	}
+	cfg.CatalogURL = cf.CatalogURL
+	cfg.CatalogPublicKey = cf.CatalogPublicKey
+	cfg.ExcludeCategories = cf.ExcludeCategories
+	cfg.CategoryLists = cf.CategoryLists
+	if cf.AllowedSchemes != nil {
+		cfg.AllowedSchemes = cf.AllowedSchemes
+	}
+	cfg.DropPrivateAddresses = cf.DropPrivateAddresses
+	cfg.InternalDomains = cf.InternalDomains
+	cfg.Notice = cf.Notice
+	cfg.NoticeText = cf.NoticeText
+	if cf.WSLWindowsProfiles != nil {
+		cfg.WSLWindowsProfiles = *cf.WSLWindowsProfiles
+	}
+	cfg.WSLDistros = cf.WSLDistros
+	cfg.ProfileStores = cf.ProfileStores
+	if cf.UserSource != "" {
+		cfg.UserSource = cf.UserSource
+	}
+	cfg.ScanHomeDirs = cf.ScanHomeDirs
+	cfg.HostRoot = cf.HostRoot
+	if cf.SkipDisabledAccounts != nil {
+		cfg.SkipDisabledAccounts = *cf.SkipDisabledAccounts
+	}
+	cfg.StaleDays = cf.StaleDays
+	cfg.ExcludedUsers = cf.ExcludedUsers
+	cfg.OptOutPublicKey = cf.OptOutPublicKey
+	if cf.ShadowCopies != nil {
+		cfg.ShadowCopies = *cf.ShadowCopies
+	}
+	if cf.MaxCopySizeMB != nil {
+		cfg.MaxCopySizeMB = *cf.MaxCopySizeMB
+	}
+	if cf.RunningBrowsers != "" {
+		cfg.RunningBrowsers = cf.RunningBrowsers
+	}
+	cfg.TempDir = cf.TempDir
+	if cf.LogMaxSizeMB != nil {
+		cfg.LogMaxSizeMB = *cf.LogMaxSizeMB
+	}
+	if cf.RetentionDays != nil {
+		cfg.RetentionDays = *cf.RetentionDays
+	}
+	if cf.RetentionMaxMB != nil {
+		cfg.RetentionMaxMB = *cf.RetentionMaxMB
+	}
+	cfg.RetentionPaths = cf.RetentionPaths
+	cfg.ShredTempFiles = cf.ShredTempFiles
+	cfg.EncryptExports = cf.EncryptExports
+	if cf.QueryTimeout != "" {
+		if cfg.QueryTimeout, err = time.ParseDuration(cf.QueryTimeout); err != nil {
+			return nil, fmt.Errorf("invalid query_timeout %q: %w", cf.QueryTimeout, err)
+		}
+	}
+	if cf.MaxRows != nil {
+		cfg.MaxRows = *cf.MaxRows
+	}
+	cfg.ErrorSeverity = cf.ErrorSeverity
+	if cf.HomeTimeout != "" {
+		if cfg.HomeTimeout, err = time.ParseDuration(cf.HomeTimeout); err != nil {
+			return nil, fmt.Errorf("invalid home_timeout %q: %w", cf.HomeTimeout, err)
+		}
+	}
+
+	for _, sf := range cf.Schedules {
+		interval, err := time.ParseDuration(sf.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule interval %q: %w", sf.Interval, err)
+		}
+		cfg.Schedules = append(cfg.Schedules, Schedule{Name: sf.Name, Interval: interval, Full: sf.Full})
+	}
+
+	return cfg, nil
 }
 
 // SaveToFile writes the configuration to a YAML file
@@ -167,6 +1220,154 @@ func (c *Config) SaveToFile(path string) error {
 		StateFile:   c.StateFile,
 		LogFile:     c.LogFile,
 		Source:      c.Source,
+
+		Headers:           c.Headers,
+		PayloadFormat:     c.PayloadFormat,
+		PayloadEncoding:   c.PayloadEncoding,
+		ResumableUploadKB: c.ResumableUploadKB,
+		UploadConcurrency: c.UploadConcurrency,
+
+		ClockSkew:        c.ClockSkew,
+		FlagFutureVisits: c.FlagFutureVisits,
+
+		CurrentUserOnly: c.CurrentUserOnly,
+
+		StateEncryption: c.StateEncryption,
+		StateKey:        c.StateKey,
+
+		StatusURL:   c.StatusURL,
+		HealthURL:   c.HealthURL,
+		ErrorURL:    c.ErrorURL,
+		EventsURL:   c.EventsURL,
+		AuditLog:    c.AuditLog,
+		HomeTimeout: c.HomeTimeout.String(),
+
+		WebhookURL:    c.WebhookURL,
+		WebhookSecret: c.WebhookSecret,
+		WebhookOn:     c.WebhookOn,
+		ControlSocket: c.ControlSocket,
+
+		SignPayloads: c.SignPayloads,
+		DeviceKey:    c.DeviceKey,
+
+		IntegrityCheck: c.IntegrityCheck,
+
+		SanctionedServices: c.SanctionedServices,
+		SanctionedURL:      c.SanctionedURL,
+		DiscoverServices:   c.DiscoverServices,
+		RiskyExtensions:    c.RiskyExtensions,
+
+		Watchlist:    c.Watchlist,
+		WatchlistURL: c.WatchlistURL,
+		AlertURL:     c.AlertURL,
+		ProxyClients: c.ProxyClients,
+		DNSSources:   c.DNSSources,
+
+		CatalogFile: c.CatalogFile,
+		AITools:     c.AITools,
+
+		CatalogURL:       c.CatalogURL,
+		CatalogPublicKey: c.CatalogPublicKey,
+
+		ExcludeCategories: c.ExcludeCategories,
+		CategoryLists:     c.CategoryLists,
+
+		AllowedSchemes:       c.AllowedSchemes,
+		DropPrivateAddresses: c.DropPrivateAddresses,
+		InternalDomains:      c.InternalDomains,
+
+		Notice:     c.Notice,
+		NoticeText: c.NoticeText,
+
+		WSLDistros: c.WSLDistros,
+
+		ProfileStores: c.ProfileStores,
+
+		UserSource:   c.UserSource,
+		ScanHomeDirs: c.ScanHomeDirs,
+		HostRoot:     c.HostRoot,
+
+		StaleDays: c.StaleDays,
+
+		ExcludedUsers:   c.ExcludedUsers,
+		OptOutPublicKey: c.OptOutPublicKey,
+
+		TempDir: c.TempDir,
+
+		ShredTempFiles: c.ShredTempFiles,
+		EncryptExports: c.EncryptExports,
+
+		RetentionPaths: c.RetentionPaths,
+	}
+
+	// Only write the non-default values
+	if c.LogLevel != DefaultConfig().LogLevel {
+		cf.LogLevel = c.LogLevel
+	}
+	if c.LogFormat != DefaultConfig().LogFormat {
+		cf.LogFormat = c.LogFormat
+	}
+	if !c.WSLWindowsProfiles {
+		cf.WSLWindowsProfiles = &c.WSLWindowsProfiles
+	}
+	if !c.SkipDisabledAccounts {
+		cf.SkipDisabledAccounts = &c.SkipDisabledAccounts
+	}
+	if !c.TrackDomains {
+		cf.TrackDomains = &c.TrackDomains
+	}
+	if c.DomainDays != DefaultConfig().DomainDays {
+		cf.DomainDays = &c.DomainDays
+	}
+	if !c.DetectOAuth {
+		cf.DetectOAuth = &c.DetectOAuth
+	}
+	if !c.DetectSignups {
+		cf.DetectSignups = &c.DetectSignups
+	}
+	if !slices.Equal(c.SignupPatterns, DefaultConfig().SignupPatterns) {
+		cf.SignupPatterns = c.SignupPatterns
+	}
+	if !slices.Equal(c.Sinks, DefaultConfig().Sinks) {
+		cf.Sinks = c.Sinks
+	}
+	if c.AuthHeader != DefaultConfig().AuthHeader {
+		cf.AuthHeader = c.AuthHeader
+	}
+	if c.AuthScheme != DefaultConfig().AuthScheme {
+		cf.AuthScheme = c.AuthScheme
+	}
+	if !c.TagServices {
+		cf.TagServices = &c.TagServices
+	}
+	if !c.ShadowCopies {
+		cf.ShadowCopies = &c.ShadowCopies
+	}
+	if c.MaxCopySizeMB != DefaultConfig().MaxCopySizeMB {
+		cf.MaxCopySizeMB = &c.MaxCopySizeMB
+	}
+	if c.RunningBrowsers != DefaultConfig().RunningBrowsers {
+		cf.RunningBrowsers = c.RunningBrowsers
+	}
+	if c.LogMaxSizeMB != DefaultConfig().LogMaxSizeMB {
+		cf.LogMaxSizeMB = &c.LogMaxSizeMB
+	}
+	if c.RetentionDays != DefaultConfig().RetentionDays {
+		cf.RetentionDays = &c.RetentionDays
+	}
+	if c.RetentionMaxMB != DefaultConfig().RetentionMaxMB {
+		cf.RetentionMaxMB = &c.RetentionMaxMB
+	}
+	if c.QueryTimeout != DefaultConfig().QueryTimeout {
+		cf.QueryTimeout = c.QueryTimeout.String()
+	}
+	if c.MaxRows != DefaultConfig().MaxRows {
+		cf.MaxRows = &c.MaxRows
+	}
+	cf.ErrorSeverity = c.ErrorSeverity
+
+	for _, s := range c.Schedules {
+		cf.Schedules = append(cf.Schedules, scheduleFile{Name: s.Name, Interval: s.Interval.String(), Full: s.Full})
 	}
 
 	data, err := yaml.Marshal(cf)
@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"hist_scanner/internal/buildinfo"
 )
 
 // Discovery configuration constants
@@ -51,9 +53,7 @@ type DiscoveryResult struct {
 // The discovery server must be accessible at http://binadox.config:3000
 // and return a JSON response with "url" and "token" fields.
 func Discover() *DiscoveryResult {
-	client := &http.Client{
-		Timeout: DiscoveryTimeout,
-	}
+	client := buildinfo.HTTPClient(DiscoveryTimeout)
 
 	resp, err := client.Get(DiscoveryURL)
 	if err != nil {
@@ -0,0 +1,145 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PolicyRegistryKey is the HKLM key Group Policy writes scanner settings to (Windows)
+const PolicyRegistryKey = `SOFTWARE\Policies\Binadox\hist_scanner`
+
+// PolicyKind is the registry value type of a policy setting
+type PolicyKind int
+
+const (
+	PolicyString PolicyKind = iota // REG_SZ
+	PolicyNumber                   // REG_DWORD
+	PolicyBool                     // REG_DWORD, 0 or 1
+)
+
+// PolicyKey describes a config key that can be set by Group Policy.
+// The registry value name is the config key name.
+type PolicyKey struct {
+	Name        string
+	Kind        PolicyKind
+	DisplayName string
+	Description string
+}
+
+// PolicyKeys lists every config key that can be managed by Group Policy
+var PolicyKeys = []PolicyKey{
+	{"server_url", PolicyString, "Server URL", "Server endpoint the scanner sends browser history to."},
+	{"api_key", PolicyString, "API key", "API key used to authenticate with the server. Policy values are readable by all local users."},
+	{"initial_days", PolicyNumber, "Initial days", "Days of history collected on the first scan of a profile."},
+	{"timeout", PolicyString, "HTTP timeout", "HTTP request timeout as a duration, e.g. 30s."},
+	{"chunk_size_kb", PolicyNumber, "Chunk size (KB)", "Maximum compressed size of one upload chunk in kilobytes."},
+	{"compress", PolicyBool, "Compress uploads", "Compress uploads with gzip."},
+	{"auth_header", PolicyString, "Authorization header", "Header carrying the API key (default: Authorization)."},
+	{"auth_scheme", PolicyString, "Authorization scheme", "Scheme written before the API key, e.g. Bearer (default: ProxyToken), or none to send the bare key."},
+	{"headers", PolicyString, "Custom headers", "Comma-separated \"Name: value\" headers added to every request to the server, e.g. X-Tenant-Id: acme."},
+	{"sinks", PolicyString, "Sinks", "Comma-separated destinations of the visits: http (server_url, the default), file:<path> (a JSON Lines file) and syslog or syslog:udp://host:514."},
+	{"payload_format", PolicyString, "Payload format", "What is uploaded: visits (each visit), aggregates (visit counts per domain), both, or auto (aggregates if the server accepts them, visits otherwise)."},
+	{"payload_encoding", PolicyString, "Payload encoding", "Encoding of visit uploads: json or msgpack (MessagePack, smaller; servers that reject it get JSON)."},
+	{"resumable_upload_kb", PolicyNumber, "Resumable uploads (KB)", "Chunks of at least this many kilobytes are uploaded in resumable sessions that survive connection resets; 0 disables them."},
+	{"upload_concurrency", PolicyNumber, "Upload concurrency", "Chunks uploaded to the server at once, 1 to 16 (default: 1)."},
+	{"clock_skew", PolicyString, "Clock skew", "Measure the device clock against the server's: annotate adds the skew to uploads (default), correct also shifts visit timestamps to the server's clock when they differ by more than two minutes, off does neither."},
+	{"flag_future_visits", PolicyBool, "Flag future visits", "Mark visits timestamped later than the server's time."},
+	{"state_file", PolicyString, "State file", "Path to the state file holding scan watermarks."},
+	{"log_file", PolicyString, "Log file", "Path to the log file, or STDERR, SYSLOG (Linux, macOS) or EVENTLOG (Windows)."},
+	{"log_level", PolicyString, "Log level", "Minimum level of logged messages: debug, info, warn or error."},
+	{"log_format", PolicyString, "Log format", "Log record format: text or json."},
+	{"source", PolicyString, "Source", "Source identifier sent with each upload."},
+	{"current_user_only", PolicyBool, "Scan current user only", "Scan only the user running the scanner instead of all users."},
+	{"state_encryption", PolicyBool, "Encrypt state file", "Encrypt the state file with AES-GCM."},
+	{"state_key", PolicyString, "State encryption secret", "Secret the state encryption key is derived from (default: machine ID)."},
+	{"home_timeout", PolicyString, "Home directory timeout", "How long a home directory on a network share may take to respond before the user is skipped, e.g. 10s."},
+	{"wsl_windows_profiles", PolicyBool, "Scan Windows profiles from WSL", "When the Linux scanner runs inside WSL, also scan the Windows profiles under /mnt/c/Users (default: enabled)."},
+	{"wsl_distros", PolicyBool, "Scan WSL distributions", "Also scan browsers installed inside WSL distributions of signed-in users. Accessing a distribution starts it."},
+	{"profile_stores", PolicyBool, "Scan profile stores", "Also scan signed-out users from FSLogix profile containers (attached read-only while scanned) and the Citrix UPM user store."},
+	{"user_source", PolicyString, "User source", "Account database on Linux and FreeBSD: auto (getent if available, the default), passwd or getent."},
+	{"scan_home_dirs", PolicyBool, "Scan unlisted home directories", "Also scan /home directories of accounts the user source does not list, e.g. SSSD domain users without enumeration (Linux, FreeBSD)."},
+	{"host_root", PolicyString, "Host root", "Absolute path the host's filesystem or Users directory is mounted at when the scanner runs in a container, e.g. C:\\host. Users and the device are read from it."},
+	{"skip_disabled_accounts", PolicyBool, "Skip disabled accounts", "Skip users whose local account is disabled, locked or expired."},
+	{"stale_days", PolicyNumber, "Stale account days", "Skip users whose browser history has not changed in this many days. 0 scans all users."},
+	{"excluded_users", PolicyString, "Excluded users", "Comma-separated users who are never scanned. They are reported as excluded by policy."},
+	{"optout_public_key", PolicyString, "Opt-out public key", "Base64 Ed25519 public key that verifies signed opt-out markers in users' home directories. Empty ignores markers."},
+	{"shadow_copies", PolicyBool, "Read locked databases from shadow copies", "Read history databases locked by a running browser from a Volume Shadow Copy snapshot, deleted after each scan."},
+	{"running_browsers", PolicyString, "Running browsers", "How profiles of a browser the user is running are scanned: defer (after the other profiles, default), next-run (left to the next run while it runs, at most three runs in a row) or scan (in turn)."},
+	{"max_copy_size_mb", PolicyNumber, "Maximum database copy size (MB)", "Largest locked history database copied to a temp file for reading. Larger ones are skipped. 0 means no limit."},
+	{"temp_dir", PolicyString, "Temp directory for database copies", "Directory for copies of locked history databases, created readable only by the scanner. Empty uses a directory in the system temp dir."},
+	{"shred_temp_files", PolicyBool, "Shred database copies", "Overwrite copies of history databases before they are removed."},
+	{"encrypt_exports", PolicyBool, "Encrypt exports", "Encrypt files written by the export command with AES-GCM, using a key derived from state_key or bound to this machine."},
+	{"log_max_size_mb", PolicyNumber, "Maximum log size (MB)", "Size at which the log file is rotated at the start of a scan. 0 never rotates."},
+	{"retention_days", PolicyNumber, "Retention (days)", "Days rotated logs and files under the retention paths are kept. 0 keeps them regardless of age."},
+	{"retention_max_mb", PolicyNumber, "Retention size (MB)", "Total size of rotated logs, and of the files under each retention path, beyond which the oldest are removed. 0 means no limit."},
+	{"retention_paths", PolicyString, "Retention paths", "Comma-separated absolute directories (spool, exports) whose files are removed by the retention limits."},
+	{"query_timeout", PolicyString, "History query timeout", "How long SQLite may work on one history query before it is interrupted, e.g. 2m. 0 means no limit."},
+	{"max_rows", PolicyNumber, "Maximum history rows per profile", "History rows read from one profile per run; the rest is read on the next run. 0 means no limit."},
+	{"error_severity", PolicyString, "Error severities", "Comma-separated category=severity entries setting whether profiles failing with an error category fail the run (error, the default) or are only reported (warning), e.g. locked=warning. Categories: permission, locked, schema, corrupt, timeout, copy_refused, send, read."},
+	{"notice", PolicyString, "User notice", "Comma-separated ways users are told that browsing is audited: page (local HTML disclosure page), login (login message, Linux)."},
+	{"notice_text", PolicyString, "User notice text", "Disclosure text of the user notice. Empty uses the built-in text."},
+	{"exclude_categories", PolicyString, "Excluded site categories", "Comma-separated categories whose visits are dropped before anything is sent: health, banking, unions, adult, or names added in category_lists."},
+	{"category_lists", PolicyString, "Category lists", "Comma-separated name=source entries replacing or adding category lists; source is an http(s) URL or absolute path of a hosts, adblock or plain domain list."},
+	{"allowed_schemes", PolicyString, "Allowed URL schemes", "Comma-separated URL schemes whose visits are sent, e.g. http,https (the default); * sends all. Visits to file:, about:, chrome-extension: and other schemes are dropped."},
+	{"drop_private_addresses", PolicyBool, "Drop private addresses", "Drop visits to loopback, private (RFC 1918) and link-local addresses and single-label intranet hosts before anything is sent."},
+	{"internal_domains", PolicyString, "Internal domains", "Comma-separated internal domains whose visits (subdomains included) are dropped before anything is sent."},
+	{"sanctioned_services", PolicyString, "Sanctioned SaaS services", "Comma-separated approved SaaS services, by catalog name or domain, e.g. Slack,zoom.us. The report command lists usage of all other services."},
+	{"sanctioned_url", PolicyString, "Sanctioned services URL", "Endpoint returning a JSON array of further approved services, by catalog name or domain, merged with sanctioned_services."},
+	{"discover_services", PolicyString, "Discover new services", "Report each unsanctioned site the first time it is visited on the device to events_url: off, catalog (SaaS catalog services only) or all (every registrable domain)."},
+	{"track_domains", PolicyBool, "Track visited domains", "Record when each site (registrable domain) was first and last visited on the device and list the sites new to it in run reports."},
+	{"domain_days", PolicyNumber, "Domain tracking (days)", "Days after their last visit that tracked sites are forgotten. 0 keeps them."},
+	{"risky_extensions", PolicyString, "Risky extensions", "Comma-separated browser extension ids rated high risk by the extensions command regardless of their permissions."},
+	{"detect_oauth", PolicyBool, "Detect OAuth grants", "Report the first visit of each user to an OAuth consent page of an app (a third-party app requesting access to the user's account) to events_url."},
+	{"detect_signups", PolicyBool, "Detect sign-ups", "Report the first visit of each user to a sign-up or registration page of an unsanctioned site to events_url."},
+	{"signup_patterns", PolicyString, "Sign-up patterns", "Comma-separated regular expressions matched against the lower-case URL path of sign-up and registration pages."},
+	{"proxy_clients", PolicyString, "Proxy client users", "Comma-separated ADDRESS=user or CIDR=user entries naming the users of proxy log requests without an authenticated user; other clients are sent by IP address."},
+	{"dns_sources", PolicyString, "DNS sources", "Comma-separated DNS sources read after each scan: windows-cache, systemd-resolved or unbound:/path/to/log. Their domain events are marked as lower-fidelity than browser history."},
+	{"watchlist", PolicyString, "Watchlist", "Comma-separated domains (subdomains included) and host/path patterns, e.g. *.ngrok.io or drive.example.com/share/*, whose visits raise a high-priority alert as soon as they are scanned."},
+	{"watchlist_url", PolicyString, "Watchlist URL", "Endpoint returning a JSON array of further watchlist entries; the last download is used while it is unreachable."},
+	{"alert_url", PolicyString, "Alert URL", "Endpoint that receives watchlist alerts. Empty sends them to events_url."},
+	{"tag_services", PolicyBool, "Tag visits with SaaS services", "Tag each sent visit with the SaaS service, category and risk of its site from the catalog."},
+	{"catalog_url", PolicyString, "Catalog bundle URL", "Server or CDN URL of a signed catalog bundle updating the SaaS catalog and category lists; checked at most daily and cached."},
+	{"catalog_public_key", PolicyString, "Catalog public key", "Base64 Ed25519 public key that verifies catalog_url bundles, from \"hist_scanner catalog keygen\"."},
+	{"ai_tools", PolicyString, "AI tool visits", "Handling of visits to generative-AI services: tag (only tag them), aggregate (also report visits per user and scan) or escalate (also report each user's first use of every AI tool as a high-priority event)."},
+	{"catalog_file", PolicyString, "Catalog file", "Absolute path of a JSON catalog whose services are added to the built-in SaaS catalog or replace those with the same name."},
+	{"webhook_url", PolicyString, "Webhook URL", "Endpoint that receives an HMAC-signed scan summary (counts, errors, duration) when a scan finishes, e.g. for SOAR or ticketing."},
+	{"webhook_secret", PolicyString, "Webhook secret", "Shared secret of the HMAC-SHA256 webhook signature. Policy values are readable by all local users."},
+	{"webhook_on", PolicyString, "Webhook trigger", "When the webhook is posted: always, or failure (scans with a non-zero exit code)."},
+	{"control_socket", PolicyString, "Daemon control socket", "Absolute path of the Unix domain socket of the daemon's control API (hist_scanner ctl), or off. Empty places control.sock next to the state file."},
+	{"status_url", PolicyString, "Status URL", "Endpoint that receives a run report (exit code, counts, errors) after each scan."},
+	{"health_url", PolicyString, "Health URL", "Health endpoint the status command checks the server with: an http(s) URL or a path on the server, e.g. /healthz (default: a HEAD request to the upload endpoint)."},
+	{"audit_log", PolicyString, "Audit log", "Path of the hash-chained log recording every chunk sent to the server. Empty disables it."},
+	{"sign_payloads", PolicyBool, "Sign uploads with a device key", "Sign every upload with a per-device Ed25519 key registered with the server, so submissions with a leaked API key can be told apart."},
+	{"device_key", PolicyString, "Device key file", "Path of the device signing key. Empty uses device.key next to the state file."},
+	{"integrity_check", PolicyString, "Binary integrity check", "Check the installed binary against the hash and build recorded at install before each scan: warn, enforce (refuse to scan) or off."},
+	{"events_url", PolicyString, "Events URL", "Endpoint that receives an event (no URLs) when a profile's browsing history was cleared or reduced between scans."},
+	{"error_url", PolicyString, "Error URL", "Endpoint that receives an error event (no history data) when a scan crashes or fails completely."},
+	{"schedules", PolicyString, "Schedules", "Comma-separated name=interval scheduler entries created by install, e.g. incremental=1h,full=168h:full; a :full suffix runs a full rescan. Empty creates one entry at the install interval."},
+}
+
+// parsePolicySchedules parses the schedules policy value: comma-separated
+// name=interval entries, with a :full suffix for full rescans
+func parsePolicySchedules(value string) ([]Schedule, error) {
+	var schedules []Schedule
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, interval, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("schedule %q must be name=interval", entry)
+		}
+		interval, full := strings.CutSuffix(strings.TrimSpace(interval), ":full")
+		d, err := time.ParseDuration(interval)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", entry, err)
+		}
+		schedules = append(schedules, Schedule{Name: strings.TrimSpace(name), Interval: d, Full: full})
+	}
+	return schedules, nil
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package config
+
+// loadPolicy returns nil: Group Policy is only available on Windows
+func loadPolicy() map[string]interface{} {
+	return nil
+}
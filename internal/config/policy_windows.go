@@ -0,0 +1,39 @@
+//go:build windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package config
+
+import (
+	"golang.org/x/sys/windows/registry"
+)
+
+// loadPolicy reads config values set by Group Policy under HKLM
+func loadPolicy() map[string]interface{} {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, PolicyRegistryKey, registry.QUERY_VALUE)
+	if err != nil {
+		// No policy configured
+		return nil
+	}
+	defer k.Close()
+
+	values := make(map[string]interface{})
+	for _, key := range PolicyKeys {
+		switch key.Kind {
+		case PolicyString:
+			if v, _, err := k.GetStringValue(key.Name); err == nil {
+				values[key.Name] = v
+			}
+		case PolicyNumber:
+			if v, _, err := k.GetIntegerValue(key.Name); err == nil {
+				values[key.Name] = int(v)
+			}
+		case PolicyBool:
+			if v, _, err := k.GetIntegerValue(key.Name); err == nil {
+				values[key.Name] = v != 0
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package control is the local control API of the daemon: a Unix domain
+// socket, readable by its owner only, that takes one JSON request per
+// connection and answers with one JSON response. Windows 10 1803 and later
+// support Unix domain sockets, so the same transport is used there.
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"hist_scanner/internal/platform"
+)
+
+// SocketName is the socket file name next to the state file
+const SocketName = "control.sock"
+
+// Commands of the control API
+const (
+	CommandScan   = "scan"   // Start a scan now
+	CommandStatus = "status" // Report the daemon state
+	CommandReload = "reload" // Reload the configuration
+	CommandFlush  = "flush"  // Send everything not sent yet, waiting for it
+)
+
+// Commands lists the commands in the order they are documented
+var Commands = []string{CommandScan, CommandStatus, CommandReload, CommandFlush}
+
+// requestTimeout bounds reading a request and writing its response
+const requestTimeout = 10 * time.Second
+
+// Request is a control command
+type Request struct {
+	Command string `json:"command"`
+}
+
+// Response is the answer to a command
+type Response struct {
+	OK      bool    `json:"ok"`
+	Message string  `json:"message,omitempty"`
+	Status  *Status `json:"status,omitempty"`
+	Run     *Run    `json:"run,omitempty"` // The scan run by flush
+}
+
+// Status is the state of the daemon
+type Status struct {
+	PID        int       `json:"pid"`
+	Version    string    `json:"version"`
+	Started    time.Time `json:"started"`
+	ConfigFile string    `json:"configFile,omitempty"`
+	Reloaded   time.Time `json:"reloaded,omitzero"`
+	Interval   string    `json:"interval"`
+	Scanning   bool      `json:"scanning"`
+	Queued     bool      `json:"queued"` // A requested scan waits for the running one
+	NextScan   time.Time `json:"nextScan,omitzero"`
+	LastRun    *Run      `json:"lastRun,omitempty"`
+}
+
+// Run summarizes a scan of the daemon
+type Run struct {
+	Started         time.Time `json:"started"`
+	DurationMS      int64     `json:"durationMs"`
+	ExitCode        int       `json:"exitCode"`
+	UsersScanned    int       `json:"usersScanned"`
+	ProfilesScanned int       `json:"profilesScanned"`
+	EntriesSent     int       `json:"entriesSent"`
+	Errors          []string  `json:"errors,omitempty"`
+
+	ErrorCategories map[string]int `json:"errorCategories,omitempty"` // Profile errors per category
+}
+
+// Handler executes a command. ctx is canceled when the daemon stops.
+type Handler func(ctx context.Context, command string) Response
+
+// DefaultPath returns the socket path for a state directory
+func DefaultPath(stateDir string) string {
+	return filepath.Join(stateDir, SocketName)
+}
+
+// Listen creates the socket, replacing one left by a daemon that did not
+// stop cleanly. A socket another daemon still answers on is not replaced.
+func Listen(path string) (net.Listener, error) {
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("another daemon is listening on %s", path)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	// Commands are only for the daemon's account (and root/Administrators);
+	// on Windows the socket file gets an explicit DACL
+	if err := platform.RestrictFile(path); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("failed to restrict socket: %w", err)
+	}
+	return l, nil
+}
+
+// Serve answers requests on l until ctx is canceled, then closes l. Each
+// connection is handled in its own goroutine, so a flush waiting for its
+// scan does not block status requests.
+func Serve(ctx context.Context, l net.Listener, h Handler) {
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		go serveConn(ctx, conn, h)
+	}
+}
+
+// serveConn answers the request of one connection
+func serveConn(ctx context.Context, conn net.Conn, h Handler) {
+	defer conn.Close()
+
+	var req Request
+	conn.SetReadDeadline(time.Now().Add(requestTimeout))
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		writeResponse(conn, Response{Message: fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+
+	// Stop a handler waiting for a scan if the client hung up
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	conn.SetReadDeadline(time.Time{})
+	go func() {
+		var b [1]byte
+		conn.Read(b[:])
+		cancel()
+	}()
+
+	var resp Response
+	if !slices.Contains(Commands, req.Command) {
+		resp = Response{Message: fmt.Sprintf("unknown command %q", req.Command)}
+	} else {
+		resp = h(ctx, req.Command)
+	}
+	writeResponse(conn, resp)
+}
+
+// writeResponse sends a response, ignoring clients that went away
+func writeResponse(conn net.Conn, resp Response) {
+	conn.SetWriteDeadline(time.Now().Add(requestTimeout))
+	json.NewEncoder(conn).Encode(resp)
+}
+
+// Call sends a command to the daemon listening on path and returns its
+// response. timeout bounds the whole exchange; 0 waits as long as the
+// command runs (flush waits for its scan).
+func Call(path, command string, timeout time.Duration) (*Response, error) {
+	conn, err := net.DialTimeout("unix", path, 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the daemon on %s: %w", path, err)
+	}
+	defer conn.Close()
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+
+	if err := json.NewEncoder(conn).Encode(Request{Command: command}); err != nil {
+		return nil, fmt.Errorf("failed to send command: %w", err)
+	}
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package db
+
+import (
+	"crypto/rand"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// shredBlockSize is the size of the random block written over shredded files
+const shredBlockSize = 64 << 10
+
+// shredFile overwrites a file with random data and flushes it to disk, so a
+// removed copy cannot be read back from the freed blocks. SSDs and
+// copy-on-write filesystems may still keep the old blocks.
+func shredFile(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return err
+	}
+	block := make([]byte, shredBlockSize)
+	rand.Read(block)
+	for left := info.Size(); left > 0; left -= int64(len(block)) {
+		if left < int64(len(block)) {
+			block = block[:left]
+		}
+		if _, err := f.Write(block); err != nil {
+			return err
+		}
+	}
+	return f.Sync()
+}
+
+// removeFile removes a temp file, shredding it first if configured
+func removeFile(path string) {
+	if copyOptions.Shred {
+		shredFile(path)
+	}
+	os.Remove(path)
+}
+
+// removeAll removes a temp directory, shredding its files first if configured
+func removeAll(dir string) {
+	if copyOptions.Shred {
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && d.Type().IsRegular() {
+				shredFile(path)
+			}
+			return nil
+		})
+	}
+	os.RemoveAll(dir)
+}
@@ -4,15 +4,90 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
-	_ "modernc.org/sqlite"
+	"modernc.org/sqlite"
+
+	"hist_scanner/internal/platform"
+)
+
+const (
+	backupPagesPerStep = 1024                   // Pages copied per backup step
+	backupRetries      = 5                      // Busy retries before the backup gives up
+	backupRetryDelay   = 200 * time.Millisecond // Wait between busy retries
+	copyAttempts       = 3                      // Raw copies tried until one is consistent
+	salvageMaxQueries  = 100000                 // Range queries before salvage gives up
+	minFreeAfterCopy   = 256 << 20              // Free space left on a filesystem after a copy
+	busyTimeoutMs      = 5000                   // How long a query waits for a browser's write lock
+)
+
+// errNeedsCopy is returned for databases that cannot be opened in place
+// without creating files in the profile directory
+var errNeedsCopy = errors.New("database must be copied to be read without side effects")
+
+// Errors returned when a locked database is not copied
+var (
+	ErrTooLarge = errors.New("database exceeds the maximum copy size")
+	ErrNoSpace  = errors.New("not enough free space for a database copy")
 )
 
+// Errors returned when a query exceeds the QueryOptions limits
+var (
+	ErrQueryTimeout = errors.New("query timed out")
+	ErrTooManyRows  = errors.New("query returned too many rows")
+)
+
+// QueryOptions limits the queries run on databases
+type QueryOptions struct {
+	Timeout time.Duration // Time SQLite may spend on one query, not counting row callbacks; 0 means no limit
+	MaxRows int           // Rows one ForEachRow or ForEachPage call reads before ErrTooManyRows; 0 means no limit
+}
+
+// queryOptions are the options set with SetQueryOptions
+var queryOptions QueryOptions
+
+// SetQueryOptions sets the query timeout and row limit
+func SetQueryOptions(opts QueryOptions) {
+	queryOptions = opts
+}
+
+// CopyOptions limits the temp copies made of locked databases
+type CopyOptions struct {
+	Dirs    []string // Candidate directories in order of preference; empty means DefaultTempDir
+	MaxSize int64    // Largest database (with its WAL) that is copied, in bytes; 0 means no limit
+	Shred   bool     // Overwrite copies before removing them
+}
+
+// copyOptions are the options set with SetCopyOptions
+var copyOptions CopyOptions
+
+// SetCopyOptions sets where and up to which size locked databases are copied
+func SetCopyOptions(opts CopyOptions) {
+	copyOptions = opts
+}
+
+// shadowCopies enables reading locked databases from a Volume Shadow Copy
+// snapshot (Windows)
+var shadowCopies bool
+
+// SetShadowCopies enables or disables the Volume Shadow Copy fallback
+func SetShadowCopies(enabled bool) {
+	shadowCopies = enabled
+}
+
+// backuper is implemented by modernc sqlite driver connections
+type backuper interface {
+	NewBackup(dstURI string) (*sqlite.Backup, error)
+}
+
 // DB wraps a SQLite database connection with WAL mode support and copy fallback
 type DB struct {
 	db       *sql.DB
@@ -20,61 +95,370 @@ type DB struct {
 	tempCopy string // non-empty if we're using a temp copy
 }
 
-// Open opens a SQLite database, trying WAL mode first, then falling back to copy
+// Open opens a SQLite database read-only without ever writing to the
+// profile directory (see openSource). If the database cannot be read in
+// place (locked by the browser), a consistent snapshot is taken with the
+// online backup API; if the browser holds an exclusive lock, the files are
+// copied and the copy is verified. On Windows, where the lock also blocks
+// copying, the files are copied from a shadow copy of the volume.
 func Open(dbPath string) (*DB, error) {
-	// First try to open directly with WAL mode
-	db, err := openWithWAL(dbPath)
+	started := time.Now()
+	defer func() { openNanos.Add(int64(time.Since(started))) }()
+
+	// First try to read the database in place
+	db, err := openSource(dbPath)
 	if err == nil {
-		return &DB{db: db, path: dbPath}, nil
+		if err = checkReadable(db); err == nil {
+			return &DB{db: db, path: dbPath}, nil
+		}
+		db.Close()
 	}
 
-	// If that failed (likely locked), copy to temp and open the copy
-	tempPath, err := copyToTemp(dbPath)
+	// If that failed (likely locked), snapshot or copy to temp and open that
+	tempPath, err := snapshotToTemp(dbPath)
+	if copyRefused(err) {
+		return nil, err
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to copy database to temp: %w", err)
+		var copyErr error
+		if tempPath, copyErr = copyToTemp(dbPath); copyRefused(copyErr) {
+			return nil, copyErr
+		} else if copyErr != nil {
+			err = fmt.Errorf("failed to snapshot database: %w; failed to copy database to temp: %w", err, copyErr)
+			if !shadowCopies || platform.CurrentOS() != platform.Windows {
+				return nil, err
+			}
+			var shadowErr error
+			if tempPath, shadowErr = copyFromShadow(dbPath); shadowErr != nil {
+				return nil, fmt.Errorf("%w; failed to read shadow copy: %w", err, shadowErr)
+			}
+		}
 	}
 
-	db, err = openWithWAL(tempPath)
+	db, err = openTemp(tempPath)
 	if err != nil {
-		os.Remove(tempPath)
+		removeTemp(tempPath)
 		return nil, fmt.Errorf("failed to open temp copy: %w", err)
 	}
 
 	return &DB{db: db, path: dbPath, tempCopy: tempPath}, nil
 }
 
-// openWithWAL opens a SQLite database in WAL mode
-func openWithWAL(dbPath string) (*sql.DB, error) {
-	// Use immutable mode for read-only access, which helps with locked databases
-	dsn := fmt.Sprintf("file:%s?mode=ro&_journal_mode=WAL&_busy_timeout=5000", dbPath)
+// openSource opens a database in a browser profile read-only. A read-only
+// connection still creates the -shm file of a WAL database if it is missing
+// (owned by the scanner's account, which can break the browser), so:
+//   - a database with no WAL content and no rollback journal, which is the
+//     case when the browser is not running, is opened immutable: SQLite reads
+//     the main file only, without locks or side files;
+//   - a database the browser has open (its -shm exists) or one in rollback
+//     journal mode is opened read-only with normal locking, which reads
+//     committed WAL frames and waits for writes in progress;
+//   - a WAL database with frames but no -shm (the browser crashed) returns
+//     errNeedsCopy.
+func openSource(dbPath string) (*sql.DB, error) {
+	walFrames := fileSize(dbPath+"-wal") > 0
+	_, journalErr := os.Stat(dbPath + "-journal")
+	_, shmErr := os.Stat(dbPath + "-shm")
+
+	switch {
+	case !walFrames && os.IsNotExist(journalErr):
+		return openDSN(dbPath, "mode=ro&immutable=1", true)
+	case shmErr == nil || !walMode(dbPath):
+		return openDSN(dbPath, "mode=ro", true)
+	default:
+		return nil, errNeedsCopy
+	}
+}
+
+// openTemp opens a copy made by the scanner. It is opened read-write so that
+// SQLite can roll back a copied hot journal and rebuild the WAL index.
+func openTemp(path string) (*sql.DB, error) {
+	return openDSN(path, "mode=rw", false)
+}
+
+// Create creates a new database file, or opens an existing one read-write.
+// It is used for generated fixtures, never for browser profiles.
+func Create(path string) (*sql.DB, error) {
+	return openDSN(path, "mode=rwc", false)
+}
+
+// openDSN opens a database file with URI parameters and sets the connection
+// pragmas. The modernc driver ignores the _journal_mode/_busy_timeout style
+// parameters of other drivers, so pragmas are run as statements on the only
+// connection of the pool; journal_mode is never set since that writes to the
+// file.
+func openDSN(path, params string, queryOnly bool) (*sql.DB, error) {
+	// ? # and % would end or corrupt the path part of the URI
+	escaped := strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23").Replace(path)
 
-	db, err := sql.Open("sqlite", dsn)
+	db, err := sql.Open("sqlite", "file:"+escaped+"?"+params)
 	if err != nil {
 		return nil, err
 	}
+	db.SetMaxOpenConns(1)
 
-	// Test the connection
-	if err := db.Ping(); err != nil {
-		db.Close()
-		return nil, err
+	pragmas := []string{fmt.Sprintf("PRAGMA busy_timeout = %d", busyTimeoutMs)}
+	if queryOnly {
+		pragmas = append(pragmas, "PRAGMA query_only = 1")
+	}
+	for _, pragma := range pragmas {
+		if _, err := db.Exec(pragma); err != nil {
+			db.Close()
+			return nil, err
+		}
 	}
 
 	return db, nil
 }
 
-// copyToTemp copies the database file to a temporary location
-func copyToTemp(dbPath string) (string, error) {
-	// Create temp file with same extension
+// walMode reports whether the database header marks a WAL database
+// (file format version 2)
+func walMode(dbPath string) bool {
+	f, err := os.Open(dbPath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	header := make([]byte, 20)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false
+	}
+	return header[18] == 2 || header[19] == 2
+}
+
+// checkReadable reads the schema, which fails if the database is locked.
+// Opening alone does not touch the file.
+func checkReadable(db *sql.DB) error {
+	var n int
+	return db.QueryRow("SELECT COUNT(*) FROM sqlite_master").Scan(&n)
+}
+
+// snapshotToTemp writes a consistent snapshot of the database to a temp file
+// with the SQLite online backup API. Unlike a file copy, the snapshot includes
+// committed WAL frames and never contains a half-written page; the backup
+// restarts by itself if the browser commits while it runs.
+func snapshotToTemp(dbPath string) (string, error) {
+	var src *sql.DB
+	err := retryBusy(func() error {
+		var err error
+		src, err = openSource(dbPath)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	conn, err := src.Conn(context.Background())
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	tempPath, err := createTemp(dbPath)
+	if err != nil {
+		return "", err
+	}
+
+	err = conn.Raw(func(driverConn interface{}) error {
+		b, ok := driverConn.(backuper)
+		if !ok {
+			return errors.New("sqlite driver does not support backups")
+		}
+		backup, err := b.NewBackup(tempPath)
+		if err != nil {
+			return err
+		}
+
+		for more := true; more; {
+			err := retryBusy(func() error {
+				var err error
+				more, err = backup.Step(backupPagesPerStep)
+				return err
+			})
+			if err != nil {
+				backup.Finish()
+				return err
+			}
+		}
+		return backup.Finish()
+	})
+	if err != nil {
+		removeTemp(tempPath)
+		return "", fmt.Errorf("backup failed: %w", err)
+	}
+
+	return tempPath, nil
+}
+
+// retryBusy calls fn until it succeeds or fails with an error other than
+// busy, waiting between attempts while the browser holds a write lock
+func retryBusy(fn func() error) error {
+	err := fn()
+	for retries := 0; err != nil && isBusy(err) && retries < backupRetries; retries++ {
+		time.Sleep(backupRetryDelay)
+		err = fn()
+	}
+	return err
+}
+
+// isBusy reports whether err is SQLITE_BUSY or SQLITE_LOCKED
+func isBusy(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	code := sqliteErr.Code() & 0xff // Primary result code
+	return code == 5 || code == 6
+}
+
+// createTemp creates an empty temp file with the database's extension, in
+// the first candidate directory with room for a copy of the database
+func createTemp(dbPath string) (string, error) {
+	dir, err := copyDir(dbPath)
+	if err != nil {
+		return "", err
+	}
+
 	ext := filepath.Ext(dbPath)
 	if ext == "" {
 		ext = ".db"
 	}
 
-	tempFile, err := os.CreateTemp("", "hist_scanner_*"+ext)
+	// The name is unpredictable and O_EXCL refuses to follow a planted file
+	tempPath := filepath.Join(dir, randomName(16)+ext)
+	tempFile, err := os.OpenFile(tempPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
 	if err != nil {
 		return "", fmt.Errorf("failed to create temp file: %w", err)
 	}
-	tempPath := tempFile.Name()
+	tempFile.Close()
+	return tempPath, nil
+}
+
+// copyDir checks the size of a database against the copy limit and returns
+// the first candidate directory that keeps minFreeAfterCopy free after the
+// copy, so a large database never fills up a filesystem halfway through
+func copyDir(dbPath string) (string, error) {
+	size := fileSize(dbPath) + fileSize(dbPath+"-wal")
+	if copyOptions.MaxSize > 0 && size > copyOptions.MaxSize {
+		return "", fmt.Errorf("%w: %s is %d MB, the limit is %d MB", ErrTooLarge, dbPath, size>>20, copyOptions.MaxSize>>20)
+	}
+
+	dirs := copyOptions.Dirs
+	if len(dirs) == 0 {
+		dirs = []string{DefaultTempDir()}
+	}
+
+	var checked []string
+	for _, root := range dirs {
+		dir, err := runDir(root)
+		if err != nil {
+			checked = append(checked, err.Error())
+			continue
+		}
+		free, err := platform.FreeSpace(dir)
+		if err != nil {
+			checked = append(checked, fmt.Sprintf("%s: %v", root, err))
+			continue
+		}
+		if free >= uint64(size)+minFreeAfterCopy {
+			return dir, nil
+		}
+		checked = append(checked, fmt.Sprintf("%s: %d MB free", root, free>>20))
+	}
+	return "", fmt.Errorf("%w: %s needs %d MB (%s)", ErrNoSpace, dbPath, (size+minFreeAfterCopy)>>20, strings.Join(checked, ", "))
+}
+
+// copyRefused reports whether err is a size or free space guard error
+func copyRefused(err error) bool {
+	return errors.Is(err, ErrTooLarge) || errors.Is(err, ErrNoSpace)
+}
+
+// fileSize returns the size of a file, or 0 if it does not exist
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// removeTemp removes a temp copy with its WAL and SHM files
+func removeTemp(tempPath string) {
+	removeFile(tempPath)
+	removeFile(tempPath + "-wal")
+	removeFile(tempPath + "-shm")
+	removeFile(tempPath + "-journal")
+}
+
+// copyToTemp copies the database files to a temporary location. This is the
+// last resort when an exclusive lock blocks the backup API: a copy taken
+// while the browser writes can be torn, so it is verified with quick_check
+// and taken again if it is inconsistent.
+func copyToTemp(dbPath string) (string, error) {
+	var err error
+	for attempt := 0; attempt < copyAttempts; attempt++ {
+		var tempPath string
+		if tempPath, err = copyFiles(dbPath); err != nil {
+			return "", err
+		}
+		if err = quickCheck(tempPath); err == nil {
+			return tempPath, nil
+		}
+		removeTemp(tempPath)
+	}
+	return "", fmt.Errorf("copy is inconsistent after %d attempts: %w", copyAttempts, err)
+}
+
+// copyFromShadow copies the database files from a shadow copy of their
+// volume. The snapshot is crash-consistent, so the copy is still verified.
+func copyFromShadow(dbPath string) (string, error) {
+	shadowPath, err := platform.ShadowPath(dbPath)
+	if err != nil {
+		return "", err
+	}
+
+	tempPath, err := copyFiles(shadowPath)
+	if err != nil {
+		return "", err
+	}
+	if err := quickCheck(tempPath); err != nil {
+		removeTemp(tempPath)
+		return "", err
+	}
+	return tempPath, nil
+}
+
+// quickCheck runs PRAGMA quick_check on a database copy
+func quickCheck(path string) error {
+	db, err := openTemp(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRow("PRAGMA quick_check").Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("quick_check: %s", result)
+	}
+	return nil
+}
+
+// copyFiles copies the database file and its WAL/SHM files to a temp file
+func copyFiles(dbPath string) (string, error) {
+	tempPath, err := createTemp(dbPath)
+	if err != nil {
+		return "", err
+	}
+	tempFile, err := os.OpenFile(tempPath, os.O_WRONLY, 0)
+	if err != nil {
+		os.Remove(tempPath)
+		return "", fmt.Errorf("failed to open temp file: %w", err)
+	}
 
 	// Open source file
 	src, err := os.Open(dbPath)
@@ -89,13 +473,15 @@ func copyToTemp(dbPath string) (string, error) {
 	_, err = io.Copy(tempFile, src)
 	tempFile.Close()
 	if err != nil {
-		os.Remove(tempPath)
+		removeFile(tempPath)
 		return "", fmt.Errorf("failed to copy: %w", err)
 	}
 
-	// Also copy WAL and SHM files if they exist (for consistency)
+	// Also copy the WAL and rollback journal if they exist; SQLite applies
+	// the WAL or rolls back the journal when the copy is opened. The -shm
+	// index is rebuilt from the WAL rather than copied mid-update.
 	copyIfExists(dbPath+"-wal", tempPath+"-wal")
-	copyIfExists(dbPath+"-shm", tempPath+"-shm")
+	copyIfExists(dbPath+"-journal", tempPath+"-journal")
 
 	return tempPath, nil
 }
@@ -108,7 +494,7 @@ func copyIfExists(src, dst string) {
 	}
 	defer srcFile.Close()
 
-	dstFile, err := os.Create(dst)
+	dstFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		return
 	}
@@ -123,22 +509,299 @@ func (d *DB) Close() error {
 
 	// Clean up temp copy if we made one
 	if d.tempCopy != "" {
-		os.Remove(d.tempCopy)
-		os.Remove(d.tempCopy + "-wal")
-		os.Remove(d.tempCopy + "-shm")
+		removeTemp(d.tempCopy)
 	}
 
 	return err
 }
 
+// QueryContext executes a query and returns rows; the query is interrupted
+// when ctx is done
+func (d *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return d.db.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext executes a query that returns at most one row; the query
+// is interrupted when ctx is done
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return d.db.QueryRowContext(ctx, query, args...)
+}
+
 // Query executes a query and returns rows
 func (d *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
 	return d.db.Query(query, args...)
 }
 
-// QueryRow executes a query that returns a single row
-func (d *DB) QueryRow(query string, args ...interface{}) *sql.Row {
-	return d.db.QueryRow(query, args...)
+// QueryRow executes a query that returns a single row and scans it into
+// dest. The query fails with ErrQueryTimeout after the query timeout.
+func (d *DB) QueryRow(query string, args []interface{}, dest ...interface{}) error {
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if queryOptions.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, queryOptions.Timeout)
+	}
+	defer cancel()
+
+	err := d.db.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s", ErrQueryTimeout, queryOptions.Timeout)
+	}
+	return err
+}
+
+// ForEachRow runs a query and calls fn for each result row until the rows
+// are exhausted or fn returns an error, which is returned. Rows are not
+// collected, so memory use does not grow with the size of the result.
+func (d *DB) ForEachRow(query string, args []interface{}, fn func(rows *sql.Rows) error) error {
+	return d.ForEachRowContext(context.Background(), query, args, fn)
+}
+
+// ForEachRowContext is ForEachRow with a context that interrupts the query
+// when done. The query fails with ErrQueryTimeout or ErrTooManyRows when it
+// exceeds the QueryOptions limits.
+func (d *DB) ForEachRowContext(ctx context.Context, query string, args []interface{}, fn func(rows *sql.Rows) error) error {
+	count := 0
+	return d.each(ctx, query, args, &count, fn)
+}
+
+// ForEachPage runs a keyset paginated query and calls fn for each row, like
+// ForEachRow. The query must select the key (the rowid or its alias) as its
+// first column and end with "AND key > ? ORDER BY key LIMIT ?"; it is run
+// once per page of pageSize rows, so no read transaction stays open while fn
+// works and a huge table is never sorted or held by a single statement.
+func (d *DB) ForEachPage(query string, args []interface{}, pageSize int, fn func(rows *sql.Rows) error) error {
+	return d.ForEachPageContext(context.Background(), query, args, pageSize, fn)
+}
+
+// ForEachPageContext is ForEachPage with a context that interrupts the
+// current page query when done. The timeout applies to each page; the row
+// limit to all pages together.
+func (d *DB) ForEachPageContext(ctx context.Context, query string, args []interface{}, pageSize int, fn func(rows *sql.Rows) error) error {
+	var after int64
+	count := 0
+	for {
+		n := 0
+		last := after
+		peek := keyPeeker()
+		pageArgs := append(append([]interface{}{}, args...), after, pageSize)
+		err := d.each(ctx, query, pageArgs, &count, func(rows *sql.Rows) error {
+			n++
+			if key, ok := peek(rows); ok {
+				last = key
+			}
+			return fn(rows)
+		})
+		if err != nil {
+			return err
+		}
+		if n < pageSize || last <= after {
+			return nil
+		}
+		after = last
+	}
+}
+
+// each runs one query and calls fn for each row. count holds the rows read
+// by the calling ForEachRow or ForEachPage call so far.
+func (d *DB) each(ctx context.Context, query string, args []interface{}, count *int, fn func(rows *sql.Rows) error) error {
+	ctx, w := startWatchdog(ctx, queryOptions.Timeout)
+	defer w.stop()
+
+	// Query time for TakeTiming, without the time spent in fn
+	started := time.Now()
+	var inFn time.Duration
+	defer func() { queryNanos.Add(int64(time.Since(started) - inFn)) }()
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return w.check(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		*count++
+		if max := queryOptions.MaxRows; max > 0 && *count > max {
+			return fmt.Errorf("%w: limit is %d", ErrTooManyRows, max)
+		}
+
+		// Time spent by fn, for example sending a batch, is not SQLite's
+		w.pause()
+		fnStarted := time.Now()
+		err := fn(rows)
+		inFn += time.Since(fnStarted)
+		w.resume()
+		if err != nil {
+			return err
+		}
+	}
+	return w.check(rows.Err())
+}
+
+// keyPeeker returns a function that reads the first column of the current
+// row as an integer key; the row can still be scanned by the caller
+func keyPeeker() func(rows *sql.Rows) (int64, bool) {
+	var key int64
+	var dest []interface{}
+	return func(rows *sql.Rows) (int64, bool) {
+		if dest == nil {
+			columns, err := rows.Columns()
+			if err != nil {
+				return 0, false
+			}
+			dest = []interface{}{&key}
+			for range columns[1:] {
+				dest = append(dest, new(interface{}))
+			}
+		}
+		return key, rows.Scan(dest...) == nil
+	}
+}
+
+// IsCorrupt reports whether err means the database file is damaged
+func IsCorrupt(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	code := sqliteErr.Code() & 0xff // Primary result code
+	return code == 11 || code == 26 // SQLITE_CORRUPT, SQLITE_NOTADB
+}
+
+// IsLocked reports whether err means a lock held by the browser kept the
+// database from being read in place and from being copied
+func IsLocked(err error) bool {
+	return isBusy(err) || platform.IsSharingViolation(err)
+}
+
+// IsUnsupportedSchema reports whether err means the database lacks a table
+// or column the scanner queries, e.g. one written by an unknown browser version
+func IsUnsupportedSchema(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	msg := sqliteErr.Error()
+	return strings.Contains(msg, "no such table") || strings.Contains(msg, "no such column")
+}
+
+// IntegrityCheck runs PRAGMA integrity_check and returns up to max problems,
+// or nil if the database is intact
+func (d *DB) IntegrityCheck(max int) ([]string, error) {
+	var problems []string
+	err := d.ForEachRow(fmt.Sprintf("PRAGMA integrity_check(%d)", max), nil, func(rows *sql.Rows) error {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return err
+		}
+		if msg != "ok" {
+			problems = append(problems, msg)
+		}
+		return nil
+	})
+	return problems, err
+}
+
+// SalvageRows reads the rows of a damaged table, like the sqlite3 .recover
+// command does for a whole database. The table is scanned by rowid without
+// indexes (a broken index is the most common damage); ranges that fail to
+// read are split until the unreadable rows are isolated and skipped. join
+// (it may be empty) joins other tables to each row, e.g. "JOIN urls ON
+// urls.id = visits.url". The first column must be the table's rowid or its
+// INTEGER PRIMARY KEY alias. where filters rows (it may be empty); fn is
+// called in rowid order and its errors stop the salvage. It returns the
+// number of rows that could not be read.
+func (d *DB) SalvageRows(table, join, columns, where string, args []interface{}, fn func(rows *sql.Rows) error) (int, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s NOT INDEXED %s WHERE %s.rowid BETWEEN ? AND ?", columns, table, join, table)
+	if where != "" {
+		query += " AND (" + where + ")"
+	}
+	query += fmt.Sprintf(" ORDER BY %s.rowid", table)
+
+	// Bounds of the table; a damaged root page makes these unreadable too
+	var lo, hi int64 = 1, 1 << 62
+	var minRow, maxRow sql.NullInt64
+	if d.db.QueryRow(fmt.Sprintf("SELECT MIN(rowid), MAX(rowid) FROM %s NOT INDEXED", table)).Scan(&minRow, &maxRow) == nil {
+		if !minRow.Valid {
+			return 0, nil // Empty table
+		}
+		lo, hi = minRow.Int64, maxRow.Int64
+	}
+
+	s := &salvage{db: d, query: query, args: args, fn: fn}
+	err := s.readRange(lo, hi)
+	return s.skipped, err
+}
+
+// salvage is the state of one SalvageRows call
+type salvage struct {
+	db      *DB
+	query   string
+	args    []interface{}
+	fn      func(rows *sql.Rows) error
+	queries int
+	rows    int
+	skipped int
+}
+
+// callbackError marks errors returned by the SalvageRows callback
+type callbackError struct{ err error }
+
+func (e *callbackError) Error() string { return e.err.Error() }
+func (e *callbackError) Unwrap() error { return e.err }
+
+// readRange reads the rows with rowids in [lo, hi]. On a read error, the
+// rows already read are kept and the rest of the range is split in halves.
+func (s *salvage) readRange(lo, hi int64) error {
+	for lo <= hi {
+		s.queries++
+		if s.queries > salvageMaxQueries {
+			return fmt.Errorf("salvage gave up after %d queries", salvageMaxQueries)
+		}
+
+		last, err := s.scan(lo, hi)
+		var cbErr *callbackError
+		if errors.As(err, &cbErr) {
+			return cbErr.err
+		}
+		if err == nil {
+			return nil
+		}
+		// Limits are not damage; splitting the range would only repeat them
+		if errors.Is(err, ErrQueryTimeout) || errors.Is(err, ErrTooManyRows) {
+			return err
+		}
+
+		// Continue after the last row read
+		if last >= lo {
+			lo = last + 1
+			continue
+		}
+		if lo == hi {
+			s.skipped++ // The row itself cannot be read
+			return nil
+		}
+		mid := lo + (hi-lo)/2
+		if err := s.readRange(lo, mid); err != nil {
+			return err
+		}
+		lo = mid + 1
+	}
+	return nil
+}
+
+// scan runs the salvage query over [lo, hi] and returns the last rowid read
+func (s *salvage) scan(lo, hi int64) (int64, error) {
+	last := lo - 1
+	peek := keyPeeker()
+	err := s.db.each(context.Background(), s.query, append([]interface{}{lo, hi}, s.args...), &s.rows, func(rows *sql.Rows) error {
+		if rowid, ok := peek(rows); ok {
+			last = rowid
+		}
+		if err := s.fn(rows); err != nil {
+			return &callbackError{err}
+		}
+		return nil
+	})
+	return last, err
 }
 
 // Path returns the original database path
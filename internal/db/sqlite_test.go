@@ -0,0 +1,126 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package db
+
+import (
+	"database/sql"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fileState is what a reader must not change about a file
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// listDir returns the files of dir with their size and modification time
+func listDir(t *testing.T, dir string) map[string]fileState {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]fileState)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[entry.Name()] = fileState{size: info.Size(), modTime: info.ModTime()}
+	}
+	return files
+}
+
+// createHistory creates a History database with a few rows in a new profile
+// directory. With wal, the database is in WAL mode; with a live writer the
+// returned connection keeps its frames in the -wal file, as a running
+// browser does, and must be closed by the caller.
+func createHistory(t *testing.T, wal, live bool) (string, *sql.DB) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "Default", "History")
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	writer, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statements := []string{"CREATE TABLE urls(id INTEGER PRIMARY KEY, url TEXT)"}
+	if wal {
+		statements = append([]string{"PRAGMA journal_mode = WAL", "PRAGMA wal_autocheckpoint = 0"}, statements...)
+	}
+	statements = append(statements, "INSERT INTO urls(url) VALUES ('https://example.com/'), ('https://example.org/')")
+	for _, statement := range statements {
+		if _, err := writer.Exec(statement); err != nil {
+			writer.Close()
+			t.Fatal(err)
+		}
+	}
+	if live {
+		return path, writer
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path, nil
+}
+
+// TestOpenSourceLeavesProfileUnchanged checks that reading a history
+// database in place creates no -wal, -shm or -journal file in the profile
+// directory and modifies no file there
+func TestOpenSourceLeavesProfileUnchanged(t *testing.T) {
+	tests := []struct {
+		name      string
+		wal, live bool
+	}{
+		{"rollback journal", false, false},
+		{"closed WAL", true, false},
+		{"WAL with live writer", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, writer := createHistory(t, tt.wal, tt.live)
+			if writer != nil {
+				defer writer.Close()
+			}
+			dir := filepath.Dir(path)
+			before := listDir(t, dir)
+			if tt.live && before["History-wal"].size == 0 {
+				t.Fatal("the writer left no WAL frames")
+			}
+
+			// Modification times must be able to tell a write apart
+			time.Sleep(20 * time.Millisecond)
+
+			reader, err := openSource(path)
+			if err != nil {
+				t.Fatalf("openSource: %v", err)
+			}
+			var count int
+			if err := reader.QueryRow("SELECT COUNT(*) FROM urls").Scan(&count); err != nil {
+				reader.Close()
+				t.Fatalf("query: %v", err)
+			}
+			if count != 2 {
+				t.Errorf("read %d rows, want 2", count)
+			}
+
+			// SQLite removes the -wal and -shm files it made on close, so the
+			// directory is compared while the database is open, too
+			if open := listDir(t, dir); !maps.Equal(before, open) {
+				t.Errorf("profile directory changed while open:\nbefore %v\nopen   %v", before, open)
+			}
+			if err := reader.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if after := listDir(t, dir); !maps.Equal(before, after) {
+				t.Errorf("profile directory changed:\nbefore %v\nafter  %v", before, after)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package db
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"hist_scanner/internal/platform"
+)
+
+// staleTempAge is the age after which leftover copies are removed even if
+// the process that made them appears to be running (its pid may be reused)
+const staleTempAge = 24 * time.Hour
+
+// runDirs maps each temp root used by this process to its run directory
+var (
+	runDirsMu sync.Mutex
+	runDirs   = make(map[string]string)
+)
+
+// DefaultTempDir returns the scanner-owned directory for database copies in
+// the system temp dir; it is per-user so unprivileged runs do not collide
+func DefaultTempDir() string {
+	name := "hist_scanner"
+	if uid := os.Getuid(); uid >= 0 {
+		name += "-" + strconv.Itoa(uid)
+	}
+	return filepath.Join(os.TempDir(), name)
+}
+
+// runDir returns this process's run directory under root, creating root with
+// owner-only permissions on first use
+func runDir(root string) (string, error) {
+	runDirsMu.Lock()
+	defer runDirsMu.Unlock()
+
+	if dir, ok := runDirs[root]; ok {
+		return dir, nil
+	}
+
+	if err := platform.MkdirPrivate(root); err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, fmt.Sprintf("%d-%s", os.Getpid(), randomName(4)))
+	if err := os.Mkdir(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	runDirs[root] = dir
+	return dir, nil
+}
+
+// randomName returns n random bytes hex encoded
+func randomName(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// RemoveTempDirs removes the run directories of this process with any copies
+// still in them
+func RemoveTempDirs() {
+	runDirsMu.Lock()
+	defer runDirsMu.Unlock()
+
+	for root, dir := range runDirs {
+		removeAll(dir)
+		delete(runDirs, root)
+	}
+}
+
+// ScavengeTempDirs removes copies left behind by scanner runs that crashed:
+// run directories under the copy directories whose process is gone, and
+// copies that older versions made directly in the system temp dir
+func ScavengeTempDirs() {
+	dirs := copyOptions.Dirs
+	if len(dirs) == 0 {
+		dirs = []string{DefaultTempDir()}
+	}
+
+	for _, root := range dirs {
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			pid, _, ok := strings.Cut(entry.Name(), "-")
+			if !entry.IsDir() || !ok {
+				continue
+			}
+			n, err := strconv.Atoi(pid)
+			if err != nil || n == os.Getpid() {
+				continue
+			}
+			if !platform.ProcessAlive(n) || olderThan(entry, staleTempAge) {
+				removeAll(filepath.Join(root, entry.Name()))
+			}
+		}
+	}
+
+	// Copies made before run directories existed
+	legacy, _ := filepath.Glob(filepath.Join(os.TempDir(), "hist_scanner_*"))
+	for _, path := range legacy {
+		if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() && time.Since(info.ModTime()) > staleTempAge {
+			removeFile(path)
+		}
+	}
+}
+
+// RunDirs returns the run directories of scanner processes under a copy
+// directory, running or not
+func RunDirs(root string) []string {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for _, entry := range entries {
+		pid, _, ok := strings.Cut(entry.Name(), "-")
+		if _, err := strconv.Atoi(pid); entry.IsDir() && ok && err == nil {
+			dirs = append(dirs, filepath.Join(root, entry.Name()))
+		}
+	}
+	return dirs
+}
+
+// olderThan reports whether a directory entry was last modified before age
+func olderThan(entry os.DirEntry, age time.Duration) bool {
+	info, err := entry.Info()
+	return err == nil && time.Since(info.ModTime()) > age
+}
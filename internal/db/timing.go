@@ -0,0 +1,27 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package db
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Timing is the time spent in database work since the last TakeTiming call
+type Timing struct {
+	Open  time.Duration // Open, including snapshots and temp copies of locked databases
+	Query time.Duration // SQLite running queries, not counting row callbacks
+}
+
+// Accumulated Timing, in nanoseconds
+var openNanos, queryNanos atomic.Int64
+
+// TakeTiming returns the time spent opening databases and running queries
+// since the last call, and starts counting again
+func TakeTiming() Timing {
+	return Timing{
+		Open:  time.Duration(openNanos.Swap(0)),
+		Query: time.Duration(queryNanos.Swap(0)),
+	}
+}
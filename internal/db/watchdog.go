@@ -0,0 +1,80 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// watchdog cancels a query's context once SQLite has worked on the query for
+// longer than the timeout. The clock is paused while the caller handles a
+// row, so a slow consumer does not time out the query.
+type watchdog struct {
+	timeout   time.Duration
+	remaining time.Duration
+	started   time.Time
+	timer     *time.Timer
+	cancel    context.CancelFunc
+	expired   atomic.Bool
+}
+
+// startWatchdog returns a context for a query that is cancelled after
+// timeout (0 means no timeout) and the watchdog measuring it
+func startWatchdog(ctx context.Context, timeout time.Duration) (context.Context, *watchdog) {
+	ctx, cancel := context.WithCancel(ctx)
+	w := &watchdog{timeout: timeout, remaining: timeout, cancel: cancel}
+	if timeout > 0 {
+		w.started = time.Now()
+		w.timer = time.AfterFunc(timeout, w.expire)
+	}
+	return ctx, w
+}
+
+// expire cancels the query
+func (w *watchdog) expire() {
+	w.expired.Store(true)
+	w.cancel()
+}
+
+// pause stops the clock
+func (w *watchdog) pause() {
+	if w.timer == nil {
+		return
+	}
+	w.timer.Stop()
+	w.remaining -= time.Since(w.started)
+}
+
+// resume restarts the clock with the time left
+func (w *watchdog) resume() {
+	if w.timer == nil {
+		return
+	}
+	if w.remaining <= 0 {
+		w.expire()
+		return
+	}
+	w.started = time.Now()
+	w.timer.Reset(w.remaining)
+}
+
+// stop releases the watchdog once the query is done
+func (w *watchdog) stop() {
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.cancel()
+}
+
+// check replaces the error of an interrupted query with ErrQueryTimeout if
+// the watchdog interrupted it
+func (w *watchdog) check(err error) error {
+	if err != nil && w.expired.Load() {
+		return fmt.Errorf("%w after %s", ErrQueryTimeout, w.timeout)
+	}
+	return err
+}
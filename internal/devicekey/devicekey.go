@@ -0,0 +1,140 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package devicekey holds the per-device Ed25519 key that signs uploads, so
+// the server can tell submissions of a registered device from ones forged
+// with a leaked API key. Each request body is signed as a detached JWS
+// (RFC 7515 Appendix F) sent in SignatureHeader.
+package devicekey
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SignatureHeader carries the detached JWS of a request body
+const SignatureHeader = "X-Device-Signature"
+
+// FileName is the key file name next to the state file
+const FileName = "device.key"
+
+// Key is a device signing key
+type Key struct {
+	private ed25519.PrivateKey
+	id      string // JWK thumbprint of the public key
+}
+
+// JWK is the public key in JSON Web Key form
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+}
+
+// LoadOrCreate reads the key at path, generating and saving a new one
+// (readable by the owner only) if there is none. created reports a new key.
+func LoadOrCreate(path string) (key *Key, created bool, err error) {
+	key, err = Load(path)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return key, false, err
+	}
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to generate device key: %w", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode device key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, false, fmt.Errorf("failed to create key directory: %w", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	// O_EXCL: a concurrent run that created the key first wins
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, os.ErrExist) {
+		key, err = Load(path)
+		return key, false, err
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to save device key: %w", err)
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, false, fmt.Errorf("failed to save device key: %w", err)
+	}
+	return newKey(private), true, nil
+}
+
+// Load reads a PEM (PKCS #8) Ed25519 key
+func Load(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read device key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%s is not a PEM private key", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse device key: %w", err)
+	}
+	private, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an Ed25519 key", path)
+	}
+	return newKey(private), nil
+}
+
+// newKey computes the key id of a private key
+func newKey(private ed25519.PrivateKey) *Key {
+	k := &Key{private: private}
+	// RFC 7638 thumbprint: SHA-256 of the required members in lexical order
+	jwk := k.JWK()
+	thumb := sha256.Sum256([]byte(`{"crv":"` + jwk.Crv + `","kty":"` + jwk.Kty + `","x":"` + jwk.X + `"}`))
+	k.id = base64.RawURLEncoding.EncodeToString(thumb[:])
+	return k
+}
+
+// ID returns the key id, the RFC 7638 thumbprint of the public key
+func (k *Key) ID() string {
+	return k.id
+}
+
+// JWK returns the public key
+func (k *Key) JWK() JWK {
+	public := k.private.Public().(ed25519.PublicKey)
+	return JWK{Kty: "OKP", Crv: "Ed25519", X: base64.RawURLEncoding.EncodeToString(public)}
+}
+
+// Sign returns the detached compact JWS of a request body: the protected
+// header, an empty payload and the signature. The header's iat limits how
+// long a captured request can be replayed.
+func (k *Key) Sign(body []byte) string {
+	header, _ := json.Marshal(struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+		Iat int64  `json:"iat"`
+	}{"EdDSA", k.id, time.Now().Unix()})
+
+	protected := base64.RawURLEncoding.EncodeToString(header)
+	input := protected + "." + base64.RawURLEncoding.EncodeToString(body)
+	sig := ed25519.Sign(k.private, []byte(input))
+	return protected + ".." + base64.RawURLEncoding.EncodeToString(sig)
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package dnslog
+
+import "context"
+
+// readWindowsCache is only available on Windows
+func readWindowsCache(ctx context.Context) ([]Query, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,42 @@
+//go:build windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package dnslog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// cacheScript lists the names of the successful A and AAAA records in the
+// DNS client cache; ipconfig /displaydns output is localized
+const cacheScript = `Get-DnsClientCache | Where-Object { $_.Status -eq 0 -and ($_.Type -eq 1 -or $_.Type -eq 28) } | ForEach-Object { $_.Entry }`
+
+// readWindowsCache returns the names in the DNS client cache, which keeps
+// each record for its TTL, at the current time
+func readWindowsCache(ctx context.Context) ([]Query, error) {
+	output, err := exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", cacheScript).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the DNS client cache: %w", err)
+	}
+
+	now := time.Now()
+	seen := make(map[string]bool)
+	var queries []Query
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		name := hostName(scanner.Text())
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		queries = append(queries, Query{Time: now, Name: name})
+	}
+	return queries, scanner.Err()
+}
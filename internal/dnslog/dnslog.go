@@ -0,0 +1,106 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package dnslog reads the names a machine resolved from the Windows DNS
+// client cache and the query logs of systemd-resolved and unbound. They show
+// which domains were reached even when browser history is disabled, but not
+// by which user or program, so they are a lower-fidelity source.
+package dnslog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Source kinds
+const (
+	KindWindowsCache = "windows-cache"    // Windows DNS client cache
+	KindResolved     = "systemd-resolved" // systemd-resolved debug log in the journal
+	KindUnbound      = "unbound"          // unbound log file with log-queries or log-replies
+)
+
+// Kinds lists the supported source kinds
+var Kinds = []string{KindWindowsCache, KindResolved, KindUnbound}
+
+// ErrUnsupported is returned by sources that do not exist on this OS
+var ErrUnsupported = errors.New("not available on this system")
+
+// Source is a place resolved names are read from
+type Source struct {
+	Kind string
+	Path string // Log file (unbound)
+}
+
+// String returns the source as configured, e.g. "unbound:/var/log/unbound.log"
+func (s Source) String() string {
+	if s.Path != "" {
+		return s.Kind + ":" + s.Path
+	}
+	return s.Kind
+}
+
+// Query is a resolved name
+type Query struct {
+	Time   time.Time
+	Client string // Address of the client that asked, "" for this machine
+	Name   string // Host name, without the trailing dot
+}
+
+// ParseSources parses "windows-cache", "systemd-resolved" and
+// "unbound:/path/to/log" entries
+func ParseSources(entries []string) ([]Source, error) {
+	var sources []Source
+	for _, e := range entries {
+		kind, path, _ := strings.Cut(strings.TrimSpace(e), ":")
+		switch kind {
+		case KindWindowsCache, KindResolved:
+			if path != "" {
+				return nil, fmt.Errorf("%q: %s takes no path", e, kind)
+			}
+		case KindUnbound:
+			if !filepath.IsAbs(path) {
+				return nil, fmt.Errorf("%q: unbound needs the absolute path of its log file, e.g. unbound:/var/log/unbound.log", e)
+			}
+		default:
+			return nil, fmt.Errorf("%q: unknown source (supported: %s)", e, strings.Join(Kinds, ", "))
+		}
+		sources = append(sources, Source{Kind: kind, Path: path})
+	}
+	return sources, nil
+}
+
+// Read returns the names resolved after since. The Windows cache holds no
+// times; its entries are returned with the current time.
+func (s Source) Read(ctx context.Context, since time.Time) ([]Query, error) {
+	switch s.Kind {
+	case KindWindowsCache:
+		return readWindowsCache(ctx)
+	case KindResolved:
+		return readResolved(ctx, since)
+	case KindUnbound:
+		return readUnbound(s.Path, since)
+	}
+	return nil, fmt.Errorf("unknown source %q", s.Kind)
+}
+
+// hostName returns a queried name as a host name, or "" for names that do
+// not name a site: single labels, reverse lookups, local and service names
+func hostName(name string) string {
+	name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+	if !strings.Contains(name, ".") || strings.ContainsAny(name, " /\\_") {
+		return ""
+	}
+	for _, suffix := range []string{".arpa", ".local", ".localhost", ".internal"} {
+		if strings.HasSuffix(name, suffix) {
+			return ""
+		}
+	}
+	return name
+}
+
+// siteTypes are the record types looked up to reach a site
+var siteTypes = map[string]bool{"A": true, "AAAA": true, "HTTPS": true}
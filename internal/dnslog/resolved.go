@@ -0,0 +1,62 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package dnslog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// resolvedLookup matches the debug message of a lookup, e.g.
+// "Looking up RR for example.com IN A."
+var resolvedLookup = regexp.MustCompile(`Looking up RR for (\S+) IN (\S+?)\.?$`)
+
+// readResolved returns the lookups systemd-resolved logged to the journal
+// after since. resolved logs them only at debug level
+// (resolvectl log-level debug).
+func readResolved(ctx context.Context, since time.Time) ([]Query, error) {
+	if _, err := exec.LookPath("journalctl"); err != nil {
+		return nil, ErrUnsupported
+	}
+	args := []string{"-u", "systemd-resolved.service", "-o", "short-unix", "-q", "--no-pager"}
+	if !since.IsZero() {
+		args = append(args, "--since", fmt.Sprintf("@%d", since.Unix()))
+	}
+	output, err := exec.CommandContext(ctx, "journalctl", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run journalctl: %w", err)
+	}
+
+	var queries []Query
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 64<<10), 64<<10)
+	for scanner.Scan() {
+		if q, ok := parseResolved(scanner.Text()); ok && q.Time.After(since) {
+			queries = append(queries, q)
+		}
+	}
+	return queries, scanner.Err()
+}
+
+// parseResolved parses a short-unix journal line of a lookup
+func parseResolved(line string) (Query, bool) {
+	m := resolvedLookup.FindStringSubmatch(line)
+	if m == nil || !siteTypes[m[2]] {
+		return Query{}, false
+	}
+	name := hostName(m[1])
+	stamp, _, _ := strings.Cut(line, " ")
+	secs, err := strconv.ParseFloat(stamp, 64)
+	if name == "" || err != nil {
+		return Query{}, false
+	}
+	return Query{Time: time.UnixMilli(int64(secs * 1000)), Name: name}, true
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package dnslog
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// readUnbound returns the queries of an unbound log file logged after since.
+// Lines are written with log-queries ("query:", or "info:" before unbound
+// 1.9, then client, name, type and class) or log-replies (the same followed
+// by the rcode); replies other than NOERROR are skipped.
+func readUnbound(path string, since time.Time) ([]Query, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open unbound log: %w", err)
+	}
+	defer f.Close()
+
+	var queries []Query
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 64<<10)
+	now := time.Now()
+	for scanner.Scan() {
+		q, ok := parseUnbound(scanner.Text(), now)
+		if ok && q.Time.After(since) {
+			queries = append(queries, q)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return queries, fmt.Errorf("failed to read unbound log: %w", err)
+	}
+	return queries, nil
+}
+
+// parseUnbound parses a query or reply line of unbound's own log file
+// ("[1700000000] unbound[1:0] query: ...") or of syslog ("Nov 14 10:00:00
+// host unbound: [1:0] query: ..."), whose times lack the year
+func parseUnbound(line string, now time.Time) (Query, bool) {
+	f := strings.Fields(line)
+	at := -1
+	for i, field := range f {
+		if field == "query:" || field == "reply:" || field == "info:" {
+			at = i
+			break
+		}
+	}
+	// client, name, type and class follow; replies add the rcode
+	if at < 1 || len(f) < at+5 || f[at+4] != "IN" || !siteTypes[f[at+3]] {
+		return Query{}, false
+	}
+	if f[at] != "query:" && len(f) > at+5 && f[at+5] != "NOERROR" {
+		return Query{}, false
+	}
+	name := hostName(f[at+2])
+	if name == "" {
+		return Query{}, false
+	}
+
+	t, ok := unboundTime(f, now)
+	if !ok {
+		return Query{}, false
+	}
+	return Query{Time: t, Client: unboundClient(f[at+1]), Name: name}, true
+}
+
+// unboundTime parses the time a line starts with
+func unboundTime(f []string, now time.Time) (time.Time, bool) {
+	if epoch, ok := strings.CutPrefix(f[0], "["); ok {
+		secs, err := strconv.ParseInt(strings.TrimSuffix(epoch, "]"), 10, 64)
+		return time.Unix(secs, 0), err == nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, f[0]); err == nil {
+		return t, true
+	}
+	if len(f) < 3 {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation("Jan 2 15:04:05", strings.Join(f[:3], " "), time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	t = t.AddDate(now.Year(), 0, 0)
+	if t.After(now.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0) // Logged in December, read in January
+	}
+	return t, true
+}
+
+// unboundClient returns the client address of a line, "" for this machine.
+// unbound logs "addr@port" with log-replies.
+func unboundClient(client string) string {
+	client, _, _ = strings.Cut(client, "@")
+	if client == "127.0.0.1" || client == "::1" {
+		return ""
+	}
+	return client
+}
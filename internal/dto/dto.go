@@ -13,14 +13,45 @@ const (
 
 // PrincipalDTO identifies the user whose browser history was scanned
 type PrincipalDTO struct {
-	Name string        `json:"name"`
-	Kind PrincipalKind `json:"kind"`
+	Name     string        `json:"name"`
+	Kind     PrincipalKind `json:"kind"`
+	Identity *IdentityDTO  `json:"identity,omitempty"` // Directory identity, when known
+}
+
+// IdentityDTO disambiguates a user name across domains and tenants
+type IdentityDTO struct {
+	Account   string         `json:"account,omitempty"`   // DOMAIN\user
+	UPN       string         `json:"upn,omitempty"`       // User principal name
+	Domain    string         `json:"domain,omitempty"`    // Directory domain
+	SID       string         `json:"sid,omitempty"`       // Windows security identifier
+	Directory string         `json:"directory,omitempty"` // macOS directory-services node
+	Device    *DeviceJoinDTO `json:"device,omitempty"`    // How the machine is joined
+}
+
+// DeviceJoinDTO describes the machine's Active Directory / Entra ID join
+type DeviceJoinDTO struct {
+	JoinType   string `json:"joinType"` // domain, entra or hybrid
+	Domain     string `json:"domain,omitempty"`
+	TenantID   string `json:"tenantId,omitempty"`
+	TenantName string `json:"tenantName,omitempty"`
+	DeviceID   string `json:"deviceId,omitempty"`
 }
 
 // VisitedSite represents a single browser history entry
 type VisitedSite struct {
 	URL       string `json:"url"`
 	Timestamp int64  `json:"timestamp"` // Unix milliseconds
+	RowID     int64  `json:"-"`         // Browser database row id (used for incremental scans, not sent)
+
+	// SaaS service of the URL's host from the catalog (tag_services)
+	App         string `json:"app,omitempty"`
+	AppCategory string `json:"appCategory,omitempty"`
+	Risk        string `json:"risk,omitempty"` // low, medium or high
+
+	// Future marks a visit timestamped later than the server's time when it
+	// was sent (flag_future_visits): the device clock was ahead when the
+	// visit was made
+	Future bool `json:"future,omitempty"`
 }
 
 // VisitedSitesDTO is the payload sent to the server
@@ -28,6 +59,141 @@ type VisitedSitesDTO struct {
 	Principal    PrincipalDTO  `json:"principal"`
 	VisitedSites []VisitedSite `json:"visitedSites"`
 	Source       string        `json:"source"`
+	Device       *DeviceDTO    `json:"device,omitempty"` // Scanned machine
+
+	// Corrupt marks entries salvaged from a damaged history database; some
+	// entries of the profile may be missing
+	Corrupt bool `json:"corrupt,omitempty"`
+
+	// Import marks visits read from an export file by the import command
+	// rather than scanned; Device is then the machine that imported them
+	Import *ImportDTO `json:"import,omitempty"`
+
+	// Proxy marks visits read from a proxy access log; Device is then the
+	// machine that read the log
+	Proxy *ProxyDTO `json:"proxy,omitempty"`
+
+	// Image marks visits scanned from a mounted disk image (run --root);
+	// Device is then the machine that scanned it
+	Image *ImageDTO `json:"image,omitempty"`
+
+	// DNS marks domain events read from a DNS cache or resolver log rather
+	// than browser history: each visit is only a resolved host, as
+	// https://host/, made by any program of the device or resolver client
+	DNS *DNSDTO `json:"dns,omitempty"`
+
+	// SchemaVersion is VisitsVersion; payloads without it are version 1
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+
+	// Browser is the browser and profile the visits were read from (version
+	// 2); imported, proxy and DNS visits have none
+	Browser *BrowserDTO `json:"browser,omitempty"`
+
+	// ScanID is the scan the visits were read in, as in the run report and
+	// the agent's JSON logs
+	ScanID string `json:"scanId,omitempty"`
+
+	// Clock is the device clock measured against the server's, when the
+	// server's responses carried a Date header
+	Clock *ClockDTO `json:"clock,omitempty"`
+
+	// Sequence numbers the requests of a scan accepted by the server from 1
+	// on; a chunk the server rejects does not use up its number. The
+	// last request of a scan is the completion marker: it has no visits,
+	// Complete is set and its sequence follows the last chunk's, so the
+	// server can tell which chunks of the scan it is missing.
+	Sequence int  `json:"sequence,omitempty"`
+	Complete bool `json:"complete,omitempty"`
+
+	// ChunkIndex and ChunkCount place the request among the chunks a batch
+	// of visits was split into; ChunkIndex counts from 0
+	ChunkIndex int `json:"chunkIndex,omitempty"`
+	ChunkCount int `json:"chunkCount,omitempty"`
+}
+
+// VisitsVersion is the version of the VisitedSitesDTO payload. Version 2
+// added Browser.
+const VisitsVersion = 2
+
+// ClockDTO describes the device clock of a visits payload
+type ClockDTO struct {
+	SkewMs    int64 `json:"skewMs"`              // Server time minus device time, in ms
+	Corrected bool  `json:"corrected,omitempty"` // Visit timestamps were shifted by SkewMs to the server's clock
+}
+
+// BrowserDTO identifies the browser and profile of visits
+type BrowserDTO struct {
+	Name        string `json:"name"`                  // chrome, edge, firefox, safari, opera, ...
+	Version     string `json:"version,omitempty"`     // Version that last opened the profile
+	Profile     string `json:"profile"`               // Profile the scan position is kept for, e.g. "Profile 1"
+	ProfileName string `json:"profileName,omitempty"` // Name shown in the browser, e.g. "Work"
+}
+
+// ImportDTO describes the export file of imported visits
+type ImportDTO struct {
+	Format string `json:"format"` // takeout, csv or firefox-json
+	File   string `json:"file"`   // Base name of the file
+}
+
+// ProxyDTO describes the access log of proxy visits
+type ProxyDTO struct {
+	Format string `json:"format"` // squid or common
+	Log    string `json:"log"`    // Path of the access log
+}
+
+// ImageDTO describes the disk image scanned visits were read from
+type ImageDTO struct {
+	Root string `json:"root"` // Where the image was mounted
+	OS   string `json:"os"`   // OS whose layout the image follows
+}
+
+// DNSDTO describes the source of DNS domain events
+type DNSDTO struct {
+	Source   string `json:"source"`   // windows-cache, systemd-resolved or unbound
+	Fidelity string `json:"fidelity"` // Always "low": no user, page or program is known
+}
+
+// AggregatesVersion is the version of the DomainAggregatesDTO payload
+const AggregatesVersion = 2
+
+// DomainAggregatesDTO is the v2 payload: the visits of one scan counted per
+// registrable domain instead of sent one by one
+type DomainAggregatesDTO struct {
+	Version int                  `json:"version"` // AggregatesVersion
+	ScanID  string               `json:"scanId"`
+	Source  string               `json:"source"`
+	Device  *DeviceDTO           `json:"device,omitempty"`
+	Domains []DomainAggregateDTO `json:"domains"`
+}
+
+// DomainAggregateDTO counts the visits to one registrable domain
+type DomainAggregateDTO struct {
+	Domain     string         `json:"domain"`
+	VisitCount int            `json:"visitCount"`
+	FirstSeen  int64          `json:"firstSeen"` // Unix milliseconds, first visit in this payload
+	LastSeen   int64          `json:"lastSeen"`  // Unix milliseconds, last visit in this payload
+	Users      []PrincipalDTO `json:"users"`
+
+	// DeviceFirstSeen is the first visit to the domain on the device
+	// (track_domains), which can predate this payload
+	DeviceFirstSeen int64 `json:"deviceFirstSeen,omitempty"`
+
+	// SaaS service of the domain from the catalog (tag_services)
+	App         string `json:"app,omitempty"`
+	AppCategory string `json:"appCategory,omitempty"`
+	Risk        string `json:"risk,omitempty"`
+}
+
+// DeviceDTO identifies the scanned machine across users and IP changes
+type DeviceDTO struct {
+	ID             string      `json:"id"` // Stable hashed machine id
+	Hostname       string      `json:"hostname"`
+	OS             string      `json:"os"` // linux, darwin, windows or freebsd
+	OSVersion      string      `json:"osVersion"`
+	ScannerVersion string      `json:"scannerVersion"` // Scanner.Version, kept for servers reading it
+	Scanner        *ScannerDTO `json:"scanner,omitempty"`
+	NoticeShown    int64       `json:"noticeShown,omitempty"` // Unix ms when the current disclosure notice was first in place
+	Container      bool        `json:"container,omitempty"`   // Scanner runs in a container (host_root names the mounted host)
 }
 
 // NewUserPrincipal creates a PrincipalDTO with USERNAME kind
@@ -45,3 +211,308 @@ func NewIPPrincipal(ip string) PrincipalDTO {
 		Kind: KindIP,
 	}
 }
+
+// RunReportDTO is the compact run summary posted to the status endpoint
+type RunReportDTO struct {
+	ScanID          string            `json:"scanId"` // Matches scan_id in the agent's JSON logs
+	Scanner         *ScannerDTO       `json:"scanner,omitempty"`
+	Source          string            `json:"source"`
+	Host            string            `json:"host"`
+	DeviceID        string            `json:"deviceId"`
+	Started         int64             `json:"started"` // Unix milliseconds
+	DurationMS      int64             `json:"durationMs"`
+	ExitCode        int               `json:"exitCode"`
+	Full            bool              `json:"full"`
+	UsersScanned    int               `json:"usersScanned"`
+	ProfilesScanned int               `json:"profilesScanned"`
+	EntriesSent     int               `json:"entriesSent"`
+	ChunksSent      int               `json:"chunksSent"` // Visit chunks the server accepted; the completion marker's sequence is one more
+	Errors          []string          `json:"errors"`
+	ProfileErrors   []ProfileErrorDTO `json:"profileErrors,omitempty"`
+	ErrorCategories map[string]int    `json:"errorCategories,omitempty"` // Profile errors per category
+	Skipped         []SkippedUserDTO  `json:"skipped,omitempty"`
+	Corrupt         []CorruptDTO      `json:"corrupt,omitempty"`
+	Postponed       []PostponedDTO    `json:"postponed,omitempty"`
+	Timing          *TimingDTO        `json:"timing,omitempty"`
+	Dropped         map[string]int    `json:"dropped,omitempty"` // Visits dropped per excluded category or destination reason
+
+	// Registrable domains visited in the run (track_domains), and those
+	// never visited on the device before, at most maxNewDomains of them
+	DomainsSeen    int             `json:"domainsSeen,omitempty"`
+	NewDomainCount int             `json:"newDomainCount,omitempty"`
+	NewDomains     []DomainSeenDTO `json:"newDomains,omitempty"`
+}
+
+// ScanWebhookDTO is the summary of a finished scan posted to webhook_url
+type ScanWebhookDTO struct {
+	Event           string   `json:"event"`  // scan.completed
+	Status          string   `json:"status"` // success, partial or failed
+	ScanID          string   `json:"scanId"`
+	Source          string   `json:"source"`
+	Host            string   `json:"host"`
+	DeviceID        string   `json:"deviceId"`
+	Started         int64    `json:"started"`  // Unix milliseconds
+	Finished        int64    `json:"finished"` // Unix milliseconds
+	DurationMS      int64    `json:"durationMs"`
+	ExitCode        int      `json:"exitCode"`
+	UsersScanned    int      `json:"usersScanned"`
+	UsersSkipped    int      `json:"usersSkipped"`
+	ProfilesScanned int      `json:"profilesScanned"`
+	EntriesSent     int      `json:"entriesSent"`
+	ErrorCount      int      `json:"errorCount"`
+	Errors          []string `json:"errors"` // The first errors
+
+	ErrorCategories map[string]int `json:"errorCategories,omitempty"` // Profile errors per category
+}
+
+// DomainSeenDTO is when a registrable domain was first and last visited on
+// the device
+type DomainSeenDTO struct {
+	Domain    string `json:"domain"`
+	FirstSeen int64  `json:"firstSeen"` // Unix milliseconds
+	LastSeen  int64  `json:"lastSeen"`  // Unix milliseconds
+}
+
+// TimingDTO is where a run spent its time: user enumeration, the phase
+// totals over all profiles and the slowest profiles
+type TimingDTO struct {
+	EnumerationMS int64              `json:"enumerationMs"`
+	Phases        PhasesDTO          `json:"phases"`
+	BytesSent     int64              `json:"bytesSent"`
+	Slowest       []ProfileTimingDTO `json:"slowest,omitempty"`
+}
+
+// PhasesDTO is the time spent in each phase of scanning profiles, in milliseconds
+type PhasesDTO struct {
+	OpenMS      int64 `json:"openMs"`
+	QueryMS     int64 `json:"queryMs"`
+	TransformMS int64 `json:"transformMs"`
+	CompressMS  int64 `json:"compressMs"`
+	HTTPMS      int64 `json:"httpMs"`
+}
+
+// ProfileTimingDTO is the timing of one of a run's slowest profiles
+type ProfileTimingDTO struct {
+	User       string    `json:"user"`
+	Browser    string    `json:"browser"`
+	Profile    string    `json:"profile"`
+	DurationMS int64     `json:"durationMs"`
+	Entries    int       `json:"entries"`
+	Phases     PhasesDTO `json:"phases"`
+}
+
+// ScannerDTO identifies the scanner build, as the User-Agent of its
+// requests does ("hist_scanner/1.4.0 (linux; amd64)")
+type ScannerDTO struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+	OS      string `json:"os"`   // GOOS the binary was built for
+	Arch    string `json:"arch"` // GOARCH, e.g. amd64 or arm64
+}
+
+// ErrorReportDTO is an error event posted to the error endpoint when a scan
+// panics or fails completely. It never contains history data.
+type ErrorReportDTO struct {
+	ScanID         string      `json:"scanId"`
+	Source         string      `json:"source"`
+	Host           string      `json:"host"`
+	DeviceID       string      `json:"deviceId"`
+	ScannerVersion string      `json:"scannerVersion"`
+	Scanner        *ScannerDTO `json:"scanner,omitempty"`
+	OS             string      `json:"os"`
+	Time           int64       `json:"time"` // Unix milliseconds
+	Kind           string      `json:"kind"` // panic or fatal
+	Message        string      `json:"message"`
+	Stack          string      `json:"stack,omitempty"` // Goroutine stack of a panic
+}
+
+// HistoryEventDTO reports a profile whose history was cleared or reduced
+// between two scans, usually by the user. It carries no URLs.
+type HistoryEventDTO struct {
+	ScanID       string       `json:"scanId"`
+	Source       string       `json:"source"`
+	Host         string       `json:"host"`
+	DeviceID     string       `json:"deviceId"`
+	Principal    PrincipalDTO `json:"principal"`
+	Browser      string       `json:"browser"`
+	Profile      string       `json:"profile"`
+	Time         int64        `json:"time"` // Unix milliseconds
+	Kind         string       `json:"kind"` // recreated, cleared or reduced
+	PrevRows     int64        `json:"prevRows"`
+	Rows         int64        `json:"rows"`
+	PrevMaxRowID int64        `json:"prevMaxRowId"`
+	MaxRowID     int64        `json:"maxRowId"`
+}
+
+// ServiceEventDTO reports an unsanctioned site visited on a device for the
+// first time. It carries the registrable domain, not the visited URLs.
+type ServiceEventDTO struct {
+	ScanID      string       `json:"scanId"`
+	Source      string       `json:"source"`
+	Host        string       `json:"host"`
+	DeviceID    string       `json:"deviceId"`
+	Principal   PrincipalDTO `json:"principal"`
+	Time        int64        `json:"time"` // Unix milliseconds
+	Kind        string       `json:"kind"` // new-service
+	Domain      string       `json:"domain"`
+	App         string       `json:"app,omitempty"`
+	AppCategory string       `json:"appCategory,omitempty"`
+	Risk        string       `json:"risk,omitempty"`
+	FirstSeen   int64        `json:"firstSeen"` // Unix milliseconds of the first visit
+	Visits      int          `json:"visits"`    // Visits in the scan that found it
+}
+
+// OAuthGrantDTO reports a user's first visit to the OAuth consent page of
+// an app. It carries the app's client id and redirect domain, not the URL.
+type OAuthGrantDTO struct {
+	ScanID         string       `json:"scanId"`
+	Source         string       `json:"source"`
+	Host           string       `json:"host"`
+	DeviceID       string       `json:"deviceId"`
+	Principal      PrincipalDTO `json:"principal"`
+	Browser        string       `json:"browser"`
+	Profile        string       `json:"profile"`
+	Time           int64        `json:"time"` // Unix milliseconds
+	Kind           string       `json:"kind"` // oauth-grant
+	Provider       string       `json:"provider"`
+	ClientID       string       `json:"clientId"`
+	RedirectDomain string       `json:"redirectDomain,omitempty"`
+	Scopes         []string     `json:"scopes,omitempty"`
+	VisitTime      int64        `json:"visitTime"` // Unix milliseconds of the consent page visit
+}
+
+// SignupEventDTO reports a user's first visit to a sign-up or registration
+// page of an unsanctioned domain, a likely account creation. It carries the
+// domain and the matched pattern, not the URL.
+type SignupEventDTO struct {
+	ScanID      string       `json:"scanId"`
+	Source      string       `json:"source"`
+	Host        string       `json:"host"`
+	DeviceID    string       `json:"deviceId"`
+	Principal   PrincipalDTO `json:"principal"`
+	Browser     string       `json:"browser"`
+	Profile     string       `json:"profile"`
+	Time        int64        `json:"time"`     // Unix milliseconds
+	Kind        string       `json:"kind"`     // signup
+	Priority    string       `json:"priority"` // high
+	Domain      string       `json:"domain"`
+	App         string       `json:"app,omitempty"`
+	AppCategory string       `json:"appCategory,omitempty"`
+	Risk        string       `json:"risk,omitempty"`
+	Pattern     string       `json:"pattern"`   // Matched signup_patterns entry
+	VisitTime   int64        `json:"visitTime"` // Unix milliseconds of the sign-up page visit
+}
+
+// WatchlistAlertDTO reports visits of a user to a watchlisted domain found
+// in newly scanned history. It carries the domain and the matched entry,
+// not the URL.
+type WatchlistAlertDTO struct {
+	ScanID     string       `json:"scanId"`
+	Source     string       `json:"source"`
+	Host       string       `json:"host"`
+	DeviceID   string       `json:"deviceId"`
+	Principal  PrincipalDTO `json:"principal"`
+	Browser    string       `json:"browser"`
+	Profile    string       `json:"profile"`
+	Time       int64        `json:"time"`     // Unix milliseconds
+	Kind       string       `json:"kind"`     // watchlist
+	Priority   string       `json:"priority"` // high
+	Domain     string       `json:"domain"`   // Visited host
+	Match      string       `json:"match"`    // Matched watchlist entry
+	Visits     int          `json:"visits"`
+	FirstVisit int64        `json:"firstVisit"` // Unix milliseconds
+	LastVisit  int64        `json:"lastVisit"`  // Unix milliseconds
+}
+
+// AIToolEventDTO reports a user's first use of a generative-AI service
+// (ai_tools: escalate)
+type AIToolEventDTO struct {
+	ScanID    string       `json:"scanId"`
+	Source    string       `json:"source"`
+	Host      string       `json:"host"`
+	DeviceID  string       `json:"deviceId"`
+	Principal PrincipalDTO `json:"principal"`
+	Browser   string       `json:"browser"`
+	Profile   string       `json:"profile"`
+	Time      int64        `json:"time"`     // Unix milliseconds
+	Kind      string       `json:"kind"`     // ai-tool
+	Priority  string       `json:"priority"` // high
+	App       string       `json:"app"`
+	Risk      string       `json:"risk,omitempty"`
+	Domain    string       `json:"domain"`
+	VisitTime int64        `json:"visitTime"` // Unix milliseconds of the first visit
+}
+
+// AIUsageDTO reports a user's visits to generative-AI services in one scan
+// (ai_tools: aggregate or escalate)
+type AIUsageDTO struct {
+	ScanID    string         `json:"scanId"`
+	Source    string         `json:"source"`
+	Host      string         `json:"host"`
+	DeviceID  string         `json:"deviceId"`
+	Principal PrincipalDTO   `json:"principal"`
+	Time      int64          `json:"time"` // Unix milliseconds
+	Kind      string         `json:"kind"` // ai-usage
+	Visits    int            `json:"visits"`
+	Tools     []AIToolVisits `json:"tools"` // Most visited first
+}
+
+// AIToolVisits counts the visits to one AI service
+type AIToolVisits struct {
+	App    string `json:"app"`
+	Risk   string `json:"risk,omitempty"`
+	Visits int    `json:"visits"`
+}
+
+// CorruptDTO is a profile whose history was salvaged from a damaged database
+type CorruptDTO struct {
+	User        string `json:"user"`
+	Browser     string `json:"browser"`
+	Profile     string `json:"profile"`
+	SkippedRows int    `json:"skippedRows"`
+	Problem     string `json:"problem,omitempty"` // First integrity_check message
+}
+
+// ProfileErrorDTO is a profile that could not be scanned, or a user's home
+// (no browser) or browser data (no profile) that could not be read
+type ProfileErrorDTO struct {
+	User     string `json:"user"`
+	Browser  string `json:"browser,omitempty"`
+	Profile  string `json:"profile,omitempty"`
+	Category string `json:"category"` // permission, locked, schema, corrupt, timeout, copy_refused, send or read
+	Severity string `json:"severity"` // error or warning (error_severity)
+	Error    string `json:"error"`
+}
+
+// PostponedDTO is a profile left to the next run because its browser was
+// running (running_browsers: next-run)
+type PostponedDTO struct {
+	User    string `json:"user"`
+	Browser string `json:"browser"`
+	Profile string `json:"profile"`
+	Runs    int    `json:"runs"` // Runs in a row that left it to the next one
+}
+
+// SkippedUserDTO is a user whose home was skipped rather than scanned
+type SkippedUserDTO struct {
+	User   string `json:"user"`
+	Reason string `json:"reason"` // inaccessible-encrypted, home-unavailable, account-disabled, stale
+	Detail string `json:"detail,omitempty"`
+}
+
+// DeviceKeyDTO registers a device's upload signing key with the server
+type DeviceKeyDTO struct {
+	Source    string `json:"source"`
+	Host      string `json:"host"`
+	DeviceID  string `json:"deviceId"`
+	KeyID     string `json:"keyId"`     // RFC 7638 thumbprint, the kid of signatures
+	PublicKey JWKDTO `json:"publicKey"` // Ed25519 public key
+	Created   int64  `json:"created"`   // Unix milliseconds
+}
+
+// JWKDTO is a public key in JSON Web Key form
+type JWKDTO struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+}
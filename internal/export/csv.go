@@ -0,0 +1,35 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"time"
+)
+
+// csvWriter writes one row per visit
+type csvWriter struct {
+	w *csv.Writer
+}
+
+// newCSVWriter writes the header row and returns the writer
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w)}
+	if err := cw.w.Write([]string{"user", "browser", "profile", "time", "domain", "url"}); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+// Write writes a visit row
+func (c *csvWriter) Write(v Visit) error {
+	return c.w.Write([]string{v.User, v.Browser, v.Profile, v.Time.Format(time.RFC3339), v.Domain, v.URL})
+}
+
+// Close flushes the buffered rows
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package export writes locally scanned browser history as reports for
+// review on the machine itself (incident response, audits).
+package export
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats lists the supported export formats
+var Formats = []string{"html", "csv", "jsonl"}
+
+// Visit is one history entry of a report
+type Visit struct {
+	User    string    `json:"user"`
+	Browser string    `json:"browser"`
+	Profile string    `json:"profile"`
+	Time    time.Time `json:"time"`
+	URL     string    `json:"url"`
+	Domain  string    `json:"domain"`
+}
+
+// Meta describes the scan a report was made from
+type Meta struct {
+	Host      string
+	Generated time.Time
+	Since     time.Time
+	Until     time.Time // Zero if the report runs up to Generated
+}
+
+// Writer receives the visits of a report. Close completes the report; it
+// does not close the underlying io.Writer.
+type Writer interface {
+	Write(v Visit) error
+	Close() error
+}
+
+// NewWriter returns a Writer for format (see Formats)
+func NewWriter(format string, w io.Writer, meta Meta) (Writer, error) {
+	switch format {
+	case "html":
+		return newHTMLWriter(w, meta), nil
+	case "csv":
+		return newCSVWriter(w)
+	case "jsonl":
+		return newJSONLWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unknown format %q (supported: %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+// FormatFromPath returns the format matching a file extension, or ""
+func FormatFromPath(path string) string {
+	// Encrypted exports, e.g. visits.csv.enc
+	path = strings.TrimSuffix(path, ".enc")
+	switch {
+	case strings.HasSuffix(path, ".html"), strings.HasSuffix(path, ".htm"):
+		return "html"
+	case strings.HasSuffix(path, ".csv"):
+		return "csv"
+	case strings.HasSuffix(path, ".jsonl"), strings.HasSuffix(path, ".ndjson"):
+		return "jsonl"
+	default:
+		return ""
+	}
+}
+
+// Domain returns the host name of a URL without a leading "www.", or the
+// scheme for URLs without a host (file:, about:)
+func Domain(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	if u.Hostname() == "" {
+		return u.Scheme + ":"
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// ParseSince parses a lower time bound: a number of days ("30d"), weeks
+// ("2w"), a Go duration ("12h") or a date ("2025-01-31")
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	if n, unit := len(s)-1, ""; n > 0 {
+		unit = s[n:]
+		if days, err := strconv.Atoi(s[:n]); err == nil && days >= 0 {
+			switch unit {
+			case "d":
+				return now.AddDate(0, 0, -days), nil
+			case "w":
+				return now.AddDate(0, 0, -7*days), nil
+			}
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("invalid time %q: use e.g. 30d, 2w, 12h or 2025-01-31", s)
+	}
+	return now.Add(-d), nil
+}
+
+// ParseUntil parses an upper time bound like ParseSince. A date includes
+// that whole day, so "2025-03-05" ends at midnight of March 6.
+func ParseUntil(s string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t.AddDate(0, 0, 1), nil
+	}
+	return ParseSince(s, now)
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package export
+
+import (
+	"html/template"
+	"io"
+	"sort"
+	"time"
+)
+
+// maxURLsPerDomain is the number of most recent distinct URLs listed per
+// domain in HTML reports
+const maxURLsPerDomain = 25
+
+// htmlWriter aggregates visits by user, browser and domain and renders them
+// on Close
+type htmlWriter struct {
+	w     io.Writer
+	meta  Meta
+	users map[string]map[string]map[string]*domainStats // user -> browser -> domain
+	total int
+}
+
+// domainStats aggregates the visits of one domain
+type domainStats struct {
+	Domain string
+	Visits int
+	First  time.Time
+	Last   time.Time
+	URLs   map[string]time.Time // Most recent distinct URLs with their last visit
+}
+
+// newHTMLWriter returns an HTML report writer
+func newHTMLWriter(w io.Writer, meta Meta) *htmlWriter {
+	return &htmlWriter{w: w, meta: meta, users: make(map[string]map[string]map[string]*domainStats)}
+}
+
+// Write adds a visit to the aggregates
+func (h *htmlWriter) Write(v Visit) error {
+	browsers := h.users[v.User]
+	if browsers == nil {
+		browsers = make(map[string]map[string]*domainStats)
+		h.users[v.User] = browsers
+	}
+	domains := browsers[v.Browser]
+	if domains == nil {
+		domains = make(map[string]*domainStats)
+		browsers[v.Browser] = domains
+	}
+	d := domains[v.Domain]
+	if d == nil {
+		d = &domainStats{Domain: v.Domain, First: v.Time, Last: v.Time, URLs: make(map[string]time.Time)}
+		domains[v.Domain] = d
+	}
+
+	h.total++
+	d.Visits++
+	if v.Time.Before(d.First) {
+		d.First = v.Time
+	}
+	if v.Time.After(d.Last) {
+		d.Last = v.Time
+	}
+	if last, ok := d.URLs[v.URL]; !ok || v.Time.After(last) {
+		d.URLs[v.URL] = v.Time
+		if len(d.URLs) > maxURLsPerDomain {
+			dropOldest(d.URLs)
+		}
+	}
+	return nil
+}
+
+// dropOldest removes the least recently visited URL
+func dropOldest(urls map[string]time.Time) {
+	var oldest string
+	for u, t := range urls {
+		if oldest == "" || t.Before(urls[oldest]) {
+			oldest = u
+		}
+	}
+	delete(urls, oldest)
+}
+
+// Close renders the report
+func (h *htmlWriter) Close() error {
+	type urlRow struct {
+		URL  string
+		Last time.Time
+	}
+	type domainRow struct {
+		*domainStats
+		Recent []urlRow
+	}
+	type browserSection struct {
+		Name    string
+		Visits  int
+		Domains []domainRow
+	}
+	type userSection struct {
+		Name     string
+		Visits   int
+		Browsers []browserSection
+	}
+
+	var users []userSection
+	for _, user := range sortedKeys(h.users) {
+		us := userSection{Name: user}
+		for _, name := range sortedKeys(h.users[user]) {
+			bs := browserSection{Name: name}
+			for _, d := range h.users[user][name] {
+				row := domainRow{domainStats: d}
+				for u, t := range d.URLs {
+					row.Recent = append(row.Recent, urlRow{URL: u, Last: t})
+				}
+				sort.Slice(row.Recent, func(i, j int) bool { return row.Recent[i].Last.After(row.Recent[j].Last) })
+				bs.Domains = append(bs.Domains, row)
+				bs.Visits += d.Visits
+			}
+			sort.Slice(bs.Domains, func(i, j int) bool {
+				if bs.Domains[i].Visits != bs.Domains[j].Visits {
+					return bs.Domains[i].Visits > bs.Domains[j].Visits
+				}
+				return bs.Domains[i].Domain < bs.Domains[j].Domain
+			})
+			us.Browsers = append(us.Browsers, bs)
+			us.Visits += bs.Visits
+		}
+		users = append(users, us)
+	}
+
+	return htmlTemplate.Execute(h.w, struct {
+		Meta
+		Total int
+		Users []userSection
+	}{h.meta, h.total, users})
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"datetime": func(t time.Time) string { return t.Format("2006-01-02 15:04") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Browser history report: {{.Host}}</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1.5em; }
+th, td { text-align: left; padding: 4px 8px; border-bottom: 1px solid #ddd; vertical-align: top; }
+th { background: #f4f4f4; }
+td.n { text-align: right; }
+details ul { margin: 4px 0; padding-left: 1.2em; font-size: 90%; word-break: break-all; }
+.meta { color: #666; }
+</style>
+</head>
+<body>
+<h1>Browser history report</h1>
+<p class="meta">Host {{.Host}} &middot; generated {{datetime .Generated}} &middot; visits since {{datetime .Since}}{{if not .Until.IsZero}} until {{datetime .Until}}{{end}} &middot; {{.Total}} visits, {{len .Users}} user(s)</p>
+{{range .Users}}
+<h2>{{.Name}} <small class="meta">{{.Visits}} visits</small></h2>
+{{range .Browsers}}
+<h3>{{.Name}} <small class="meta">{{.Visits}} visits, {{len .Domains}} domains</small></h3>
+<table>
+<tr><th>Domain</th><th>Visits</th><th>First</th><th>Last</th></tr>
+{{range .Domains}}
+<tr>
+<td><details><summary>{{.Domain}}</summary><ul>{{range .Recent}}<li>{{datetime .Last}} {{.URL}}</li>{{end}}</ul></details></td>
+<td class="n">{{.Visits}}</td><td>{{datetime .First}}</td><td>{{datetime .Last}}</td>
+</tr>
+{{end}}
+</table>
+{{end}}
+{{else}}
+<p>No history found.</p>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,31 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package export
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// jsonlWriter writes one JSON object per line and visit
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+// newJSONLWriter returns a JSON Lines writer
+func newJSONLWriter(w io.Writer) *jsonlWriter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &jsonlWriter{enc: enc}
+}
+
+// Write writes a visit line
+func (j *jsonlWriter) Write(v Visit) error {
+	return j.enc.Encode(v)
+}
+
+// Close does nothing; lines are written as they come
+func (j *jsonlWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package export
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"hist_scanner/internal/catalog"
+)
+
+// UsageReport aggregates visits by user and registrable domain (eTLD+1) and
+// tags them with the catalog's SaaS services. It is a Writer; Print renders
+// it once all visits are written.
+type UsageReport struct {
+	meta       Meta
+	catalog    *catalog.Catalog
+	sanctioned catalog.Sanctioned
+	users      map[string]*userUsage
+}
+
+// userUsage aggregates the visits of one user
+type userUsage struct {
+	visits   int
+	domains  map[string]int           // Visits by registrable domain
+	services map[string]*serviceUsage // By service name
+}
+
+// serviceUsage aggregates one user's visits to one service
+type serviceUsage struct {
+	service *catalog.Service
+	visits  int
+	last    time.Time
+	domains map[string]bool
+}
+
+// NewUsageReport returns a report tagging domains with c. Services named in
+// sanctioned, by service name or domain, are approved and not listed.
+func NewUsageReport(meta Meta, c *catalog.Catalog, sanctioned []string) *UsageReport {
+	return &UsageReport{meta: meta, catalog: c, sanctioned: sanctioned, users: make(map[string]*userUsage)}
+}
+
+// Write adds a visit to the aggregates
+func (r *UsageReport) Write(v Visit) error {
+	u := r.users[v.User]
+	if u == nil {
+		u = &userUsage{domains: make(map[string]int), services: make(map[string]*serviceUsage)}
+		r.users[v.User] = u
+	}
+
+	domain := catalog.RegistrableDomain(v.Domain)
+	u.visits++
+	u.domains[domain]++
+
+	service, ok := r.catalog.Lookup(v.Domain)
+	if !ok {
+		return nil
+	}
+	s := u.services[service.Name]
+	if s == nil {
+		s = &serviceUsage{service: service, domains: make(map[string]bool)}
+		u.services[service.Name] = s
+	}
+	s.visits++
+	s.domains[domain] = true
+	if v.Time.After(s.last) {
+		s.last = v.Time
+	}
+	return nil
+}
+
+// Close does nothing; the report is rendered by Print
+func (r *UsageReport) Close() error {
+	return nil
+}
+
+// Print writes the top unsanctioned services of each user, then a summary
+// of unsanctioned services across users
+func (r *UsageReport) Print(out io.Writer, top int) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Shadow IT report for %s, visits since %s\n", r.meta.Host, r.meta.Since.Format(time.DateOnly))
+	fmt.Fprintf(w, "Catalog: %d services, %d sanctioned\n", r.catalog.Len(), len(r.sanctioned))
+
+	type total struct {
+		service *catalog.Service
+		users   int
+		visits  int
+	}
+	totals := make(map[string]*total)
+
+	for _, name := range sortedKeys(r.users) {
+		u := r.users[name]
+
+		var unsanctioned []*serviceUsage
+		for _, s := range u.services {
+			if r.sanctioned.Service(s.service) {
+				continue
+			}
+			unsanctioned = append(unsanctioned, s)
+
+			t := totals[s.service.Name]
+			if t == nil {
+				t = &total{service: s.service}
+				totals[s.service.Name] = t
+			}
+			t.users++
+			t.visits += s.visits
+		}
+		sort.Slice(unsanctioned, func(i, j int) bool {
+			if unsanctioned[i].visits != unsanctioned[j].visits {
+				return unsanctioned[i].visits > unsanctioned[j].visits
+			}
+			return unsanctioned[i].service.Name < unsanctioned[j].service.Name
+		})
+
+		fmt.Fprintf(w, "\n%s: %d visits to %d domains, %d SaaS services (%d unsanctioned)\n",
+			name, u.visits, len(u.domains), len(u.services), len(unsanctioned))
+		if len(unsanctioned) == 0 {
+			continue
+		}
+		fmt.Fprintln(w, "  SERVICE\tCATEGORY\tRISK\tVISITS\tLAST SEEN\tDOMAINS")
+		for i, s := range unsanctioned {
+			if top > 0 && i >= top {
+				fmt.Fprintf(w, "  ... and %d more\n", len(unsanctioned)-top)
+				break
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%d\t%s\t%s\n", s.service.Name, s.service.Category, s.service.Risk, s.visits,
+				s.last.Format("2006-01-02 15:04"), strings.Join(sortedKeys(s.domains), ", "))
+		}
+	}
+
+	if len(totals) > 0 {
+		sorted := make([]*total, 0, len(totals))
+		for _, t := range totals {
+			sorted = append(sorted, t)
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			if sorted[i].users != sorted[j].users {
+				return sorted[i].users > sorted[j].users
+			}
+			if sorted[i].visits != sorted[j].visits {
+				return sorted[i].visits > sorted[j].visits
+			}
+			return sorted[i].service.Name < sorted[j].service.Name
+		})
+
+		fmt.Fprintln(w, "\nUnsanctioned services across users:")
+		fmt.Fprintln(w, "  SERVICE\tCATEGORY\tRISK\tUSERS\tVISITS")
+		for _, t := range sorted {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%d\t%d\n", t.service.Name, t.service.Category, t.service.Risk, t.users, t.visits)
+		}
+	}
+
+	return w.Flush()
+}
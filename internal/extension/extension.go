@@ -0,0 +1,197 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package extension lists the extensions installed in a browser profile and
+// rates the access their permissions give them
+package extension
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Extension is an extension installed in a browser profile
+type Extension struct {
+	ID              string
+	Name            string
+	Version         string
+	Permissions     []string // API permissions, e.g. cookies
+	HostPermissions []string // Match patterns of the sites it can access
+	Risk            string   // low, medium or high (Score)
+	Reasons         []string // Why it has its risk
+}
+
+// List returns the extensions of a Chromium (Extensions directory) or
+// Firefox (extensions.json) profile. A profile without extensions has none.
+func List(profilePath string) ([]Extension, error) {
+	exts, err := listFirefox(filepath.Join(profilePath, "extensions.json"))
+	if !errors.Is(err, os.ErrNotExist) {
+		return exts, err
+	}
+	exts, err = listChromium(filepath.Join(profilePath, "Extensions"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return exts, err
+}
+
+// chromiumManifest is the part of a Chromium manifest.json that is read
+type chromiumManifest struct {
+	Name            string            `json:"name"`
+	Version         string            `json:"version"`
+	DefaultLocale   string            `json:"default_locale"`
+	Permissions     []json.RawMessage `json:"permissions"` // Strings, or objects in old manifests
+	HostPermissions []string          `json:"host_permissions"`
+	ContentScripts  []struct {
+		Matches []string `json:"matches"`
+	} `json:"content_scripts"`
+}
+
+// listChromium reads Extensions/<id>/<version>/manifest.json, the newest
+// version of each extension
+func listChromium(dir string) ([]Extension, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var exts []Extension
+	for _, e := range entries {
+		if !e.IsDir() || e.Name() == "Temp" {
+			continue
+		}
+		versionDir := newestVersion(filepath.Join(dir, e.Name()))
+		if versionDir == "" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(versionDir, "manifest.json"))
+		if err != nil {
+			continue
+		}
+		var m chromiumManifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			continue
+		}
+
+		ext := Extension{ID: e.Name(), Name: localize(versionDir, m.DefaultLocale, m.Name), Version: m.Version}
+		for _, raw := range m.Permissions {
+			var p string
+			if json.Unmarshal(raw, &p) != nil {
+				continue
+			}
+			// Manifest V2 lists host patterns with the permissions
+			if isHostPattern(p) {
+				ext.HostPermissions = append(ext.HostPermissions, p)
+			} else {
+				ext.Permissions = append(ext.Permissions, p)
+			}
+		}
+		ext.HostPermissions = append(ext.HostPermissions, m.HostPermissions...)
+		for _, cs := range m.ContentScripts {
+			ext.HostPermissions = append(ext.HostPermissions, cs.Matches...)
+		}
+		slices.Sort(ext.HostPermissions)
+		ext.HostPermissions = slices.Compact(ext.HostPermissions)
+		exts = append(exts, ext)
+	}
+	return exts, nil
+}
+
+// newestVersion returns the most recently installed version directory of
+// an extension; Chrome keeps the previous version until it is no longer in
+// use
+func newestVersion(dir string) string {
+	versions, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	var newest string
+	var newestTime time.Time
+	for _, v := range versions {
+		info, err := v.Info()
+		if err != nil || !v.IsDir() {
+			continue
+		}
+		if newest == "" || info.ModTime().After(newestTime) {
+			newest, newestTime = filepath.Join(dir, v.Name()), info.ModTime()
+		}
+	}
+	return newest
+}
+
+// localize resolves a __MSG_name__ manifest string from the default locale
+func localize(dir, locale, s string) string {
+	key, ok := strings.CutPrefix(s, "__MSG_")
+	if key, ok = strings.CutSuffix(key, "__"); !ok || locale == "" {
+		return s
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "_locales", locale, "messages.json"))
+	if err != nil {
+		return s
+	}
+	var messages map[string]struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &messages) != nil {
+		return s
+	}
+	for k, m := range messages {
+		if strings.EqualFold(k, key) {
+			return m.Message
+		}
+	}
+	return s
+}
+
+// firefoxAddons is the part of Firefox's extensions.json that is read
+type firefoxAddons struct {
+	Addons []struct {
+		ID            string `json:"id"`
+		Type          string `json:"type"`
+		Version       string `json:"version"`
+		Location      string `json:"location"`
+		DefaultLocale struct {
+			Name string `json:"name"`
+		} `json:"defaultLocale"`
+		UserPermissions *struct {
+			Permissions []string `json:"permissions"`
+			Origins     []string `json:"origins"`
+		} `json:"userPermissions"`
+	} `json:"addons"`
+}
+
+// listFirefox reads the extensions of extensions.json, without the ones
+// Firefox ships (app-builtin, app-system-*)
+func listFirefox(path string) ([]Extension, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc firefoxAddons
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	var exts []Extension
+	for _, a := range doc.Addons {
+		if a.Type != "extension" || strings.HasPrefix(a.Location, "app-") {
+			continue
+		}
+		ext := Extension{ID: a.ID, Name: a.DefaultLocale.Name, Version: a.Version}
+		if a.UserPermissions != nil {
+			ext.Permissions = a.UserPermissions.Permissions
+			ext.HostPermissions = a.UserPermissions.Origins
+		}
+		exts = append(exts, ext)
+	}
+	return exts, nil
+}
+
+// isHostPattern reports whether a permission is a match pattern
+func isHostPattern(p string) bool {
+	return p == "<all_urls>" || strings.Contains(p, "://")
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package extension
+
+import (
+	"slices"
+	"strings"
+)
+
+// Risk levels, lowest first, as in the SaaS catalog
+const (
+	RiskLow    = "low"
+	RiskMedium = "medium"
+	RiskHigh   = "high"
+)
+
+// sensitivePermissions read or change what users do in any site, or reach
+// outside the browser
+var sensitivePermissions = map[string]string{
+	"clipboardRead":                       "reads the clipboard",
+	"cookies":                             "reads cookies",
+	"debugger":                            "controls pages through the debugger",
+	"declarativeNetRequestWithHostAccess": "rewrites requests",
+	"desktopCapture":                      "captures the screen",
+	"history":                             "reads browsing history",
+	"management":                          "manages other extensions",
+	"nativeMessaging":                     "talks to local programs",
+	"proxy":                               "routes traffic through a proxy",
+	"tabCapture":                          "captures tabs",
+	"webRequest":                          "intercepts requests",
+	"webRequestBlocking":                  "blocks and changes requests",
+}
+
+// Rules rate extensions. Risky lists the ids of extensions that are high
+// risk regardless of their permissions.
+type Rules struct {
+	Risky []string
+}
+
+// Score sets the risk of an extension and the reasons for it:
+//   - high: a risky id, the debugger, or access to all sites combined with a
+//     sensitive permission
+//   - medium: access to all sites, or a sensitive permission
+//   - low: neither
+func (r Rules) Score(e *Extension) {
+	var reasons []string
+	risky := slices.ContainsFunc(r.Risky, func(id string) bool { return strings.EqualFold(id, e.ID) })
+	if risky {
+		reasons = append(reasons, "listed as risky")
+	}
+	allSites := slices.ContainsFunc(e.HostPermissions, isAllSites)
+	if allSites {
+		reasons = append(reasons, "reads and changes data on all sites")
+	}
+	var sensitive []string
+	for _, p := range e.Permissions {
+		if reason, ok := sensitivePermissions[p]; ok {
+			sensitive = append(sensitive, p)
+			reasons = append(reasons, reason)
+		}
+	}
+
+	switch {
+	case risky || slices.Contains(sensitive, "debugger") || (allSites && len(sensitive) > 0):
+		e.Risk = RiskHigh
+	case allSites || len(sensitive) > 0:
+		e.Risk = RiskMedium
+	default:
+		e.Risk = RiskLow
+	}
+	e.Reasons = reasons
+}
+
+// isAllSites reports whether a match pattern covers every site
+func isAllSites(pattern string) bool {
+	switch pattern {
+	case "<all_urls>", "*://*/*", "http://*/*", "https://*/*", "*://*/", "http://*/", "https://*/":
+		return true
+	}
+	return false
+}
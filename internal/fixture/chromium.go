@@ -0,0 +1,97 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package fixture
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// chromiumSchema is the part of a Chromium History database the scanner and
+// other history tools read (schema version 67)
+const chromiumSchema = `
+CREATE TABLE meta(key LONGVARCHAR NOT NULL UNIQUE PRIMARY KEY, value LONGVARCHAR);
+CREATE TABLE urls(id INTEGER PRIMARY KEY AUTOINCREMENT, url LONGVARCHAR, title LONGVARCHAR, visit_count INTEGER DEFAULT 0 NOT NULL, typed_count INTEGER DEFAULT 0 NOT NULL, last_visit_time INTEGER NOT NULL, hidden INTEGER DEFAULT 0 NOT NULL);
+CREATE TABLE visits(id INTEGER PRIMARY KEY AUTOINCREMENT, url INTEGER NOT NULL, visit_time INTEGER NOT NULL, from_visit INTEGER, transition INTEGER DEFAULT 0 NOT NULL, segment_id INTEGER, visit_duration INTEGER DEFAULT 0 NOT NULL, incremented_omnibox_typed_score BOOLEAN DEFAULT FALSE NOT NULL);
+CREATE INDEX urls_url_index ON urls (url);
+CREATE INDEX visits_url_index ON visits (url);
+CREATE INDEX visits_time_index ON visits (visit_time);
+INSERT INTO meta VALUES ('version', '67'), ('last_compatible_version', '16'), ('mmap_status', '-1');
+`
+
+// chromiumVersion is the browser version fixtures were last opened with
+const chromiumVersion = "126.0.6478.127"
+
+// Chromium page transitions: link or typed, as the start and end of a
+// redirect chain
+const (
+	transitionLink  = 0x30000000
+	transitionTyped = 0x30000001
+)
+
+// chromiumTime converts a time to microseconds since 1601-01-01
+func chromiumTime(t time.Time) int64 {
+	return t.UnixMicro() + 11644473600*1000000
+}
+
+// writeChromium writes the History database and Preferences of a Chromium
+// profile named name in dir
+func writeChromium(dir, name string, visits []Visit, created time.Time) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create profile directory: %w", err)
+	}
+	err := writeDatabase(filepath.Join(dir, "History"), chromiumSchema, func(tx *sql.Tx) error {
+		ids := make(map[string]int64)
+		for _, v := range visits {
+			transition, typed := transitionLink, 0
+			if v.Typed {
+				transition, typed = transitionTyped, 1
+			}
+			id, ok := ids[v.URL]
+			if ok {
+				if _, err := tx.Exec(`UPDATE urls SET visit_count = visit_count + 1, typed_count = typed_count + ?, last_visit_time = ?, title = ? WHERE id = ?`,
+					typed, chromiumTime(v.Time), v.Title, id); err != nil {
+					return err
+				}
+			} else {
+				res, err := tx.Exec(`INSERT INTO urls (url, title, visit_count, typed_count, last_visit_time) VALUES (?, ?, 1, ?, ?)`,
+					v.URL, v.Title, typed, chromiumTime(v.Time))
+				if err != nil {
+					return err
+				}
+				if id, err = res.LastInsertId(); err != nil {
+					return err
+				}
+				ids[v.URL] = id
+			}
+			if _, err := tx.Exec(`INSERT INTO visits (url, visit_time, from_visit, transition, segment_id) VALUES (?, ?, 0, ?, 0)`,
+				id, chromiumTime(v.Time), transition); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The scanner reads creation_time to tell a recreated profile
+	prefs := map[string]any{"profile": map[string]any{
+		"creation_time": strconv.FormatInt(chromiumTime(created), 10),
+		"name":          name,
+	}}
+	data, err := json.Marshal(prefs)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "Preferences"), data, 0600); err != nil {
+		return fmt.Errorf("failed to write Preferences: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package fixture
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// firefoxSchema is the part of places.sqlite the scanner and other history
+// tools read (schema version 75)
+const firefoxSchema = `
+CREATE TABLE moz_origins (id INTEGER PRIMARY KEY, prefix TEXT NOT NULL, host TEXT NOT NULL, frecency INTEGER NOT NULL, UNIQUE (prefix, host));
+CREATE TABLE moz_places (id INTEGER PRIMARY KEY, url LONGVARCHAR, title LONGVARCHAR, rev_host LONGVARCHAR, visit_count INTEGER DEFAULT 0, hidden INTEGER DEFAULT 0 NOT NULL, typed INTEGER DEFAULT 0 NOT NULL, frecency INTEGER DEFAULT -1 NOT NULL, last_visit_date INTEGER, guid TEXT, foreign_count INTEGER DEFAULT 0 NOT NULL, url_hash INTEGER DEFAULT 0 NOT NULL, description TEXT, preview_image_url TEXT, site_name TEXT, origin_id INTEGER REFERENCES moz_origins(id));
+CREATE TABLE moz_historyvisits (id INTEGER PRIMARY KEY, from_visit INTEGER, place_id INTEGER, visit_date INTEGER, visit_type INTEGER, session INTEGER, source INTEGER DEFAULT 0 NOT NULL, triggeringPlaceId INTEGER);
+CREATE UNIQUE INDEX moz_places_url_uniqueindex ON moz_places (url);
+CREATE INDEX moz_historyvisits_placedateindex ON moz_historyvisits (place_id, visit_date);
+CREATE INDEX moz_historyvisits_dateindex ON moz_historyvisits (visit_date);
+PRAGMA user_version = 75;
+`
+
+// firefoxVersion is the compatibility.ini LastVersion fixtures were last
+// opened with
+const firefoxVersion = "128.0_20240704121409/20240704121409"
+
+// Firefox visit types
+const (
+	visitLink  = 1
+	visitTyped = 2
+)
+
+// firefoxProfile is a Firefox profile directory under the data directory
+type firefoxProfile struct {
+	name string // e.g. default-release
+	dir  string // e.g. 3x8kq2lp.default-release
+}
+
+// newFirefoxProfile returns a profile with the random directory prefix
+// Firefox gives new profiles
+func newFirefoxProfile(rng *rand.Rand, name string) firefoxProfile {
+	const chars = "abcdefghijklmnopqrstuvwxyz0123456789"
+	prefix := make([]byte, 8)
+	for i := range prefix {
+		prefix[i] = chars[rng.IntN(len(chars))]
+	}
+	return firefoxProfile{name: name, dir: string(prefix) + "." + name}
+}
+
+// writeFirefox writes the places.sqlite database and times.json of a
+// Firefox profile in dir
+func writeFirefox(dir string, visits []Visit, created time.Time, rng *rand.Rand) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create profile directory: %w", err)
+	}
+	err := writeDatabase(filepath.Join(dir, "places.sqlite"), firefoxSchema, func(tx *sql.Tx) error {
+		places, origins := make(map[string]int64), make(map[string]int64)
+		for _, v := range visits {
+			visitType, typed := visitLink, 0
+			if v.Typed {
+				visitType, typed = visitTyped, 1
+			}
+			id, ok := places[v.URL]
+			if ok {
+				if _, err := tx.Exec(`UPDATE moz_places SET visit_count = visit_count + 1, typed = max(typed, ?), last_visit_date = ?, title = ? WHERE id = ?`,
+					typed, v.Time.UnixMicro(), v.Title, id); err != nil {
+					return err
+				}
+			} else {
+				originID, err := firefoxOrigin(tx, origins, v.URL)
+				if err != nil {
+					return err
+				}
+				res, err := tx.Exec(`INSERT INTO moz_places (url, title, rev_host, visit_count, typed, frecency, last_visit_date, guid, origin_id) VALUES (?, ?, ?, 1, ?, 100, ?, ?, ?)`,
+					v.URL, v.Title, revHost(v.URL), typed, v.Time.UnixMicro(), guid(rng), originID)
+				if err != nil {
+					return err
+				}
+				if id, err = res.LastInsertId(); err != nil {
+					return err
+				}
+				places[v.URL] = id
+			}
+			if _, err := tx.Exec(`INSERT INTO moz_historyvisits (from_visit, place_id, visit_date, visit_type, session) VALUES (0, ?, ?, ?, 0)`,
+				id, v.Time.UnixMicro(), visitType); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The scanner reads created to tell a recreated profile
+	times := fmt.Sprintf(`{"created":%d,"firstUse":null}`, created.UnixMilli())
+	if err := os.WriteFile(filepath.Join(dir, "times.json"), []byte(times), 0600); err != nil {
+		return fmt.Errorf("failed to write times.json: %w", err)
+	}
+	compat := "[Compatibility]\nLastVersion=" + firefoxVersion + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "compatibility.ini"), []byte(compat), 0600); err != nil {
+		return fmt.Errorf("failed to write compatibility.ini: %w", err)
+	}
+	return nil
+}
+
+// writeProfilesIni lists the profiles in the profiles.ini of a Firefox data
+// directory; the first one is the default
+func writeProfilesIni(dataDir string, profiles []firefoxProfile) error {
+	var b strings.Builder
+	for i, p := range profiles {
+		fmt.Fprintf(&b, "[Profile%d]\nName=%s\nIsRelative=1\nPath=%s\n", i, p.name, p.dir)
+		if i == 0 {
+			b.WriteString("Default=1\n")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("[General]\nStartWithLastProfile=1\nVersion=2\n")
+	if err := os.WriteFile(filepath.Join(dataDir, "profiles.ini"), []byte(b.String()), 0600); err != nil {
+		return fmt.Errorf("failed to write profiles.ini: %w", err)
+	}
+	return nil
+}
+
+// firefoxOrigin returns the moz_origins id of a URL's scheme and host,
+// adding it, or nil for URLs without a host
+func firefoxOrigin(tx *sql.Tx, origins map[string]int64, rawURL string) (any, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, nil
+	}
+	prefix := u.Scheme + "://"
+	if id, ok := origins[prefix+u.Host]; ok {
+		return id, nil
+	}
+	res, err := tx.Exec(`INSERT INTO moz_origins (prefix, host, frecency) VALUES (?, ?, 100)`, prefix, u.Host)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	origins[prefix+u.Host] = id
+	return id, nil
+}
+
+// revHost returns the reversed host of a URL followed by a dot, as Firefox
+// indexes it ("moc.elpmaxe.www.")
+func revHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	host := []rune(u.Hostname())
+	for i, j := 0, len(host)-1; i < j; i, j = i+1, j-1 {
+		host[i], host[j] = host[j], host[i]
+	}
+	return string(host) + "."
+}
+
+// guid returns a random 12-character place GUID
+func guid(rng *rand.Rand) string {
+	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+	b := make([]byte, 12)
+	for i := range b {
+		b[i] = chars[rng.IntN(len(chars))]
+	}
+	return string(b)
+}
@@ -0,0 +1,219 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package fixture generates realistic browser history databases for
+// integration tests: a system root with users' homes holding Chromium and
+// Firefox profiles, laid out like a Linux, macOS or Windows disk so that
+// run --root scans them.
+package fixture
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"hist_scanner/internal/browser"
+	"hist_scanner/internal/db"
+	"hist_scanner/internal/platform"
+)
+
+// Options configures Generate
+type Options struct {
+	Root     string      // Directory the system root is created in
+	OS       platform.OS // Layout of the root; empty means Linux
+	Users    []string
+	Browsers []string  // Chromium-based browsers and firefox
+	Profiles int       // Profiles per user and browser; browsers without profiles get one
+	Visits   int       // Visits per profile
+	Days     int       // Days up to Now the visits are spread over
+	Seed     uint64    // Equal options and seeds give equal fixtures
+	Now      time.Time // Zero means the current time
+}
+
+// Database is a generated history database
+type Database struct {
+	User    string
+	Browser string
+	Profile string
+	Path    string
+	Visits  int
+}
+
+// Generate creates the users' homes and history databases under opts.Root.
+// Existing databases are never overwritten.
+func Generate(opts Options) ([]Database, error) {
+	if opts.OS == "" {
+		opts.OS = platform.Linux
+	}
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	if len(opts.Users) == 0 || len(opts.Browsers) == 0 || opts.Profiles < 1 || opts.Visits < 0 || opts.Days < 1 {
+		return nil, fmt.Errorf("fixtures need users, browsers, at least one profile and one day")
+	}
+	browsers := make([]browser.Browser, len(opts.Browsers))
+	for i, name := range opts.Browsers {
+		switch b := browser.ByName(strings.ToLower(name)).(type) {
+		case *browser.ChromiumBrowser, *browser.FirefoxBrowser:
+			browsers[i] = b
+		default:
+			return nil, fmt.Errorf("no fixtures for browser %q (supported: Chromium-based browsers and firefox)", name)
+		}
+	}
+
+	rng := rand.New(rand.NewPCG(opts.Seed, opts.Seed))
+	var dbs []Database
+	for i, name := range opts.Users {
+		user, err := createUser(opts.Root, opts.OS, name, i)
+		if err != nil {
+			return dbs, err
+		}
+		for _, b := range browsers {
+			created, err := generateProfiles(opts, rng, user, b)
+			dbs = append(dbs, created...)
+			if err != nil {
+				return dbs, err
+			}
+		}
+	}
+	return dbs, nil
+}
+
+// generateProfiles writes a user's profiles of a browser
+func generateProfiles(opts Options, rng *rand.Rand, user platform.User, b browser.Browser) ([]Database, error) {
+	dataDir := b.(browser.DataDirer).DataDir(user)
+	if dataDir == "" {
+		return nil, fmt.Errorf("%s is not available on %s", b.Name(), opts.OS)
+	}
+	// Profiles were created before their oldest visit
+	created := opts.Now.AddDate(0, 0, -opts.Days-1)
+
+	var dbs []Database
+	switch b := b.(type) {
+	case *browser.ChromiumBrowser:
+		n := opts.Profiles
+		if !b.HasProfiles() {
+			n = 1
+		}
+		for i := range n {
+			name, dir := "Default", dataDir
+			if b.HasProfiles() {
+				if i > 0 {
+					name = fmt.Sprintf("Profile %d", i)
+				}
+				dir = filepath.Join(dataDir, name)
+			}
+			visits := Visits(opts.Visits, opts.Days, rng, opts.Now)
+			if err := writeChromium(dir, fmt.Sprintf("Person %d", i+1), visits, created); err != nil {
+				return dbs, err
+			}
+			dbs = append(dbs, Database{User: user.Username, Browser: b.Name(), Profile: name, Path: b.HistoryFile(browser.Profile{Path: dir}), Visits: len(visits)})
+		}
+		if err := os.WriteFile(filepath.Join(dataDir, "Last Version"), []byte(chromiumVersion), 0600); err != nil {
+			return dbs, fmt.Errorf("failed to write Last Version: %w", err)
+		}
+
+	case *browser.FirefoxBrowser:
+		var profiles []firefoxProfile
+		for i := range opts.Profiles {
+			name := "default-release"
+			if i > 0 {
+				name = fmt.Sprintf("profile%d", i)
+			}
+			p := newFirefoxProfile(rng, name)
+			dir := filepath.Join(dataDir, p.dir)
+			visits := Visits(opts.Visits, opts.Days, rng, opts.Now)
+			if err := writeFirefox(dir, visits, created, rng); err != nil {
+				return dbs, err
+			}
+			profiles = append(profiles, p)
+			dbs = append(dbs, Database{User: user.Username, Browser: b.Name(), Profile: name, Path: b.HistoryFile(browser.Profile{Path: dir}), Visits: len(visits)})
+		}
+		if err := writeProfilesIni(dataDir, profiles); err != nil {
+			return dbs, err
+		}
+	}
+	return dbs, nil
+}
+
+// createUser creates a user's home under root with the files that make the
+// root and the user recognizable to ImageUsers
+func createUser(root string, layout platform.OS, name string, i int) (platform.User, error) {
+	user := platform.User{Username: name, Layout: layout}
+	var dirs, files []string
+	switch layout {
+	case platform.Windows:
+		user.HomeDir = filepath.Join(root, "Users", name)
+		dirs = []string{filepath.Join(root, "Windows", "System32"), filepath.Join(root, "Users", "Public"), user.HomeDir}
+		files = []string{filepath.Join(user.HomeDir, "NTUSER.DAT")}
+	case platform.Darwin:
+		user.HomeDir = filepath.Join(root, "Users", name)
+		dirs = []string{filepath.Join(root, "Users", "Shared"), filepath.Join(user.HomeDir, "Library")}
+	case platform.Linux:
+		user.HomeDir = filepath.Join(root, "home", name)
+		dirs = []string{filepath.Join(root, "etc"), user.HomeDir}
+	default:
+		return user, fmt.Errorf("unsupported fixture OS %q", layout)
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return user, fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+	for _, file := range files {
+		if err := os.WriteFile(file, nil, 0600); err != nil {
+			return user, fmt.Errorf("failed to create %s: %w", file, err)
+		}
+	}
+
+	if layout == platform.Linux {
+		f, err := os.OpenFile(filepath.Join(root, "etc", "passwd"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return user, fmt.Errorf("failed to write etc/passwd: %w", err)
+		}
+		defer f.Close()
+		uid := 1000 + i
+		if _, err := fmt.Fprintf(f, "%s:x:%d:%d:%s:/home/%s:/bin/bash\n", name, uid, uid, name, name); err != nil {
+			return user, fmt.Errorf("failed to write etc/passwd: %w", err)
+		}
+	}
+	return user, nil
+}
+
+// writeDatabase creates a database at path with schema and fills it in one
+// transaction. A database that exists is left alone.
+func writeDatabase(path, schema string, fill func(tx *sql.Tx) error) (err error) {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	database, err := db.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer func() {
+		database.Close()
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+
+	if _, err := database.Exec(schema); err != nil {
+		return fmt.Errorf("failed to create the schema of %s: %w", path, err)
+	}
+	tx, err := database.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := fill(tx); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
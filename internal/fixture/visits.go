@@ -0,0 +1,119 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package fixture
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+
+	"hist_scanner/internal/catalog"
+)
+
+// Visit is a generated page visit
+type Visit struct {
+	URL   string
+	Title string
+	Time  time.Time
+	Typed bool // Typed in the address bar rather than followed as a link
+}
+
+// commonHosts are sites outside the SaaS catalog that make up most
+// history, most visited first
+var commonHosts = []string{
+	"www.google.com", "www.youtube.com", "en.wikipedia.org", "www.bing.com",
+	"stackoverflow.com", "www.reddit.com", "news.ycombinator.com", "www.bbc.com",
+	"www.nytimes.com", "www.amazon.com", "www.linkedin.com", "developer.mozilla.org",
+	"pkg.go.dev", "docs.python.org", "www.weather.com", "maps.google.com",
+	"www.booking.com", "www.imdb.com", "medium.com", "www.theguardian.com",
+}
+
+// noiseURLs are entries the scanner drops (internal pages, private
+// addresses, other schemes), so fixtures exercise its filters
+var noiseURLs = []string{
+	"chrome://settings/", "about:preferences", "file:///home/user/report.pdf",
+	"http://localhost:8080/", "http://192.168.1.1/admin", "http://10.0.4.17/wiki/",
+}
+
+// words fill generated paths, queries and titles
+var words = []string{
+	"report", "budget", "roadmap", "invoice", "onboarding", "design", "release",
+	"meeting", "contract", "pricing", "support", "training", "quarterly", "team",
+	"project", "customer", "analytics", "security", "policy", "planning",
+}
+
+// Visits returns n visits spread over the days up to now, oldest first. Few
+// sites get most visits, working hours are busier, about 40% of the visits
+// go to SaaS services of the built-in catalog and 2% are URLs the scanner
+// drops. The same rng state gives the same visits.
+func Visits(n, days int, rng *rand.Rand, now time.Time) []Visit {
+	// Popularity of sites is long-tailed
+	popular := rand.NewZipf(rng, 1.3, 2, uint64(len(commonHosts)-1))
+	saas := catalogHosts()
+	start := now.AddDate(0, 0, -max(days, 1))
+
+	visits := make([]Visit, 0, n)
+	for range n {
+		t := visitTime(rng, start, now)
+		switch r := rng.IntN(100); {
+		case r < 2:
+			visits = append(visits, Visit{URL: noiseURLs[rng.IntN(len(noiseURLs))], Time: t})
+		case r < 42:
+			visits = append(visits, pageVisit(rng, saas[rng.IntN(len(saas))], t))
+		default:
+			visits = append(visits, pageVisit(rng, commonHosts[popular.Uint64()], t))
+		}
+	}
+	slices.SortFunc(visits, func(a, b Visit) int { return a.Time.Compare(b.Time) })
+	return visits
+}
+
+// catalogHosts returns a host of every domain in the built-in catalog
+func catalogHosts() []string {
+	var hosts []string
+	for _, s := range catalog.Builtin().Services() {
+		for _, d := range s.Domains {
+			if strings.Count(d, ".") == 1 {
+				d = "www." + d
+			}
+			hosts = append(hosts, d)
+		}
+	}
+	return hosts
+}
+
+// visitTime returns a time between start and now, mostly on working hours
+func visitTime(rng *rand.Rand, start, now time.Time) time.Time {
+	span := now.Sub(start)
+	d := start.Add(time.Duration(rng.Int64N(int64(span))))
+	day := time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, d.Location())
+	hour := rng.IntN(24)
+	if rng.IntN(10) < 8 {
+		hour = 8 + rng.IntN(10)
+	}
+	t := day.Add(time.Duration(hour)*time.Hour + time.Duration(rng.Int64N(int64(time.Hour))))
+	if t.After(now) || t.Before(start) {
+		return start.Add(time.Duration(rng.Int64N(int64(span))))
+	}
+	return t
+}
+
+// pageVisit returns a visit of a page of host
+func pageVisit(rng *rand.Rand, host string, t time.Time) Visit {
+	word := words[rng.IntN(len(words))]
+	path, title := "/", host
+	switch rng.IntN(5) {
+	case 1:
+		path, title = "/search?q="+word, word+" - Search"
+	case 2:
+		path, title = "/"+word, strings.ToUpper(word[:1])+word[1:]+" - "+host
+	case 3:
+		other := words[rng.IntN(len(words))]
+		path, title = "/"+word+"/"+other, other+" | "+host
+	case 4:
+		path, title = "/dashboard", "Dashboard - "+host
+	}
+	return Visit{URL: "https://" + host + path, Title: title, Time: t, Typed: rng.IntN(5) == 0}
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvTimeColumns are the header names of the visit time column, in order of
+// preference; the export command writes "time"
+var csvTimeColumns = []string{"time", "visit_time", "visittime", "last_visit_time", "lastvisittime", "timestamp", "date"}
+
+// csvTimeLayouts are the accepted time formats besides Unix times
+var csvTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04"}
+
+// parseCSV reads one visit per row of a CSV file whose header names a url
+// column and a time column
+func parseCSV(data []byte) ([]record, error) {
+	// Spreadsheet applications start UTF-8 CSV files with a byte order mark
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	urlCol, timeCol := -1, -1
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if i, ok := columns["url"]; ok {
+		urlCol = i
+	}
+	for _, name := range csvTimeColumns {
+		if i, ok := columns[name]; ok {
+			timeCol = i
+			break
+		}
+	}
+	if urlCol < 0 || timeCol < 0 {
+		return nil, fmt.Errorf("header needs a url column and a time column (%s)", strings.Join(csvTimeColumns, ", "))
+	}
+
+	var records []record
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rec := record{}
+		if urlCol < len(row) {
+			rec.url = row[urlCol]
+		}
+		if timeCol < len(row) {
+			rec.timestamp = parseCSVTime(strings.TrimSpace(row[timeCol]))
+		}
+		records = append(records, rec)
+	}
+}
+
+// parseCSVTime returns the Unix milliseconds of a time in a supported
+// layout or a Unix time in seconds, milliseconds or microseconds, or 0.
+// Times without a zone are UTC.
+func parseCSVTime(s string) int64 {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		switch {
+		case n < 1e11:
+			return n * 1000
+		case n < 1e14:
+			return n
+		default:
+			return n / 1000
+		}
+	}
+	for _, layout := range csvTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UnixMilli()
+		}
+	}
+	return 0
+}
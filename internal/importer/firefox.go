@@ -0,0 +1,130 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package importer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// mozLz4Magic starts the LZ4-compressed backups Firefox writes (.jsonlz4)
+var mozLz4Magic = []byte("mozLz40\x00")
+
+// maxBackupSize bounds the decompressed size of a .jsonlz4 backup
+const maxBackupSize = 256 << 20
+
+// firefoxNode is a folder or bookmark of a Firefox bookmark backup
+type firefoxNode struct {
+	URI       string        `json:"uri"`
+	DateAdded int64         `json:"dateAdded"` // Unix microseconds
+	Children  []firefoxNode `json:"children"`
+}
+
+// parseFirefox reads the bookmarks of a Firefox bookmark backup (JSON or
+// the .jsonlz4 files of bookmarkbackups); each bookmark is a visit at the
+// time it was added
+func parseFirefox(data []byte) ([]record, error) {
+	if bytes.HasPrefix(data, mozLz4Magic) {
+		var err error
+		if data, err = decodeMozLz4(data[len(mozLz4Magic):]); err != nil {
+			return nil, err
+		}
+	}
+	var root firefoxNode
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	if root.Children == nil {
+		return nil, fmt.Errorf("not a bookmark backup: no children")
+	}
+
+	var records []record
+	var walk func(n firefoxNode)
+	walk = func(n firefoxNode) {
+		// place: URIs are saved searches, not pages
+		if n.URI != "" && !strings.HasPrefix(n.URI, "place:") {
+			records = append(records, record{url: n.URI, timestamp: n.DateAdded / 1000})
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(root)
+	return records, nil
+}
+
+// decodeMozLz4 decompresses the LZ4 block after the mozLz4 magic, which
+// starts with the little-endian decompressed size
+func decodeMozLz4(src []byte) ([]byte, error) {
+	if len(src) < 4 {
+		return nil, fmt.Errorf("truncated jsonlz4 header")
+	}
+	size := int(binary.LittleEndian.Uint32(src))
+	if size > maxBackupSize {
+		return nil, fmt.Errorf("jsonlz4 backup of %d bytes is too large", size)
+	}
+	src = src[4:]
+
+	dst := make([]byte, 0, size)
+	// lz4Length adds the extension bytes of a 15 length nibble
+	lz4Length := func(n int, i *int) (int, error) {
+		if n != 15 {
+			return n, nil
+		}
+		for {
+			if *i >= len(src) {
+				return 0, fmt.Errorf("truncated jsonlz4 data")
+			}
+			b := src[*i]
+			*i++
+			n += int(b)
+			if b != 255 {
+				return n, nil
+			}
+		}
+	}
+
+	for i := 0; i < len(src); {
+		token := src[i]
+		i++
+		literals, err := lz4Length(int(token>>4), &i)
+		if err != nil {
+			return nil, err
+		}
+		if i+literals > len(src) || len(dst)+literals > size {
+			return nil, fmt.Errorf("corrupt jsonlz4 data")
+		}
+		dst = append(dst, src[i:i+literals]...)
+		i += literals
+		// The last sequence has only literals
+		if i == len(src) {
+			break
+		}
+
+		if i+2 > len(src) {
+			return nil, fmt.Errorf("truncated jsonlz4 data")
+		}
+		offset := int(src[i]) | int(src[i+1])<<8
+		i += 2
+		match, err := lz4Length(int(token&15), &i)
+		if err != nil {
+			return nil, err
+		}
+		match += 4
+		if offset == 0 || offset > len(dst) || len(dst)+match > size {
+			return nil, fmt.Errorf("corrupt jsonlz4 data")
+		}
+		// Byte by byte: the match may overlap the bytes it produces
+		for range match {
+			dst = append(dst, dst[len(dst)-offset])
+		}
+	}
+	if len(dst) != size {
+		return nil, fmt.Errorf("corrupt jsonlz4 data: %d of %d bytes", len(dst), size)
+	}
+	return dst, nil
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package importer reads browser history exported outside the scanner
+// (Google Takeout, CSV, Firefox bookmark backups) into visits, so forensic
+// evidence can be sent through the same pipeline as scanned history
+package importer
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"hist_scanner/internal/dto"
+)
+
+// Import formats
+const (
+	FormatTakeout     = "takeout"
+	FormatCSV         = "csv"
+	FormatFirefoxJSON = "firefox-json"
+)
+
+// Formats lists the supported formats
+var Formats = []string{FormatTakeout, FormatCSV, FormatFirefoxJSON}
+
+// Result is the visits read from an export file
+type Result struct {
+	Visits  []dto.VisitedSite // Oldest first
+	Skipped int               // Records without a URL or time, or with an invalid one
+}
+
+// ReadFile reads an export file of a format
+func ReadFile(format, path string) (*Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var records []record
+	switch format {
+	case FormatTakeout:
+		records, err = parseTakeout(data)
+	case FormatCSV:
+		records, err = parseCSV(data)
+	case FormatFirefoxJSON:
+		records, err = parseFirefox(data)
+	default:
+		return nil, fmt.Errorf("unknown format %q (want %s)", format, strings.Join(Formats, ", "))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s export: %w", format, err)
+	}
+	return normalize(records), nil
+}
+
+// record is a visit as read from an export, before validation
+type record struct {
+	url       string
+	timestamp int64 // Unix milliseconds, 0 if unknown
+}
+
+// normalize keeps the records with an absolute URL and a time, oldest first
+func normalize(records []record) *Result {
+	r := &Result{}
+	for _, rec := range records {
+		u, err := url.Parse(strings.TrimSpace(rec.url))
+		if err != nil || u.Scheme == "" || rec.timestamp <= 0 {
+			r.Skipped++
+			continue
+		}
+		r.Visits = append(r.Visits, dto.VisitedSite{URL: u.String(), Timestamp: rec.timestamp})
+	}
+	slices.SortStableFunc(r.Visits, func(a, b dto.VisitedSite) int { return cmp.Compare(a.Timestamp, b.Timestamp) })
+	return r
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// takeoutHistory is Chrome/BrowserHistory.json of a Google Takeout archive
+type takeoutHistory struct {
+	BrowserHistory *[]struct {
+		URL      string `json:"url"`
+		TimeUsec int64  `json:"time_usec"`
+	} `json:"Browser History"`
+}
+
+// takeoutActivity is an entry of My Activity/Chrome/MyActivity.json
+type takeoutActivity struct {
+	TitleURL string `json:"titleUrl"`
+	Time     string `json:"time"` // RFC 3339
+}
+
+// parseTakeout reads the browser history of a Google Takeout archive, from
+// BrowserHistory.json or the Chrome activity of MyActivity.json
+func parseTakeout(data []byte) ([]record, error) {
+	var history takeoutHistory
+	if err := json.Unmarshal(data, &history); err == nil {
+		if history.BrowserHistory == nil {
+			return nil, fmt.Errorf(`no "Browser History" list`)
+		}
+		records := make([]record, 0, len(*history.BrowserHistory))
+		for _, h := range *history.BrowserHistory {
+			records = append(records, record{url: h.URL, timestamp: h.TimeUsec / 1000})
+		}
+		return records, nil
+	}
+
+	var activity []takeoutActivity
+	if err := json.Unmarshal(data, &activity); err != nil {
+		return nil, fmt.Errorf("neither BrowserHistory.json nor MyActivity.json: %w", err)
+	}
+	records := make([]record, 0, len(activity))
+	for _, a := range activity {
+		rec := record{url: unwrapGoogleRedirect(a.TitleURL)}
+		if t, err := time.Parse(time.RFC3339Nano, a.Time); err == nil {
+			rec.timestamp = t.UnixMilli()
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// unwrapGoogleRedirect returns the target of a www.google.com/url?q=
+// redirect, as My Activity records visited pages
+func unwrapGoogleRedirect(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host != "www.google.com" || u.Path != "/url" {
+		return rawURL
+	}
+	if q := u.Query().Get("q"); q != "" {
+		return q
+	}
+	return rawURL
+}
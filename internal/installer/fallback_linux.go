@@ -0,0 +1,227 @@
+//go:build linux
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package installer
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	cronFilePath     = "/etc/cron.d/hist_scanner"
+	openrcScriptPath = "/etc/init.d/hist_scanner"
+)
+
+const cronTemplate = `# Installed by hist_scanner - removed by "hist_scanner uninstall"
+SHELL=/bin/sh
+PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin
+{{- range .Environment}}
+{{.}}
+{{- end}}
+{{- range .Entries}}
+{{.Schedule}} {{$.User}} {{$.BinaryPath}} run --config {{$.ConfigPath}}{{if .Full}} --full{{end}} >/dev/null 2>&1
+{{- end}}
+`
+
+const openrcTemplate = `#!/sbin/openrc-run
+# Installed by hist_scanner - removed by "hist_scanner uninstall"
+
+description="Browser History Scanner"
+command="{{.BinaryPath}}"
+command_args="daemon --config {{.ConfigPath}} --interval {{.Interval}}"
+command_user="{{.User}}"
+supervisor="supervise-daemon"
+{{- range .Environment}}
+export {{.}}
+{{- end}}
+
+depend() {
+	need net
+}
+`
+
+// installCron writes a cron.d entry running the scanner on the interval
+func installCron(r *runner, paths InstallPaths, runAsUser string, opts Options) error {
+	scheds, err := opts.schedules()
+	if err != nil {
+		return err
+	}
+
+	type cronEntry struct {
+		Schedule string
+		Full     bool
+	}
+	entries := make([]cronEntry, len(scheds))
+	for i, sched := range scheds {
+		entries[i] = cronEntry{Schedule: cronSchedule(sched.Interval), Full: sched.Full}
+	}
+
+	data := struct {
+		Entries     []cronEntry
+		User        string
+		BinaryPath  string
+		ConfigPath  string
+		Environment []string
+	}{
+		Entries:     entries,
+		User:        runAsUser,
+		BinaryPath:  paths.BinaryPath,
+		ConfigPath:  paths.ConfigPath,
+		Environment: opts.Environment, // cron takes NAME=value lines literally
+	}
+
+	// cron ignores files that are group/world writable
+	return writeTemplate(r, cronFilePath, cronTemplate, data, 0644)
+}
+
+// installOpenRC writes an OpenRC service running the scanner daemon and starts it
+func installOpenRC(r *runner, paths InstallPaths, runAsUser string, opts Options) error {
+	interval, err := daemonInterval(opts, "OpenRC")
+	if err != nil {
+		return err
+	}
+
+	env := make([]string, len(opts.Environment))
+	for i, kv := range opts.Environment {
+		key, value, _ := strings.Cut(kv, "=")
+		env[i] = key + "=" + shellQuote(value)
+	}
+
+	data := struct {
+		BinaryPath  string
+		ConfigPath  string
+		Interval    string
+		User        string
+		Environment []string
+	}{
+		BinaryPath:  paths.BinaryPath,
+		ConfigPath:  paths.ConfigPath,
+		Interval:    interval.String(),
+		User:        runAsUser,
+		Environment: env,
+	}
+
+	if err := writeTemplate(r, openrcScriptPath, openrcTemplate, data, 0755); err != nil {
+		return err
+	}
+
+	commands := [][]string{
+		{"rc-update", "add", "hist_scanner", "default"},
+		{"rc-service", "hist_scanner", "restart"},
+	}
+
+	for _, args := range commands {
+		if err := r.command(args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeOpenRC stops and removes the OpenRC service if present
+func removeOpenRC(r *removal) {
+	if !fileExists(openrcScriptPath) {
+		return
+	}
+
+	exec.Command("rc-service", "hist_scanner", "stop").Run()
+	exec.Command("rc-update", "del", "hist_scanner", "default").Run()
+	r.file(openrcScriptPath)
+}
+
+// openrcStatus fills the interval from the init script and the result from rc-service
+func openrcStatus(st *Status) {
+	st.Mode = ModeOpenRC
+	if data, err := os.ReadFile(openrcScriptPath); err == nil {
+		if _, args, ok := strings.Cut(string(data), "--interval "); ok {
+			st.Interval, _, _ = strings.Cut(args, "\"")
+		}
+	}
+
+	// OpenRC does not record run times; report the supervised daemon's status
+	if output, err := exec.Command("rc-service", "hist_scanner", "status").CombinedOutput(); err == nil {
+		st.LastResult = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(output)), "*"))
+	}
+}
+
+// cronEntrySchedule returns the schedule of the scanner line in a crontab
+func cronEntrySchedule(lines []string) string {
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) > 5 && !strings.HasPrefix(line, "#") && strings.Contains(line, " run --config ") {
+			return strings.Join(fields[:5], " ")
+		}
+	}
+	return ""
+}
+
+// openrcEntry reads the executable from the OpenRC script's command=
+func openrcEntry() *schedulerEntry {
+	data, err := os.ReadFile(openrcScriptPath)
+	if err != nil {
+		return nil
+	}
+
+	binary := strings.Trim(unitValue(string(data), "command"), `"`)
+	replace := func(binaryPath string) (string, string) {
+		return `command="` + binary + `"`, `command="` + binaryPath + `"`
+	}
+	return fileEntry("OpenRC script "+openrcScriptPath, []string{openrcScriptPath}, binary, replace,
+		[]string{"rc-service", "hist_scanner", "restart"})
+}
+
+// cronFileEntry reads the executable from the cron.d line
+// ("<5 schedule fields> <user> <binary> run --config ...")
+func cronFileEntry() *schedulerEntry {
+	data, err := os.ReadFile(cronFilePath)
+	if err != nil {
+		return nil
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Fields(line); len(fields) > 7 && fields[7] == "run" {
+			binary := fields[6]
+			replace := func(binaryPath string) (string, string) {
+				return " " + binary + " run ", " " + binaryPath + " run "
+			}
+			// cron picks up changed files itself
+			return fileEntry("cron entry "+cronFilePath, []string{cronFilePath}, binary, replace)
+		}
+	}
+	return nil
+}
+
+// writeTemplate renders a text template to path with the given permissions
+func writeTemplate(r *runner, path, text string, data interface{}, perm os.FileMode) error {
+	content, err := renderTemplate(path, text, data)
+	if err != nil {
+		return err
+	}
+
+	return r.writeFile(path, []byte(content), perm)
+}
+
+// cronSchedule converts an interval into a cron schedule expression.
+// Cron cannot express arbitrary intervals, so the interval is rounded to
+// whole minutes (< 1h), hours (< 1d) or days.
+func cronSchedule(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		minutes := int(d.Minutes())
+		if minutes < 1 {
+			minutes = 1
+		}
+		return fmt.Sprintf("*/%d * * * *", minutes)
+	case d < 24*time.Hour:
+		return fmt.Sprintf("0 */%d * * *", int(d.Hours()))
+	default:
+		return fmt.Sprintf("0 0 */%d * *", int(d.Hours()/24))
+	}
+}
@@ -4,27 +4,209 @@
 package installer
 
 import (
+	"bytes"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 
-	"gopkg.in/yaml.v3"
-
 	"hist_scanner/internal/config"
+	"hist_scanner/internal/control"
+	"hist_scanner/internal/db"
+	"hist_scanner/internal/devicekey"
+	"hist_scanner/internal/logging"
+	"hist_scanner/internal/notice"
 	"hist_scanner/internal/platform"
+	"hist_scanner/internal/seal"
+	"hist_scanner/internal/state"
 )
 
 // Installer handles installation and uninstallation of the scanner
 type Installer interface {
-	Install(cfg *config.Config, interval time.Duration, runAsUser string) error
-	Uninstall() error
+	Install(cfg *config.Config, opts Options) ([]Step, error)
+	Uninstall(opts UninstallOptions) ([]string, error)
 	IsInstalled() bool
+	Status() Status
+	Upgrade() error
+
+	// Verify checks the installed binary, config file and scheduler entry;
+	// with fix set it repairs any drift it finds
+	Verify(fix bool) []Check
+}
+
+// Install modes (Options.Mode)
+const (
+	ModeDefault = ""        // Platform default scheduler
+	ModeTask    = "task"    // Windows Task Scheduler
+	ModeService = "service" // Windows service running the scanner daemon
+	ModeSystemd = "systemd" // Linux systemd timer
+	ModeOpenRC  = "openrc"  // Linux OpenRC service running the scanner daemon
+	ModeCron    = "cron"    // Linux /etc/cron.d entry
+	ModeLaunchd = "launchd" // macOS launchd job
+	ModeRCD     = "rcd"     // FreeBSD rc.d service running the scanner daemon
+)
+
+// Options controls how the scanner is registered with the system scheduler
+type Options struct {
+	Interval  time.Duration // Scan interval
+	RunAsUser string        // Account the scan runs as (default: root/SYSTEM)
+
+	// RunAsCurrentUser runs the scan as whichever user is logged on, scanning
+	// only that user's profiles (Windows task mode)
+	RunAsCurrentUser bool
+	Mode             string // Scheduler mechanism, see Mode* constants
+
+	// Task Scheduler conditions (Windows task mode)
+	RequireNetwork bool          // Only start when a network connection is available
+	RunMissed      bool          // Run as soon as possible after a missed start
+	WakeToRun      bool          // Wake the computer to run the scan
+	RandomDelay    time.Duration // Random delay added to each start, spreads fleet load
+
+	// systemd service options (Linux systemd mode)
+	Hardening     bool     // Sandbox the service (ProtectSystem=strict, PrivateTmp, NoNewPrivileges)
+	CPUQuota      string   // CPUQuota= value, e.g. "50%"
+	MemoryMax     string   // MemoryMax= value, e.g. "512M"
+	WritablePaths []string // Directories the scan writes to besides the state directory
+
+	// DryRun only plans the installation: Install returns the steps it would
+	// perform without writing files, running commands or requiring root
+	DryRun bool
+
+	// Environment holds "KEY=VALUE" pairs set for scheduled runs, e.g. proxy
+	// settings that root/SYSTEM does not inherit from the installing user
+	Environment []string
+
+	// Schedules creates one scheduler entry per schedule (cron, systemd,
+	// launchd and Task Scheduler). Empty means a single entry at Interval.
+	Schedules []config.Schedule
+}
+
+// scheduleNameRe restricts schedule names to characters valid in unit,
+// task and launchd label names
+var scheduleNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ValidateSchedules checks intervals and the names of additional schedules
+func ValidateSchedules(scheds []config.Schedule) error {
+	seen := make(map[string]bool)
+	for i, s := range scheds {
+		if s.Interval <= 0 {
+			return fmt.Errorf("schedule %d: interval must be > 0", i+1)
+		}
+		// The first schedule uses the base entry names; the others need a unique name
+		if i == 0 {
+			continue
+		}
+		if !scheduleNameRe.MatchString(s.Name) {
+			return fmt.Errorf("schedule %d: invalid name %q (use lowercase letters, digits, - and _)", i+1, s.Name)
+		}
+		if seen[s.Name] {
+			return fmt.Errorf("duplicate schedule name %q", s.Name)
+		}
+		seen[s.Name] = true
+	}
+	return nil
+}
+
+// schedules returns the schedules to install: Options.Schedules, or a single
+// schedule at Options.Interval
+func (o Options) schedules() ([]config.Schedule, error) {
+	if len(o.Schedules) == 0 {
+		return []config.Schedule{{Interval: o.Interval}}, nil
+	}
+	if err := ValidateSchedules(o.Schedules); err != nil {
+		return nil, err
+	}
+	return o.Schedules, nil
+}
+
+// daemonInterval returns the scan interval for modes that run the scanner
+// daemon (OpenRC, rc.d, Windows service), which has a single incremental scan loop
+func daemonInterval(opts Options, mode string) (time.Duration, error) {
+	switch {
+	case len(opts.Schedules) > 1:
+		return 0, fmt.Errorf("%s mode supports only one schedule", mode)
+	case len(opts.Schedules) == 1 && opts.Schedules[0].Full:
+		return 0, fmt.Errorf("%s mode does not support full-scan schedules", mode)
+	case len(opts.Schedules) == 1:
+		return opts.Schedules[0].Interval, nil
+	}
+	return opts.Interval, nil
+}
+
+// entryName returns the scheduler entry name of the i-th schedule: base for
+// the first, base-<name> for the others
+func entryName(base string, i int, s config.Schedule) string {
+	if i == 0 {
+		return base
+	}
+	return base + "-" + s.Name
+}
+
+// UninstallOptions controls which data uninstall removes. Binary, config and
+// scheduler entries are always removed; state, keys and logs only with Purge.
+type UninstallOptions struct {
+	Purge     bool // Also remove the data the config names (see purgeData)
+	KeepState bool // With Purge, keep the state file (scan watermarks) and its seal.key
+}
+
+// ValidateEnvironment checks that every entry has the form KEY=VALUE
+func ValidateEnvironment(env []string) error {
+	for _, kv := range env {
+		key, _, ok := strings.Cut(kv, "=")
+		if !ok || key == "" || strings.ContainsAny(key, " \t\n\"'") || strings.ContainsAny(kv, "\r\n") {
+			return fmt.Errorf("invalid environment variable %q (expected KEY=VALUE)", kv)
+		}
+	}
+	return nil
 }
 
-// New creates a platform-specific installer
-func New() (Installer, error) {
-	return newPlatformInstaller()
+// xmlEscape escapes a value for use in XML text content
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// shellQuote quotes a value for /bin/sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Status describes an existing installation, as reported by "install status"
+type Status struct {
+	Installed  bool   `json:"installed"`
+	Scope      Scope  `json:"scope"`
+	Mode       string `json:"mode,omitempty"`        // Scheduler mechanism in use
+	Interval   string `json:"interval,omitempty"`    // Interval as written in the scheduler entry
+	LastRun    string `json:"last_run,omitempty"`    // Last start time reported by the scheduler
+	LastResult string `json:"last_result,omitempty"` // Last result reported by the scheduler
+	BinaryPath string `json:"binary_path"`
+	ConfigPath string `json:"config_path"`
+	StatePath  string `json:"state_path,omitempty"`
+
+	Runs []state.RunRecord `json:"runs,omitempty"` // Recent scan results, newest first
+}
+
+// Scope selects between a system-wide and a per-user installation
+type Scope string
+
+const (
+	ScopeSystem Scope = "system" // Runs as root/SYSTEM and scans all users
+	ScopeUser   Scope = "user"   // Runs as the installing user and scans only that user
+)
+
+// New creates a platform-specific installer for the given scope
+func New(scope Scope) (Installer, error) {
+	switch scope {
+	case ScopeSystem, ScopeUser:
+	default:
+		return nil, fmt.Errorf("unknown install scope %q (use %q or %q)", scope, ScopeSystem, ScopeUser)
+	}
+	return newPlatformInstaller(scope)
 }
 
 // InstallPaths contains the installation paths for the current platform
@@ -33,8 +215,12 @@ type InstallPaths struct {
 	ConfigPath string
 }
 
-// GetInstallPaths returns the installation paths for the current platform
-func GetInstallPaths() InstallPaths {
+// GetInstallPaths returns the installation paths for the current platform and scope
+func GetInstallPaths(scope Scope) InstallPaths {
+	if scope == ScopeUser {
+		return getUserInstallPaths()
+	}
+
 	switch platform.CurrentOS() {
 	case platform.Linux:
 		return InstallPaths{
@@ -59,6 +245,40 @@ func GetInstallPaths() InstallPaths {
 			BinaryPath: "/usr/local/bin/hist_scanner",
 			ConfigPath: "/etc/hist_scanner/config.yaml",
 		}
+	case platform.FreeBSD:
+		// Third-party software lives under /usr/local on FreeBSD
+		return InstallPaths{
+			BinaryPath: "/usr/local/bin/hist_scanner",
+			ConfigPath: "/usr/local/etc/hist_scanner/config.yaml",
+		}
+	default:
+		return InstallPaths{}
+	}
+}
+
+// getUserInstallPaths returns the per-user installation paths for the current platform
+func getUserInstallPaths() InstallPaths {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return InstallPaths{}
+	}
+
+	switch platform.CurrentOS() {
+	case platform.Linux:
+		configHome := os.Getenv("XDG_CONFIG_HOME")
+		if configHome == "" {
+			configHome = filepath.Join(home, ".config")
+		}
+		return InstallPaths{
+			BinaryPath: filepath.Join(home, ".local", "bin", "hist_scanner"),
+			ConfigPath: filepath.Join(configHome, "hist_scanner", "config.yaml"),
+		}
+	case platform.Darwin:
+		appSupport := filepath.Join(home, "Library", "Application Support", "hist_scanner")
+		return InstallPaths{
+			BinaryPath: filepath.Join(appSupport, "hist_scanner"),
+			ConfigPath: filepath.Join(appSupport, "config.yaml"),
+		}
 	default:
 		return InstallPaths{}
 	}
@@ -78,6 +298,11 @@ func CopyBinary(dstPath string) error {
 		return fmt.Errorf("failed to resolve executable path: %w", err)
 	}
 
+	// Nothing to do when running the installed binary itself
+	if resolved, err := filepath.EvalSymlinks(dstPath); err == nil && resolved == srcPath {
+		return nil
+	}
+
 	// Create destination directory
 	dstDir := filepath.Dir(dstPath)
 	if err := os.MkdirAll(dstDir, 0755); err != nil {
@@ -90,56 +315,201 @@ func CopyBinary(dstPath string) error {
 		return fmt.Errorf("failed to read source binary: %w", err)
 	}
 
-	// Write to destination
-	if err := os.WriteFile(dstPath, data, 0755); err != nil {
+	// Write next to the destination and rename over it, so a scan that is
+	// running the old binary is not affected
+	tmpPath := dstPath + ".new"
+	if err := os.WriteFile(tmpPath, data, 0755); err != nil {
 		return fmt.Errorf("failed to write binary: %w", err)
 	}
 
+	if err := os.Rename(tmpPath, dstPath); err != nil {
+		// Windows cannot replace a running executable, but can rename it aside
+		oldPath := dstPath + ".old"
+		RemoveFile(oldPath)
+		if renameErr := os.Rename(dstPath, oldPath); renameErr != nil {
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to replace binary: %w", err)
+		}
+		if err := os.Rename(tmpPath, dstPath); err != nil {
+			return fmt.Errorf("failed to replace binary: %w", err)
+		}
+	}
+
+	// Leftover from a previous replacement; fails harmlessly while still running
+	RemoveFile(dstPath + ".old")
+
 	return nil
 }
 
+// MigrateConfig rewrites an installed config file in the current format,
+// keeping all of its values
+func MigrateConfig(configPath string) (*config.Config, error) {
+	cfg, err := config.LoadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.SaveToFile(configPath); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
 // WriteConfig writes the configuration file
 func WriteConfig(cfg *config.Config, configPath string) error {
-	// Create config directory
-	configDir := filepath.Dir(configPath)
-	if err := os.MkdirAll(configDir, 0755); err != nil {
-		return fmt.Errorf("failed to create config directory: %w", err)
-	}
-
-	// Convert to YAML-friendly structure
-	configData := struct {
-		ServerURL   string `yaml:"server_url"`
-		APIKey      string `yaml:"api_key"`
-		InitialDays int    `yaml:"initial_days"`
-		Timeout     string `yaml:"timeout"`
-		ChunkSizeKB int    `yaml:"chunk_size_kb"`
-		Compress    bool   `yaml:"compress"`
-		StateFile   string `yaml:"state_file,omitempty"`
-		LogFile     string `yaml:"log_file,omitempty"`
-		Source      string `yaml:"source,omitempty"`
-	}{
-		ServerURL:   cfg.ServerURL,
-		APIKey:      cfg.APIKey,
-		InitialDays: cfg.InitialDays,
-		Timeout:     cfg.Timeout.String(),
-		ChunkSizeKB: cfg.ChunkSizeKB,
-		Compress:    cfg.Compress,
-		StateFile:   cfg.StateFile,
-		LogFile:     cfg.LogFile,
-		Source:      cfg.Source,
-	}
-
-	data, err := yaml.Marshal(configData)
+	return cfg.SaveToFile(configPath)
+}
+
+// removal tracks what an uninstall removed, for reporting
+type removal struct {
+	removed []string
+	errs    []error // Data that should have been removed but was not
+}
+
+// file removes a file and records it if it existed
+func (r *removal) file(path string) {
+	if path != "" && os.Remove(path) == nil {
+		r.removed = append(r.removed, path)
+	}
+}
+
+// dir removes a directory if it's empty and records it
+func (r *removal) dir(path string) {
+	if path != "" && os.Remove(path) == nil {
+		r.removed = append(r.removed, path)
+	}
+}
+
+// add records a removed item that is not a file, such as a scheduler entry
+func (r *removal) add(item string) {
+	r.removed = append(r.removed, item)
+}
+
+// tree removes a file or directory with everything in it and records it if
+// it existed; a failure is kept for err
+func (r *removal) tree(path string) {
+	if _, err := os.Lstat(path); path == "" || err != nil {
+		return
+	}
+	if err := os.RemoveAll(path); err != nil {
+		r.errs = append(r.errs, fmt.Errorf("failed to remove %s: %w", path, err))
+		return
+	}
+	r.removed = append(r.removed, path)
+}
+
+// purgedDir removes a directory that purging should have emptied and
+// records it; if it is not empty or cannot be removed, the failure is kept
+// for err
+func (r *removal) purgedDir(path string) {
+	if path == "" {
+		return
+	}
+	if err := os.Remove(path); err != nil {
+		if !os.IsNotExist(err) {
+			r.errs = append(r.errs, fmt.Errorf("failed to remove %s: %w", path, err))
+		}
+		return
+	}
+	r.removed = append(r.removed, path)
+}
+
+// err returns the failures to remove purged data, or nil
+func (r *removal) err() error {
+	return errors.Join(r.errs...)
+}
+
+// installedConfig reads the installed config, falling back to defaults
+func installedConfig(configPath string) *config.Config {
+	cfg, err := config.LoadFile(configPath)
 	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
+		return config.DefaultConfig()
 	}
+	return cfg
+}
 
-	// Write with restricted permissions (contains API key)
-	if err := os.WriteFile(configPath, data, 0600); err != nil {
-		return fmt.Errorf("failed to write config: %w", err)
+// purgeData removes the data named by the installed config: the state file
+// and its seal.key (unless KeepState), the device key, the control socket,
+// the log and audit log with their rotated copies, database copies and the
+// contents of retention_paths. The state directory is removed last; if
+// anything is left in it, Uninstall fails. Must run before the config file
+// itself is removed.
+func (r *removal) purgeData(configPath string, opts UninstallOptions) {
+	if !opts.Purge {
+		return
 	}
 
-	return nil
+	cfg := installedConfig(configPath)
+	statePath := state.Locate(cfg.StateFile)
+	stateDir := state.Dir(cfg.StateFile)
+	if statePath != "" {
+		stateDir = filepath.Dir(statePath)
+	}
+
+	if !opts.KeepState {
+		r.file(statePath)
+		r.file(filepath.Join(stateDir, seal.KeyFileName))
+	}
+	deviceKey := cfg.DeviceKey
+	if deviceKey == "" {
+		deviceKey = filepath.Join(stateDir, devicekey.FileName)
+	}
+	r.file(deviceKey)
+	switch cfg.ControlSocket {
+	case "off":
+	case "":
+		r.file(control.DefaultPath(stateDir))
+	default:
+		r.file(cfg.ControlSocket)
+	}
+
+	for _, path := range []string{cfg.LogPath(), cfg.AuditLog} {
+		if path == "" {
+			continue
+		}
+		r.file(path)
+		for _, rotated := range logging.RotatedFiles(path) {
+			r.file(rotated)
+		}
+	}
+
+	// Only the scanner's run directories: temp_dir may be shared
+	copyDirs := []string{cfg.TempDir}
+	if cfg.TempDir == "" {
+		copyDirs = []string{db.DefaultTempDir(), filepath.Join(stateDir, "tmp")}
+	}
+	for _, dir := range copyDirs {
+		for _, run := range db.RunDirs(dir) {
+			r.tree(run)
+		}
+		r.dir(dir)
+	}
+
+	// retention_paths hold scanner data only
+	for _, dir := range cfg.RetentionPaths {
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			r.tree(filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	if !opts.KeepState {
+		r.purgedDir(stateDir)
+	}
+}
+
+// removeNotice removes the user notice files and the disclosure page directory
+func (r *removal) removeNotice() {
+	for _, path := range notice.Paths() {
+		r.file(path)
+	}
+	r.dir(notice.PageDir())
+}
+
+// fileExists checks if a path exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
 }
 
 // RemoveFile removes a file if it exists
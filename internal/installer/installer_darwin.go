@@ -7,18 +7,20 @@ package installer
 
 import (
 	"fmt"
+	"html"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"text/template"
-	"time"
+	"regexp"
+	"slices"
+	"strings"
 
 	"hist_scanner/internal/config"
 )
 
 // newPlatformInstaller creates the macOS installer
-func newPlatformInstaller() (Installer, error) {
-	return &DarwinInstaller{}, nil
+func newPlatformInstaller(scope Scope) (Installer, error) {
+	return &DarwinInstaller{scope: scope}, nil
 }
 
 const (
@@ -26,8 +28,31 @@ const (
 	launchdLabel     = "com.binadox.hist_scanner"
 )
 
-// DarwinInstaller handles installation on macOS using launchd
-type DarwinInstaller struct{}
+// DarwinInstaller handles installation on macOS using launchd: a LaunchDaemon
+// for system scope, or a LaunchAgent in ~/Library/LaunchAgents for user scope
+type DarwinInstaller struct {
+	scope Scope
+}
+
+// plistPath returns the launchd plist location for the installer scope
+func (i *DarwinInstaller) plistPath() string {
+	return i.plistPathFor(launchdLabel)
+}
+
+// plistPathFor returns the plist location of a job label for the installer scope
+func (i *DarwinInstaller) plistPathFor(label string) string {
+	if i.scope == ScopeUser {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, "Library", "LaunchAgents", label+".plist")
+	}
+	return filepath.Join(filepath.Dir(launchdPlistPath), label+".plist")
+}
+
+// extraPlists returns the plists of additional schedules
+func (i *DarwinInstaller) extraPlists() []string {
+	matches, _ := filepath.Glob(i.plistPathFor(launchdLabel + "-*"))
+	return matches
+}
 
 const plistTemplate = `<?xml version="1.0" encoding="UTF-8"?>
 <!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
@@ -41,108 +66,275 @@ const plistTemplate = `<?xml version="1.0" encoding="UTF-8"?>
         <string>run</string>
         <string>--config</string>
         <string>{{.ConfigPath}}</string>
+{{- if .Full}}
+        <string>--full</string>
+{{- end}}
     </array>
     <key>StartInterval</key>
     <integer>{{.IntervalSeconds}}</integer>
     <key>RunAtLoad</key>
     <true/>
+{{- if .Environment}}
+    <key>EnvironmentVariables</key>
+    <dict>
+{{- range .Environment}}
+        <key>{{xml .Key}}</key>
+        <string>{{xml .Value}}</string>
+{{- end}}
+    </dict>
+{{- end}}
+{{- if .User}}
     <key>UserName</key>
     <string>{{.User}}</string>
+{{- end}}
 </dict>
 </plist>
 `
 
 // Install installs the scanner as a launchd service
-func (i *DarwinInstaller) Install(cfg *config.Config, interval time.Duration, runAsUser string) error {
-	// Check for root
-	if os.Getuid() != 0 {
-		return fmt.Errorf("installation requires root privileges (run with sudo)")
+func (i *DarwinInstaller) Install(cfg *config.Config, opts Options) ([]Step, error) {
+	if opts.RunAsCurrentUser {
+		return nil, fmt.Errorf("--run-as-current-user is only supported on Windows (use --scope user)")
+	}
+
+	if opts.Mode != ModeDefault && opts.Mode != ModeLaunchd {
+		return nil, fmt.Errorf("install mode %q is not supported on macOS", opts.Mode)
+	}
+
+	paths := GetInstallPaths(i.scope)
+	plistPath := i.plistPath()
+
+	// LaunchAgents always run as the logged-in user and scan only that user
+	runAsUser := opts.RunAsUser
+	if i.scope == ScopeUser {
+		if runAsUser != "" {
+			return nil, fmt.Errorf("--user cannot be combined with user scope")
+		}
+		userCfg := *cfg
+		userCfg.CurrentUserOnly = true
+		cfg = &userCfg
+	} else {
+		// Check for root
+		if !opts.DryRun && os.Getuid() != 0 {
+			return nil, fmt.Errorf("installation requires root privileges (run with sudo)")
+		}
+
+		// Default user to root
+		if runAsUser == "" {
+			runAsUser = "root"
+		}
 	}
 
-	paths := GetInstallPaths()
+	r := &runner{dryRun: opts.DryRun}
 
-	// Default user to root
-	if runAsUser == "" {
-		runAsUser = "root"
+	if err := r.mkdirAll(filepath.Dir(paths.BinaryPath), 0755); err != nil {
+		return r.steps, err
 	}
 
 	// Copy binary
-	if err := CopyBinary(paths.BinaryPath); err != nil {
-		return fmt.Errorf("failed to copy binary: %w", err)
+	if err := r.copyBinary(paths.BinaryPath); err != nil {
+		return r.steps, err
 	}
 
 	// Write config
-	if err := WriteConfig(cfg, paths.ConfigPath); err != nil {
-		return fmt.Errorf("failed to write config: %w", err)
-	}
-
-	// Generate and write plist file
-	plistData := struct {
-		Label           string
-		BinaryPath      string
-		ConfigPath      string
-		IntervalSeconds int
-		User            string
-	}{
-		Label:           launchdLabel,
-		BinaryPath:      paths.BinaryPath,
-		ConfigPath:      paths.ConfigPath,
-		IntervalSeconds: int(interval.Seconds()),
-		User:            runAsUser,
-	}
-
-	plistTmpl, err := template.New("plist").Parse(plistTemplate)
-	if err != nil {
-		return fmt.Errorf("failed to parse plist template: %w", err)
+	if err := r.writeConfig(cfg, paths.ConfigPath); err != nil {
+		return r.steps, err
 	}
 
-	plistFile, err := os.Create(launchdPlistPath)
+	scheds, err := opts.schedules()
 	if err != nil {
-		return fmt.Errorf("failed to create plist file: %w", err)
+		return r.steps, err
 	}
-	defer plistFile.Close()
 
-	if err := plistTmpl.Execute(plistFile, plistData); err != nil {
-		return fmt.Errorf("failed to write plist file: %w", err)
+	if err := r.mkdirAll(filepath.Dir(plistPath), 0755); err != nil {
+		return r.steps, err
 	}
 
-	// Set correct permissions
-	if err := os.Chmod(launchdPlistPath, 0644); err != nil {
-		return fmt.Errorf("failed to set plist permissions: %w", err)
+	// One job per schedule
+	var plists []string
+	for n, sched := range scheds {
+		label := entryName(launchdLabel, n, sched)
+		plistData := struct {
+			Label           string
+			BinaryPath      string
+			ConfigPath      string
+			Full            bool
+			IntervalSeconds int
+			User            string
+			Environment     []envVar
+		}{
+			Label:           label,
+			BinaryPath:      paths.BinaryPath,
+			ConfigPath:      paths.ConfigPath,
+			Full:            sched.Full,
+			IntervalSeconds: int(sched.Interval.Seconds()),
+			User:            runAsUser,
+			Environment:     splitEnvironment(opts.Environment),
+		}
+
+		plist, err := renderTemplate("plist", plistTemplate, plistData)
+		if err != nil {
+			return r.steps, err
+		}
+
+		path := i.plistPathFor(label)
+		if err := r.writeFile(path, []byte(plist), 0644); err != nil {
+			return r.steps, err
+		}
+		plists = append(plists, path)
 	}
 
-	// Load the service
-	cmd := exec.Command("launchctl", "load", launchdPlistPath)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to load launchd service: %w\n%s", err, output)
+	// Jobs of schedules that are no longer configured
+	for _, path := range i.extraPlists() {
+		if slices.Contains(plists, path) {
+			continue
+		}
+		r.tryCommand("launchctl", "unload", path)
+		if err := r.removeFile(path); err != nil {
+			return r.steps, err
+		}
 	}
 
-	return nil
+	// Load the jobs
+	for _, path := range plists {
+		if err := r.command("launchctl", "load", path); err != nil {
+			return r.steps, err
+		}
+	}
+	return r.steps, nil
+}
+
+// envVar is a single environment variable for the plist
+type envVar struct {
+	Key   string
+	Value string
+}
+
+// splitEnvironment splits "KEY=VALUE" pairs for the EnvironmentVariables dict
+func splitEnvironment(env []string) []envVar {
+	vars := make([]envVar, 0, len(env))
+	for _, kv := range env {
+		key, value, _ := strings.Cut(kv, "=")
+		vars = append(vars, envVar{Key: key, Value: value})
+	}
+	return vars
 }
 
 // Uninstall removes the scanner from launchd
-func (i *DarwinInstaller) Uninstall() error {
+func (i *DarwinInstaller) Uninstall(opts UninstallOptions) ([]string, error) {
 	// Check for root
-	if os.Getuid() != 0 {
-		return fmt.Errorf("uninstallation requires root privileges (run with sudo)")
+	if i.scope == ScopeSystem && os.Getuid() != 0 {
+		return nil, fmt.Errorf("uninstallation requires root privileges (run with sudo)")
 	}
 
-	paths := GetInstallPaths()
+	paths := GetInstallPaths(i.scope)
+	plistPath := i.plistPath()
+	r := &removal{}
 
-	// Unload the service
-	exec.Command("launchctl", "unload", launchdPlistPath).Run()
+	// Unload the jobs of all schedules
+	for _, path := range append([]string{plistPath}, i.extraPlists()...) {
+		exec.Command("launchctl", "unload", path).Run()
+		r.file(path)
+	}
 
 	// Remove files
-	RemoveFile(launchdPlistPath)
-	RemoveFile(paths.BinaryPath)
-	RemoveFile(paths.ConfigPath)
-	RemoveDir(filepath.Dir(paths.ConfigPath))
+	r.purgeData(paths.ConfigPath, opts)
+	r.removeMetadata(i.scope)
+	r.removeNotice()
+	r.file(paths.BinaryPath)
+	r.file(paths.ConfigPath)
+	r.dir(filepath.Dir(paths.ConfigPath))
+
+	return r.removed, r.err()
+}
 
+// Upgrade replaces the installed binary; launchd picks it up on the next start
+func (i *DarwinInstaller) Upgrade() error {
+	if i.scope == ScopeSystem && os.Getuid() != 0 {
+		return fmt.Errorf("upgrade requires root privileges (run with sudo)")
+	}
+
+	if err := CopyBinary(GetInstallPaths(i.scope).BinaryPath); err != nil {
+		return fmt.Errorf("failed to copy binary: %w", err)
+	}
 	return nil
 }
 
 // IsInstalled checks if the scanner is installed
 func (i *DarwinInstaller) IsInstalled() bool {
-	_, err := os.Stat(launchdPlistPath)
+	_, err := os.Stat(i.plistPath())
 	return err == nil
 }
+
+var (
+	plistIntervalRe  = regexp.MustCompile(`<key>StartInterval</key>\s*<integer>(\d+)</integer>`)
+	lastExitStatusRe = regexp.MustCompile(`"LastExitStatus"\s*=\s*(-?\d+);`)
+)
+
+// plistProgramRe extracts the executable (first ProgramArguments string) from the plist
+var plistProgramRe = regexp.MustCompile(`<key>ProgramArguments</key>\s*<array>\s*<string>([^<]*)</string>`)
+
+// Verify checks the installation for drift and optionally repairs it
+func (i *DarwinInstaller) Verify(fix bool) []Check {
+	return verifyInstall(i.scope, fix, i.schedulerEntry())
+}
+
+// schedulerEntry reads the executable from the launchd plist
+func (i *DarwinInstaller) schedulerEntry() *schedulerEntry {
+	plistPath := i.plistPath()
+	data, err := os.ReadFile(plistPath)
+	if err != nil {
+		return nil
+	}
+
+	m := plistProgramRe.FindSubmatch(data)
+	if m == nil {
+		return nil
+	}
+
+	binary := string(m[1])
+	replace := func(binaryPath string) (string, string) {
+		return "<string>" + binary + "</string>", "<string>" + xmlEscape(binaryPath) + "</string>"
+	}
+	// Jobs of additional schedules run the same binary
+	files := append([]string{plistPath}, i.extraPlists()...)
+	var reload [][]string
+	for _, path := range files {
+		reload = append(reload, []string{"launchctl", "unload", path}, []string{"launchctl", "load", path})
+	}
+	return fileEntry("launchd job "+plistPath, files, html.UnescapeString(binary), replace, reload...)
+}
+
+// Status reports the launchd job and its last exit status
+func (i *DarwinInstaller) Status() Status {
+	paths := GetInstallPaths(i.scope)
+	st := Status{
+		Installed:  i.IsInstalled(),
+		Scope:      i.scope,
+		BinaryPath: paths.BinaryPath,
+		ConfigPath: paths.ConfigPath,
+	}
+	if !st.Installed {
+		return st
+	}
+
+	st.Mode = ModeLaunchd
+	if data, err := os.ReadFile(i.plistPath()); err == nil {
+		if m := plistIntervalRe.FindSubmatch(data); m != nil {
+			st.Interval = string(m[1]) + "s"
+		}
+	}
+
+	// launchd does not record start times, only the last exit status
+	output, err := exec.Command("launchctl", "list", launchdLabel).Output()
+	if err != nil {
+		return st
+	}
+	if m := lastExitStatusRe.FindSubmatch(output); m != nil {
+		st.LastResult = "exit status " + string(m[1])
+	} else if strings.Contains(string(output), `"PID"`) {
+		st.LastResult = "running"
+	}
+
+	return st
+}
@@ -0,0 +1,249 @@
+//go:build freebsd
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package installer
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"hist_scanner/internal/config"
+)
+
+// newPlatformInstaller creates the FreeBSD installer
+func newPlatformInstaller(scope Scope) (Installer, error) {
+	if scope != ScopeSystem {
+		return nil, fmt.Errorf("install scope %q is not supported on this platform", scope)
+	}
+	return &FreeBSDInstaller{}, nil
+}
+
+const rcScriptPath = "/usr/local/etc/rc.d/hist_scanner"
+
+// rcTemplate runs the scanner daemon under daemon(8), which restarts it if it exits
+const rcTemplate = `#!/bin/sh
+# Installed by hist_scanner - removed by "hist_scanner uninstall"
+
+# PROVIDE: hist_scanner
+# REQUIRE: LOGIN NETWORKING
+# KEYWORD: shutdown
+
+. /etc/rc.subr
+
+name="hist_scanner"
+rcvar="hist_scanner_enable"
+
+load_rc_config $name
+: ${hist_scanner_enable:="NO"}
+
+hist_scanner_bin="{{.BinaryPath}}"
+pidfile="/var/run/${name}.pid"
+command="/usr/sbin/daemon"
+command_args="-f -r -P ${pidfile} -u {{.User}} ${hist_scanner_bin} daemon --config {{.ConfigPath}} --interval {{.Interval}}"
+{{- range .Environment}}
+export {{.}}
+{{- end}}
+
+run_rc_command "$1"
+`
+
+// FreeBSDInstaller handles installation on FreeBSD as an rc.d service
+type FreeBSDInstaller struct{}
+
+// Install installs the scanner as an rc.d service running the scanner daemon
+func (i *FreeBSDInstaller) Install(cfg *config.Config, opts Options) ([]Step, error) {
+	if opts.RunAsCurrentUser {
+		return nil, fmt.Errorf("--run-as-current-user is only supported on Windows")
+	}
+
+	if opts.Mode != ModeDefault && opts.Mode != ModeRCD {
+		return nil, fmt.Errorf("install mode %q is not supported on FreeBSD (use %q)", opts.Mode, ModeRCD)
+	}
+
+	interval, err := daemonInterval(opts, "rc.d")
+	if err != nil {
+		return nil, err
+	}
+
+	// Check for root
+	if !opts.DryRun && os.Getuid() != 0 {
+		return nil, fmt.Errorf("installation requires root privileges")
+	}
+
+	paths := GetInstallPaths(ScopeSystem)
+	r := &runner{dryRun: opts.DryRun}
+
+	// Default user to root
+	runAsUser := opts.RunAsUser
+	if runAsUser == "" {
+		runAsUser = "root"
+	}
+
+	if err := r.mkdirAll(filepath.Dir(paths.BinaryPath), 0755); err != nil {
+		return r.steps, err
+	}
+
+	// Copy binary
+	if err := r.copyBinary(paths.BinaryPath); err != nil {
+		return r.steps, err
+	}
+
+	// Write config
+	if err := r.writeConfig(cfg, paths.ConfigPath); err != nil {
+		return r.steps, err
+	}
+
+	env := make([]string, len(opts.Environment))
+	for n, kv := range opts.Environment {
+		key, value, _ := strings.Cut(kv, "=")
+		env[n] = key + "=" + shellQuote(value)
+	}
+
+	data := struct {
+		BinaryPath  string
+		ConfigPath  string
+		Interval    string
+		User        string
+		Environment []string
+	}{
+		BinaryPath:  paths.BinaryPath,
+		ConfigPath:  paths.ConfigPath,
+		Interval:    interval.String(),
+		User:        runAsUser,
+		Environment: env,
+	}
+
+	script, err := renderTemplate("rc.d", rcTemplate, data)
+	if err != nil {
+		return r.steps, err
+	}
+
+	if err := r.mkdirAll(filepath.Dir(rcScriptPath), 0755); err != nil {
+		return r.steps, err
+	}
+
+	if err := r.writeFile(rcScriptPath, []byte(script), 0755); err != nil {
+		return r.steps, err
+	}
+
+	commands := [][]string{
+		{"sysrc", "hist_scanner_enable=YES"},
+		{"service", "hist_scanner", "restart"},
+	}
+
+	for _, args := range commands {
+		if err := r.command(args...); err != nil {
+			return r.steps, err
+		}
+	}
+
+	return r.steps, nil
+}
+
+// Uninstall stops and removes the rc.d service and installed files
+func (i *FreeBSDInstaller) Uninstall(opts UninstallOptions) ([]string, error) {
+	// Check for root
+	if os.Getuid() != 0 {
+		return nil, fmt.Errorf("uninstallation requires root privileges")
+	}
+
+	paths := GetInstallPaths(ScopeSystem)
+	r := &removal{}
+
+	if fileExists(rcScriptPath) {
+		exec.Command("service", "hist_scanner", "stop").Run()
+		exec.Command("sysrc", "-x", "hist_scanner_enable").Run()
+		r.file(rcScriptPath)
+	}
+
+	// Remove files
+	r.purgeData(paths.ConfigPath, opts)
+	r.removeMetadata(ScopeSystem)
+	r.removeNotice()
+	r.file(paths.BinaryPath)
+	r.file(paths.ConfigPath)
+	r.dir(filepath.Dir(paths.ConfigPath))
+
+	return r.removed, r.err()
+}
+
+// Upgrade replaces the installed binary and restarts the daemon
+func (i *FreeBSDInstaller) Upgrade() error {
+	if os.Getuid() != 0 {
+		return fmt.Errorf("upgrade requires root privileges")
+	}
+
+	if err := CopyBinary(GetInstallPaths(ScopeSystem).BinaryPath); err != nil {
+		return fmt.Errorf("failed to copy binary: %w", err)
+	}
+
+	if fileExists(rcScriptPath) {
+		cmd := exec.Command("service", "hist_scanner", "restart")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to restart rc.d service: %w\n%s", err, output)
+		}
+	}
+
+	return nil
+}
+
+// IsInstalled checks if the rc.d script is installed
+func (i *FreeBSDInstaller) IsInstalled() bool {
+	return fileExists(rcScriptPath)
+}
+
+// Status reports the rc.d service and whether the daemon is running
+func (i *FreeBSDInstaller) Status() Status {
+	paths := GetInstallPaths(ScopeSystem)
+	st := Status{
+		Installed:  i.IsInstalled(),
+		Scope:      ScopeSystem,
+		BinaryPath: paths.BinaryPath,
+		ConfigPath: paths.ConfigPath,
+	}
+	if !st.Installed {
+		return st
+	}
+
+	st.Mode = ModeRCD
+	if data, err := os.ReadFile(rcScriptPath); err == nil {
+		if _, args, ok := strings.Cut(string(data), "--interval "); ok {
+			st.Interval, _, _ = strings.Cut(args, "\"")
+		}
+	}
+
+	// rc.d does not record run times; report the supervised daemon's status
+	output, err := exec.Command("service", "hist_scanner", "status").CombinedOutput()
+	if err == nil {
+		st.LastResult = "running"
+	} else if len(output) > 0 {
+		st.LastResult = strings.TrimSpace(string(output))
+	}
+
+	return st
+}
+
+// Verify checks the installation for drift and optionally repairs it
+func (i *FreeBSDInstaller) Verify(fix bool) []Check {
+	return verifyInstall(ScopeSystem, fix, rcEntry())
+}
+
+// rcEntry reads the executable from the rc.d script's hist_scanner_bin=
+func rcEntry() *schedulerEntry {
+	data, err := os.ReadFile(rcScriptPath)
+	if err != nil {
+		return nil
+	}
+
+	binary := strings.Trim(unitValue(string(data), "hist_scanner_bin"), `"`)
+	replace := func(binaryPath string) (string, string) {
+		return `hist_scanner_bin="` + binary + `"`, `hist_scanner_bin="` + binaryPath + `"`
+	}
+	return fileEntry("rc.d script "+rcScriptPath, []string{rcScriptPath}, binary, replace,
+		[]string{"service", "hist_scanner", "restart"})
+}
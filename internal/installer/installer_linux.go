@@ -10,185 +10,403 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"text/template"
-	"time"
+	"slices"
+	"strings"
 
 	"hist_scanner/internal/config"
+	"hist_scanner/internal/notice"
+	"hist_scanner/pkg/sink"
 )
 
 // newPlatformInstaller creates the Linux installer
-func newPlatformInstaller() (Installer, error) {
-	return &LinuxInstaller{}, nil
+func newPlatformInstaller(scope Scope) (Installer, error) {
+	return &LinuxInstaller{scope: scope}, nil
 }
 
-const (
-	systemdServicePath = "/etc/systemd/system/hist_scanner.service"
-	systemdTimerPath   = "/etc/systemd/system/hist_scanner.timer"
-)
-
-// LinuxInstaller handles installation on Linux using systemd
-type LinuxInstaller struct{}
-
-const serviceTemplate = `[Unit]
-Description=Browser History Scanner
-After=network.target
+// LinuxInstaller handles installation on Linux using systemd, OpenRC or cron:
+// system-wide for system scope, or in the user's home for user scope
+type LinuxInstaller struct {
+	scope Scope
+}
 
-[Service]
-Type=oneshot
-ExecStart={{.BinaryPath}} run --config {{.ConfigPath}}
-User={{.User}}
-`
+// Install installs the scanner with the detected (or requested) init system:
+// a systemd timer, an OpenRC service running the daemon, or a cron.d entry
+func (i *LinuxInstaller) Install(cfg *config.Config, opts Options) ([]Step, error) {
+	if opts.RunAsCurrentUser {
+		return nil, fmt.Errorf("--run-as-current-user is only supported on Windows (use --scope user)")
+	}
 
-const timerTemplate = `[Unit]
-Description=Run Browser History Scanner periodically
+	r := &runner{dryRun: opts.DryRun}
+	if i.scope == ScopeUser {
+		err := installUser(r, cfg, opts)
+		return r.steps, err
+	}
 
-[Timer]
-OnBootSec=5min
-OnUnitActiveSec={{.Interval}}
-Persistent=true
+	mode := opts.Mode
+	if mode == ModeDefault {
+		mode = detectInitSystem()
+		if mode == ModeDefault {
+			return nil, fmt.Errorf("no supported scheduler found (systemd, OpenRC or /etc/cron.d)")
+		}
+	}
 
-[Install]
-WantedBy=timers.target
-`
+	switch mode {
+	case ModeSystemd, ModeOpenRC, ModeCron:
+	default:
+		return nil, fmt.Errorf("install mode %q is not supported on Linux (use %q, %q or %q)", mode, ModeSystemd, ModeOpenRC, ModeCron)
+	}
 
-// Install installs the scanner as a systemd service
-func (i *LinuxInstaller) Install(cfg *config.Config, interval time.Duration, runAsUser string) error {
 	// Check for root
-	if os.Getuid() != 0 {
-		return fmt.Errorf("installation requires root privileges")
+	if !opts.DryRun && os.Getuid() != 0 {
+		return nil, fmt.Errorf("installation requires root privileges")
 	}
 
-	paths := GetInstallPaths()
+	paths := GetInstallPaths(ScopeSystem)
 
 	// Default user to root
+	runAsUser := opts.RunAsUser
 	if runAsUser == "" {
 		runAsUser = "root"
 	}
 
+	if err := r.mkdirAll(filepath.Dir(paths.BinaryPath), 0755); err != nil {
+		return r.steps, err
+	}
+
 	// Copy binary
-	if err := CopyBinary(paths.BinaryPath); err != nil {
-		return fmt.Errorf("failed to copy binary: %w", err)
+	if err := r.copyBinary(paths.BinaryPath); err != nil {
+		return r.steps, err
 	}
 
 	// Write config
-	if err := WriteConfig(cfg, paths.ConfigPath); err != nil {
-		return fmt.Errorf("failed to write config: %w", err)
+	if err := r.writeConfig(cfg, paths.ConfigPath); err != nil {
+		return r.steps, err
 	}
 
-	// Generate and write service file
-	serviceData := struct {
-		BinaryPath string
-		ConfigPath string
-		User       string
-	}{
-		BinaryPath: paths.BinaryPath,
-		ConfigPath: paths.ConfigPath,
-		User:       runAsUser,
+	opts.WritablePaths = writablePaths(cfg)
+
+	// Only one scheduling mechanism may be active at a time
+	if err := removeOtherModes(r, mode); err != nil {
+		return r.steps, err
 	}
 
-	serviceTmpl, err := template.New("service").Parse(serviceTemplate)
-	if err != nil {
-		return fmt.Errorf("failed to parse service template: %w", err)
+	var err error
+	switch mode {
+	case ModeOpenRC:
+		err = installOpenRC(r, paths, runAsUser, opts)
+	case ModeCron:
+		err = installCron(r, paths, runAsUser, opts)
+	default:
+		err = installSystemdUnits(r, systemdUnitDir, []string{"systemctl"}, ScopeSystem, paths, opts)
 	}
+	return r.steps, err
+}
 
-	serviceFile, err := os.Create(systemdServicePath)
+// installSystemdUnits writes a service and timer unit per schedule into dir,
+// removes the units of schedules that are no longer configured and enables
+// the timers. systemctl is the command prefix ("systemctl" or "systemctl --user").
+func installSystemdUnits(r *runner, dir string, systemctl []string, scope Scope, paths InstallPaths, opts Options) error {
+	scheds, err := opts.schedules()
 	if err != nil {
-		return fmt.Errorf("failed to create service file: %w", err)
+		return err
 	}
-	defer serviceFile.Close()
 
-	if err := serviceTmpl.Execute(serviceFile, serviceData); err != nil {
-		return fmt.Errorf("failed to write service file: %w", err)
+	if err := r.mkdirAll(dir, 0755); err != nil {
+		return err
 	}
 
-	// Generate and write timer file
-	timerData := struct {
-		Interval string
-	}{
-		Interval: formatDuration(interval),
+	var timers []string
+	for i, sched := range scheds {
+		service, timer, err := SystemdUnits(scope, paths, opts, sched)
+		if err != nil {
+			return err
+		}
+
+		name := entryName("hist_scanner", i, sched)
+		if err := r.writeFile(filepath.Join(dir, name+".service"), []byte(service), 0644); err != nil {
+			return err
+		}
+		if err := r.writeFile(filepath.Join(dir, name+".timer"), []byte(timer), 0644); err != nil {
+			return err
+		}
+		timers = append(timers, name+".timer")
 	}
 
-	timerTmpl, err := template.New("timer").Parse(timerTemplate)
-	if err != nil {
-		return fmt.Errorf("failed to parse timer template: %w", err)
+	for _, name := range extraUnits(dir) {
+		if slices.Contains(timers, name+".timer") {
+			continue
+		}
+		r.tryCommand(append(systemctl, "disable", "--now", name+".timer")...)
+		if err := r.removeFile(filepath.Join(dir, name+".timer")); err != nil {
+			return err
+		}
+		if err := r.removeFile(filepath.Join(dir, name+".service")); err != nil {
+			return err
+		}
 	}
 
-	timerFile, err := os.Create(systemdTimerPath)
-	if err != nil {
-		return fmt.Errorf("failed to create timer file: %w", err)
+	if err := r.command(append(systemctl, "daemon-reload")...); err != nil {
+		return err
+	}
+	for _, timer := range timers {
+		if err := r.command(append(systemctl, "enable", "--now", timer)...); err != nil {
+			return err
+		}
 	}
-	defer timerFile.Close()
 
-	if err := timerTmpl.Execute(timerFile, timerData); err != nil {
-		return fmt.Errorf("failed to write timer file: %w", err)
+	return nil
+}
+
+// removeOtherModes removes the scheduler entries of the modes other than
+// mode, left by an install with another --mode
+func removeOtherModes(r *runner, mode string) error {
+	if mode != ModeSystemd {
+		if err := replaceSystemdUnits(r, systemdUnitDir, []string{"systemctl"}); err != nil {
+			return err
+		}
+	}
+	if mode != ModeOpenRC && fileExists(openrcScriptPath) {
+		r.tryCommand("rc-service", "hist_scanner", "stop")
+		r.tryCommand("rc-update", "del", "hist_scanner", "default")
+		if err := r.removeFile(openrcScriptPath); err != nil {
+			return err
+		}
 	}
+	if mode != ModeCron && fileExists(cronFilePath) {
+		if err := r.removeFile(cronFilePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	// Reload systemd and enable timer
-	commands := [][]string{
-		{"systemctl", "daemon-reload"},
-		{"systemctl", "enable", "hist_scanner.timer"},
-		{"systemctl", "start", "hist_scanner.timer"},
+// replaceSystemdUnits disables and removes the units of every schedule in
+// dir as install steps, when another mode replaces them
+func replaceSystemdUnits(r *runner, dir string, systemctl []string) error {
+	names := append([]string{"hist_scanner"}, extraUnits(dir)...)
+	if !fileExists(filepath.Join(dir, "hist_scanner.timer")) && len(names) == 1 {
+		return nil
 	}
 
-	for _, args := range commands {
-		cmd := exec.Command(args[0], args[1:]...)
-		if output, err := cmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("failed to run %v: %w\n%s", args, err, output)
+	for _, name := range names {
+		r.tryCommand(append(systemctl, "disable", "--now", name+".timer")...)
+		if err := r.removeFile(filepath.Join(dir, name+".timer")); err != nil {
+			return err
+		}
+		if err := r.removeFile(filepath.Join(dir, name+".service")); err != nil {
+			return err
 		}
 	}
-
+	r.tryCommand(append(systemctl, "daemon-reload")...)
 	return nil
 }
 
-// Uninstall removes the scanner from systemd
-func (i *LinuxInstaller) Uninstall() error {
+// removeSystemdUnits disables and removes the units of every schedule in dir
+func removeSystemdUnits(r *removal, dir string, systemctl []string) {
+	names := append([]string{"hist_scanner"}, extraUnits(dir)...)
+	if !fileExists(filepath.Join(dir, "hist_scanner.timer")) && len(names) == 1 {
+		return
+	}
+
+	for _, name := range names {
+		exec.Command(systemctl[0], append(systemctl[1:], "disable", "--now", name+".timer")...).Run()
+		r.file(filepath.Join(dir, name+".timer"))
+		r.file(filepath.Join(dir, name+".service"))
+	}
+	exec.Command(systemctl[0], append(systemctl[1:], "daemon-reload")...).Run()
+}
+
+// extraUnits returns the unit names (without suffix) of additional schedules in dir
+func extraUnits(dir string) []string {
+	matches, _ := filepath.Glob(filepath.Join(dir, "hist_scanner-*.timer"))
+	names := make([]string, len(matches))
+	for i, m := range matches {
+		names[i] = strings.TrimSuffix(filepath.Base(m), ".timer")
+	}
+	return names
+}
+
+// writablePaths returns the directories a scan writes to outside the systemd
+// state directory, so the ProtectSystem=strict sandbox allows them
+func writablePaths(cfg *config.Config) []string {
+	var dirs []string
+	add := func(dir string) {
+		if dir != "" && dir != "." && !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	// Files written or rotated in place, and sockets created in their directory
+	files := []string{cfg.StateFile, cfg.LogPath(), cfg.AuditLog, cfg.DeviceKey}
+	if cfg.ControlSocket != "off" {
+		files = append(files, cfg.ControlSocket)
+	}
+	for _, spec := range cfg.Sinks {
+		if name, arg, err := sink.Parse(spec); err == nil && name == "file" {
+			files = append(files, arg)
+		}
+	}
+	for _, path := range files {
+		if path != "" {
+			add(filepath.Dir(path))
+		}
+	}
+
+	// Directories written to as a whole: database copies and cleanup
+	add(cfg.TempDir)
+	for _, dir := range cfg.RetentionPaths {
+		add(dir)
+	}
+
+	// Scans rewrite missing or outdated user notices
+	if len(cfg.Notice) > 0 {
+		files, _ := notice.Files(cfg.Notice, cfg.NoticeText)
+		for _, f := range files {
+			add(filepath.Dir(f.Path))
+		}
+	}
+	return dirs
+}
+
+// Uninstall removes the scanner from systemd, OpenRC and cron
+func (i *LinuxInstaller) Uninstall(opts UninstallOptions) ([]string, error) {
+	if i.scope == ScopeUser {
+		return uninstallUser(opts)
+	}
+
 	// Check for root
 	if os.Getuid() != 0 {
-		return fmt.Errorf("uninstallation requires root privileges")
+		return nil, fmt.Errorf("uninstallation requires root privileges")
 	}
 
-	paths := GetInstallPaths()
+	paths := GetInstallPaths(ScopeSystem)
+	r := &removal{}
+
+	// Remove every scheduler mechanism we may have installed
+	removeSystemdUnits(r, systemdUnitDir, []string{"systemctl"})
+	removeOpenRC(r)
+	r.file(cronFilePath)
+
+	// Remove files
+	r.purgeData(paths.ConfigPath, opts)
+	r.removeMetadata(ScopeSystem)
+	r.removeNotice()
+	r.file(paths.BinaryPath)
+	r.file(paths.ConfigPath)
+	r.dir(filepath.Dir(paths.ConfigPath))
+
+	return r.removed, r.err()
+}
 
-	// Stop and disable timer
-	commands := [][]string{
-		{"systemctl", "stop", "hist_scanner.timer"},
-		{"systemctl", "disable", "hist_scanner.timer"},
-		{"systemctl", "daemon-reload"},
+// Upgrade replaces the installed binary and restarts the OpenRC daemon if used.
+// Timers and cron entries run the new binary on their next start.
+func (i *LinuxInstaller) Upgrade() error {
+	if i.scope == ScopeSystem && os.Getuid() != 0 {
+		return fmt.Errorf("upgrade requires root privileges")
 	}
 
-	for _, args := range commands {
-		cmd := exec.Command(args[0], args[1:]...)
-		cmd.Run() // Ignore errors
+	if err := CopyBinary(GetInstallPaths(i.scope).BinaryPath); err != nil {
+		return fmt.Errorf("failed to copy binary: %w", err)
 	}
 
-	// Remove files
-	RemoveFile(systemdTimerPath)
-	RemoveFile(systemdServicePath)
-	RemoveFile(paths.BinaryPath)
-	RemoveFile(paths.ConfigPath)
-	RemoveDir(filepath.Dir(paths.ConfigPath))
+	if i.scope == ScopeSystem && fileExists(openrcScriptPath) {
+		cmd := exec.Command("rc-service", "hist_scanner", "restart")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to restart OpenRC service: %w\n%s", err, output)
+		}
+	}
 
 	return nil
 }
 
-// IsInstalled checks if the scanner is installed
+// IsInstalled checks if the scanner is installed with any supported scheduler
 func (i *LinuxInstaller) IsInstalled() bool {
-	_, err := os.Stat(systemdTimerPath)
-	return err == nil
+	if i.scope == ScopeUser {
+		return userInstalled()
+	}
+	return fileExists(systemdTimerPath) || fileExists(openrcScriptPath) || fileExists(cronFilePath)
+}
+
+// Verify checks the installation for drift and optionally repairs it
+func (i *LinuxInstaller) Verify(fix bool) []Check {
+	if i.scope == ScopeUser {
+		return verifyInstall(ScopeUser, fix, userSchedulerEntry())
+	}
+
+	var entry *schedulerEntry
+	switch {
+	case fileExists(systemdTimerPath):
+		entry = systemdEntry(systemdServicePath, []string{"systemctl", "daemon-reload"})
+	case fileExists(openrcScriptPath):
+		entry = openrcEntry()
+	case fileExists(cronFilePath):
+		entry = cronFileEntry()
+	}
+	return verifyInstall(ScopeSystem, fix, entry)
+}
+
+// systemdEntry reads the executable from the service unit's ExecStart=
+func systemdEntry(servicePath string, reload []string) *schedulerEntry {
+	data, err := os.ReadFile(servicePath)
+	if err != nil {
+		return nil
+	}
+
+	binary, _, _ := strings.Cut(unitValue(string(data), "ExecStart"), " ")
+	replace := func(binaryPath string) (string, string) {
+		return "ExecStart=" + binary + " ", "ExecStart=" + binaryPath + " "
+	}
+	// Units of additional schedules run the same binary
+	files := []string{servicePath}
+	dir := filepath.Dir(servicePath)
+	for _, name := range extraUnits(dir) {
+		files = append(files, filepath.Join(dir, name+".service"))
+	}
+	return fileEntry("systemd unit "+servicePath, files, binary, replace, reload)
+}
+
+// Status reports the installed scheduler entry and its last run
+func (i *LinuxInstaller) Status() Status {
+	paths := GetInstallPaths(i.scope)
+	st := Status{
+		Installed:  i.IsInstalled(),
+		Scope:      i.scope,
+		BinaryPath: paths.BinaryPath,
+		ConfigPath: paths.ConfigPath,
+	}
+
+	if i.scope == ScopeUser {
+		userStatus(&st)
+		return st
+	}
+
+	switch {
+	case fileExists(systemdTimerPath):
+		systemdStatus(&st, systemdTimerPath, false)
+	case fileExists(openrcScriptPath):
+		openrcStatus(&st)
+	case fileExists(cronFilePath):
+		st.Mode = ModeCron
+		if data, err := os.ReadFile(cronFilePath); err == nil {
+			st.Interval = cronEntrySchedule(strings.Split(string(data), "\n"))
+		}
+	}
+
+	return st
 }
 
-// formatDuration formats a duration for systemd (e.g., "24h" -> "24h", "6h" -> "6h")
-func formatDuration(d time.Duration) string {
-	hours := int(d.Hours())
-	if hours >= 24 && hours%24 == 0 {
-		return fmt.Sprintf("%dd", hours/24)
+// detectInitSystem returns the scheduler mode to use on this machine, or
+// ModeDefault if none is available
+func detectInitSystem() string {
+	// sd_booted(): systemd is PID 1 if this directory exists
+	if fileExists("/run/systemd/system") {
+		return ModeSystemd
 	}
-	if hours > 0 {
-		return fmt.Sprintf("%dh", hours)
+	if fileExists("/sbin/openrc-run") || fileExists("/run/openrc") {
+		return ModeOpenRC
 	}
-	minutes := int(d.Minutes())
-	if minutes > 0 {
-		return fmt.Sprintf("%dm", minutes)
+	if fileExists(filepath.Dir(cronFilePath)) {
+		return ModeCron
 	}
-	return fmt.Sprintf("%ds", int(d.Seconds()))
+	return ModeDefault
 }
@@ -6,18 +6,29 @@
 package installer
 
 import (
+	"encoding/csv"
 	"fmt"
+	"html"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strconv"
-	"time"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/sys/windows"
 
 	"hist_scanner/internal/config"
+	"hist_scanner/internal/logging"
+	"hist_scanner/internal/platform"
+	"hist_scanner/internal/service"
 )
 
 // newPlatformInstaller creates the Windows installer
-func newPlatformInstaller() (Installer, error) {
+func newPlatformInstaller(scope Scope) (Installer, error) {
+	if scope != ScopeSystem {
+		return nil, fmt.Errorf("install scope %q is not supported on this platform", scope)
+	}
 	return &WindowsInstaller{}, nil
 }
 
@@ -26,104 +37,342 @@ const taskName = "BrowserHistoryScanner"
 // WindowsInstaller handles installation on Windows using Task Scheduler
 type WindowsInstaller struct{}
 
-// Install installs the scanner as a scheduled task
-func (i *WindowsInstaller) Install(cfg *config.Config, interval time.Duration, runAsUser string) error {
-	paths := GetInstallPaths()
+// Install installs the scanner as a scheduled task or, with ModeService, as a Windows service
+func (i *WindowsInstaller) Install(cfg *config.Config, opts Options) ([]Step, error) {
+	switch opts.Mode {
+	case ModeDefault, ModeTask, ModeService:
+	default:
+		return nil, fmt.Errorf("install mode %q is not supported on Windows (use %q or %q)", opts.Mode, ModeTask, ModeService)
+	}
 
-	// Default user to SYSTEM
-	if runAsUser == "" {
-		runAsUser = "SYSTEM"
+	// Per-user runs scan only the logged-on user's profiles
+	if opts.RunAsCurrentUser {
+		if opts.Mode == ModeService {
+			return nil, fmt.Errorf("--run-as-current-user requires task mode")
+		}
+		if opts.RunAsUser != "" {
+			return nil, fmt.Errorf("--run-as-current-user cannot be combined with --user")
+		}
+		// One state file for all users would mix their positions
+		if cfg.StateFile != "" {
+			return nil, fmt.Errorf("--run-as-current-user keeps each user's state in %%LOCALAPPDATA%%; remove state_file from the config")
+		}
+		userCfg := *cfg
+		userCfg.CurrentUserOnly = true
+		cfg = &userCfg
 	}
 
+	paths := GetInstallPaths(ScopeSystem)
+	r := &runner{dryRun: opts.DryRun}
+
 	// Create directories
-	binaryDir := filepath.Dir(paths.BinaryPath)
-	if err := os.MkdirAll(binaryDir, 0755); err != nil {
-		return fmt.Errorf("failed to create binary directory: %w", err)
+	if err := r.mkdirAll(filepath.Dir(paths.BinaryPath), 0755); err != nil {
+		return r.steps, err
 	}
 
 	// Copy binary
-	if err := CopyBinary(paths.BinaryPath); err != nil {
-		return fmt.Errorf("failed to copy binary: %w", err)
+	if err := r.copyBinary(paths.BinaryPath); err != nil {
+		return r.steps, err
 	}
 
 	// Write config
-	if err := WriteConfig(cfg, paths.ConfigPath); err != nil {
-		return fmt.Errorf("failed to write config: %w", err)
+	if err := r.writeConfig(cfg, paths.ConfigPath); err != nil {
+		return r.steps, err
+	}
+
+	// The mode bits set no access list on Windows. Tasks of the logged-on
+	// user or of a --user account need to read the config, API key
+	// included; otherwise only SYSTEM and Administrators may.
+	var readers []string
+	switch {
+	case opts.RunAsCurrentUser:
+		readers = append(readers, usersSID)
+	case opts.RunAsUser != "" && !strings.EqualFold(opts.RunAsUser, "SYSTEM"):
+		sid, _, _, err := windows.LookupSID("", opts.RunAsUser)
+		if err != nil {
+			return r.steps, fmt.Errorf("failed to look up %s: %w", opts.RunAsUser, err)
+		}
+		readers = append(readers, sid.String())
+	}
+	err := r.register("access list of "+paths.ConfigPath, configACLDescription(readers), func() error {
+		return platform.RestrictFile(paths.ConfigPath, readers...)
+	})
+	if err != nil {
+		return r.steps, err
+	}
+
+	// Event Log logging needs the event source registered
+	if strings.EqualFold(cfg.LogFile, logging.TargetEventLog) {
+		if err := r.register("event log source "+logging.EventSource, "", logging.InstallEventSource); err != nil {
+			return r.steps, err
+		}
+	}
+
+	// Only one scheduling mechanism may be active at a time
+	if opts.Mode == ModeService {
+		r.tryCommand("schtasks", "/delete", "/tn", taskName, "/f")
+		err := installService(r, paths, opts)
+		return r.steps, err
+	}
+
+	err = r.register("remove service "+service.Name+" (if present)", "", func() error {
+		if err := removeService(); err != nil {
+			return fmt.Errorf("failed to remove existing service: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return r.steps, err
+	}
+
+	err = installTask(r, paths, opts)
+	return r.steps, err
+}
+
+// configACLDescription describes the config access list for the install plan
+func configACLDescription(readers []string) string {
+	desc := "full access: SYSTEM, Administrators, the installing account"
+	if len(readers) > 0 {
+		desc += "\nread access: " + strings.Join(readers, ", ")
+		if readers[0] == usersSID {
+			desc += " (BUILTIN\\Users: every interactive user can read the API key)"
+		}
+	}
+	return desc
+}
+
+// installTask registers one scheduled task per schedule from full XML task definitions
+func installTask(r *runner, paths InstallPaths, opts Options) error {
+	scheds, err := opts.schedules()
+	if err != nil {
+		return err
 	}
 
-	// Delete existing task if present
-	exec.Command("schtasks", "/delete", "/tn", taskName, "/f").Run()
+	var names []string
+	for i, sched := range scheds {
+		name := entryName(taskName, i, sched)
+		definition, err := buildTaskXML(name, paths, opts, sched)
+		if err != nil {
+			return err
+		}
 
-	// Create scheduled task
-	// Build command for task
-	taskCmd := fmt.Sprintf(`"%s" run --config "%s"`, paths.BinaryPath, paths.ConfigPath)
+		// Delete existing task if present
+		r.tryCommand("schtasks", "/delete", "/tn", name, "/f")
 
-	// Calculate repetition interval
-	intervalMinutes := int(interval.Minutes())
-	if intervalMinutes < 1 {
-		intervalMinutes = 1
+		// The definition goes through a temp file, removed once the task is created
+		target := fmt.Sprintf("schtasks /create /tn %s /xml <task definition> /f", name)
+		err = r.register(target, definition, func() error {
+			return createTask(name, definition)
+		})
+		if err != nil {
+			return err
+		}
+		names = append(names, name)
 	}
 
-	// Use schtasks to create the task
-	// For intervals > 24h, use daily schedule
-	// For intervals <= 24h, use repetition
-	var args []string
-	if interval >= 24*time.Hour {
-		days := int(interval.Hours() / 24)
-		if days < 1 {
-			days = 1
+	// Tasks of schedules that are no longer configured
+	for _, name := range extraTasks() {
+		if !slices.Contains(names, name) {
+			r.tryCommand("schtasks", "/delete", "/tn", name, "/f")
 		}
-		args = []string{
-			"/create",
-			"/tn", taskName,
-			"/tr", taskCmd,
-			"/sc", "daily",
-			"/mo", strconv.Itoa(days),
-			"/ru", runAsUser,
-			"/rl", "HIGHEST",
-			"/f",
+	}
+
+	return nil
+}
+
+// extraTasks returns the names of scheduled tasks of additional schedules
+func extraTasks() []string {
+	output, err := exec.Command("schtasks", "/query", "/fo", "csv", "/nh").Output()
+	if err != nil {
+		return nil
+	}
+	rows, err := csv.NewReader(strings.NewReader(string(output))).ReadAll()
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, row := range rows {
+		if len(row) == 0 {
+			continue
 		}
-	} else {
-		args = []string{
-			"/create",
-			"/tn", taskName,
-			"/tr", taskCmd,
-			"/sc", "minute",
-			"/mo", strconv.Itoa(intervalMinutes),
-			"/ru", runAsUser,
-			"/rl", "HIGHEST",
-			"/f",
+		name := strings.TrimPrefix(row[0], `\`)
+		if strings.HasPrefix(name, taskName+"-") && !slices.Contains(names, name) {
+			names = append(names, name)
 		}
 	}
+	return names
+}
 
-	cmd := exec.Command("schtasks", args...)
-	output, err := cmd.CombinedOutput()
+// createTask creates (or replaces) a scheduled task from an XML definition
+func createTask(name, definition string) error {
+	xmlPath, err := writeTaskXML(definition)
 	if err != nil {
-		return fmt.Errorf("failed to create scheduled task: %w\n%s", err, output)
+		return err
 	}
+	defer os.Remove(xmlPath)
 
+	cmd := exec.Command("schtasks", "/create", "/tn", name, "/xml", xmlPath, "/f")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create scheduled task: %w\n%s", err, output)
+	}
 	return nil
 }
 
 // Uninstall removes the scanner from Task Scheduler
-func (i *WindowsInstaller) Uninstall() error {
-	paths := GetInstallPaths()
+func (i *WindowsInstaller) Uninstall(opts UninstallOptions) ([]string, error) {
+	paths := GetInstallPaths(ScopeSystem)
+	r := &removal{}
 
-	// Delete scheduled task
-	cmd := exec.Command("schtasks", "/delete", "/tn", taskName, "/f")
-	cmd.Run() // Ignore errors
+	// Delete the scheduled tasks of all schedules
+	for _, name := range append([]string{taskName}, extraTasks()...) {
+		cmd := exec.Command("schtasks", "/delete", "/tn", name, "/f")
+		if cmd.Run() == nil {
+			r.add("scheduled task " + name)
+		}
+	}
+
+	// Stop and delete the service (service install mode)
+	if serviceExists() {
+		if err := removeService(); err != nil {
+			return r.removed, fmt.Errorf("failed to remove service: %w", err)
+		}
+		r.add("service " + service.Name)
+	}
+
+	if logging.RemoveEventSource() == nil {
+		r.add("event log source " + logging.EventSource)
+	}
 
 	// Remove files
-	RemoveFile(paths.BinaryPath)
-	RemoveFile(paths.ConfigPath)
-	RemoveDir(filepath.Dir(paths.BinaryPath))
-	RemoveDir(filepath.Dir(paths.ConfigPath))
+	r.purgeData(paths.ConfigPath, opts)
+	r.removeMetadata(ScopeSystem)
+	r.removeNotice()
+	r.file(paths.BinaryPath)
+	r.file(paths.ConfigPath)
+	r.dir(filepath.Dir(paths.BinaryPath))
+	r.dir(filepath.Dir(paths.ConfigPath))
+
+	return r.removed, r.err()
+}
 
+// Upgrade replaces the installed binary, restarting the service in service mode.
+// The scheduled task or service registration is kept as is.
+func (i *WindowsInstaller) Upgrade() error {
+	paths := GetInstallPaths(ScopeSystem)
+	if serviceExists() {
+		return upgradeService(paths)
+	}
+
+	if err := CopyBinary(paths.BinaryPath); err != nil {
+		return fmt.Errorf("failed to copy binary: %w", err)
+	}
 	return nil
 }
 
-// IsInstalled checks if the scanner is installed
+// IsInstalled checks if the scanner is installed as a task or service
 func (i *WindowsInstaller) IsInstalled() bool {
 	cmd := exec.Command("schtasks", "/query", "/tn", taskName)
-	return cmd.Run() == nil
+	return cmd.Run() == nil || serviceExists()
+}
+
+// Verify checks the installation for drift and optionally repairs it
+func (i *WindowsInstaller) Verify(fix bool) []Check {
+	if serviceExists() {
+		return verifyInstall(ScopeSystem, fix, serviceEntry())
+	}
+	return verifyInstall(ScopeSystem, fix, taskEntry())
+}
+
+// taskCommandRe extracts the executable from the task XML
+var taskCommandRe = regexp.MustCompile(`<Command>([^<]*)</Command>`)
+
+// taskEntry reads the executable from the registered task definition
+func taskEntry() *schedulerEntry {
+	output, err := exec.Command("schtasks", "/query", "/tn", taskName, "/xml").Output()
+	if err != nil {
+		return nil
+	}
+
+	m := taskCommandRe.FindSubmatch(output)
+	if m == nil {
+		return nil
+	}
+
+	return &schedulerEntry{
+		name:   "scheduled task " + taskName,
+		binary: html.UnescapeString(string(m[1])),
+		repoint: func(binaryPath string) error {
+			// Tasks of additional schedules run the same binary
+			for _, name := range append([]string{taskName}, extraTasks()...) {
+				if err := repointTask(name, binaryPath); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// repointTask re-creates a scheduled task with its command set to binaryPath
+func repointTask(name, binaryPath string) error {
+	output, err := exec.Command("schtasks", "/query", "/tn", name, "/xml").Output()
+	if err != nil {
+		return fmt.Errorf("failed to query scheduled task %s: %w", name, err)
+	}
+
+	command := "<Command>" + xmlEscape(binaryPath) + "</Command>"
+	definition := taskCommandRe.ReplaceAllLiteralString(string(output), command)
+	return createTask(name, definition)
+}
+
+// taskIntervalRe extracts the repetition interval of a time trigger or the
+// day interval of a calendar trigger from the task XML
+var taskIntervalRe = regexp.MustCompile(`<(Interval|DaysInterval)>(\w+)</`)
+
+// taskInterval returns the interval of a task as an ISO 8601 duration
+func taskInterval(definition []byte) string {
+	m := taskIntervalRe.FindSubmatch(definition)
+	switch {
+	case m == nil:
+		return ""
+	case string(m[1]) == "DaysInterval":
+		return "P" + string(m[2]) + "D"
+	default:
+		return string(m[2])
+	}
+}
+
+// Status reports the scheduled task or service and its last run
+func (i *WindowsInstaller) Status() Status {
+	paths := GetInstallPaths(ScopeSystem)
+	st := Status{
+		Installed:  i.IsInstalled(),
+		Scope:      ScopeSystem,
+		BinaryPath: paths.BinaryPath,
+		ConfigPath: paths.ConfigPath,
+	}
+
+	if serviceExists() {
+		serviceStatus(&st)
+		return st
+	}
+
+	output, err := exec.Command("schtasks", "/query", "/tn", taskName, "/xml").Output()
+	if err != nil {
+		return st
+	}
+	st.Mode = ModeTask
+
+	// Each additional schedule has a task of its own. The last run is left
+	// to the run records: the verbose schtasks output is localized.
+	intervals := []string{taskInterval(output)}
+	for _, name := range extraTasks() {
+		if output, err := exec.Command("schtasks", "/query", "/tn", name, "/xml").Output(); err == nil {
+			intervals = append(intervals, taskInterval(output))
+		}
+	}
+	st.Interval = strings.Join(slices.DeleteFunc(intervals, func(s string) bool { return s == "" }), ", ")
+
+	return st
 }
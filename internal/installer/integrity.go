@@ -0,0 +1,133 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package installer
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Manifest identifies the build of the running binary. It is embedded at
+// link time and recorded in install.json with the hash of the installed binary.
+type Manifest struct {
+	Version   string
+	Commit    string
+	BuildTime string
+}
+
+// build is the manifest of the running binary
+var build = Manifest{Version: "dev"}
+
+// SetBuild sets the manifest of the running binary from its link-time values
+func SetBuild(version, commit, buildTime string) {
+	build = Manifest{Version: version, Commit: commit, BuildTime: buildTime}
+}
+
+// BinaryHash returns the hex SHA-256 digest of a binary
+func BinaryHash(path string) (string, error) {
+	sum, err := fileHash(path)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// CheckIntegrity checks the running binary against the install metadata of
+// the installation that registered it with the scheduler: its hash must be
+// the one recorded at install or upgrade, and its build the recorded one.
+// A binary that was not installed (run from elsewhere) is not checked.
+func CheckIntegrity() error {
+	running, err := runningBinary()
+	if err != nil {
+		return err
+	}
+
+	for _, scope := range []Scope{ScopeSystem, ScopeUser} {
+		md, err := ReadMetadata(scope)
+		if err != nil || !samePath(md.BinaryPath, running) {
+			continue
+		}
+		if md.Version != build.Version || md.Commit != build.Commit || (md.BuildTime != "" && md.BuildTime != build.BuildTime) {
+			return fmt.Errorf("%s is build %s (commit %s), but %s (commit %s) was installed", running, build.Version, build.Commit, md.Version, md.Commit)
+		}
+		// Installed before hashes were recorded; verify --fix records it
+		if md.SHA256 == "" {
+			return nil
+		}
+		hash, err := BinaryHash(running)
+		if err != nil {
+			return err
+		}
+		if hash != md.SHA256 {
+			return fmt.Errorf("%s was modified after installation (sha256 %s, expected %s)", running, hash, md.SHA256)
+		}
+		return nil
+	}
+	return nil
+}
+
+// verifyIntegrity checks the installed binary against the hash recorded in
+// install.json. With fix, the hash of the installed binary is recorded if it
+// is the running one (verify --fix has already replaced it otherwise).
+func verifyIntegrity(scope Scope, binaryPath string, fix bool) Check {
+	c := Check{Name: "integrity"}
+
+	md, err := ReadMetadata(scope)
+	if err != nil {
+		c.Detail = fmt.Sprintf("failed to read install metadata: %v", err)
+		return c
+	}
+
+	hash, err := BinaryHash(binaryPath)
+	if err != nil {
+		c.Detail = err.Error()
+		return c
+	}
+
+	switch {
+	case md.SHA256 == hash:
+		c.OK = true
+		c.Detail = fmt.Sprintf("%s has the hash recorded at install (%s, commit %s)", binaryPath, md.Version, md.Commit)
+		return c
+	case md.SHA256 == "":
+		c.Detail = fmt.Sprintf("no hash of %s was recorded at install", binaryPath)
+	default:
+		c.Detail = fmt.Sprintf("%s was modified after installation (sha256 %s, expected %s)", binaryPath, hash, md.SHA256)
+	}
+
+	if fix {
+		running, err := runningBinary()
+		if err == nil {
+			var runningHash string
+			if runningHash, err = BinaryHash(running); err == nil && runningHash != hash {
+				err = fmt.Errorf("%s is not the running binary", binaryPath)
+			}
+		}
+		if err == nil {
+			md.Version, md.Commit, md.BuildTime = build.Version, build.Commit, build.BuildTime
+			err = WriteMetadata(*md)
+		}
+		if err != nil {
+			c.Detail += fmt.Sprintf("; fix failed: %v", err)
+			return c
+		}
+		c.OK, c.Fixed = true, true
+		c.Detail += "; recorded the running binary"
+	}
+	return c
+}
+
+// runningBinary returns the path of the running executable with symlinks resolved
+func runningBinary() (string, error) {
+	running, err := os.Executable()
+	if err == nil {
+		running, err = filepath.EvalSymlinks(running)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to locate running executable: %w", err)
+	}
+	return running, nil
+}
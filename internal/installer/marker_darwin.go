@@ -0,0 +1,55 @@
+//go:build darwin
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package installer
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// markerDomain is the preferences domain holding the detection marker
+const markerDomain = "com.binadox.hist_scanner"
+
+// markerPath returns the preferences plist (without .plist, as defaults expects)
+func markerPath(scope Scope) string {
+	if scope == ScopeUser {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, "Library", "Preferences", markerDomain)
+	}
+	return filepath.Join("/Library/Preferences", markerDomain)
+}
+
+// writeMarker records the installed version in a preferences plist, readable
+// by JAMF extension attributes with "defaults read"
+func writeMarker(md Metadata) error {
+	path := markerPath(md.Scope)
+	values := [][]string{
+		{"Version", "-string", md.Version},
+		{"BinaryPath", "-string", md.BinaryPath},
+		{"InstalledAt", "-string", md.InstalledAt.Format(time.RFC3339)},
+	}
+
+	// defaults goes through cfprefsd, so readers never see a stale cached copy
+	for _, v := range values {
+		args := append([]string{"write", path}, v...)
+		if output, err := exec.Command("defaults", args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to run defaults %v: %w\n%s", args, err, output)
+		}
+	}
+	return nil
+}
+
+// removeMarker deletes the preferences plist and returns its path if it existed
+func removeMarker(scope Scope) string {
+	path := markerPath(scope)
+	if exec.Command("defaults", "delete", path).Run() != nil {
+		return ""
+	}
+	return path + ".plist"
+}
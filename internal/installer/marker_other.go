@@ -0,0 +1,16 @@
+//go:build !windows && !darwin
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package installer
+
+// writeMarker is a no-op: install.json is the detection marker on this platform
+func writeMarker(md Metadata) error {
+	return nil
+}
+
+// removeMarker is a no-op on this platform
+func removeMarker(scope Scope) string {
+	return ""
+}
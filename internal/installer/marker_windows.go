@@ -0,0 +1,49 @@
+//go:build windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package installer
+
+import (
+	"time"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+const (
+	markerKey       = `SOFTWARE\Binadox\hist_scanner`
+	markerVendorKey = `SOFTWARE\Binadox`
+)
+
+// writeMarker records the installed version under HKLM, for Intune
+// registry detection rules
+func writeMarker(md Metadata) error {
+	k, _, err := registry.CreateKey(registry.LOCAL_MACHINE, markerKey, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer k.Close()
+
+	values := map[string]string{
+		"Version":     md.Version,
+		"BinaryPath":  md.BinaryPath,
+		"InstalledAt": md.InstalledAt.Format(time.RFC3339),
+	}
+	for name, value := range values {
+		if err := k.SetStringValue(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeMarker deletes the registry key and returns its name if it existed
+func removeMarker(scope Scope) string {
+	if registry.DeleteKey(registry.LOCAL_MACHINE, markerKey) != nil {
+		return ""
+	}
+	// Only succeeds when no other Binadox product uses the vendor key
+	registry.DeleteKey(registry.LOCAL_MACHINE, markerVendorKey)
+	return `HKLM\` + markerKey
+}
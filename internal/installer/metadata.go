@@ -0,0 +1,85 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// metadataFileName is written next to the config file after installation
+const metadataFileName = "install.json"
+
+// Metadata describes an installation for MDM detection rules (Intune, JAMF)
+// and fleet inventory
+type Metadata struct {
+	Version     string    `json:"version"`
+	Commit      string    `json:"commit,omitempty"`
+	BuildTime   string    `json:"build_time,omitempty"`
+	Scope       Scope     `json:"scope"`
+	Mode        string    `json:"mode,omitempty"`
+	BinaryPath  string    `json:"binary_path"`
+	SHA256      string    `json:"binary_sha256,omitempty"` // Set by WriteMetadata
+	ConfigPath  string    `json:"config_path"`
+	InstalledAt time.Time `json:"installed_at"`
+}
+
+// MetadataPath returns the install metadata file for the given scope
+func MetadataPath(scope Scope) string {
+	return filepath.Join(filepath.Dir(GetInstallPaths(scope).ConfigPath), metadataFileName)
+}
+
+// WriteMetadata writes install.json with the hash of the installed binary
+// and the platform detection marker (registry key on Windows, preferences
+// plist on macOS)
+func WriteMetadata(md Metadata) error {
+	hash, err := BinaryHash(md.BinaryPath)
+	if err != nil {
+		return fmt.Errorf("failed to hash installed binary: %w", err)
+	}
+	md.SHA256 = hash
+
+	data, err := json.MarshalIndent(md, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal install metadata: %w", err)
+	}
+
+	path := MetadataPath(md.Scope)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create metadata directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write install metadata: %w", err)
+	}
+
+	if err := writeMarker(md); err != nil {
+		return fmt.Errorf("failed to write detection marker: %w", err)
+	}
+	return nil
+}
+
+// ReadMetadata reads install.json for the given scope
+func ReadMetadata(scope Scope) (*Metadata, error) {
+	data, err := os.ReadFile(MetadataPath(scope))
+	if err != nil {
+		return nil, err
+	}
+
+	var md Metadata
+	if err := json.Unmarshal(data, &md); err != nil {
+		return nil, fmt.Errorf("failed to parse install metadata: %w", err)
+	}
+	return &md, nil
+}
+
+// removeMetadata removes install.json and the detection marker
+func (r *removal) removeMetadata(scope Scope) {
+	r.file(MetadataPath(scope))
+	if item := removeMarker(scope); item != "" {
+		r.add(item)
+	}
+}
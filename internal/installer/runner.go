@@ -0,0 +1,130 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package installer
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"hist_scanner/internal/config"
+)
+
+// Install step actions
+const (
+	ActionWrite    = "write"    // Write a file
+	ActionRun      = "run"      // Run a command
+	ActionRegister = "register" // Change a scheduler registration through an API
+	ActionRemove   = "remove"   // Remove a file left over from an earlier install
+)
+
+// Step is one action performed (or, with Options.DryRun, planned) by Install
+type Step struct {
+	Action  string      `json:"action"`
+	Target  string      `json:"target"`            // File path, command line or scheduler entry
+	Mode    os.FileMode `json:"mode,omitempty"`    // File mode for ActionWrite
+	Content string      `json:"content,omitempty"` // File content or definition, for review
+}
+
+// runner performs install actions and records them; in dry-run mode it only records
+type runner struct {
+	dryRun bool
+	steps  []Step
+}
+
+// writeFile writes a file with the given permissions
+func (r *runner) writeFile(path string, data []byte, perm os.FileMode) error {
+	r.steps = append(r.steps, Step{Action: ActionWrite, Target: path, Mode: perm, Content: string(data)})
+	if r.dryRun {
+		return nil
+	}
+
+	if err := os.WriteFile(path, data, perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	// WriteFile keeps the mode of an existing file
+	return os.Chmod(path, perm)
+}
+
+// mkdirAll creates a directory and its parents
+func (r *runner) mkdirAll(path string, perm os.FileMode) error {
+	if r.dryRun {
+		return nil
+	}
+	if err := os.MkdirAll(path, perm); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", path, err)
+	}
+	return nil
+}
+
+// copyBinary installs the running executable at path
+func (r *runner) copyBinary(path string) error {
+	src, _ := os.Executable()
+	r.steps = append(r.steps, Step{Action: ActionWrite, Target: path, Mode: 0755, Content: "(copy of " + src + ")"})
+	if r.dryRun {
+		return nil
+	}
+
+	if err := CopyBinary(path); err != nil {
+		return fmt.Errorf("failed to copy binary: %w", err)
+	}
+	return nil
+}
+
+// writeConfig writes the config file; its content is not recorded since it holds the API key
+func (r *runner) writeConfig(cfg *config.Config, path string) error {
+	r.steps = append(r.steps, Step{Action: ActionWrite, Target: path, Mode: 0600})
+	if r.dryRun {
+		return nil
+	}
+
+	if err := WriteConfig(cfg, path); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
+
+// removeFile removes a file left over from an earlier install
+func (r *runner) removeFile(path string) error {
+	r.steps = append(r.steps, Step{Action: ActionRemove, Target: path})
+	if r.dryRun {
+		return nil
+	}
+	return RemoveFile(path)
+}
+
+// command runs a command and fails with its output on error
+func (r *runner) command(args ...string) error {
+	r.steps = append(r.steps, Step{Action: ActionRun, Target: strings.Join(args, " ")})
+	if r.dryRun {
+		return nil
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to run %v: %w\n%s", args, err, output)
+	}
+	return nil
+}
+
+// tryCommand runs a command whose failure is expected and ignored (e.g. removing
+// an entry that may not exist)
+func (r *runner) tryCommand(args ...string) {
+	r.steps = append(r.steps, Step{Action: ActionRun, Target: strings.Join(args, " ")})
+	if r.dryRun {
+		return
+	}
+	exec.Command(args[0], args[1:]...).Run()
+}
+
+// register changes a scheduler registration through fn (API calls, temp files)
+func (r *runner) register(target, content string, fn func() error) error {
+	r.steps = append(r.steps, Step{Action: ActionRegister, Target: target, Content: content})
+	if r.dryRun {
+		return nil
+	}
+	return fn()
+}
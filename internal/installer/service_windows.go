@@ -0,0 +1,311 @@
+//go:build windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+
+	"hist_scanner/internal/service"
+)
+
+// serviceStopTimeout bounds how long uninstall waits for the service to stop
+const serviceStopTimeout = 30 * time.Second
+
+// serviceDeleteTimeout bounds how long a removal waits for a deleted service
+// to disappear
+const serviceDeleteTimeout = 30 * time.Second
+
+// installService registers the scanner as an auto-start Windows service that
+// runs "hist_scanner daemon" and schedules scans internally
+func installService(r *runner, paths InstallPaths, opts Options) error {
+	// Services need a password for custom accounts; only LocalSystem is supported
+	if opts.RunAsUser != "" && !strings.EqualFold(opts.RunAsUser, "SYSTEM") {
+		return fmt.Errorf("service mode only supports running as SYSTEM (got %q)", opts.RunAsUser)
+	}
+
+	interval, err := daemonInterval(opts, "service")
+	if err != nil {
+		return err
+	}
+
+	args := []string{"daemon", "--config", paths.ConfigPath, "--interval", interval.String()}
+	for _, kv := range opts.Environment {
+		args = append(args, "--env", kv)
+	}
+
+	target := fmt.Sprintf("create and start service %s (automatic start, restart on failure)", service.Name)
+	commandLine := windows.EscapeArg(paths.BinaryPath)
+	for _, arg := range args {
+		commandLine += " " + windows.EscapeArg(arg)
+	}
+
+	return r.register(target, commandLine, func() error {
+		return createService(paths, args)
+	})
+}
+
+// createService replaces the scanner service registration and starts it
+func createService(paths InstallPaths, args []string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	// Replace an existing registration so binary path and arguments are current
+	if err := removeServiceWith(m); err != nil {
+		return err
+	}
+
+	s, err := m.CreateService(service.Name, paths.BinaryPath, mgr.Config{
+		DisplayName: "Browser History Scanner",
+		Description: "Scans browser history for security audit (Binadox hist_scanner)",
+		StartType:   mgr.StartAutomatic,
+	}, args...)
+	if err != nil {
+		return fmt.Errorf("failed to create service: %w", err)
+	}
+	defer s.Close()
+
+	// Restart the daemon if it crashes
+	recovery := []mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: time.Minute},
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Minute},
+		{Type: mgr.NoAction},
+	}
+	if err := s.SetRecoveryActions(recovery, uint32((24 * time.Hour).Seconds())); err != nil {
+		return fmt.Errorf("failed to set service recovery actions: %w", err)
+	}
+
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("failed to start service: %w", err)
+	}
+
+	return nil
+}
+
+// removeService stops and deletes the scanner service if it exists
+func removeService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	return removeServiceWith(m)
+}
+
+// removeServiceWith stops and deletes the scanner service using an open manager
+func removeServiceWith(m *mgr.Mgr) error {
+	s, err := m.OpenService(service.Name)
+	if err != nil {
+		// Not installed as a service
+		return nil
+	}
+
+	stopService(s)
+
+	err = s.Delete()
+	s.Close()
+	if err != nil {
+		return fmt.Errorf("failed to delete service: %w", err)
+	}
+
+	// Delete only marks the service while other handles to it are open
+	// (e.g. the Services console); creating it again fails until it is gone
+	deadline := time.Now().Add(serviceDeleteTimeout)
+	for {
+		s, err := m.OpenService(service.Name)
+		if errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+			return nil
+		}
+		if err == nil {
+			s.Close()
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("service %s is marked for deletion but still exists; close the Services console and retry", service.Name)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// stopService stops the service and waits until it has stopped (or the timeout expires)
+func stopService(s *mgr.Service) {
+	status, err := s.Control(svc.Stop)
+	if err != nil {
+		return
+	}
+
+	deadline := time.Now().Add(serviceStopTimeout)
+	for status.State != svc.Stopped && time.Now().Before(deadline) {
+		time.Sleep(500 * time.Millisecond)
+		if status, err = s.Query(); err != nil {
+			break
+		}
+	}
+}
+
+// upgradeService stops the service, replaces its binary and starts it again.
+// The registration (arguments, recovery actions) is left untouched.
+func upgradeService(paths InstallPaths) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(service.Name)
+	if err != nil {
+		return fmt.Errorf("failed to open service: %w", err)
+	}
+	defer s.Close()
+
+	stopService(s)
+
+	if err := CopyBinary(paths.BinaryPath); err != nil {
+		return fmt.Errorf("failed to copy binary: %w", err)
+	}
+
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("failed to start service: %w", err)
+	}
+	return nil
+}
+
+// serviceEntry reads the executable from the service registration
+func serviceEntry() *schedulerEntry {
+	m, err := mgr.Connect()
+	if err != nil {
+		return nil
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(service.Name)
+	if err != nil {
+		return nil
+	}
+	defer s.Close()
+
+	cfg, err := s.Config()
+	if err != nil {
+		return nil
+	}
+
+	// BinaryPathName is the full command line; the executable may be quoted
+	binary, args := splitCommandLine(cfg.BinaryPathName)
+	return &schedulerEntry{
+		name:   "service " + service.Name,
+		binary: binary,
+		repoint: func(binaryPath string) error {
+			return repointService(windows.EscapeArg(binaryPath) + args)
+		},
+	}
+}
+
+// repointService updates the service command line and restarts the service
+func repointService(commandLine string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(service.Name)
+	if err != nil {
+		return fmt.Errorf("failed to open service: %w", err)
+	}
+	defer s.Close()
+
+	cfg, err := s.Config()
+	if err != nil {
+		return fmt.Errorf("failed to read service config: %w", err)
+	}
+
+	cfg.BinaryPathName = commandLine
+	if err := s.UpdateConfig(cfg); err != nil {
+		return fmt.Errorf("failed to update service: %w", err)
+	}
+
+	stopService(s)
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("failed to start service: %w", err)
+	}
+	return nil
+}
+
+// splitCommandLine splits a command line into the executable and the
+// remaining arguments (with their leading space)
+func splitCommandLine(commandLine string) (string, string) {
+	if rest, ok := strings.CutPrefix(commandLine, `"`); ok {
+		if end := strings.Index(rest, `"`); end >= 0 {
+			return rest[:end], rest[end+1:]
+		}
+	}
+	if end := strings.Index(commandLine, " "); end >= 0 {
+		return commandLine[:end], commandLine[end:]
+	}
+	return commandLine, ""
+}
+
+// serviceExists checks if the scanner service is registered
+func serviceExists() bool {
+	m, err := mgr.Connect()
+	if err != nil {
+		return false
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(service.Name)
+	if err != nil {
+		return false
+	}
+	s.Close()
+	return true
+}
+
+// serviceStatus fills the interval from the service arguments and its current state
+func serviceStatus(st *Status) {
+	st.Mode = ModeService
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(service.Name)
+	if err != nil {
+		return
+	}
+	defer s.Close()
+
+	if cfg, err := s.Config(); err == nil {
+		if _, args, ok := strings.Cut(cfg.BinaryPathName, "--interval "); ok {
+			st.Interval, _, _ = strings.Cut(args, " ")
+		}
+	}
+
+	// The daemon schedules scans itself; report the service state instead
+	status, err := s.Query()
+	if err != nil {
+		return
+	}
+	switch status.State {
+	case svc.Running:
+		st.LastResult = "running"
+	case svc.Stopped:
+		st.LastResult = fmt.Sprintf("stopped (exit code %d)", status.Win32ExitCode)
+	default:
+		st.LastResult = fmt.Sprintf("state %d", status.State)
+	}
+}
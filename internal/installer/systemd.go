@@ -0,0 +1,219 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package installer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"hist_scanner/internal/config"
+)
+
+const (
+	systemdUnitDir     = "/etc/systemd/system"
+	systemdServicePath = "/etc/systemd/system/hist_scanner.service"
+	systemdTimerPath   = "/etc/systemd/system/hist_scanner.timer"
+)
+
+const serviceTemplate = `[Unit]
+Description=Browser History Scanner
+After=network.target
+
+[Service]
+Type=oneshot
+ExecStart={{.BinaryPath}} run --config {{.ConfigPath}}{{if .Full}} --full{{end}}
+{{- if .User}}
+User={{.User}}
+{{- end}}
+{{- if .Hardening}}
+NoNewPrivileges=yes
+PrivateTmp=yes
+ProtectSystem=strict
+ProtectKernelTunables=yes
+ProtectKernelModules=yes
+ProtectControlGroups=yes
+StateDirectory=hist_scanner
+StateDirectoryMode=0700
+{{- range .WritablePaths}}
+ReadWritePaths=-{{.}}
+{{- end}}
+{{- end}}
+{{- range .Environment}}
+Environment={{.}}
+{{- end}}
+{{- if .CPUQuota}}
+CPUQuota={{.CPUQuota}}
+{{- end}}
+{{- if .MemoryMax}}
+MemoryMax={{.MemoryMax}}
+{{- end}}
+`
+
+const timerTemplate = `[Unit]
+Description=Run Browser History Scanner periodically{{if .Name}} ({{.Name}}){{end}}
+
+[Timer]
+OnBootSec=5min
+OnUnitActiveSec={{.Interval}}
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`
+
+var (
+	cpuQuotaRe  = regexp.MustCompile(`^[0-9]+%$`)
+	memoryMaxRe = regexp.MustCompile(`^([0-9]+[KMGT]?|[0-9]+%|infinity)$`)
+)
+
+// SystemdUnits renders the systemd service and timer units for one schedule.
+// Used by the Linux installer and by package generation. User-scope units
+// run under the user's systemd instance and carry no User= directive; the
+// filesystem sandbox is only applied to system units.
+func SystemdUnits(scope Scope, paths InstallPaths, opts Options, sched config.Schedule) (string, string, error) {
+	if opts.CPUQuota != "" && !cpuQuotaRe.MatchString(opts.CPUQuota) {
+		return "", "", fmt.Errorf("invalid CPU quota %q (expected a percentage, e.g. 50%%)", opts.CPUQuota)
+	}
+	if opts.MemoryMax != "" && !memoryMaxRe.MatchString(opts.MemoryMax) {
+		return "", "", fmt.Errorf("invalid memory limit %q (expected bytes with optional K/M/G/T suffix, e.g. 512M)", opts.MemoryMax)
+	}
+
+	// Default user to root
+	runAsUser := opts.RunAsUser
+	if scope == ScopeUser {
+		runAsUser = ""
+	} else if runAsUser == "" {
+		runAsUser = "root"
+	}
+
+	serviceData := struct {
+		BinaryPath    string
+		ConfigPath    string
+		User          string
+		Hardening     bool
+		WritablePaths []string
+		CPUQuota      string
+		MemoryMax     string
+		Environment   []string
+		Full          bool
+	}{
+		BinaryPath:    paths.BinaryPath,
+		ConfigPath:    paths.ConfigPath,
+		User:          runAsUser,
+		Hardening:     opts.Hardening && scope == ScopeSystem,
+		WritablePaths: opts.WritablePaths,
+		CPUQuota:      opts.CPUQuota,
+		MemoryMax:     opts.MemoryMax,
+		Environment:   systemdQuoteAll(opts.Environment),
+		Full:          sched.Full,
+	}
+
+	service, err := renderTemplate("service", serviceTemplate, serviceData)
+	if err != nil {
+		return "", "", err
+	}
+
+	timerData := struct {
+		Name     string
+		Interval string
+	}{
+		Name:     sched.Name,
+		Interval: formatDuration(sched.Interval),
+	}
+
+	timer, err := renderTemplate("timer", timerTemplate, timerData)
+	if err != nil {
+		return "", "", err
+	}
+
+	return service, timer, nil
+}
+
+// systemdStatus fills the interval from the timer unit file and the last run
+// from the service's runtime properties
+func systemdStatus(st *Status, timerPath string, user bool) {
+	st.Mode = ModeSystemd
+	if data, err := os.ReadFile(timerPath); err == nil {
+		st.Interval = unitValue(string(data), "OnUnitActiveSec")
+	}
+
+	args := []string{"show", "hist_scanner.service", "--property=ExecMainStartTimestamp,Result,ExecMainStatus"}
+	if user {
+		args = append([]string{"--user"}, args...)
+	}
+	output, err := exec.Command("systemctl", args...).Output()
+	if err != nil {
+		return
+	}
+
+	props := make(map[string]string)
+	for _, line := range strings.Split(string(output), "\n") {
+		if key, value, ok := strings.Cut(line, "="); ok {
+			props[key] = strings.TrimSpace(value)
+		}
+	}
+
+	// Never-started units report an empty timestamp
+	if ts := props["ExecMainStartTimestamp"]; ts != "" && ts != "n/a" {
+		st.LastRun = ts
+		st.LastResult = fmt.Sprintf("%s (exit status %s)", props["Result"], props["ExecMainStatus"])
+	}
+}
+
+// systemdQuoteAll quotes "KEY=VALUE" pairs for Environment= lines
+func systemdQuoteAll(env []string) []string {
+	quoted := make([]string, len(env))
+	for i, kv := range env {
+		kv = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%").Replace(kv)
+		quoted[i] = `"` + kv + `"`
+	}
+	return quoted
+}
+
+// unitValue returns the value of the first "key=value" line in a unit file
+func unitValue(content, key string) string {
+	for _, line := range strings.Split(content, "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), key+"="); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// renderTemplate executes a text template into a string
+func renderTemplate(name, text string, data interface{}) (string, error) {
+	tmpl, err := template.New(name).Funcs(template.FuncMap{"xml": xmlEscape}).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s template: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", name, err)
+	}
+
+	return buf.String(), nil
+}
+
+// formatDuration formats a duration for systemd (e.g., "24h" -> "1d", "6h" -> "6h")
+func formatDuration(d time.Duration) string {
+	hours := int(d.Hours())
+	if hours >= 24 && hours%24 == 0 {
+		return fmt.Sprintf("%dd", hours/24)
+	}
+	if hours > 0 {
+		return fmt.Sprintf("%dh", hours)
+	}
+	minutes := int(d.Minutes())
+	if minutes > 0 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%ds", int(d.Seconds()))
+}
@@ -0,0 +1,217 @@
+//go:build windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package installer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/sys/windows"
+
+	"hist_scanner/internal/config"
+)
+
+// Well-known SIDs used as task principals
+const (
+	systemSID = "S-1-5-18"     // LocalSystem
+	usersSID  = "S-1-5-32-545" // BUILTIN\Users
+)
+
+const taskTemplate = `<?xml version="1.0" encoding="UTF-16"?>
+<Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">
+  <RegistrationInfo>
+    <Author>Binadox</Author>
+    <Description>Scans browser history for security audit (hist_scanner)</Description>
+    <URI>\{{xml .TaskName}}</URI>
+  </RegistrationInfo>
+  <Triggers>
+{{- if .LogonTrigger}}
+    <LogonTrigger>
+      <Enabled>true</Enabled>
+      <Delay>PT5M</Delay>
+    </LogonTrigger>
+{{- end}}
+{{- if .DaysInterval}}
+    <CalendarTrigger>
+      <StartBoundary>{{.StartBoundary}}</StartBoundary>
+      <Enabled>true</Enabled>
+      <ScheduleByDay>
+        <DaysInterval>{{.DaysInterval}}</DaysInterval>
+      </ScheduleByDay>
+{{- if .RandomDelay}}
+      <RandomDelay>{{.RandomDelay}}</RandomDelay>
+{{- end}}
+    </CalendarTrigger>
+{{- else}}
+    <TimeTrigger>
+      <StartBoundary>{{.StartBoundary}}</StartBoundary>
+      <Enabled>true</Enabled>
+      <Repetition>
+        <Interval>{{.Repetition}}</Interval>
+        <StopAtDurationEnd>false</StopAtDurationEnd>
+      </Repetition>
+{{- if .RandomDelay}}
+      <RandomDelay>{{.RandomDelay}}</RandomDelay>
+{{- end}}
+    </TimeTrigger>
+{{- end}}
+  </Triggers>
+  <Principals>
+    <Principal id="Author">
+{{- if .GroupID}}
+      <GroupId>{{.GroupID}}</GroupId>
+      <RunLevel>LeastPrivilege</RunLevel>
+{{- else}}
+      <UserId>{{xml .UserID}}</UserId>
+      <LogonType>{{.LogonType}}</LogonType>
+      <RunLevel>HighestAvailable</RunLevel>
+{{- end}}
+    </Principal>
+  </Principals>
+  <Settings>
+    <MultipleInstancesPolicy>IgnoreNew</MultipleInstancesPolicy>
+    <DisallowStartIfOnBatteries>false</DisallowStartIfOnBatteries>
+    <StopIfGoingOnBatteries>false</StopIfGoingOnBatteries>
+    <StartWhenAvailable>{{.RunMissed}}</StartWhenAvailable>
+    <RunOnlyIfNetworkAvailable>{{.RequireNetwork}}</RunOnlyIfNetworkAvailable>
+    <WakeToRun>{{.WakeToRun}}</WakeToRun>
+    <ExecutionTimeLimit>PT2H</ExecutionTimeLimit>
+    <Enabled>true</Enabled>
+  </Settings>
+  <Actions Context="Author">
+    <Exec>
+      <Command>{{xml .BinaryPath}}</Command>
+      <Arguments>{{xml .Arguments}}</Arguments>
+    </Exec>
+  </Actions>
+</Task>
+`
+
+// taskData holds the values substituted into taskTemplate
+type taskData struct {
+	TaskName       string
+	StartBoundary  string
+	DaysInterval   int    // Set for intervals of one day or more (CalendarTrigger)
+	Repetition     string // ISO 8601 repetition interval for shorter intervals (TimeTrigger)
+	RandomDelay    string
+	UserID         string
+	LogonType      string
+	GroupID        string // Set to run in the session of each logged-on group member
+	LogonTrigger   bool
+	RunMissed      bool
+	RequireNetwork bool
+	WakeToRun      bool
+	BinaryPath     string
+	Arguments      string
+}
+
+// buildTaskXML renders the Task Scheduler XML definition of one schedule
+func buildTaskXML(name string, paths InstallPaths, opts Options, sched config.Schedule) (string, error) {
+	data := taskData{
+		TaskName:       name,
+		StartBoundary:  time.Now().Format("2006-01-02T15:04:05"),
+		UserID:         systemSID,
+		LogonType:      "ServiceAccount",
+		RunMissed:      opts.RunMissed,
+		RequireNetwork: opts.RequireNetwork,
+		WakeToRun:      opts.WakeToRun,
+		BinaryPath:     paths.BinaryPath,
+		Arguments:      fmt.Sprintf(`run --config "%s"`, paths.ConfigPath),
+	}
+
+	if sched.Full {
+		data.Arguments += " --full"
+	}
+
+	// Task definitions cannot carry environment variables; pass them as --env arguments
+	for _, kv := range opts.Environment {
+		data.Arguments += " --env " + windows.EscapeArg(kv)
+	}
+
+	// Run as whichever user is logged on (interactive token), starting shortly
+	// after logon as well as on the interval
+	if opts.RunAsCurrentUser {
+		data.GroupID = usersSID
+		data.LogonTrigger = true
+	} else if opts.RunAsUser != "" && !strings.EqualFold(opts.RunAsUser, "SYSTEM") {
+		// Non-SYSTEM accounts run via S4U: whether or not the user is logged on, no stored password
+		data.UserID = opts.RunAsUser
+		data.LogonType = "S4U"
+	}
+
+	// Whole days run on a calendar trigger; other intervals, e.g. 36h,
+	// repeat a time trigger, which allows at most 31 days
+	if sched.Interval >= 24*time.Hour && sched.Interval%(24*time.Hour) == 0 {
+		data.DaysInterval = int(sched.Interval / (24 * time.Hour))
+	} else if sched.Interval > 31*24*time.Hour {
+		return "", fmt.Errorf("interval %s is too long for Task Scheduler: use whole days or at most 744h", sched.Interval)
+	} else {
+		interval := sched.Interval
+		if interval < time.Minute {
+			interval = time.Minute
+		}
+		data.Repetition = isoDuration(interval)
+	}
+
+	if opts.RandomDelay > 0 {
+		data.RandomDelay = isoDuration(opts.RandomDelay)
+	}
+
+	tmpl, err := template.New("task").Funcs(template.FuncMap{"xml": xmlEscape}).Parse(taskTemplate)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse task template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render task definition: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// writeTaskXML writes a task definition to a temp file as UTF-16LE with BOM,
+// the encoding schtasks /xml expects. The caller removes the file.
+func writeTaskXML(definition string) (string, error) {
+	f, err := os.CreateTemp("", "hist_scanner_task_*.xml")
+	if err != nil {
+		return "", fmt.Errorf("failed to create task definition file: %w", err)
+	}
+	defer f.Close()
+
+	encoded := utf16.Encode([]rune(definition))
+	if err := binary.Write(f, binary.LittleEndian, append([]uint16{0xFEFF}, encoded...)); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write task definition: %w", err)
+	}
+
+	return f.Name(), nil
+}
+
+// isoDuration formats a duration as an ISO 8601 duration (e.g., "P1D", "PT1H30M")
+func isoDuration(d time.Duration) string {
+	if d >= 24*time.Hour && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("P%dD", int(d.Hours()/24))
+	}
+
+	out := "PT"
+	if h := int(d.Hours()); h > 0 {
+		out += fmt.Sprintf("%dH", h)
+	}
+	if m := int(d.Minutes()) % 60; m > 0 {
+		out += fmt.Sprintf("%dM", m)
+	}
+	if s := int(d.Seconds()) % 60; s > 0 || out == "PT" {
+		out += fmt.Sprintf("%dS", s)
+	}
+	return out
+}
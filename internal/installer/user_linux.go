@@ -0,0 +1,283 @@
+//go:build linux
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package installer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"hist_scanner/internal/config"
+)
+
+// crontabMarker tags the user crontab line managed by the installer
+const crontabMarker = "# hist_scanner"
+
+// userUnitDir returns the systemd --user unit directory
+func userUnitDir() string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, _ := os.UserHomeDir()
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "systemd", "user")
+}
+
+// installUser installs the scanner for the invoking user without root:
+// a systemd --user timer if a user manager is running, otherwise a crontab entry
+func installUser(r *runner, cfg *config.Config, opts Options) error {
+	if opts.RunAsUser != "" {
+		return fmt.Errorf("--user cannot be combined with user scope")
+	}
+
+	mode := opts.Mode
+	if mode == ModeDefault {
+		mode = ModeCron
+		if userSystemdAvailable() {
+			mode = ModeSystemd
+		}
+	}
+
+	switch mode {
+	case ModeSystemd, ModeCron:
+	default:
+		return fmt.Errorf("install mode %q is not supported for user scope (use %q or %q)", mode, ModeSystemd, ModeCron)
+	}
+
+	if mode == ModeCron {
+		if _, err := exec.LookPath("crontab"); err != nil {
+			return fmt.Errorf("no systemd user manager and crontab not found: %w", err)
+		}
+	}
+
+	paths := GetInstallPaths(ScopeUser)
+	if paths.BinaryPath == "" {
+		return fmt.Errorf("failed to determine home directory")
+	}
+
+	// Scan only the invoking user's profiles
+	userCfg := *cfg
+	userCfg.CurrentUserOnly = true
+
+	if err := r.mkdirAll(filepath.Dir(paths.BinaryPath), 0755); err != nil {
+		return err
+	}
+
+	// Copy binary
+	if err := r.copyBinary(paths.BinaryPath); err != nil {
+		return err
+	}
+
+	// Write config
+	if err := r.writeConfig(&userCfg, paths.ConfigPath); err != nil {
+		return err
+	}
+
+	// Only one scheduling mechanism may be active at a time
+	if mode == ModeCron {
+		if err := replaceSystemdUnits(r, userUnitDir(), []string{"systemctl", "--user"}); err != nil {
+			return err
+		}
+		return installUserCron(r, paths, opts)
+	}
+	if lines, err := readCrontab(); err == nil && hasScannerEntry(lines) {
+		err := r.register("remove crontab entry", "", func() error {
+			return writeCrontab(withoutScannerEntry(lines))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return installUserSystemd(r, paths, opts)
+}
+
+// installUserSystemd writes and enables systemd --user service and timer units
+func installUserSystemd(r *runner, paths InstallPaths, opts Options) error {
+	return installSystemdUnits(r, userUnitDir(), []string{"systemctl", "--user"}, ScopeUser, paths, opts)
+}
+
+// installUserCron adds (or replaces) the scanner entry in the user's crontab
+func installUserCron(r *runner, paths InstallPaths, opts Options) error {
+	lines, err := readCrontab()
+	if err != nil {
+		return err
+	}
+
+	// Environment lines would apply to the user's other entries; pass --env instead
+	var envArgs string
+	for _, kv := range opts.Environment {
+		envArgs += " --env " + strings.ReplaceAll(shellQuote(kv), "%", `\%`) // cron treats % as newline
+	}
+
+	scheds, err := opts.schedules()
+	if err != nil {
+		return err
+	}
+
+	// One line per schedule, all tagged with the marker
+	var entries []string
+	for _, sched := range scheds {
+		args := envArgs
+		if sched.Full {
+			args += " --full"
+		}
+		entries = append(entries, fmt.Sprintf("%s %s run --config %s%s >/dev/null 2>&1 %s",
+			cronSchedule(sched.Interval), paths.BinaryPath, paths.ConfigPath, args, crontabMarker))
+	}
+
+	return r.register("crontab entry", strings.Join(entries, "\n"), func() error {
+		return writeCrontab(append(withoutScannerEntry(lines), entries...))
+	})
+}
+
+// uninstallUser removes the user-scope timer, crontab entry and files
+func uninstallUser(opts UninstallOptions) ([]string, error) {
+	paths := GetInstallPaths(ScopeUser)
+	unitDir := userUnitDir()
+	r := &removal{}
+
+	removeSystemdUnits(r, unitDir, []string{"systemctl", "--user"})
+
+	if lines, err := readCrontab(); err == nil && hasScannerEntry(lines) {
+		if err := writeCrontab(withoutScannerEntry(lines)); err != nil {
+			return r.removed, err
+		}
+		r.add("crontab entry")
+	}
+
+	r.purgeData(paths.ConfigPath, opts)
+	r.removeMetadata(ScopeUser)
+	r.file(paths.BinaryPath)
+	r.file(paths.ConfigPath)
+	r.dir(filepath.Dir(paths.ConfigPath))
+
+	return r.removed, r.err()
+}
+
+// userInstalled checks for a user-scope timer or crontab entry
+func userInstalled() bool {
+	if fileExists(filepath.Join(userUnitDir(), "hist_scanner.timer")) {
+		return true
+	}
+	lines, err := readCrontab()
+	return err == nil && hasScannerEntry(lines)
+}
+
+// userStatus fills the status of a user-scope installation
+func userStatus(st *Status) {
+	timerPath := filepath.Join(userUnitDir(), "hist_scanner.timer")
+	if fileExists(timerPath) {
+		systemdStatus(st, timerPath, true)
+		return
+	}
+
+	if lines, err := readCrontab(); err == nil && hasScannerEntry(lines) {
+		st.Mode = ModeCron
+		st.Interval = cronEntrySchedule(lines)
+	}
+}
+
+// userSchedulerEntry returns the user-scope timer or crontab entry for Verify
+func userSchedulerEntry() *schedulerEntry {
+	unitDir := userUnitDir()
+	if fileExists(filepath.Join(unitDir, "hist_scanner.timer")) {
+		return systemdEntry(filepath.Join(unitDir, "hist_scanner.service"), []string{"systemctl", "--user", "daemon-reload"})
+	}
+
+	lines, err := readCrontab()
+	if err != nil {
+		return nil
+	}
+	for _, line := range lines {
+		// "<5 schedule fields> <binary> run --config ... # hist_scanner"
+		fields := strings.Fields(line)
+		if !strings.HasSuffix(line, crontabMarker) || len(fields) < 7 || fields[6] != "run" {
+			continue
+		}
+
+		binary := fields[5]
+		return &schedulerEntry{
+			name:   "crontab entry",
+			binary: binary,
+			repoint: func(binaryPath string) error {
+				// Every schedule has its own line
+				for i, line := range lines {
+					if strings.HasSuffix(line, crontabMarker) {
+						lines[i] = strings.Replace(line, " "+binary+" run ", " "+binaryPath+" run ", 1)
+					}
+				}
+				return writeCrontab(lines)
+			},
+		}
+	}
+	return nil
+}
+
+// userSystemdAvailable checks whether a systemd --user manager is reachable
+func userSystemdAvailable() bool {
+	if !fileExists("/run/systemd/system") {
+		return false
+	}
+	return exec.Command("systemctl", "--user", "show-environment").Run() == nil
+}
+
+// readCrontab returns the current user's crontab lines (empty if none)
+func readCrontab() ([]string, error) {
+	if _, err := exec.LookPath("crontab"); err != nil {
+		return nil, fmt.Errorf("crontab not found: %w", err)
+	}
+
+	output, err := exec.Command("crontab", "-l").Output()
+	if err != nil {
+		// "no crontab for user" exits non-zero; anything else is a real failure
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && strings.Contains(string(exitErr.Stderr), "no crontab") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read crontab: %w", err)
+	}
+
+	text := strings.TrimRight(string(output), "\n")
+	if text == "" {
+		return nil, nil
+	}
+	return strings.Split(text, "\n"), nil
+}
+
+// writeCrontab replaces the current user's crontab
+func writeCrontab(lines []string) error {
+	cmd := exec.Command("crontab", "-")
+	cmd.Stdin = bytes.NewBufferString(strings.Join(lines, "\n") + "\n")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to update crontab: %w\n%s", err, output)
+	}
+	return nil
+}
+
+// hasScannerEntry reports whether the crontab contains the scanner entry
+func hasScannerEntry(lines []string) bool {
+	for _, line := range lines {
+		if strings.HasSuffix(line, crontabMarker) {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutScannerEntry returns the crontab without the scanner entry
+func withoutScannerEntry(lines []string) []string {
+	var kept []string
+	for _, line := range lines {
+		if !strings.HasSuffix(line, crontabMarker) {
+			kept = append(kept, line)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,212 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package installer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Check is the result of one installation integrity check
+type Check struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Fixed  bool   `json:"fixed,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// schedulerEntry is the installed scheduler entry as seen by Verify
+type schedulerEntry struct {
+	name    string                        // Human-readable location of the entry
+	binary  string                        // Executable the entry runs
+	repoint func(binaryPath string) error // Rewrites the entry to run binaryPath
+}
+
+// verifyInstall checks the installed binary, config file and scheduler entry,
+// repairing drift when fix is set. entry is nil if no scheduler entry exists.
+func verifyInstall(scope Scope, fix bool, entry *schedulerEntry) []Check {
+	paths := GetInstallPaths(scope)
+	return []Check{
+		verifyBinary(paths.BinaryPath, fix),
+		verifyIntegrity(scope, paths.BinaryPath, fix),
+		verifyConfig(paths.ConfigPath, fix),
+		verifyScheduler(paths.BinaryPath, fix, entry),
+	}
+}
+
+// verifyBinary compares the installed binary with the running one
+func verifyBinary(binaryPath string, fix bool) Check {
+	c := Check{Name: "binary"}
+
+	running, err := runningBinary()
+	if err != nil {
+		c.Detail = err.Error()
+		return c
+	}
+
+	runningHash, err := fileHash(running)
+	if err != nil {
+		c.Detail = err.Error()
+		return c
+	}
+
+	installedHash, err := fileHash(binaryPath)
+	if err == nil && bytes.Equal(installedHash, runningHash) {
+		c.OK = true
+		c.Detail = fmt.Sprintf("%s matches the running binary (sha256 %x)", binaryPath, runningHash)
+		return c
+	}
+
+	if err != nil {
+		c.Detail = err.Error()
+	} else {
+		c.Detail = fmt.Sprintf("%s differs from the running binary (sha256 %x, expected %x)", binaryPath, installedHash, runningHash)
+	}
+
+	if fix {
+		if err := CopyBinary(binaryPath); err != nil {
+			c.Detail += fmt.Sprintf("; fix failed: %v", err)
+			return c
+		}
+		c.OK, c.Fixed = true, true
+		c.Detail += "; replaced with the running binary"
+	}
+	return c
+}
+
+// verifyConfig checks that the config file exists and is private (it holds the API key)
+func verifyConfig(configPath string, fix bool) Check {
+	c := Check{Name: "config"}
+
+	info, err := os.Stat(configPath)
+	if err != nil {
+		c.Detail = fmt.Sprintf("%s: %v", configPath, err)
+		return c
+	}
+
+	// Windows ACLs are not represented in the file mode
+	if runtime.GOOS == "windows" || info.Mode().Perm() == 0600 {
+		c.OK = true
+		c.Detail = fmt.Sprintf("%s (mode %04o)", configPath, info.Mode().Perm())
+		return c
+	}
+
+	c.Detail = fmt.Sprintf("%s has mode %04o, expected 0600", configPath, info.Mode().Perm())
+	if fix {
+		if err := os.Chmod(configPath, 0600); err != nil {
+			c.Detail += fmt.Sprintf("; fix failed: %v", err)
+			return c
+		}
+		c.OK, c.Fixed = true, true
+		c.Detail += "; permissions reset"
+	}
+	return c
+}
+
+// verifyScheduler checks that the scheduler entry runs the installed binary
+func verifyScheduler(binaryPath string, fix bool, entry *schedulerEntry) Check {
+	c := Check{Name: "scheduler"}
+
+	if entry == nil {
+		c.Detail = "no scheduler entry found (run install)"
+		return c
+	}
+
+	if samePath(entry.binary, binaryPath) {
+		c.OK = true
+		c.Detail = fmt.Sprintf("%s runs %s", entry.name, entry.binary)
+		return c
+	}
+
+	c.Detail = fmt.Sprintf("%s runs %q, expected %s", entry.name, entry.binary, binaryPath)
+	if fix {
+		if err := entry.repoint(binaryPath); err != nil {
+			c.Detail += fmt.Sprintf("; fix failed: %v", err)
+			return c
+		}
+		c.OK, c.Fixed = true, true
+		c.Detail += "; entry updated"
+	}
+	return c
+}
+
+// fileHash returns the SHA-256 digest of a file
+func fileHash(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return h.Sum(nil), nil
+}
+
+// samePath compares executable paths (case-insensitively on Windows)
+func samePath(a, b string) bool {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// fileEntry describes a scheduler entry stored in text files (the first one
+// belongs to the primary schedule), repointed by replacing oldText with
+// newText in each file and then running the reload commands
+func fileEntry(name string, files []string, binary string, replace func(binaryPath string) (oldText, newText string), reload ...[]string) *schedulerEntry {
+	return &schedulerEntry{
+		name:   name,
+		binary: binary,
+		repoint: func(binaryPath string) error {
+			oldText, newText := replace(binaryPath)
+			for _, path := range files {
+				if err := replaceInFile(path, oldText, newText); err != nil {
+					return err
+				}
+			}
+
+			for _, args := range reload {
+				if output, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+					return fmt.Errorf("failed to run %v: %w\n%s", args, err, output)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// replaceInFile replaces every occurrence of oldText, keeping the file mode
+func replaceInFile(path, oldText, newText string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if !strings.Contains(string(data), oldText) {
+		return fmt.Errorf("entry in %s has an unexpected format", path)
+	}
+
+	// Files holding several schedules (cron.d) repeat the path
+	content := strings.ReplaceAll(string(data), oldText, newText)
+	if err := os.WriteFile(path, []byte(content), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
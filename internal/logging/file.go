@@ -0,0 +1,108 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatedLayout is the time suffix of rotated log files
+const rotatedLayout = "20060102-150405"
+
+// File is a log file that can be rotated while it is written to
+type File struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File // nil if reopening after a rotation failed
+}
+
+// OpenFile opens a log file for appending, creating it if needed
+func OpenFile(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &File{path: path, f: f}, nil
+}
+
+// Write appends to the current log file
+func (l *File) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return 0, os.ErrClosed
+	}
+	return l.f.Write(p)
+}
+
+// Close closes the log file; later writes fail
+func (l *File) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// Path returns the log file path
+func (l *File) Path() string {
+	return l.path
+}
+
+// RotatedFiles returns the rotated copies of a log file
+func RotatedFiles(path string) []string {
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil
+	}
+	prefix := filepath.Base(path) + "."
+	var files []string
+	for _, e := range entries {
+		suffix, ok := strings.CutPrefix(e.Name(), prefix)
+		if _, err := time.Parse(rotatedLayout, suffix); ok && err == nil && e.Type().IsRegular() {
+			files = append(files, filepath.Join(filepath.Dir(path), e.Name()))
+		}
+	}
+	return files
+}
+
+// Rotate renames the log file to path.YYYYMMDD-HHMMSS and starts a new one
+// if it has grown past maxSize bytes. It returns the rotated file, or "" if
+// the file was not rotated.
+func (l *File) Rotate(maxSize int64) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.f == nil {
+		return "", os.ErrClosed
+	}
+	info, err := l.f.Stat()
+	if err != nil || info.Size() <= maxSize {
+		return "", err
+	}
+
+	// Windows does not rename open files
+	if err := l.f.Close(); err != nil {
+		return "", fmt.Errorf("failed to close log file: %w", err)
+	}
+	rotated := l.path + "." + time.Now().Format(rotatedLayout)
+	renameErr := os.Rename(l.path, rotated)
+
+	l.f, err = os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to reopen log file: %w", err)
+	}
+	if renameErr != nil {
+		return "", fmt.Errorf("failed to rotate log file: %w", renameErr)
+	}
+	return rotated, nil
+}
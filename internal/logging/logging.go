@@ -0,0 +1,98 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package logging provides the scanner's leveled logger. Records are written
+// as text lines in the classic "[hist_scanner] date time message" layout or
+// as JSON objects for log pipelines, which also carry the context attributes
+// (scan id, user, browser, profile) added with With.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Levels lists the accepted log levels, most verbose first
+var Levels = []string{"debug", "info", "warn", "error"}
+
+// Formats lists the accepted log formats
+var Formats = []string{"text", "json"}
+
+// Logger is a leveled logger with printf-style helpers
+type Logger struct {
+	*slog.Logger
+}
+
+// ParseLevel converts a level name to a slog level
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("invalid log level %q (use %s)", s, strings.Join(Levels, ", "))
+}
+
+// New returns a logger writing records at or above level to w in format
+// ("text" or "json")
+func New(w io.Writer, level, format string) (*Logger, error) {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "", "text":
+		h = newTextHandler(w, lvl)
+	case "json":
+		h = slog.NewJSONHandler(w, &slog.HandlerOptions{Level: lvl})
+	default:
+		return nil, fmt.Errorf("invalid log format %q (use %s)", format, strings.Join(Formats, ", "))
+	}
+	return &Logger{slog.New(h)}, nil
+}
+
+// With returns a logger that adds attributes to every record
+func (l *Logger) With(args ...any) *Logger {
+	return &Logger{l.Logger.With(args...)}
+}
+
+// Debugf logs a formatted message at debug level
+func (l *Logger) Debugf(format string, args ...any) {
+	l.logf(slog.LevelDebug, format, args)
+}
+
+// Infof logs a formatted message at info level
+func (l *Logger) Infof(format string, args ...any) {
+	l.logf(slog.LevelInfo, format, args)
+}
+
+// Warnf logs a formatted message at warn level
+func (l *Logger) Warnf(format string, args ...any) {
+	l.logf(slog.LevelWarn, format, args)
+}
+
+// Errorf logs a formatted message at error level
+func (l *Logger) Errorf(format string, args ...any) {
+	l.logf(slog.LevelError, format, args)
+}
+
+// logf formats and logs a message unless its level is disabled
+func (l *Logger) logf(level slog.Level, format string, args []any) {
+	ctx := context.Background()
+	if !l.Enabled(ctx, level) {
+		return
+	}
+	r := slog.NewRecord(time.Now(), level, fmt.Sprintf(format, args...), 0)
+	_ = l.Handler().Handle(ctx, r)
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// System log targets accepted as log_file values
+const (
+	TargetSyslog   = "SYSLOG"   // Local syslog (Linux, macOS, BSD)
+	TargetEventLog = "EVENTLOG" // Windows Event Log
+)
+
+// EventSource is the syslog tag and Windows Event Log source name
+const EventSource = "hist_scanner"
+
+// systemWriter sends messages to a system log at a given severity
+type systemWriter interface {
+	Debug(msg string) error
+	Info(msg string) error
+	Warning(msg string) error
+	Error(msg string) error
+	Close() error
+}
+
+// IsSystemTarget reports whether a log_file value names a system log
+// rather than a file
+func IsSystemTarget(target string) bool {
+	return strings.EqualFold(target, TargetSyslog) || strings.EqualFold(target, TargetEventLog)
+}
+
+// ValidateTarget checks that a system log target is supported on this platform
+func ValidateTarget(target string) error {
+	if !slices.ContainsFunc(systemTargets, func(t string) bool { return strings.EqualFold(t, target) }) {
+		return fmt.Errorf("log target %s is not supported on this platform", strings.ToUpper(target))
+	}
+	return nil
+}
+
+// NewSystem returns a logger writing records at or above level to a system
+// log target (SYSLOG or EVENTLOG). The system log adds its own timestamp and
+// severity, so text records hold only the message and attributes.
+func NewSystem(target, level, format string) (*Logger, error) {
+	if err := ValidateTarget(target); err != nil {
+		return nil, err
+	}
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := openSystemLog(strings.ToUpper(target))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", strings.ToUpper(target), err)
+	}
+
+	out := &systemOutput{w: w}
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "", "text":
+		th := newTextHandler(out, lvl)
+		th.bare = true
+		h = th
+	case "json":
+		h = slog.NewJSONHandler(out, &slog.HandlerOptions{Level: lvl})
+	default:
+		w.Close()
+		return nil, fmt.Errorf("invalid log format %q (use %s)", format, strings.Join(Formats, ", "))
+	}
+	return &Logger{slog.New(&systemHandler{Handler: h, out: out})}, nil
+}
+
+// systemHandler formats records with a text or JSON handler and passes each
+// one to the system log at the record's severity
+type systemHandler struct {
+	slog.Handler
+	out *systemOutput
+}
+
+func (h *systemHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.out.mu.Lock()
+	defer h.out.mu.Unlock()
+	h.out.level = r.Level
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *systemHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &systemHandler{Handler: h.Handler.WithAttrs(attrs), out: h.out}
+}
+
+func (h *systemHandler) WithGroup(name string) slog.Handler {
+	return &systemHandler{Handler: h.Handler.WithGroup(name), out: h.out}
+}
+
+// systemOutput receives one formatted record per Write and sends it to the
+// system log at the level set by systemHandler.Handle
+type systemOutput struct {
+	mu    sync.Mutex
+	w     systemWriter
+	level slog.Level
+}
+
+func (o *systemOutput) Write(p []byte) (int, error) {
+	msg := string(bytes.TrimRight(p, "\n"))
+	var err error
+	switch {
+	case o.level >= slog.LevelError:
+		err = o.w.Error(msg)
+	case o.level >= slog.LevelWarn:
+		err = o.w.Warning(msg)
+	case o.level >= slog.LevelInfo:
+		err = o.w.Info(msg)
+	default:
+		err = o.w.Debug(msg)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package logging
+
+import "log/syslog"
+
+// systemTargets lists the system log targets supported on this platform
+var systemTargets = []string{TargetSyslog}
+
+// openSystemLog connects to the local syslog daemon with the daemon facility
+func openSystemLog(target string) (systemWriter, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, EventSource)
+	if err != nil {
+		return nil, err
+	}
+	return syslogWriter{w}, nil
+}
+
+// syslogWriter adapts syslog.Writer to systemWriter
+type syslogWriter struct {
+	*syslog.Writer
+}
+
+func (w syslogWriter) Error(msg string) error {
+	return w.Err(msg)
+}
@@ -0,0 +1,60 @@
+//go:build windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package logging
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// systemTargets lists the system log targets supported on this platform
+var systemTargets = []string{TargetEventLog}
+
+// Event ids of the scanner's Event Log entries, one per severity
+const (
+	eventIDInfo    = 1
+	eventIDWarning = 2
+	eventIDError   = 3
+)
+
+// openSystemLog opens the Event Log with the scanner's event source, which
+// install registers (see InstallEventSource)
+func openSystemLog(target string) (systemWriter, error) {
+	l, err := eventlog.Open(EventSource)
+	if err != nil {
+		return nil, err
+	}
+	return eventLogWriter{l}, nil
+}
+
+// eventLogWriter adapts eventlog.Log to systemWriter. The Event Log has no
+// debug severity, so debug records are written as information.
+type eventLogWriter struct {
+	l *eventlog.Log
+}
+
+func (w eventLogWriter) Debug(msg string) error   { return w.l.Info(eventIDInfo, msg) }
+func (w eventLogWriter) Info(msg string) error    { return w.l.Info(eventIDInfo, msg) }
+func (w eventLogWriter) Warning(msg string) error { return w.l.Warning(eventIDWarning, msg) }
+func (w eventLogWriter) Error(msg string) error   { return w.l.Error(eventIDError, msg) }
+func (w eventLogWriter) Close() error             { return w.l.Close() }
+
+// InstallEventSource registers the scanner's event source in the Application
+// log, replacing an existing registration. Messages use the generic
+// EventCreate.exe message file, so they show as written.
+func InstallEventSource() error {
+	_ = eventlog.Remove(EventSource)
+	if err := eventlog.InstallAsEventCreate(EventSource, eventlog.Info|eventlog.Warning|eventlog.Error); err != nil {
+		return fmt.Errorf("failed to register event source: %w", err)
+	}
+	return nil
+}
+
+// RemoveEventSource removes the scanner's event source registration
+func RemoveEventSource() error {
+	return eventlog.Remove(EventSource)
+}
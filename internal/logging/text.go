@@ -0,0 +1,105 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// textPrefix starts every text log line
+const textPrefix = "[hist_scanner] "
+
+// textHandler writes records as "[hist_scanner] 2006/01/02 15:04:05 message",
+// with the message prefixed by "Debug: ", "Warning: " or "Error: " below or
+// above info level and record attributes appended as key=value. Context
+// attributes from WithAttrs are left out to keep lines short; the messages
+// already name the user and profile they are about. Bare handlers, used for
+// system logs, leave out the prefix, timestamp and level.
+type textHandler struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	level  slog.Level
+	prefix string // Group prefix for attribute keys from WithGroup
+	bare   bool
+}
+
+// newTextHandler creates a text handler for records at or above level
+func newTextHandler(w io.Writer, level slog.Level) *textHandler {
+	return &textHandler{mu: &sync.Mutex{}, w: w, level: level}
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	if !h.bare {
+		b.WriteString(textPrefix)
+		b.WriteString(r.Time.Format("2006/01/02 15:04:05 "))
+		switch {
+		case r.Level >= slog.LevelError:
+			b.WriteString("Error: ")
+		case r.Level >= slog.LevelWarn:
+			b.WriteString("Warning: ")
+		case r.Level < slog.LevelInfo:
+			b.WriteString("Debug: ")
+		}
+	}
+	b.WriteString(r.Message)
+	r.Attrs(func(a slog.Attr) bool {
+		appendAttr(&b, h.prefix, a)
+		return true
+	})
+	b.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h
+}
+
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix += name + "."
+	return &h2
+}
+
+// appendAttr writes " key=value", quoting values that contain spaces
+func appendAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		p := prefix
+		if a.Key != "" {
+			p += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			appendAttr(b, p, ga)
+		}
+		return
+	}
+	b.WriteByte(' ')
+	b.WriteString(prefix + a.Key)
+	b.WriteByte('=')
+	v := a.Value.String()
+	if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+		v = strconv.Quote(v)
+	}
+	b.WriteString(v)
+}
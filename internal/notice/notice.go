@@ -0,0 +1,165 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package notice writes the disclosure that tells users their browsing
+// history is audited: a local HTML page and, where the OS has one, a login
+// message file.
+package notice
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Notice methods accepted in the notice config key
+const (
+	MethodPage  = "page"  // HTML disclosure page
+	MethodLogin = "login" // Message shown at login
+)
+
+// Methods lists the notice methods
+var Methods = []string{MethodPage, MethodLogin}
+
+// DefaultText is the disclosure used when notice_text is empty
+const DefaultText = "This computer is managed by your organization. The browsing history of its users " +
+	"(visited web addresses and visit times) is collected and sent to your organization to audit the use " +
+	"of cloud services. Contact your IT department for details."
+
+// File is a notice file with its content
+type File struct {
+	Method string
+	Path   string
+	Data   []byte
+}
+
+// Text returns the disclosure text, the built-in one if text is empty
+func Text(text string) string {
+	if strings.TrimSpace(text) == "" {
+		return DefaultText
+	}
+	return strings.TrimSpace(text)
+}
+
+// Digest identifies a disclosure text, so a changed text is disclosed again
+func Digest(text string) string {
+	sum := sha256.Sum256([]byte(Text(text)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Validate checks that methods are known and supported on this platform
+func Validate(methods []string) error {
+	for _, m := range methods {
+		if !slices.Contains(Methods, m) {
+			return fmt.Errorf("unknown notice method %q (use %s)", m, strings.Join(Methods, ", "))
+		}
+		if m == MethodLogin && loginPath == "" {
+			return fmt.Errorf("notice method %q is not supported on this platform", m)
+		}
+	}
+	return nil
+}
+
+// Files returns the notice files of methods for a disclosure text
+func Files(methods []string, text string) ([]File, error) {
+	text = Text(text)
+	var files []File
+	for _, m := range methods {
+		switch m {
+		case MethodPage:
+			page, err := renderPage(text)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, File{Method: m, Path: pagePath, Data: page})
+		case MethodLogin:
+			msg := text + "\n"
+			if slices.Contains(methods, MethodPage) {
+				msg += "Details: " + pagePath + "\n"
+			}
+			files = append(files, File{Method: m, Path: loginPath, Data: []byte(msg)})
+		}
+	}
+	return files, nil
+}
+
+// Write writes the notice files that are missing or out of date and returns
+// the paths written
+func Write(methods []string, text string) ([]string, error) {
+	if err := Validate(methods); err != nil {
+		return nil, err
+	}
+	files, err := Files(methods, text)
+	if err != nil {
+		return nil, err
+	}
+
+	var written []string
+	for _, f := range files {
+		if current, err := os.ReadFile(f.Path); err == nil && bytes.Equal(current, f.Data) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(f.Path), 0755); err != nil {
+			return written, fmt.Errorf("failed to create notice directory: %w", err)
+		}
+		// Users must be able to read the notice
+		if err := os.WriteFile(f.Path, f.Data, 0644); err != nil {
+			return written, fmt.Errorf("failed to write notice: %w", err)
+		}
+		if err := os.Chmod(f.Path, 0644); err != nil {
+			return written, fmt.Errorf("failed to write notice: %w", err)
+		}
+		written = append(written, f.Path)
+	}
+	return written, nil
+}
+
+// Paths returns the paths of all notice files on this platform
+func Paths() []string {
+	paths := []string{pagePath}
+	if loginPath != "" {
+		paths = append(paths, loginPath)
+	}
+	return paths
+}
+
+// PageDir returns the directory of the disclosure page, owned by the scanner
+func PageDir() string {
+	return filepath.Dir(pagePath)
+}
+
+// pageTemplate is the HTML disclosure page
+var pageTemplate = template.Must(template.New("notice").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Browsing history audit notice</title>
+<style>body{font-family:sans-serif;max-width:40em;margin:3em auto;line-height:1.5}</style>
+</head>
+<body>
+<h1>Browsing history audit notice</h1>
+{{range .}}<p>{{.}}</p>
+{{end}}</body>
+</html>
+`))
+
+// renderPage renders the disclosure page, one paragraph per text paragraph
+func renderPage(text string) ([]byte, error) {
+	var paragraphs []string
+	for _, p := range strings.Split(text, "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+	}
+	var buf bytes.Buffer
+	if err := pageTemplate.Execute(&buf, paragraphs); err != nil {
+		return nil, fmt.Errorf("failed to render notice page: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,12 @@
+//go:build darwin
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package notice
+
+// Notice file paths; the login window text is left to the MDM profile
+const (
+	pagePath  = "/Library/Application Support/hist_scanner/notice.html"
+	loginPath = ""
+)
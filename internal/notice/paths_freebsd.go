@@ -0,0 +1,12 @@
+//go:build freebsd
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package notice
+
+// Notice file paths; FreeBSD has a single motd file, which is not changed
+const (
+	pagePath  = "/usr/local/share/hist_scanner/notice.html"
+	loginPath = ""
+)
@@ -0,0 +1,12 @@
+//go:build linux
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package notice
+
+// Notice file paths; pam_motd shows the files in /etc/motd.d at login
+const (
+	pagePath  = "/usr/local/share/hist_scanner/notice.html"
+	loginPath = "/etc/motd.d/hist_scanner"
+)
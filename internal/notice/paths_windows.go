@@ -0,0 +1,25 @@
+//go:build windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package notice
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Notice file paths; the logon legal notice is left to Group Policy
+var (
+	pagePath  = filepath.Join(programData(), "hist_scanner", "notice.html")
+	loginPath = ""
+)
+
+// programData returns the ProgramData directory
+func programData() string {
+	if dir := os.Getenv("ProgramData"); dir != "" {
+		return dir
+	}
+	return `C:\ProgramData`
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package oauth recognizes OAuth and OpenID Connect authorization URLs: a
+// visit to one means a user was asked to grant a third-party app access to
+// their account at an identity provider.
+package oauth
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Grant is an authorization request found in a visited URL
+type Grant struct {
+	Provider       string   // Identity provider, e.g. google, or the host of an unknown one
+	ClientID       string   // Id of the app access was requested for
+	RedirectURI    string   // Where the provider returns the user
+	RedirectDomain string   // Host of RedirectURI, "" if there is none
+	Scopes         []string // Requested scopes
+}
+
+// provider is a known identity provider and the paths of its authorization
+// endpoints
+type provider struct {
+	name  string
+	hosts []string
+	paths []string // Path prefixes; a path containing one after a tenant segment also matches
+}
+
+// providers are the identity providers recognized by host
+var providers = []provider{
+	{"google", []string{"accounts.google.com"}, []string{"/o/oauth2/", "/signin/oauth"}},
+	{"microsoft", []string{"login.microsoftonline.com", "login.microsoft.com", "login.windows.net"}, []string{"/oauth2/"}},
+	{"microsoft", []string{"login.live.com"}, []string{"/oauth20_authorize.srf"}},
+	{"github", []string{"github.com"}, []string{"/login/oauth/authorize"}},
+	{"slack", []string{"slack.com"}, []string{"/oauth/"}},
+	{"dropbox", []string{"www.dropbox.com", "dropbox.com"}, []string{"/oauth2/authorize"}},
+	{"atlassian", []string{"auth.atlassian.com"}, []string{"/authorize"}},
+	{"salesforce", []string{"login.salesforce.com"}, []string{"/services/oauth2/authorize"}},
+	{"zoom", []string{"zoom.us"}, []string{"/oauth/authorize"}},
+}
+
+// Detect returns the authorization request of a URL. Known providers match
+// by host and endpoint path; any other URL matches if it carries client_id,
+// response_type and redirect_uri, as every authorization request does.
+func Detect(rawURL string) (Grant, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return Grant{}, false
+	}
+	q := u.Query()
+	clientID := q.Get("client_id")
+	if clientID == "" {
+		return Grant{}, false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	name := ""
+	for _, p := range providers {
+		if matchProvider(p, host, u.Path) {
+			name = p.name
+			break
+		}
+	}
+	if name == "" {
+		if q.Get("response_type") == "" || q.Get("redirect_uri") == "" {
+			return Grant{}, false
+		}
+		name = host
+	}
+
+	g := Grant{Provider: name, ClientID: clientID, RedirectURI: q.Get("redirect_uri"), Scopes: scopes(q.Get("scope"))}
+	if r, err := url.Parse(g.RedirectURI); err == nil {
+		g.RedirectDomain = strings.ToLower(r.Hostname())
+	}
+	return g, true
+}
+
+// matchProvider reports whether a host and path are an authorization
+// endpoint of a provider. Microsoft puts the tenant first
+// (/common/oauth2/v2.0/authorize), so path prefixes also match after it.
+func matchProvider(p provider, host, path string) bool {
+	found := false
+	for _, h := range p.hosts {
+		found = found || host == h
+	}
+	if !found {
+		return false
+	}
+	for _, prefix := range p.paths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+		if _, rest, ok := strings.Cut(strings.TrimPrefix(path, "/"), "/"); ok && strings.HasPrefix("/"+rest, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// scopes splits a scope parameter: space-separated (RFC 6749), or
+// comma-separated as some providers use
+func scopes(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' })
+}
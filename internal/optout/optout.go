@@ -0,0 +1,121 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package optout signs and verifies per-user opt-out markers. An
+// administrator signs a marker for a user with the organization's private
+// key and places it in the user's home; the scanner honors it only if it
+// verifies against the public key in optout_public_key, so users cannot opt
+// themselves out.
+package optout
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MarkerFile is the name of the opt-out marker in a user's home
+const MarkerFile = ".hist_scanner_optout"
+
+// signedPrefix starts the signed data, so signatures of other documents
+// made with the same key are never valid markers
+const signedPrefix = "hist_scanner opt-out v1\n"
+
+// Marker is a signed opt-out marker
+type Marker struct {
+	User      string `json:"user"`
+	Issued    string `json:"issued"`            // Date, 2006-01-02
+	Expires   string `json:"expires,omitempty"` // Last valid date; empty never expires
+	Signature string `json:"signature"`         // Base64 Ed25519 signature
+}
+
+// signedData returns the data covered by the signature
+func (m *Marker) signedData() []byte {
+	return []byte(signedPrefix + strings.ToLower(m.User) + "\n" + m.Issued + "\n" + m.Expires + "\n")
+}
+
+// GenerateKey returns a new base64 public and private key pair
+func GenerateKey() (string, string, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	enc := base64.StdEncoding
+	return enc.EncodeToString(pub), enc.EncodeToString(priv), nil
+}
+
+// ParsePublicKey decodes a base64 public key
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid opt-out public key: want base64 of %d bytes", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// ParsePrivateKey decodes a base64 private key
+func ParsePrivateKey(s string) (ed25519.PrivateKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid opt-out private key: want base64 of %d bytes", ed25519.PrivateKeySize)
+	}
+	return ed25519.PrivateKey(key), nil
+}
+
+// Sign returns the marker file content opting user out from issued on, until
+// the end of expires (zero for no expiry)
+func Sign(key ed25519.PrivateKey, user string, issued, expires time.Time) ([]byte, error) {
+	m := Marker{User: user, Issued: issued.Format(time.DateOnly)}
+	if !expires.IsZero() {
+		m.Expires = expires.Format(time.DateOnly)
+	}
+	m.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, m.signedData()))
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal opt-out marker: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// Check reads the opt-out marker in home and verifies it for user. It
+// returns nil without error if there is no marker, and an error if a marker
+// exists but is not a valid opt-out of user at time now.
+func Check(key ed25519.PublicKey, home, user string, now time.Time) (*Marker, error) {
+	data, err := os.ReadFile(filepath.Join(home, MarkerFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read opt-out marker: %w", err)
+	}
+
+	var m Marker
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse opt-out marker: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil || !ed25519.Verify(key, m.signedData(), sig) {
+		return nil, fmt.Errorf("opt-out marker signature is not valid")
+	}
+	if !strings.EqualFold(m.User, user) {
+		return nil, fmt.Errorf("opt-out marker was issued for %s", m.User)
+	}
+	if m.Expires != "" {
+		expires, err := time.ParseInLocation(time.DateOnly, m.Expires, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid opt-out marker expiry %q", m.Expires)
+		}
+		if !now.Before(expires.AddDate(0, 0, 1)) {
+			return nil, fmt.Errorf("opt-out marker expired on %s", m.Expires)
+		}
+	}
+	return &m, nil
+}
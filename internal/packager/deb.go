@@ -0,0 +1,211 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package packager
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// debPackageName is the Debian package name (underscores are not allowed)
+const debPackageName = "hist-scanner"
+
+// debArch maps Go architectures to Debian architectures
+var debArch = map[string]string{
+	"amd64": "amd64",
+	"arm64": "arm64",
+	"386":   "i386",
+	"arm":   "armhf",
+}
+
+// buildDeb writes a .deb archive: an ar file holding debian-binary,
+// control.tar.gz and data.tar.gz
+func buildDeb(opts Options, files []file) (string, error) {
+	arch, ok := debArch[opts.Arch]
+	if !ok {
+		return "", fmt.Errorf("unsupported architecture for deb: %s", opts.Arch)
+	}
+	version := debVersion(opts.Version)
+	mtime := time.Now()
+
+	data, err := debDataTar(files, mtime)
+	if err != nil {
+		return "", err
+	}
+
+	control, err := debControlTar(opts, files, arch, version, mtime)
+	if err != nil {
+		return "", err
+	}
+
+	outPath := filepath.Join(opts.OutputDir, fmt.Sprintf("%s_%s_%s.deb", debPackageName, version, arch))
+	out, err := os.Create(outPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create package: %w", err)
+	}
+	defer out.Close()
+
+	// ar archive: global header followed by entries in this exact order
+	if _, err := out.WriteString("!<arch>\n"); err != nil {
+		return "", fmt.Errorf("failed to write package: %w", err)
+	}
+	entries := []struct {
+		name string
+		data []byte
+	}{
+		{"debian-binary", []byte("2.0\n")},
+		{"control.tar.gz", control},
+		{"data.tar.gz", data},
+	}
+	for _, e := range entries {
+		if err := writeArEntry(out, e.name, e.data, mtime); err != nil {
+			return "", fmt.Errorf("failed to write package: %w", err)
+		}
+	}
+
+	return outPath, nil
+}
+
+// debDataTar builds data.tar.gz with the installed files
+func debDataTar(files []file, mtime time.Time) ([]byte, error) {
+	var entries []tarEntry
+	for _, dir := range parentDirs(files) {
+		entries = append(entries, tarEntry{name: "." + dir + "/", mode: 0755, dir: true})
+	}
+	for _, f := range files {
+		entries = append(entries, tarEntry{name: "." + f.path, mode: f.mode, data: f.data})
+	}
+	return gzipTar(entries, mtime)
+}
+
+// debControlTar builds control.tar.gz with metadata and maintainer scripts
+func debControlTar(opts Options, files []file, arch, version string, mtime time.Time) ([]byte, error) {
+	var installedSize int64
+	var md5sums, conffiles strings.Builder
+	for _, f := range files {
+		installedSize += int64(len(f.data))
+		fmt.Fprintf(&md5sums, "%x  %s\n", md5.Sum(f.data), strings.TrimPrefix(f.path, "/"))
+		if f.config {
+			fmt.Fprintf(&conffiles, "%s\n", f.path)
+		}
+	}
+
+	maintainer := opts.Maintainer
+	if maintainer == "" {
+		maintainer = "Binadox"
+	}
+
+	control := fmt.Sprintf(`Package: %s
+Version: %s
+Architecture: %s
+Maintainer: %s
+Installed-Size: %d
+Section: admin
+Priority: optional
+Homepage: https://binadox.com
+Description: %s
+ %s
+`, debPackageName, version, arch, maintainer, (installedSize+1023)/1024, summary, description)
+
+	return gzipTar([]tarEntry{
+		{name: "./control", mode: 0644, data: []byte(control)},
+		{name: "./conffiles", mode: 0644, data: []byte(conffiles.String())},
+		{name: "./md5sums", mode: 0644, data: []byte(md5sums.String())},
+		{name: "./postinst", mode: 0755, data: []byte(postInstallScript)},
+		{name: "./prerm", mode: 0755, data: []byte(preRemoveScript)},
+		{name: "./postrm", mode: 0755, data: []byte(postRemoveScript)},
+	}, mtime)
+}
+
+// debVersion converts a version string into a valid Debian version
+// (must start with a digit; "dev" builds become "0.0.0~dev")
+func debVersion(v string) string {
+	if v == "" || v[0] < '0' || v[0] > '9' {
+		v = "0.0.0~" + v
+	}
+	return strings.TrimSuffix(sanitizeVersion(v, "+~.-"), "~")
+}
+
+// sanitizeVersion replaces characters outside [A-Za-z0-9] and allowed with "."
+func sanitizeVersion(v, allowed string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || strings.ContainsRune(allowed, r) {
+			return r
+		}
+		return '.'
+	}, v)
+}
+
+// tarEntry is a single file or directory in a tar archive
+type tarEntry struct {
+	name string
+	mode os.FileMode
+	data []byte
+	dir  bool
+}
+
+// gzipTar builds a gzip-compressed tar archive owned by root
+func gzipTar(entries []tarEntry, mtime time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name:    e.name,
+			Mode:    int64(e.mode),
+			ModTime: mtime,
+			Uname:   "root",
+			Gname:   "root",
+			Format:  tar.FormatGNU,
+		}
+		if e.dir {
+			hdr.Typeflag = tar.TypeDir
+		} else {
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = int64(len(e.data))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, fmt.Errorf("failed to write tar header: %w", err)
+		}
+		if !e.dir {
+			if _, err := tw.Write(e.data); err != nil {
+				return nil, fmt.Errorf("failed to write tar data: %w", err)
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close tar: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close gzip: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// writeArEntry writes one member of a common-format ar archive
+func writeArEntry(out *os.File, name string, data []byte, mtime time.Time) error {
+	header := fmt.Sprintf("%-16s%-12d%-6d%-6d%-8s%-10d`\n", name, mtime.Unix(), 0, 0, "100644", len(data))
+	if _, err := out.WriteString(header); err != nil {
+		return err
+	}
+	if _, err := out.Write(data); err != nil {
+		return err
+	}
+	// Members are 2-byte aligned
+	if len(data)%2 == 1 {
+		if _, err := out.Write([]byte{'\n'}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package packager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"hist_scanner/internal/config"
+	"hist_scanner/internal/installer"
+)
+
+// Package formats
+const (
+	FormatDeb = "deb"
+	FormatRPM = "rpm"
+)
+
+// Linux installation layout, identical to the installer's so that
+// "hist_scanner uninstall" and package-managed installs agree
+const (
+	binaryPath  = "/usr/local/bin/hist_scanner"
+	configPath  = "/etc/hist_scanner/config.yaml"
+	servicePath = "/etc/systemd/system/hist_scanner.service"
+	timerPath   = "/etc/systemd/system/hist_scanner.timer"
+)
+
+// Options controls package generation
+type Options struct {
+	Format     string        // FormatDeb or FormatRPM
+	Version    string        // Package version (a leading "v" is stripped)
+	Arch       string        // Go architecture (amd64, arm64); default: runtime.GOARCH
+	BinaryPath string        // Linux binary to package; default: the running executable
+	OutputDir  string        // Directory for the generated package
+	Interval   time.Duration // Scan interval written into the systemd timer
+	Maintainer string        // Package maintainer
+}
+
+// file is a single file to be placed in the package
+type file struct {
+	path   string // Absolute install path
+	mode   os.FileMode
+	data   []byte
+	config bool // Configuration file preserved on upgrade/removal
+}
+
+// defaultConfig is installed as a conffile; with empty values the scanner
+// falls back to auto-discovery until an admin fills it in
+const defaultConfig = `# hist_scanner configuration
+# See the README for all options.
+# Leave server_url/api_key empty to use auto-discovery (http://binadox.config:3000).
+server_url: ""
+api_key: ""
+initial_days: 7
+timeout: 30s
+chunk_size_kb: 1024
+compress: true
+`
+
+// postInstallScript enables the timer after installation or upgrade
+const postInstallScript = `#!/bin/sh
+set -e
+if [ -d /run/systemd/system ]; then
+	systemctl daemon-reload >/dev/null 2>&1 || true
+	systemctl enable hist_scanner.timer >/dev/null 2>&1 || true
+	systemctl restart hist_scanner.timer >/dev/null 2>&1 || true
+fi
+exit 0
+`
+
+// preRemoveScript stops the timer; $1 is "remove" (deb) or "0" (rpm) on removal,
+// anything else on upgrade
+const preRemoveScript = `#!/bin/sh
+set -e
+if [ "$1" = "remove" ] || [ "$1" = "0" ]; then
+	if [ -d /run/systemd/system ]; then
+		systemctl stop hist_scanner.timer >/dev/null 2>&1 || true
+		systemctl disable hist_scanner.timer >/dev/null 2>&1 || true
+	fi
+fi
+exit 0
+`
+
+// postRemoveScript reloads systemd after the units are gone
+const postRemoveScript = `#!/bin/sh
+set -e
+if [ -d /run/systemd/system ]; then
+	systemctl daemon-reload >/dev/null 2>&1 || true
+fi
+exit 0
+`
+
+// description is the package summary and long description
+const (
+	summary     = "Browser history scanner for security audit"
+	description = "Scans browser history from all users and profiles on the machine and sends it to a Binadox server for shadow IT audit. Runs periodically via a systemd timer."
+)
+
+// Build generates a native package and returns its path
+func Build(opts Options) (string, error) {
+	if opts.Arch == "" {
+		opts.Arch = runtime.GOARCH
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 24 * time.Hour
+	}
+	if opts.OutputDir == "" {
+		opts.OutputDir = "."
+	}
+	opts.Version = strings.TrimPrefix(opts.Version, "v")
+
+	if opts.BinaryPath == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			return "", fmt.Errorf("failed to get current executable: %w", err)
+		}
+		opts.BinaryPath = exe
+	}
+
+	files, err := packageFiles(opts)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(opts.OutputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	switch opts.Format {
+	case FormatDeb:
+		return buildDeb(opts, files)
+	case FormatRPM:
+		return buildRPM(opts, files)
+	default:
+		return "", fmt.Errorf("unsupported package format %q (use %q or %q)", opts.Format, FormatDeb, FormatRPM)
+	}
+}
+
+// packageFiles collects the binary, default config and systemd units
+func packageFiles(opts Options) ([]file, error) {
+	binary, err := os.ReadFile(opts.BinaryPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read binary: %w", err)
+	}
+
+	paths := installer.InstallPaths{BinaryPath: binaryPath, ConfigPath: configPath}
+	service, timer, err := installer.SystemdUnits(installer.ScopeSystem, paths, installer.Options{Hardening: true}, config.Schedule{Interval: opts.Interval})
+	if err != nil {
+		return nil, err
+	}
+
+	return []file{
+		{path: binaryPath, mode: 0755, data: binary},
+		{path: configPath, mode: 0600, data: []byte(defaultConfig), config: true},
+		{path: servicePath, mode: 0644, data: []byte(service)},
+		{path: timerPath, mode: 0644, data: []byte(timer)},
+	}, nil
+}
+
+// parentDirs returns the parent directories of the given files, outermost first
+func parentDirs(files []file) []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, f := range files {
+		var chain []string
+		for dir := filepath.ToSlash(filepath.Dir(f.path)); dir != "/" && !seen[dir]; dir = filepath.ToSlash(filepath.Dir(dir)) {
+			seen[dir] = true
+			chain = append([]string{dir}, chain...)
+		}
+		dirs = append(dirs, chain...)
+	}
+	return dirs
+}
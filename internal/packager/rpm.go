@@ -0,0 +1,152 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package packager
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// rpmPackageName is the RPM package name
+const rpmPackageName = "hist_scanner"
+
+// rpmArch maps Go architectures to RPM architectures
+var rpmArch = map[string]string{
+	"amd64": "x86_64",
+	"arm64": "aarch64",
+	"386":   "i686",
+	"arm":   "armv7hl",
+}
+
+// buildRPM stages the files in a temporary rpmbuild tree and runs rpmbuild -bb
+func buildRPM(opts Options, files []file) (string, error) {
+	arch, ok := rpmArch[opts.Arch]
+	if !ok {
+		return "", fmt.Errorf("unsupported architecture for rpm: %s", opts.Arch)
+	}
+
+	rpmbuild, err := exec.LookPath("rpmbuild")
+	if err != nil {
+		return "", fmt.Errorf("rpmbuild not found (install the rpm-build package): %w", err)
+	}
+
+	version := rpmVersion(opts.Version)
+
+	topDir, err := os.MkdirTemp("", "hist_scanner-rpm-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create build directory: %w", err)
+	}
+	defer os.RemoveAll(topDir)
+
+	// Stage files; the spec's %install copies them into the buildroot
+	stageDir := filepath.Join(topDir, "STAGE")
+	for _, f := range files {
+		dest := filepath.Join(stageDir, f.path)
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return "", fmt.Errorf("failed to stage %s: %w", f.path, err)
+		}
+		if err := os.WriteFile(dest, f.data, f.mode); err != nil {
+			return "", fmt.Errorf("failed to stage %s: %w", f.path, err)
+		}
+	}
+
+	specPath := filepath.Join(topDir, rpmPackageName+".spec")
+	if err := os.WriteFile(specPath, []byte(rpmSpec(opts, files, version, stageDir)), 0644); err != nil {
+		return "", fmt.Errorf("failed to write spec file: %w", err)
+	}
+
+	cmd := exec.Command(rpmbuild, "-bb",
+		"--define", "_topdir "+topDir,
+		"--target", arch,
+		specPath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to run rpmbuild: %w\n%s", err, output)
+	}
+
+	name := fmt.Sprintf("%s-%s-1.%s.rpm", rpmPackageName, version, arch)
+	built := filepath.Join(topDir, "RPMS", arch, name)
+	outPath := filepath.Join(opts.OutputDir, name)
+
+	data, err := os.ReadFile(built)
+	if err != nil {
+		return "", fmt.Errorf("failed to read built package: %w", err)
+	}
+	if err := os.WriteFile(outPath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write package: %w", err)
+	}
+
+	return outPath, nil
+}
+
+// rpmSpec generates the spec file for the staged files
+func rpmSpec(opts Options, files []file, version, stageDir string) string {
+	maintainer := opts.Maintainer
+	if maintainer == "" {
+		maintainer = "Binadox"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `%%define debug_package %%{nil}
+%%define __strip /bin/true
+%%define __os_install_post %%{nil}
+
+Name: %s
+Version: %s
+Release: 1
+Summary: %s
+License: Zlib
+URL: https://binadox.com
+Packager: %s
+AutoReqProv: no
+
+%%description
+%s
+
+%%install
+mkdir -p %%{buildroot}
+cp -a %s/. %%{buildroot}/
+
+%%post
+%s
+%%preun
+%s
+%%postun
+%s
+%%files
+`, rpmPackageName, version, summary, maintainer, description, stageDir,
+		scriptBody(postInstallScript), scriptBody(preRemoveScript), scriptBody(postRemoveScript))
+
+	for _, f := range files {
+		if f.config {
+			fmt.Fprintf(&b, "%%config(noreplace) %%attr(%04o,root,root) %s\n", f.mode, f.path)
+		} else {
+			fmt.Fprintf(&b, "%%attr(%04o,root,root) %s\n", f.mode, f.path)
+		}
+	}
+	// Own only the directory created for the scanner
+	fmt.Fprintf(&b, "%%dir %%attr(0755,root,root) %s\n", filepath.Dir(configPath))
+
+	return b.String()
+}
+
+// scriptBody strips the shebang from a maintainer script (rpm runs scriptlets with /bin/sh)
+func scriptBody(script string) string {
+	if strings.HasPrefix(script, "#!") {
+		if i := strings.Index(script, "\n"); i >= 0 {
+			return script[i+1:]
+		}
+	}
+	return script
+}
+
+// rpmVersion converts a version string into a valid RPM version ("-" is not allowed)
+func rpmVersion(v string) string {
+	if v == "" {
+		return "0.0.0"
+	}
+	return sanitizeVersion(v, "._+~")
+}
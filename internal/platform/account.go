@@ -0,0 +1,15 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+// AccountDisabled reports whether the user's account is disabled, locked or
+// expired. It returns a short description, or "" if the account is usable or
+// its status cannot be determined (e.g. directory accounts).
+// This is implemented per-platform in account_*.go files
+func AccountDisabled(u User) string {
+	if u.Username == "" || u.Layout != "" {
+		return ""
+	}
+	return accountDisabledImpl(u)
+}
@@ -0,0 +1,27 @@
+//go:build darwin
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// accountDisabledImpl checks for the DisabledUser tag that
+// "pwpolicy disableuser" and MDM add to AuthenticationAuthority
+func accountDisabledImpl(u User) string {
+	output, err := exec.Command("dscl", ".", "-read", "/Users/"+u.Username, "AuthenticationAuthority").Output()
+	if err != nil {
+		return ""
+	}
+
+	for _, authority := range parseDsclAttributes(string(output))["AuthenticationAuthority"] {
+		if strings.Contains(authority, ";DisabledUser;") {
+			return "account is disabled"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,102 @@
+//go:build linux || freebsd
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// shadowEntry is the lock state of one account from the shadow database
+type shadowEntry struct {
+	locked  bool
+	expires time.Time // Zero if the account does not expire
+}
+
+var (
+	shadowOnce    sync.Once
+	shadowEntries map[string]shadowEntry
+)
+
+// accountDisabledImpl checks the lock marker and expiry date of local
+// accounts in /etc/shadow (Linux) or /etc/master.passwd (FreeBSD). Both are
+// only readable by root; accounts not listed there are not checked.
+func accountDisabledImpl(u User) string {
+	shadowOnce.Do(func() {
+		if CurrentOS() == FreeBSD {
+			shadowEntries = readShadow("/etc/master.passwd", parseMasterPasswdLine)
+		} else {
+			shadowEntries = readShadow("/etc/shadow", parseShadowLine)
+		}
+	})
+
+	entry, ok := shadowEntries[u.Username]
+	if !ok {
+		return ""
+	}
+	if entry.locked {
+		return "account is locked"
+	}
+	if !entry.expires.IsZero() && entry.expires.Before(time.Now()) {
+		return "account expired on " + entry.expires.Format(time.DateOnly)
+	}
+	return ""
+}
+
+// readShadow reads a shadow database with the given line parser
+func readShadow(path string, parse func(fields []string) (string, shadowEntry, bool)) map[string]shadowEntry {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	entries := make(map[string]shadowEntry)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if name, entry, ok := parse(strings.Split(line, ":")); ok {
+			entries[name] = entry
+		}
+	}
+	return entries
+}
+
+// parseShadowLine parses shadow(5): name:password:lastchg:min:max:warn:inactive:expire:
+// A password hash prefixed with "!" is locked (passwd -l, usermod -L); a bare
+// "!" or "!!" only means no password was set (key or SSO logins). Expire is
+// in days since the epoch.
+func parseShadowLine(fields []string) (string, shadowEntry, bool) {
+	if len(fields) < 8 {
+		return "", shadowEntry{}, false
+	}
+	entry := shadowEntry{locked: strings.HasPrefix(fields[1], "!") && strings.TrimLeft(fields[1], "!") != ""}
+	if days, err := strconv.ParseInt(fields[7], 10, 64); err == nil && days > 0 {
+		entry.expires = time.Unix(days*86400, 0)
+	}
+	return fields[0], entry, true
+}
+
+// parseMasterPasswdLine parses master.passwd(5): name:password:uid:gid:class:change:expire:...
+// A password starting with "*LOCKED*" is locked (pw lock); expire is in
+// seconds since the epoch.
+func parseMasterPasswdLine(fields []string) (string, shadowEntry, bool) {
+	if len(fields) < 10 {
+		return "", shadowEntry{}, false
+	}
+	entry := shadowEntry{locked: strings.HasPrefix(fields[1], "*LOCKED*")}
+	if secs, err := strconv.ParseInt(fields[6], 10, 64); err == nil && secs > 0 {
+		entry.expires = time.Unix(secs, 0)
+	}
+	return fields[0], entry, true
+}
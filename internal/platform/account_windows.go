@@ -0,0 +1,70 @@
+//go:build windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// User account flags (lmaccess.h)
+const (
+	ufAccountDisable = 0x0002
+	ufLockout        = 0x0010
+)
+
+// userInfo1 mirrors USER_INFO_1
+type userInfo1 struct {
+	Name        *uint16
+	Password    *uint16
+	PasswordAge uint32
+	Priv        uint32
+	HomeDir     *uint16
+	Comment     *uint16
+	Flags       uint32
+	ScriptPath  *uint16
+}
+
+// accountDisabledImpl reads the flags of local accounts with NetUserGetInfo.
+// Domain and Entra ID accounts are managed by their directory and are not
+// checked.
+func accountDisabledImpl(u User) string {
+	if u.SID == "" {
+		return ""
+	}
+	sid, err := windows.StringToSid(u.SID)
+	if err != nil {
+		return ""
+	}
+	account, domain, _, err := sid.LookupAccount("")
+	if err != nil {
+		return ""
+	}
+	if computer, err := windows.ComputerName(); err != nil || !strings.EqualFold(domain, computer) {
+		return ""
+	}
+
+	name, err := windows.UTF16PtrFromString(account)
+	if err != nil {
+		return ""
+	}
+	var buf *byte
+	if err := windows.NetUserGetInfo(nil, name, 1, &buf); err != nil {
+		return ""
+	}
+	defer windows.NetApiBufferFree(buf)
+
+	info := (*userInfo1)(unsafe.Pointer(buf))
+	switch {
+	case info.Flags&ufAccountDisable != 0:
+		return "account is disabled"
+	case info.Flags&ufLockout != 0:
+		return "account is locked out"
+	}
+	return ""
+}
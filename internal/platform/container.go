@@ -0,0 +1,129 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	containerOnce sync.Once
+	inContainer   bool
+
+	hostRootMu sync.Mutex
+	hostRoot   string
+)
+
+// InContainer reports whether the scanner runs in a Docker, Podman,
+// Kubernetes, LXC or systemd-nspawn container, or a Windows container
+func InContainer() bool {
+	containerOnce.Do(func() { inContainer = detectContainer() })
+	return inContainer
+}
+
+// detectContainer looks for the files and variables container runtimes set
+func detectContainer() bool {
+	// Podman, LXC and systemd-nspawn set container=...
+	if os.Getenv("container") != "" {
+		return true
+	}
+	for _, path := range []string{"/.dockerenv", "/run/.containerenv"} {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+	// cgroup v1 paths name the runtime; cgroup v2 shows only "0::/"
+	if data, err := os.ReadFile("/proc/1/cgroup"); err == nil {
+		for _, marker := range []string{"docker", "kubepods", "containerd", "libpod", "lxc"} {
+			if strings.Contains(string(data), marker) {
+				return true
+			}
+		}
+	}
+	// Windows containers run as one of two built-in accounts
+	switch os.Getenv("USERNAME") {
+	case "ContainerAdministrator", "ContainerUser":
+		return true
+	}
+	return false
+}
+
+// SetHostRoot sets where the host's filesystem, or its account database and
+// homes, is mounted when the scanner runs in a container. The machine id,
+// hostname and OS version are then those of the host.
+func SetHostRoot(root string) {
+	hostRootMu.Lock()
+	defer hostRootMu.Unlock()
+
+	hostRoot = root
+}
+
+// HostRoot returns the mount point set with SetHostRoot, "" if there is none
+func HostRoot() string {
+	hostRootMu.Lock()
+	defer hostRootMu.Unlock()
+
+	return hostRoot
+}
+
+// HostOS returns the OS of the host mounted at the host root, or CurrentOS()
+// without one
+func HostOS() OS {
+	if root := HostRoot(); root != "" {
+		if layout, err := ImageOS(root); err == nil {
+			return layout
+		}
+	}
+	return CurrentOS()
+}
+
+// Hostname returns the host name of the host mounted at the host root (its
+// etc/hostname), or of this system
+func Hostname() string {
+	if root := HostRoot(); root != "" {
+		if data, err := os.ReadFile(filepath.Join(root, "etc", "hostname")); err == nil {
+			if name := strings.TrimSpace(string(data)); name != "" {
+				return name
+			}
+		}
+	}
+	name, _ := os.Hostname()
+	return name
+}
+
+// hostMachineID reads the machine id of the host mounted at root. The id of
+// the container is never used in its place, as it changes with the container.
+func hostMachineID(root string) (string, error) {
+	for _, path := range []string{"etc/machine-id", "var/lib/dbus/machine-id"} {
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(path)))
+		if err != nil {
+			continue
+		}
+		if id := strings.TrimSpace(string(data)); id != "" {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("machine id not found under host root %s", root)
+}
+
+// hostOSVersion reads the distribution name from the host's os-release, ""
+// if the host root does not include it
+func hostOSVersion(root string) string {
+	for _, path := range []string{"etc/os-release", "usr/lib/os-release"} {
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(path)))
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if value, ok := strings.CutPrefix(line, "PRETTY_NAME="); ok {
+				return strings.Trim(value, `"'`)
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,11 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+// FreeSpace returns the bytes available to the process on the filesystem
+// holding dir.
+// This is implemented per-platform in freespace_*.go files
+func FreeSpace(dir string) (uint64, error) {
+	return freeSpaceImpl(dir)
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import "golang.org/x/sys/unix"
+
+// freeSpaceImpl reads the blocks available to unprivileged users with statfs
+func freeSpaceImpl(dir string) (uint64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
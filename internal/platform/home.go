@@ -0,0 +1,69 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrHomeTimeout is returned by CheckHome when a home directory on a network
+// filesystem (NFS, SMB, redirected folders) does not respond in time
+var ErrHomeTimeout = errors.New("home directory did not respond")
+
+var (
+	homeMu      sync.Mutex
+	homeTimeout = 10 * time.Second
+	homePending = make(map[string]bool) // Probes still blocked on a hung mount
+)
+
+// SetHomeTimeout sets how long CheckHome waits for a home directory;
+// non-positive values keep the current timeout
+func SetHomeTimeout(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	homeMu.Lock()
+	defer homeMu.Unlock()
+
+	homeTimeout = d
+}
+
+// CheckHome reports whether a home directory is reachable. Missing or
+// unmounted homes return an error matching os.ErrNotExist; homes that do not
+// answer within the timeout return ErrHomeTimeout. A stat blocked on a hung
+// mount cannot be cancelled, so later checks of the same directory fail
+// immediately until it returns.
+func CheckHome(dir string) error {
+	homeMu.Lock()
+	if homePending[dir] {
+		homeMu.Unlock()
+		return fmt.Errorf("%s: %w", dir, ErrHomeTimeout)
+	}
+	homePending[dir] = true
+	timeout := homeTimeout
+	homeMu.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := os.Stat(dir)
+
+		homeMu.Lock()
+		delete(homePending, dir)
+		homeMu.Unlock()
+
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("%s: %w after %s", dir, ErrHomeTimeout, timeout)
+	}
+}
@@ -0,0 +1,16 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+// LockedHome reports whether the user's home is encrypted and locked because
+// the user is signed out (ecryptfs, systemd-homed, legacy FileVault). It
+// returns a short description of the encryption, or "" if the home is not
+// a locked encrypted home.
+// This is implemented per-platform in homelock_*.go files
+func LockedHome(u User) string {
+	if u.HomeDir == "" || u.Layout != "" {
+		return ""
+	}
+	return lockedHomeImpl(u)
+}
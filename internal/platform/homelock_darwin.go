@@ -0,0 +1,22 @@
+//go:build darwin
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// lockedHomeImpl detects legacy FileVault homes: while the user is signed
+// out, the home folder only holds the encrypted disk image
+func lockedHomeImpl(u User) string {
+	for _, ext := range []string{".sparsebundle", ".sparseimage"} {
+		if _, err := os.Stat(filepath.Join(u.HomeDir, u.Username+ext)); err == nil {
+			return "FileVault home image is not mounted"
+		}
+	}
+	return ""
+}
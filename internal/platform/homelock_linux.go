@@ -0,0 +1,39 @@
+//go:build linux
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// ecryptfsMagic is the statfs type of a mounted ecryptfs filesystem
+const ecryptfsMagic = 0xf15f
+
+// lockedHomeImpl detects ecryptfs private homes that are not mounted and
+// inactive systemd-homed homes (the image exists, the home is not mounted)
+func lockedHomeImpl(u User) string {
+	// ecryptfs keeps the encrypted data in /home/.ecryptfs/<user>
+	if _, err := os.Stat(filepath.Join(filepath.Dir(u.HomeDir), ".ecryptfs", u.Username)); err == nil {
+		var fs unix.Statfs_t
+		if err := unix.Statfs(u.HomeDir, &fs); err != nil || fs.Type != ecryptfsMagic {
+			return "ecryptfs private directory is not mounted"
+		}
+		return ""
+	}
+
+	// systemd-homed stores the home as /home/<user>.home (LUKS image or directory)
+	if _, err := os.Stat(u.HomeDir + ".home"); err == nil {
+		var home, parent unix.Stat_t
+		if unix.Stat(u.HomeDir, &home) != nil || unix.Stat(filepath.Dir(u.HomeDir), &parent) != nil || home.Dev == parent.Dev {
+			return "systemd-homed home is not active"
+		}
+	}
+
+	return ""
+}
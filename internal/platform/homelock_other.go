@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+// lockedHomeImpl is not implemented on this platform
+func lockedHomeImpl(u User) string {
+	return ""
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import "sync"
+
+// Identity is the directory account behind a local user
+type Identity struct {
+	Domain    string // Directory domain, e.g. "CORP" or "AzureAD"
+	Account   string // Domain-qualified account name, e.g. "CORP\jsmith"
+	UPN       string // User principal name, e.g. "jsmith@corp.example.com"
+	Directory string // macOS directory-services node, e.g. "/Active Directory/CORP/All Domains"
+}
+
+// DeviceJoin describes how this machine is joined to a directory
+type DeviceJoin struct {
+	Type       string // "domain", "entra" or "hybrid"
+	Domain     string // Active Directory domain
+	TenantID   string // Entra ID tenant
+	TenantName string
+	DeviceID   string // Entra ID device object id
+}
+
+// Device join types
+const (
+	JoinDomain = "domain" // Active Directory
+	JoinEntra  = "entra"  // Entra ID (Azure AD)
+	JoinHybrid = "hybrid" // Active Directory and Entra ID
+)
+
+// GetIdentity resolves the directory account of a user. It returns nil for
+// local accounts or if the directory cannot be queried.
+// This is implemented per-platform in identity_*.go files
+func GetIdentity(u User) *Identity {
+	return getIdentityImpl(u)
+}
+
+var (
+	deviceJoinOnce sync.Once
+	deviceJoin     *DeviceJoin
+)
+
+// GetDeviceJoin returns the machine's directory join, or nil if it is not joined.
+// The result is cached for the lifetime of the process.
+func GetDeviceJoin() *DeviceJoin {
+	deviceJoinOnce.Do(func() {
+		deviceJoin = getDeviceJoinImpl()
+	})
+	return deviceJoin
+}
@@ -0,0 +1,85 @@
+//go:build darwin
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// getIdentityImpl reads the directory-services origin of a mobile account
+// (OriginalNodeName) and its Kerberos principal from the local node
+func getIdentityImpl(u User) *Identity {
+	output, err := exec.Command("dscl", ".", "-read", "/Users/"+u.Username, "OriginalNodeName", "AuthenticationAuthority").Output()
+	if err != nil {
+		return nil
+	}
+
+	attrs := parseDsclAttributes(string(output))
+	node := strings.Join(attrs["OriginalNodeName"], " ")
+	if node == "" {
+		return nil // Local account
+	}
+
+	id := &Identity{Directory: node}
+
+	// "/Active Directory/CORP/All Domains"
+	if rest, ok := strings.CutPrefix(node, "/Active Directory/"); ok {
+		id.Domain, _, _ = strings.Cut(rest, "/")
+		id.Account = id.Domain + `\` + u.Username
+	}
+
+	// ";Kerberosv5;;jsmith@CORP.EXAMPLE.COM;CORP.EXAMPLE.COM;"
+	for _, authority := range attrs["AuthenticationAuthority"] {
+		fields := strings.Split(authority, ";")
+		if len(fields) > 4 && fields[1] == "Kerberosv5" && !strings.HasPrefix(fields[4], "LKDC:") {
+			id.UPN = fields[3]
+			break
+		}
+	}
+
+	return id
+}
+
+// parseDsclAttributes parses "dscl -read" output into attribute values.
+// Long values are printed on indented continuation lines.
+func parseDsclAttributes(output string) map[string][]string {
+	attrs := make(map[string][]string)
+	var current string
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, " ") {
+			if current != "" {
+				attrs[current] = append(attrs[current], strings.Fields(line)...)
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			current = ""
+			continue
+		}
+		current = key
+		attrs[key] = append(attrs[key], strings.Fields(value)...)
+	}
+	return attrs
+}
+
+// getDeviceJoinImpl reads the Active Directory binding from dsconfigad
+func getDeviceJoinImpl() *DeviceJoin {
+	output, err := exec.Command("dsconfigad", "-show").Output()
+	if err != nil {
+		return nil
+	}
+
+	// "Active Directory Domain          = corp.example.com"
+	for _, line := range strings.Split(string(output), "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if ok && strings.TrimSpace(key) == "Active Directory Domain" {
+			return &DeviceJoin{Type: JoinDomain, Domain: strings.TrimSpace(value)}
+		}
+	}
+	return nil
+}
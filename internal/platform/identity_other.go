@@ -0,0 +1,17 @@
+//go:build !windows && !darwin
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+// getIdentityImpl is not implemented; directory accounts (sssd, winbind)
+// already appear under their qualified user names
+func getIdentityImpl(u User) *Identity {
+	return nil
+}
+
+// getDeviceJoinImpl is not implemented on this platform
+func getDeviceJoinImpl() *DeviceJoin {
+	return nil
+}
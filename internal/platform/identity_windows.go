@@ -0,0 +1,139 @@
+//go:build windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"os/exec"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// identityCacheKey holds the UPNs of Entra ID (and hybrid) accounts by SID
+const identityCacheKey = `SOFTWARE\Microsoft\IdentityStore\Cache`
+
+// getIdentityImpl resolves the user's SID to its domain account and UPN
+func getIdentityImpl(u User) *Identity {
+	if u.SID == "" {
+		return nil
+	}
+
+	sid, err := windows.StringToSid(u.SID)
+	if err != nil {
+		return nil
+	}
+	account, domain, _, err := sid.LookupAccount("")
+	if err != nil || domain == "" {
+		return nil
+	}
+
+	// Local accounts belong to the computer's own "domain"
+	if computer, err := windows.ComputerName(); err == nil && strings.EqualFold(domain, computer) {
+		return nil
+	}
+
+	id := &Identity{
+		Domain:  domain,
+		Account: domain + `\` + account,
+	}
+
+	id.UPN = cachedUPN(u.SID)
+	if id.UPN == "" {
+		id.UPN = translateUPN(id.Account)
+	}
+	return id
+}
+
+// cachedUPN reads the UPN Windows caches for Entra ID sign-ins
+func cachedUPN(sid string) string {
+	path := identityCacheKey + `\` + sid + `\IdentityCache\` + sid
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, path, registry.QUERY_VALUE|registry.WOW64_64KEY)
+	if err != nil {
+		return ""
+	}
+	defer k.Close()
+
+	upn, _, err := k.GetStringValue("UserName")
+	if err != nil {
+		return ""
+	}
+	return upn
+}
+
+// translateUPN asks Active Directory for the UPN of a DOMAIN\user account.
+// This needs a reachable domain controller.
+func translateUPN(account string) string {
+	name, err := windows.UTF16PtrFromString(account)
+	if err != nil {
+		return ""
+	}
+
+	buf := make([]uint16, 256)
+	size := uint32(len(buf))
+	if err := windows.TranslateName(name, windows.NameSamCompatible, windows.NameUserPrincipal, &buf[0], &size); err != nil {
+		return ""
+	}
+	return windows.UTF16ToString(buf[:size])
+}
+
+// getDeviceJoinImpl reads the join state from dsregcmd, falling back to the
+// Active Directory domain for systems without dsregcmd
+func getDeviceJoinImpl() *DeviceJoin {
+	if output, err := exec.Command("dsregcmd", "/status").Output(); err == nil {
+		return parseDsregcmd(string(output))
+	}
+
+	var name *uint16
+	var status uint32
+	if err := windows.NetGetJoinInformation(nil, &name, &status); err != nil {
+		return nil
+	}
+	defer windows.NetApiBufferFree((*byte)(unsafe.Pointer(name)))
+
+	if status != windows.NetSetupDomainName {
+		return nil
+	}
+	return &DeviceJoin{Type: JoinDomain, Domain: windows.UTF16PtrToString(name)}
+}
+
+// parseDsregcmd extracts the join state from "dsregcmd /status" output
+// ("    AzureAdJoined : YES" lines)
+func parseDsregcmd(output string) *DeviceJoin {
+	values := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		if key, value, ok := strings.Cut(line, " : "); ok {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+
+	t := joinType(values["DomainJoined"] == "YES", values["AzureAdJoined"] == "YES")
+	if t == "" {
+		return nil
+	}
+
+	return &DeviceJoin{
+		Type:       t,
+		Domain:     values["DomainName"],
+		TenantID:   values["TenantId"],
+		TenantName: values["TenantName"],
+		DeviceID:   values["DeviceId"],
+	}
+}
+
+// joinType combines Active Directory and Entra ID join states
+func joinType(domain, entra bool) string {
+	switch {
+	case domain && entra:
+		return JoinHybrid
+	case domain:
+		return JoinDomain
+	case entra:
+		return JoinEntra
+	}
+	return ""
+}
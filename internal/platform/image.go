@@ -0,0 +1,177 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// imageMarkers identify the OS of a system root by files it keeps at fixed
+// paths, in order of precedence (macOS also has /etc/passwd)
+var imageMarkers = []struct {
+	os   OS
+	path string
+}{
+	{Windows, "Windows/System32"},
+	{Darwin, "System/Library/CoreServices/SystemVersion.plist"},
+	{Darwin, "Users/Shared"},  // APFS data volume mounted on its own
+	{Windows, "Users/Public"}, // Profiles folder mounted on its own
+	{FreeBSD, "bin/freebsd-version"},
+	{Linux, "etc/passwd"},
+	{Linux, "home"},
+}
+
+// ImageOS returns the OS whose layout a mounted disk image or offline system
+// root follows
+func ImageOS(root string) (OS, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return "", fmt.Errorf("failed to read image root: %w", err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("image root %s is not a directory", root)
+	}
+	for _, m := range imageMarkers {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(m.path))); err == nil {
+			return m.os, nil
+		}
+	}
+	return "", fmt.Errorf("cannot tell the OS of %s: no Windows, Users or etc/passwd", root)
+}
+
+// ImageUsers returns the users of a mounted disk image or offline system
+// root, with their homes under root and the image's layout. Windows and
+// macOS users are the profiles under Users; Linux and FreeBSD users come from
+// the image's etc/passwd and home directories. With names ("user" or
+// "user=/home/dir", relative to root), only those users are returned, at
+// their enumerated or default home.
+func ImageUsers(root string, names []string) ([]User, error) {
+	layout, err := ImageOS(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []User
+	switch layout {
+	case Windows:
+		users, err = windowsProfiles(filepath.Join(root, "Users"))
+	case Darwin:
+		users, err = darwinImageUsers(root)
+	default:
+		users, err = passwdImageUsers(root)
+	}
+	// The account database may be unreadable when users are supplied
+	if err != nil && len(names) == 0 {
+		return nil, err
+	}
+	if len(names) > 0 {
+		if users, err = selectImageUsers(users, names, root, layout); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range users {
+		users[i].Root, users[i].Layout = root, layout
+	}
+	return users, nil
+}
+
+// darwinImageUsers returns the homes under Users that hold a Library folder
+func darwinImageUsers(root string) ([]User, error) {
+	usersDir := filepath.Join(root, "Users")
+	entries, err := os.ReadDir(usersDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Users directory: %w", err)
+	}
+
+	var users []User
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || name == "Shared" || name == "Guest" || strings.HasPrefix(name, ".") {
+			continue
+		}
+		homeDir := filepath.Join(usersDir, name)
+		if _, err := os.Stat(filepath.Join(homeDir, "Library")); err != nil {
+			continue
+		}
+		users = append(users, User{Username: name, HomeDir: homeDir})
+	}
+	return users, nil
+}
+
+// passwdImageUsers returns the real users of the image's etc/passwd whose
+// homes exist, and the directories under home that no account owns
+func passwdImageUsers(root string) ([]User, error) {
+	var users []User
+	homes := make(map[string]bool)
+	file, err := os.Open(filepath.Join(root, "etc", "passwd"))
+	if err == nil {
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			u, ok := parsePasswdLine(scanner.Text())
+			if !ok {
+				continue
+			}
+			u.HomeDir = filepath.Join(root, u.HomeDir)
+			if _, err := os.Stat(u.HomeDir); err != nil || homes[u.HomeDir] {
+				continue
+			}
+			homes[u.HomeDir] = true
+			users = append(users, u)
+		}
+		err = scanner.Err()
+		file.Close()
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read etc/passwd of the image: %w", err)
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(root, "home"))
+	for _, entry := range entries {
+		name := entry.Name()
+		dir := filepath.Join(root, "home", name)
+		if !entry.IsDir() || name == "lost+found" || strings.HasPrefix(name, ".") || homes[dir] {
+			continue
+		}
+		users = append(users, User{Username: name, HomeDir: dir})
+	}
+	return users, nil
+}
+
+// selectImageUsers returns the named users, at their enumerated home or the
+// given or default home of the image's layout
+func selectImageUsers(users []User, names []string, root string, layout OS) ([]User, error) {
+	var selected []User
+	for _, entry := range names {
+		name, home, explicit := strings.Cut(strings.TrimSpace(entry), "=")
+		if name = strings.TrimSpace(name); name == "" {
+			return nil, fmt.Errorf("invalid image user %q", entry)
+		}
+
+		u := User{Username: name}
+		for _, known := range users {
+			if strings.EqualFold(known.Username, name) {
+				u = known
+				break
+			}
+		}
+		switch {
+		case explicit:
+			u.HomeDir = filepath.Join(root, filepath.FromSlash(strings.TrimSpace(home)))
+		case u.HomeDir != "":
+		case layout == Windows || layout == Darwin:
+			u.HomeDir = filepath.Join(root, "Users", name)
+		case name == "root":
+			u.HomeDir = filepath.Join(root, "root")
+		default:
+			u.HomeDir = filepath.Join(root, "home", name)
+		}
+		selected = append(selected, u)
+	}
+	return selected, nil
+}
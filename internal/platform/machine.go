@@ -0,0 +1,41 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// MachineID returns a stable, OS-assigned identifier of this machine
+// (/etc/machine-id, IOPlatformUUID or MachineGuid), or the machine id of the
+// host mounted at the host root.
+// This is implemented per-platform in machine_*.go files
+func MachineID() (string, error) {
+	if root := HostRoot(); root != "" {
+		return hostMachineID(root)
+	}
+	return machineIDImpl()
+}
+
+// DeviceID returns a stable device identifier derived from MachineID. The
+// machine id is hashed so the raw value is never sent to the server.
+func DeviceID() (string, error) {
+	id, err := MachineID()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte("hist_scanner device:" + id))
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// OSVersion returns the OS name and version, e.g. "Ubuntu 24.04.1 LTS"
+// or "macOS 14.5 (23F79)". For a host mounted at the host root it is read
+// from the host's os-release, and "" if the mount does not include it.
+func OSVersion() string {
+	if root := HostRoot(); root != "" {
+		return hostOSVersion(root)
+	}
+	return osVersionImpl()
+}
@@ -0,0 +1,66 @@
+//go:build darwin
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// machineIDImpl reads IOPlatformUUID via ioreg
+func machineIDImpl() (string, error) {
+	cmd := exec.Command("ioreg", "-rd1", "-c", "IOPlatformExpertDevice")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run ioreg: %w", err)
+	}
+
+	// Parse line: "IOPlatformUUID" = "564D4F1A-..."
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.Contains(line, "IOPlatformUUID") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if id := strings.Trim(strings.TrimSpace(parts[1]), `"`); id != "" {
+			return id, nil
+		}
+	}
+
+	return "", fmt.Errorf("IOPlatformUUID not found")
+}
+
+// osVersionImpl reads the product name and version from sw_vers
+func osVersionImpl() string {
+	output, err := exec.Command("sw_vers").Output()
+	if err != nil {
+		return "macOS"
+	}
+
+	// "ProductName:\tmacOS", "ProductVersion:\t14.5", "BuildVersion:\t23F79"
+	values := make(map[string]string)
+	for _, line := range strings.Split(string(output), "\n") {
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			values[key] = strings.TrimSpace(value)
+		}
+	}
+
+	version := strings.TrimSpace(values["ProductName"] + " " + values["ProductVersion"])
+	if version == "" {
+		return "macOS"
+	}
+	if values["BuildVersion"] != "" {
+		version += " (" + values["BuildVersion"] + ")"
+	}
+	return version
+}
@@ -0,0 +1,41 @@
+//go:build freebsd
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// machineIDImpl reads the host UUID (kern.hostuuid, persisted in /etc/hostid)
+func machineIDImpl() (string, error) {
+	if id, err := unix.Sysctl("kern.hostuuid"); err == nil {
+		// Unset host UUIDs read as all zeros
+		if id = strings.TrimSpace(id); id != "" && strings.Trim(id, "0-") != "" {
+			return id, nil
+		}
+	}
+
+	if data, err := os.ReadFile("/etc/hostid"); err == nil {
+		if id := strings.TrimSpace(string(data)); id != "" {
+			return id, nil
+		}
+	}
+
+	return "", fmt.Errorf("host uuid not found")
+}
+
+// osVersionImpl returns the kernel name and release, e.g. "FreeBSD 14.1-RELEASE-p3"
+func osVersionImpl() string {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return "FreeBSD"
+	}
+	return unix.ByteSliceToString(uts.Sysname[:]) + " " + unix.ByteSliceToString(uts.Release[:])
+}
@@ -0,0 +1,58 @@
+//go:build windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// machineIDImpl reads MachineGuid from the registry
+func machineIDImpl() (string, error) {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SOFTWARE\Microsoft\Cryptography`, registry.QUERY_VALUE|registry.WOW64_64KEY)
+	if err != nil {
+		return "", fmt.Errorf("failed to open Cryptography key: %w", err)
+	}
+	defer key.Close()
+
+	id, _, err := key.GetStringValue("MachineGuid")
+	if err != nil {
+		return "", fmt.Errorf("failed to read MachineGuid: %w", err)
+	}
+	return id, nil
+}
+
+// osVersionImpl reads the edition, release and build from the registry,
+// e.g. "Windows 11 Enterprise 23H2 (build 22631.3880)"
+func osVersionImpl() string {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SOFTWARE\Microsoft\Windows NT\CurrentVersion`, registry.QUERY_VALUE|registry.WOW64_64KEY)
+	if err != nil {
+		return "Windows"
+	}
+	defer key.Close()
+
+	name, _, _ := key.GetStringValue("ProductName")
+	release, _, _ := key.GetStringValue("DisplayVersion")
+	build, _, _ := key.GetStringValue("CurrentBuild")
+	ubr, _, _ := key.GetIntegerValue("UBR")
+
+	// Windows 11 still reports "Windows 10" as ProductName
+	if n, err := strconv.Atoi(build); err == nil && n >= 22000 {
+		name = strings.Replace(name, "Windows 10", "Windows 11", 1)
+	}
+	if name == "" {
+		name = "Windows"
+	}
+
+	version := strings.TrimSpace(name + " " + release)
+	if build != "" {
+		version += fmt.Sprintf(" (build %s.%d)", build, ubr)
+	}
+	return version
+}
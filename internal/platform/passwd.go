@@ -0,0 +1,60 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parsePasswdLine parses one passwd entry, rejecting system accounts
+func parsePasswdLine(line string) (User, bool) {
+	if line == "" || strings.HasPrefix(line, "#") {
+		return User{}, false
+	}
+
+	fields := strings.Split(line, ":")
+	if len(fields) < 7 {
+		return User{}, false
+	}
+
+	username := fields[0]
+	uid := fields[2]
+	homeDir := fields[5]
+	shell := fields[6]
+
+	// Skip system users (typically UID < 1000) and users with nologin/false shells
+	// But include root (UID 0) if it has a valid home
+	if !isRealUser(uid, shell) {
+		return User{}, false
+	}
+
+	return User{
+		Username: username,
+		HomeDir:  homeDir,
+		UID:      uid,
+	}, true
+}
+
+// isRealUser checks if this is a real user account (not a system service)
+func isRealUser(uid, shell string) bool {
+	// Exclude nologin shells
+	nologinShells := []string{"/usr/sbin/nologin", "/sbin/nologin", "/bin/false", "/usr/bin/false"}
+	for _, nologin := range nologinShells {
+		if shell == nologin {
+			return false
+		}
+	}
+
+	// Include root
+	if uid == "0" {
+		return true
+	}
+
+	// For regular users, UID should be >= 1000 (configurable via /etc/login.defs)
+	// We'll use 1000 as the default threshold
+	var uidNum int
+	fmt.Sscanf(uid, "%d", &uidNum)
+	return uidNum >= 1000
+}
@@ -4,6 +4,7 @@
 package platform
 
 import (
+	"path/filepath"
 	"runtime"
 )
 
@@ -14,6 +15,7 @@ const (
 	Linux   OS = "linux"
 	Windows OS = "windows"
 	Darwin  OS = "darwin"
+	FreeBSD OS = "freebsd"
 )
 
 // User represents a system user with their home directory
@@ -21,6 +23,62 @@ type User struct {
 	Username string
 	HomeDir  string
 	UID      string
+	SID      string // Windows security identifier; empty on other platforms
+	Domain   string // Windows account domain, for display as DOMAIN\user; empty if unknown
+
+	// Redirected AppData folders (Windows folder redirection, roaming
+	// profiles); empty means the default location under HomeDir
+	RoamingAppData string
+	LocalAppData   string
+
+	// Layout is the OS whose directory layout HomeDir follows, e.g. Windows
+	// profiles scanned from inside WSL; empty means CurrentOS()
+	Layout OS
+
+	// Root is the mounted disk image or offline system root HomeDir lies
+	// under (ImageUsers); empty for users of the running system. Host marks
+	// users of a live host mounted into the scanner's container at Root.
+	Root string
+	Host bool
+
+	// ProfileStore names the profile store a signed-out user was found in
+	// ("fslogix" or "citrix-upm"). Container is the FSLogix disk that must be
+	// attached with MountContainer before HomeDir is known.
+	ProfileStore string
+	Container    string
+}
+
+// HomeLayout returns the OS whose directory layout the user's home follows
+func (u User) HomeLayout() OS {
+	if u.Layout != "" {
+		return u.Layout
+	}
+	return CurrentOS()
+}
+
+// DisplayName returns DOMAIN\user for Windows accounts of a known domain,
+// otherwise the user name
+func (u User) DisplayName() string {
+	if u.Domain != "" {
+		return u.Domain + `\` + u.Username
+	}
+	return u.Username
+}
+
+// AppDataPath returns the user's roaming AppData folder (Windows)
+func (u User) AppDataPath() string {
+	if u.RoamingAppData != "" {
+		return u.RoamingAppData
+	}
+	return filepath.Join(u.HomeDir, "AppData", "Roaming")
+}
+
+// LocalAppDataPath returns the user's local AppData folder (Windows)
+func (u User) LocalAppDataPath() string {
+	if u.LocalAppData != "" {
+		return u.LocalAppData
+	}
+	return filepath.Join(u.HomeDir, "AppData", "Local")
 }
 
 // CurrentOS returns the current operating system
@@ -31,7 +89,7 @@ func CurrentOS() OS {
 // IsSupported checks if the current OS is supported
 func IsSupported() bool {
 	switch CurrentOS() {
-	case Linux, Windows, Darwin:
+	case Linux, Windows, Darwin, FreeBSD:
 		return true
 	default:
 		return false
@@ -0,0 +1,26 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+// MkdirPrivate creates dir (and missing parents) so that only the current
+// user can access it (plus SYSTEM and Administrators on Windows). An existing
+// dir is checked or restricted the same way; a dir owned by another user or
+// a symlink is rejected.
+// This is implemented per-platform in private_*.go files
+func MkdirPrivate(dir string) error {
+	return mkdirPrivateImpl(dir)
+}
+
+// RestrictFile makes an existing file (or socket) accessible to the current
+// user only (plus SYSTEM and Administrators on Windows, with an explicit
+// DACL). On Windows, readers are SIDs additionally granted read access;
+// elsewhere they are ignored.
+func RestrictFile(path string, readers ...string) error {
+	return restrictFileImpl(path, readers)
+}
+
+// ProcessAlive reports whether a process with the given pid exists
+func ProcessAlive(pid int) bool {
+	return processAliveImpl(pid)
+}
@@ -0,0 +1,86 @@
+//go:build windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// stillActive is the exit code GetExitCodeProcess reports for running processes
+const stillActive = 259
+
+// mkdirPrivateImpl creates dir and replaces its inherited permissions with
+// full access for the current user, SYSTEM and Administrators only
+func mkdirPrivateImpl(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() || info.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	return setPrivateDACL(dir, "OICI", nil)
+}
+
+// restrictFileImpl replaces the inherited permissions of path with full
+// access for the current user, SYSTEM and Administrators and read access
+// for readers. os.Chmod only sets the read-only attribute on Windows.
+func restrictFileImpl(path string, readers []string) error {
+	return setPrivateDACL(path, "", readers)
+}
+
+// setPrivateDACL sets a protected DACL granting full access to the current
+// user, SYSTEM and Administrators and read access to the readers' SIDs;
+// inherit holds the ACE inheritance flags
+func setPrivateDACL(path, inherit string, readers []string) error {
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return fmt.Errorf("failed to read process user: %w", err)
+	}
+	sddl := fmt.Sprintf("D:P(A;%[1]s;FA;;;SY)(A;%[1]s;FA;;;BA)(A;%[1]s;FA;;;%[2]s)", inherit, user.User.Sid)
+	for _, sid := range readers {
+		sddl += fmt.Sprintf("(A;%s;FR;;;%s)", inherit, sid)
+	}
+	sd, err := windows.SecurityDescriptorFromString(sddl)
+	if err != nil {
+		return err
+	}
+	dacl, _, err := sd.DACL()
+	if err != nil {
+		return err
+	}
+	err = windows.SetNamedSecurityInfo(path, windows.SE_FILE_OBJECT,
+		windows.DACL_SECURITY_INFORMATION|windows.PROTECTED_DACL_SECURITY_INFORMATION, nil, nil, dacl, nil)
+	if err != nil {
+		return fmt.Errorf("failed to restrict %s: %w", path, err)
+	}
+	return nil
+}
+
+// processAliveImpl opens the process and checks that it has not exited
+func processAliveImpl(pid int) bool {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// Access denied means the process exists
+		return errors.Is(err, windows.ERROR_ACCESS_DENIED)
+	}
+	defer windows.CloseHandle(h)
+
+	var code uint32
+	if err := windows.GetExitCodeProcess(h, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
@@ -0,0 +1,26 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+// Process is a running process
+type Process struct {
+	PID   int
+	Name  string // Executable name in lower case without .exe, e.g. "chrome" or "google chrome"
+	Owner string // UID (Unix) or SID (Windows) of the user running it, "" if unknown
+}
+
+// RunBy reports whether the process runs as user u
+func (p Process) RunBy(u User) bool {
+	if u.SID != "" {
+		return p.Owner == u.SID
+	}
+	return u.UID != "" && p.Owner == u.UID
+}
+
+// Processes lists the running processes. Processes of other users are
+// listed without an owner if the scanner may not inspect them.
+// This is implemented per-platform in process_*.go files
+func Processes() ([]Process, error) {
+	return processesImpl()
+}
@@ -0,0 +1,50 @@
+//go:build linux
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// processesImpl reads the name and real user id of each process from
+// /proc/<pid>/status; processes that exit meanwhile are left out
+func processesImpl() ([]Process, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+	var procs []Process
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile("/proc/" + e.Name() + "/status")
+		if err != nil {
+			continue
+		}
+		p := Process{PID: pid}
+		sc := bufio.NewScanner(bytes.NewReader(data))
+		for sc.Scan() {
+			key, value, _ := strings.Cut(sc.Text(), ":")
+			switch key {
+			case "Name":
+				p.Name = strings.ToLower(strings.TrimSpace(value))
+			case "Uid":
+				if fields := strings.Fields(value); len(fields) > 0 {
+					p.Owner = fields[0]
+				}
+			}
+		}
+		procs = append(procs, p)
+	}
+	return procs, nil
+}
@@ -0,0 +1,37 @@
+//go:build darwin || freebsd
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// processesImpl lists processes with ps; comm is the executable path on
+// macOS (e.g. .../MacOS/Google Chrome) and its name on FreeBSD
+func processesImpl() ([]Process, error) {
+	output, err := exec.Command("ps", "-axo", "pid=,uid=,comm=").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+	var procs []Process
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		comm := strings.Join(fields[2:], " ")
+		procs = append(procs, Process{PID: pid, Name: strings.ToLower(filepath.Base(comm)), Owner: fields[1]})
+	}
+	return procs, nil
+}
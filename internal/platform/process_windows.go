@@ -0,0 +1,61 @@
+//go:build windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// processesImpl walks a Toolhelp snapshot of the processes and reads the
+// user of each from its token
+func processesImpl() ([]Process, error) {
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+	defer windows.CloseHandle(snapshot)
+
+	var procs []Process
+	entry := windows.ProcessEntry32{Size: uint32(unsafe.Sizeof(windows.ProcessEntry32{}))}
+	for err = windows.Process32First(snapshot, &entry); err == nil; err = windows.Process32Next(snapshot, &entry) {
+		name := strings.ToLower(windows.UTF16ToString(entry.ExeFile[:]))
+		procs = append(procs, Process{
+			PID:   int(entry.ProcessID),
+			Name:  strings.TrimSuffix(name, ".exe"),
+			Owner: processOwner(entry.ProcessID),
+		})
+	}
+	if !errors.Is(err, windows.ERROR_NO_MORE_FILES) {
+		return procs, fmt.Errorf("failed to list processes: %w", err)
+	}
+	return procs, nil
+}
+
+// processOwner returns the SID of the user running a process, or "" if the
+// scanner may not open it
+func processOwner(pid uint32) string {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return ""
+	}
+	defer windows.CloseHandle(h)
+
+	var token windows.Token
+	if err := windows.OpenProcessToken(h, windows.TOKEN_QUERY, &token); err != nil {
+		return ""
+	}
+	defer token.Close()
+	user, err := token.GetTokenUser()
+	if err != nil {
+		return ""
+	}
+	return user.User.Sid.String()
+}
@@ -0,0 +1,25 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+// Profile store names
+const (
+	StoreFSLogix   = "fslogix"    // FSLogix profile containers (VHD/VHDX)
+	StoreCitrixUPM = "citrix-upm" // Citrix Profile Management user store
+)
+
+// GetProfileStoreUsers returns the users whose profiles live in a configured
+// FSLogix or Citrix UPM profile store (terminal servers, VDI). Users whose
+// container is attached right now are signed in and already returned by
+// GetAllUsers, so they are skipped.
+// This is implemented per-platform in profilestore_*.go files
+func GetProfileStoreUsers() ([]User, error) {
+	return getProfileStoreUsersImpl()
+}
+
+// MountContainer attaches an FSLogix profile container read-only and returns
+// the profile directory inside it and a function that detaches it again
+func MountContainer(path string) (string, func(), error) {
+	return mountContainerImpl(path)
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import "fmt"
+
+// getProfileStoreUsersImpl returns no users; profile stores are Windows-only
+func getProfileStoreUsersImpl() ([]User, error) {
+	return nil, nil
+}
+
+// mountContainerImpl is not supported outside Windows
+func mountContainerImpl(path string) (string, func(), error) {
+	return "", nil, fmt.Errorf("profile containers are only supported on Windows")
+}
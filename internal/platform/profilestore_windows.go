@@ -0,0 +1,279 @@
+//go:build windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+const (
+	fslogixKey   = `SOFTWARE\FSLogix\Profiles`
+	upmPolicyKey = `SOFTWARE\Policies\Citrix\UserProfileManager`
+	upmKey       = `SOFTWARE\Citrix\UserProfileManager`
+)
+
+// getProfileStoreUsersImpl lists signed-out users from FSLogix container
+// locations and the Citrix UPM user store
+func getProfileStoreUsersImpl() ([]User, error) {
+	var users []User
+	var errs []error
+
+	fslogix, err := fslogixUsers()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	users = append(users, fslogix...)
+
+	upm, err := upmUsers()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	users = append(users, upm...)
+
+	return users, errors.Join(errs...)
+}
+
+// fslogixUsers finds profile containers in the VHDLocations folders. Each user
+// has a "<SID>_<username>" folder (or "<username>_<SID>" with
+// FlipFlopProfileDirectoryName) holding Profile_<username>.vhd(x).
+func fslogixUsers() ([]User, error) {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, fslogixKey, registry.QUERY_VALUE|registry.WOW64_64KEY)
+	if err != nil {
+		return nil, nil // FSLogix not installed
+	}
+	defer k.Close()
+
+	if enabled, _, err := k.GetIntegerValue("Enabled"); err != nil || enabled == 0 {
+		return nil, nil
+	}
+
+	var locations []string
+	if values, _, err := k.GetStringsValue("VHDLocations"); err == nil {
+		locations = values
+	} else if value, _, err := k.GetStringValue("VHDLocations"); err == nil {
+		locations = strings.Split(value, ";")
+	}
+
+	var users []User
+	for _, location := range locations {
+		location = strings.TrimSpace(location)
+		if location == "" {
+			continue
+		}
+
+		entries, err := os.ReadDir(location)
+		if err != nil {
+			return users, fmt.Errorf("failed to read FSLogix location %s: %w", location, err)
+		}
+
+		for _, entry := range entries {
+			sid, username := splitSIDDirName(entry.Name())
+			if !entry.IsDir() || sid == "" {
+				continue
+			}
+
+			dir := filepath.Join(location, entry.Name())
+			containers, _ := filepath.Glob(filepath.Join(dir, "Profile_*.vhd*"))
+			if len(containers) == 0 || containerInUse(containers[0]) {
+				continue
+			}
+
+			users = append(users, User{
+				Username:     username,
+				SID:          sid,
+				ProfileStore: StoreFSLogix,
+				Container:    containers[0],
+			})
+		}
+	}
+
+	return users, nil
+}
+
+// splitSIDDirName splits an FSLogix "<SID>_<username>" or "<username>_<SID>"
+// folder name
+func splitSIDDirName(name string) (sid, username string) {
+	if first, rest, ok := strings.Cut(name, "_"); ok && strings.HasPrefix(first, "S-1-") {
+		return first, rest
+	}
+	if i := strings.LastIndex(name, "_"); i != -1 && strings.HasPrefix(name[i+1:], "S-1-") {
+		return name[i+1:], name[:i]
+	}
+	return "", ""
+}
+
+// containerInUse reports whether FSLogix has the container attached for a
+// signed-in session: the attach holds write access, so an open that only
+// shares reading fails
+func containerInUse(path string) bool {
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return true
+	}
+	h, err := windows.CreateFile(name, windows.GENERIC_READ, windows.FILE_SHARE_READ, nil, windows.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return true
+	}
+	windows.CloseHandle(h)
+	return false
+}
+
+// mountContainerImpl attaches the disk read-only without a drive letter and
+// returns the "Profile" folder on its volume. While it is attached, FSLogix
+// cannot attach the container for a sign-in of that user, so callers detach
+// it as soon as the profile is scanned.
+func mountContainerImpl(path string) (string, func(), error) {
+	image := psQuote(path)
+	script := fmt.Sprintf(`$ErrorActionPreference = 'Stop'
+$disk = Mount-DiskImage -ImagePath %s -Access ReadOnly -NoDriveLetter -PassThru | Get-Disk
+Get-Partition -DiskNumber $disk.Number | ForEach-Object { $_.AccessPaths } | Where-Object { $_ -like '\\?\Volume*' } | Select-Object -First 1`, image)
+
+	output, err := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script).CombinedOutput()
+	detach := func() {
+		exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", "Dismount-DiskImage -ImagePath "+image).Run()
+	}
+	if err != nil {
+		detach()
+		return "", nil, fmt.Errorf("failed to attach profile container %s: %w\n%s", path, err, output)
+	}
+
+	volume := strings.TrimSpace(string(output))
+	if volume == "" {
+		detach()
+		return "", nil, fmt.Errorf("profile container %s has no volume", path)
+	}
+
+	return filepath.Join(volume, "Profile"), detach, nil
+}
+
+// psQuote quotes a string for PowerShell
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// upmUsers lists user folders in the Citrix UPM store. PathToUserStore, e.g.
+// "\\srv\upm$\%USERNAME%.%USERDOMAIN%\!CTX_OSNAME!!CTX_OSBITNESS!", is split
+// at the first variable into the store root and the user folder pattern; the
+// profile itself is the UPM_Profile folder below the user folder.
+func upmUsers() ([]User, error) {
+	store := upmStorePath()
+	if store == "" {
+		return nil, nil
+	}
+
+	root, pattern := splitUserStorePath(store)
+	if root == "" || !strings.Contains(strings.ToUpper(pattern), "%USERNAME%") {
+		return nil, fmt.Errorf("unsupported Citrix UPM store path %q", store)
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Citrix UPM store %s: %w", root, err)
+	}
+
+	var users []User
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		username := userFromPattern(entry.Name(), pattern)
+		if username == "" {
+			continue
+		}
+
+		profile := findUPMProfile(filepath.Join(root, entry.Name()))
+		if profile == "" {
+			continue
+		}
+
+		users = append(users, User{
+			Username:     username,
+			HomeDir:      profile,
+			ProfileStore: StoreCitrixUPM,
+		})
+	}
+
+	return users, nil
+}
+
+// upmStorePath reads PathToUserStore from policy, then from the local settings
+func upmStorePath() string {
+	for _, path := range []string{upmPolicyKey, upmKey} {
+		k, err := registry.OpenKey(registry.LOCAL_MACHINE, path, registry.QUERY_VALUE|registry.WOW64_64KEY)
+		if err != nil {
+			continue
+		}
+		value, _, err := k.GetStringValue("PathToUserStore")
+		k.Close()
+		if err == nil && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// splitUserStorePath splits a store path at the first segment holding a
+// %VARIABLE% or !CTX_VARIABLE!, returning the root and that segment
+func splitUserStorePath(path string) (string, string) {
+	parts := strings.Split(strings.TrimRight(path, `\`), `\`)
+	for i, part := range parts {
+		if strings.ContainsAny(part, "%!#") {
+			return strings.Join(parts[:i], `\`), part
+		}
+	}
+	return "", ""
+}
+
+// userFromPattern extracts the user name from a folder name created from a
+// pattern such as "%USERNAME%.%USERDOMAIN%"
+func userFromPattern(name, pattern string) string {
+	i := strings.Index(strings.ToUpper(pattern), "%USERNAME%")
+	prefix := pattern[:i]
+	rest := pattern[i+len("%USERNAME%"):]
+	if strings.ContainsAny(prefix, "%!#") || !strings.HasPrefix(strings.ToUpper(name), strings.ToUpper(prefix)) {
+		return ""
+	}
+
+	name = name[len(prefix):]
+	if rest != "" {
+		// The literal separator after %USERNAME%, e.g. "."
+		sep := rest[:1]
+		if sep == "%" || sep == "!" || sep == "#" {
+			return ""
+		}
+		name, _, _ = strings.Cut(name, sep)
+	}
+	return name
+}
+
+// findUPMProfile finds the UPM_Profile folder in a user folder, directly or
+// one platform folder (!CTX_OSNAME!...) deeper
+func findUPMProfile(userDir string) string {
+	if dir := filepath.Join(userDir, "UPM_Profile"); isDir(dir) {
+		return dir
+	}
+	matches, _ := filepath.Glob(filepath.Join(userDir, "*", "UPM_Profile"))
+	for _, dir := range matches {
+		if isDir(dir) {
+			return dir
+		}
+	}
+	return ""
+}
+
+// isDir reports whether path is an existing directory
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
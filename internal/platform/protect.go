@@ -0,0 +1,17 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+// ProtectData encrypts a secret so only the current account can recover it
+// (DPAPI on Windows). On other platforms it is returned unchanged and must
+// be kept in a file readable by its owner only.
+// This is implemented per-platform in protect_*.go files
+func ProtectData(data []byte) ([]byte, error) {
+	return protectDataImpl(data)
+}
+
+// UnprotectData recovers a secret protected by ProtectData
+func UnprotectData(data []byte) ([]byte, error) {
+	return unprotectDataImpl(data)
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+// protectDataImpl returns data unchanged; file permissions protect it here
+func protectDataImpl(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+// unprotectDataImpl returns data unchanged
+func unprotectDataImpl(data []byte) ([]byte, error) {
+	return data, nil
+}
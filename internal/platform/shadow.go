@@ -0,0 +1,23 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+// ShadowPath returns the path of a file inside a Volume Shadow Copy snapshot
+// of its volume (Windows), creating the snapshot on first use. Snapshots are
+// reused for other files on the same volume until ReleaseShadowCopies.
+// This is implemented per-platform in shadow_*.go files
+func ShadowPath(path string) (string, error) {
+	return shadowPathImpl(path)
+}
+
+// ReleaseShadowCopies deletes the snapshots created by ShadowPath
+func ReleaseShadowCopies() {
+	releaseShadowCopiesImpl()
+}
+
+// IsSharingViolation reports whether err means another process opened the
+// file without sharing it (Windows), as running Chromium browsers do
+func IsSharingViolation(err error) bool {
+	return isSharingViolationImpl(err)
+}
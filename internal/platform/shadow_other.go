@@ -0,0 +1,21 @@
+//go:build !windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import "fmt"
+
+// shadowPathImpl is not implemented; browsers do not hold mandatory locks here
+func shadowPathImpl(path string) (string, error) {
+	return "", fmt.Errorf("shadow copies are only supported on Windows")
+}
+
+// releaseShadowCopiesImpl has nothing to release on this platform
+func releaseShadowCopiesImpl() {}
+
+// isSharingViolationImpl is always false; files are not opened exclusively here
+func isSharingViolationImpl(err error) bool {
+	return false
+}
@@ -0,0 +1,92 @@
+//go:build windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/windows"
+)
+
+// shadowCopy is a snapshot created by ShadowPath
+type shadowCopy struct {
+	id     string // Win32_ShadowCopy ID
+	device string // \\?\GLOBALROOT\Device\HarddiskVolumeShadowCopyN
+}
+
+var (
+	shadowMu     sync.Mutex
+	shadowCopies = make(map[string]shadowCopy) // By volume, e.g. "C:"
+)
+
+// shadowPathImpl maps a path on a local drive into a shadow copy of the
+// drive. Files locked exclusively by a running browser can be read there.
+func shadowPathImpl(path string) (string, error) {
+	volume := strings.ToUpper(filepath.VolumeName(path))
+	if len(volume) != 2 || volume[1] != ':' {
+		return "", fmt.Errorf("shadow copies need a path on a local drive: %s", path)
+	}
+
+	shadowMu.Lock()
+	defer shadowMu.Unlock()
+
+	sc, ok := shadowCopies[volume]
+	if !ok {
+		var err error
+		if sc, err = createShadowCopy(volume); err != nil {
+			return "", err
+		}
+		shadowCopies[volume] = sc
+	}
+
+	return sc.device + path[len(volume):], nil
+}
+
+// createShadowCopy creates a client-accessible snapshot of a volume with WMI.
+// This needs administrative rights and the Volume Shadow Copy service.
+func createShadowCopy(volume string) (shadowCopy, error) {
+	script := fmt.Sprintf(`$ErrorActionPreference = 'Stop'
+$r = Invoke-CimMethod -ClassName Win32_ShadowCopy -MethodName Create -Arguments @{Volume = %s; Context = 'ClientAccessible'}
+if ($r.ReturnValue -ne 0) { throw "Win32_ShadowCopy.Create returned $($r.ReturnValue)" }
+$s = Get-CimInstance -ClassName Win32_ShadowCopy -Filter "ID='$($r.ShadowID)'"
+$s.ID
+$s.DeviceObject`, psQuote(volume+`\`))
+
+	output, err := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script).CombinedOutput()
+	if err != nil {
+		return shadowCopy{}, fmt.Errorf("failed to create shadow copy of %s: %w\n%s", volume, err, output)
+	}
+
+	lines := strings.Fields(string(output))
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], `\\?\GLOBALROOT\`) {
+		return shadowCopy{}, fmt.Errorf("failed to create shadow copy of %s: unexpected output %q", volume, output)
+	}
+
+	return shadowCopy{id: lines[0], device: lines[1]}, nil
+}
+
+// releaseShadowCopiesImpl deletes all snapshots created by this process
+func releaseShadowCopiesImpl() {
+	shadowMu.Lock()
+	defer shadowMu.Unlock()
+
+	for volume, sc := range shadowCopies {
+		script := fmt.Sprintf("Get-CimInstance -ClassName Win32_ShadowCopy -Filter \"ID='%s'\" | Remove-CimInstance", sc.id)
+		exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script).Run()
+		delete(shadowCopies, volume)
+	}
+}
+
+// isSharingViolationImpl reports ERROR_SHARING_VIOLATION and
+// ERROR_LOCK_VIOLATION, returned for files open without sharing
+func isSharingViolationImpl(err error) bool {
+	return errors.Is(err, windows.ERROR_SHARING_VIOLATION) || errors.Is(err, windows.ERROR_LOCK_VIOLATION)
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import "sync"
+
+// User sources for UserOptions.Source
+const (
+	UserSourceAuto   = ""       // getent if available, otherwise /etc/passwd
+	UserSourcePasswd = "passwd" // /etc/passwd only
+	UserSourceGetent = "getent" // getent passwd (all NSS sources: SSSD, LDAP, ...)
+)
+
+// UserOptions selects where GetAllUsers finds accounts on Linux and FreeBSD
+type UserOptions struct {
+	Source   string // One of the UserSource* constants
+	HomeDirs bool   // Also add /home directories of accounts not enumerated by NSS
+}
+
+var (
+	userOptsMu sync.Mutex
+	userOpts   UserOptions
+)
+
+// SetUserOptions configures account enumeration for GetAllUsers
+func SetUserOptions(opts UserOptions) {
+	userOptsMu.Lock()
+	defer userOptsMu.Unlock()
+
+	userOpts = opts
+}
+
+// getUserOptions returns the configured account enumeration options
+func getUserOptions() UserOptions {
+	userOptsMu.Lock()
+	defer userOptsMu.Unlock()
+
+	return userOpts
+}
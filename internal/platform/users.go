@@ -13,3 +13,21 @@ func GetAllUsers() ([]User, error) {
 func GetCurrentUser() (*User, error) {
 	return getCurrentUserImpl()
 }
+
+// PrivilegeAdvice describes the privileges needed to read the browser data
+// of other users
+func PrivilegeAdvice() string {
+	switch CurrentOS() {
+	case Darwin:
+		return "run as root and grant hist_scanner Full Disk Access (System Settings > Privacy & Security), which Safari data and other users' Library folders require"
+	case Windows:
+		return "run elevated as Administrator or as LocalSystem (install creates a SYSTEM task or service)"
+	default:
+		return "run as root (install creates a system service)"
+	}
+}
+
+// IsPrivileged reports whether the process runs as root/elevated Administrator
+func IsPrivileged() bool {
+	return isPrivilegedImpl()
+}
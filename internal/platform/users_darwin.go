@@ -8,6 +8,7 @@ package platform
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -56,8 +57,8 @@ func getAllUsersImpl() ([]User, error) {
 			continue
 		}
 
-		// Verify home directory exists
-		if _, err := os.Stat(homeDir); os.IsNotExist(err) {
+		// Skip missing or unmounted homes; unresponsive ones are reported by the scan
+		if err := CheckHome(homeDir); errors.Is(err, os.ErrNotExist) {
 			continue
 		}
 
@@ -110,6 +111,11 @@ func getUserUID(username string) (string, error) {
 	return strings.TrimSpace(parts[1]), nil
 }
 
+// isPrivilegedImpl reports whether the process runs as root
+func isPrivilegedImpl() bool {
+	return os.Geteuid() == 0
+}
+
 // getCurrentUserImpl returns the current user on macOS
 func getCurrentUserImpl() (*User, error) {
 	u, err := user.Current()
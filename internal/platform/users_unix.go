@@ -0,0 +1,163 @@
+//go:build linux || freebsd
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// getAllUsersImpl returns all users on Linux and FreeBSD from the passwd
+// database (/etc/passwd or getent, see UserOptions), optionally adding home
+// directories of accounts that NSS does not enumerate
+func getAllUsersImpl() ([]User, error) {
+	opts := getUserOptions()
+
+	source := opts.Source
+	if source == UserSourceAuto {
+		source = UserSourcePasswd
+		if _, err := exec.LookPath("getent"); err == nil {
+			source = UserSourceGetent
+		}
+	}
+
+	var users []User
+	switch source {
+	case UserSourceGetent:
+		// Includes NSS sources (SSSD, LDAP, winbind) that enumerate users
+		output, err := exec.Command("getent", "passwd").Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to run getent passwd: %w", err)
+		}
+		if users, err = parsePasswd(bytes.NewReader(output)); err != nil {
+			return nil, fmt.Errorf("failed to read getent output: %w", err)
+		}
+
+	case UserSourcePasswd:
+		file, err := os.Open("/etc/passwd")
+		if err != nil {
+			return nil, fmt.Errorf("failed to open /etc/passwd: %w", err)
+		}
+		defer file.Close()
+
+		if users, err = parsePasswd(file); err != nil {
+			return nil, fmt.Errorf("failed to read /etc/passwd: %w", err)
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown user source %q", source)
+	}
+
+	if opts.HomeDirs {
+		users = append(users, homeDirUsers(users)...)
+	}
+
+	return users, nil
+}
+
+// parsePasswd reads real users from passwd(5) formatted lines
+func parsePasswd(r io.Reader) ([]User, error) {
+	var users []User
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		u, ok := parsePasswdLine(scanner.Text())
+		if !ok {
+			continue
+		}
+
+		// Skip missing or unmounted homes; unresponsive ones are reported by
+		// the scan, locked encrypted ones are reported as skipped
+		if err := CheckHome(u.HomeDir); errors.Is(err, os.ErrNotExist) && LockedHome(u) == "" {
+			continue
+		}
+
+		users = append(users, u)
+	}
+
+	return users, scanner.Err()
+}
+
+// homeDirUsers returns the /home directories not owned by a known user.
+// SSSD does not enumerate domain users by default, so each directory name is
+// looked up individually; unknown directories are named after the directory
+// and carry the owner's uid.
+func homeDirUsers(known []User) []User {
+	entries, err := os.ReadDir("/home")
+	if err != nil {
+		return nil
+	}
+
+	homes := make(map[string]bool)
+	for _, u := range known {
+		homes[filepath.Clean(u.HomeDir)] = true
+	}
+
+	var users []User
+	for _, entry := range entries {
+		name := entry.Name()
+		dir := filepath.Join("/home", name)
+		if !entry.IsDir() || name == "lost+found" || strings.HasPrefix(name, ".") || homes[dir] {
+			continue
+		}
+
+		if output, err := exec.Command("getent", "passwd", name).Output(); err == nil {
+			if u, ok := parsePasswdLine(strings.TrimSpace(string(output))); ok && !homes[filepath.Clean(u.HomeDir)] {
+				homes[filepath.Clean(u.HomeDir)] = true
+				users = append(users, u)
+			}
+			continue
+		}
+
+		u := User{Username: name, HomeDir: dir}
+		if info, err := entry.Info(); err == nil {
+			if st, ok := info.Sys().(*syscall.Stat_t); ok {
+				u.UID = strconv.FormatUint(uint64(st.Uid), 10)
+			}
+		}
+		users = append(users, u)
+	}
+
+	return users
+}
+
+// isPrivilegedImpl reports whether the process runs as root
+func isPrivilegedImpl() bool {
+	return os.Geteuid() == 0
+}
+
+// getCurrentUserImpl returns the current user on Linux and FreeBSD
+func getCurrentUserImpl() (*User, error) {
+	u, err := user.Current()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+
+	// Ensure home directory is absolute
+	homeDir := u.HomeDir
+	if !filepath.IsAbs(homeDir) {
+		homeDir, err = filepath.Abs(homeDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve home directory: %w", err)
+		}
+	}
+
+	return &User{
+		Username: u.Username,
+		HomeDir:  homeDir,
+		UID:      u.Uid,
+	}, nil
+}
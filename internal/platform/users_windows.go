@@ -6,15 +6,195 @@
 package platform
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/user"
 	"path/filepath"
 	"strings"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
 )
 
-// getAllUsersImpl returns all users on Windows by scanning C:\Users directory
+// profileListKey lists the local profiles by SID with their ProfileImagePath
+const profileListKey = `SOFTWARE\Microsoft\Windows NT\CurrentVersion\ProfileList`
+
+// getAllUsersImpl returns all users on Windows from the ProfileList registry key,
+// falling back to scanning the Users directory if the key cannot be read
 func getAllUsersImpl() ([]User, error) {
+	users, err := profileListUsers()
+	if err == nil {
+		return users, nil
+	}
+
+	users, dirErr := usersDirUsers()
+	if dirErr != nil {
+		return nil, fmt.Errorf("failed to read profile list: %w; %w", err, dirErr)
+	}
+	return users, nil
+}
+
+// profileListUsers enumerates user profiles registered in ProfileList. Unlike
+// a directory scan this finds profiles moved off C:\Users and ignores
+// leftover folders of deleted accounts.
+func profileListUsers() ([]User, error) {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, profileListKey, registry.ENUMERATE_SUB_KEYS|registry.WOW64_64KEY)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ProfileList key: %w", err)
+	}
+	defer k.Close()
+
+	sids, err := k.ReadSubKeyNames(-1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ProfileList key: %w", err)
+	}
+
+	var users []User
+	seen := make(map[string]bool)
+	for _, sid := range sids {
+		// Only local/domain (S-1-5-21) and Entra ID (S-1-12-1) accounts; this skips
+		// SYSTEM, service accounts and ".bak" keys of temporary profiles
+		if (!strings.HasPrefix(sid, "S-1-5-21-") && !strings.HasPrefix(sid, "S-1-12-1-")) || strings.HasSuffix(sid, ".bak") {
+			continue
+		}
+
+		homeDir, err := profileImagePath(sid)
+		if err != nil || seen[strings.ToLower(homeDir)] {
+			continue
+		}
+
+		// Verify it's a valid user profile (has NTUSER.DAT)
+		if _, err := os.Stat(filepath.Join(homeDir, "NTUSER.DAT")); err != nil {
+			continue
+		}
+
+		username, domain, err := lookupSID(sid)
+		if errors.Is(err, windows.ERROR_NONE_MAPPED) {
+			continue // Account was deleted, the profile was left behind
+		}
+		if err != nil {
+			// Domain controller unreachable: the folder name is usually the account name
+			username = filepath.Base(homeDir)
+		}
+
+		seen[strings.ToLower(homeDir)] = true
+		roaming, local := shellFolders(sid, homeDir)
+		users = append(users, User{
+			Username:       username,
+			HomeDir:        homeDir,
+			SID:            sid,
+			Domain:         domain,
+			RoamingAppData: roaming,
+			LocalAppData:   local,
+		})
+	}
+
+	return users, nil
+}
+
+// profileImagePath reads the expanded profile directory of a ProfileList entry
+func profileImagePath(sid string) (string, error) {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, profileListKey+`\`+sid, registry.QUERY_VALUE|registry.WOW64_64KEY)
+	if err != nil {
+		return "", err
+	}
+	defer k.Close()
+
+	path, _, err := k.GetStringValue("ProfileImagePath")
+	if err != nil {
+		return "", err
+	}
+	return registry.ExpandString(path)
+}
+
+// userShellFoldersKey holds a user's folder locations, including redirected ones
+const userShellFoldersKey = `Software\Microsoft\Windows\CurrentVersion\Explorer\User Shell Folders`
+
+// shellFolders reads the (possibly redirected) AppData folders from the user's
+// registry hive. The hive is only loaded under HKEY_USERS while the user is
+// signed in; otherwise the default locations under the profile are used.
+func shellFolders(sid, homeDir string) (roaming, local string) {
+	k, err := registry.OpenKey(registry.USERS, sid+`\`+userShellFoldersKey, registry.QUERY_VALUE)
+	if err != nil {
+		return "", ""
+	}
+	defer k.Close()
+
+	vars := userVariables(sid, homeDir)
+	if value, _, err := k.GetStringValue("AppData"); err == nil {
+		roaming = expandUserVariables(value, vars)
+	}
+	if value, _, err := k.GetStringValue("Local AppData"); err == nil {
+		local = expandUserVariables(value, vars)
+	}
+	return roaming, local
+}
+
+// userVariables collects the user's own environment (HOMESHARE, HOMEDRIVE, ...)
+// from the loaded hive, which folder paths may refer to
+func userVariables(sid, homeDir string) map[string]string {
+	vars := map[string]string{"USERPROFILE": homeDir}
+	for _, sub := range []string{"Environment", "Volatile Environment"} {
+		k, err := registry.OpenKey(registry.USERS, sid+`\`+sub, registry.QUERY_VALUE)
+		if err != nil {
+			continue
+		}
+		names, _ := k.ReadValueNames(-1)
+		for _, name := range names {
+			if value, _, err := k.GetStringValue(name); err == nil {
+				vars[strings.ToUpper(name)] = value
+			}
+		}
+		k.Close()
+	}
+	return vars
+}
+
+// expandUserVariables expands %NAME% references using the user's variables,
+// then the process environment (e.g. %SystemDrive%)
+func expandUserVariables(value string, vars map[string]string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(value, "%")
+		if start == -1 {
+			break
+		}
+		end := strings.Index(value[start+1:], "%")
+		if end == -1 {
+			break
+		}
+		name := value[start+1 : start+1+end]
+		b.WriteString(value[:start])
+		if v, ok := vars[strings.ToUpper(name)]; ok {
+			b.WriteString(v)
+		} else if v, ok := os.LookupEnv(name); ok {
+			b.WriteString(v)
+		} else {
+			b.WriteString("%" + name + "%")
+		}
+		value = value[start+end+2:]
+	}
+	b.WriteString(value)
+	return b.String()
+}
+
+// lookupSID resolves a SID string to its account name and domain
+func lookupSID(sidString string) (string, string, error) {
+	sid, err := windows.StringToSid(sidString)
+	if err != nil {
+		return "", "", err
+	}
+
+	account, domain, _, err := sid.LookupAccount("")
+	if err != nil {
+		return "", "", err
+	}
+	return account, domain, nil
+}
+
+// usersDirUsers returns all users by scanning the Users directory
+func usersDirUsers() ([]User, error) {
 	var users []User
 
 	usersDir := os.Getenv("SYSTEMDRIVE") + "\\Users"
@@ -27,22 +207,13 @@ func getAllUsersImpl() ([]User, error) {
 		return nil, fmt.Errorf("failed to read Users directory: %w", err)
 	}
 
-	// System directories to skip
-	skipDirs := map[string]bool{
-		"Public":        true,
-		"Default":       true,
-		"Default User":  true,
-		"All Users":     true,
-		"desktop.ini":   true,
-	}
-
 	for _, entry := range entries {
 		if !entry.IsDir() {
 			continue
 		}
 
 		name := entry.Name()
-		if skipDirs[name] {
+		if windowsSkipDirs[name] {
 			continue
 		}
 
@@ -69,6 +240,11 @@ func getAllUsersImpl() ([]User, error) {
 	return users, nil
 }
 
+// isPrivilegedImpl reports whether the process token is elevated (Administrator or SYSTEM)
+func isPrivilegedImpl() bool {
+	return windows.GetCurrentProcessToken().IsElevated()
+}
+
 // getCurrentUserImpl returns the current user on Windows
 func getCurrentUserImpl() (*User, error) {
 	u, err := user.Current()
@@ -77,9 +253,9 @@ func getCurrentUserImpl() (*User, error) {
 	}
 
 	// On Windows, Username might be DOMAIN\username, extract just the username
-	username := u.Username
+	username, domain := u.Username, ""
 	if idx := strings.LastIndex(username, "\\"); idx != -1 {
-		username = username[idx+1:]
+		username, domain = username[idx+1:], username[:idx]
 	}
 
 	// Ensure home directory is absolute
@@ -95,5 +271,11 @@ func getCurrentUserImpl() (*User, error) {
 		Username: username,
 		HomeDir:  homeDir,
 		UID:      u.Uid,
+		SID:      u.Uid, // os/user reports the SID as Uid on Windows
+		Domain:   domain,
+
+		// The process environment already reflects folder redirection
+		RoamingAppData: os.Getenv("APPDATA"),
+		LocalAppData:   os.Getenv("LOCALAPPDATA"),
 	}, nil
 }
@@ -0,0 +1,66 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IsWSL reports whether the process runs inside Windows Subsystem for Linux
+func IsWSL() bool {
+	return isWSLImpl()
+}
+
+// GetWSLUsers returns the users on the other side of WSL: the Windows
+// profiles when running inside WSL, or the homes inside WSL distributions
+// when running on Windows. Their homes follow the other OS's layout (see
+// User.Layout).
+// This is implemented per-platform in wsl_*.go files
+func GetWSLUsers(currentUserOnly bool) ([]User, error) {
+	return getWSLUsersImpl(currentUserOnly)
+}
+
+// windowsSkipDirs are folders under C:\Users that are not user profiles
+var windowsSkipDirs = map[string]bool{
+	"Public":       true,
+	"Default":      true,
+	"Default User": true,
+	"All Users":    true,
+	"desktop.ini":  true,
+}
+
+// windowsProfiles returns the profiles in a Windows Users directory read
+// from another system (a mounted drive or disk image), with Windows layout
+func windowsProfiles(usersDir string) ([]User, error) {
+	entries, err := os.ReadDir(usersDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Windows Users directory: %w", err)
+	}
+
+	var users []User
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || windowsSkipDirs[name] || strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		homeDir := filepath.Join(usersDir, name)
+
+		// Verify it's a valid user profile (has NTUSER.DAT)
+		if _, err := os.Stat(filepath.Join(homeDir, "NTUSER.DAT")); err != nil {
+			continue
+		}
+
+		users = append(users, User{
+			Username: name,
+			HomeDir:  homeDir,
+			Layout:   Windows,
+		})
+	}
+
+	return users, nil
+}
@@ -0,0 +1,93 @@
+//go:build linux
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// isWSLImpl detects the WSL kernel ("...-microsoft-standard-WSL2")
+func isWSLImpl() bool {
+	data, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	return err == nil && strings.Contains(strings.ToLower(string(data)), "microsoft")
+}
+
+// getWSLUsersImpl lists the Windows profiles on the mounted system drive.
+// With currentUserOnly, only the profile of the Windows user running WSL
+// (asked via interop) is returned.
+func getWSLUsersImpl(currentUserOnly bool) ([]User, error) {
+	if !isWSLImpl() {
+		return nil, nil
+	}
+
+	driveDir := filepath.Join(wslMountRoot(), "c")
+	users, err := windowsProfiles(filepath.Join(driveDir, "Users"))
+	if err != nil || !currentUserOnly {
+		return users, err
+	}
+
+	only := windowsUsername(driveDir)
+	if only == "" {
+		return nil, fmt.Errorf("failed to determine the Windows user via interop")
+	}
+	for _, u := range users {
+		if strings.EqualFold(u.Username, only) {
+			return []User{u}, nil
+		}
+	}
+	return nil, nil
+}
+
+// wslMountRoot returns where Windows drives are mounted ([automount] root
+// in /etc/wsl.conf, default /mnt)
+func wslMountRoot() string {
+	root := "/mnt"
+
+	file, err := os.Open("/etc/wsl.conf")
+	if err != nil {
+		return root
+	}
+	defer file.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(line[1 : len(line)-1])
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if ok && section == "automount" && strings.TrimSpace(key) == "root" {
+			if value = strings.Trim(strings.TrimSpace(value), `"`); value != "" {
+				root = strings.TrimRight(value, "/")
+			}
+		}
+	}
+	return root
+}
+
+// windowsUsername asks Windows for the user running this WSL session. Interop
+// (running cmd.exe) is unavailable under some systemd/cron setups.
+func windowsUsername(driveDir string) string {
+	cmd := exec.Command("cmd.exe", "/c", "echo %USERNAME%")
+	cmd.Dir = driveDir // Avoid the UNC working directory warning
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+
+	name := strings.TrimSpace(string(output))
+	if strings.Contains(name, "%") {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,16 @@
+//go:build !linux && !windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+// isWSLImpl is always false outside Linux
+func isWSLImpl() bool {
+	return false
+}
+
+// getWSLUsersImpl returns no users; WSL only exists on Windows
+func getWSLUsersImpl(currentUserOnly bool) ([]User, error) {
+	return nil, nil
+}
@@ -0,0 +1,98 @@
+//go:build windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package platform
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// lxssKey lists a user's registered WSL distributions
+const lxssKey = `Software\Microsoft\Windows\CurrentVersion\Lxss`
+
+// isWSLImpl is always false on the Windows side
+func isWSLImpl() bool {
+	return false
+}
+
+// getWSLUsersImpl lists the homes inside the WSL distributions registered by
+// signed-in users (or the current user), reached through \\wsl.localhost.
+// Distributions are per-user and accessing one starts it; distributions that
+// cannot be reached are skipped.
+func getWSLUsersImpl(currentUserOnly bool) ([]User, error) {
+	var distros []string
+	if currentUserOnly {
+		distros = wslDistros(registry.CURRENT_USER, lxssKey)
+	} else {
+		k, err := registry.OpenKey(registry.USERS, "", registry.ENUMERATE_SUB_KEYS)
+		if err != nil {
+			return nil, err
+		}
+		sids, _ := k.ReadSubKeyNames(-1)
+		k.Close()
+
+		for _, sid := range sids {
+			if strings.HasSuffix(sid, "_Classes") {
+				continue
+			}
+			distros = append(distros, wslDistros(registry.USERS, sid+`\`+lxssKey)...)
+		}
+	}
+
+	var users []User
+	seen := make(map[string]bool)
+	for _, distro := range distros {
+		if seen[distro] {
+			continue
+		}
+		seen[distro] = true
+
+		homeRoot := `\\wsl.localhost\` + distro + `\home`
+		entries, err := os.ReadDir(homeRoot)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			users = append(users, User{
+				Username: entry.Name(),
+				HomeDir:  filepath.Join(homeRoot, entry.Name()),
+				Layout:   Linux,
+			})
+		}
+	}
+
+	return users, nil
+}
+
+// wslDistros reads the DistributionName of each registered distribution
+func wslDistros(root registry.Key, path string) []string {
+	k, err := registry.OpenKey(root, path, registry.ENUMERATE_SUB_KEYS)
+	if err != nil {
+		return nil
+	}
+	defer k.Close()
+
+	ids, _ := k.ReadSubKeyNames(-1)
+
+	var names []string
+	for _, id := range ids {
+		dk, err := registry.OpenKey(k, id, registry.QUERY_VALUE)
+		if err != nil {
+			continue
+		}
+		if name, _, err := dk.GetStringValue("DistributionName"); err == nil && name != "" {
+			names = append(names, name)
+		}
+		dk.Close()
+	}
+	return names
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package proxylog parses the access logs of forward proxies (Squid native
+// format and the Common/Combined Log Format) into requests and reads them
+// from a log file that may be rotated while it is followed
+package proxylog
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Log formats
+const (
+	FormatSquid  = "squid"  // Squid native access.log format
+	FormatCommon = "common" // Common or Combined Log Format
+)
+
+// Formats lists the supported formats
+var Formats = []string{FormatSquid, FormatCommon}
+
+// Request is one request of an access log
+type Request struct {
+	Time   time.Time
+	Client string // Client IP address (or host name)
+	User   string // Authenticated user, "" if none
+	URL    string // Absolute URL; CONNECT requests become https://host/
+}
+
+// Parse parses a log line of a format. ok is false for lines that are not
+// requests of a visited site: malformed lines, requests for proxy
+// authentication (407, repeated once authenticated) and relative URLs.
+func Parse(format, line string) (r Request, ok bool) {
+	switch format {
+	case FormatSquid:
+		return parseSquid(line)
+	case FormatCommon:
+		return parseCommon(line)
+	}
+	return Request{}, false
+}
+
+// parseSquid parses "time elapsed client action/code size method URL ident
+// hierarchy/peer type"
+func parseSquid(line string) (Request, bool) {
+	f := strings.Fields(line)
+	if len(f) < 7 || strings.HasSuffix(f[3], "/407") {
+		return Request{}, false
+	}
+	secs, err := strconv.ParseFloat(f[0], 64)
+	if err != nil {
+		return Request{}, false
+	}
+	r := Request{Time: time.UnixMilli(int64(secs * 1000)), Client: f[2]}
+	if len(f) > 7 {
+		r.User = user(f[7])
+	}
+	var ok bool
+	r.URL, ok = requestURL(f[5], f[6])
+	return r, ok
+}
+
+// commonLine matches 'host ident authuser [time] "method target proto" status'
+var commonLine = regexp.MustCompile(`^(\S+) (\S+) (\S+) \[([^\]]+)\] "(\S+) (\S+)[^"]*" (\d{3})`)
+
+// parseCommon parses a Common or Combined Log Format line
+func parseCommon(line string) (Request, bool) {
+	m := commonLine.FindStringSubmatch(line)
+	if m == nil || m[7] == "407" {
+		return Request{}, false
+	}
+	t, err := time.Parse("02/Jan/2006:15:04:05 -0700", m[4])
+	if err != nil {
+		return Request{}, false
+	}
+	r := Request{Time: t, Client: m[1], User: user(m[3])}
+	if r.User == "" {
+		r.User = user(m[2])
+	}
+	var ok bool
+	r.URL, ok = requestURL(m[5], m[6])
+	return r, ok
+}
+
+// user returns a logged user name, "" for "-"
+func user(field string) string {
+	if field == "-" {
+		return ""
+	}
+	return field
+}
+
+// requestURL returns the absolute URL of a request target; a CONNECT
+// tunnel's host:port becomes https://host/
+func requestURL(method, target string) (string, bool) {
+	if method == "CONNECT" {
+		host, port, err := net.SplitHostPort(target)
+		if err != nil || host == "" {
+			return "", false
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		return "https://" + host + "/", true
+	}
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		return "", false
+	}
+	return target, true
+}
+
+// Clients maps client addresses to user names, for clients of proxies
+// without authentication
+type Clients []clientRule
+
+// clientRule maps a network to a user name
+type clientRule struct {
+	prefix netip.Prefix
+	user   string
+}
+
+// ParseClients parses "ADDRESS=user" and "CIDR=user" entries
+func ParseClients(entries []string) (Clients, error) {
+	var c Clients
+	for _, e := range entries {
+		addr, name, ok := strings.Cut(strings.TrimSpace(e), "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("%q is not ADDRESS=user or CIDR=user", e)
+		}
+		prefix, err := netip.ParsePrefix(addr)
+		if err != nil {
+			ip, ipErr := netip.ParseAddr(addr)
+			if ipErr != nil {
+				return nil, fmt.Errorf("%q: invalid address or network", e)
+			}
+			prefix = netip.PrefixFrom(ip, ip.BitLen())
+		}
+		c = append(c, clientRule{prefix.Masked(), strings.TrimSpace(name)})
+	}
+	return c, nil
+}
+
+// User returns the user name of the most specific network containing a
+// client address, or ""
+func (c Clients) User(client string) string {
+	ip, err := netip.ParseAddr(client)
+	if err != nil {
+		return ""
+	}
+	ip = ip.Unmap()
+	best, bits := "", -1
+	for _, r := range c {
+		if r.prefix.Contains(ip) && r.prefix.Bits() > bits {
+			best, bits = r.user, r.prefix.Bits()
+		}
+	}
+	return best
+}
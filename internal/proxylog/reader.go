@@ -0,0 +1,147 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package proxylog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// maxLineSize bounds a log line; longer lines are skipped
+const maxLineSize = 64 << 10
+
+// Position is where reading a log file stopped
+type Position struct {
+	ID     string // SHA-256 of the first line, which changes when the log is rotated
+	Offset int64  // Bytes of the complete lines read
+}
+
+// Reader reads the complete lines of a log file from a position
+type Reader struct {
+	path string
+	f    *os.File
+	r    *bufio.Reader
+	pos  Position
+	long bool // Skipping the rest of a line longer than maxLineSize
+}
+
+// Open opens a log file at a position. A position of another file (the log
+// was rotated) or beyond its end (truncated) starts from the beginning, and
+// restarted is set.
+func Open(path string, pos Position) (rd *Reader, restarted bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to open log: %w", err)
+	}
+	rd = &Reader{path: path, f: f}
+	id := rd.firstLineID()
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, false, fmt.Errorf("failed to stat log: %w", err)
+	}
+	if pos.Offset > info.Size() || (pos.ID != "" && id != "" && id != pos.ID) {
+		restarted = pos.Offset > 0
+		pos = Position{}
+	}
+	pos.ID = id
+	if err := rd.Reset(pos); err != nil {
+		f.Close()
+		return nil, false, err
+	}
+	return rd, restarted, nil
+}
+
+// firstLineID returns the ID of the file, or "" while its first line is incomplete
+func (rd *Reader) firstLineID() string {
+	buf := make([]byte, maxLineSize)
+	n, _ := rd.f.ReadAt(buf, 0)
+	line, _, ok := bytes.Cut(buf[:n], []byte("\n"))
+	if !ok {
+		return ""
+	}
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:])
+}
+
+// Reset continues reading at a position of the same file, e.g. to read
+// lines again whose visits could not be sent
+func (rd *Reader) Reset(pos Position) error {
+	if _, err := rd.f.Seek(pos.Offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek log: %w", err)
+	}
+	rd.r = bufio.NewReaderSize(rd.f, maxLineSize)
+	rd.pos = pos
+	return nil
+}
+
+// Position returns the position after the lines read
+func (rd *Reader) Position() Position {
+	return rd.pos
+}
+
+// ReadLines returns up to n complete lines. A line still being written is
+// left for a later call; at the end of the file fewer lines are returned.
+func (rd *Reader) ReadLines(n int) ([]string, error) {
+	var lines []string
+	for len(lines) < n {
+		line, err := rd.r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			// Too long to be a request; skip it up to its newline
+			rd.pos.Offset += int64(len(line))
+			rd.long = true
+			continue
+		}
+		if err == io.EOF {
+			// Read the partial line again once it is complete
+			return lines, rd.Reset(rd.pos)
+		}
+		if err != nil {
+			return lines, fmt.Errorf("failed to read log: %w", err)
+		}
+		rd.pos.Offset += int64(len(line))
+		if rd.long {
+			rd.long = false
+			continue
+		}
+		if rd.pos.ID == "" {
+			rd.pos.ID = rd.firstLineID()
+		}
+		lines = append(lines, string(bytes.TrimRight(line, "\r\n")))
+	}
+	return lines, nil
+}
+
+// Rotated reports whether the path now names another file (the log was
+// rotated) or the file was truncated. The rest of a rotated file should be
+// read before the path is opened again.
+func (rd *Reader) Rotated() (bool, error) {
+	current, err := rd.f.Stat()
+	if err != nil {
+		return false, fmt.Errorf("failed to stat log: %w", err)
+	}
+	if current.Size() < rd.pos.Offset {
+		return true, nil
+	}
+	if id := rd.firstLineID(); id != "" && rd.pos.ID != "" && id != rd.pos.ID {
+		// Truncated and written again
+		return true, nil
+	}
+	info, err := os.Stat(rd.path)
+	if err != nil {
+		// Moved away and not recreated yet
+		return false, nil
+	}
+	return !os.SameFile(info, current), nil
+}
+
+// Close closes the log file
+func (rd *Reader) Close() error {
+	return rd.f.Close()
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package retention limits how much data the scanner leaves on an endpoint:
+// files older than a maximum age are removed, then the oldest ones until the
+// rest fit in a maximum size
+package retention
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Policy limits the age and total size of a set of files
+type Policy struct {
+	MaxAge  time.Duration // Files last modified before this are removed; 0 keeps them
+	MaxSize int64         // Total bytes kept; 0 means no limit
+}
+
+// Removed counts the files removed by Prune
+type Removed struct {
+	Files int
+	Bytes int64
+}
+
+// file is a candidate for removal
+type file struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// Walk returns the regular files under a directory. Symlinks are not
+// followed, so a link cannot make Prune remove files elsewhere.
+func Walk(dir string) []string {
+	var files []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files
+}
+
+// Prune applies a policy to files. Files that cannot be removed are skipped;
+// the first error is returned with the counts of what was removed.
+func Prune(paths []string, p Policy, now time.Time) (Removed, error) {
+	var files []file
+	for _, path := range paths {
+		if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() {
+			files = append(files, file{path, info.Size(), info.ModTime()})
+		}
+	}
+	// Newest first, so the oldest are beyond the size limit
+	slices.SortFunc(files, func(a, b file) int { return b.modTime.Compare(a.modTime) })
+
+	var removed Removed
+	var firstErr error
+	var kept int64
+	full := false
+	for _, f := range files {
+		expired := p.MaxAge > 0 && now.Sub(f.modTime) > p.MaxAge
+		full = full || (p.MaxSize > 0 && kept+f.size > p.MaxSize)
+		if !expired && !full {
+			kept += f.size
+			continue
+		}
+		if err := os.Remove(f.path); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		removed.Files++
+		removed.Bytes += f.size
+	}
+	return removed, firstErr
+}
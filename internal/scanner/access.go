@@ -0,0 +1,92 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+
+	"hist_scanner/internal/browser"
+	"hist_scanner/internal/platform"
+)
+
+// Access statuses of a user's home or browser data directory
+const (
+	AccessOK         = "ok"
+	AccessDenied     = "permission-denied"
+	AccessNotPresent = "not-present"
+)
+
+// AccessDiagnostic is the result of the permission pre-flight for one
+// user's home (Browser empty) or one browser data directory
+type AccessDiagnostic struct {
+	Username string
+	Browser  string
+	Path     string
+	Status   string // One of the Access* statuses
+}
+
+// CheckAccess classifies whether the scanner can read a user's browser data.
+// If the home itself cannot be listed only the home is reported, since every
+// browser directory below it is unreadable for the same reason.
+func CheckAccess(user platform.User, browsers []browser.Browser) []AccessDiagnostic {
+	if user.HomeDir != "" {
+		if status := accessStatus(user.HomeDir); status != AccessOK {
+			return []AccessDiagnostic{{Username: user.Username, Path: user.HomeDir, Status: status}}
+		}
+	}
+
+	var diags []AccessDiagnostic
+	for _, b := range browsers {
+		d, ok := b.(browser.DataDirer)
+		if !ok {
+			continue
+		}
+		dir := d.DataDir(user)
+		if dir == "" {
+			continue
+		}
+		diags = append(diags, AccessDiagnostic{
+			Username: user.Username,
+			Browser:  b.Name(),
+			Path:     dir,
+			Status:   accessStatus(dir),
+		})
+	}
+	return diags
+}
+
+// accessStatus lists dir to tell an unreadable directory from a missing one.
+// Stat alone succeeds on directories that cannot be listed.
+func accessStatus(dir string) string {
+	f, err := os.Open(dir)
+	if err == nil {
+		_, err = f.Readdirnames(1)
+		f.Close()
+	}
+	switch {
+	case err == nil || errors.Is(err, io.EOF):
+		return AccessOK
+	case errors.Is(err, fs.ErrPermission):
+		return AccessDenied
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, syscall.ENOTDIR):
+		return AccessNotPresent
+	default:
+		// Other read errors leave the data just as unreadable
+		return AccessDenied
+	}
+}
+
+// hasDenied reports whether any diagnostic is permission-denied
+func hasDenied(diags []AccessDiagnostic) bool {
+	for _, d := range diags {
+		if d.Status == AccessDenied {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"hist_scanner/internal/browser"
+	"hist_scanner/internal/catalog"
+	"hist_scanner/internal/dto"
+	"hist_scanner/internal/platform"
+)
+
+// domainAggregate counts the visits of a run to one registrable domain
+type domainAggregate struct {
+	dto.DomainAggregateDTO
+	users map[string]bool // Principal names already in Users
+}
+
+// pendingPosition is a profile's scan position reached by visits that were
+// only aggregated; it is stored once the aggregates are sent
+type pendingPosition struct {
+	user                   platform.User
+	browser                browser.Browser
+	profile                browser.Profile
+	maxTimestamp, maxRowID int64
+	fingerprint            *browser.Fingerprint // History fingerprint of a completed scan
+}
+
+// negotiatePayload decides whether this run sends visits, aggregates or
+// both. In auto mode the server is asked whether it accepts aggregates; if
+// it cannot be asked, visits are sent.
+func (s *Scanner) negotiatePayload() {
+	s.aggregates, s.pending = make(map[string]*domainAggregate), nil
+	switch s.cfg.PayloadFormat {
+	case "aggregates":
+		s.sendVisits, s.sendAggregates = false, true
+	case "both":
+		s.sendVisits, s.sendAggregates = true, true
+	case "auto":
+		s.sendVisits, s.sendAggregates = true, false
+		if s.dryRun {
+			return
+		}
+		ok, err := s.client.NegotiateAggregates()
+		if err != nil {
+			s.logger.Warnf("failed to negotiate the payload format, sending visits: %v", err)
+			return
+		}
+		s.sendVisits, s.sendAggregates = !ok, ok
+		s.logger.Debugf("Server accepts aggregates: %t", ok)
+	default:
+		s.sendVisits, s.sendAggregates = true, false
+	}
+}
+
+// aggregate adds a batch of a user's visits to the run's domain aggregates
+func (s *Scanner) aggregate(user platform.User, entries []dto.VisitedSite) {
+	principal := s.principal(user)
+	for _, e := range entries {
+		u, err := url.Parse(e.URL)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		domain := catalog.RegistrableDomain(u.Hostname())
+		a, ok := s.aggregates[domain]
+		if !ok {
+			a = &domainAggregate{users: make(map[string]bool)}
+			a.Domain, a.FirstSeen, a.LastSeen = domain, e.Timestamp, e.Timestamp
+			s.aggregates[domain] = a
+		}
+		a.VisitCount++
+		a.FirstSeen = min(a.FirstSeen, e.Timestamp)
+		a.LastSeen = max(a.LastSeen, e.Timestamp)
+		if a.App == "" && e.App != "" {
+			a.App, a.AppCategory, a.Risk = e.App, e.AppCategory, e.Risk
+		}
+		if !a.users[principal.Name] {
+			a.users[principal.Name] = true
+			a.Users = append(a.Users, principal)
+		}
+	}
+}
+
+// deferPosition records the scan position reached by aggregated visits
+func (s *Scanner) deferPosition(user platform.User, b browser.Browser, profile browser.Profile, maxTimestamp, maxRowID int64) {
+	if s.dryRun || s.ranged || s.origin != nil {
+		return
+	}
+	s.pending = append(s.pending, pendingPosition{user: user, browser: b, profile: profile, maxTimestamp: maxTimestamp, maxRowID: maxRowID})
+}
+
+// flushAggregates sends the run's domain aggregates (or prints them in dry
+// run) and then stores the scan positions deferred until they were sent
+func (s *Scanner) flushAggregates() error {
+	if !s.sendAggregates || len(s.aggregates) == 0 {
+		return nil
+	}
+	payload := dto.DomainAggregatesDTO{
+		Version: dto.AggregatesVersion,
+		ScanID:  s.scanID,
+		Source:  s.cfg.Source,
+		Device:  s.deviceInfo(),
+	}
+	for _, a := range s.aggregates {
+		if seen, ok := s.state.GetDomain(a.Domain); ok && s.cfg.TrackDomains {
+			a.DeviceFirstSeen = seen.First
+		}
+		payload.Domains = append(payload.Domains, a.DomainAggregateDTO)
+	}
+	slices.SortFunc(payload.Domains, func(a, b dto.DomainAggregateDTO) int { return cmp.Compare(a.Domain, b.Domain) })
+
+	if s.dryRun && s.aggregatesOutput != nil {
+		return s.aggregatesOutput(payload)
+	}
+	if s.dryRun {
+		data, err := json.MarshalIndent(payload, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if err := s.client.SendAggregates(payload); err != nil {
+		return fmt.Errorf("failed to send domain aggregates: %w", err)
+	}
+	s.logger.Infof("Sent visit counts of %d domains", len(payload.Domains))
+	s.state.SetLastSend(time.Now())
+	for _, p := range s.pending {
+		s.advancePosition(p.user, p.browser, p.profile, p.maxTimestamp, p.maxRowID)
+		if p.fingerprint != nil {
+			s.state.SetFingerprint(stateUser(p.user), p.browser.Name(), p.profile.Name, p.fingerprint.ID, p.fingerprint.MaxRowID, p.fingerprint.Rows)
+		}
+	}
+	// A followed proxy log flushes its aggregates once per poll
+	s.aggregates, s.pending = make(map[string]*domainAggregate), nil
+	return nil
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"hist_scanner/internal/browser"
+	"hist_scanner/internal/catalog"
+	"hist_scanner/internal/dto"
+	"hist_scanner/internal/platform"
+)
+
+// Event kinds of ai_tools
+const (
+	AIToolFirstUse = "ai-tool"  // A user's first use of an AI tool (escalate)
+	AIUsage        = "ai-usage" // A user's AI tool visits in a scan (aggregate, escalate)
+)
+
+// aiUsage counts a user's visits to AI tools in a scan
+type aiUsage struct {
+	user   platform.User
+	visits map[string]int // By service name
+	risks  map[string]string
+}
+
+// countAITools counts the visits of a batch to AI tools and, with
+// ai_tools: escalate, reports the tools the user never used before. A first
+// use is recorded in state once it was logged and, with events_url, posted;
+// a failed post is retried at the next visit.
+func (s *Scanner) countAITools(user platform.User, b browser.Browser, profile browser.Profile, entries []dto.VisitedSite) {
+	if !s.countsAITools() || s.catalog == nil || s.dryRun {
+		return
+	}
+
+	key := stateUser(user)
+	usage := s.aiUsage[key]
+	if usage == nil {
+		usage = &aiUsage{user: user, visits: make(map[string]int), risks: make(map[string]string)}
+		s.aiUsage[key] = usage
+	}
+	type firstUse struct {
+		service *catalog.Service
+		domain  string
+		time    int64
+	}
+	first := make(map[string]*firstUse)
+	var order []string
+	for _, e := range entries {
+		u, err := url.Parse(e.URL)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		service, ok := s.catalog.Lookup(u.Hostname())
+		if !ok || service.Category != catalog.AITool {
+			continue
+		}
+		usage.visits[service.Name]++
+		usage.risks[service.Name] = service.Risk
+
+		if s.cfg.AITools != "escalate" {
+			continue
+		}
+		if f, ok := first[service.Name]; ok {
+			f.time = min(f.time, e.Timestamp)
+			continue
+		}
+		if !s.state.IsAIToolReported(key, service.Name) {
+			first[service.Name] = &firstUse{service: service, domain: catalog.RegistrableDomain(u.Hostname()), time: e.Timestamp}
+			order = append(order, service.Name)
+		}
+	}
+
+	for _, name := range order {
+		f := first[name]
+		s.reportAIToolFirstUse(user, b, profile, f.service, f.domain, f.time)
+	}
+}
+
+// countsAITools reports whether ai_tools reports more than tags
+func (s *Scanner) countsAITools() bool {
+	return s.cfg.AITools == "aggregate" || s.cfg.AITools == "escalate"
+}
+
+// reportAIToolFirstUse logs a user's first use of an AI tool and posts it
+// to the events endpoint, if one is configured
+func (s *Scanner) reportAIToolFirstUse(user platform.User, b browser.Browser, profile browser.Profile, service *catalog.Service, domain string, visitTime int64) {
+	s.logger.With("user", user.Username, "browser", b.Name(), "profile", profile.Name, "app", service.Name,
+		"risk", service.Risk, "priority", highPriority, "ai_event", AIToolFirstUse).
+		Warnf("  %s/%s: first use of AI tool %s", b.Name(), profile.Name, service.Name)
+
+	if s.cfg.EventsURL != "" {
+		principal := dto.NewUserPrincipal(user.Username)
+		principal.Identity = s.identity(user)
+		hostname := platform.Hostname()
+		event := dto.AIToolEventDTO{
+			ScanID:    s.scanID,
+			Source:    s.cfg.Source,
+			Host:      hostname,
+			DeviceID:  s.deviceInfo().ID,
+			Principal: principal,
+			Browser:   b.Name(),
+			Profile:   profile.Name,
+			Time:      time.Now().UnixMilli(),
+			Kind:      AIToolFirstUse,
+			Priority:  highPriority,
+			App:       service.Name,
+			Risk:      service.Risk,
+			Domain:    domain,
+			VisitTime: visitTime,
+		}
+		if err := s.client.SendAIToolEvent(s.cfg.EventsURL, event); err != nil {
+			s.logger.Warnf("failed to send AI tool event: %v", err)
+			return
+		}
+	}
+	s.state.SetAIToolReported(stateUser(user), service.Name, visitTime)
+}
+
+// reportAIUsage logs the AI tool visits of each user in the scan and posts
+// them to the events endpoint, if one is configured. A failed post is only
+// logged.
+func (s *Scanner) reportAIUsage() {
+	keys := make([]string, 0, len(s.aiUsage))
+	for key, usage := range s.aiUsage {
+		if len(usage.visits) > 0 {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	hostname := platform.Hostname()
+	for _, key := range keys {
+		usage := s.aiUsage[key]
+		event := dto.AIUsageDTO{
+			ScanID:   s.scanID,
+			Source:   s.cfg.Source,
+			Host:     hostname,
+			DeviceID: s.deviceInfo().ID,
+			Time:     time.Now().UnixMilli(),
+			Kind:     AIUsage,
+		}
+		for name, n := range usage.visits {
+			event.Tools = append(event.Tools, dto.AIToolVisits{App: name, Risk: usage.risks[name], Visits: n})
+			event.Visits += n
+		}
+		slices.SortFunc(event.Tools, func(a, b dto.AIToolVisits) int {
+			if a.Visits != b.Visits {
+				return b.Visits - a.Visits
+			}
+			return strings.Compare(a.App, b.App)
+		})
+
+		parts := make([]string, len(event.Tools))
+		for i, t := range event.Tools {
+			parts[i] = fmt.Sprintf("%s %d", t.App, t.Visits)
+		}
+		s.logger.With("user", usage.user.Username, "ai_visits", event.Visits, "ai_event", AIUsage).
+			Infof("AI tools of %s: %d visits (%s)", usage.user.Username, event.Visits, strings.Join(parts, ", "))
+
+		if s.cfg.EventsURL == "" {
+			continue
+		}
+		event.Principal = dto.NewUserPrincipal(usage.user.Username)
+		event.Principal.Identity = s.identity(usage.user)
+		if err := s.client.SendAIUsage(s.cfg.EventsURL, event); err != nil {
+			s.logger.Warnf("failed to send AI usage event: %v", err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"time"
+
+	"hist_scanner/internal/catalog"
+	"hist_scanner/internal/dto"
+)
+
+// loadCatalog loads the SaaS catalog that tags sent visits, limits
+// discovered services and finds AI tools. If catalog_file cannot be read,
+// the built-in catalog is used.
+func (s *Scanner) loadCatalog() {
+	s.catalog, s.aiUsage = nil, make(map[string]*aiUsage)
+	if !s.cfg.TagServices && s.cfg.DiscoverServices != "catalog" && !s.countsAITools() {
+		return
+	}
+	c, err := catalog.Load(s.bundle, s.cfg.CatalogFile)
+	if err != nil {
+		s.logger.Warnf("%v; using the built-in catalog", err)
+		c, _ = catalog.Load(s.bundle, "")
+	}
+	s.catalog = c
+}
+
+// loadBundle loads the signed catalog bundle of catalog_url. It is
+// downloaded at most once per listRefresh; while it is unreachable, or
+// serves a bundle with an invalid signature or an older version, the cached
+// bundle is used. Without any valid bundle, the built-in catalog and lists
+// are used.
+func (s *Scanner) loadBundle() {
+	s.bundle = nil
+	if s.cfg.CatalogURL == "" {
+		return
+	}
+	key, err := catalog.ParsePublicKey(s.cfg.CatalogPublicKey)
+	if err != nil {
+		s.logger.Warnf("%v; using the built-in catalog", err)
+		return
+	}
+
+	cache := catalog.BundleCache(s.state.StateDir())
+	cached, cacheErr := catalog.ReadBundle(key, cache)
+	if cacheErr != nil && !errors.Is(cacheErr, os.ErrNotExist) {
+		s.logger.Warnf("ignoring the cached catalog bundle: %v", cacheErr)
+	}
+	if info, err := os.Stat(cache); err == nil && cacheErr == nil && time.Since(info.ModTime()) <= listRefresh {
+		s.bundle = cached
+		return
+	}
+
+	var minVersion int64
+	if cached != nil {
+		minVersion = cached.Version
+	}
+	b, err := catalog.DownloadBundle(key, s.cfg.CatalogURL, cache, minVersion, s.cfg.Timeout)
+	switch {
+	case err == nil:
+		if cached == nil || b.Version != cached.Version {
+			s.logger.Infof("Catalog bundle version %d: %d services, %d category lists", b.Version, len(b.Services), len(b.Categories))
+		}
+		s.bundle = b
+	case cached != nil:
+		s.logger.Warnf("%v; using the cached catalog bundle version %d", err, cached.Version)
+		s.bundle = cached
+	default:
+		s.logger.Warnf("%v; using the built-in catalog", err)
+	}
+}
+
+// tagServices tags visits with the SaaS service of their host
+func (s *Scanner) tagServices(entries []dto.VisitedSite) {
+	if s.catalog == nil || !s.cfg.TagServices {
+		return
+	}
+	for i := range entries {
+		u, err := url.Parse(entries[i].URL)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		if service, ok := s.catalog.Lookup(u.Hostname()); ok {
+			entries[i].App = service.Name
+			entries[i].AppCategory = service.Category
+			entries[i].Risk = service.Risk
+		}
+	}
+}
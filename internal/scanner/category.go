@@ -0,0 +1,182 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"hist_scanner/internal/browser"
+	"hist_scanner/internal/category"
+	"hist_scanner/internal/dto"
+	"hist_scanner/internal/platform"
+)
+
+// listRefresh is how long a downloaded category list is used before it is
+// downloaded again
+const listRefresh = 24 * time.Hour
+
+// loadCategories loads the lists of exclude_categories. A hosted list is
+// downloaded at most once per listRefresh; while its source is unreachable
+// the cached copy, then the bundled list is used. A category without any
+// list fails the run, so its visits are never sent unfiltered.
+func (s *Scanner) loadCategories() error {
+	s.exclude, s.dropped = nil, make(map[string]int)
+	if len(s.cfg.ExcludeCategories) == 0 {
+		return nil
+	}
+	sources, err := category.ParseSources(s.cfg.CategoryLists)
+	if err != nil {
+		return fmt.Errorf("category_lists: %w", err)
+	}
+
+	set := &category.Set{}
+	for _, name := range s.cfg.ExcludeCategories {
+		name = strings.ToLower(strings.TrimSpace(name))
+		l, err := s.loadCategory(name, sources[name])
+		if err != nil {
+			return fmt.Errorf("category %s: %w; nothing was sent", name, err)
+		}
+		s.logger.Debugf("Category %s: %d domains", name, len(l))
+		set.Add(name, l)
+	}
+	s.exclude = set
+	return nil
+}
+
+// loadCategory returns the list of one category from its source, falling
+// back to the last download, the catalog bundle and the bundled list
+func (s *Scanner) loadCategory(name, source string) (category.List, error) {
+	var err error
+	switch {
+	case source == "":
+		if l, ok := s.bundleList(name); ok {
+			return l, nil
+		}
+		if l, ok := category.BundledList(name); ok {
+			return l, nil
+		}
+		return nil, fmt.Errorf("no list")
+	case category.IsURL(source):
+		cached := filepath.Join(s.state.StateDir(), "lists", name+".txt")
+		info, statErr := os.Stat(cached)
+		if statErr != nil || time.Since(info.ModTime()) > listRefresh {
+			err = category.Download(source, cached, s.cfg.Timeout)
+		}
+		l, readErr := category.ReadFile(cached)
+		if readErr == nil {
+			if err != nil {
+				s.logger.Warnf("category %s: using the list downloaded on %s: %v", name, info.ModTime().Format(time.DateOnly), err)
+			}
+			return l, nil
+		}
+		if err == nil {
+			err = readErr
+		}
+	default:
+		l, readErr := category.ReadFile(source)
+		if readErr == nil {
+			return l, nil
+		}
+		err = readErr
+	}
+
+	if l, ok := s.bundleList(name); ok {
+		s.logger.Warnf("category %s: using the list of the catalog bundle: %v", name, err)
+		return l, nil
+	}
+	if l, ok := category.BundledList(name); ok {
+		s.logger.Warnf("category %s: using the bundled list: %v", name, err)
+		return l, nil
+	}
+	return nil, err
+}
+
+// bundleList returns the list of a category in the catalog bundle
+func (s *Scanner) bundleList(name string) (category.List, bool) {
+	if s.bundle == nil {
+		return nil, false
+	}
+	domains, ok := s.bundle.Categories[name]
+	if !ok {
+		return nil, false
+	}
+	l, err := category.Parse(strings.NewReader(strings.Join(domains, "\n")))
+	return l, err == nil && len(l) > 0
+}
+
+// dropExcluded removes the visits to excluded schemes, destinations and
+// sites of excluded categories from a batch, counting them per reason. It
+// returns the kept entries and the highest timestamp and row id of the
+// dropped ones.
+func (s *Scanner) dropExcluded(entries []dto.VisitedSite) ([]dto.VisitedSite, int64, int64) {
+	if s.exclude == nil && s.destinations == nil {
+		return entries, 0, 0
+	}
+	kept := entries[:0:0]
+	var maxTimestamp, maxRowID int64
+	for _, e := range entries {
+		reason := s.dropReason(e.URL)
+		if reason == "" {
+			kept = append(kept, e)
+			continue
+		}
+		s.dropped[reason]++
+		maxTimestamp = max(maxTimestamp, e.Timestamp)
+		maxRowID = max(maxRowID, e.RowID)
+	}
+	return kept, maxTimestamp, maxRowID
+}
+
+// dropReason returns the destination reason or category a visit is dropped
+// for, or "" if it is sent
+func (s *Scanner) dropReason(rawURL string) string {
+	if reason := s.destinations.reason(rawURL); reason != "" {
+		return reason
+	}
+	if s.exclude == nil {
+		return ""
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	name, _ := s.exclude.Match(u.Hostname())
+	return name
+}
+
+// advancePosition moves a profile's scan position past sent or dropped entries
+func (s *Scanner) advancePosition(user platform.User, b browser.Browser, profile browser.Profile, maxTimestamp, maxRowID int64) {
+	if maxTimestamp > s.state.GetLastTimestamp(stateUser(user), b.Name(), profile.Name) {
+		s.state.SetLastTimestamp(stateUser(user), b.Name(), profile.Name, maxTimestamp)
+	}
+	if maxRowID > s.state.GetLastRowID(stateUser(user), b.Name(), profile.Name) {
+		s.state.SetLastRowID(stateUser(user), b.Name(), profile.Name, maxRowID)
+	}
+}
+
+// logDropped logs how many visits were dropped per excluded destination
+// reason and category
+func (s *Scanner) logDropped(result *ScanResult) {
+	if len(result.Dropped) == 0 {
+		return
+	}
+	names := []string{dropScheme, dropPrivate, dropInternal}
+	if s.exclude != nil {
+		names = append(names, s.exclude.Names()...)
+	}
+	var parts []string
+	total := 0
+	for _, name := range names {
+		if n := result.Dropped[name]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", name, n))
+			total += n
+		}
+	}
+	s.logger.With("dropped", total).Infof("Dropped %d visits to excluded destinations: %s", total, strings.Join(parts, ", "))
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"slices"
+	"time"
+
+	"hist_scanner/internal/dto"
+)
+
+// clockTolerance is the clock skew left uncorrected and how far ahead of
+// the server's time a visit may be before it is flagged: Date headers have
+// a resolution of a second and clocks kept by NTP differ by far less
+const clockTolerance = 2 * time.Minute
+
+// measureClock measures the device clock against the server's before the
+// first upload of a scan and warns about a skew beyond clockTolerance
+func (s *Scanner) measureClock() {
+	if s.cfg.ClockSkew == "off" || s.client == nil || s.cfg.ServerURL == "" {
+		return
+	}
+	if err := s.client.MeasureClock(); err != nil {
+		s.logger.Warnf("failed to measure the device clock against the server's: %v", err)
+		return
+	}
+	skew, _ := s.client.ClockSkew()
+	if skew.Abs() <= clockTolerance {
+		s.logger.Debugf("Clock skew to the server: %s", skew)
+		return
+	}
+	direction := "behind"
+	if skew < 0 {
+		direction = "ahead of"
+	}
+	action := "timestamps are sent as recorded"
+	if s.cfg.ClockSkew == "correct" {
+		action = "timestamps recorded by this device are corrected"
+	}
+	s.logger.Warnf("device clock is %s %s the server's, %s", skew.Abs().Round(time.Second), direction, action)
+}
+
+// normalizeTimestamps returns the visits of a batch as sent with the clock
+// annotation of their payload and the shift added to their timestamps.
+// Timestamps are shifted to the server's clock with clock_skew correct, and
+// visits later than the server's time are flagged with flag_future_visits.
+// Visits recorded on another machine (imports and disk images) are not
+// shifted. entries keep the device's timestamps, which scan positions are
+// kept in.
+func (s *Scanner) normalizeTimestamps(entries []dto.VisitedSite, local bool) ([]dto.VisitedSite, *dto.ClockDTO, int64) {
+	var clock *dto.ClockDTO
+	var skew, shift int64
+	if s.client != nil && s.cfg.ClockSkew != "off" {
+		if d, ok := s.client.ClockSkew(); ok {
+			skew = d.Milliseconds()
+			clock = &dto.ClockDTO{SkewMs: skew}
+			if s.cfg.ClockSkew == "correct" && local && d.Abs() > clockTolerance {
+				clock.Corrected, shift = true, skew
+			}
+		}
+	}
+	if shift == 0 && !s.cfg.FlagFutureVisits {
+		return entries, clock, 0
+	}
+
+	latest := time.Now().Add(clockTolerance).UnixMilli() + skew
+	visits, future := slices.Clone(entries), 0
+	for i := range visits {
+		visits[i].Timestamp += shift
+		if s.cfg.FlagFutureVisits && visits[i].Timestamp > latest {
+			visits[i].Future = true
+			future++
+		}
+	}
+	if future > 0 {
+		s.logger.Warnf("%d visits are later than the server's time; the device clock was ahead when they were made", future)
+	}
+	return visits, clock, shift
+}
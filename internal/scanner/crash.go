@@ -0,0 +1,98 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"hist_scanner/internal/dto"
+	"hist_scanner/internal/platform"
+)
+
+// Error report kinds
+const (
+	errorKindPanic = "panic" // The scan panicked and was recovered
+	errorKindFatal = "fatal" // The scan finished without sending anything
+)
+
+// Size limits of error report fields
+const (
+	maxReportMessage = 1 << 10
+	maxReportStack   = 16 << 10
+)
+
+// reportURLRe matches URLs, which are removed from error reports in case a
+// message quotes history data
+var reportURLRe = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+
+// safeScan runs scan, turning a panic into a failed result. The panic is
+// logged with its stack and reported to the error endpoint.
+func (s *Scanner) safeScan() (result *ScanResult, panicked bool) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		msg := fmt.Sprint(r)
+		stack := string(debug.Stack())
+		s.logger.With("category", errorKindPanic).Errorf("internal error: %s\n%s", msg, stack)
+		s.reportError(errorKindPanic, msg, stack)
+
+		result = &ScanResult{
+			ExitCode: ExitCompleteFailure,
+			Errors:   []string{"internal error: " + sanitizeReport(msg, maxReportMessage)},
+		}
+		panicked = true
+	}()
+	return s.scan(), false
+}
+
+// reportFatal reports a scan that sent nothing to the error endpoint
+func (s *Scanner) reportFatal(result *ScanResult) {
+	msg := "nothing was scanned"
+	if len(result.Errors) > 0 {
+		msg = strings.Join(truncateErrors(result.Errors), "; ")
+	}
+	s.reportError(errorKindFatal, msg, "")
+}
+
+// reportError posts a sanitized error event to the error endpoint, if one is
+// configured. The event has already been logged; a failed post is only logged.
+func (s *Scanner) reportError(kind, msg, stack string) {
+	if s.dryRun || s.cfg.ErrorURL == "" {
+		return
+	}
+
+	hostname := platform.Hostname()
+	report := dto.ErrorReportDTO{
+		ScanID:         s.scanID,
+		Source:         s.cfg.Source,
+		Host:           hostname,
+		DeviceID:       s.deviceInfo().ID,
+		ScannerVersion: s.version,
+		Scanner:        s.build(),
+		OS:             runtime.GOOS,
+		Time:           time.Now().UnixMilli(),
+		Kind:           kind,
+		Message:        sanitizeReport(msg, maxReportMessage),
+		Stack:          sanitizeReport(stack, maxReportStack),
+	}
+	if err := s.client.SendErrorReport(s.cfg.ErrorURL, report); err != nil {
+		s.logger.Warnf("failed to send error report: %v", err)
+	}
+}
+
+// sanitizeReport removes URLs from a report field and truncates it to max bytes
+func sanitizeReport(s string, max int) string {
+	s = reportURLRe.ReplaceAllString(s, "<url>")
+	if len(s) > max {
+		s = strings.ToValidUTF8(s[:max], "") + "..."
+	}
+	return s
+}
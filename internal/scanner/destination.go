@@ -0,0 +1,98 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+
+	"hist_scanner/internal/category"
+	"hist_scanner/internal/config"
+)
+
+// Reasons visits are dropped by destination, counted like excluded categories
+const (
+	dropScheme   = "scheme"
+	dropPrivate  = "private-address"
+	dropInternal = "internal-domain"
+)
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which
+// netip does not count as private
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// destinationFilter drops visits by URL scheme and destination host
+type destinationFilter struct {
+	schemes  map[string]bool // Sent schemes, nil when all are sent
+	private  bool            // Drop local addresses and intranet hosts
+	internal category.List   // internal_domains, nil when none
+}
+
+// newDestinationFilter returns the filter of allowed_schemes,
+// drop_private_addresses and internal_domains, or nil if nothing is dropped
+func newDestinationFilter(cfg *config.Config) *destinationFilter {
+	f := &destinationFilter{private: cfg.DropPrivateAddresses}
+	if len(cfg.AllowedSchemes) > 0 && !slices.Contains(cfg.AllowedSchemes, "*") {
+		f.schemes = make(map[string]bool)
+		for _, scheme := range cfg.AllowedSchemes {
+			f.schemes[strings.TrimSuffix(strings.ToLower(strings.TrimSpace(scheme)), ":")] = true
+		}
+	}
+	for _, domain := range cfg.InternalDomains {
+		if f.internal == nil {
+			f.internal = make(category.List)
+		}
+		domain = strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "*"), ".")
+		f.internal[strings.TrimSuffix(domain, ".")] = true
+	}
+	if f.schemes == nil && !f.private && f.internal == nil {
+		return nil
+	}
+	return f
+}
+
+// reason returns why a visit is dropped, or "" if it is sent
+func (f *destinationFilter) reason(rawURL string) string {
+	if f == nil {
+		return ""
+	}
+	// The scheme is read from the raw URL, so URLs that do not parse are
+	// still filtered
+	if f.schemes != nil {
+		scheme, _, ok := strings.Cut(rawURL, ":")
+		if !ok || !f.schemes[strings.ToLower(scheme)] {
+			return dropScheme
+		}
+	}
+	if !f.private && f.internal == nil {
+		return ""
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	host := u.Hostname()
+	if f.private && isPrivateHost(host) {
+		return dropPrivate
+	}
+	if f.internal != nil && f.internal.Contains(host) {
+		return dropInternal
+	}
+	return ""
+}
+
+// isPrivateHost reports whether a host is a loopback, private (RFC 1918,
+// RFC 4193), shared (RFC 6598), link-local or unspecified address, or a
+// local name: single-label intranet hosts, localhost and .local
+func isPrivateHost(host string) bool {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		ip = ip.Unmap()
+		return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	return !strings.Contains(host, ".") || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".local")
+}
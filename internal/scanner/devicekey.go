@@ -0,0 +1,54 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"path/filepath"
+	"time"
+
+	"hist_scanner/internal/config"
+	"hist_scanner/internal/devicekey"
+	"hist_scanner/internal/dto"
+	"hist_scanner/internal/platform"
+	"hist_scanner/internal/sender"
+	"hist_scanner/internal/state"
+)
+
+// DeviceKeyPath returns the device signing key file: device_key, or
+// device.key next to the state file
+func DeviceKeyPath(cfg *config.Config, stateMgr *state.Manager) string {
+	if cfg.DeviceKey != "" {
+		return cfg.DeviceKey
+	}
+	return filepath.Join(stateMgr.StateDir(), devicekey.FileName)
+}
+
+// RegisterDeviceKey registers the device's signing key with the server
+func RegisterDeviceKey(client *sender.Client, cfg *config.Config, key *devicekey.Key, deviceID string) error {
+	hostname := platform.Hostname()
+	jwk := key.JWK()
+	return client.RegisterDevice(dto.DeviceKeyDTO{
+		Source:    cfg.Source,
+		Host:      hostname,
+		DeviceID:  deviceID,
+		KeyID:     key.ID(),
+		PublicKey: dto.JWKDTO{Kty: jwk.Kty, Crv: jwk.Crv, X: jwk.X},
+		Created:   time.Now().UnixMilli(),
+	})
+}
+
+// registerDeviceKey registers the signing key until the server accepted it.
+// A failure is only logged; uploads are still signed and the next run
+// tries again.
+func (s *Scanner) registerDeviceKey() {
+	if s.deviceKey == nil || s.state.GetDeviceKey() == s.deviceKey.ID() {
+		return
+	}
+	if err := RegisterDeviceKey(s.client, s.cfg, s.deviceKey, s.deviceInfo().ID); err != nil {
+		s.logger.Warnf("failed to register device key %s: %v", s.deviceKey.ID(), err)
+		return
+	}
+	s.logger.Infof("Registered device key %s", s.deviceKey.ID())
+	s.state.SetDeviceKey(s.deviceKey.ID())
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"net/url"
+	"slices"
+	"time"
+
+	"hist_scanner/internal/catalog"
+	"hist_scanner/internal/dto"
+	"hist_scanner/internal/platform"
+)
+
+// ServiceNew is the kind of events reporting an unsanctioned service
+// visited on the device for the first time
+const ServiceNew = "new-service"
+
+// loadSanctioned prepares new-service discovery and sign-up detection:
+// sanctioned_services merged with the list of sanctioned_url. If that list
+// cannot be downloaded, neither runs in this scan, so approved services are
+// not reported.
+func (s *Scanner) loadSanctioned() {
+	s.sanctioned, s.discover, s.signups = nil, false, nil
+	discover := s.cfg.DiscoverServices == "catalog" || s.cfg.DiscoverServices == "all"
+	signups := s.cfg.DetectSignups && len(s.cfg.SignupPatterns) > 0
+	if !discover && !signups {
+		return
+	}
+	sanctioned := slices.Clone(catalog.Sanctioned(s.cfg.SanctionedServices))
+	if s.cfg.SanctionedURL != "" {
+		names, err := s.client.FetchSanctioned(s.cfg.SanctionedURL)
+		if err != nil {
+			s.logger.Warnf("failed to download sanctioned services, not reporting new services and sign-ups: %v", err)
+			return
+		}
+		sanctioned = append(sanctioned, names...)
+	}
+	s.sanctioned, s.discover = sanctioned, discover
+	if signups {
+		s.signups = compileSignupPatterns(s.cfg.SignupPatterns)
+	}
+}
+
+// discoveredService is an unsanctioned domain found in a batch
+type discoveredService struct {
+	domain    string
+	service   *catalog.Service // nil for domains outside the catalog
+	firstSeen int64
+	visits    int
+}
+
+// discoverServices reports the unsanctioned registrable domains of a batch
+// that were never reported on this device. A domain is recorded in state
+// once it was logged and, with events_url, posted; a failed post is retried
+// at its next visit.
+func (s *Scanner) discoverServices(user platform.User, entries []dto.VisitedSite) {
+	if !s.discover || s.dryRun {
+		return
+	}
+
+	found := make(map[string]*discoveredService)
+	var order []string
+	for _, e := range entries {
+		u, err := url.Parse(e.URL)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		domain := catalog.RegistrableDomain(u.Hostname())
+		if d, ok := found[domain]; ok {
+			d.firstSeen = min(d.firstSeen, e.Timestamp)
+			d.visits++
+			continue
+		}
+		if s.state.IsServiceReported(domain) || s.sanctioned.Domain(domain) {
+			continue
+		}
+		var service *catalog.Service
+		if s.catalog != nil {
+			service, _ = s.catalog.Lookup(u.Hostname())
+		}
+		if service != nil && s.sanctioned.Service(service) {
+			continue
+		}
+		if service == nil && s.cfg.DiscoverServices == "catalog" {
+			continue
+		}
+		found[domain] = &discoveredService{domain: domain, service: service, firstSeen: e.Timestamp, visits: 1}
+		order = append(order, domain)
+	}
+
+	for _, domain := range order {
+		s.reportService(user, found[domain])
+	}
+}
+
+// reportService logs a new service and posts it to the events endpoint, if
+// one is configured
+func (s *Scanner) reportService(user platform.User, d *discoveredService) {
+	event := dto.ServiceEventDTO{
+		ScanID:    s.scanID,
+		Source:    s.cfg.Source,
+		DeviceID:  s.deviceInfo().ID,
+		Time:      time.Now().UnixMilli(),
+		Kind:      ServiceNew,
+		Domain:    d.domain,
+		FirstSeen: d.firstSeen,
+		Visits:    d.visits,
+	}
+	if d.service != nil {
+		event.App, event.AppCategory, event.Risk = d.service.Name, d.service.Category, d.service.Risk
+	}
+	s.logger.With("user", user.Username, "domain", d.domain, "app", event.App, "risk", event.Risk, "service_event", ServiceNew).
+		Infof("  New unsanctioned service: %s (first visited %s)", d.domain, time.UnixMilli(d.firstSeen).Format(time.DateTime))
+
+	if s.cfg.EventsURL != "" {
+		event.Principal = dto.NewUserPrincipal(user.Username)
+		event.Principal.Identity = s.identity(user)
+		event.Host = platform.Hostname()
+		if err := s.client.SendServiceEvent(s.cfg.EventsURL, event); err != nil {
+			s.logger.Warnf("failed to send service event: %v", err)
+			return
+		}
+	}
+	s.state.SetServiceReported(d.domain, d.firstSeen)
+}
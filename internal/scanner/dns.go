@@ -0,0 +1,140 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"hist_scanner/internal/browser"
+	"hist_scanner/internal/dnslog"
+	"hist_scanner/internal/dto"
+	"hist_scanner/internal/platform"
+)
+
+// dnsStateBrowser keys the positions of DNS sources in the state file, like
+// proxyStateBrowser
+const dnsStateBrowser = "dns"
+
+// dnsRepeat is how long lookups of a client for the same host count as one
+// event; resolvers look up each record type and again when a TTL expires
+const dnsRepeat = time.Minute
+
+// dnsFidelity marks DNS events as weaker evidence than browser history
+const dnsFidelity = "low"
+
+// collectDNS sends the hosts resolved since the last scan from dns_sources
+// as domain events of this device, or of the clients of its resolver, after
+// the browser history. They are sent as visits with a dns marker, never as
+// aggregates, and skip the detectors like proxy logs; runs that send only
+// aggregates skip them. Sources that do not exist on this OS are skipped. It
+// returns the sources read and failed.
+func (s *Scanner) collectDNS(result *ScanResult) (int, int) {
+	// Filtered runs and disk images are about browser profiles
+	if len(s.cfg.DNSSources) == 0 || s.root != "" || !s.filter.IsEmpty() {
+		return 0, 0
+	}
+	if !s.sendVisits {
+		s.logger.Warnf("dns_sources skipped: DNS events are sent as visits and this run sends only aggregates")
+		return 0, 0
+	}
+	sources, err := dnslog.ParseSources(s.cfg.DNSSources)
+	if err != nil {
+		msg := fmt.Sprintf("dns_sources: %v", err)
+		result.Errors = append(result.Errors, msg)
+		s.logger.Errorf("%s", msg)
+		return 0, 1
+	}
+
+	aggregates := s.sendAggregates
+	s.sendAggregates = false
+	defer func() { s.sendAggregates, s.origin = aggregates, nil }()
+
+	read, failed := 0, 0
+	for _, src := range sources {
+		if s.ctx.Err() != nil || s.limitReached() {
+			break
+		}
+		sent, err := s.collectDNSSource(src)
+		result.EntriesSent += sent
+		if errors.Is(err, dnslog.ErrUnsupported) {
+			s.logger.Debugf("dns/%s: %v", src, err)
+			continue
+		}
+		if err != nil {
+			failed++
+			msg := fmt.Sprintf("dns/%s: %v", src, err)
+			result.Errors = append(result.Errors, msg)
+			s.logger.Errorf("%s", msg)
+			continue
+		}
+		read++
+		s.logger.Infof("  dns/%s: %d domain events", src, sent)
+	}
+	return read, failed
+}
+
+// collectDNSSource sends the new lookups of one source, in batches per client
+func (s *Scanner) collectDNSSource(src dnslog.Source) (int, error) {
+	key := src.String()
+	since, until := time.UnixMilli(s.state.GetLastTimestamp("", dnsStateBrowser, key)), s.until
+	switch {
+	case s.ranged && !s.since.IsZero():
+		since = s.since
+	case s.ranged || s.full || since.UnixMilli() == 0:
+		since = s.initialSince()
+	}
+
+	queries, err := src.Read(s.ctx, since)
+	if err != nil {
+		return 0, err
+	}
+
+	// Collapse repeated lookups of a host per client
+	last := make(map[[2]string]time.Time)
+	events := make(map[string][]dto.VisitedSite)
+	var newest int64
+	for _, q := range queries {
+		if !until.IsZero() && !q.Time.Before(until) {
+			continue
+		}
+		newest = max(newest, q.Time.UnixMilli())
+		k := [2]string{q.Client, q.Name}
+		if t, ok := last[k]; ok && q.Time.Sub(t) < dnsRepeat && !q.Time.Before(t) {
+			continue
+		}
+		last[k] = q.Time
+		events[q.Client] = append(events[q.Client], dto.VisitedSite{URL: "https://" + q.Name + "/", Timestamp: q.Time.UnixMilli()})
+	}
+
+	clients := make([]string, 0, len(events))
+	for client := range events {
+		clients = append(clients, client)
+	}
+	slices.SortFunc(clients, cmp.Compare)
+	s.profile = ProfileStats{Browser: dnsStateBrowser, Profile: key}
+	sent := 0
+	for _, client := range clients {
+		addr := client
+		if addr == "" {
+			addr = getLocalIP()
+		}
+		s.origin = &origin{principal: dto.NewIPPrincipal(addr), dns: &dto.DNSDTO{Source: src.Kind, Fidelity: dnsFidelity}}
+		for batch := range slices.Chunk(events[client], historyBatchSize) {
+			n, err := s.sendEntries(platform.User{}, nil, browser.Profile{Name: key}, batch, false)
+			sent += n
+			if err != nil {
+				return sent, fmt.Errorf("%w: %w", errSendFailed, err)
+			}
+		}
+	}
+
+	if newest > 0 && !s.dryRun && !s.ranged {
+		s.state.SetLastTimestamp("", dnsStateBrowser, key, newest)
+	}
+	return sent, nil
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"cmp"
+	"net/url"
+	"slices"
+	"time"
+
+	"hist_scanner/internal/catalog"
+	"hist_scanner/internal/dto"
+)
+
+// maxNewDomains is the number of new domains listed in a run report; a
+// first scan finds every domain new
+const maxNewDomains = 200
+
+// startDomains resets the domains visited in the run and forgets the ones
+// not visited for domain_days
+func (s *Scanner) startDomains() {
+	s.seenDomains, s.newDomains = make(map[string]bool), nil
+	if !s.cfg.TrackDomains || s.dryRun || s.cfg.DomainDays == 0 {
+		return
+	}
+	before := time.Now().AddDate(0, 0, -s.cfg.DomainDays).UnixMilli()
+	if n := s.state.PruneDomains(before); n > 0 {
+		s.logger.Debugf("Forgot %d domains not visited for %d days", n, s.cfg.DomainDays)
+	}
+}
+
+// trackDomains records when the registrable domains of a batch were first
+// and last visited on the device
+func (s *Scanner) trackDomains(entries []dto.VisitedSite) {
+	if !s.cfg.TrackDomains || s.dryRun {
+		return
+	}
+	for _, e := range entries {
+		u, err := url.Parse(e.URL)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		domain := catalog.RegistrableDomain(u.Hostname())
+		s.seenDomains[domain] = true
+		if s.state.SeeDomain(domain, e.Timestamp) {
+			s.newDomains = append(s.newDomains, domain)
+		}
+	}
+}
+
+// logDomains logs how many domains the run visited and how many of them
+// are new to the device
+func (s *Scanner) logDomains(result *ScanResult) {
+	if result.DomainsSeen == 0 {
+		return
+	}
+	s.logger.With("domains", result.DomainsSeen, "new_domains", len(result.NewDomains)).
+		Infof("Visited %d domains, %d of them new to this device", result.DomainsSeen, len(result.NewDomains))
+}
+
+// newDomainsReport returns when the run's new domains were first and last
+// visited, most recently first seen first, at most maxNewDomains of them
+func (s *Scanner) newDomainsReport(domains []string) []dto.DomainSeenDTO {
+	var report []dto.DomainSeenDTO
+	for _, domain := range domains {
+		if seen, ok := s.state.GetDomain(domain); ok {
+			report = append(report, dto.DomainSeenDTO{Domain: domain, FirstSeen: seen.First, LastSeen: seen.Last})
+		}
+	}
+	slices.SortFunc(report, func(a, b dto.DomainSeenDTO) int { return cmp.Compare(b.FirstSeen, a.FirstSeen) })
+	if len(report) > maxNewDomains {
+		report = report[:maxNewDomains]
+	}
+	return report
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"hist_scanner/internal/browser"
+	"hist_scanner/internal/platform"
+)
+
+// Filter restricts a scan to matching users, browsers and profiles. Names
+// are compared case-insensitively; an empty list matches everything.
+type Filter struct {
+	Users    []string // Usernames
+	Browsers []string // Browser names, e.g. chrome
+	Profiles []string // Profile names or directory names, e.g. Default or "Profile 1"
+}
+
+// IsEmpty reports whether the filter matches everything
+func (f Filter) IsEmpty() bool {
+	return len(f.Users) == 0 && len(f.Browsers) == 0 && len(f.Profiles) == 0
+}
+
+// String describes the filter for the log
+func (f Filter) String() string {
+	var parts []string
+	for _, p := range []struct {
+		name  string
+		names []string
+	}{{"users", f.Users}, {"browsers", f.Browsers}, {"profiles", f.Profiles}} {
+		if len(p.names) > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%s", p.name, strings.Join(p.names, ",")))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// SelectUsers returns the users matching the filter
+func (f Filter) SelectUsers(users []platform.User) []platform.User {
+	if len(f.Users) == 0 {
+		return users
+	}
+	var matched []platform.User
+	for _, u := range users {
+		if matchName(f.Users, u.Username) {
+			matched = append(matched, u)
+		}
+	}
+	return matched
+}
+
+// SelectBrowsers returns the browsers matching the filter
+func (f Filter) SelectBrowsers(browsers []browser.Browser) []browser.Browser {
+	if len(f.Browsers) == 0 {
+		return browsers
+	}
+	var matched []browser.Browser
+	for _, b := range browsers {
+		if matchName(f.Browsers, b.Name()) {
+			matched = append(matched, b)
+		}
+	}
+	return matched
+}
+
+// SelectProfiles returns the profiles matching the filter
+func (f Filter) SelectProfiles(profiles []browser.Profile) []browser.Profile {
+	if len(f.Profiles) == 0 {
+		return profiles
+	}
+	var matched []browser.Profile
+	for _, p := range profiles {
+		if matchName(f.Profiles, p.Name, filepath.Base(p.Path)) {
+			matched = append(matched, p)
+		}
+	}
+	return matched
+}
+
+// MatchStateKey reports whether a profile's scan position, keyed
+// user/browser/profile in the state file, matches the filter. Users of disk
+// images and of the Windows side of WSL match by their name, without the
+// root or layout their key starts with. Positions of DNS sources and proxy
+// logs never match.
+func (f Filter) MatchStateKey(key string) bool {
+	for _, name := range browser.SupportedBrowserNames() {
+		user, profile, ok := strings.Cut(key, "/"+name+"/")
+		if !ok {
+			continue
+		}
+		username := user[strings.LastIndex(user, ":")+1:]
+		return (len(f.Users) == 0 || matchName(f.Users, user, username)) &&
+			(len(f.Browsers) == 0 || matchName(f.Browsers, name)) &&
+			(len(f.Profiles) == 0 || matchName(f.Profiles, profile))
+	}
+	return false
+}
+
+// matchName reports whether any of values equals one of names
+func matchName(names []string, values ...string) bool {
+	for _, name := range names {
+		for _, value := range values {
+			if strings.EqualFold(name, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"time"
+
+	"hist_scanner/internal/browser"
+	"hist_scanner/internal/dto"
+	"hist_scanner/internal/platform"
+)
+
+// History event kinds
+const (
+	HistoryRecreated = "recreated" // The profile or its history database was recreated
+	HistoryCleared   = "cleared"   // The history was emptied and its row ids went backwards
+	HistoryReduced   = "reduced"   // Many rows disappeared: part of the history was deleted
+)
+
+// A profile's history counts as reduced when at least historyDropRows rows
+// and historyDropPercent of them disappeared since the last scan. Browsers
+// also expire old visits, but only a few percent between two scans.
+const (
+	historyDropRows    = 100
+	historyDropPercent = 20
+)
+
+// HistoryEvent records a profile whose history was cleared or reduced since
+// the last scan
+type HistoryEvent struct {
+	Username     string
+	Browser      string
+	Profile      string
+	Kind         string // One of the History* kinds
+	PrevRows     int64
+	Rows         int64
+	PrevMaxRowID int64
+	MaxRowID     int64
+}
+
+// historyEventKind compares a profile's fingerprint with the one stored at
+// the last scan and returns the kind of history loss, or "" if there is none.
+// A lower highest row id alone is no reset: deleting the newest visits, like
+// Chrome's "clear last hour", lowers it too. The history counts as cleared
+// only if it is empty, or if more rows disappeared than the dropped ids
+// could account for, so the older rows went with them.
+func historyEventKind(prevID string, prevMaxRowID, prevRows int64, fp browser.Fingerprint) string {
+	switch {
+	case prevID != "" && fp.ID != "" && prevID != fp.ID:
+		return HistoryRecreated
+	case fp.MaxRowID < prevMaxRowID && (fp.Rows == 0 || prevRows-fp.Rows > prevMaxRowID-fp.MaxRowID):
+		return HistoryCleared
+	case prevRows > 0 && prevRows-fp.Rows >= historyDropRows && (prevRows-fp.Rows)*100 >= prevRows*historyDropPercent:
+		return HistoryReduced
+	}
+	return ""
+}
+
+// reportHistoryEvent logs a history event, adds it to result and posts it
+// to the events endpoint, if one is configured. A failed post is only logged.
+func (s *Scanner) reportHistoryEvent(user platform.User, ev HistoryEvent, result *ScanResult) {
+	result.HistoryEvents = append(result.HistoryEvents, ev)
+	s.logger.With("user", ev.Username, "browser", ev.Browser, "profile", ev.Profile, "history_event", ev.Kind,
+		"prev_rows", ev.PrevRows, "rows", ev.Rows).
+		Infof("  %s/%s: history was %s since the last scan (%d rows, was %d)", ev.Browser, ev.Profile, ev.Kind, ev.Rows, ev.PrevRows)
+
+	if s.cfg.EventsURL == "" {
+		return
+	}
+	principal := dto.NewUserPrincipal(user.Username)
+	principal.Identity = s.identity(user)
+	hostname := platform.Hostname()
+	event := dto.HistoryEventDTO{
+		ScanID:       s.scanID,
+		Source:       s.cfg.Source,
+		Host:         hostname,
+		DeviceID:     s.deviceInfo().ID,
+		Principal:    principal,
+		Browser:      ev.Browser,
+		Profile:      ev.Profile,
+		Time:         time.Now().UnixMilli(),
+		Kind:         ev.Kind,
+		PrevRows:     ev.PrevRows,
+		Rows:         ev.Rows,
+		PrevMaxRowID: ev.PrevMaxRowID,
+		MaxRowID:     ev.MaxRowID,
+	}
+	if err := s.client.SendHistoryEvent(s.cfg.EventsURL, event); err != nil {
+		s.logger.Warnf("failed to send history event: %v", err)
+	}
+}
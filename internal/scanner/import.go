@@ -0,0 +1,121 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"hist_scanner/internal/browser"
+	"hist_scanner/internal/dto"
+	"hist_scanner/internal/platform"
+)
+
+// origin is the source of visits that were not scanned from local browser
+// profiles. They are sent for its principal, do not move scan positions and
+// skip the detectors that keep per-device or per-user state.
+type origin struct {
+	principal dto.PrincipalDTO
+	imported  *dto.ImportDTO
+	proxy     *dto.ProxyDTO
+	dns       *dto.DNSDTO
+}
+
+// Import is history read from an export file, sent for a chosen principal
+type Import struct {
+	Format    string // takeout, csv or firefox-json
+	File      string
+	Principal dto.PrincipalDTO
+	Visits    []dto.VisitedSite
+}
+
+// Import sends the visits of an export file (or prints them in dry-run
+// mode) like scanned history: excluded visits are dropped, services tagged
+// and the payloads go to the sinks, or as aggregates. Scan positions are not
+// changed, and the detectors that keep per-device or per-user state (domain
+// tracking, service discovery, OAuth grants, sign-ups, AI tools and the
+// watchlist) are skipped. The run is not recorded as a scan.
+func (s *Scanner) Import(ctx context.Context, imp Import) *ScanResult {
+	started := time.Now()
+	defer s.begin(ctx)()
+	s.origin = &origin{
+		principal: imp.Principal,
+		imported:  &dto.ImportDTO{Format: imp.Format, File: filepath.Base(imp.File)},
+	}
+	defer func() { s.origin = nil }()
+
+	result := s.importVisits(imp)
+	s.logger.With("duration_ms", time.Since(started).Milliseconds(), "entries", result.EntriesSent,
+		"errors", len(result.Errors), "exit_code", int(result.ExitCode)).
+		Infof("Import complete: %d of %d entries sent, %d errors", result.EntriesSent, len(imp.Visits), len(result.Errors))
+	s.logDropped(result)
+
+	if err := s.state.Save(); err != nil {
+		s.logger.Warnf("failed to save state: %v", err)
+	}
+	return result
+}
+
+// importVisits sends the visits of an import in batches
+func (s *Scanner) importVisits(imp Import) *ScanResult {
+	result := &ScanResult{}
+	fail := func(err error) *ScanResult {
+		s.logger.Errorf("%v", err)
+		result.Errors = append(result.Errors, err.Error())
+		if result.EntriesSent > 0 {
+			result.ExitCode = ExitPartialFailure
+		} else {
+			result.ExitCode = ExitCompleteFailure
+		}
+		return result
+	}
+
+	s.logger.Infof("Importing %d visits of %s export %s as %s (scan %s)", len(imp.Visits), imp.Format, imp.File, imp.Principal.Name, s.scanID)
+	if err := s.checkIntegrity(); err != nil {
+		return fail(err)
+	}
+	s.registerDeviceKey()
+	s.loadBundle()
+	if err := s.loadCategories(); err != nil {
+		return fail(err)
+	}
+	s.loadCatalog()
+	s.negotiatePayload()
+	s.measureClock()
+	if err := s.openSinks(); err != nil {
+		return fail(err)
+	}
+	defer s.closeSinks()
+
+	profile := browser.Profile{Name: filepath.Base(imp.File)}
+	s.profile = ProfileStats{Username: imp.Principal.Name, Browser: imp.Format, Profile: profile.Name}
+	result.ProfilesScanned = 1
+	for start := 0; start < len(imp.Visits); start += historyBatchSize {
+		if err := s.ctx.Err(); err != nil {
+			return fail(fmt.Errorf("import canceled: %w", err))
+		}
+		batch := imp.Visits[start:min(start+historyBatchSize, len(imp.Visits))]
+		sent, err := s.sendEntries(platform.User{}, nil, profile, batch, false)
+		result.EntriesSent += sent
+		if err != nil {
+			return fail(fmt.Errorf("%w: %w", errSendFailed, err))
+		}
+	}
+	result.Phases, result.BytesSent = s.profile.Phases, s.profile.Bytes
+
+	if err := s.flushSinks(); err != nil {
+		return fail(err)
+	}
+	s.completeScan()
+	if err := s.flushAggregates(); err != nil {
+		if !s.sendVisits {
+			result.EntriesSent = 0
+		}
+		return fail(err)
+	}
+	result.Dropped = s.dropped
+	return result
+}
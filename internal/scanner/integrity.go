@@ -0,0 +1,28 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"fmt"
+
+	"hist_scanner/internal/installer"
+)
+
+// checkIntegrity checks the running binary against the one recorded at
+// install. A mismatch is logged, or with integrity_check: enforce fails the
+// run before any data is read.
+func (s *Scanner) checkIntegrity() error {
+	if s.cfg.IntegrityCheck == "off" {
+		return nil
+	}
+	err := installer.CheckIntegrity()
+	if err == nil {
+		return nil
+	}
+	if s.cfg.IntegrityCheck == "enforce" {
+		return fmt.Errorf("integrity check failed: %w; refusing to scan", err)
+	}
+	s.logger.Warnf("integrity check failed: %v", err)
+	return nil
+}
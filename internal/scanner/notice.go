@@ -0,0 +1,49 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"os"
+	"time"
+
+	"hist_scanner/internal/notice"
+	"hist_scanner/internal/state"
+)
+
+// showNotice writes the user notice files that are missing or out of date
+// and records when the current notice text was first in place. Failures are
+// logged; the scan goes on.
+func (s *Scanner) showNotice() {
+	if len(s.cfg.Notice) == 0 || s.dryRun {
+		return
+	}
+
+	written, err := notice.Write(s.cfg.Notice, s.cfg.NoticeText)
+	for _, path := range written {
+		s.logger.Infof("Wrote user notice %s", path)
+	}
+	if err != nil {
+		s.logger.Warnf("failed to write user notice: %v", err)
+		return
+	}
+
+	digest := notice.Digest(s.cfg.NoticeText)
+	if s.state.GetNotice().Digest == digest {
+		return
+	}
+	// A notice written by install has been in place since it was written
+	shown := time.Now().UTC()
+	if len(written) == 0 {
+		files, _ := notice.Files(s.cfg.Notice, s.cfg.NoticeText)
+		for _, f := range files {
+			if info, err := os.Stat(f.Path); err == nil && info.ModTime().Before(shown) {
+				shown = info.ModTime().UTC()
+			}
+		}
+	}
+	s.state.SetNotice(state.NoticeRecord{Shown: shown, Digest: digest})
+	if s.device != nil {
+		s.device.NoticeShown = shown.UnixMilli()
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"strings"
+	"time"
+
+	"hist_scanner/internal/browser"
+	"hist_scanner/internal/dto"
+	"hist_scanner/internal/oauth"
+	"hist_scanner/internal/platform"
+)
+
+// OAuthGrant is the kind of events reporting a user's first visit to the
+// OAuth consent page of an app
+const OAuthGrant = "oauth-grant"
+
+// detectOAuth reports the OAuth consent pages of a batch whose app was
+// never reported for the user. A grant is recorded in state once it was
+// logged and, with events_url, posted; a failed post is retried at the
+// next visit.
+func (s *Scanner) detectOAuth(user platform.User, b browser.Browser, profile browser.Profile, entries []dto.VisitedSite) {
+	if !s.cfg.DetectOAuth || s.dryRun {
+		return
+	}
+
+	type visit struct {
+		grant oauth.Grant
+		time  int64
+	}
+	found := make(map[string]*visit)
+	var order []string
+	for _, e := range entries {
+		g, ok := oauth.Detect(e.URL)
+		if !ok {
+			continue
+		}
+		key := g.Provider + "/" + g.ClientID
+		if v, ok := found[key]; ok {
+			v.time = min(v.time, e.Timestamp)
+			continue
+		}
+		if s.state.IsGrantReported(stateUser(user), g.Provider, g.ClientID) {
+			continue
+		}
+		found[key] = &visit{grant: g, time: e.Timestamp}
+		order = append(order, key)
+	}
+
+	for _, key := range order {
+		s.reportOAuthGrant(user, b, profile, found[key].grant, found[key].time)
+	}
+}
+
+// reportOAuthGrant logs an OAuth grant and posts it to the events endpoint,
+// if one is configured
+func (s *Scanner) reportOAuthGrant(user platform.User, b browser.Browser, profile browser.Profile, g oauth.Grant, visitTime int64) {
+	s.logger.With("user", user.Username, "browser", b.Name(), "profile", profile.Name, "provider", g.Provider,
+		"client_id", g.ClientID, "redirect_domain", g.RedirectDomain, "oauth_event", OAuthGrant).
+		Infof("  %s/%s: OAuth consent for %s app %s (redirects to %s, scopes %s)", b.Name(), profile.Name,
+			g.Provider, g.ClientID, g.RedirectDomain, strings.Join(g.Scopes, " "))
+
+	if s.cfg.EventsURL != "" {
+		principal := dto.NewUserPrincipal(user.Username)
+		principal.Identity = s.identity(user)
+		hostname := platform.Hostname()
+		event := dto.OAuthGrantDTO{
+			ScanID:         s.scanID,
+			Source:         s.cfg.Source,
+			Host:           hostname,
+			DeviceID:       s.deviceInfo().ID,
+			Principal:      principal,
+			Browser:        b.Name(),
+			Profile:        profile.Name,
+			Time:           time.Now().UnixMilli(),
+			Kind:           OAuthGrant,
+			Provider:       g.Provider,
+			ClientID:       g.ClientID,
+			RedirectDomain: g.RedirectDomain,
+			Scopes:         g.Scopes,
+			VisitTime:      visitTime,
+		}
+		if err := s.client.SendOAuthGrant(s.cfg.EventsURL, event); err != nil {
+			s.logger.Warnf("failed to send OAuth grant event: %v", err)
+			return
+		}
+	}
+	s.state.SetGrantReported(stateUser(user), g.Provider, g.ClientID, visitTime)
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"hist_scanner/internal/logging"
+	"hist_scanner/internal/optout"
+	"hist_scanner/internal/platform"
+)
+
+// listedExcluded reports whether excluded_users lists user
+func (s *Scanner) listedExcluded(user platform.User) bool {
+	for _, name := range s.cfg.ExcludedUsers {
+		if strings.EqualFold(strings.TrimSpace(name), user.Username) {
+			return true
+		}
+	}
+	return false
+}
+
+// optedOut returns the skip detail of a valid signed opt-out marker in the
+// user's home, or "" if there is none. Markers are ignored unless
+// optout_public_key is set; invalid ones are logged and ignored.
+func (s *Scanner) optedOut(user platform.User, ulog *logging.Logger) string {
+	if s.cfg.OptOutPublicKey == "" {
+		return ""
+	}
+	key, err := optout.ParsePublicKey(s.cfg.OptOutPublicKey)
+	if err != nil {
+		ulog.Warnf("Ignoring opt-out markers: %v", err)
+		return ""
+	}
+	m, err := optout.Check(key, user.HomeDir, user.Username, time.Now())
+	if err != nil {
+		ulog.Warnf("Ignoring opt-out marker of %s: %v", user.Username, err)
+		return ""
+	}
+	if m == nil {
+		return ""
+	}
+	return fmt.Sprintf("signed opt-out marker issued %s", m.Issued)
+}
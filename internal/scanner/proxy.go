@@ -0,0 +1,271 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"hist_scanner/internal/browser"
+	"hist_scanner/internal/dto"
+	"hist_scanner/internal/platform"
+	"hist_scanner/internal/proxylog"
+)
+
+// proxyStateBrowser keys the positions of proxy logs in the state file; no
+// local user has an empty name, so they never collide with profiles
+const proxyStateBrowser = "proxy"
+
+// proxyRepeat is how long requests of a client to the same host count as
+// one visit, as a page loads many resources
+const proxyRepeat = time.Minute
+
+// ProxyLog is a proxy access log read as a source of visits
+type ProxyLog struct {
+	Path   string
+	Format string        // squid or common
+	Follow bool          // Keep reading lines appended to the log
+	Poll   time.Duration // How often a followed log is checked for new lines
+}
+
+// proxyVisit is the last visit of a client to a host
+type proxyVisit struct {
+	principal, host string
+}
+
+// ReadProxyLog sends the requests of a proxy access log appended since the
+// last run (or of the last initial_days) as visits of their authenticated
+// user, the user proxy_clients maps their address to, or their address.
+// Visits go through the pipeline of scanned history without the detectors
+// that keep per-device state, like Import. The log position is stored in
+// the state file once they are sent. With Follow the log is read until ctx
+// is canceled, across rotations.
+func (s *Scanner) ReadProxyLog(ctx context.Context, p ProxyLog) *ScanResult {
+	started := time.Now()
+	defer s.begin(ctx)()
+	path, err := filepath.Abs(p.Path)
+	if err == nil {
+		p.Path = path
+	}
+	s.origin = &origin{proxy: &dto.ProxyDTO{Format: p.Format, Log: p.Path}}
+	defer func() { s.origin = nil }()
+
+	result := s.readProxyLog(p)
+	s.logger.With("duration_ms", time.Since(started).Milliseconds(), "entries", result.EntriesSent,
+		"errors", len(result.Errors), "exit_code", int(result.ExitCode)).
+		Infof("Proxy log complete: %d entries sent, %d errors", result.EntriesSent, len(result.Errors))
+	s.logDropped(result)
+
+	if err := s.state.Save(); err != nil {
+		s.logger.Warnf("failed to save state: %v", err)
+	}
+	return result
+}
+
+// readProxyLog sends the new requests of a log, following it if requested
+func (s *Scanner) readProxyLog(p ProxyLog) *ScanResult {
+	result := &ScanResult{ProfilesScanned: 1}
+	fail := func(err error) *ScanResult {
+		s.logger.Errorf("%v", err)
+		result.Errors = append(result.Errors, err.Error())
+		if result.EntriesSent > 0 {
+			result.ExitCode = ExitPartialFailure
+		} else {
+			result.ExitCode = ExitCompleteFailure
+		}
+		return result
+	}
+
+	s.logger.Infof("Reading %s proxy log %s (scan %s)", p.Format, p.Path, s.scanID)
+	clients, err := proxylog.ParseClients(s.cfg.ProxyClients)
+	if err != nil {
+		return fail(fmt.Errorf("proxy_clients: %w", err))
+	}
+	if err := s.checkIntegrity(); err != nil {
+		return fail(err)
+	}
+	s.registerDeviceKey()
+	s.loadBundle()
+	if err := s.loadCategories(); err != nil {
+		return fail(err)
+	}
+	s.loadCatalog()
+	s.negotiatePayload()
+	s.measureClock()
+	if err := s.openSinks(); err != nil {
+		return fail(err)
+	}
+	defer s.closeSinks()
+
+	// Without a stored position, only the last initial_days are sent
+	saved := s.proxyPosition(p.Path)
+	var since time.Time
+	if saved.Offset == 0 {
+		since = time.Now().AddDate(0, 0, -s.cfg.InitialDays)
+	}
+	rd, restarted, err := proxylog.Open(p.Path, saved)
+	if err != nil {
+		return fail(err)
+	}
+	defer func() { rd.Close() }()
+	if restarted {
+		s.logger.Infof("%s was rotated or truncated since the last run, reading it from the start", p.Path)
+	}
+
+	recent := make(map[proxyVisit]time.Time)
+	rotating := false
+	s.profile = ProfileStats{Browser: proxyStateBrowser, Profile: p.Path}
+	for {
+		if err := s.ctx.Err(); err != nil {
+			if p.Follow {
+				break
+			}
+			return fail(fmt.Errorf("proxy log canceled: %w", err))
+		}
+
+		sent, err := s.sendProxyLines(p, rd, clients, since, recent)
+		result.EntriesSent += sent
+		if err == nil {
+			err = s.flushSinks()
+		}
+		if err == nil {
+			err = s.flushAggregates()
+		}
+		if err != nil {
+			if !p.Follow {
+				return fail(err)
+			}
+			// Read the unsent lines again on the next poll
+			s.logger.Errorf("%v", err)
+			result.Errors = append(result.Errors, err.Error())
+			if err := rd.Reset(saved); err != nil {
+				return fail(err)
+			}
+		} else if rd.Position() != saved {
+			saved = rd.Position()
+			s.setProxyPosition(p.Path, saved)
+			s.checkpoint()
+		}
+		if !p.Follow {
+			break
+		}
+
+		// A rotated log is read once more after the proxy reopened its log,
+		// then the new one is read
+		if rotated, err := rd.Rotated(); err != nil {
+			s.logger.Warnf("%v", err)
+		} else if rotated && rotating && rd.Position() == saved {
+			if next, _, err := proxylog.Open(p.Path, proxylog.Position{}); err != nil {
+				s.logger.Warnf("%v", err)
+			} else {
+				rd.Close()
+				rd, saved, rotating = next, next.Position(), false
+				s.setProxyPosition(p.Path, saved)
+				s.logger.Infof("%s was rotated, reading the new log", p.Path)
+			}
+		} else {
+			rotating = rotated
+		}
+		for key, t := range recent {
+			if time.Since(t) > proxyRepeat {
+				delete(recent, key)
+			}
+		}
+
+		select {
+		case <-s.ctx.Done():
+		case <-time.After(p.Poll):
+		}
+	}
+	s.completeScan()
+
+	result.Phases, result.BytesSent = s.profile.Phases, s.profile.Bytes
+	result.Dropped = s.dropped
+	if len(result.Errors) > 0 {
+		result.ExitCode = ExitPartialFailure
+	}
+	return result
+}
+
+// sendProxyLines sends the visits of the complete lines of a log, in
+// batches per principal
+func (s *Scanner) sendProxyLines(p ProxyLog, rd *proxylog.Reader, clients proxylog.Clients, since time.Time, recent map[proxyVisit]time.Time) (int, error) {
+	sent := 0
+	for {
+		lines, err := rd.ReadLines(historyBatchSize)
+		if err != nil {
+			return sent, err
+		}
+
+		visits := make(map[string][]dto.VisitedSite)
+		principals := make(map[string]dto.PrincipalDTO)
+		for _, line := range lines {
+			req, ok := proxylog.Parse(p.Format, line)
+			if !ok || req.Time.Before(since) {
+				continue
+			}
+			principal := s.proxyPrincipal(req, clients)
+			u, err := url.Parse(req.URL)
+			if err != nil || u.Hostname() == "" {
+				continue
+			}
+			key := proxyVisit{principal.Name, u.Hostname()}
+			if last, ok := recent[key]; ok && req.Time.Sub(last) < proxyRepeat && !req.Time.Before(last) {
+				continue
+			}
+			recent[key] = req.Time
+			principals[principal.Name] = principal
+			visits[principal.Name] = append(visits[principal.Name], dto.VisitedSite{URL: req.URL, Timestamp: req.Time.UnixMilli()})
+		}
+
+		names := make([]string, 0, len(visits))
+		for name := range visits {
+			names = append(names, name)
+		}
+		slices.SortFunc(names, cmp.Compare)
+		for _, name := range names {
+			s.origin.principal = principals[name]
+			n, err := s.sendEntries(platform.User{}, nil, browser.Profile{Name: p.Path}, visits[name], false)
+			sent += n
+			if err != nil {
+				return sent, fmt.Errorf("%w: %w", errSendFailed, err)
+			}
+		}
+		if len(lines) < historyBatchSize {
+			return sent, nil
+		}
+	}
+}
+
+// proxyPrincipal returns the principal of a request: its authenticated
+// user, the user of its client address in proxy_clients, or the address
+func (s *Scanner) proxyPrincipal(req proxylog.Request, clients proxylog.Clients) dto.PrincipalDTO {
+	if req.User != "" {
+		return dto.NewUserPrincipal(req.User)
+	}
+	if user := clients.User(req.Client); user != "" {
+		return dto.NewUserPrincipal(user)
+	}
+	return dto.NewIPPrincipal(req.Client)
+}
+
+// proxyPosition returns the stored position of a log
+func (s *Scanner) proxyPosition(path string) proxylog.Position {
+	id, _, _ := s.state.GetFingerprint("", proxyStateBrowser, path)
+	return proxylog.Position{ID: id, Offset: s.state.GetLastRowID("", proxyStateBrowser, path)}
+}
+
+// setProxyPosition stores the position of a log whose lines were sent
+func (s *Scanner) setProxyPosition(path string, pos proxylog.Position) {
+	if s.dryRun {
+		return
+	}
+	s.state.SetFingerprint("", proxyStateBrowser, path, pos.ID, pos.Offset, 0)
+	s.state.SetLastRowID("", proxyStateBrowser, path, pos.Offset)
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"time"
+
+	"hist_scanner/internal/db"
+	"hist_scanner/internal/logging"
+	"hist_scanner/internal/retention"
+)
+
+// enforceRetention limits the scanner's local data before each scan: copies
+// of locked databases left by crashed runs are removed, the log file is
+// rotated when too large, and rotated logs and files under retention_paths
+// are pruned to retention_days and retention_max_mb. Dry runs only remove
+// database copies.
+func (s *Scanner) enforceRetention() {
+	db.ScavengeTempDirs()
+	if s.dryRun {
+		return
+	}
+
+	policy := retention.Policy{
+		MaxAge:  time.Duration(s.cfg.RetentionDays) * 24 * time.Hour,
+		MaxSize: int64(s.cfg.RetentionMaxMB) << 20,
+	}
+	if s.logFile != nil {
+		if s.cfg.LogMaxSizeMB > 0 {
+			rotated, err := s.logFile.Rotate(int64(s.cfg.LogMaxSizeMB) << 20)
+			if err != nil {
+				s.logger.Warnf("failed to rotate log file: %v", err)
+			} else if rotated != "" {
+				s.logger.Infof("Rotated log file to %s", rotated)
+			}
+		}
+		s.prune("rotated logs", logging.RotatedFiles(s.logFile.Path()), policy)
+	}
+	for _, dir := range s.cfg.RetentionPaths {
+		s.prune(dir, retention.Walk(dir), policy)
+	}
+}
+
+// prune applies the retention policy to a set of files, logging what was removed
+func (s *Scanner) prune(what string, files []string, policy retention.Policy) {
+	removed, err := retention.Prune(files, policy, time.Now())
+	if err != nil {
+		s.logger.Warnf("retention: %s: %v", what, err)
+	}
+	if removed.Files > 0 {
+		s.logger.With("removed_files", removed.Files, "removed_bytes", removed.Bytes).
+			Infof("Retention: removed %d files (%d KB) of %s", removed.Files, removed.Bytes>>10, what)
+	}
+}
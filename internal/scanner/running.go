@@ -0,0 +1,111 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"slices"
+
+	"hist_scanner/internal/browser"
+	"hist_scanner/internal/platform"
+)
+
+// maxPostponed is how many runs in a row may leave a profile to the next
+// one with running_browsers next-run; the run after them scans it while its
+// browser runs, so a browser that is never closed does not stop the scans
+const maxPostponed = 3
+
+// deferredProfile is a profile left to the end of the run because its
+// browser was running
+type deferredProfile struct {
+	user    platform.User
+	browser browser.Browser
+	profile browser.Profile
+}
+
+// PostponedProfile records a profile left to the next run because its
+// browser was running
+type PostponedProfile struct {
+	Username string
+	Browser  string
+	Profile  string
+	Runs     int // Runs in a row that left it to the next one
+}
+
+// listProcesses lists the running processes for browserRunning, unless
+// running_browsers is scan or the scan reads a disk image
+func (s *Scanner) listProcesses() {
+	s.processes = nil
+	if s.cfg.RunningBrowsers == "scan" || s.root != "" {
+		return
+	}
+	procs, err := platform.Processes()
+	if err != nil {
+		s.logger.Warnf("%v; profiles of running browsers are scanned in turn", err)
+	}
+	s.processes = procs
+}
+
+// browserRunning reports whether a user runs a browser, as of the last
+// listProcesses. Users of disk images, of a host mounted into the scanner's
+// container and of the Windows side of WSL run no processes the scanner
+// sees, and users of profile containers are signed out.
+func (s *Scanner) browserRunning(user platform.User, b browser.Browser) bool {
+	if user.Root != "" || user.Layout != "" || user.Container != "" {
+		return false
+	}
+	names := browser.ProcessNames(b.Name())
+	for _, p := range s.processes {
+		if slices.Contains(names, p.Name) && p.RunBy(user) {
+			return true
+		}
+	}
+	return false
+}
+
+// keepsPostponed reports whether this run counts the runs that left a
+// profile to the next one; dry and ranged runs leave scan state alone
+func (s *Scanner) keepsPostponed() bool {
+	return s.cfg.RunningBrowsers == "next-run" && !s.dryRun && !s.ranged
+}
+
+// scanDeferred scans the profiles deferred because their browser was
+// running, after listing the processes again, and returns the successes
+// and failures as scanUser does. With running_browsers next-run, profiles
+// whose browser still runs are left to the next run, unless maxPostponed
+// runs in a row left them already.
+func (s *Scanner) scanDeferred(result *ScanResult) (int, int) {
+	deferred := s.deferred
+	s.deferred = nil
+	if len(deferred) == 0 {
+		return 0, 0
+	}
+	if s.keepsPostponed() {
+		s.listProcesses()
+	}
+
+	successes, failures := 0, 0
+	for _, d := range deferred {
+		if s.ctx.Err() != nil || s.limitReached() {
+			break
+		}
+		plog := s.profileLogger(d.user, d.browser, d.profile)
+		if s.keepsPostponed() && s.browserRunning(d.user, d.browser) {
+			runs := s.state.GetPostponed(stateUser(d.user), d.browser.Name(), d.profile.Name) + 1
+			if runs <= maxPostponed {
+				s.state.SetPostponed(stateUser(d.user), d.browser.Name(), d.profile.Name, runs)
+				result.Postponed = append(result.Postponed, PostponedProfile{Username: d.user.Username, Browser: d.browser.Name(), Profile: d.profile.Name, Runs: runs})
+				plog.Infof("%s/%s/%s: %s is running, leaving the profile to the next run (%d of %d)", d.user.Username, d.browser.Name(), d.profile.Name, d.browser.Name(), runs, maxPostponed)
+				continue
+			}
+			plog.Infof("%s/%s/%s: %s was running for %d runs, scanning the profile anyway", d.user.Username, d.browser.Name(), d.profile.Name, d.browser.Name(), maxPostponed)
+		}
+		switch s.runProfile(d.user, d.browser, d.profile, result) {
+		case profileSent:
+			successes++
+		case profileFailed:
+			failures++
+		}
+	}
+	return successes, failures
+}
@@ -4,21 +4,35 @@
 package scanner
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
+	"hist_scanner/internal/audit"
 	"hist_scanner/internal/browser"
+	"hist_scanner/internal/buildinfo"
+	"hist_scanner/internal/catalog"
+	"hist_scanner/internal/category"
 	"hist_scanner/internal/config"
+	"hist_scanner/internal/db"
+	"hist_scanner/internal/devicekey"
 	"hist_scanner/internal/dto"
+	"hist_scanner/internal/logging"
 	"hist_scanner/internal/platform"
 	"hist_scanner/internal/sender"
 	"hist_scanner/internal/state"
+	"hist_scanner/internal/watchlist"
+	"hist_scanner/pkg/sink"
 )
 
 // ExitCode represents the scanner exit status
@@ -35,8 +49,65 @@ type Scanner struct {
 	cfg    *config.Config
 	state  *state.Manager
 	client *sender.Client
-	logger *log.Logger
+	sink   sink.Sink // Receives the visits of the current run (sinks)
+	logger *logging.Logger
 	dryRun bool
+	full   bool   // Ignore stored watermarks and rescan initial_days
+	filter Filter // Users, browsers and profiles to scan
+
+	fullDays int // Days a full rescan reads instead of initial_days, 0 for initial_days
+
+	logFile *logging.File // Rotated by retention, nil when not logging to a file
+
+	// Manual time range (SetRange); when ranged, watermarks are neither used nor changed
+	ranged       bool
+	since, until time.Time
+
+	ctx context.Context // Cancels the current run (Run)
+
+	// Mounted disk image scanned instead of the running system (SetRoot)
+	root      string
+	rootUsers []string
+
+	limit  int                             // Entries read per run, 0 means no limit (SetLimit)
+	taken  int                             // Entries read so far in this run
+	output func(dto.VisitedSitesDTO) error // Receives dry-run payloads (SetDryRunOutput)
+
+	aggregatesOutput func(dto.DomainAggregatesDTO) error // Receives dry-run aggregates (SetDryRunAggregatesOutput)
+
+	scanID  string         // Random id of the current run, in logs, run records and reports
+	version string         // Scanner version reported in payloads
+	device  *dto.DeviceDTO // Resolved on the first scan
+
+	identities map[string]*dto.IdentityDTO // Resolved directory identities by identityKey
+
+	profile ProfileStats // Send phases of the profile being scanned, added by sendEntries
+
+	deviceKey *devicekey.Key // Signs uploads when sign_payloads is set
+
+	exclude      *category.Set       // Lists of exclude_categories, nil when none are excluded
+	destinations *destinationFilter  // Drops visits by scheme and destination, nil when none are
+	catalog      *catalog.Catalog    // Tags sent visits with SaaS services, nil unless tag_services or discover_services is catalog
+	bundle       *catalog.Bundle     // Signed catalog update of catalog_url, nil when there is none
+	sanctioned   catalog.Sanctioned  // Approved services, not reported by discover_services and detect_signups
+	discover     bool                // Report new unsanctioned services in this run
+	signups      []*regexp.Regexp    // Paths of sign-up pages reported in this run, nil when not detecting them
+	seenDomains  map[string]bool     // Registrable domains visited in this run (track_domains)
+	newDomains   []string            // Domains of this run never visited on the device before
+	aiUsage      map[string]*aiUsage // AI tool visits per user in this run (ai_tools)
+	watchlist    *watchlist.List     // Domains and patterns alerted on, nil when there are none
+	alerted      map[string]bool     // Users and hosts alerted on in this run
+	dropped      map[string]int      // Visits dropped per excluded category or destination reason in this run
+
+	// payload_format of this run, negotiated with the server in auto mode
+	sendVisits, sendAggregates bool
+	aggregates                 map[string]*domainAggregate // Visits of this run per registrable domain
+	pending                    []pendingPosition           // Scan positions stored once the aggregates are sent
+
+	origin *origin // Source of visits not scanned from local profiles (Import, ReadProxyLog), nil when scanning
+
+	processes []platform.Process // Running processes, listed for running_browsers
+	deferred  []deferredProfile  // Profiles of running browsers, scanned at the end of the run
 }
 
 // ScanResult contains the results of a scan operation
@@ -45,38 +116,188 @@ type ScanResult struct {
 	ProfilesScanned int
 	EntriesSent     int
 	Errors          []string
+	ProfileErrors   []ProfileError // Errors of profiles and users' browser data, by category
+	Skipped         []SkippedUser
+	Access          []AccessDiagnostic // Permission pre-flight of each user's browser data
+	Corrupt         []CorruptProfile   // Profiles salvaged from damaged databases
+	HistoryEvents   []HistoryEvent     // Profiles whose history was cleared or reduced
+	Dropped         map[string]int     // Visits dropped per excluded category or destination reason
+	DomainsSeen     int                // Registrable domains visited (track_domains)
+	NewDomains      []string           // Visited domains never visited on the device before
+	Postponed       []PostponedProfile // Profiles left to the next run while their browser ran
 	ExitCode        ExitCode
+
+	Enumeration time.Duration  // Enumerating users and finding their profiles
+	Phases      PhaseTimes     // Phase totals over all profiles
+	BytesSent   int64          // Bytes sent, after compression
+	Profiles    []ProfileStats // Timing of each scanned profile
+}
+
+// Skip reasons for users whose home could not be scanned
+const (
+	SkipEncrypted   = "inaccessible-encrypted" // Encrypted home locked while the user is signed out
+	SkipUnavailable = "home-unavailable"       // Home missing or on an unmounted filesystem
+	SkipDisabled    = "account-disabled"       // Account disabled, locked or expired
+	SkipStale       = "stale"                  // No history change within stale_days
+	SkipExcluded    = "excluded-by-policy"     // Listed in excluded_users or signed opt-out marker
+)
+
+// CorruptProfile records a profile whose history was salvaged from a damaged database
+type CorruptProfile struct {
+	Username    string
+	Browser     string
+	Profile     string
+	SkippedRows int    // Rows that could not be read
+	Problem     string // First integrity_check message
+}
+
+// ProfileError records a profile that could not be scanned, or a user's
+// home or browser data that could not be read
+type ProfileError struct {
+	Username string
+	Browser  string // Empty for errors of the home
+	Profile  string // Empty for errors of the home or browser data
+	Category string // One of the error categories, e.g. locked
+	Severity string // config.SeverityError or config.SeverityWarning
+	Message  string
+}
+
+// ErrorCategories counts the profile errors of each category, nil if there
+// are none
+func (r *ScanResult) ErrorCategories() map[string]int {
+	if len(r.ProfileErrors) == 0 {
+		return nil
+	}
+	counts := make(map[string]int)
+	for _, pe := range r.ProfileErrors {
+		counts[pe.Category]++
+	}
+	return counts
+}
+
+// SkippedUser records a user that was skipped instead of scanned
+type SkippedUser struct {
+	Username string
+	Reason   string // One of the Skip* reasons
+	Detail   string
+}
+
+// NewClient returns a client for the server of cfg that authenticates and
+// adds the headers as configured
+func NewClient(cfg *config.Config) *sender.Client {
+	client := sender.NewClient(cfg.ServerURL, cfg.APIKey, cfg.Timeout, cfg.ChunkSizeKB, cfg.Compress)
+	if cfg.AuthHeader != "" {
+		scheme := cfg.AuthScheme
+		if strings.EqualFold(scheme, "none") {
+			scheme = ""
+		}
+		client.SetAuth(cfg.AuthHeader, scheme)
+	}
+	client.SetEncoding(cfg.PayloadEncoding)
+	client.SetResumable(cfg.ResumableUploadKB)
+	client.SetConcurrency(cfg.UploadConcurrency)
+	client.SetHealthURL(cfg.HealthURL)
+	// Validate rejected malformed headers
+	if headers, err := cfg.StaticHeaders(); err == nil {
+		client.SetHeaders(headers)
+	}
+	return client
 }
 
 // New creates a new Scanner instance
 func New(cfg *config.Config, dryRun bool) (*Scanner, error) {
 	// Set up logger
 	var logWriter io.Writer = io.Discard
-	if cfg.LogFile != "" {
-		if strings.EqualFold(cfg.LogFile, "STDERR") {
-			logWriter = os.Stderr
-		} else {
-			f, err := os.OpenFile(cfg.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-			if err != nil {
-				return nil, fmt.Errorf("failed to open log file: %w", err)
-			}
-			logWriter = f
+	var logFile *logging.File
+	if path := cfg.LogPath(); path != "" {
+		f, err := logging.OpenFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %w", err)
 		}
+		logWriter, logFile = f, f
+	} else if strings.EqualFold(cfg.LogFile, "STDERR") {
+		logWriter = os.Stderr
+	}
 
+	var logger *logging.Logger
+	var err error
+	if logging.IsSystemTarget(cfg.LogFile) {
+		logger, err = logging.NewSystem(cfg.LogFile, cfg.LogLevel, cfg.LogFormat)
+	} else {
+		logger, err = logging.New(logWriter, cfg.LogLevel, cfg.LogFormat)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	logger := log.New(logWriter, "[hist_scanner] ", log.LstdFlags)
+	platform.SetHomeTimeout(cfg.HomeTimeout)
+
+	userSource := cfg.UserSource
+	if userSource == "auto" {
+		userSource = platform.UserSourceAuto
+	}
+	platform.SetUserOptions(platform.UserOptions{Source: userSource, HomeDirs: cfg.ScanHomeDirs})
+	platform.SetHostRoot(cfg.HostRoot)
+	db.SetShadowCopies(cfg.ShadowCopies)
 
 	// Initialize state manager
 	stateMgr := state.NewManager(cfg.StateFile)
+	if cfg.StateEncryption {
+		if err := stateMgr.EnableEncryption(cfg.StateKey); err != nil {
+			return nil, err
+		}
+	}
 	if err := stateMgr.Load(); err != nil {
-		logger.Printf("Warning: failed to load state: %v", err)
+		// Starting over would overwrite the positions with a new key
+		if errors.Is(err, state.ErrStateKey) {
+			return nil, fmt.Errorf("failed to load state: %w (restore the key, or move the state file aside to start over)", err)
+		}
+		logger.Warnf("failed to load state: %v", err)
+	}
+
+	// Copies of locked databases go to the configured directory, or to the
+	// scanner's temp dir with one next to the state file as a fallback when
+	// the temp filesystem is short on space
+	copyDirs := []string{cfg.TempDir}
+	if cfg.TempDir == "" {
+		copyDirs = []string{db.DefaultTempDir(), filepath.Join(filepath.Dir(stateMgr.GetStateFilePath()), "tmp")}
 	}
+	db.SetCopyOptions(db.CopyOptions{
+		Dirs:    copyDirs,
+		MaxSize: int64(cfg.MaxCopySizeMB) << 20,
+		Shred:   cfg.ShredTempFiles,
+	})
+	db.SetQueryOptions(db.QueryOptions{
+		Timeout: cfg.QueryTimeout,
+		MaxRows: cfg.MaxRows,
+	})
 
 	// Initialize HTTP client (nil if dry run)
 	var client *sender.Client
 	if !dryRun {
-		client = sender.NewClient(cfg.ServerURL, cfg.APIKey, cfg.Timeout, cfg.ChunkSizeKB, cfg.Compress)
+		client = NewClient(cfg)
+		if cfg.AuditLog != "" {
+			auditLog, err := audit.Open(cfg.AuditLog)
+			if err != nil {
+				return nil, err
+			}
+			client.SetAuditLog(auditLog)
+		}
+	}
+
+	// The device key is created on the first run if install did not create it
+	var deviceKey *devicekey.Key
+	if cfg.SignPayloads && !dryRun {
+		path := DeviceKeyPath(cfg, stateMgr)
+		key, created, err := devicekey.LoadOrCreate(path)
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			logger.Infof("Created device key %s at %s", key.ID(), path)
+		}
+		client.SetSigner(key.Sign)
+		deviceKey = key
 	}
 
 	return &Scanner{
@@ -85,131 +306,1068 @@ func New(cfg *config.Config, dryRun bool) (*Scanner, error) {
 		client: client,
 		logger: logger,
 		dryRun: dryRun,
+
+		logFile: logFile,
+
+		ctx: context.Background(),
+
+		identities: make(map[string]*dto.IdentityDTO),
+		deviceKey:  deviceKey,
+
+		destinations: newDestinationFilter(cfg),
 	}, nil
 }
 
-// Run executes the full scan process
-func (s *Scanner) Run() *ScanResult {
+// SetFull makes the scan ignore stored watermarks and rescan the last days
+// of history, or initial_days if days is 0. Watermarks are still advanced,
+// never moved back.
+func (s *Scanner) SetFull(full bool, days int) {
+	s.full, s.fullDays = full, days
+}
+
+// initialSince is where reading starts for profiles without a watermark,
+// and for all profiles in a full rescan
+func (s *Scanner) initialSince() time.Time {
+	days := s.cfg.InitialDays
+	if s.full && s.fullDays > 0 {
+		days = s.fullDays
+	}
+	return time.Now().AddDate(0, 0, -days)
+}
+
+// SetRange scans the visits from since up to (not including) until instead of
+// the history after the stored watermarks, which are left unchanged. A zero
+// since means initial_days ago, a zero until means no upper bound.
+func (s *Scanner) SetRange(since, until time.Time) {
+	s.ranged = true
+	s.since, s.until = since, until
+}
+
+// SetRoot scans the users of a mounted disk image or offline system root
+// instead of the running system, optionally only the given users ("user" or
+// "user=/home/dir", relative to root). Checks of the running system (disabled
+// accounts, locked homes, directory identities, profile stores and WSL) are
+// skipped.
+func (s *Scanner) SetRoot(root string, users []string) {
+	s.root, s.rootUsers = root, users
+}
+
+// SetLimit stops the run after n entries have been read (0 means no limit)
+func (s *Scanner) SetLimit(n int) {
+	s.limit = n
+}
+
+// SetDryRunOutput makes dry-run scans pass each payload to fn instead of
+// printing it as JSON
+func (s *Scanner) SetDryRunOutput(fn func(payload dto.VisitedSitesDTO) error) {
+	s.output = fn
+}
+
+// SetDryRunAggregatesOutput makes dry-run scans pass the domain aggregates
+// payload to fn instead of printing it as JSON
+func (s *Scanner) SetDryRunAggregatesOutput(fn func(payload dto.DomainAggregatesDTO) error) {
+	s.aggregatesOutput = fn
+}
+
+// limitReached reports whether the run has read as many entries as SetLimit allows
+func (s *Scanner) limitReached() bool {
+	return s.limit > 0 && s.taken >= s.limit
+}
+
+// errLimitReached stops a history stream when the run's entry limit is reached
+var errLimitReached = errors.New("entry limit reached")
+
+// SetFilter restricts the scan to matching users, browsers and profiles
+func (s *Scanner) SetFilter(filter Filter) {
+	s.filter = filter
+}
+
+// SetVersion sets the scanner version reported in the device block of payloads
+func (s *Scanner) SetVersion(version string) {
+	s.version = version
+}
+
+// build returns the scanner build reported in payloads and reports, with
+// the version set by SetVersion
+func (s *Scanner) build() *dto.ScannerDTO {
+	build := buildinfo.Scanner()
+	if s.version != "" {
+		build.Version = s.version
+	}
+	return build
+}
+
+// Logger returns the scanner's logger, writing to the configured log
+func (s *Scanner) Logger() *logging.Logger {
+	return s.logger
+}
+
+// Close releases the log file of a scanner that is no longer used
+func (s *Scanner) Close() error {
+	if s.logFile == nil {
+		return nil
+	}
+	return s.logFile.Close()
+}
+
+// maxRunErrors caps the errors kept in run records and status reports
+const maxRunErrors = 10
+
+// historyBatchSize is the number of history entries read from a database
+// before they are sent and the scan position is saved
+const historyBatchSize = 5000
+
+// Run executes the full scan process, records its outcome in the state file
+// and posts a run report to the status endpoint if one is configured. A
+// panic during the scan is recovered and reported as a failed run.
+// Canceling ctx stops the scan at the next history entry and aborts its
+// requests; what was sent until then is recorded.
+func (s *Scanner) Run(ctx context.Context) *ScanResult {
+	started := time.Now()
+	defer s.begin(ctx)()
+
+	result, panicked := s.safeScan()
+	s.logger.With("duration_ms", time.Since(started).Milliseconds(), "entries", result.EntriesSent,
+		"errors", len(result.Errors), "exit_code", int(result.ExitCode)).
+		Infof("Scan complete: %d entries sent, %d errors, %d users skipped",
+			result.EntriesSent, len(result.Errors), len(result.Skipped))
+	s.logDropped(result)
+	s.logDomains(result)
+	s.logTiming(result)
+	if !panicked && result.ExitCode == ExitCompleteFailure {
+		s.reportFatal(result)
+	}
+
+	if !s.dryRun {
+		s.state.AddRun(state.RunRecord{
+			ScanID:          s.scanID,
+			Started:         started,
+			DurationMS:      time.Since(started).Milliseconds(),
+			ExitCode:        int(result.ExitCode),
+			Full:            s.full,
+			Ranged:          s.ranged,
+			UsersScanned:    result.UsersScanned,
+			ProfilesScanned: result.ProfilesScanned,
+			EntriesSent:     result.EntriesSent,
+			UsersSkipped:    len(result.Skipped),
+			Errors:          truncateErrors(result.Errors),
+			ErrorCategories: result.ErrorCategories(),
+			Timing:          runTiming(result),
+		})
+	}
+
+	// Save state
+	if err := s.state.Save(); err != nil {
+		s.logger.Warnf("failed to save state: %v", err)
+	}
+
+	if !s.dryRun && s.cfg.StatusURL != "" {
+		s.sendReport(started, result)
+	}
+	if !s.dryRun && s.cfg.WebhookURL != "" {
+		s.sendWebhook(started, result)
+	}
+
+	return result
+}
+
+// begin starts a run canceled by ctx and tags its log records and uploads
+// with a new scan id. The returned function restores the scanner after the run.
+func (s *Scanner) begin(ctx context.Context) func() {
+	s.ctx = ctx
+	s.scanID = newScanID()
+	client, base := s.client, s.logger
+	if client != nil {
+		s.client = client.WithContext(ctx).WithScan(s.scanID)
+	}
+	s.logger = base.With("scan_id", s.scanID)
+	return func() { s.client, s.logger = client, base }
+}
+
+// scan enumerates users, browsers and profiles and sends new history entries
+func (s *Scanner) scan() *ScanResult {
 	result := &ScanResult{}
+	s.taken = 0
+
+	// Delete shadow copies taken for locked databases
+	defer platform.ReleaseShadowCopies()
+	// Delete copies of locked databases that were not closed
+	defer db.RemoveTempDirs()
+
+	s.enforceRetention()
+	s.logger.Infof("Starting browser history scan (scan %s)", s.scanID)
+	if !s.filter.IsEmpty() {
+		s.logger.Infof("Filter: %s", s.filter)
+	}
+	if s.ranged {
+		s.logger.Infof("Range: %s, scan positions are not used or changed", s.rangeString())
+	}
+	switch {
+	case s.root != "":
+		s.logger.Infof("Scanning the image at %s instead of this system", s.root)
+	case s.cfg.HostRoot != "":
+		s.logger.Infof("Scanning the host mounted at %s", s.cfg.HostRoot)
+	case platform.InContainer():
+		s.logger.Warnf("running in a container without host_root: only the users of the container are scanned")
+	}
+	if err := s.checkIntegrity(); err != nil {
+		s.logger.Errorf("%v", err)
+		result.Errors = append(result.Errors, err.Error())
+		result.ExitCode = ExitCompleteFailure
+		return result
+	}
+	s.showNotice()
+	s.registerDeviceKey()
 
-	s.logger.Println("Starting browser history scan")
+	s.loadBundle()
+	if err := s.loadCategories(); err != nil {
+		s.logger.Errorf("%v", err)
+		result.Errors = append(result.Errors, err.Error())
+		result.ExitCode = ExitCompleteFailure
+		return result
+	}
+	s.loadCatalog()
+	s.loadSanctioned()
+	s.startDomains()
+	s.loadWatchlist()
+	s.negotiatePayload()
+	s.measureClock()
+	if err := s.openSinks(); err != nil {
+		s.logger.Errorf("%v", err)
+		result.Errors = append(result.Errors, err.Error())
+		result.ExitCode = ExitCompleteFailure
+		return result
+	}
+	defer s.closeSinks()
 
-	// Get all users
-	users, err := platform.GetAllUsers()
+	// Get all users (or just the current one for per-user installs)
+	enumStarted := time.Now()
+	users, err := s.getUsers()
+	result.Enumeration = time.Since(enumStarted)
 	if err != nil {
-		s.logger.Printf("Error: failed to enumerate users: %v", err)
+		s.logger.Errorf("failed to enumerate users: %v", err)
 		result.Errors = append(result.Errors, fmt.Sprintf("user enumeration failed: %v", err))
 		result.ExitCode = ExitCompleteFailure
 		return result
 	}
 
 	if len(users) == 0 {
-		s.logger.Println("No users found")
+		s.logger.Infof("No users found")
 		result.ExitCode = ExitCompleteFailure
 		return result
 	}
 
-	s.logger.Printf("Found %d users to scan", len(users))
+	if users = s.filter.SelectUsers(users); len(users) == 0 {
+		s.logger.Infof("No users match %s", strings.Join(s.filter.Users, ", "))
+		result.Errors = append(result.Errors, "no users match the filter")
+		result.ExitCode = ExitCompleteFailure
+		return result
+	}
+
+	s.logger.Infof("Found %d users to scan", len(users))
 
-	// Get all browsers
-	browsers := browser.All()
+	// Get all browsers (or the filtered ones)
+	browsers := s.filter.SelectBrowsers(browser.All())
+	s.listProcesses()
 
 	successCount := 0
 	failureCount := 0
 
 	// Scan each user
 	for _, user := range users {
+		if err := s.ctx.Err(); err != nil {
+			s.logger.Warnf("Scan canceled: %v", err)
+			result.Errors = append(result.Errors, fmt.Sprintf("scan canceled: %v", err))
+			failureCount++
+			break
+		}
+		if s.limitReached() {
+			s.logger.Infof("Entry limit of %d reached, stopping", s.limit)
+			break
+		}
 		result.UsersScanned++
-		s.logger.Printf("Scanning user: %s", user.Username)
+		// Time not spent in the user's profiles went into finding them
+		userStarted, scanned := time.Now(), len(result.Profiles)
+		successes, failures := s.scanUser(user, browsers, result)
+		result.Enumeration += time.Since(userStarted) - profileTime(result.Profiles[scanned:])
+		successCount += successes
+		failureCount += failures
+	}
+	deferredSent, deferredFailed := s.scanDeferred(result)
+	successCount += deferredSent
+	failureCount += deferredFailed
+	dnsRead, dnsFailed := s.collectDNS(result)
+	successCount += dnsRead
+	failureCount += dnsFailed
+	if err := s.flushSinks(); err != nil {
+		s.logger.Errorf("%v", err)
+		result.Errors = append(result.Errors, err.Error())
+		failureCount++
+	}
+	s.completeScan()
+	if err := s.flushAggregates(); err != nil {
+		s.logger.Errorf("%v", err)
+		result.Errors = append(result.Errors, err.Error())
+		if !s.sendVisits {
+			// Nothing of the run reached the server
+			successCount, failureCount = 0, failureCount+successCount
+			result.EntriesSent = 0
+		} else {
+			failureCount++
+		}
+	}
+	result.Dropped = s.dropped
+	result.DomainsSeen, result.NewDomains = len(s.seenDomains), s.newDomains
+	s.reportAIUsage()
 
-		// Scan each browser for this user
-		for _, b := range browsers {
-			profiles, err := b.FindProfiles(user)
-			if err != nil {
-				s.logger.Printf("Error finding %s profiles for %s: %v", b.Name(), user.Username, err)
-				continue
-			}
+	// Determine exit code
+	if successCount == 0 && failureCount > 0 {
+		result.ExitCode = ExitCompleteFailure
+	} else if failureCount > 0 {
+		result.ExitCode = ExitPartialFailure
+	} else {
+		result.ExitCode = ExitSuccess
+	}
+
+	if hasDenied(result.Access) && !platform.IsPrivileged() {
+		s.logger.Warnf("some browser data could not be read; %s", platform.PrivilegeAdvice())
+	}
+
+	return result
+}
+
+// newScanID returns a random id correlating the log records, run record and
+// run report of one scan
+func newScanID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// sendReport posts a compact summary of the run to the status endpoint.
+// Failures are only logged; they do not change the run's exit code.
+func (s *Scanner) sendReport(started time.Time, result *ScanResult) {
+	hostname := platform.Hostname()
+	report := dto.RunReportDTO{
+		ScanID:          s.scanID,
+		Scanner:         s.build(),
+		Source:          s.cfg.Source,
+		Host:            hostname,
+		DeviceID:        s.deviceInfo().ID,
+		Started:         started.UnixMilli(),
+		DurationMS:      time.Since(started).Milliseconds(),
+		ExitCode:        int(result.ExitCode),
+		Full:            s.full,
+		UsersScanned:    result.UsersScanned,
+		ProfilesScanned: result.ProfilesScanned,
+		EntriesSent:     result.EntriesSent,
+		ChunksSent:      s.client.ChunksSent(),
+		Errors:          truncateErrors(result.Errors),
+		ErrorCategories: result.ErrorCategories(),
+		Timing:          reportTiming(result),
+		Dropped:         result.Dropped,
+		DomainsSeen:     result.DomainsSeen,
+		NewDomainCount:  len(result.NewDomains),
+		NewDomains:      s.newDomainsReport(result.NewDomains),
+	}
+	if report.Errors == nil {
+		report.Errors = []string{}
+	}
+	for _, c := range result.Corrupt {
+		report.Corrupt = append(report.Corrupt, dto.CorruptDTO{
+			User:        c.Username,
+			Browser:     c.Browser,
+			Profile:     c.Profile,
+			SkippedRows: c.SkippedRows,
+			Problem:     c.Problem,
+		})
+	}
+	for _, pe := range result.ProfileErrors[:min(len(result.ProfileErrors), maxRunErrors)] {
+		report.ProfileErrors = append(report.ProfileErrors, dto.ProfileErrorDTO{
+			User:     pe.Username,
+			Browser:  pe.Browser,
+			Profile:  pe.Profile,
+			Category: pe.Category,
+			Severity: pe.Severity,
+			Error:    pe.Message,
+		})
+	}
+	for _, skip := range result.Skipped {
+		report.Skipped = append(report.Skipped, dto.SkippedUserDTO{
+			User:   skip.Username,
+			Reason: skip.Reason,
+			Detail: skip.Detail,
+		})
+	}
+	for _, p := range result.Postponed {
+		report.Postponed = append(report.Postponed, dto.PostponedDTO{
+			User:    p.Username,
+			Browser: p.Browser,
+			Profile: p.Profile,
+			Runs:    p.Runs,
+		})
+	}
+
+	if err := s.client.SendReport(s.cfg.StatusURL, report); err != nil {
+		s.logger.Warnf("failed to send run report: %v", err)
+	}
+}
+
+// sendWebhook posts a signed summary of the run to the webhook, always or,
+// with webhook_on: failure, only for runs that did not fully succeed.
+// Failures are only logged.
+func (s *Scanner) sendWebhook(started time.Time, result *ScanResult) {
+	if s.cfg.WebhookOn == "failure" && result.ExitCode == ExitSuccess {
+		return
+	}
+	status := "success"
+	switch result.ExitCode {
+	case ExitPartialFailure:
+		status = "partial"
+	case ExitCompleteFailure:
+		status = "failed"
+	}
+	hostname := platform.Hostname()
+	summary := dto.ScanWebhookDTO{
+		Event:           "scan.completed",
+		Status:          status,
+		ScanID:          s.scanID,
+		Source:          s.cfg.Source,
+		Host:            hostname,
+		DeviceID:        s.deviceInfo().ID,
+		Started:         started.UnixMilli(),
+		Finished:        time.Now().UnixMilli(),
+		DurationMS:      time.Since(started).Milliseconds(),
+		ExitCode:        int(result.ExitCode),
+		UsersScanned:    result.UsersScanned,
+		UsersSkipped:    len(result.Skipped),
+		ProfilesScanned: result.ProfilesScanned,
+		EntriesSent:     result.EntriesSent,
+		ErrorCount:      len(result.Errors),
+		Errors:          truncateErrors(result.Errors),
+		ErrorCategories: result.ErrorCategories(),
+	}
+	if summary.Errors == nil {
+		summary.Errors = []string{}
+	}
+	if err := s.client.SendWebhook(s.cfg.WebhookURL, s.cfg.WebhookSecret, summary); err != nil {
+		s.logger.Warnf("failed to send scan webhook: %v", err)
+	}
+}
+
+// truncateErrors keeps the first maxRunErrors errors and notes how many were dropped
+func truncateErrors(errs []string) []string {
+	if len(errs) <= maxRunErrors {
+		return errs
+	}
+	kept := append([]string{}, errs[:maxRunErrors]...)
+	return append(kept, fmt.Sprintf("... and %d more", len(errs)-maxRunErrors))
+}
+
+// scanUser scans all browser profiles of one user, adding entries and errors
+// to result. It returns the number of profiles sent and failed.
+func (s *Scanner) scanUser(user platform.User, browsers []browser.Browser, result *ScanResult) (int, int) {
+	ulog := s.logger.With("user", user.Username)
+	ulog.Infof("Scanning user: %s", user.Username)
+
+	fail := func(browserName, category, errMsg string) int {
+		return s.recordError(ulog, result, ProfileError{Username: user.Username, Browser: browserName, Category: category, Message: errMsg})
+	}
+	skip := func(reason, detail string) {
+		result.Skipped = append(result.Skipped, SkippedUser{Username: user.Username, Reason: reason, Detail: detail})
+		ulog.With("reason", reason).Infof("Skipping user %s: %s (%s)", user.Username, reason, detail)
+	}
+
+	// Users excluded by policy are reported, not silently left out
+	if s.listedExcluded(user) {
+		skip(SkipExcluded, "listed in excluded_users")
+		return 0, 0
+	}
+
+	// Disabled accounts keep their profiles, but nobody browses with them
+	if s.cfg.SkipDisabledAccounts && user.Root == "" {
+		if detail := platform.AccountDisabled(user); detail != "" {
+			skip(SkipDisabled, detail)
+			return 0, 0
+		}
+	}
+
+	// Attach FSLogix profile containers of signed-out users read-only
+	if user.Container != "" {
+		home, detach, err := platform.MountContainer(user.Container)
+		if err != nil {
+			return 0, fail("", errorCategory(err), fmt.Sprintf("%s: %v", user.Username, err))
+		}
+		defer detach()
+		user.HomeDir = home
+	}
+
+	// Encrypted homes of signed-out users cannot be read; they are not errors
+	if detail := platform.LockedHome(user); detail != "" && user.Root == "" {
+		skip(SkipEncrypted, detail)
+		return 0, 0
+	}
+
+	// Skip homes on unmounted or unresponsive network filesystems
+	if err := checkUserDirs(user); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			skip(SkipUnavailable, "home directory not available")
+			return 0, 0
+		}
+		return 0, fail("", errorCategory(err), fmt.Sprintf("%s: %v", user.Username, err))
+	}
+
+	if detail := s.optedOut(user, ulog); detail != "" {
+		skip(SkipExcluded, detail)
+		return 0, 0
+	}
+
+	// Report data that exists but cannot be read instead of finding no profiles
+	access := CheckAccess(user, browsers)
+	result.Access = append(result.Access, access...)
+	denied := 0
+	for _, a := range access {
+		if a.Status == AccessDenied {
+			denied += fail(a.Browser, categoryPermission, fmt.Sprintf("%s: permission denied reading %s", user.Username, a.Path))
+		}
+	}
+	if len(access) == 1 && access[0].Browser == "" {
+		return 0, denied // The home itself is unreadable
+	}
+
+	// Find the profiles of each browser for this user
+	var found []browserProfiles
+	for _, b := range browsers {
+		profiles, err := b.FindProfiles(user)
+		if err != nil {
+			s.logger.Errorf("failed to find %s profiles for %s: %v", b.Name(), user.Username, err)
+			continue
+		}
+		if profiles = s.filter.SelectProfiles(profiles); len(profiles) > 0 {
+			found = append(found, browserProfiles{browser: b, profiles: profiles})
+		}
+	}
+
+	// Skip users who have not browsed in a long time (e.g. former contractors)
+	if s.cfg.StaleDays > 0 {
+		cutoff := time.Now().AddDate(0, 0, -s.cfg.StaleDays)
+		if last, ok := lastHistoryChange(found); ok && last.Before(cutoff) {
+			skip(SkipStale, "no history change since "+last.Format(time.DateOnly))
+			return 0, 0
+		}
+	}
 
-			if len(profiles) == 0 {
+	successes, failures := 0, denied
+
+	// Scan each profile
+	for _, bp := range found {
+		b := bp.browser
+		s.logger.Debugf("%s: %d profile(s) of %s", b.Name(), len(bp.profiles), user.Username)
+		for _, profile := range bp.profiles {
+			if s.ctx.Err() != nil || s.limitReached() {
+				break
+			}
+			if s.browserRunning(user, b) {
+				s.deferred = append(s.deferred, deferredProfile{user: user, browser: b, profile: profile})
+				s.profileLogger(user, b, profile).Debugf("%s/%s: %s is running, scanning the profile at the end of the run", b.Name(), profile.Name, b.Name())
 				continue
 			}
+			switch s.runProfile(user, b, profile, result) {
+			case profileSent:
+				successes++
+			case profileFailed:
+				failures++
+			}
+		}
+	}
 
-			// Scan each profile
-			for _, profile := range profiles {
-				result.ProfilesScanned++
-
-				sent, err := s.scanProfile(user, b, profile)
-				if err != nil {
-					failureCount++
-					errMsg := fmt.Sprintf("%s/%s/%s: %v", user.Username, b.Name(), profile.Name, err)
-					result.Errors = append(result.Errors, errMsg)
-					s.logger.Printf("Error: %s", errMsg)
-					continue
-				}
+	return successes, failures
+}
+
+// Outcomes of runProfile
+const (
+	profileEmpty  = iota // Scanned, nothing new to send
+	profileSent          // Scanned and sent entries
+	profileFailed        // Failed; the error is in the result
+	profileWarned        // Failed with an error of warning severity, which does not fail the run
+)
+
+// runProfile scans one profile of a user and records its stats and error
+// in result
+func (s *Scanner) runProfile(user platform.User, b browser.Browser, profile browser.Profile, result *ScanResult) int {
+	result.ProfilesScanned++
+	if !s.dryRun && !s.ranged && s.state.GetPostponed(stateUser(user), b.Name(), profile.Name) > 0 {
+		s.state.SetPostponed(stateUser(user), b.Name(), profile.Name, 0)
+	}
+
+	s.startProfile()
+	started := time.Now()
+	sent, err := s.scanProfile(user, b, profile, result)
+	result.EntriesSent += sent
+	stats := s.finishProfile(user, b, profile, started, sent)
+	result.addProfile(stats)
+	plog := s.profileLogger(user, b, profile).With(stats.attrs()...)
+	if err != nil {
+		errMsg := fmt.Sprintf("%s/%s/%s: %v", user.Username, b.Name(), profile.Name, err)
+		pe := ProfileError{Username: user.Username, Browser: b.Name(), Profile: profile.Name, Category: errorCategory(err), Message: errMsg}
+		if s.recordError(plog, result, pe) == 0 {
+			return profileWarned
+		}
+		return profileFailed
+	}
+	plog.Debugf("%s/%s: done in %s (%s)", b.Name(), profile.Name, roundMS(stats.Duration), stats.Phases)
 
-				result.EntriesSent += sent
-				if sent > 0 {
-					successCount++
+	if sent > 0 {
+		return profileSent
+	}
+	return profileEmpty
+}
+
+// profileLogger returns the logger for records about one profile
+func (s *Scanner) profileLogger(user platform.User, b browser.Browser, profile browser.Profile) *logging.Logger {
+	return s.logger.With("user", user.Username, "browser", b.Name(), "profile", profile.Name)
+}
+
+// Error categories of failed profiles, as listed in config.ErrorCategories
+const (
+	categoryPermission = "permission"   // Profile data not readable by the scanner
+	categoryLocked     = "locked"       // Database locked by the running browser, and not copied
+	categorySchema     = "schema"       // Database lacks a table or column the scanner reads
+	categoryCorrupt    = "corrupt"      // Damaged database that could not be salvaged
+	categoryTimeout    = "timeout"      // Query exceeded query_timeout
+	categoryCopy       = "copy_refused" // Locked database too large or no space to copy it
+	categorySend       = "send"         // Server or network failure
+	categoryRead       = "read"         // Any other failure reading history
+)
+
+// errSendFailed marks errors from sending history to the server
+var errSendFailed = errors.New("failed to send")
+
+// errorCategory classifies a profile error for log records
+func errorCategory(err error) string {
+	switch {
+	case errors.Is(err, errSendFailed):
+		return categorySend
+	case errors.Is(err, os.ErrPermission):
+		return categoryPermission
+	case db.IsCorrupt(err):
+		return categoryCorrupt
+	case errors.Is(err, db.ErrQueryTimeout):
+		return categoryTimeout
+	case errors.Is(err, db.ErrTooLarge), errors.Is(err, db.ErrNoSpace):
+		return categoryCopy
+	case db.IsLocked(err):
+		return categoryLocked
+	case db.IsUnsupportedSchema(err):
+		return categorySchema
+	}
+	return categoryRead
+}
+
+// severity returns the severity error_severity sets for an error category
+func (s *Scanner) severity(category string) string {
+	severities, _ := s.cfg.ErrorSeverities()
+	if severity, ok := severities[category]; ok {
+		return severity
+	}
+	return config.SeverityError
+}
+
+// recordError adds an error to result and logs it at the level of its
+// category's severity. It returns 1 if the error fails the run and 0 if it
+// is a warning.
+func (s *Scanner) recordError(log *logging.Logger, result *ScanResult, pe ProfileError) int {
+	pe.Severity = s.severity(pe.Category)
+	result.Errors = append(result.Errors, pe.Message)
+	result.ProfileErrors = append(result.ProfileErrors, pe)
+	log = log.With("category", pe.Category)
+	if pe.Severity == config.SeverityWarning {
+		log.Warnf("%s", pe.Message)
+		return 0
+	}
+	log.Errorf("%s", pe.Message)
+	return 1
+}
+
+// browserProfiles holds the profiles found for one browser
+type browserProfiles struct {
+	browser  browser.Browser
+	profiles []browser.Profile
+}
+
+// lastHistoryChange returns the newest modification time of the history
+// databases (and their WAL files) of the found profiles. It returns false
+// if there are no profiles or a browser cannot report its history file.
+func lastHistoryChange(found []browserProfiles) (time.Time, bool) {
+	var last time.Time
+	for _, bp := range found {
+		filer, ok := bp.browser.(browser.HistoryFiler)
+		if !ok {
+			return time.Time{}, false
+		}
+		for _, profile := range bp.profiles {
+			path := filer.HistoryFile(profile)
+			for _, file := range []string{path, path + "-wal"} {
+				if info, err := os.Stat(file); err == nil && info.ModTime().After(last) {
+					last = info.ModTime()
 				}
 			}
 		}
 	}
+	return last, !last.IsZero()
+}
 
-	// Save state
-	if err := s.state.Save(); err != nil {
-		s.logger.Printf("Warning: failed to save state: %v", err)
+// appendNewUsers appends the users whose name or SID is not in users yet
+// (signed-in users appear both locally and in their profile store)
+func appendNewUsers(users, more []platform.User) []platform.User {
+	known := make(map[string]bool)
+	for _, u := range users {
+		known[strings.ToLower(u.Username)] = true
+		if u.SID != "" {
+			known[u.SID] = true
+		}
 	}
+	for _, u := range more {
+		if known[strings.ToLower(u.Username)] || (u.SID != "" && known[u.SID]) {
+			continue
+		}
+		known[strings.ToLower(u.Username)] = true
+		users = append(users, u)
+	}
+	return users
+}
 
-	// Determine exit code
-	if successCount == 0 && failureCount > 0 {
-		result.ExitCode = ExitCompleteFailure
-	} else if failureCount > 0 {
-		result.ExitCode = ExitPartialFailure
+// stateUser returns the state key user name. Users scanned across WSL or in
+// a disk image are prefixed with their layout or image root so they never
+// share watermarks with a local user of the same name. Users of a host
+// mounted into a container keep their own name, as on the host. Local
+// Windows profiles are keyed by their folder name: accounts of two domains
+// may share a name (CONTOSO\jsmith, FABRIKAM\jsmith), but not a profile
+// folder, and the folder name does not depend on reaching the domain
+// controller.
+func stateUser(user platform.User) string {
+	if user.Host {
+		return user.Username
+	}
+	if user.SID != "" && user.HomeDir != "" && user.ProfileStore == "" && user.Root == "" && user.Layout == "" {
+		return filepath.Base(user.HomeDir)
+	}
+	if user.Root != "" {
+		return user.Root + ":" + user.Username
+	}
+	if user.Layout != "" {
+		return string(user.Layout) + ":" + user.Username
+	}
+	return user.Username
+}
+
+// checkUserDirs checks that the user's home and any redirected AppData
+// folders (e.g. on a file share) are reachable
+func checkUserDirs(user platform.User) error {
+	if user.HomeDir == "" {
+		return nil
+	}
+	for _, dir := range []string{user.HomeDir, user.RoamingAppData, user.LocalAppData} {
+		if dir == "" {
+			continue
+		}
+		if err := platform.CheckHome(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getUsers returns the users to scan
+func (s *Scanner) getUsers() ([]platform.User, error) {
+	if s.root != "" {
+		return platform.ImageUsers(s.root, s.rootUsers)
+	}
+	if s.cfg.HostRoot != "" {
+		return hostUsers(s.cfg.HostRoot)
+	}
+
+	var users []platform.User
+	if s.cfg.CurrentUserOnly {
+		u, err := platform.GetCurrentUser()
+		if err != nil {
+			return nil, err
+		}
+		users = []platform.User{*u}
 	} else {
-		result.ExitCode = ExitSuccess
+		var err error
+		if users, err = platform.GetAllUsers(); err != nil {
+			return nil, err
+		}
 	}
 
-	s.logger.Printf("Scan complete: %d entries sent, %d errors", result.EntriesSent, len(result.Errors))
+	// Add signed-out users from FSLogix / Citrix UPM profile stores
+	if s.cfg.ProfileStores && !s.cfg.CurrentUserOnly {
+		storeUsers, err := platform.GetProfileStoreUsers()
+		if err != nil {
+			s.logger.Warnf("failed to read profile stores: %v", err)
+		}
+		users = appendNewUsers(users, storeUsers)
+	}
 
-	return result
+	// Add the users on the other side of WSL
+	if (platform.IsWSL() && s.cfg.WSLWindowsProfiles) || (platform.CurrentOS() == platform.Windows && s.cfg.WSLDistros) {
+		wslUsers, err := platform.GetWSLUsers(s.cfg.CurrentUserOnly)
+		if err != nil {
+			s.logger.Warnf("failed to enumerate WSL users: %v", err)
+		}
+		users = append(users, wslUsers...)
+	}
+
+	return users, nil
+}
+
+// hostUsers returns the users of the host mounted at root, enumerated like
+// those of a disk image from its etc/passwd and home or its Users directory
+func hostUsers(root string) ([]platform.User, error) {
+	users, err := platform.ImageUsers(root, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enumerate the users of the host at %s: %w", root, err)
+	}
+	for i := range users {
+		users[i].Host = true
+	}
+	return users, nil
+}
+
+// rangeString describes the manual time range for the log
+func (s *Scanner) rangeString() string {
+	since, until := "initial_days ago", "now"
+	if !s.since.IsZero() {
+		since = s.since.Format(time.DateTime)
+	}
+	if !s.until.IsZero() {
+		until = s.until.Format(time.DateTime)
+	}
+	return since + " to " + until
+}
+
+// scanRange scans a profile's visits in the manual time range
+func (s *Scanner) scanRange(user platform.User, b browser.Browser, profile browser.Profile) (int, error) {
+	since := s.since
+	if since.IsZero() {
+		since = s.initialSince()
+	}
+	// GetHistory returns visits after the cursor; include the first millisecond
+	cursor := browser.Cursor{Timestamp: since.UnixMilli() - 1}
+	until := s.until.UnixMilli()
+
+	return s.sendHistory(user, b, profile, false, func(fn browser.VisitFunc) error {
+		return b.StreamHistory(s.ctx, profile, cursor, func(site dto.VisitedSite) error {
+			if !s.until.IsZero() && site.Timestamp >= until {
+				return nil
+			}
+			return fn(site)
+		})
+	})
 }
 
 // scanProfile scans a single browser profile and sends the results
-func (s *Scanner) scanProfile(user platform.User, b browser.Browser, profile browser.Profile) (int, error) {
-	// Get last scan timestamp
-	lastTimestamp := s.state.GetLastTimestamp(user.Username, b.Name(), profile.Name)
+func (s *Scanner) scanProfile(user platform.User, b browser.Browser, profile browser.Profile, result *ScanResult) (int, error) {
+	if s.ranged {
+		return s.scanRange(user, b, profile)
+	}
+
+	// Drop the watermark if the history database was cleared or recreated
+	fp := s.checkHistoryReset(user, b, profile, result)
+
+	// Get last scan position
+	last := browser.Cursor{
+		Timestamp: s.state.GetLastTimestamp(stateUser(user), b.Name(), profile.Name),
+		RowID:     s.state.GetLastRowID(stateUser(user), b.Name(), profile.Name),
+	}
+
+	// If no previous scan (or a full rescan), use initial_days config
+	since := last
+	if since.Timestamp == 0 || s.full {
+		since = browser.Cursor{Timestamp: s.initialSince().UnixMilli()}
+	}
+
+	s.profileLogger(user, b, profile).Debugf("%s/%s: reading history after %s (row id %d)", b.Name(), profile.Name,
+		time.UnixMilli(since.Timestamp).Format(time.DateTime), since.RowID)
+	sent, err := s.sendHistory(user, b, profile, false, func(fn browser.VisitFunc) error {
+		return b.StreamHistory(s.ctx, profile, since, fn)
+	})
+	if err == nil {
+		s.commitFingerprint(user, b, profile, fp)
+		return sent, nil
+	}
+	salvager, ok := b.(browser.Salvager)
+	if !db.IsCorrupt(err) || !ok {
+		return sent, err
+	}
+
+	// Recover what is readable from the damaged database, after the entries
+	// sent before the damage was hit
+	s.profileLogger(user, b, profile).With("category", categoryCorrupt).Warnf("%s/%s/%s: history database is damaged, salvaging: %v", user.Username, b.Name(), profile.Name, err)
+	if sent > 0 {
+		since = browser.Cursor{
+			Timestamp: s.state.GetLastTimestamp(stateUser(user), b.Name(), profile.Name),
+			RowID:     s.state.GetLastRowID(stateUser(user), b.Name(), profile.Name),
+		}
+	}
+
+	var info browser.Salvage
+	salvaged, err := s.sendHistory(user, b, profile, true, func(fn browser.VisitFunc) error {
+		var err error
+		info, err = salvager.SalvageHistory(profile, since, fn)
+		return err
+	})
+	sent += salvaged
+	if err != nil {
+		return sent, err
+	}
+
+	corrupt := CorruptProfile{Username: user.Username, Browser: b.Name(), Profile: profile.Name, SkippedRows: info.Skipped}
+	if len(info.Problems) > 0 {
+		corrupt.Problem = info.Problems[0]
+	}
+	result.Corrupt = append(result.Corrupt, corrupt)
+	s.profileLogger(user, b, profile).With("salvaged", salvaged, "skipped_rows", info.Skipped).Warnf("%s/%s/%s: salvaged %d entries, %d unreadable rows skipped", user.Username, b.Name(), profile.Name, salvaged, info.Skipped)
+
+	s.commitFingerprint(user, b, profile, fp)
+	return sent, nil
+}
 
-	// If no previous scan, use initial_days config
-	if lastTimestamp == 0 {
-		lastTimestamp = time.Now().AddDate(0, 0, -s.cfg.InitialDays).UnixMilli()
+// sendHistory sends the entries of a history stream in batches, advancing the
+// scan position after each one, so memory use does not grow with the history.
+// corrupt marks the entries as salvaged from a damaged database.
+func (s *Scanner) sendHistory(user platform.User, b browser.Browser, profile browser.Profile, corrupt bool, stream func(fn browser.VisitFunc) error) (int, error) {
+	sent, read := 0, 0
+	batch := make([]dto.VisitedSite, 0, historyBatchSize)
+	var sendErr error
+	flush := func() error {
+		n, err := s.sendEntries(user, b, profile, batch, corrupt)
+		sent += n
+		batch = batch[:0]
+		sendErr = err
+		return err
 	}
 
-	// Get history since last scan
-	entries, err := b.GetHistory(profile, lastTimestamp)
+	err := stream(func(site dto.VisitedSite) error {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+		if s.limitReached() {
+			return errLimitReached
+		}
+		s.taken++
+		read++
+		batch = append(batch, site)
+		if len(batch) >= historyBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+			s.checkpoint()
+		}
+		return nil
+	})
+	// Entries read before a read error are valid and sent as well
+	if sendErr == nil && len(batch) > 0 {
+		if flushErr := flush(); err == nil {
+			err = flushErr
+		}
+	}
+
+	plog := s.profileLogger(user, b, profile)
+	if read > 0 {
+		plog.With("entries", read).Infof("  %s/%s: %d new entries", b.Name(), profile.Name, read)
+	}
+	if errors.Is(err, errLimitReached) {
+		err = nil
+	}
+	if errors.Is(err, db.ErrTooManyRows) {
+		// The scan position covers the sent rows; the next run continues
+		plog.Infof("  %s/%s: row limit of %d reached, the rest is read on the next run", b.Name(), profile.Name, s.cfg.MaxRows)
+		err = nil
+	}
+	if sendErr != nil {
+		return sent, fmt.Errorf("%w: %w", errSendFailed, sendErr)
+	}
 	if err != nil {
-		return 0, fmt.Errorf("failed to get history: %w", err)
+		return sent, fmt.Errorf("failed to get history: %w", err)
 	}
 
+	return sent, nil
+}
+
+// checkpoint saves the scan positions reached so far, so that a run killed
+// in the middle of a large profile does not resend its sent batches
+func (s *Scanner) checkpoint() {
+	if s.dryRun || s.ranged {
+		return
+	}
+	if err := s.state.Save(); err != nil {
+		s.logger.Warnf("failed to save state: %v", err)
+	}
+}
+
+// sendEntries sends one batch of a profile's history (or prints it in dry-run
+// mode) and advances the profile's scan position to the sent entries
+func (s *Scanner) sendEntries(user platform.User, b browser.Browser, profile browser.Profile, entries []dto.VisitedSite, corrupt bool) (int, error) {
+	// Visits to excluded categories never leave the machine
+	entries, droppedTimestamp, droppedRowID := s.dropExcluded(entries)
 	if len(entries) == 0 {
+		if !s.sendVisits {
+			// Not past visits aggregated earlier and not sent yet
+			s.deferPosition(user, b, profile, droppedTimestamp, droppedRowID)
+		} else if !s.dryRun && !s.ranged && s.origin == nil {
+			s.advancePosition(user, b, profile, droppedTimestamp, droppedRowID)
+		}
 		return 0, nil
 	}
+	s.tagServices(entries)
+	// Imported, proxy and DNS visits were not made in a local browser profile
+	if s.origin == nil {
+		s.trackDomains(entries)
+		s.discoverServices(user, entries)
+		s.detectOAuth(user, b, profile, entries)
+		s.detectSignups(user, b, profile, entries)
+		s.countAITools(user, b, profile, entries)
+		s.checkWatchlist(user, b, profile, entries)
+	}
 
-	s.logger.Printf("  %s/%s: %d new entries", b.Name(), profile.Name, len(entries))
-
-	// Create principal
-	principal := dto.NewUserPrincipal(user.Username)
-	if user.Username == "" {
-		// Fallback to IP if username unknown
-		principal = dto.NewIPPrincipal(getLocalIP())
+	if s.sendAggregates {
+		s.aggregate(user, entries)
+	}
+	if !s.sendVisits {
+		maxTimestamp, maxRowID := maxPosition(entries)
+		s.deferPosition(user, b, profile, max(maxTimestamp, droppedTimestamp), max(maxRowID, droppedRowID))
+		return len(entries), nil
 	}
 
 	// Create payload
+	image := user.Root != "" && !user.Host
+	visits, clock, shift := s.normalizeTimestamps(entries, !image && (s.origin == nil || s.origin.imported == nil))
 	payload := dto.VisitedSitesDTO{
-		Principal:    principal,
-		Source:       s.cfg.Source,
-		VisitedSites: entries,
+		Principal:     s.principal(user),
+		Source:        s.cfg.Source,
+		VisitedSites:  visits,
+		Device:        s.deviceInfo(),
+		Corrupt:       corrupt,
+		SchemaVersion: dto.VisitsVersion,
+		Browser:       describeBrowser(b, profile),
+		ScanID:        s.scanID,
+		Clock:         clock,
+	}
+	if s.origin != nil {
+		payload.Import, payload.Proxy, payload.DNS = s.origin.imported, s.origin.proxy, s.origin.dns
+	}
+	if image {
+		payload.Image = &dto.ImageDTO{Root: user.Root, OS: string(user.HomeLayout())}
 	}
 
+	if s.dryRun && s.output != nil {
+		if err := s.output(payload); err != nil {
+			return 0, err
+		}
+		return len(entries), nil
+	}
 	if s.dryRun {
 		// In dry run, dump JSON to stdout
 		data, err := json.MarshalIndent(payload, "", "  ")
@@ -220,18 +1378,230 @@ func (s *Scanner) scanProfile(user platform.User, b browser.Browser, profile bro
 		return len(entries), nil
 	}
 
-	// Send to server
-	result, maxTimestamp, err := s.client.Send(payload)
+	// Send to the sinks
+	err := s.sink.Send(s.ctx, payload)
+	if m, ok := s.sink.(sink.Measurer); ok {
+		stats := m.TakeStats()
+		s.profile.Phases.Compress += stats.Encode
+		s.profile.Phases.HTTP += stats.Transfer
+		s.profile.Bytes += stats.Bytes
+		for _, w := range stats.Warnings {
+			s.logger.Warnf("%s", w)
+		}
+	}
+	sent := len(entries)
+	maxTimestamp, maxRowID := maxPosition(entries)
+	// Visits of an origin have no scan position to resume from, so a
+	// partially sent batch is sent again as a whole
+	var partial *sink.PartialError
+	if errors.As(err, &partial) && s.origin == nil {
+		sent, maxTimestamp, maxRowID = partial.Sent, partial.MaxTimestamp, partial.MaxRowID
+		if maxTimestamp > 0 {
+			// Positions are kept in the device's time
+			maxTimestamp -= shift
+		}
+		// Dropped entries may follow undelivered ones; they are dropped
+		// again by the next scan
+		droppedTimestamp, droppedRowID = 0, 0
+	} else if err != nil {
+		return 0, err
+	}
+
+	s.state.SetLastSend(time.Now())
+	if s.ranged || s.origin != nil {
+		return sent, nil
+	}
+
+	// Update state with the max timestamp and row id of sent and dropped entries
+	s.advancePosition(user, b, profile, max(maxTimestamp, droppedTimestamp), max(maxRowID, droppedRowID))
+
+	// The entries after the undelivered ones are left to the next scan, so
+	// the position never passes an entry that was not delivered
+	if partial != nil {
+		return sent, err
+	}
+	return sent, nil
+}
+
+// maxPosition returns the highest timestamp and row id of entries
+func maxPosition(entries []dto.VisitedSite) (int64, int64) {
+	var maxTimestamp, maxRowID int64
+	for _, e := range entries {
+		maxTimestamp, maxRowID = max(maxTimestamp, e.Timestamp), max(maxRowID, e.RowID)
+	}
+	return maxTimestamp, maxRowID
+}
+
+// checkHistoryReset compares the profile's history database fingerprint with the
+// one stored in state. If the database was recreated or emptied (history cleared),
+// the watermark is reset so the profile is rescanned from initial_days.
+// Recreated, cleared and substantially reduced histories are reported as history events.
+// It returns the fingerprint for commitFingerprint, or nil if there is none.
+func (s *Scanner) checkHistoryReset(user platform.User, b browser.Browser, profile browser.Profile, result *ScanResult) *browser.Fingerprint {
+	fpr, ok := b.(browser.Fingerprinter)
+	if !ok || s.dryRun {
+		return nil
+	}
+
+	fp, err := fpr.Fingerprint(profile)
 	if err != nil {
-		return 0, fmt.Errorf("failed to send: %w", err)
+		s.profileLogger(user, b, profile).Warnf("failed to fingerprint %s/%s: %v", b.Name(), profile.Name, err)
+		return nil
+	}
+
+	prevID, prevMaxRowID, prevRows := s.state.GetFingerprint(stateUser(user), b.Name(), profile.Name)
+	kind := historyEventKind(prevID, prevMaxRowID, prevRows, fp)
+	if kind != "" {
+		s.reportHistoryEvent(user, HistoryEvent{
+			Username:     user.Username,
+			Browser:      b.Name(),
+			Profile:      profile.Name,
+			Kind:         kind,
+			PrevRows:     prevRows,
+			Rows:         fp.Rows,
+			PrevMaxRowID: prevMaxRowID,
+			MaxRowID:     fp.MaxRowID,
+		}, result)
+	}
+	if kind == HistoryRecreated || kind == HistoryCleared {
+		s.profileLogger(user, b, profile).Infof("  %s/%s: history database was reset, rescanning last %d days", b.Name(), profile.Name, s.cfg.InitialDays)
+		s.state.ResetWatermark(stateUser(user), b.Name(), profile.Name)
+	}
+	return &fp
+}
+
+// commitFingerprint stores the fingerprint of a profile whose scan completed,
+// along with its scan position, so a failed scan finds the history event
+// again on the next run
+func (s *Scanner) commitFingerprint(user platform.User, b browser.Browser, profile browser.Profile, fp *browser.Fingerprint) {
+	if fp == nil {
+		return
+	}
+	if !s.sendVisits {
+		s.pending = append(s.pending, pendingPosition{user: user, browser: b, profile: profile, fingerprint: fp})
+		return
+	}
+	s.state.SetFingerprint(stateUser(user), b.Name(), profile.Name, fp.ID, fp.MaxRowID, fp.Rows)
+}
+
+// deviceInfo returns the device block sent with every payload. The id is
+// derived from the OS machine id; if there is none, a random id is generated
+// once and kept in the state file.
+func (s *Scanner) deviceInfo() *dto.DeviceDTO {
+	if s.device != nil {
+		return s.device
+	}
+
+	id, err := platform.DeviceID()
+	if err != nil {
+		id = s.state.GetDeviceID()
+		if id == "" {
+			buf := make([]byte, 16)
+			rand.Read(buf)
+			id = hex.EncodeToString(buf)
+			s.state.SetDeviceID(id)
+			s.logger.Warnf("machine id unavailable (%v), generated device id %s", err, id)
+		}
+	}
+
+	s.device = &dto.DeviceDTO{
+		ID:             id,
+		Hostname:       platform.Hostname(),
+		OS:             string(platform.HostOS()),
+		OSVersion:      platform.OSVersion(),
+		ScannerVersion: s.version,
+		Scanner:        s.build(),
+		Container:      platform.InContainer(),
+	}
+	if n := s.state.GetNotice(); !n.Shown.IsZero() {
+		s.device.NoticeShown = n.Shown.UnixMilli()
 	}
+	return s.device
+}
 
-	// Update state with the max timestamp of sent entries
-	if maxTimestamp > 0 {
-		s.state.SetLastTimestamp(user.Username, b.Name(), profile.Name, maxTimestamp)
+// describeBrowser returns the browser and profile of a payload, or nil for
+// visits not read from a browser
+func describeBrowser(b browser.Browser, profile browser.Profile) *dto.BrowserDTO {
+	if b == nil {
+		return nil
+	}
+	desc := &dto.BrowserDTO{Name: b.Name(), Profile: profile.Name}
+	if d, ok := b.(browser.Describer); ok {
+		info := d.Describe(profile)
+		desc.Version, desc.ProfileName = info.Version, info.DisplayName
 	}
+	return desc
+}
 
-	return result.TotalSent, nil
+// principal returns the principal of a user's payloads, falling back to the
+// IP address if the username is unknown. Imported and proxy visits have the
+// principal of their origin.
+func (s *Scanner) principal(user platform.User) dto.PrincipalDTO {
+	if s.origin != nil {
+		return s.origin.principal
+	}
+	if user.Username == "" {
+		return dto.NewIPPrincipal(getLocalIP())
+	}
+	principal := dto.NewUserPrincipal(user.Username)
+	principal.Identity = s.identity(user)
+	return principal
+}
+
+// identity returns the directory identity of a user, or nil for local accounts
+// on machines without a directory join. Lookups are cached per account since
+// they may query a domain controller.
+func (s *Scanner) identity(user platform.User) *dto.IdentityDTO {
+	// Directories are queried about the running system, not an image
+	if user.Root != "" {
+		return nil
+	}
+	if id, ok := s.identities[identityKey(user)]; ok {
+		return id
+	}
+
+	var id *dto.IdentityDTO
+	if pid := platform.GetIdentity(user); pid != nil {
+		id = &dto.IdentityDTO{
+			Account:   pid.Account,
+			UPN:       pid.UPN,
+			Domain:    pid.Domain,
+			Directory: pid.Directory,
+		}
+	}
+
+	if join := platform.GetDeviceJoin(); join != nil {
+		if id == nil {
+			id = &dto.IdentityDTO{}
+		}
+		id.Device = &dto.DeviceJoinDTO{
+			JoinType:   join.Type,
+			Domain:     join.Domain,
+			TenantID:   join.TenantID,
+			TenantName: join.TenantName,
+			DeviceID:   join.DeviceID,
+		}
+	}
+
+	if id != nil {
+		id.SID = user.SID
+	}
+
+	s.identities[identityKey(user)] = id
+	return id
+}
+
+// identityKey identifies a user's account for the identity cache: by SID,
+// which tells apart accounts of two domains with the same name, otherwise by
+// home directory
+func identityKey(user platform.User) string {
+	if user.SID != "" {
+		return user.SID
+	}
+	if user.HomeDir != "" {
+		return user.HomeDir
+	}
+	return stateUser(user)
 }
 
 // getLocalIP returns the local IP address with hostname fallback
@@ -262,7 +1632,7 @@ func getLocalIP() string {
 	}
 
 	// Get hostname
-	hostname, _ := os.Hostname()
+	hostname := platform.Hostname()
 
 	// Return ip/hostname or just hostname as fallback
 	if ip != "" && hostname != "" {
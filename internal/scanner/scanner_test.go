@@ -0,0 +1,250 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner_test
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"hist_scanner/internal/config"
+	"hist_scanner/internal/db"
+	"hist_scanner/internal/dto"
+	"hist_scanner/internal/fixture"
+	"hist_scanner/internal/scanner"
+)
+
+// uploadServer accepts visit uploads and counts each visit received. The
+// visit chunk numbered failChunk (from 1) is rejected.
+type uploadServer struct {
+	mu        sync.Mutex
+	visits    map[string]int
+	chunks    int
+	failChunk int
+}
+
+func (u *uploadServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+	var payload dto.VisitedSitesDTO
+	if err := json.NewDecoder(body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(payload.VisitedSites) == 0 {
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.chunks++
+	if u.chunks == u.failChunk {
+		http.Error(w, "chunk rejected", http.StatusInternalServerError)
+		return
+	}
+	for _, site := range payload.VisitedSites {
+		u.visits[visitKey(payload, site)]++
+	}
+}
+
+// received returns the visits received so far and forgets them
+func (u *uploadServer) received() map[string]int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	visits := u.visits
+	u.visits = make(map[string]int)
+	return visits
+}
+
+// visitKey identifies a visit of a payload
+func visitKey(payload dto.VisitedSitesDTO, site dto.VisitedSite) string {
+	var browser, profile string
+	if payload.Browser != nil {
+		browser, profile = payload.Browser.Name, payload.Browser.Profile
+	}
+	return fmt.Sprintf("%s/%s/%s %d %s", payload.Principal.Name, browser, profile, site.Timestamp, site.URL)
+}
+
+// setup generates a fixture root of visits per profile and returns it with
+// its databases and a config that uploads to a new server
+func setup(t *testing.T, visits int) (string, []fixture.Database, *config.Config, *uploadServer) {
+	t.Helper()
+	root := t.TempDir()
+	dbs, err := fixture.Generate(fixture.Options{
+		Root:     root,
+		Users:    []string{"alice"},
+		Browsers: []string{"chrome", "firefox"},
+		Profiles: 1,
+		Visits:   visits,
+		Days:     3,
+		Seed:     1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &uploadServer{visits: make(map[string]int)}
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+
+	cfg := config.DefaultConfig()
+	cfg.ServerURL = ts.URL
+	cfg.APIKey = "test"
+	cfg.StateFile = filepath.Join(t.TempDir(), "state.json")
+	cfg.InitialDays = 30
+	cfg.RunningBrowsers = "scan"
+	return root, dbs, cfg, srv
+}
+
+// countQueries count the http and https visits of a database, which are
+// the visits of a fixture the scanner keeps
+var countQueries = map[string]string{
+	"chrome":  "SELECT COUNT(*) FROM visits JOIN urls ON urls.id = visits.url WHERE urls.url LIKE 'http%'",
+	"firefox": "SELECT COUNT(*) FROM moz_historyvisits JOIN moz_places ON moz_places.id = moz_historyvisits.place_id WHERE moz_places.url LIKE 'http%'",
+}
+
+// kept returns how many visits of dbs a scan sends
+func kept(t *testing.T, dbs []fixture.Database) int {
+	t.Helper()
+	total := 0
+	for _, d := range dbs {
+		database, err := db.Open(d.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n int
+		err = database.QueryRow(countQueries[d.Browser], nil, &n)
+		database.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += n
+	}
+	return total
+}
+
+// run scans root once with cfg
+func run(t *testing.T, cfg *config.Config, root string, dryRun bool, output func(dto.VisitedSitesDTO) error) *scanner.ScanResult {
+	t.Helper()
+	s, err := scanner.New(cfg, dryRun)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.SetRoot(root, nil)
+	if output != nil {
+		s.SetDryRunOutput(output)
+	}
+	return s.Run(context.Background())
+}
+
+// expected returns the visits a scan of root reads, from a dry run, after
+// checking that it reads each visit of dbs it keeps once
+func expected(t *testing.T, cfg *config.Config, root string, dbs []fixture.Database) map[string]int {
+	t.Helper()
+	visits := make(map[string]int)
+	result := run(t, cfg, root, true, func(payload dto.VisitedSitesDTO) error {
+		for _, site := range payload.VisitedSites {
+			visits[visitKey(payload, site)]++
+		}
+		return nil
+	})
+	if result.ExitCode != scanner.ExitSuccess {
+		t.Fatalf("dry run failed: %v", result.Errors)
+	}
+	if want := kept(t, dbs); len(visits) != want {
+		t.Fatalf("dry run read %d visits, want %d", len(visits), want)
+	}
+	for key, n := range visits {
+		if n > 1 {
+			t.Fatalf("dry run read %s %d times", key, n)
+		}
+	}
+	return visits
+}
+
+// TestRunIncremental checks that a scan sends every visit of profiles read
+// over several history pages once, and that the next scan sends nothing
+func TestRunIncremental(t *testing.T) {
+	root, dbs, cfg, srv := setup(t, 12000)
+	want := expected(t, cfg, root, dbs)
+
+	result := run(t, cfg, root, false, nil)
+	if result.ExitCode != scanner.ExitSuccess {
+		t.Fatalf("scan failed: %v", result.Errors)
+	}
+	got := srv.received()
+	if result.EntriesSent != len(want) || len(got) != len(want) {
+		t.Fatalf("sent %d entries, received %d visits, want %d", result.EntriesSent, len(got), len(want))
+	}
+	for key := range want {
+		if got[key] != 1 {
+			t.Errorf("visit %s received %d times, want 1", key, got[key])
+		}
+	}
+
+	result = run(t, cfg, root, false, nil)
+	if result.ExitCode != scanner.ExitSuccess {
+		t.Fatalf("second scan failed: %v", result.Errors)
+	}
+	if result.EntriesSent != 0 {
+		t.Errorf("second scan sent %d entries, want 0", result.EntriesSent)
+	}
+	for key := range srv.received() {
+		t.Errorf("visit sent again: %s", key)
+	}
+}
+
+// TestRunAfterFailedChunk checks that when a chunk uploaded alongside others
+// is rejected, the next scan sends the visits it and the chunks after it
+// held, so that no visit is lost
+func TestRunAfterFailedChunk(t *testing.T) {
+	root, dbs, cfg, srv := setup(t, 6000)
+	cfg.UploadConcurrency = 4
+	cfg.ChunkSizeKB = 16
+	want := expected(t, cfg, root, dbs)
+
+	srv.failChunk = 3
+	result := run(t, cfg, root, false, nil)
+	if result.ExitCode == scanner.ExitSuccess {
+		t.Fatal("scan with a rejected chunk succeeded")
+	}
+	got := srv.received()
+	if len(got) == 0 || len(got) == len(want) {
+		t.Fatalf("received %d of %d visits with a rejected chunk", len(got), len(want))
+	}
+
+	result = run(t, cfg, root, false, nil)
+	if result.ExitCode != scanner.ExitSuccess {
+		t.Fatalf("second scan failed: %v", result.Errors)
+	}
+	for key, n := range srv.received() {
+		got[key] += n
+	}
+	for key := range want {
+		if got[key] == 0 {
+			t.Errorf("visit never received: %s", key)
+		}
+	}
+
+	result = run(t, cfg, root, false, nil)
+	if result.EntriesSent != 0 {
+		t.Errorf("third scan sent %d entries, want 0", result.EntriesSent)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"hist_scanner/internal/browser"
+	"hist_scanner/internal/catalog"
+	"hist_scanner/internal/dto"
+	"hist_scanner/internal/platform"
+)
+
+// Signup is the kind of events reporting a likely account creation at an
+// unsanctioned service
+const Signup = "signup"
+
+// highPriority ranks sign-ups and escalated events above other shadow-IT
+// events: an account holds data, a visit may not
+const highPriority = "high"
+
+// compileSignupPatterns compiles signup_patterns, skipping invalid ones
+// (Validate rejects them)
+func compileSignupPatterns(patterns []string) []*regexp.Regexp {
+	var res []*regexp.Regexp
+	for _, p := range patterns {
+		if re, err := regexp.Compile(p); err == nil {
+			res = append(res, re)
+		}
+	}
+	return res
+}
+
+// signupVisit is the first sign-up page visit of a domain in a batch
+type signupVisit struct {
+	domain  string
+	service *catalog.Service // nil for domains outside the catalog
+	pattern string
+	time    int64
+}
+
+// detectSignups reports the sign-up pages of unsanctioned domains in a
+// batch that were never reported for the user. A sign-up is recorded in
+// state once it was logged and, with events_url, posted; a failed post is
+// retried at the next visit.
+func (s *Scanner) detectSignups(user platform.User, b browser.Browser, profile browser.Profile, entries []dto.VisitedSite) {
+	if s.signups == nil || s.dryRun {
+		return
+	}
+
+	found := make(map[string]*signupVisit)
+	var order []string
+	for _, e := range entries {
+		u, err := url.Parse(e.URL)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		pattern := s.signupPattern(strings.ToLower(u.Path))
+		if pattern == "" {
+			continue
+		}
+		domain := catalog.RegistrableDomain(u.Hostname())
+		if v, ok := found[domain]; ok {
+			v.time = min(v.time, e.Timestamp)
+			continue
+		}
+		if s.state.IsSignupReported(stateUser(user), domain) || s.sanctioned.Domain(domain) {
+			continue
+		}
+		var service *catalog.Service
+		if s.catalog != nil {
+			service, _ = s.catalog.Lookup(u.Hostname())
+		}
+		if service != nil && s.sanctioned.Service(service) {
+			continue
+		}
+		found[domain] = &signupVisit{domain: domain, service: service, pattern: pattern, time: e.Timestamp}
+		order = append(order, domain)
+	}
+
+	for _, domain := range order {
+		s.reportSignup(user, b, profile, found[domain])
+	}
+}
+
+// signupPattern returns the first signup_patterns entry matching a path,
+// or "" if none does
+func (s *Scanner) signupPattern(path string) string {
+	for _, re := range s.signups {
+		if re.MatchString(path) {
+			return re.String()
+		}
+	}
+	return ""
+}
+
+// reportSignup logs a sign-up and posts it to the events endpoint, if one
+// is configured
+func (s *Scanner) reportSignup(user platform.User, b browser.Browser, profile browser.Profile, v *signupVisit) {
+	event := dto.SignupEventDTO{
+		ScanID:    s.scanID,
+		Source:    s.cfg.Source,
+		DeviceID:  s.deviceInfo().ID,
+		Browser:   b.Name(),
+		Profile:   profile.Name,
+		Time:      time.Now().UnixMilli(),
+		Kind:      Signup,
+		Priority:  highPriority,
+		Domain:    v.domain,
+		Pattern:   v.pattern,
+		VisitTime: v.time,
+	}
+	if v.service != nil {
+		event.App, event.AppCategory, event.Risk = v.service.Name, v.service.Category, v.service.Risk
+	}
+	s.logger.With("user", user.Username, "browser", b.Name(), "profile", profile.Name, "domain", v.domain,
+		"app", event.App, "priority", highPriority, "signup_event", Signup).
+		Warnf("  %s/%s: likely sign-up at unsanctioned %s", b.Name(), profile.Name, v.domain)
+
+	if s.cfg.EventsURL != "" {
+		event.Principal = dto.NewUserPrincipal(user.Username)
+		event.Principal.Identity = s.identity(user)
+		event.Host = platform.Hostname()
+		if err := s.client.SendSignupEvent(s.cfg.EventsURL, event); err != nil {
+			s.logger.Warnf("failed to send sign-up event: %v", err)
+			return
+		}
+	}
+	s.state.SetSignupReported(stateUser(user), v.domain, v.time)
+}
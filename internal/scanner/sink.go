@@ -0,0 +1,84 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"fmt"
+
+	"hist_scanner/internal/dto"
+	"hist_scanner/internal/sender"
+	"hist_scanner/pkg/sink"
+)
+
+// openSinks opens the sinks the run sends visits to; the http sink uploads
+// with the scanner's client. Dry runs and runs sending only aggregates have
+// none.
+func (s *Scanner) openSinks() error {
+	s.sink = nil
+	if s.dryRun || !s.sendVisits {
+		return nil
+	}
+	specs := s.cfg.Sinks
+	if len(specs) == 0 {
+		specs = []string{sink.HTTP}
+	}
+	var sinks []sink.Sink
+	for _, spec := range specs {
+		name, _, err := sink.Parse(spec)
+		var snk sink.Sink
+		switch {
+		case err != nil:
+		case name == sink.HTTP:
+			snk = sender.NewSink(s.client)
+		default:
+			snk, err = sink.Open(spec)
+		}
+		if err != nil {
+			sink.Multi(sinks...).Close()
+			return fmt.Errorf("failed to open sinks: %w; nothing was sent", err)
+		}
+		sinks = append(sinks, snk)
+	}
+	s.sink = sink.Multi(sinks...)
+	return nil
+}
+
+// flushSinks writes out what the sinks buffered in the run
+func (s *Scanner) flushSinks() error {
+	if s.sink == nil {
+		return nil
+	}
+	if err := s.sink.Flush(s.ctx); err != nil {
+		return fmt.Errorf("failed to flush sinks: %w", err)
+	}
+	return nil
+}
+
+// completeScan posts the completion marker of a run whose chunks the server
+// accepted, so it can tell whether some are missing. A canceled run sends
+// none and stays incomplete to the server.
+func (s *Scanner) completeScan() {
+	if s.dryRun || s.client == nil || s.ctx.Err() != nil || s.client.ChunksSent() == 0 {
+		return
+	}
+	marker := dto.VisitedSitesDTO{
+		Principal: dto.NewIPPrincipal(getLocalIP()),
+		Source:    s.cfg.Source,
+		Device:    s.deviceInfo(),
+	}
+	if err := s.client.CompleteScan(marker); err != nil {
+		s.logger.Warnf("failed to send the scan completion marker: %v", err)
+	}
+}
+
+// closeSinks closes the sinks of the run
+func (s *Scanner) closeSinks() {
+	if s.sink == nil {
+		return
+	}
+	if err := s.sink.Close(); err != nil {
+		s.logger.Warnf("failed to close sinks: %v", err)
+	}
+	s.sink = nil
+}
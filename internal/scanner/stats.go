@@ -0,0 +1,214 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"hist_scanner/internal/browser"
+	"hist_scanner/internal/db"
+	"hist_scanner/internal/dto"
+	"hist_scanner/internal/platform"
+	"hist_scanner/internal/state"
+)
+
+// maxSlowProfiles is the number of slowest profiles kept in run records and
+// status reports
+const maxSlowProfiles = 3
+
+// PhaseTimes is the time spent in each phase of scanning profiles
+type PhaseTimes struct {
+	Open      time.Duration // Opening history databases, including snapshots and temp copies
+	Query     time.Duration // SQLite running history queries
+	Transform time.Duration // Converting rows to entries and batching them (the rest of the profile's time)
+	Compress  time.Duration // Encoding payloads as JSON and compressing them
+	HTTP      time.Duration // Posting payloads, retries and failed requests included
+}
+
+// add adds the times of p
+func (t *PhaseTimes) add(p PhaseTimes) {
+	t.Open += p.Open
+	t.Query += p.Query
+	t.Transform += p.Transform
+	t.Compress += p.Compress
+	t.HTTP += p.HTTP
+}
+
+// String lists the phase times, e.g. "open 1.2s, query 3s, ..."
+func (t PhaseTimes) String() string {
+	return fmt.Sprintf("open %s, query %s, transform %s, compress %s, http %s",
+		roundMS(t.Open), roundMS(t.Query), roundMS(t.Transform), roundMS(t.Compress), roundMS(t.HTTP))
+}
+
+// ProfileStats is the timing and throughput of one scanned profile
+type ProfileStats struct {
+	Username string
+	Browser  string
+	Profile  string
+	Duration time.Duration
+	Entries  int   // Entries sent
+	Bytes    int64 // Bytes sent, after compression
+	Phases   PhaseTimes
+}
+
+// attrs returns the log attributes of the stats
+func (p ProfileStats) attrs() []any {
+	return []any{
+		"duration_ms", p.Duration.Milliseconds(), "sent", p.Entries, "bytes", p.Bytes,
+		"open_ms", p.Phases.Open.Milliseconds(), "query_ms", p.Phases.Query.Milliseconds(),
+		"transform_ms", p.Phases.Transform.Milliseconds(), "compress_ms", p.Phases.Compress.Milliseconds(),
+		"http_ms", p.Phases.HTTP.Milliseconds(),
+	}
+}
+
+// startProfile resets the timing of the profile about to be scanned
+func (s *Scanner) startProfile() {
+	db.TakeTiming()
+	s.profile = ProfileStats{}
+}
+
+// finishProfile returns the stats of the profile scanned since started. The
+// send phases were added by sendEntries; transform is the time left over.
+func (s *Scanner) finishProfile(user platform.User, b browser.Browser, profile browser.Profile, started time.Time, sent int) ProfileStats {
+	st := s.profile
+	st.Username, st.Browser, st.Profile = user.Username, b.Name(), profile.Name
+	st.Duration = time.Since(started)
+	st.Entries = sent
+
+	t := db.TakeTiming()
+	st.Phases.Open, st.Phases.Query = t.Open, t.Query
+	rest := st.Duration - st.Phases.Open - st.Phases.Query - st.Phases.Compress - st.Phases.HTTP
+	st.Phases.Transform = max(rest, 0)
+	return st
+}
+
+// addProfile adds a profile's stats to the run totals
+func (r *ScanResult) addProfile(st ProfileStats) {
+	r.Profiles = append(r.Profiles, st)
+	r.Phases.add(st.Phases)
+	r.BytesSent += st.Bytes
+}
+
+// profileTime returns the total scan time of profiles
+func profileTime(profiles []ProfileStats) time.Duration {
+	var d time.Duration
+	for _, p := range profiles {
+		d += p.Duration
+	}
+	return d
+}
+
+// slowestProfiles returns up to n profiles, slowest first
+func (r *ScanResult) slowestProfiles(n int) []ProfileStats {
+	sorted := slices.Clone(r.Profiles)
+	slices.SortStableFunc(sorted, func(a, b ProfileStats) int {
+		return int(b.Duration - a.Duration)
+	})
+	return sorted[:min(n, len(sorted))]
+}
+
+// logTiming logs the run's phase times, throughput and slowest profile
+func (s *Scanner) logTiming(result *ScanResult) {
+	if len(result.Profiles) == 0 {
+		return
+	}
+	p := result.Phases
+	var throughput []string
+	if d := profileTime(result.Profiles); d > 0 && result.EntriesSent > 0 {
+		throughput = append(throughput, fmt.Sprintf("%.0f entries/s", float64(result.EntriesSent)/d.Seconds()))
+	}
+	if p.HTTP > 0 && result.BytesSent > 0 {
+		throughput = append(throughput, fmt.Sprintf("%.1f KB/s upload", float64(result.BytesSent)/1024/p.HTTP.Seconds()))
+	}
+	msg := fmt.Sprintf("Time: enumeration %s, %s", roundMS(result.Enumeration), p)
+	if len(throughput) > 0 {
+		msg += "; " + strings.Join(throughput, ", ")
+	}
+	s.logger.With("enumeration_ms", result.Enumeration.Milliseconds(), "open_ms", p.Open.Milliseconds(),
+		"query_ms", p.Query.Milliseconds(), "transform_ms", p.Transform.Milliseconds(),
+		"compress_ms", p.Compress.Milliseconds(), "http_ms", p.HTTP.Milliseconds(), "bytes", result.BytesSent).
+		Infof("%s", msg)
+
+	slowest := result.slowestProfiles(1)[0]
+	s.logger.With(append([]any{"user", slowest.Username, "browser", slowest.Browser, "profile", slowest.Profile}, slowest.attrs()...)...).
+		Infof("Slowest profile: %s/%s/%s in %s (%s)", slowest.Username, slowest.Browser, slowest.Profile,
+			roundMS(slowest.Duration), slowest.Phases)
+}
+
+// runTiming converts the run's timing for the run record
+func runTiming(result *ScanResult) *state.RunTiming {
+	if len(result.Profiles) == 0 {
+		return nil
+	}
+	t := &state.RunTiming{
+		EnumerationMS: result.Enumeration.Milliseconds(),
+		Phases:        statePhases(result.Phases),
+		BytesSent:     result.BytesSent,
+	}
+	for _, p := range result.slowestProfiles(maxSlowProfiles) {
+		t.Slowest = append(t.Slowest, state.ProfileTiming{
+			User:       p.Username,
+			Browser:    p.Browser,
+			Profile:    p.Profile,
+			DurationMS: p.Duration.Milliseconds(),
+			Entries:    p.Entries,
+			Phases:     statePhases(p.Phases),
+		})
+	}
+	return t
+}
+
+// statePhases converts phase times to milliseconds
+func statePhases(p PhaseTimes) state.PhaseMS {
+	return state.PhaseMS{
+		OpenMS:      p.Open.Milliseconds(),
+		QueryMS:     p.Query.Milliseconds(),
+		TransformMS: p.Transform.Milliseconds(),
+		CompressMS:  p.Compress.Milliseconds(),
+		HTTPMS:      p.HTTP.Milliseconds(),
+	}
+}
+
+// reportTiming converts the run's timing for the run report
+func reportTiming(result *ScanResult) *dto.TimingDTO {
+	rt := runTiming(result)
+	if rt == nil {
+		return nil
+	}
+	t := &dto.TimingDTO{
+		EnumerationMS: rt.EnumerationMS,
+		Phases:        dtoPhases(rt.Phases),
+		BytesSent:     rt.BytesSent,
+	}
+	for _, p := range rt.Slowest {
+		t.Slowest = append(t.Slowest, dto.ProfileTimingDTO{
+			User:       p.User,
+			Browser:    p.Browser,
+			Profile:    p.Profile,
+			DurationMS: p.DurationMS,
+			Entries:    p.Entries,
+			Phases:     dtoPhases(p.Phases),
+		})
+	}
+	return t
+}
+
+// dtoPhases converts phase times for the run report
+func dtoPhases(p state.PhaseMS) dto.PhasesDTO {
+	return dto.PhasesDTO{
+		OpenMS:      p.OpenMS,
+		QueryMS:     p.QueryMS,
+		TransformMS: p.TransformMS,
+		CompressMS:  p.CompressMS,
+		HTTPMS:      p.HTTPMS,
+	}
+}
+
+// roundMS rounds a duration to milliseconds for display
+func roundMS(d time.Duration) time.Duration {
+	return d.Round(time.Millisecond)
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package scanner
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"hist_scanner/internal/browser"
+	"hist_scanner/internal/dto"
+	"hist_scanner/internal/platform"
+	"hist_scanner/internal/watchlist"
+)
+
+// WatchlistAlert is the kind of events alerting on visits to a watchlisted
+// domain
+const WatchlistAlert = "watchlist"
+
+// loadWatchlist prepares watchlist alerts: watchlist merged with the entries
+// of watchlist_url. While that URL is unreachable its last download is used.
+func (s *Scanner) loadWatchlist() {
+	s.watchlist, s.alerted = nil, make(map[string]bool)
+	if s.dryRun {
+		return
+	}
+	entries := slices.Clone(s.cfg.Watchlist)
+	if s.cfg.WatchlistURL != "" {
+		remote, err := s.fetchWatchlist()
+		if err != nil {
+			s.logger.Warnf("failed to download the watchlist, alerting on configured entries only: %v", err)
+		}
+		entries = append(entries, remote...)
+	}
+	if len(entries) == 0 {
+		return
+	}
+	// Validate has checked the configured entries and fetchWatchlist the downloaded ones
+	l, err := watchlist.Parse(entries)
+	if err != nil {
+		s.logger.Warnf("watchlist: %v", err)
+		return
+	}
+	s.logger.Debugf("Watchlist: %d entries", l.Len())
+	s.watchlist = l
+}
+
+// fetchWatchlist downloads the entries of watchlist_url and caches them next
+// to the state file, falling back to the cached copy if the download fails
+func (s *Scanner) fetchWatchlist() ([]string, error) {
+	cached := filepath.Join(s.state.StateDir(), "lists", "watchlist.json")
+	entries, err := s.client.FetchWatchlist(s.cfg.WatchlistURL)
+	if err == nil {
+		_, err = watchlist.Parse(entries)
+	}
+	if err == nil {
+		data, _ := json.Marshal(entries)
+		if mkErr := os.MkdirAll(filepath.Dir(cached), 0700); mkErr != nil {
+			s.logger.Warnf("failed to cache the watchlist: %v", mkErr)
+		} else if writeErr := os.WriteFile(cached, data, 0600); writeErr != nil {
+			s.logger.Warnf("failed to cache the watchlist: %v", writeErr)
+		}
+		return entries, nil
+	}
+
+	info, statErr := os.Stat(cached)
+	data, readErr := os.ReadFile(cached)
+	if statErr != nil || readErr != nil || json.Unmarshal(data, &entries) != nil {
+		return nil, err
+	}
+	if _, parseErr := watchlist.Parse(entries); parseErr != nil {
+		return nil, err
+	}
+	s.logger.Warnf("using the watchlist downloaded on %s: %v", info.ModTime().Format(time.DateOnly), err)
+	return entries, nil
+}
+
+// watchlistHit is the visits of a batch to one watchlisted host
+type watchlistHit struct {
+	host, match string
+	visits      int
+	first, last int64
+}
+
+// checkWatchlist alerts on the visits of a batch to watchlisted hosts, once
+// per user and host in a run
+func (s *Scanner) checkWatchlist(user platform.User, b browser.Browser, profile browser.Profile, entries []dto.VisitedSite) {
+	if s.watchlist == nil || s.dryRun {
+		return
+	}
+
+	found := make(map[string]*watchlistHit)
+	var order []string
+	for _, e := range entries {
+		match, ok := s.watchlist.Match(e.URL)
+		if !ok {
+			continue
+		}
+		u, _ := url.Parse(e.URL)
+		host := strings.ToLower(u.Hostname())
+		if h, ok := found[host]; ok {
+			h.visits++
+			h.first, h.last = min(h.first, e.Timestamp), max(h.last, e.Timestamp)
+			continue
+		}
+		if s.alerted[stateUser(user)+"/"+host] {
+			continue
+		}
+		found[host] = &watchlistHit{host: host, match: match, visits: 1, first: e.Timestamp, last: e.Timestamp}
+		order = append(order, host)
+	}
+
+	for _, host := range order {
+		s.alertWatchlist(user, b, profile, found[host])
+		s.alerted[stateUser(user)+"/"+host] = true
+	}
+}
+
+// alertWatchlist logs a watchlist alert and posts it to alert_url, or
+// events_url if there is none
+func (s *Scanner) alertWatchlist(user platform.User, b browser.Browser, profile browser.Profile, h *watchlistHit) {
+	s.logger.With("user", user.Username, "browser", b.Name(), "profile", profile.Name, "domain", h.host,
+		"match", h.match, "visits", h.visits, "priority", highPriority, "watchlist_event", WatchlistAlert).
+		Warnf("  %s/%s: %d visits to watchlisted %s (%s)", b.Name(), profile.Name, h.visits, h.host, h.match)
+
+	alertURL := s.cfg.AlertURL
+	if alertURL == "" {
+		alertURL = s.cfg.EventsURL
+	}
+	if alertURL == "" {
+		return
+	}
+	hostname := platform.Hostname()
+	event := dto.WatchlistAlertDTO{
+		ScanID:     s.scanID,
+		Source:     s.cfg.Source,
+		Host:       hostname,
+		DeviceID:   s.deviceInfo().ID,
+		Principal:  s.principal(user),
+		Browser:    b.Name(),
+		Profile:    profile.Name,
+		Time:       time.Now().UnixMilli(),
+		Kind:       WatchlistAlert,
+		Priority:   highPriority,
+		Domain:     h.host,
+		Match:      h.match,
+		Visits:     h.visits,
+		FirstVisit: h.first,
+		LastVisit:  h.last,
+	}
+	if err := s.client.SendWatchlistAlert(alertURL, event); err != nil {
+		s.logger.Warnf("failed to send watchlist alert: %v", err)
+	}
+}
@@ -0,0 +1,325 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package seal encrypts files holding browsing data at rest with AES-GCM
+// under a device key. Files are sealed in chunks, so large exports are
+// streamed; a truncated, reordered or modified file fails to open.
+package seal
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"hist_scanner/internal/platform"
+)
+
+// magic starts sealed files so they can be told apart from plain ones
+var magic = []byte("HSSEAL1")
+
+const (
+	saltSize  = 16
+	chunkSize = 64 << 10
+	fileInfo  = "hist_scanner sealed file"
+)
+
+// KeyFileName is the master key file next to the state file
+const KeyFileName = "seal.key"
+
+// Key derives a 256-bit key for a purpose from secret. If secret is empty,
+// it is derived from the random master key in keyFile, which is generated on
+// first use and readable by its owner only (protected with DPAPI for the
+// scanner's account on Windows).
+func Key(secret, purpose, keyFile string) ([]byte, error) {
+	if secret != "" {
+		return hkdf.Key(sha256.New, []byte(secret), nil, purpose, 32)
+	}
+	master, err := loadOrCreateMaster(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return hkdf.Key(sha256.New, master, nil, purpose, 32)
+}
+
+// LegacyKey derives the key older versions used without a secret from the
+// machine id, which any local user can read. It only opens data sealed by
+// them.
+func LegacyKey(purpose string) ([]byte, error) {
+	id, err := platform.MachineID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive machine key: %w", err)
+	}
+	return hkdf.Key(sha256.New, []byte(id), nil, purpose, 32)
+}
+
+// loadOrCreateMaster reads the master key, generating and saving a new one
+// if there is none
+func loadOrCreateMaster(path string) ([]byte, error) {
+	master, err := loadMaster(path)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return master, err
+	}
+
+	master = make([]byte, 32)
+	if _, err := rand.Read(master); err != nil {
+		return nil, fmt.Errorf("failed to generate seal key: %w", err)
+	}
+	data, err := platform.ProtectData(master)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create key directory: %w", err)
+	}
+	// O_EXCL: a concurrent run that created the key first wins
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, os.ErrExist) {
+		return loadMaster(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save seal key: %w", err)
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to save seal key: %w", err)
+	}
+	return master, nil
+}
+
+// loadMaster reads the master key, refusing a file other users can read
+func loadMaster(path string) ([]byte, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seal key: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("seal key %s is not a regular file", path)
+	}
+	if platform.CurrentOS() != platform.Windows && info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("seal key %s is accessible by other users (mode %v)", path, info.Mode().Perm())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seal key: %w", err)
+	}
+	master, err := platform.UnprotectData(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(master) != 32 {
+		return nil, fmt.Errorf("seal key %s is damaged", path)
+	}
+	return master, nil
+}
+
+// IsSealed reports whether data starts a sealed file
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// fileAEAD returns the AEAD of one file; each file has its own key derived
+// with a random salt, so chunk counters can serve as nonces
+func fileAEAD(key, salt []byte) (cipher.AEAD, error) {
+	fileKey, err := hkdf.Key(sha256.New, key, salt, fileInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(fileKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid seal key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// nonce returns the nonce of chunk n; the last chunk is flagged so a file
+// cut at a chunk boundary is detected
+func nonce(n uint64, last bool) []byte {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint64(b, n)
+	if last {
+		b[11] = 1
+	}
+	return b
+}
+
+// Writer seals data written to it
+type Writer struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header []byte
+	buf    []byte
+	n      uint64
+	err    error
+}
+
+// NewWriter returns a writer sealing to w. Close must be called to write
+// the last chunk; it does not close w.
+func NewWriter(w io.Writer, key []byte) (*Writer, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	aead, err := fileAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	header := append(append([]byte{}, magic...), salt...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, aead: aead, header: header, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+// Write buffers p, sealing each full chunk
+func (s *Writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 && s.err == nil {
+		n := min(len(p), chunkSize-len(s.buf))
+		s.buf = append(s.buf, p[:n]...)
+		p, written = p[n:], written+n
+		if len(s.buf) == chunkSize {
+			s.err = s.flush(false)
+		}
+	}
+	return written, s.err
+}
+
+// Close seals the last chunk
+func (s *Writer) Close() error {
+	if s.err == nil {
+		s.err = s.flush(true)
+		if s.err == nil {
+			s.err = errors.New("seal: writer is closed")
+			return nil
+		}
+	}
+	return s.err
+}
+
+// flush writes the buffered data as one sealed chunk
+func (s *Writer) flush(last bool) error {
+	sealed := s.aead.Seal(nil, nonce(s.n, last), s.buf, s.header)
+	s.n++
+	s.buf = s.buf[:0]
+
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(sealed)))
+	if _, err := s.w.Write(size[:]); err != nil {
+		return err
+	}
+	_, err := s.w.Write(sealed)
+	return err
+}
+
+// reader opens a sealed file chunk by chunk
+type reader struct {
+	r      *bufio.Reader
+	aead   cipher.AEAD
+	header []byte
+	plain  []byte
+	n      uint64
+	done   bool
+}
+
+// NewReader returns a reader of the plain data of a sealed file. Of several
+// keys, the first that opens the first chunk is used.
+func NewReader(r io.Reader, keys ...[]byte) (io.Reader, error) {
+	header := make([]byte, len(magic)+saltSize)
+	if _, err := io.ReadFull(r, header); err != nil || !IsSealed(header) {
+		return nil, fmt.Errorf("not a sealed file")
+	}
+	br := bufio.NewReaderSize(r, 4+chunkSize+64)
+	for i, key := range keys {
+		aead, err := fileAEAD(key, header[len(magic):])
+		if err != nil {
+			return nil, err
+		}
+		if i == len(keys)-1 || opensFirstChunk(br, aead, header) {
+			return &reader{r: br, aead: aead, header: header}, nil
+		}
+	}
+	return nil, fmt.Errorf("no key to open the sealed file")
+}
+
+// opensFirstChunk reports whether aead opens the next chunk of r, without
+// consuming it
+func opensFirstChunk(r *bufio.Reader, aead cipher.AEAD, header []byte) bool {
+	size, err := r.Peek(4)
+	if err != nil {
+		return false
+	}
+	n := int(binary.BigEndian.Uint32(size))
+	if n > chunkSize+aead.Overhead() {
+		return false
+	}
+	chunk, err := r.Peek(4 + n)
+	if err != nil {
+		return false
+	}
+	for _, last := range []bool{false, true} {
+		if _, err := aead.Open(nil, nonce(0, last), chunk[4:], header); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Read returns the plain data of the next chunks
+func (s *reader) Read(p []byte) (int, error) {
+	for len(s.plain) == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+		if err := s.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, s.plain)
+	s.plain = s.plain[n:]
+	return n, nil
+}
+
+// next opens the next chunk
+func (s *reader) next() error {
+	var size [4]byte
+	if _, err := io.ReadFull(s.r, size[:]); err != nil {
+		return fmt.Errorf("sealed file is truncated")
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > chunkSize+uint32(s.aead.Overhead()) {
+		return fmt.Errorf("sealed file is damaged")
+	}
+	sealed := make([]byte, n)
+	if _, err := io.ReadFull(s.r, sealed); err != nil {
+		return fmt.Errorf("sealed file is truncated")
+	}
+
+	// Only the last chunk opens with the last-chunk nonce
+	plain, err := s.aead.Open(nil, nonce(s.n, false), sealed, s.header)
+	if err != nil {
+		if plain, err = s.aead.Open(nil, nonce(s.n, true), sealed, s.header); err != nil {
+			return fmt.Errorf("failed to decrypt (wrong key or damaged file)")
+		}
+		s.done = true
+		if _, err := s.r.Peek(1); err != io.EOF {
+			return fmt.Errorf("sealed file has data after its last chunk")
+		}
+	}
+	s.n++
+	s.plain = plain
+	return nil
+}
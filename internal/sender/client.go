@@ -6,38 +6,209 @@ package sender
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"html"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
 
+	"hist_scanner/internal/audit"
+	"hist_scanner/internal/buildinfo"
+	"hist_scanner/internal/devicekey"
 	"hist_scanner/internal/dto"
+	"hist_scanner/internal/msgpack"
 )
 
 // Client handles HTTP communication with the server
 type Client struct {
-	serverURL    string
-	apiKey       string
-	httpClient   *http.Client
-	maxChunkSize int  // Max compressed chunk size in bytes
-	compress     bool // Whether to use gzip compression
+	serverURL     string
+	apiKey        string
+	authHeader    string            // Header carrying the API key (SetAuth)
+	authScheme    string            // Written before the API key, "" for none
+	headers       map[string]string // Static headers of every request (SetHeaders)
+	httpClient    *http.Client
+	maxChunkSize  int  // Max compressed chunk size in bytes
+	compress      bool // Whether to use gzip compression
+	audit         *audit.Log
+	sign          func(body []byte) string // Signs request bodies (SetSigner)
+	ctx           context.Context          // Cancels requests (WithContext)
+	scan          *scanSequence            // Numbers the chunks of a scan (WithScan)
+	schemaV1      *atomic.Bool             // The server accepts only version 1 visits payloads
+	msgpack       bool                     // Encode visits as MessagePack (SetEncoding)
+	jsonOnly      *atomic.Bool             // The server rejected MessagePack
+	resumableSize int                      // Smallest body uploaded resumably, 0 for none (SetResumable)
+	wholeOnly     *atomic.Bool             // The server has no resumable uploads
+	slots         chan struct{}            // Holds a token per chunk in flight (SetConcurrency)
+	buffered      *atomic.Bool             // The server requires Content-Length (411)
+	clock         *clockSkew               // Skew of the server's clock, sampled from every response
+	healthURL     string                   // Endpoint TestConnection checks, "" for the upload endpoint (SetHealthURL)
 }
 
+// scanSequence numbers the chunks of a scan and counts the ones the server
+// accepted. It is shared by the copies of a client, so chunks sent through
+// any of them are numbered in one sequence. A chunk that is not accepted
+// gives its number back to the next chunk started.
+type scanSequence struct {
+	mu   sync.Mutex
+	id   string
+	next int   // Numbers up to next were handed out
+	free []int // Numbers given back below next, lowest first
+	sent int
+}
+
+// take hands out the lowest number given back, reporting it as a hole
+// below later numbers, or else the number after the last handed out
+func (s *scanSequence) take() (n int, hole bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.free) > 0 {
+		n, s.free = s.free[0], s.free[1:]
+		return n, true
+	}
+	s.next++
+	return s.next, false
+}
+
+// giveBack returns the number of a chunk the server did not accept. Numbers
+// at the top of the sequence are handed out again as new ones.
+func (s *scanSequence) giveBack(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, _ := slices.BinarySearch(s.free, n)
+	s.free = slices.Insert(s.free, i, n)
+	for len(s.free) > 0 && s.free[len(s.free)-1] == s.next {
+		s.free = s.free[:len(s.free)-1]
+		s.next--
+	}
+}
+
+// accept counts a chunk the server accepted
+func (s *scanSequence) accept() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent++
+}
+
+// Headers repeating the scan metadata of a chunk, for servers that route or
+// count requests before reading their body
+const (
+	ScanIDHeader       = "X-Scan-Id"
+	SequenceHeader     = "X-Scan-Sequence"
+	ScanCompleteHeader = "X-Scan-Complete"
+	ChunkIndexHeader   = "X-Chunk-Index"
+	ChunkCountHeader   = "X-Chunk-Count"
+)
+
 // NewClient creates a new HTTP client for sending history data
 // maxChunkSizeKB is the maximum compressed chunk size in kilobytes
 func NewClient(serverURL, apiKey string, timeout time.Duration, maxChunkSizeKB int, compress bool) *Client {
+	clock := new(clockSkew)
+	httpClient := buildinfo.HTTPClient(timeout)
+	httpClient.Transport = clockTransport{httpClient.Transport, clock}
 	return &Client{
-		serverURL: normalizeServerURL(serverURL),
-		apiKey:    apiKey,
-		httpClient: &http.Client{
-			Timeout: timeout,
-		},
+		serverURL:    normalizeServerURL(serverURL),
+		apiKey:       apiKey,
+		authHeader:   "Authorization",
+		authScheme:   "ProxyToken",
+		httpClient:   httpClient,
 		maxChunkSize: maxChunkSizeKB * 1024, // Convert to bytes
 		compress:     compress,
+		ctx:          context.Background(),
+		schemaV1:     new(atomic.Bool),
+		jsonOnly:     new(atomic.Bool),
+		wholeOnly:    new(atomic.Bool),
+		slots:        make(chan struct{}, 1),
+		buffered:     new(atomic.Bool),
+		clock:        clock,
+	}
+}
+
+// WithContext returns a copy of the client whose requests are canceled
+// with ctx
+func (c *Client) WithContext(ctx context.Context) *Client {
+	cc := *c
+	cc.ctx = ctx
+	return &cc
+}
+
+// WithScan returns a copy of the client that tags the chunks it sends with
+// a scan id and numbers them, starting a new sequence
+func (c *Client) WithScan(scanID string) *Client {
+	cc := *c
+	cc.scan = &scanSequence{id: scanID}
+	return &cc
+}
+
+// ChunksSent returns the number of chunks of the scan the server accepted
+func (c *Client) ChunksSent() int {
+	if c.scan == nil {
+		return 0
+	}
+	c.scan.mu.Lock()
+	defer c.scan.mu.Unlock()
+	return c.scan.sent
+}
+
+// SetAuth sends the API key as "<header>: <scheme> <key>", or as the bare
+// key if scheme is ""
+func (c *Client) SetAuth(header, scheme string) {
+	c.authHeader, c.authScheme = header, scheme
+}
+
+// SetHeaders adds static headers to every request carrying the API key
+func (c *Client) SetHeaders(headers map[string]string) {
+	c.headers = headers
+}
+
+// authorize adds the static headers and the API key to a request
+func (c *Client) authorize(req *http.Request) {
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+	if c.authScheme == "" {
+		req.Header.Set(c.authHeader, c.apiKey)
+	} else {
+		req.Header.Set(c.authHeader, c.authScheme+" "+c.apiKey)
 	}
 }
 
+// Payload encodings of visit uploads
+const (
+	EncodingJSON    = "json"
+	EncodingMsgpack = "msgpack"
+)
+
+// SetEncoding selects how visit uploads are encoded: EncodingJSON or
+// EncodingMsgpack. A server that rejects MessagePack with 415 gets JSON.
+func (c *Client) SetEncoding(encoding string) {
+	c.msgpack = encoding == EncodingMsgpack
+}
+
+// SetAuditLog records every chunk sent to the server in an audit log
+func (c *Client) SetAuditLog(log *audit.Log) {
+	c.audit = log
+}
+
+// SetSigner signs every request body with sign, sending the signature in
+// devicekey.SignatureHeader
+func (c *Client) SetSigner(sign func(body []byte) string) {
+	c.sign = sign
+}
+
 // SendResult contains the result of a send operation
 type SendResult struct {
 	TotalSent     int   // Total entries successfully sent
@@ -46,10 +217,22 @@ type SendResult struct {
 	FailedCount   int   // Number of entries that failed to send
 	BytesSent     int64 // Total bytes sent (compressed if enabled)
 	BytesOriginal int64 // Total bytes before compression
+	MaxRowID      int64 // Highest browser row id among successfully sent entries
+	AuditError    error // First failure writing the audit log (the data was still sent)
+	SchemaV1      error // Why the server rejected version 2 payloads, if chunks were sent as version 1 from then on
+	JSONFallback  bool  // The server rejected MessagePack; chunks were sent as JSON from then on
+	WholeFallback bool  // The server has no resumable uploads; chunks were posted whole
+	Resumed       int   // Uploads interrupted and resumed, counted once per interruption
+
+	EncodeTime time.Duration // JSON encoding and compression of all chunks
+	HTTPTime   time.Duration // HTTP requests of all chunks, failed ones included
 }
 
-// Send sends visited sites to the server, chunking by compressed size
-// Returns the maximum timestamp of successfully sent entries (for state update)
+// Send sends visited sites to the server, chunking by compressed size, with
+// up to the client's concurrency of chunks in flight (SetConcurrency). No
+// chunk is started once one failed. Returns the maximum timestamp of the
+// chunks accepted in order, before the first that was not (for state
+// update): a chunk accepted after a failed one is sent again with it.
 func (c *Client) Send(payload dto.VisitedSitesDTO) (*SendResult, int64, error) {
 	result := &SendResult{}
 
@@ -57,30 +240,88 @@ func (c *Client) Send(payload dto.VisitedSitesDTO) (*SendResult, int64, error) {
 		return result, 0, nil
 	}
 
-	var maxTimestamp int64
-
 	// Build chunks based on compressed size
 	chunks := c.buildChunks(payload)
+	accepted := make([]bool, len(chunks))
 
-	for _, chunk := range chunks {
-		bytesSent, bytesOriginal, err := c.sendChunk(chunk)
-		if err != nil {
-			result.LastError = err
-			result.FailedCount += len(chunk.VisitedSites)
-			// Continue trying other chunks
-			continue
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		failed bool
+	)
+	started := time.Now()
+	for i := range chunks {
+		chunks[i].ChunkIndex, chunks[i].ChunkCount = i, len(chunks)
+		if !c.acquireSlot() {
+			mu.Lock()
+			result.LastError = c.ctx.Err()
+			mu.Unlock()
+			break
 		}
+		mu.Lock()
+		stop := failed
+		mu.Unlock()
+		if stop {
+			c.releaseSlot()
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer c.releaseSlot()
+			chunk := chunks[i]
+			sent, err := c.sendSequenced(chunk)
+			auditErr := c.auditChunk(chunk, sent.bytesSent, sent.status, err)
+
+			mu.Lock()
+			defer mu.Unlock()
+			result.EncodeTime += sent.encode
+			result.HTTPTime += sent.http
+			if auditErr != nil && result.AuditError == nil {
+				result.AuditError = auditErr
+			}
+			if sent.schemaV1 != nil {
+				result.SchemaV1 = sent.schemaV1
+			}
+			result.JSONFallback = result.JSONFallback || sent.jsonFallback
+			result.WholeFallback = result.WholeFallback || sent.wholeFallback
+			result.Resumed += sent.resumed
+			if err != nil {
+				result.LastError = err
+				failed = true
+				return
+			}
+			accepted[i] = true
+			result.TotalSent += len(chunk.VisitedSites)
+			result.ChunksSent++
+			result.BytesSent += sent.bytesSent
+			result.BytesOriginal += sent.bytesOriginal
+		}()
+	}
+	wg.Wait()
+	result.FailedCount = len(payload.VisitedSites) - result.TotalSent
 
-		result.TotalSent += len(chunk.VisitedSites)
-		result.ChunksSent++
-		result.BytesSent += bytesSent
-		result.BytesOriginal += bytesOriginal
+	// Chunks in flight together overlap, so their times are scaled to the
+	// time the whole send took
+	if elapsed, total := time.Since(started), result.EncodeTime+result.HTTPTime; total > elapsed {
+		result.EncodeTime = time.Duration(float64(result.EncodeTime) * float64(elapsed) / float64(total))
+		result.HTTPTime = elapsed - result.EncodeTime
+	}
 
-		// Track max timestamp from successful sends
+	// Track max timestamp of the chunks accepted in order
+	var maxTimestamp int64
+	for i, chunk := range chunks {
+		if !accepted[i] {
+			break
+		}
 		for _, site := range chunk.VisitedSites {
 			if site.Timestamp > maxTimestamp {
 				maxTimestamp = site.Timestamp
 			}
+			if site.RowID > result.MaxRowID {
+				result.MaxRowID = site.RowID
+			}
 		}
 	}
 
@@ -91,6 +332,112 @@ func (c *Client) Send(payload dto.VisitedSitesDTO) (*SendResult, int64, error) {
 	return result, maxTimestamp, nil
 }
 
+// SetConcurrency sets how many chunks are uploaded at once, at least 1. The
+// limit is shared by the copies of the client, so it holds for the
+// destination across every Send.
+func (c *Client) SetConcurrency(n int) {
+	c.slots = make(chan struct{}, max(n, 1))
+}
+
+// acquireSlot waits until fewer chunks than the concurrency are in flight,
+// or returns false once the client's context is done
+func (c *Client) acquireSlot() bool {
+	if c.ctx.Err() != nil {
+		return false
+	}
+	select {
+	case c.slots <- struct{}{}:
+		return true
+	case <-c.ctx.Done():
+		return false
+	}
+}
+
+// releaseSlot ends an upload started with acquireSlot
+func (c *Client) releaseSlot() {
+	<-c.slots
+}
+
+// auditChunk records a chunk send attempt in the audit log, if one is set
+func (c *Client) auditChunk(chunk dto.VisitedSitesDTO, bytesSent int64, status int, sendErr error) error {
+	if c.audit == nil {
+		return nil
+	}
+	r := audit.Record{
+		Time:      time.Now().UTC(),
+		Principal: chunk.Principal.Name,
+		Entries:   len(chunk.VisitedSites),
+		URLDigest: audit.URLDigest(chunk.VisitedSites),
+		Bytes:     bytesSent,
+		Status:    status,
+	}
+	if sendErr != nil {
+		r.Error = sendErr.Error()
+	}
+	return c.audit.Append(r)
+}
+
+// sendSequenced sends a chunk with the next number of the scan, if the
+// client has one. The number is used up only if the server accepts the chunk.
+func (c *Client) sendSequenced(chunk dto.VisitedSitesDTO) (chunkResult, error) {
+	if c.scan == nil {
+		return c.sendChunk(chunk)
+	}
+	n, _ := c.scan.take()
+	chunk.ScanID, chunk.Sequence = c.scan.id, n
+	r, err := c.sendChunk(chunk)
+	if err != nil {
+		c.scan.giveBack(n)
+	} else {
+		c.scan.accept()
+	}
+	return r, err
+}
+
+// CompleteScan posts marker as the completion marker of the scan, without
+// visits and numbered after the last chunk the server accepted. Numbers that
+// failed chunks gave back below accepted ones (concurrent uploads) are first
+// filled with requests without visits, so that the server has received
+// every number below the marker's.
+func (c *Client) CompleteScan(marker dto.VisitedSitesDTO) error {
+	if c.scan == nil {
+		return fmt.Errorf("no scan to complete")
+	}
+	marker.ScanID, marker.VisitedSites = c.scan.id, []dto.VisitedSite{}
+	marker.ChunkIndex, marker.ChunkCount = 0, 0
+	for {
+		n, hole := c.scan.take()
+		chunk := marker
+		chunk.Sequence, chunk.Complete = n, !hole
+		if _, err := c.sendEncoded(chunk); err != nil {
+			c.scan.giveBack(n)
+			return err
+		}
+		if !hole {
+			return nil
+		}
+	}
+}
+
+// chunkHeaders returns the scan metadata headers of a chunk
+func chunkHeaders(chunk dto.VisitedSitesDTO) map[string]string {
+	headers := make(map[string]string)
+	if chunk.ScanID != "" {
+		headers[ScanIDHeader] = chunk.ScanID
+	}
+	if chunk.Sequence > 0 {
+		headers[SequenceHeader] = strconv.Itoa(chunk.Sequence)
+	}
+	if chunk.Complete {
+		headers[ScanCompleteHeader] = "true"
+	}
+	if chunk.ChunkCount > 0 {
+		headers[ChunkIndexHeader] = strconv.Itoa(chunk.ChunkIndex)
+		headers[ChunkCountHeader] = strconv.Itoa(chunk.ChunkCount)
+	}
+	return headers
+}
+
 // buildChunks splits the payload into chunks based on compressed size
 func (c *Client) buildChunks(payload dto.VisitedSitesDTO) []dto.VisitedSitesDTO {
 	var chunks []dto.VisitedSitesDTO
@@ -112,9 +459,19 @@ func (c *Client) buildChunks(payload dto.VisitedSitesDTO) []dto.VisitedSitesDTO
 		if len(currentSites) > 0 && estimatedCompressedSize+entrySize > c.maxChunkSize {
 			// Save current chunk
 			chunks = append(chunks, dto.VisitedSitesDTO{
-				Principal:    payload.Principal,
-				Source:       payload.Source,
-				VisitedSites: currentSites,
+				Principal:     payload.Principal,
+				Source:        payload.Source,
+				VisitedSites:  currentSites,
+				Device:        payload.Device,
+				Corrupt:       payload.Corrupt,
+				Import:        payload.Import,
+				Proxy:         payload.Proxy,
+				Image:         payload.Image,
+				DNS:           payload.DNS,
+				SchemaVersion: payload.SchemaVersion,
+				Browser:       payload.Browser,
+				ScanID:        payload.ScanID,
+				Clock:         payload.Clock,
 			})
 			currentSites = nil
 			currentSize = 0
@@ -127,117 +484,648 @@ func (c *Client) buildChunks(payload dto.VisitedSitesDTO) []dto.VisitedSitesDTO
 	// Don't forget the last chunk
 	if len(currentSites) > 0 {
 		chunks = append(chunks, dto.VisitedSitesDTO{
-			Principal:    payload.Principal,
-			Source:       payload.Source,
-			VisitedSites: currentSites,
+			Principal:     payload.Principal,
+			Source:        payload.Source,
+			VisitedSites:  currentSites,
+			Device:        payload.Device,
+			Corrupt:       payload.Corrupt,
+			Import:        payload.Import,
+			Proxy:         payload.Proxy,
+			Image:         payload.Image,
+			DNS:           payload.DNS,
+			SchemaVersion: payload.SchemaVersion,
+			Browser:       payload.Browser,
+			ScanID:        payload.ScanID,
+			Clock:         payload.Clock,
 		})
 	}
 
 	return chunks
 }
 
-// sendChunk sends a single chunk to the server
-// Returns (bytesSent, bytesOriginal, error)
-func (c *Client) sendChunk(payload dto.VisitedSitesDTO) (int64, int64, error) {
-	data, err := json.Marshal(payload)
+// chunkResult describes one chunk send attempt
+type chunkResult struct {
+	bytesSent     int64         // Bytes sent, after compression
+	bytesOriginal int64         // JSON bytes before compression
+	status        int           // HTTP status, 0 without a response
+	encode        time.Duration // JSON encoding and compression
+	http          time.Duration // HTTP requests, including a retry without compression
+	schemaV1      error         // Why the server rejected version 2, if the chunk was sent as version 1 instead
+	jsonFallback  bool          // Sent as JSON after the server rejected MessagePack
+	wholeFallback bool          // Posted whole after the server refused a resumable upload
+	resumed       int           // Interruptions of resumable uploads that were resumed
+}
+
+// sendChunk sends a single chunk to the server. If the server rejects a
+// version 2 payload as malformed (400, 422), the chunk is sent again as
+// version 1; once that is accepted, every later chunk is sent as version 1.
+func (c *Client) sendChunk(payload dto.VisitedSitesDTO) (chunkResult, error) {
+	if payload.SchemaVersion < 2 {
+		return c.sendEncoded(payload)
+	}
+	if c.schemaV1.Load() {
+		return c.sendEncoded(schemaV1(payload))
+	}
+	r, rejected := c.sendEncoded(payload)
+	if !isSchemaRejected(rejected) {
+		return r, rejected
+	}
+	retry, err := c.sendEncoded(schemaV1(payload))
+	retry.encode += r.encode
+	retry.http += r.http
+	if err == nil && !c.schemaV1.Swap(true) {
+		retry.schemaV1 = rejected
+	}
+	return retry, err
+}
+
+// schemaV1 returns a payload as version 1, without the browser
+func schemaV1(payload dto.VisitedSitesDTO) dto.VisitedSitesDTO {
+	payload.SchemaVersion, payload.Browser = 0, nil
+	return payload
+}
+
+// isSchemaRejected reports whether the server refused a payload as malformed
+func isSchemaRejected(err error) bool {
+	if httpErr, ok := err.(*httpError); ok {
+		return httpErr.statusCode == http.StatusBadRequest || httpErr.statusCode == http.StatusUnprocessableEntity
+	}
+	return false
+}
+
+// sendEncoded sends a chunk in the client's encoding. If the server rejects
+// MessagePack (415 Unsupported Media Type), the chunk is sent again as JSON,
+// and so is every later chunk of the client.
+func (c *Client) sendEncoded(payload dto.VisitedSitesDTO) (chunkResult, error) {
+	if !c.msgpack || c.jsonOnly.Load() {
+		return c.sendAs(payload, false)
+	}
+	r, err := c.sendAs(payload, true)
+	if !isUnsupportedMediaType(err) {
+		return r, err
+	}
+	retry, err := c.sendAs(payload, false)
+	retry.encode += r.encode
+	retry.http += r.http
+	if err == nil {
+		retry.jsonFallback = !c.jsonOnly.Swap(true)
+	}
+	return retry, err
+}
+
+// sendAs encodes a chunk as MessagePack or JSON and sends it, compressed if
+// enabled
+func (c *Client) sendAs(payload dto.VisitedSitesDTO, asMsgpack bool) (chunkResult, error) {
+	var r chunkResult
+	marshal, contentType := json.Marshal, "application/json"
+	if asMsgpack {
+		marshal, contentType = msgpack.Marshal, msgpack.MediaType
+	}
+	headers := chunkHeaders(payload)
+	compress := c.compress
+	if compress && c.streams() {
+		err := c.postStreamed(&r, payload, asMsgpack, contentType, headers)
+		switch {
+		case isLengthRequired(err):
+			c.buffered.Store(true)
+		case isUnsupportedMediaType(err):
+			compress = false
+		default:
+			return r, err
+		}
+	}
+
+	started := time.Now()
+	data, err := marshal(payload)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to marshal payload: %w", err)
+		return r, fmt.Errorf("failed to marshal payload: %w", err)
 	}
+	r.bytesOriginal = int64(len(data))
 
-	bytesOriginal := int64(len(data))
+	if !compress {
+		r.encode += time.Since(started)
+		return r, c.send(&r, data, contentType, "", headers)
+	}
 
-	if c.compress {
-		// Try with gzip first
-		bytesSent, err := c.sendWithGzip(data)
+	compressed, err := gzipData(data)
+	r.encode += time.Since(started)
+	if err != nil {
+		return r, err
+	}
+
+	// Try with gzip first; if the server rejects it (415 Unsupported Media
+	// Type), retry without compression
+	err = c.send(&r, compressed, contentType, "gzip", headers)
+	if isUnsupportedMediaType(err) {
+		err = c.send(&r, data, contentType, "", headers)
+	}
+	return r, err
+}
+
+// streams reports whether compressed chunks are streamed. A signature or a
+// resumable upload needs the whole compressed body before the request, and
+// a server that requires Content-Length gets bodies compressed in memory.
+func (c *Client) streams() bool {
+	return c.sign == nil && c.resumableSize == 0 && !c.buffered.Load()
+}
+
+// postStreamed posts a chunk compressed as it is sent: the payload is
+// encoded and compressed into the request body through a pipe, so the
+// compressed chunk is never held in memory. The body is sent with chunked
+// transfer encoding, its sizes counted on the way.
+func (c *Client) postStreamed(r *chunkResult, payload dto.VisitedSitesDTO, asMsgpack bool, contentType string, headers map[string]string) error {
+	pr, pw := io.Pipe()
+	var original, compressed countingWriter
+	var encode time.Duration
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		started := time.Now()
+		compressed.w = pw
+		gz := gzip.NewWriter(&compressed)
+		original.w = gz
+		err := encodePayload(&original, payload, asMsgpack)
 		if err == nil {
-			return bytesSent, bytesOriginal, nil
+			err = gz.Close()
 		}
+		encode = time.Since(started)
+		pw.CloseWithError(err)
+	}()
 
-		// If server rejected gzip (415 Unsupported Media Type), retry without compression
-		if isUnsupportedMediaType(err) {
-			bytesSent, err = c.sendRaw(data)
-			return bytesSent, bytesOriginal, err
-		}
+	started := time.Now()
+	req, err := c.newChunkRequest(pr, contentType, "gzip", headers)
+	if err == nil {
+		err = c.do(r, req)
+	}
+	// A server answering before reading the whole body stops the encoding
+	pr.Close()
+	<-done
+	r.encode += encode
+	r.http += max(time.Since(started)-encode, 0)
+	r.bytesOriginal = original.n
+	if err == nil {
+		r.bytesSent = compressed.n
+	}
+	return err
+}
 
-		return 0, bytesOriginal, err
+// encodePayload writes a payload as MessagePack or JSON
+func encodePayload(w io.Writer, payload dto.VisitedSitesDTO, asMsgpack bool) error {
+	if !asMsgpack {
+		if err := json.NewEncoder(w).Encode(payload); err != nil {
+			return fmt.Errorf("failed to marshal payload: %w", err)
+		}
+		return nil
+	}
+	data, err := msgpack.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
 	}
+	_, err = w.Write(data)
+	return err
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
 
-	bytesSent, err := c.sendRaw(data)
-	return bytesSent, bytesOriginal, err
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
 }
 
-// sendWithGzip sends gzip-compressed data
-func (c *Client) sendWithGzip(data []byte) (int64, error) {
+// gzipData compresses data with gzip
+func gzipData(data []byte) ([]byte, error) {
 	var compressed bytes.Buffer
 	gzWriter, err := gzip.NewWriterLevel(&compressed, gzip.DefaultCompression)
 	if err != nil {
-		return 0, fmt.Errorf("failed to create gzip writer: %w", err)
+		return nil, fmt.Errorf("failed to create gzip writer: %w", err)
 	}
 
 	if _, err := gzWriter.Write(data); err != nil {
-		return 0, fmt.Errorf("failed to write gzip data: %w", err)
+		return nil, fmt.Errorf("failed to write gzip data: %w", err)
 	}
 
 	if err := gzWriter.Close(); err != nil {
-		return 0, fmt.Errorf("failed to close gzip writer: %w", err)
+		return nil, fmt.Errorf("failed to close gzip writer: %w", err)
+	}
+
+	return compressed.Bytes(), nil
+}
+
+// post sends a chunk body with the given Content-Type, Content-Encoding (""
+// for none) and scan metadata headers, recording its duration, HTTP status
+// and, on success, size in r
+func (c *Client) post(r *chunkResult, body []byte, contentType, encoding string, headers map[string]string) error {
+	started := time.Now()
+	defer func() { r.http += time.Since(started) }()
+
+	req, err := c.newChunkRequest(bytes.NewReader(body), contentType, encoding, headers)
+	if err != nil {
+		return err
+	}
+	c.signRequest(req, body)
+	if err := c.do(r, req); err != nil {
+		return err
+	}
+
+	r.bytesSent = int64(len(body))
+	return nil
+}
+
+// newChunkRequest returns the request posting a chunk body
+func (c *Client) newChunkRequest(body io.Reader, contentType, encoding string, headers map[string]string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, c.serverURL, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	c.authorize(req)
+	return req, nil
+}
+
+// do sends a chunk request, recording the HTTP status in r
+func (c *Client) do(r *chunkResult, req *http.Request) error {
+	r.status = 0
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	r.status = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return c.responseError(resp, c.serverURL)
+	}
+	return nil
+}
+
+// SendReport posts a run report to the status endpoint. Reports are small,
+// so they are sent uncompressed in a single request.
+func (c *Client) SendReport(statusURL string, report dto.RunReportDTO) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal run report: %w", err)
+	}
+	return c.postJSON(statusURL, data)
+}
+
+// SendErrorReport posts an error event to the error endpoint, uncompressed
+// in a single request like run reports
+func (c *Client) SendErrorReport(errorURL string, report dto.ErrorReportDTO) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal error report: %w", err)
+	}
+	return c.postJSON(errorURL, data)
+}
+
+// SendHistoryEvent posts a history-clearing event to the events endpoint,
+// uncompressed in a single request like run reports
+func (c *Client) SendHistoryEvent(eventsURL string, event dto.HistoryEventDTO) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history event: %w", err)
+	}
+	return c.postJSON(eventsURL, data)
+}
+
+// SendServiceEvent posts a new-service event to the events endpoint
+func (c *Client) SendServiceEvent(eventsURL string, event dto.ServiceEventDTO) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal service event: %w", err)
+	}
+	return c.postJSON(eventsURL, data)
+}
+
+// SendOAuthGrant posts an OAuth grant event to the events endpoint
+func (c *Client) SendOAuthGrant(eventsURL string, event dto.OAuthGrantDTO) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal OAuth grant event: %w", err)
+	}
+	return c.postJSON(eventsURL, data)
+}
+
+// SendSignupEvent posts a sign-up event to the events endpoint
+func (c *Client) SendSignupEvent(eventsURL string, event dto.SignupEventDTO) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sign-up event: %w", err)
+	}
+	return c.postJSON(eventsURL, data)
+}
+
+// SendAIToolEvent posts a first AI tool use to the events endpoint
+func (c *Client) SendAIToolEvent(eventsURL string, event dto.AIToolEventDTO) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal AI tool event: %w", err)
+	}
+	return c.postJSON(eventsURL, data)
+}
+
+// SendAIUsage posts a user's AI tool visits in a scan to the events endpoint
+func (c *Client) SendAIUsage(eventsURL string, event dto.AIUsageDTO) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal AI usage event: %w", err)
 	}
+	return c.postJSON(eventsURL, data)
+}
 
-	req, err := http.NewRequest(http.MethodPost, c.serverURL, &compressed)
+// SendWatchlistAlert posts a watchlist alert to the alert endpoint
+func (c *Client) SendWatchlistAlert(alertURL string, event dto.WatchlistAlertDTO) error {
+	data, err := json.Marshal(event)
 	if err != nil {
-		return 0, fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to marshal watchlist alert: %w", err)
 	}
+	return c.postJSON(alertURL, data)
+}
 
+// Webhook signature headers: the Unix time of the request and the hex
+// HMAC-SHA256 of "<timestamp>.<body>" with the webhook secret
+const (
+	WebhookTimestampHeader = "X-Hist-Scanner-Timestamp"
+	WebhookSignatureHeader = "X-Hist-Scanner-Signature"
+)
+
+// SendWebhook posts a scan summary to a webhook, signed with the secret
+// instead of the API key, which is not disclosed to it
+func (c *Client) SendWebhook(webhookURL, secret string, summary dto.ScanWebhookDTO) error {
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook: %w", err)
+	}
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, webhookURL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(data)
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Content-Encoding", "gzip")
-	req.Header.Set("Authorization", "ProxyToken "+c.apiKey)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return 0, fmt.Errorf("request failed: %w", err)
+		return fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return 0, &httpError{statusCode: resp.StatusCode, url: c.serverURL}
+		return c.responseError(resp, webhookURL)
+	}
+	return nil
+}
+
+// FetchSanctioned downloads the approved services, a JSON array of catalog
+// names and domains
+func (c *Client) FetchSanctioned(sanctionedURL string) ([]string, error) {
+	return c.fetchList(sanctionedURL)
+}
+
+// FetchWatchlist downloads watchlist entries, a JSON array of domains and
+// patterns
+func (c *Client) FetchWatchlist(watchlistURL string) ([]string, error) {
+	return c.fetchList(watchlistURL)
+}
+
+// fetchList downloads a JSON array of strings of at most maxListSize bytes
+func (c *Client) fetchList(listURL string) ([]string, error) {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, listURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	req.Header.Set("Accept", "application/json")
+	c.authorize(req)
 
-	return int64(compressed.Len()), nil
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, c.responseError(resp, listURL)
+	}
+	var list []string
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxListSize)).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", listURL, err)
+	}
+	return list, nil
 }
 
-// sendRaw sends uncompressed data
-func (c *Client) sendRaw(data []byte) (int64, error) {
-	req, err := http.NewRequest(http.MethodPost, c.serverURL, bytes.NewReader(data))
+// maxListSize bounds the sanctioned services and watchlist documents
+const maxListSize = 1 << 20
+
+// postJSON posts a small JSON document with the API key
+func (c *Client) postJSON(url string, data []byte) error {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, url, bytes.NewReader(data))
 	if err != nil {
-		return 0, fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "ProxyToken "+c.apiKey)
+	c.authorize(req)
+	c.signRequest(req, data)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return 0, fmt.Errorf("request failed: %w", err)
+		return fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return 0, &httpError{statusCode: resp.StatusCode, url: c.serverURL}
+		return c.responseError(resp, url)
 	}
 
-	return int64(len(data)), nil
+	return nil
+}
+
+// signRequest adds the signature of the body as sent (compressed, if it is)
+func (c *Client) signRequest(req *http.Request, body []byte) {
+	if c.sign != nil {
+		req.Header.Set(devicekey.SignatureHeader, c.sign(body))
+	}
+}
+
+// RegisterDevice posts the device's signing key to the devices endpoint
+// next to the upload endpoint. The request is signed with the key itself,
+// proving possession.
+func (c *Client) RegisterDevice(key dto.DeviceKeyDTO) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("failed to marshal device key: %w", err)
+	}
+	return c.postJSON(devicesURL(c.serverURL), data)
+}
+
+// devicesURL returns the device registration endpoint of an upload endpoint
+func devicesURL(serverURL string) string {
+	return strings.TrimSuffix(serverURL, "/visited-sites") + "/devices"
+}
+
+// AggregatesMediaType is the content type of the v2 domain aggregates payload
+const AggregatesMediaType = "application/vnd.hist-scanner.domains.v2+json"
+
+// aggregatesURL returns the domain aggregates endpoint of an upload endpoint
+func aggregatesURL(serverURL string) string {
+	return strings.TrimSuffix(serverURL, "/visited-sites") + "/v2/domains"
+}
+
+// SendAggregates posts the domain aggregates of a scan to the v2 endpoint,
+// compressed if enabled (retrying uncompressed on 415, like visits)
+func (c *Client) SendAggregates(payload dto.DomainAggregatesDTO) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal aggregates: %w", err)
+	}
+	if !c.compress {
+		return c.postAggregates(data, "")
+	}
+	compressed, err := gzipData(data)
+	if err != nil {
+		return err
+	}
+	err = c.postAggregates(compressed, "gzip")
+	if isUnsupportedMediaType(err) {
+		err = c.postAggregates(data, "")
+	}
+	return err
+}
+
+// NegotiateAggregates asks the server whether it accepts the v2 aggregates
+// payload by posting an empty one. A server without the endpoint (404, 405)
+// or the media type (406, 415) does not; other failures are returned.
+func (c *Client) NegotiateAggregates() (bool, error) {
+	data, err := json.Marshal(dto.DomainAggregatesDTO{Version: dto.AggregatesVersion, Domains: []dto.DomainAggregateDTO{}})
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal aggregates: %w", err)
+	}
+	err = c.postAggregates(data, "")
+	if httpErr, ok := err.(*httpError); ok {
+		switch httpErr.statusCode {
+		case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotAcceptable, http.StatusUnsupportedMediaType:
+			return false, nil
+		}
+	}
+	return err == nil, err
+}
+
+// postAggregates posts a v2 aggregates body with the given Content-Encoding
+func (c *Client) postAggregates(body []byte, encoding string) error {
+	url := aggregatesURL(c.serverURL)
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", AggregatesMediaType)
+	req.Header.Set("Accept", AggregatesMediaType)
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	c.authorize(req)
+	c.signRequest(req, body)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return c.responseError(resp, url)
+	}
+	return nil
 }
 
 // httpError represents an HTTP error with status code
 type httpError struct {
 	statusCode int
 	url        string
+	headers    []string // Diagnostic response headers, "Name: value"
+	body       string   // Start of the response body, sanitized
 }
 
 func (e *httpError) Error() string {
+	msg := fmt.Sprintf("server returned status %d", e.statusCode)
 	if e.url != "" {
-		return fmt.Sprintf("server returned status %d (%s)", e.statusCode, e.url)
+		msg += " (" + e.url + ")"
+	}
+	if len(e.headers) > 0 {
+		msg += " [" + strings.Join(e.headers, ", ") + "]"
 	}
-	return fmt.Sprintf("server returned status %d", e.statusCode)
+	if e.body != "" {
+		msg += ": " + e.body
+	}
+	return msg
+}
+
+// Limits of the response body kept in an httpError
+const (
+	errorBodyRead  = 4096 // Bytes read
+	errorBodyShown = 300  // Characters kept
+)
+
+// diagnosticHeaders are response headers that help tell why a server
+// refused a request: request ids to look it up in the server's logs, when
+// to retry and the authentication expected
+var diagnosticHeaders = []string{"X-Request-Id", "X-Correlation-Id", "Request-Id", "Retry-After", "WWW-Authenticate"}
+
+// responseError returns the httpError of a failed response with the
+// diagnostic headers and the start of its body. Text bodies are kept on one
+// line and cut short, HTML error pages are reduced to their title, other
+// bodies are left out and the API key is never repeated.
+func (c *Client) responseError(resp *http.Response, url string) *httpError {
+	e := &httpError{statusCode: resp.StatusCode, url: url}
+	for _, name := range diagnosticHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			e.headers = append(e.headers, name+": "+c.sanitize(value, errorBodyShown))
+		}
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, errorBodyRead))
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "text/html":
+		if m := htmlTitle.FindSubmatch(data); m != nil {
+			e.body = c.sanitize(html.UnescapeString(string(m[1])), errorBodyShown)
+		}
+	case mediaType == "", strings.HasPrefix(mediaType, "text/"), strings.HasSuffix(mediaType, "json"), strings.HasSuffix(mediaType, "xml"):
+		e.body = c.sanitize(string(data), errorBodyShown)
+	}
+	return e
+}
+
+// htmlTitle matches the title of an HTML page
+var htmlTitle = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// sanitize puts text from a server on one line of at most n characters,
+// without control characters or the API key
+func (c *Client) sanitize(s string, n int) string {
+	s = strings.ToValidUTF8(s, "\uFFFD")
+	if c.apiKey != "" {
+		s = strings.ReplaceAll(s, c.apiKey, "[redacted]")
+	}
+	s = strings.Join(strings.FieldsFunc(s, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }), " ")
+	if r := []rune(s); len(r) > n {
+		s = string(r[:n]) + "..."
+	}
+	return s
 }
 
 // normalizeServerURL ensures the endpoint ends with /visited-sites (once)
@@ -252,6 +1140,15 @@ func normalizeServerURL(u string) string {
 	return trimmed + "/visited-sites"
 }
 
+// isLengthRequired checks if error is 411 Length Required, the answer of
+// servers that do not take chunked request bodies
+func isLengthRequired(err error) bool {
+	if httpErr, ok := err.(*httpError); ok {
+		return httpErr.statusCode == http.StatusLengthRequired
+	}
+	return false
+}
+
 // isUnsupportedMediaType checks if error is 415 Unsupported Media Type
 func isUnsupportedMediaType(err error) bool {
 	if httpErr, ok := err.(*httpError); ok {
@@ -260,18 +1157,48 @@ func isUnsupportedMediaType(err error) bool {
 	return false
 }
 
-// TestConnection tests if the server is reachable
+// SetHealthURL makes TestConnection check a health endpoint: an absolute
+// URL, or a reference resolved against the upload endpoint (/healthz is on
+// the server's host, health next to /visited-sites)
+func (c *Client) SetHealthURL(healthURL string) {
+	c.healthURL = healthURL
+}
+
+// TestConnection checks that the server is reachable and takes the API key
+// without sending data. With a health endpoint (SetHealthURL) it sends GET
+// there, which must answer 2xx. Otherwise it sends HEAD to the upload
+// endpoint, where any answer but 401, 403, 404 and 5xx will do: servers
+// routing only POST there answer 405.
 func (c *Client) TestConnection() error {
-	// Send empty payload to test connection
-	testPayload := dto.VisitedSitesDTO{
-		Principal: dto.PrincipalDTO{
-			Name: "test",
-			Kind: dto.KindUsername,
-		},
-		VisitedSites: []dto.VisitedSite{},
-		Source:       "test",
+	method, target := http.MethodHead, c.serverURL
+	if c.healthURL != "" {
+		base, err := url.Parse(c.serverURL)
+		if err != nil {
+			return fmt.Errorf("invalid server URL: %w", err)
+		}
+		ref, err := url.Parse(c.healthURL)
+		if err != nil {
+			return fmt.Errorf("invalid health URL: %w", err)
+		}
+		method, target = http.MethodGet, base.ResolveReference(ref).String()
 	}
+	req, err := http.NewRequestWithContext(c.ctx, method, target, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	c.authorize(req)
 
-	_, _, err := c.sendChunk(testPayload)
-	return err
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch status := resp.StatusCode; {
+	case status >= 200 && status < 300:
+		return nil
+	case method == http.MethodHead && status < 500 && status != http.StatusUnauthorized && status != http.StatusForbidden && status != http.StatusNotFound:
+		return nil
+	}
+	return c.responseError(resp, target)
 }
@@ -0,0 +1,95 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package sender
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// clockSkew estimates how far the server's clock is ahead of the device's
+// from the Date headers of the server's responses. Date has a resolution of
+// a second and is set somewhere between the request and its response, so
+// the estimate of the response with the shortest round trip is kept, as an
+// NTP client keeps its best sample.
+type clockSkew struct {
+	mu    sync.Mutex
+	skew  time.Duration
+	rtt   time.Duration
+	known bool
+}
+
+// observe adds the sample of a response with Date header date to a request
+// sent at sent and answered at received
+func (k *clockSkew) observe(date string, sent, received time.Time) {
+	server, err := http.ParseTime(date)
+	if err != nil {
+		return
+	}
+	rtt := received.Sub(sent)
+	// The server's time was somewhere in the second Date names
+	skew := server.Add(500 * time.Millisecond).Sub(sent.Add(rtt / 2))
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !k.known || rtt < k.rtt {
+		k.skew, k.rtt, k.known = skew.Round(time.Millisecond), rtt, true
+	}
+}
+
+// reset forgets the samples taken so far
+func (k *clockSkew) reset() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.skew, k.rtt, k.known = 0, 0, false
+}
+
+// clockTransport samples the clock skew from every response of the server
+type clockTransport struct {
+	base  http.RoundTripper
+	clock *clockSkew
+}
+
+func (t clockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	sent := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err == nil {
+		t.clock.observe(resp.Header.Get("Date"), sent, time.Now())
+	}
+	return resp, err
+}
+
+// ClockSkew returns how far the server's clock is ahead of the device's
+// (negative if it is behind), estimated from the responses of the server so
+// far, and false if none had a Date header
+func (c *Client) ClockSkew() (time.Duration, bool) {
+	c.clock.mu.Lock()
+	defer c.clock.mu.Unlock()
+	return c.clock.skew, c.clock.known
+}
+
+// MeasureClock starts a new estimate of the clock skew with a HEAD request
+// to the upload endpoint; any response with a Date header will do. Later
+// responses refine the estimate.
+func (c *Client) MeasureClock() error {
+	c.clock.reset()
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodHead, c.serverURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	c.authorize(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	resp.Body.Close()
+
+	if _, ok := c.ClockSkew(); !ok {
+		return fmt.Errorf("server sent no Date header")
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package sender
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Resumable uploads follow the core protocol of tus 1.0
+// (https://tus.io/protocols/resumable-upload): a chunk body is announced
+// with its length, sent in parts, and after a connection reset the client
+// asks the server how much of it arrived and sends the rest.
+const (
+	TusResumableHeader   = "Tus-Resumable"
+	UploadLengthHeader   = "Upload-Length"
+	UploadOffsetHeader   = "Upload-Offset"
+	UploadMetadataHeader = "Upload-Metadata"
+
+	tusVersion      = "1.0.0"
+	offsetMediaType = "application/offset+octet-stream"
+)
+
+const (
+	resumablePartSize   = 256 * 1024      // Bytes sent per PATCH request
+	resumableRetries    = 5               // Interruptions an upload survives
+	resumableRetryDelay = 2 * time.Second // Wait before the first resume, growing linearly
+)
+
+// errNoUploads is returned when the server has no resumable uploads
+var errNoUploads = errors.New("server does not support resumable uploads")
+
+// SetResumable uploads chunk bodies of at least thresholdKB kilobytes (as
+// sent) through resumable upload sessions; 0 posts every chunk whole. A
+// server without the uploads endpoint gets whole chunks.
+func (c *Client) SetResumable(thresholdKB int) {
+	c.resumableSize = thresholdKB * 1024
+}
+
+// uploadsURL returns the resumable uploads endpoint of an upload endpoint
+func uploadsURL(serverURL string) string {
+	return strings.TrimSuffix(serverURL, "/visited-sites") + "/uploads"
+}
+
+// send posts a chunk body, through a resumable upload if it is large enough
+// and the server supports them
+func (c *Client) send(r *chunkResult, body []byte, contentType, encoding string, headers map[string]string) error {
+	if c.resumableSize == 0 || len(body) < c.resumableSize || c.wholeOnly.Load() {
+		return c.post(r, body, contentType, encoding, headers)
+	}
+	err := c.upload(r, body, contentType, encoding, headers)
+	if !errors.Is(err, errNoUploads) {
+		return err
+	}
+	r.wholeFallback = !c.wholeOnly.Swap(true)
+	return c.post(r, body, contentType, encoding, headers)
+}
+
+// upload sends a chunk body through a resumable upload session, recording
+// its duration, last HTTP status and, on success, size in r. The chunk is
+// delivered once the server has all of its bytes; the response to the last
+// part carries the server's verdict on the chunk, like the response to a
+// whole post.
+func (c *Client) upload(r *chunkResult, body []byte, contentType, encoding string, headers map[string]string) error {
+	started := time.Now()
+	defer func() { r.http += time.Since(started) }()
+	r.status = 0
+
+	session, err := c.createUpload(r, body, contentType, encoding, headers)
+	if err != nil {
+		return err
+	}
+	failures := 0
+	for offset := int64(0); offset < int64(len(body)); {
+		end := min(offset+resumablePartSize, int64(len(body)))
+		next, err := c.patchUpload(r, session, body[offset:end], offset)
+		if err == nil {
+			offset = next
+			continue
+		}
+		if !isInterrupted(err) {
+			return err
+		}
+		if offset, err = c.resumeUpload(session, err, &failures); err != nil {
+			return err
+		}
+		r.resumed++
+	}
+	r.bytesSent = int64(len(body))
+	return nil
+}
+
+// createUpload announces a chunk body and returns the URL of its session.
+// The request carries the headers and signature a whole post would, and the
+// Content-Type and Content-Encoding of the body in Upload-Metadata.
+func (c *Client) createUpload(r *chunkResult, body []byte, contentType, encoding string, headers map[string]string) (string, error) {
+	endpoint := uploadsURL(c.serverURL)
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	metadata := "contentType " + base64.StdEncoding.EncodeToString([]byte(contentType))
+	if encoding != "" {
+		metadata += ",contentEncoding " + base64.StdEncoding.EncodeToString([]byte(encoding))
+	}
+	req.Header.Set(TusResumableHeader, tusVersion)
+	req.Header.Set(UploadLengthHeader, strconv.Itoa(len(body)))
+	req.Header.Set(UploadMetadataHeader, metadata)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	c.authorize(req)
+	c.signRequest(req, body)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	r.status = resp.StatusCode
+	switch resp.StatusCode {
+	case http.StatusCreated:
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return "", errNoUploads
+	default:
+		return "", c.responseError(resp, endpoint)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.Header.Get("Location") == "" {
+		return "", fmt.Errorf("server created an upload without a valid Location")
+	}
+	base, _ := url.Parse(endpoint)
+	return base.ResolveReference(location).String(), nil
+}
+
+// patchUpload sends a part of a body starting at offset and returns the
+// offset the server has after it
+func (c *Client) patchUpload(r *chunkResult, session string, part []byte, offset int64) (int64, error) {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPatch, session, bytes.NewReader(part))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set(TusResumableHeader, tusVersion)
+	req.Header.Set(UploadOffsetHeader, strconv.FormatInt(offset, 10))
+	req.Header.Set("Content-Type", offsetMediaType)
+	c.authorize(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	r.status = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return 0, c.responseError(resp, session)
+	}
+	next, err := strconv.ParseInt(resp.Header.Get(UploadOffsetHeader), 10, 64)
+	if err != nil || next <= offset || next > offset+int64(len(part)) {
+		return 0, fmt.Errorf("server returned an invalid %s after a part at %d", UploadOffsetHeader, offset)
+	}
+	return next, nil
+}
+
+// resumeUpload waits for an interrupted upload's connection to come back and
+// returns the offset the server has. It gives up with the last error once
+// the upload was interrupted resumableRetries times.
+func (c *Client) resumeUpload(session string, lastErr error, failures *int) (int64, error) {
+	for {
+		*failures++
+		if *failures > resumableRetries {
+			return 0, fmt.Errorf("upload interrupted %d times: %w", resumableRetries, lastErr)
+		}
+		select {
+		case <-c.ctx.Done():
+			return 0, c.ctx.Err()
+		case <-time.After(time.Duration(*failures) * resumableRetryDelay):
+		}
+		offset, err := c.uploadOffset(session)
+		if err == nil {
+			return offset, nil
+		}
+		if !isInterrupted(err) {
+			return 0, err
+		}
+		lastErr = err
+	}
+}
+
+// uploadOffset asks the server how many bytes of an upload it has
+func (c *Client) uploadOffset(session string) (int64, error) {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodHead, session, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set(TusResumableHeader, tusVersion)
+	c.authorize(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return 0, c.responseError(resp, session)
+	}
+	offset, err := strconv.ParseInt(resp.Header.Get(UploadOffsetHeader), 10, 64)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("server returned an invalid %s for %s", UploadOffsetHeader, session)
+	}
+	return offset, nil
+}
+
+// isInterrupted reports whether a part of an upload may have been lost on
+// the way: the connection failed, the server failed or the offsets of
+// client and server disagree (409 Conflict). The upload then resumes from
+// the offset the server reports, unless the client's context is done.
+func isInterrupted(err error) bool {
+	var httpErr *httpError
+	if errors.As(err, &httpErr) {
+		return httpErr.statusCode == http.StatusConflict || (httpErr.statusCode >= 500 && httpErr.statusCode != http.StatusNotImplemented)
+	}
+	return err != nil
+}
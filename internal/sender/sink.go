@@ -0,0 +1,78 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package sender
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"hist_scanner/pkg/sink"
+)
+
+// Sink is the http sink: it uploads payloads to the server with a client
+type Sink struct {
+	c     *Client
+	mu    sync.Mutex
+	stats sink.Stats
+}
+
+// NewSink returns the http sink of a client
+func NewSink(c *Client) *Sink {
+	return &Sink{c: c}
+}
+
+// Send uploads a payload in chunks. If only some chunks were accepted, the
+// visits of the accepted ones are reported in a *sink.PartialError.
+func (s *Sink) Send(ctx context.Context, payload sink.Payload) error {
+	result, maxTimestamp, err := s.c.WithContext(ctx).Send(payload)
+	if result != nil {
+		s.mu.Lock()
+		s.stats.Bytes += result.BytesSent
+		s.stats.Encode += result.EncodeTime
+		s.stats.Transfer += result.HTTPTime
+		if result.AuditError != nil {
+			s.stats.Warnings = append(s.stats.Warnings, result.AuditError.Error())
+		}
+		if result.JSONFallback {
+			s.stats.Warnings = append(s.stats.Warnings, "server does not accept MessagePack payloads, sending JSON")
+		}
+		if result.WholeFallback {
+			s.stats.Warnings = append(s.stats.Warnings, "server does not support resumable uploads, posting chunks whole")
+		}
+		if result.Resumed > 0 {
+			s.stats.Warnings = append(s.stats.Warnings, fmt.Sprintf("resumed %d interrupted uploads", result.Resumed))
+		}
+		if result.SchemaV1 != nil {
+			s.stats.Warnings = append(s.stats.Warnings, fmt.Sprintf("server rejected visits payload version 2, sending version 1 without browser details: %v", result.SchemaV1))
+		}
+		s.mu.Unlock()
+	}
+	if err != nil {
+		return err
+	}
+	if result.FailedCount > 0 {
+		return &sink.PartialError{Sent: result.TotalSent, MaxTimestamp: maxTimestamp, MaxRowID: result.MaxRowID, Err: result.LastError}
+	}
+	return nil
+}
+
+// Flush does nothing; every payload is sent by Send
+func (s *Sink) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Close does nothing; the client is shared with the other requests of the scanner
+func (s *Sink) Close() error {
+	return nil
+}
+
+// TakeStats returns the upload stats since the last call
+func (s *Sink) TakeStats() sink.Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.stats
+	s.stats = sink.Stats{}
+	return st
+}
@@ -0,0 +1,30 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package service
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Name is the service name registered with the OS service manager
+const Name = "hist_scanner"
+
+// Run executes fn until it returns, the OS service manager stops the service,
+// or a termination signal is received. fn must return when ctx is cancelled.
+// This is implemented per-platform in service_*.go files
+func Run(fn func(ctx context.Context)) error {
+	return runImpl(fn)
+}
+
+// runInteractive runs fn in the foreground until SIGINT/SIGTERM
+func runInteractive(fn func(ctx context.Context)) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fn(ctx)
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package service
+
+import "context"
+
+// runImpl runs in the foreground; systemd/launchd supervise the process directly
+func runImpl(fn func(ctx context.Context)) error {
+	return runInteractive(fn)
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package state
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+
+	"hist_scanner/internal/seal"
+)
+
+// encryptedMagic prefixes encrypted state files so they can be told apart from plain JSON
+var encryptedMagic = []byte("HSENC1")
+
+// keyInfo binds derived keys to their purpose
+const keyInfo = "hist_scanner state encryption"
+
+// DeriveKey derives a 256-bit AES key for the state file from secret, or,
+// if secret is empty, from the master key in keyFile (see seal.Key)
+func DeriveKey(secret, keyFile string) ([]byte, error) {
+	return seal.Key(secret, keyInfo, keyFile)
+}
+
+// encrypt seals data with AES-GCM: magic || nonce || ciphertext
+func encrypt(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	out := append([]byte{}, encryptedMagic...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, data, encryptedMagic), nil
+}
+
+// decrypt opens data produced by encrypt
+func decrypt(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data = data[len(encryptedMagic):]
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted state is truncated")
+	}
+
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, encryptedMagic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt state (wrong key?): %w", err)
+	}
+	return plain, nil
+}
+
+// isEncrypted reports whether data is an encrypted state file
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, encryptedMagic)
+}
+
+// newGCM creates an AES-GCM AEAD for the given key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid state key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -5,21 +5,136 @@ package state
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"hist_scanner/internal/platform"
+	"hist_scanner/internal/seal"
 )
 
 // Manager handles state persistence for scan timestamps
 type Manager struct {
 	stateFile string
-	data      map[string]int64 // key: "user/browser/profile", value: last timestamp (Unix ms)
+	data      map[string]ProfileState // key: "user/browser/profile"
+	runs      []RunRecord             // Most recent last, at most maxRuns
+	deviceID  string                  // Generated device id, used when the OS provides none
+	lastSend  time.Time               // When history was last accepted by the server
+	notice    NoticeRecord            // Disclosure notice in place
+	deviceKey string                  // Id of the signing key the server accepted
+	services  map[string]int64        // Unsanctioned domains reported as new services, with when they were first seen
+	grants    map[string]int64        // OAuth grants reported, by user/provider/client id, with when they were first seen
+	signups   map[string]int64        // Sign-ups reported, by user/domain, with when they were first seen
+	aiTools   map[string]int64        // First AI tool uses reported, by user/service, with when they were first seen
+	domains   map[string]DomainSeen   // Registrable domains visited on the device
+	key       []byte                  // AES-GCM key; nil stores state as plain JSON
+	legacyKey []byte                  // Machine id key of older versions, only to read their state
+	sealed    string                  // State file Load could not decrypt; Save does not overwrite it
 	mu        sync.RWMutex
 }
 
+// ErrStateKey is returned by Load when the state file is encrypted with
+// another key than the configured one
+var ErrStateKey = errors.New("state file cannot be decrypted with the configured key")
+
+// ProfileState is the persisted scan state of a single user/browser/profile
+type ProfileState struct {
+	LastTimestamp int64  `json:"last_timestamp"`        // Unix ms of the newest sent entry
+	LastRowID     int64  `json:"last_row_id,omitempty"` // Highest browser row id sent
+	Fingerprint   string `json:"fingerprint,omitempty"` // History database identity marker
+	MaxRowID      int64  `json:"max_row_id,omitempty"`  // Highest history row id seen at last scan
+	Rows          int64  `json:"rows,omitempty"`        // History rows seen at last scan
+	Postponed     int    `json:"postponed,omitempty"`   // Consecutive runs that left the profile to the next one while its browser ran
+}
+
+// RunRecord is the outcome of one scan run, kept for install status
+type RunRecord struct {
+	ScanID          string         `json:"scan_id,omitempty"`
+	Started         time.Time      `json:"started"`
+	DurationMS      int64          `json:"duration_ms"`
+	ExitCode        int            `json:"exit_code"`
+	Full            bool           `json:"full,omitempty"`
+	Ranged          bool           `json:"ranged,omitempty"`
+	UsersScanned    int            `json:"users_scanned"`
+	ProfilesScanned int            `json:"profiles_scanned"`
+	EntriesSent     int            `json:"entries_sent"`
+	UsersSkipped    int            `json:"users_skipped,omitempty"`
+	Errors          []string       `json:"errors,omitempty"`
+	ErrorCategories map[string]int `json:"error_categories,omitempty"` // Profile errors per category
+	Timing          *RunTiming     `json:"timing,omitempty"`
+}
+
+// RunTiming is where a run spent its time, for diagnosing slow machines
+type RunTiming struct {
+	EnumerationMS int64           `json:"enumeration_ms"` // Enumerating users and finding their profiles
+	Phases        PhaseMS         `json:"phases"`         // Totals over all profiles
+	BytesSent     int64           `json:"bytes_sent"`
+	Slowest       []ProfileTiming `json:"slowest,omitempty"`
+}
+
+// PhaseMS is the time spent in each phase of scanning profiles, in milliseconds
+type PhaseMS struct {
+	OpenMS      int64 `json:"open_ms"`
+	QueryMS     int64 `json:"query_ms"`
+	TransformMS int64 `json:"transform_ms"`
+	CompressMS  int64 `json:"compress_ms"`
+	HTTPMS      int64 `json:"http_ms"`
+}
+
+// ProfileTiming is the timing of one of a run's slowest profiles
+type ProfileTiming struct {
+	User       string  `json:"user"`
+	Browser    string  `json:"browser"`
+	Profile    string  `json:"profile"`
+	DurationMS int64   `json:"duration_ms"`
+	Entries    int     `json:"entries"`
+	Phases     PhaseMS `json:"phases"`
+}
+
+// maxRuns is the number of run records kept in the state file
+const maxRuns = 20
+
+// stateVersion is the current on-disk state format version
+const stateVersion = 3
+
+// visitRowsVersion is the first state version whose browser row ids and
+// Chromium fingerprints are of visits; older ones hold urls and moz_places ids
+const visitRowsVersion = 3
+
+// stateDocument is the on-disk layout of the state file
+type stateDocument struct {
+	Version   int                     `json:"version"`
+	Profiles  map[string]ProfileState `json:"profiles"`
+	Runs      []RunRecord             `json:"runs,omitempty"`
+	DeviceID  string                  `json:"device_id,omitempty"`
+	LastSend  time.Time               `json:"last_send,omitzero"`
+	Notice    NoticeRecord            `json:"notice,omitzero"`
+	DeviceKey string                  `json:"device_key,omitempty"` // Id of the registered signing key
+	Services  map[string]int64        `json:"services,omitempty"`   // Reported unsanctioned domains, first seen in Unix ms
+	Grants    map[string]int64        `json:"grants,omitempty"`     // Reported OAuth grants, first seen in Unix ms
+	Signups   map[string]int64        `json:"signups,omitempty"`    // Reported sign-ups, first seen in Unix ms
+	AITools   map[string]int64        `json:"ai_tools,omitempty"`   // Reported first AI tool uses, first seen in Unix ms
+	Domains   map[string]DomainSeen   `json:"domains,omitempty"`    // Visited registrable domains
+}
+
+// DomainSeen is when a registrable domain was first and last visited on
+// the device, in Unix milliseconds
+type DomainSeen struct {
+	First int64 `json:"first"`
+	Last  int64 `json:"last"`
+}
+
+// NoticeRecord records when the current disclosure notice was first in place
+type NoticeRecord struct {
+	Shown  time.Time `json:"shown"`
+	Digest string    `json:"digest"` // Identifies the notice text
+}
+
 // stateFileName is the hidden file name for per-profile state
 const stateFileName = ".hist_scanner_state"
 
@@ -28,10 +143,36 @@ const stateFileName = ".hist_scanner_state"
 func NewManager(stateFile string) *Manager {
 	return &Manager{
 		stateFile: stateFile,
-		data:      make(map[string]int64),
+		data:      make(map[string]ProfileState),
 	}
 }
 
+// EnableEncryption enables encryption of the state file with a key derived
+// from secret, or, if it is empty, from the master key next to the state
+// file. Plain state and state encrypted by older versions with the machine
+// id key are still read, and are encrypted with the new key on the next save.
+func (m *Manager) EnableEncryption(secret string) error {
+	key, err := DeriveKey(secret, m.KeyPath())
+	if err != nil {
+		return fmt.Errorf("failed to derive state encryption key: %w", err)
+	}
+	var legacy []byte
+	if secret == "" {
+		legacy, _ = seal.LegacyKey(keyInfo)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.key, m.legacyKey = key, legacy
+	return nil
+}
+
+// KeyPath returns the master key file used without a state_key: seal.key
+// next to the state file
+func (m *Manager) KeyPath() string {
+	return filepath.Join(m.StateDir(), seal.KeyFileName)
+}
+
 // Load loads state from file
 func (m *Manager) Load() error {
 	m.mu.Lock()
@@ -51,14 +192,84 @@ func (m *Manager) Load() error {
 		return fmt.Errorf("failed to read state file: %w", err)
 	}
 
-	if err := json.Unmarshal(data, &m.data); err != nil {
+	if isEncrypted(data) {
+		if m.key == nil {
+			m.sealed = path
+			return fmt.Errorf("%w: state_encryption is not enabled", ErrStateKey)
+		}
+		plain, err := decrypt(m.key, data)
+		if err != nil && m.legacyKey != nil {
+			plain, err = decrypt(m.legacyKey, data)
+		}
+		if err != nil {
+			m.sealed = path
+			return fmt.Errorf("%w: %v", ErrStateKey, err)
+		}
+		data = plain
+	}
+
+	doc, err := parseState(data)
+	if err != nil {
 		return fmt.Errorf("failed to parse state file: %w", err)
 	}
 
-	m.stateFile = path
+	m.data = doc.Profiles
+	m.runs = doc.Runs
+	m.deviceID = doc.DeviceID
+	m.lastSend = doc.LastSend
+	m.notice = doc.Notice
+	m.deviceKey = doc.DeviceKey
+	m.services = doc.Services
+	m.grants = doc.Grants
+	m.signups = doc.Signups
+	m.aiTools = doc.AITools
+	m.domains = doc.Domains
+	// State found elsewhere moves to an explicitly configured file
+	if m.stateFile == "" {
+		m.stateFile = path
+	}
 	return nil
 }
 
+// parseState decodes a state file, upgrading the legacy flat
+// "key -> timestamp" format written by older versions
+func parseState(data []byte) (stateDocument, error) {
+	var doc stateDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return doc, err
+	}
+
+	if doc.Version > 0 {
+		if doc.Profiles == nil {
+			doc.Profiles = make(map[string]ProfileState)
+		}
+		if doc.Version < visitRowsVersion {
+			// A url id is no position in the visits; the next scan resumes
+			// from the timestamp alone and fingerprints the visits afresh.
+			// Proxy logs keep byte offsets there.
+			for key, ps := range doc.Profiles {
+				if !strings.HasPrefix(key, "/proxy/") {
+					ps.LastRowID, ps.MaxRowID, ps.Rows = 0, 0, 0
+					doc.Profiles[key] = ps
+				}
+			}
+		}
+		return doc, nil
+	}
+
+	// Legacy format: {"user/browser/profile": 1702300800000}
+	var legacy map[string]int64
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return doc, err
+	}
+
+	profiles := make(map[string]ProfileState, len(legacy))
+	for key, timestamp := range legacy {
+		profiles[key] = ProfileState{LastTimestamp: timestamp}
+	}
+	return stateDocument{Profiles: profiles}, nil
+}
+
 // Save persists state to file
 func (m *Manager) Save() error {
 	m.mu.RLock()
@@ -73,19 +284,61 @@ func (m *Manager) Save() error {
 		}
 		m.stateFile = path
 	}
+	if m.sealed != "" && filepath.Clean(path) == filepath.Clean(m.sealed) {
+		return fmt.Errorf("refusing to overwrite %s: %w", path, ErrStateKey)
+	}
+
+	doc := stateDocument{
+		Version:   stateVersion,
+		Profiles:  m.data,
+		Runs:      m.runs,
+		DeviceID:  m.deviceID,
+		LastSend:  m.lastSend,
+		Notice:    m.notice,
+		DeviceKey: m.deviceKey,
+		Services:  m.services,
+		Grants:    m.grants,
+		Signups:   m.signups,
+		AITools:   m.aiTools,
+		Domains:   m.domains,
+	}
 
-	data, err := json.MarshalIndent(m.data, "", "  ")
+	data, err := json.MarshalIndent(doc, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal state: %w", err)
 	}
 
-	// Ensure directory exists
+	if m.key != nil {
+		if data, err = encrypt(m.key, data); err != nil {
+			return fmt.Errorf("failed to encrypt state: %w", err)
+		}
+	}
+
+	// Ensure directory exists (private: state keys contain user names)
 	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	if err := os.MkdirAll(dir, 0700); err != nil {
 		return fmt.Errorf("failed to create state directory: %w", err)
 	}
 
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	// Scans save after every page, so a crash must never leave a truncated
+	// state file: the new one replaces it only once it is on disk. The
+	// temp file is created with mode 0600.
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
 		return fmt.Errorf("failed to write state file: %w", err)
 	}
 
@@ -98,7 +351,7 @@ func (m *Manager) GetLastTimestamp(username, browserName, profileName string) in
 	defer m.mu.RUnlock()
 
 	key := makeKey(username, browserName, profileName)
-	return m.data[key]
+	return m.data[key].LastTimestamp
 }
 
 // SetLastTimestamp sets the last scan timestamp for a user/browser/profile
@@ -107,7 +360,326 @@ func (m *Manager) SetLastTimestamp(username, browserName, profileName string, ti
 	defer m.mu.Unlock()
 
 	key := makeKey(username, browserName, profileName)
-	m.data[key] = timestamp
+	ps := m.data[key]
+	ps.LastTimestamp = timestamp
+	m.data[key] = ps
+}
+
+// GetLastRowID returns the last sent browser row id for a user/browser/profile
+func (m *Manager) GetLastRowID(username, browserName, profileName string) int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.data[makeKey(username, browserName, profileName)].LastRowID
+}
+
+// SetLastRowID sets the last sent browser row id for a user/browser/profile
+func (m *Manager) SetLastRowID(username, browserName, profileName string, rowID int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := makeKey(username, browserName, profileName)
+	ps := m.data[key]
+	ps.LastRowID = rowID
+	m.data[key] = ps
+}
+
+// GetFingerprint returns the stored history database fingerprint for a user/browser/profile
+// with its highest row id and row count
+func (m *Manager) GetFingerprint(username, browserName, profileName string) (string, int64, int64) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ps := m.data[makeKey(username, browserName, profileName)]
+	return ps.Fingerprint, ps.MaxRowID, ps.Rows
+}
+
+// SetFingerprint records the history database fingerprint for a user/browser/profile
+func (m *Manager) SetFingerprint(username, browserName, profileName, fingerprint string, maxRowID, rows int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := makeKey(username, browserName, profileName)
+	ps := m.data[key]
+	ps.Fingerprint = fingerprint
+	ps.MaxRowID = maxRowID
+	ps.Rows = rows
+	m.data[key] = ps
+}
+
+// GetPostponed returns the consecutive runs that left a user/browser/profile
+// to the next run
+func (m *Manager) GetPostponed(username, browserName, profileName string) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.data[makeKey(username, browserName, profileName)].Postponed
+}
+
+// SetPostponed sets the consecutive runs that left a user/browser/profile to
+// the next run
+func (m *Manager) SetPostponed(username, browserName, profileName string, runs int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := makeKey(username, browserName, profileName)
+	ps := m.data[key]
+	ps.Postponed = runs
+	m.data[key] = ps
+}
+
+// ResetWatermark clears the scan position for a user/browser/profile so the
+// next scan falls back to initial_days
+func (m *Manager) ResetWatermark(username, browserName, profileName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := makeKey(username, browserName, profileName)
+	ps := m.data[key]
+	ps.LastTimestamp = 0
+	ps.LastRowID = 0
+	m.data[key] = ps
+}
+
+// Reset forgets the scan state of the user/browser/profile keys match
+// accepts and returns the keys removed, sorted. The next scan of those
+// profiles starts over as on the first run.
+func (m *Manager) Reset(match func(key string) bool) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var removed []string
+	for key := range m.data {
+		if match(key) {
+			delete(m.data, key)
+			removed = append(removed, key)
+		}
+	}
+	slices.Sort(removed)
+	return removed
+}
+
+// AddRun records the outcome of a scan run, dropping the oldest records
+// beyond maxRuns
+func (m *Manager) AddRun(run RunRecord) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.runs = append(m.runs, run)
+	if len(m.runs) > maxRuns {
+		m.runs = m.runs[len(m.runs)-maxRuns:]
+	}
+}
+
+// GetRuns returns up to n of the most recent run records, newest first
+func (m *Manager) GetRuns(n int) []RunRecord {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if n <= 0 || n > len(m.runs) {
+		n = len(m.runs)
+	}
+	runs := make([]RunRecord, 0, n)
+	for i := len(m.runs) - 1; i >= len(m.runs)-n; i-- {
+		runs = append(runs, m.runs[i])
+	}
+	return runs
+}
+
+// GetDeviceID returns the generated device id, or "" if none was stored
+func (m *Manager) GetDeviceID() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.deviceID
+}
+
+// SetDeviceID stores a generated device id
+func (m *Manager) SetDeviceID(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deviceID = id
+}
+
+// GetLastSend returns when history was last accepted by the server, or the
+// zero time if never
+func (m *Manager) GetLastSend() time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.lastSend
+}
+
+// SetLastSend records a successful send
+func (m *Manager) SetLastSend(t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastSend = t
+}
+
+// GetNotice returns the disclosure notice record, zero if no notice was shown
+func (m *Manager) GetNotice() NoticeRecord {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.notice
+}
+
+// SetNotice records the disclosure notice in place
+func (m *Manager) SetNotice(n NoticeRecord) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.notice = n
+}
+
+// GetDeviceKey returns the id of the registered signing key, "" if none
+func (m *Manager) GetDeviceKey() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.deviceKey
+}
+
+// SetDeviceKey records the id of the signing key the server accepted
+func (m *Manager) SetDeviceKey(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deviceKey = id
+}
+
+// IsServiceReported reports whether an unsanctioned domain was already
+// reported as a new service
+func (m *Manager) IsServiceReported(domain string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.services[domain]
+	return ok
+}
+
+// SetServiceReported records that an unsanctioned domain first seen at
+// firstSeen (Unix ms) was reported as a new service
+func (m *Manager) SetServiceReported(domain string, firstSeen int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.services == nil {
+		m.services = make(map[string]int64)
+	}
+	m.services[domain] = firstSeen
+}
+
+// IsGrantReported reports whether a user's OAuth grant to an app was
+// already reported
+func (m *Manager) IsGrantReported(username, provider, clientID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.grants[makeKey(username, provider, clientID)]
+	return ok
+}
+
+// SetGrantReported records that a user's OAuth grant to an app first seen
+// at firstSeen (Unix ms) was reported
+func (m *Manager) SetGrantReported(username, provider, clientID string, firstSeen int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.grants == nil {
+		m.grants = make(map[string]int64)
+	}
+	m.grants[makeKey(username, provider, clientID)] = firstSeen
+}
+
+// IsSignupReported reports whether a user's sign-up at a domain was already
+// reported
+func (m *Manager) IsSignupReported(username, domain string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.signups[username+"/"+domain]
+	return ok
+}
+
+// SetSignupReported records that a user's sign-up at a domain first seen
+// at firstSeen (Unix ms) was reported
+func (m *Manager) SetSignupReported(username, domain string, firstSeen int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.signups == nil {
+		m.signups = make(map[string]int64)
+	}
+	m.signups[username+"/"+domain] = firstSeen
+}
+
+// IsAIToolReported reports whether a user's first use of an AI tool was
+// already reported
+func (m *Manager) IsAIToolReported(username, service string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.aiTools[username+"/"+service]
+	return ok
+}
+
+// SetAIToolReported records that a user's first use of an AI tool, at
+// firstSeen (Unix ms), was reported
+func (m *Manager) SetAIToolReported(username, service string, firstSeen int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.aiTools == nil {
+		m.aiTools = make(map[string]int64)
+	}
+	m.aiTools[username+"/"+service] = firstSeen
+}
+
+// SeeDomain records a visit to a registrable domain at ts (Unix ms) and
+// reports whether the domain was never visited on the device before
+func (m *Manager) SeeDomain(domain string, ts int64) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.domains == nil {
+		m.domains = make(map[string]DomainSeen)
+	}
+	seen, ok := m.domains[domain]
+	if !ok {
+		m.domains[domain] = DomainSeen{First: ts, Last: ts}
+		return true
+	}
+	m.domains[domain] = DomainSeen{First: min(seen.First, ts), Last: max(seen.Last, ts)}
+	return false
+}
+
+// GetDomain returns when a registrable domain was first and last visited
+func (m *Manager) GetDomain(domain string) (DomainSeen, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	seen, ok := m.domains[domain]
+	return seen, ok
+}
+
+// PruneDomains forgets the domains last visited before a time (Unix ms)
+// and returns how many were removed
+func (m *Manager) PruneDomains(before int64) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := 0
+	for domain, seen := range m.domains {
+		if seen.Last < before {
+			delete(m.domains, domain)
+			n++
+		}
+	}
+	return n
 }
 
 // makeKey creates a state key from user/browser/profile
@@ -124,18 +696,14 @@ func (m *Manager) resolveStatePath() string {
 		}
 	}
 
-	// 2. Central config location
-	centralPath := getCentralStatePath()
-	if centralPath != "" {
-		if _, err := os.Stat(centralPath); err == nil {
-			return centralPath
+	// 2. Central (privileged) or per-user location, then namespaced temp
+	for _, path := range []string{getCentralStatePath(), getUserStatePath(), getLegacyUserStatePath(), getTempStatePath()} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			return path
 		}
-	}
-
-	// 3. Temp location
-	tempPath := getTempStatePath()
-	if _, err := os.Stat(tempPath); err == nil {
-		return tempPath
 	}
 
 	return ""
@@ -148,28 +716,35 @@ func (m *Manager) findWritablePath() string {
 		return m.stateFile
 	}
 
-	// 2. Central config location
+	// 2. Central config location (root/Administrator only)
 	centralPath := getCentralStatePath()
 	if centralPath != "" && canWrite(filepath.Dir(centralPath)) {
 		return centralPath
 	}
 
-	// 3. Temp location
+	// 3. Per-user location
+	userPath := getUserStatePath()
+	if userPath != "" && canWrite(filepath.Dir(userPath)) {
+		return userPath
+	}
+
+	// 4. Temp location
 	return getTempStatePath()
 }
 
-// getCentralStatePath returns the central state file path for the current OS
+// getCentralStatePath returns the system-wide state file path for the current OS.
+// Only privileged runs use it, so unprivileged users never share a state file.
 func getCentralStatePath() string {
+	if !platform.IsPrivileged() {
+		return ""
+	}
+
 	switch platform.CurrentOS() {
 	case platform.Linux:
-		// Check if running as root
-		if os.Getuid() == 0 {
-			return "/var/lib/hist_scanner/state.json"
-		}
-		home, _ := os.UserHomeDir()
-		if home != "" {
-			return filepath.Join(home, ".config/hist_scanner/state.json")
-		}
+		return "/var/lib/hist_scanner/state.json"
+
+	case platform.FreeBSD:
+		return "/var/db/hist_scanner/state.json"
 
 	case platform.Windows:
 		programData := os.Getenv("PROGRAMDATA")
@@ -188,15 +763,77 @@ func getCentralStatePath() string {
 	return ""
 }
 
-// getTempStatePath returns the temp state file path
+// getUserStatePath returns the per-user state file path for unprivileged runs
+func getUserStatePath() string {
+	switch platform.CurrentOS() {
+	case platform.Linux, platform.FreeBSD:
+		if xdg := os.Getenv("XDG_STATE_HOME"); xdg != "" && filepath.IsAbs(xdg) {
+			return filepath.Join(xdg, "hist_scanner", "state.json")
+		}
+		home, _ := os.UserHomeDir()
+		if home != "" {
+			return filepath.Join(home, ".local/state/hist_scanner/state.json")
+		}
+
+	case platform.Windows:
+		localAppData := os.Getenv("LOCALAPPDATA")
+		if localAppData != "" {
+			return filepath.Join(localAppData, "hist_scanner", "state.json")
+		}
+
+	case platform.Darwin:
+		home, _ := os.UserHomeDir()
+		if home != "" {
+			return filepath.Join(home, "Library/Application Support/hist_scanner/state.json")
+		}
+	}
+
+	return ""
+}
+
+// getLegacyUserStatePath returns the per-user path used by older versions on Linux,
+// so existing watermarks are still found after upgrading
+func getLegacyUserStatePath() string {
+	if platform.CurrentOS() != platform.Linux {
+		return ""
+	}
+	home, _ := os.UserHomeDir()
+	if home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".config/hist_scanner/state.json")
+}
+
+// getTempStatePath returns the temp state file path, namespaced per user, or
+// "" if its directory cannot be made private. The temp dir is shared, so a
+// directory another user created or replaced by a symlink is never used.
 func getTempStatePath() string {
-	return filepath.Join(os.TempDir(), "hist_scanner_state.json")
+	name := "hist_scanner"
+	if u, err := platform.GetCurrentUser(); err == nil && u.Username != "" {
+		name += "-" + tempDirName(u.DisplayName())
+	}
+	dir := filepath.Join(os.TempDir(), name)
+	if err := platform.MkdirPrivate(dir); err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "state.json")
+}
+
+// tempDirName makes a user name (DOMAIN\user on Windows) safe as part of a
+// directory name by replacing characters outside [A-Za-z0-9._-] with "_"
+func tempDirName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || strings.ContainsRune("._-", r) {
+			return r
+		}
+		return '_'
+	}, name)
 }
 
 // canWrite checks if we can write to a directory
 func canWrite(dir string) bool {
 	// Try to create directory if it doesn't exist
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	if err := os.MkdirAll(dir, 0700); err != nil {
 		return false
 	}
 
@@ -211,11 +848,36 @@ func canWrite(dir string) bool {
 	return true
 }
 
+// Locate returns the existing state file a scan would load, or "" if none exists
+func Locate(stateFile string) string {
+	m := &Manager{stateFile: stateFile}
+	return m.resolveStatePath()
+}
+
+// Dir returns the directory a scan keeps its state file in
+func Dir(stateFile string) string {
+	m := &Manager{stateFile: stateFile}
+	return m.StateDir()
+}
+
 // GetStateFilePath returns the current state file path
 func (m *Manager) GetStateFilePath() string {
 	return m.stateFile
 }
 
+// StateDir returns the directory the state file is in, or will be written
+// to if there is none yet
+func (m *Manager) StateDir() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	path := m.stateFile
+	if path == "" {
+		path = m.findWritablePath()
+	}
+	return filepath.Dir(path)
+}
+
 // GetAllEntries returns all state entries (for debugging)
 func (m *Manager) GetAllEntries() map[string]int64 {
 	m.mu.RLock()
@@ -223,7 +885,7 @@ func (m *Manager) GetAllEntries() map[string]int64 {
 
 	result := make(map[string]int64, len(m.data))
 	for k, v := range m.data {
-		result[k] = v
+		result[k] = v.LastTimestamp
 	}
 	return result
 }
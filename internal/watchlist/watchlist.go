@@ -0,0 +1,93 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package watchlist matches visited URLs against the domains and patterns
+// an administrator wants to be alerted about, such as known exfiltration
+// sites or a competitor's file sharing
+package watchlist
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// List is a parsed watchlist
+type List struct {
+	entries []entry
+}
+
+// entry is one watchlist entry: a domain, matching its subdomains too, or a
+// host[/path] pattern where * matches any characters
+type entry struct {
+	raw     string
+	domain  string
+	pattern *regexp.Regexp
+}
+
+// Parse parses watchlist entries. Entries without * or / are domains;
+// others are patterns matched against the host and path of a URL, e.g.
+// *.ngrok.io or drive.example.com/share/*.
+func Parse(entries []string) (*List, error) {
+	l := &List{}
+	for _, raw := range entries {
+		e := strings.ToLower(strings.TrimSpace(raw))
+		if e == "" {
+			continue
+		}
+		if strings.Contains(e, "://") {
+			return nil, fmt.Errorf("%q: entries are domains or host/path patterns without a scheme", raw)
+		}
+		host, path, hasPath := strings.Cut(e, "/")
+		if host == "" {
+			return nil, fmt.Errorf("%q: no host", raw)
+		}
+		if !hasPath && !strings.Contains(host, "*") {
+			l.entries = append(l.entries, entry{raw: raw, domain: strings.TrimSuffix(host, ".")})
+			continue
+		}
+		expr := "^" + glob(host)
+		if hasPath {
+			expr += "/" + glob(path)
+		} else {
+			expr += "(/.*)?"
+		}
+		l.entries = append(l.entries, entry{raw: raw, pattern: regexp.MustCompile(expr + "$")})
+	}
+	return l, nil
+}
+
+// glob converts a pattern where * matches any characters to a regexp
+func glob(pattern string) string {
+	parts := strings.Split(pattern, "*")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	return strings.Join(parts, ".*")
+}
+
+// Len returns the number of entries
+func (l *List) Len() int {
+	return len(l.entries)
+}
+
+// Match returns the first entry matching a URL
+func (l *List) Match(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return "", false
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	target := host + strings.ToLower(u.Path)
+	for _, e := range l.entries {
+		if e.pattern != nil {
+			if e.pattern.MatchString(target) {
+				return e.raw, true
+			}
+		} else if host == e.domain || strings.HasSuffix(host, "."+e.domain) {
+			return e.raw, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package browser reads browser history for use outside hist_scanner: it
+// finds the users of the machine, their browser profiles and the visits
+// recorded in them. History databases locked by a running browser are read
+// from a temporary copy.
+package browser
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"hist_scanner/internal/browser"
+	"hist_scanner/internal/dto"
+	"hist_scanner/internal/platform"
+)
+
+// User is an account whose browser data can be read
+type User struct {
+	Username string
+	HomeDir  string
+
+	u platform.User // Platform details (SID, redirected folders) of enumerated users
+}
+
+// NewUser returns a user with a home directory in the default layout of
+// the current OS
+func NewUser(username, homeDir string) User {
+	return User{Username: username, HomeDir: homeDir, u: platform.User{Username: username, HomeDir: homeDir}}
+}
+
+// platformUser returns the internal user, keeping changes to the public fields
+func (u User) platformUser() platform.User {
+	pu := u.u
+	pu.Username, pu.HomeDir = u.Username, u.HomeDir
+	return pu
+}
+
+// Users returns the users of the machine. Reading other users' browser data
+// needs administrator rights.
+func Users() ([]User, error) {
+	users, err := platform.GetAllUsers()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]User, len(users))
+	for i, u := range users {
+		res[i] = User{Username: u.Username, HomeDir: u.HomeDir, u: u}
+	}
+	return res, nil
+}
+
+// CurrentUser returns the user running the process
+func CurrentUser() (User, error) {
+	u, err := platform.GetCurrentUser()
+	if err != nil {
+		return User{}, err
+	}
+	return User{Username: u.Username, HomeDir: u.HomeDir, u: *u}, nil
+}
+
+// Profile is a browser profile of a user
+type Profile struct {
+	Name string // Profile name, e.g. Default or "Profile 1"
+	Path string // Profile directory
+}
+
+// Cursor is the position of the last visit read from a profile. Visits
+// after Time or, if RowID is set, with a higher row id are newer. The zero
+// cursor reads all history.
+type Cursor struct {
+	Time  time.Time
+	RowID int64
+}
+
+// Visit is one history entry
+type Visit struct {
+	URL   string
+	Time  time.Time
+	RowID int64 // Row id in the browser's database, for the next Cursor
+}
+
+// Browser reads the history of one browser
+type Browser struct {
+	b browser.Browser
+}
+
+// All returns the supported browsers
+func All() []*Browser {
+	all := browser.All()
+	res := make([]*Browser, len(all))
+	for i, b := range all {
+		res[i] = &Browser{b}
+	}
+	return res
+}
+
+// ByName returns a supported browser by name (case-insensitive), or nil
+func ByName(name string) *Browser {
+	b := browser.ByName(strings.ToLower(name))
+	if b == nil {
+		return nil
+	}
+	return &Browser{b}
+}
+
+// Names returns the names of the supported browsers
+func Names() []string {
+	return browser.SupportedBrowserNames()
+}
+
+// Name returns the browser name, e.g. chrome or firefox
+func (b *Browser) Name() string {
+	return b.b.Name()
+}
+
+// Profiles returns a user's profiles of the browser
+func (b *Browser) Profiles(user User) ([]Profile, error) {
+	profiles, err := b.b.FindProfiles(user.platformUser())
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Profile, len(profiles))
+	for i, p := range profiles {
+		res[i] = Profile{Name: p.Name, Path: p.Path}
+	}
+	return res, nil
+}
+
+// History calls fn for each visit of a profile newer than since, in row id
+// order. An error from fn or the cancellation of ctx stops reading and is
+// returned.
+func (b *Browser) History(ctx context.Context, profile Profile, since Cursor, fn func(Visit) error) error {
+	cursor := browser.Cursor{RowID: since.RowID}
+	if !since.Time.IsZero() {
+		cursor.Timestamp = since.Time.UnixMilli()
+	}
+	return b.b.StreamHistory(ctx, browser.Profile{Name: profile.Name, Path: profile.Path}, cursor, func(site dto.VisitedSite) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fn(Visit{URL: site.URL, Time: time.UnixMilli(site.Timestamp), RowID: site.RowID})
+	})
+}
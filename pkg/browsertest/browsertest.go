@@ -0,0 +1,77 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package browsertest provides an in-memory browser for integration tests of
+// the pipeline behind the scanner. A registered Browser is scanned by
+// pkg/scanner and listed by pkg/browser like an installed browser, but
+// serves synthetic profiles and history instead of reading databases. Tests
+// that need real database files can create them with hist_scanner debug
+// generate and scan them with run --root.
+package browsertest
+
+import (
+	"math/rand/v2"
+	"time"
+
+	"hist_scanner/internal/browser"
+	"hist_scanner/internal/dto"
+	"hist_scanner/internal/fixture"
+)
+
+// Visit is one history entry of a fake profile
+type Visit struct {
+	URL  string
+	Time time.Time
+}
+
+// Browser is an in-memory browser
+type Browser struct {
+	f *browser.Fake
+}
+
+// New returns a browser without profiles. Named like a supported browser
+// (e.g. chrome), it replaces that browser while registered.
+func New(name string) *Browser {
+	return &Browser{browser.NewFake(name)}
+}
+
+// Name returns the browser name
+func (b *Browser) Name() string {
+	return b.f.Name()
+}
+
+// AddVisits records visits in a user's profile, creating the profile. The
+// profiles of user "" belong to every user scanned. Visits added later are
+// new to the next scan whatever their time, like rows appended to a history
+// database.
+func (b *Browser) AddVisits(user, profile string, visits ...Visit) {
+	sites := make([]dto.VisitedSite, len(visits))
+	for i, v := range visits {
+		sites[i] = dto.VisitedSite{URL: v.URL, Timestamp: v.Time.UnixMilli()}
+	}
+	b.f.AddVisits(user, profile, sites...)
+}
+
+// Register makes scans and pkg/browser read this browser until Unregister.
+// Registration is process-wide, like the other scanner settings.
+func (b *Browser) Register() {
+	browser.Register(b.f)
+}
+
+// Unregister removes the browser from scans
+func (b *Browser) Unregister() {
+	browser.Unregister(b.f.Name())
+}
+
+// Generate returns n realistic visits over the last days, oldest first:
+// few sites get most visits, working hours are busier, about 40% go to SaaS
+// services of the built-in catalog and 2% are URLs the scanner drops. The
+// same seed gives the same sites at the same times relative to now.
+func Generate(n, days int, seed uint64) []Visit {
+	generated := fixture.Visits(n, days, rand.New(rand.NewPCG(seed, seed)), time.Now())
+	visits := make([]Visit, len(generated))
+	for i, v := range generated {
+		visits[i] = Visit{URL: v.URL, Time: v.Time}
+	}
+	return visits
+}
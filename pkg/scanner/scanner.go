@@ -0,0 +1,136 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package scanner embeds the hist_scanner scan in another agent: one Run
+// reads the new browser history of the machine's users and sends it to the
+// server, keeping scan positions in a state file like the scanner does.
+//
+// Scanners share process-wide settings (user enumeration, database copies),
+// so a process should run one scan at a time.
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"hist_scanner/internal/config"
+	"hist_scanner/internal/scanner"
+)
+
+// Options configures a Scanner. Zero values keep the value of ConfigFile,
+// or the scanner's default if there is none.
+type Options struct {
+	// ConfigFile is a hist_scanner YAML config holding the settings Options
+	// does not cover (catalog, exclusions, events); empty uses the defaults
+	ConfigFile string
+
+	ServerURL   string
+	APIKey      string
+	Source      string        // Identifies the sending agent; default hist_scanner
+	StateFile   string        // Scan positions; default the system (privileged) or per-user state location
+	InitialDays int           // Days of history sent on the first scan of a profile; default 7
+	Timeout     time.Duration // Per request; default 30s
+	LogFile     string        // Path, STDERR, or empty to discard the log
+	LogLevel    string        // debug, info, warn or error; default info
+
+	// CurrentUserOnly scans only the user running the process
+	CurrentUserOnly bool
+
+	// Users, Browsers and Profiles restrict the scan to matching names
+	// (case-insensitive); empty scans all
+	Users    []string
+	Browsers []string
+	Profiles []string
+
+	Full    bool   // Ignore scan positions and rescan InitialDays
+	Days    int    // Days rescanned with Full instead of InitialDays, 0 for InitialDays
+	DryRun  bool   // Print payloads as JSON instead of sending them
+	Version string // Agent version reported in payloads
+}
+
+// Result describes a finished scan
+type Result struct {
+	UsersScanned    int
+	ProfilesScanned int
+	EntriesSent     int
+	Errors          []string
+	ProfileErrors   []ProfileError
+	ExitCode        int // 0 success, 1 partial failure, 2 nothing sent, like the CLI
+}
+
+// ProfileError is a profile that could not be scanned, or a user's home
+// (no Browser) or browser data (no Profile) that could not be read
+type ProfileError struct {
+	User     string
+	Browser  string
+	Profile  string
+	Category string // permission, locked, schema, corrupt, timeout, copy_refused, send or read
+	Severity string // error, or warning if it does not fail the run (error_severity)
+	Message  string
+}
+
+// Scanner scans browser history and sends it to the server
+type Scanner struct {
+	s *scanner.Scanner
+}
+
+// New returns a scanner for opts
+func New(opts Options) (*Scanner, error) {
+	cfg := config.DefaultConfig()
+	if opts.ConfigFile != "" {
+		var err error
+		if cfg, err = config.LoadFile(opts.ConfigFile); err != nil {
+			return nil, err
+		}
+	}
+	cfg.ApplyFlags(opts.ServerURL, opts.APIKey, opts.StateFile, opts.LogFile, opts.InitialDays, 0, false, false, opts.Timeout)
+	if opts.Source != "" {
+		cfg.Source = opts.Source
+	}
+	if opts.LogLevel != "" {
+		cfg.LogLevel = opts.LogLevel
+	}
+	if opts.CurrentUserOnly {
+		cfg.CurrentUserOnly = true
+	}
+	if !opts.DryRun {
+		if err := cfg.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid options: %w", err)
+		}
+	}
+
+	s, err := scanner.New(cfg, opts.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	s.SetFilter(scanner.Filter{Users: opts.Users, Browsers: opts.Browsers, Profiles: opts.Profiles})
+	s.SetFull(opts.Full, opts.Days)
+	s.SetVersion(opts.Version)
+	return &Scanner{s}, nil
+}
+
+// Run scans once. Canceling ctx stops the scan at the next history entry;
+// what was sent until then is kept, and ctx's error is returned with the
+// result.
+func (s *Scanner) Run(ctx context.Context) (Result, error) {
+	r := s.s.Run(ctx)
+	result := Result{
+		UsersScanned:    r.UsersScanned,
+		ProfilesScanned: r.ProfilesScanned,
+		EntriesSent:     r.EntriesSent,
+		Errors:          r.Errors,
+		ExitCode:        int(r.ExitCode),
+	}
+	for _, pe := range r.ProfileErrors {
+		result.ProfileErrors = append(result.ProfileErrors, ProfileError{
+			User:     pe.Username,
+			Browser:  pe.Browser,
+			Profile:  pe.Profile,
+			Category: pe.Category,
+			Severity: pe.Severity,
+			Message:  pe.Message,
+		})
+	}
+	return result, ctx.Err()
+}
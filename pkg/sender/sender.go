@@ -0,0 +1,142 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package sender uploads browser visits to a hist_scanner server in the
+// format of the scanner: chunked by compressed size, gzip-compressed with a
+// fallback for servers that reject it, and optionally signed.
+package sender
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"hist_scanner/internal/dto"
+	"hist_scanner/internal/sender"
+	"hist_scanner/pkg/browser"
+)
+
+// Options configures a Client. Zero values use the scanner's defaults.
+type Options struct {
+	ServerURL   string        // Upload endpoint; /visited-sites is appended if missing
+	APIKey      string        // Sent as "<AuthHeader>: <AuthScheme> <key>"
+	AuthHeader  string        // Default Authorization
+	AuthScheme  string        // Default ProxyToken; "none" sends the bare key
+	Timeout     time.Duration // Per request; default 30s
+	ChunkSizeKB int           // Largest compressed chunk; default 1024
+	NoCompress  bool          // Send uncompressed
+	Msgpack     bool          // Encode as MessagePack; servers that reject it get JSON
+	ResumableKB int           // Upload chunks of at least this size resumably; 0 never
+	Concurrency int           // Chunks uploaded at once; default 1
+	HealthURL   string        // Checked by TestConnection: a URL or a path on the server, e.g. /healthz
+
+	// Headers are added to every request, e.g. {"X-Tenant-Id": "acme"}
+	Headers map[string]string
+
+	// Sign returns the signature of a request body, sent in the
+	// X-Device-Signature header; nil sends unsigned requests
+	Sign func(body []byte) string
+}
+
+// Client uploads visits to the server
+type Client struct {
+	c *sender.Client
+}
+
+// New returns a client for the server of opts
+func New(opts Options) (*Client, error) {
+	if opts.ServerURL == "" {
+		return nil, fmt.Errorf("server URL is required")
+	}
+	if opts.APIKey == "" {
+		return nil, fmt.Errorf("API key is required")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.ChunkSizeKB <= 0 {
+		opts.ChunkSizeKB = 1024
+	}
+	c := sender.NewClient(opts.ServerURL, opts.APIKey, opts.Timeout, opts.ChunkSizeKB, !opts.NoCompress)
+	if opts.AuthHeader != "" || opts.AuthScheme != "" {
+		header, scheme := opts.AuthHeader, opts.AuthScheme
+		if header == "" {
+			header = "Authorization"
+		}
+		switch {
+		case scheme == "":
+			scheme = "ProxyToken"
+		case strings.EqualFold(scheme, "none"):
+			scheme = ""
+		}
+		c.SetAuth(header, scheme)
+	}
+	c.SetHeaders(opts.Headers)
+	c.SetResumable(opts.ResumableKB)
+	c.SetConcurrency(opts.Concurrency)
+	c.SetHealthURL(opts.HealthURL)
+	if opts.Msgpack {
+		c.SetEncoding(sender.EncodingMsgpack)
+	}
+	if opts.Sign != nil {
+		c.SetSigner(opts.Sign)
+	}
+	return &Client{c}, nil
+}
+
+// Payload is the visits of one user
+type Payload struct {
+	Username string
+	Source   string // Identifies the sending agent, e.g. hist_scanner
+	Visits   []browser.Visit
+}
+
+// Result describes what Send uploaded
+type Result struct {
+	Sent      int       // Visits accepted by the server
+	Failed    int       // Visits of chunks rejected or not sent
+	Chunks    int       // Requests that succeeded
+	BytesSent int64     // After compression
+	Last      time.Time // Newest visit sent, for the next browser.Cursor
+	LastRowID int64     // Highest row id sent
+	LastError error     // Why the last failed chunk failed, with the server's response
+}
+
+// Send uploads a payload in chunks. No chunk is started after one failed;
+// Last and LastRowID cover the chunks accepted in order before it. An error
+// is returned only if none was accepted. Canceling ctx aborts the requests
+// in progress.
+func (c *Client) Send(ctx context.Context, payload Payload) (Result, error) {
+	sites := make([]dto.VisitedSite, len(payload.Visits))
+	for i, v := range payload.Visits {
+		sites[i] = dto.VisitedSite{URL: v.URL, Timestamp: v.Time.UnixMilli(), RowID: v.RowID}
+	}
+	res, maxTimestamp, err := c.c.WithContext(ctx).Send(dto.VisitedSitesDTO{
+		Principal:    dto.NewUserPrincipal(payload.Username),
+		Source:       payload.Source,
+		VisitedSites: sites,
+	})
+	var r Result
+	if res != nil {
+		r = Result{Sent: res.TotalSent, Failed: res.FailedCount, Chunks: res.ChunksSent, BytesSent: res.BytesSent, LastRowID: res.MaxRowID, LastError: res.LastError}
+	}
+	if maxTimestamp > 0 {
+		r.Last = time.UnixMilli(maxTimestamp)
+	}
+	return r, err
+}
+
+// ClockSkew returns how far the server's clock is ahead of the device's
+// (negative if it is behind), estimated from the Date headers of the
+// server's responses so far, and false before the first
+func (c *Client) ClockSkew() (time.Duration, bool) {
+	return c.c.ClockSkew()
+}
+
+// TestConnection checks that the server is reachable and accepts the API
+// key without sending visits: GET of Options.HealthURL, or HEAD of the upload
+// endpoint
+func (c *Client) TestConnection(ctx context.Context) error {
+	return c.c.WithContext(ctx).TestConnection()
+}
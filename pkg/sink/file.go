@@ -0,0 +1,87 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+func init() {
+	Register("file", openFile)
+}
+
+// fileSink appends each payload to a file as one JSON line, readable by the
+// owner only
+type fileSink struct {
+	mu    sync.Mutex
+	f     *os.File
+	w     *bufio.Writer
+	stats Stats
+}
+
+// openFile opens the file sink of file:<path>
+func openFile(path string) (Sink, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no path, use file:<path>")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSink{f: f, w: bufio.NewWriter(f)}, nil
+}
+
+func (s *fileSink) Send(ctx context.Context, payload Payload) error {
+	started := time.Now()
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Encode += time.Since(started)
+	written := time.Now()
+	if _, err := s.w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write payload: %w", err)
+	}
+	s.stats.Transfer += time.Since(written)
+	s.stats.Bytes += int64(len(data)) + 1
+	return nil
+}
+
+// Flush writes buffered payloads through to the disk
+func (s *fileSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.w.Flush(); err != nil {
+		return fmt.Errorf("failed to write payloads: %w", err)
+	}
+	return s.f.Sync()
+}
+
+func (s *fileSink) Close() error {
+	err := s.Flush(context.Background())
+	if closeErr := s.f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (s *fileSink) TakeStats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.stats
+	s.stats = Stats{}
+	return st
+}
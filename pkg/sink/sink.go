@@ -0,0 +1,210 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+// Package sink delivers the scanner's visit payloads. The scanner sends each
+// batch to the sinks selected by the sinks setting; sinks are registered by
+// name, so an embedding agent can add its own with Register.
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"hist_scanner/internal/dto"
+)
+
+// Payload is one batch of a user's visits
+type Payload = dto.VisitedSitesDTO
+
+// Visit is one visit of a payload
+type Visit = dto.VisitedSite
+
+// Sink delivers payloads
+type Sink interface {
+	// Send delivers a payload. A sink that delivered only part of it
+	// returns a *PartialError.
+	Send(ctx context.Context, payload Payload) error
+
+	// Flush writes out buffered payloads; the scanner calls it at the end
+	// of every scan
+	Flush(ctx context.Context) error
+
+	// Close releases the sink
+	Close() error
+}
+
+// PartialError reports a payload of which only some visits were delivered
+type PartialError struct {
+	Sent                   int   // Visits delivered
+	MaxTimestamp, MaxRowID int64 // Newest delivered visit
+	Err                    error // Why the others were not
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("%d visits delivered: %v", e.Sent, e.Err)
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
+
+// Stats measures the sends of a sink
+type Stats struct {
+	Bytes    int64         // Bytes delivered, after compression
+	Encode   time.Duration // Encoding and compression
+	Transfer time.Duration // Network or disk time
+	Warnings []string      // Problems that did not fail a send
+}
+
+// Measurer is implemented by sinks that measure their sends
+type Measurer interface {
+	// TakeStats returns the stats since the last call
+	TakeStats() Stats
+}
+
+// Factory opens a sink. arg is the part of the sink spec after "name:",
+// e.g. the path of file:/var/log/visits.jsonl.
+type Factory func(arg string) (Sink, error)
+
+var (
+	mu        sync.RWMutex
+	factories = make(map[string]Factory)
+)
+
+// HTTP is the name of the built-in sink uploading to server_url. The
+// scanner opens it with its own client, so Open does not.
+const HTTP = "http"
+
+// Register makes a sink available by name. It panics if the name is taken,
+// like database/sql drivers.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	name = strings.ToLower(name)
+	if _, ok := factories[name]; ok || name == HTTP {
+		panic("sink: Register called twice for " + name)
+	}
+	factories[name] = factory
+}
+
+// Names returns the names of the available sinks
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := []string{HTTP}
+	for name := range factories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Parse splits a sink spec "name[:arg]" and checks that the sink exists
+func Parse(spec string) (name, arg string, err error) {
+	name, arg, _ = strings.Cut(strings.TrimSpace(spec), ":")
+	name = strings.ToLower(name)
+	if name == HTTP {
+		return name, arg, nil
+	}
+	mu.RLock()
+	_, ok := factories[name]
+	mu.RUnlock()
+	if !ok {
+		return "", "", fmt.Errorf("unknown sink %q (available: %s)", name, strings.Join(Names(), ", "))
+	}
+	return name, arg, nil
+}
+
+// Open opens the sink of a spec "name[:arg]"
+func Open(spec string) (Sink, error) {
+	name, arg, err := Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	if name == HTTP {
+		return nil, fmt.Errorf("the %s sink is opened by the scanner", HTTP)
+	}
+	mu.RLock()
+	factory := factories[name]
+	mu.RUnlock()
+	s, err := factory(arg)
+	if err != nil {
+		return nil, fmt.Errorf("sink %s: %w", name, err)
+	}
+	return s, nil
+}
+
+// multi sends every payload to several sinks
+type multi []Sink
+
+// Multi returns a sink that sends every payload to all sinks. A payload
+// counts as delivered if every sink delivered it; the first failure is
+// returned, the other sinks still receive it.
+func Multi(sinks ...Sink) Sink {
+	if len(sinks) == 1 {
+		return sinks[0]
+	}
+	return multi(sinks)
+}
+
+func (m multi) Send(ctx context.Context, payload Payload) error {
+	var partial *PartialError
+	var firstErr error
+	for _, s := range m {
+		err := s.Send(ctx, payload)
+		var p *PartialError
+		switch {
+		case err == nil:
+		case errors.As(err, &p):
+			// Delivered by all sinks only up to the least any of them delivered
+			if partial == nil || p.Sent < partial.Sent {
+				partial = p
+			}
+		case firstErr == nil:
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	if partial != nil {
+		return partial
+	}
+	return nil
+}
+
+func (m multi) Flush(ctx context.Context) error {
+	var errs []error
+	for _, s := range m {
+		errs = append(errs, s.Flush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (m multi) Close() error {
+	var errs []error
+	for _, s := range m {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// TakeStats sums the stats of the sinks that measure their sends
+func (m multi) TakeStats() Stats {
+	var total Stats
+	for _, s := range m {
+		if ms, ok := s.(Measurer); ok {
+			st := ms.TakeStats()
+			total.Bytes += st.Bytes
+			total.Encode += st.Encode
+			total.Transfer += st.Transfer
+			total.Warnings = append(total.Warnings, st.Warnings...)
+		}
+	}
+	return total
+}
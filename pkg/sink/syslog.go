@@ -0,0 +1,90 @@
+//go:build !windows
+
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+	"net/url"
+)
+
+func init() {
+	Register("syslog", openSyslog)
+}
+
+// syslogSink writes one JSON message per visit to syslog with the user
+// facility, so a SIEM can collect visits with the rest of the logs
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+// syslogVisit is the message of one visit
+type syslogVisit struct {
+	User    string `json:"user"`
+	Source  string `json:"source"`
+	Browser string `json:"browser,omitempty"`
+	Visit
+}
+
+// openSyslog opens the syslog sink of syslog (the local daemon) or
+// syslog:udp://host:514 and syslog:tcp://host:514
+func openSyslog(arg string) (Sink, error) {
+	network, addr := "", ""
+	if arg != "" {
+		u, err := url.Parse(arg)
+		if err != nil || (u.Scheme != "udp" && u.Scheme != "tcp") || u.Host == "" {
+			return nil, fmt.Errorf("%q is not udp://host:port or tcp://host:port", arg)
+		}
+		network, addr = u.Scheme, u.Host
+	}
+	w, err := syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_USER, "hist_scanner")
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	return &syslogSink{w: w}, nil
+}
+
+func (s *syslogSink) Send(ctx context.Context, payload Payload) error {
+	for i, v := range payload.VisitedSites {
+		if err := ctx.Err(); err != nil {
+			return s.partial(payload.VisitedSites[:i], err)
+		}
+		msg := syslogVisit{User: payload.Principal.Name, Source: payload.Source, Visit: v}
+		if payload.Browser != nil {
+			msg.Browser = payload.Browser.Name
+		}
+		data, err := json.Marshal(msg)
+		if err == nil {
+			err = s.w.Info(string(data))
+		}
+		if err != nil {
+			return s.partial(payload.VisitedSites[:i], err)
+		}
+	}
+	return nil
+}
+
+// partial reports the visits written before a failure
+func (s *syslogSink) partial(sent []Visit, err error) error {
+	if len(sent) == 0 {
+		return err
+	}
+	p := &PartialError{Sent: len(sent), Err: err}
+	for _, v := range sent {
+		p.MaxTimestamp, p.MaxRowID = max(p.MaxTimestamp, v.Timestamp), max(p.MaxRowID, v.RowID)
+	}
+	return p
+}
+
+func (s *syslogSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *syslogSink) Close() error {
+	return s.w.Close()
+}
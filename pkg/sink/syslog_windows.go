@@ -0,0 +1,12 @@
+// Copyright (c) 2025 Binadox (https://binadox.com)
+// This software is licensed under the zlib license. See LICENSE file for details.
+
+package sink
+
+import "fmt"
+
+func init() {
+	Register("syslog", func(string) (Sink, error) {
+		return nil, fmt.Errorf("syslog is not available on Windows")
+	})
+}